@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 type AuthStrategy interface {
@@ -41,6 +42,8 @@ type Claims struct {
 	UserName    string              `json:"preferred_username"`
 	Email       string              `json:"email"`
 	RealmAccess map[string][]string `json:"realm_access"`
+	Groups      []string            `json:"groups,omitempty"` // requires a group membership mapper in Keycloak
+	Tenant      string              `json:"tenant,omitempty"` // requires a user attribute mapper in Keycloak
 	Audience    Audience            `json:"aud,omitempty"`
 	jwt.StandardClaims
 }
@@ -56,10 +59,69 @@ func overlap(s1 []string, s2 []string) bool {
 	return false
 }
 
+// Context key under which claims of an already validated token are stored
+const claimsKey = "auth.claims"
+
+// Authenticated reports whether the user of the request was identified by a valid token or client certificate,
+// only then are its user headers and Authorization header verified
+func Authenticated(c echo.Context) bool {
+	_, ok := c.Get(claimsKey).(*Claims)
+	return ok
+}
+
+// Identify validates the bearer token if one is provided and sets user headers.
+// Requests without a valid token continue anonymously with user headers removed,
+// so that public routes can rely on these headers.
+func Identify(strategy AuthStrategy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// user already identified by client certificate
+			if _, ok := c.Get(claimsKey).(*Claims); ok {
+				return next(c)
+			}
+
+			anonymous := func() error {
+				c.Request().Header.Del("X-SEPEX-User-Email")
+				return next(c)
+			}
+			c.Request().Header.Del("X-SEPEX-User-Roles")
+			c.Request().Header.Del("X-SEPEX-User-Groups")
+			c.Request().Header.Del("X-SEPEX-User-Tenant")
+
+			tokenString, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if !ok || tokenString == "" {
+				return anonymous()
+			}
+
+			claims, err := strategy.ValidateToken(tokenString)
+			if err != nil || strategy.ValidateUser(c, claims) != nil {
+				return anonymous()
+			}
+			if err := strategy.SetUserRolesHeader(c, claims); err != nil {
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+			c.Set(claimsKey, claims)
+			return next(c)
+		}
+	}
+}
+
 // Middleware
-func Authorize(strategy AuthStrategy) echo.MiddlewareFunc {
+// Requests for which any of skippers returns true are passed on without validation.
+func Authorize(strategy AuthStrategy, skippers ...middleware.Skipper) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			for _, skip := range skippers {
+				if skip(c) {
+					return next(c)
+				}
+			}
+
+			// token already validated by Identify or user identified by client certificate
+			if _, ok := c.Get(claimsKey).(*Claims); ok {
+				return next(c)
+			}
+
 			authHead := c.Request().Header.Get("Authorization")
 			// Check if the Authorization header is missing or not in the expected format
 			if authHead == "" || !strings.HasPrefix(authHead, "Bearer ") {
@@ -85,6 +147,7 @@ func Authorize(strategy AuthStrategy) echo.MiddlewareFunc {
 			if err != nil {
 				return c.JSON(http.StatusInternalServerError, err.Error())
 			}
+			c.Set(claimsKey, claims)
 
 			return next(c)
 		}
@@ -1,11 +1,11 @@
 package auth
 
 import (
+	"app/config"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -39,11 +39,10 @@ type KeycloakAuthStrategy struct {
 func NewKeycloakAuthStrategy() (*KeycloakAuthStrategy, error) {
 	strategy := &KeycloakAuthStrategy{
 		PublicKeys:      make(map[string]PublicKey),
-		ServiceRoleName: os.Getenv("AUTH_SERVICE_ROLE"),
+		ServiceRoleName: config.Get().Auth.ServiceRole,
 	}
 
-	kcUrl, exist := os.LookupEnv("KEYCLOAK_PUBLIC_KEYS_URL")
-	if !exist || kcUrl == "" {
+	if config.Get().Auth.KeycloakPublicKeysURL == "" {
 		return nil, errors.New("env variable KEYCLOAK_PUBLIC_KEYS_URL not set")
 	}
 
@@ -75,7 +74,7 @@ func (kas *KeycloakAuthStrategy) LoadPublicKeys() error {
 	kas.Mutex.Lock()
 	defer kas.Mutex.Unlock()
 
-	r, err := http.Get(os.Getenv("KEYCLOAK_PUBLIC_KEYS_URL"))
+	r, err := http.Get(config.Get().Auth.KeycloakPublicKeysURL)
 	if err != nil {
 		return err
 	}
@@ -145,15 +144,11 @@ func (kas *KeycloakAuthStrategy) ValidateUser(c echo.Context, claims *Claims) (e
 	return nil
 }
 
-// Set user roles to API Header
+// Set user roles, groups and tenant to API Header
+// Headers sent by the client are always replaced so that they can't be spoofed
 func (kas *KeycloakAuthStrategy) SetUserRolesHeader(c echo.Context, claims *Claims) (err error) {
-	roles, exists := claims.RealmAccess["roles"]
-	if exists {
-		rolesString := strings.Join(roles, ",")
-		c.Request().Header.Set("X-SEPEX-User-Roles", rolesString)
-	} else {
-		return nil
-	}
-
+	c.Request().Header.Set("X-SEPEX-User-Roles", strings.Join(claims.RealmAccess["roles"], ","))
+	c.Request().Header.Set("X-SEPEX-User-Groups", strings.Join(claims.Groups, ","))
+	c.Request().Header.Set("X-SEPEX-User-Tenant", claims.Tenant)
 	return nil
 }
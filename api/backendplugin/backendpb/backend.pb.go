@@ -0,0 +1,775 @@
+// Job backend contract of out-of-process backend plugins, see package backendplugin.
+// Generate the Go code from the api directory with protoc-gen-go and protoc-gen-go-grpc:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative backendplugin/backendpb/backend.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: backendplugin/backendpb/backend.proto
+
+package backendpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type InfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoRequest) Reset() {
+	*x = InfoRequest{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoRequest) ProtoMessage() {}
+
+func (x *InfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoRequest.ProtoReflect.Descriptor instead.
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{0}
+}
+
+type InfoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HostType      string                 `protobuf:"bytes,1,opt,name=host_type,json=hostType,proto3" json:"host_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoResponse) Reset() {
+	*x = InfoResponse{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoResponse) ProtoMessage() {}
+
+func (x *InfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoResponse.ProtoReflect.Descriptor instead.
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *InfoResponse) GetHostType() string {
+	if x != nil {
+		return x.HostType
+	}
+	return ""
+}
+
+type CreateRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	JobId          string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	ProcessId      string                 `protobuf:"bytes,2,opt,name=process_id,json=processId,proto3" json:"process_id,omitempty"`
+	ProcessVersion string                 `protobuf:"bytes,3,opt,name=process_version,json=processVersion,proto3" json:"process_version,omitempty"`
+	// host.image of the process
+	Image   string   `protobuf:"bytes,4,opt,name=image,proto3" json:"image,omitempty"`
+	Command []string `protobuf:"bytes,5,rep,name=command,proto3" json:"command,omitempty"`
+	// Env variables of the process, including secrets, LOG_LEVEL and overrides of the execute request
+	Env  map[string]string `protobuf:"bytes,6,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Cpus float32           `protobuf:"fixed32,7,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	// MB
+	Memory int32 `protobuf:"varint,8,opt,name=memory,proto3" json:"memory,omitempty"`
+	// Inputs of processes with inputDelivery file as JSON
+	Inputs        []byte `protobuf:"bytes,9,opt,name=inputs,proto3" json:"inputs,omitempty"`
+	Submitter     string `protobuf:"bytes,10,opt,name=submitter,proto3" json:"submitter,omitempty"`
+	Tenant        string `protobuf:"bytes,11,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRequest) Reset() {
+	*x = CreateRequest{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRequest) ProtoMessage() {}
+
+func (x *CreateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRequest.ProtoReflect.Descriptor instead.
+func (*CreateRequest) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetProcessId() string {
+	if x != nil {
+		return x.ProcessId
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetProcessVersion() string {
+	if x != nil {
+		return x.ProcessVersion
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetCpus() float32 {
+	if x != nil {
+		return x.Cpus
+	}
+	return 0
+}
+
+func (x *CreateRequest) GetMemory() int32 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *CreateRequest) GetInputs() []byte {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetSubmitter() string {
+	if x != nil {
+		return x.Submitter
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+type CreateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the job on the backend
+	BackendId     string `protobuf:"bytes,1,opt,name=backend_id,json=backendId,proto3" json:"backend_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateResponse) Reset() {
+	*x = CreateResponse{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateResponse) ProtoMessage() {}
+
+func (x *CreateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateResponse.ProtoReflect.Descriptor instead.
+func (*CreateResponse) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateResponse) GetBackendId() string {
+	if x != nil {
+		return x.BackendId
+	}
+	return ""
+}
+
+type JobRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackendId     string                 `protobuf:"bytes,1,opt,name=backend_id,json=backendId,proto3" json:"backend_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRef) Reset() {
+	*x = JobRef{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRef) ProtoMessage() {}
+
+func (x *JobRef) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRef.ProtoReflect.Descriptor instead.
+func (*JobRef) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *JobRef) GetBackendId() string {
+	if x != nil {
+		return x.BackendId
+	}
+	return ""
+}
+
+type JobState struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// accepted, running, successful, failed or dismissed
+	Status  string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Updated *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated,proto3" json:"updated,omitempty"`
+	// Why the job failed, logged in the server logs of the job
+	Reason        string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobState) Reset() {
+	*x = JobState{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobState) ProtoMessage() {}
+
+func (x *JobState) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobState.ProtoReflect.Descriptor instead.
+func (*JobState) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *JobState) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobState) GetUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *JobState) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type KillRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackendId     string                 `protobuf:"bytes,1,opt,name=backend_id,json=backendId,proto3" json:"backend_id,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillRequest) Reset() {
+	*x = KillRequest{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillRequest) ProtoMessage() {}
+
+func (x *KillRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillRequest.ProtoReflect.Descriptor instead.
+func (*KillRequest) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *KillRequest) GetBackendId() string {
+	if x != nil {
+		return x.BackendId
+	}
+	return ""
+}
+
+func (x *KillRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type KillResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillResponse) Reset() {
+	*x = KillResponse{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillResponse) ProtoMessage() {}
+
+func (x *KillResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillResponse.ProtoReflect.Descriptor instead.
+func (*KillResponse) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{7}
+}
+
+type LogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BackendId     string                 `protobuf:"bytes,1,opt,name=backend_id,json=backendId,proto3" json:"backend_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogsRequest) Reset() {
+	*x = LogsRequest{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogsRequest) ProtoMessage() {}
+
+func (x *LogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogsRequest.ProtoReflect.Descriptor instead.
+func (*LogsRequest) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LogsRequest) GetBackendId() string {
+	if x != nil {
+		return x.BackendId
+	}
+	return ""
+}
+
+func (x *LogsRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type LogsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lines         []string               `protobuf:"bytes,1,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogsResponse) Reset() {
+	*x = LogsResponse{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogsResponse) ProtoMessage() {}
+
+func (x *LogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogsResponse.ProtoReflect.Descriptor instead.
+func (*LogsResponse) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *LogsResponse) GetLines() []string {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type JobMetadata struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Image the job ran, with its digest if known
+	ImageUri      string                 `protobuf:"bytes,1,opt,name=image_uri,json=imageUri,proto3" json:"image_uri,omitempty"`
+	ImageDigest   string                 `protobuf:"bytes,2,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=started,proto3" json:"started,omitempty"`
+	Ended         *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=ended,proto3" json:"ended,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobMetadata) Reset() {
+	*x = JobMetadata{}
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobMetadata) ProtoMessage() {}
+
+func (x *JobMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_backendplugin_backendpb_backend_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobMetadata.ProtoReflect.Descriptor instead.
+func (*JobMetadata) Descriptor() ([]byte, []int) {
+	return file_backendplugin_backendpb_backend_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *JobMetadata) GetImageUri() string {
+	if x != nil {
+		return x.ImageUri
+	}
+	return ""
+}
+
+func (x *JobMetadata) GetImageDigest() string {
+	if x != nil {
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *JobMetadata) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *JobMetadata) GetEnded() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ended
+	}
+	return nil
+}
+
+var File_backendplugin_backendpb_backend_proto protoreflect.FileDescriptor
+
+const file_backendplugin_backendpb_backend_proto_rawDesc = "" +
+	"\n" +
+	"%backendplugin/backendpb/backend.proto\x12\x10sepex.backend.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\r\n" +
+	"\vInfoRequest\"+\n" +
+	"\fInfoResponse\x12\x1b\n" +
+	"\thost_type\x18\x01 \x01(\tR\bhostType\"\x8c\x03\n" +
+	"\rCreateRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1d\n" +
+	"\n" +
+	"process_id\x18\x02 \x01(\tR\tprocessId\x12'\n" +
+	"\x0fprocess_version\x18\x03 \x01(\tR\x0eprocessVersion\x12\x14\n" +
+	"\x05image\x18\x04 \x01(\tR\x05image\x12\x18\n" +
+	"\acommand\x18\x05 \x03(\tR\acommand\x12:\n" +
+	"\x03env\x18\x06 \x03(\v2(.sepex.backend.v1.CreateRequest.EnvEntryR\x03env\x12\x12\n" +
+	"\x04cpus\x18\a \x01(\x02R\x04cpus\x12\x16\n" +
+	"\x06memory\x18\b \x01(\x05R\x06memory\x12\x16\n" +
+	"\x06inputs\x18\t \x01(\fR\x06inputs\x12\x1c\n" +
+	"\tsubmitter\x18\n" +
+	" \x01(\tR\tsubmitter\x12\x16\n" +
+	"\x06tenant\x18\v \x01(\tR\x06tenant\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"/\n" +
+	"\x0eCreateResponse\x12\x1d\n" +
+	"\n" +
+	"backend_id\x18\x01 \x01(\tR\tbackendId\"'\n" +
+	"\x06JobRef\x12\x1d\n" +
+	"\n" +
+	"backend_id\x18\x01 \x01(\tR\tbackendId\"p\n" +
+	"\bJobState\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x124\n" +
+	"\aupdated\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\aupdated\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"D\n" +
+	"\vKillRequest\x12\x1d\n" +
+	"\n" +
+	"backend_id\x18\x01 \x01(\tR\tbackendId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\x0e\n" +
+	"\fKillResponse\"D\n" +
+	"\vLogsRequest\x12\x1d\n" +
+	"\n" +
+	"backend_id\x18\x01 \x01(\tR\tbackendId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\"$\n" +
+	"\fLogsResponse\x12\x14\n" +
+	"\x05lines\x18\x01 \x03(\tR\x05lines\"\xb5\x01\n" +
+	"\vJobMetadata\x12\x1b\n" +
+	"\timage_uri\x18\x01 \x01(\tR\bimageUri\x12!\n" +
+	"\fimage_digest\x18\x02 \x01(\tR\vimageDigest\x124\n" +
+	"\astarted\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x120\n" +
+	"\x05ended\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05ended2\xb0\x03\n" +
+	"\aBackend\x12E\n" +
+	"\x04Info\x12\x1d.sepex.backend.v1.InfoRequest\x1a\x1e.sepex.backend.v1.InfoResponse\x12K\n" +
+	"\x06Create\x12\x1f.sepex.backend.v1.CreateRequest\x1a .sepex.backend.v1.CreateResponse\x12>\n" +
+	"\x06Status\x12\x18.sepex.backend.v1.JobRef\x1a\x1a.sepex.backend.v1.JobState\x12E\n" +
+	"\x04Kill\x12\x1d.sepex.backend.v1.KillRequest\x1a\x1e.sepex.backend.v1.KillResponse\x12E\n" +
+	"\x04Logs\x12\x1d.sepex.backend.v1.LogsRequest\x1a\x1e.sepex.backend.v1.LogsResponse\x12C\n" +
+	"\bMetadata\x12\x18.sepex.backend.v1.JobRef\x1a\x1d.sepex.backend.v1.JobMetadataB\x1dZ\x1bapp/backendplugin/backendpbb\x06proto3"
+
+var (
+	file_backendplugin_backendpb_backend_proto_rawDescOnce sync.Once
+	file_backendplugin_backendpb_backend_proto_rawDescData []byte
+)
+
+func file_backendplugin_backendpb_backend_proto_rawDescGZIP() []byte {
+	file_backendplugin_backendpb_backend_proto_rawDescOnce.Do(func() {
+		file_backendplugin_backendpb_backend_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_backendplugin_backendpb_backend_proto_rawDesc), len(file_backendplugin_backendpb_backend_proto_rawDesc)))
+	})
+	return file_backendplugin_backendpb_backend_proto_rawDescData
+}
+
+var file_backendplugin_backendpb_backend_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_backendplugin_backendpb_backend_proto_goTypes = []any{
+	(*InfoRequest)(nil),           // 0: sepex.backend.v1.InfoRequest
+	(*InfoResponse)(nil),          // 1: sepex.backend.v1.InfoResponse
+	(*CreateRequest)(nil),         // 2: sepex.backend.v1.CreateRequest
+	(*CreateResponse)(nil),        // 3: sepex.backend.v1.CreateResponse
+	(*JobRef)(nil),                // 4: sepex.backend.v1.JobRef
+	(*JobState)(nil),              // 5: sepex.backend.v1.JobState
+	(*KillRequest)(nil),           // 6: sepex.backend.v1.KillRequest
+	(*KillResponse)(nil),          // 7: sepex.backend.v1.KillResponse
+	(*LogsRequest)(nil),           // 8: sepex.backend.v1.LogsRequest
+	(*LogsResponse)(nil),          // 9: sepex.backend.v1.LogsResponse
+	(*JobMetadata)(nil),           // 10: sepex.backend.v1.JobMetadata
+	nil,                           // 11: sepex.backend.v1.CreateRequest.EnvEntry
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_backendplugin_backendpb_backend_proto_depIdxs = []int32{
+	11, // 0: sepex.backend.v1.CreateRequest.env:type_name -> sepex.backend.v1.CreateRequest.EnvEntry
+	12, // 1: sepex.backend.v1.JobState.updated:type_name -> google.protobuf.Timestamp
+	12, // 2: sepex.backend.v1.JobMetadata.started:type_name -> google.protobuf.Timestamp
+	12, // 3: sepex.backend.v1.JobMetadata.ended:type_name -> google.protobuf.Timestamp
+	0,  // 4: sepex.backend.v1.Backend.Info:input_type -> sepex.backend.v1.InfoRequest
+	2,  // 5: sepex.backend.v1.Backend.Create:input_type -> sepex.backend.v1.CreateRequest
+	4,  // 6: sepex.backend.v1.Backend.Status:input_type -> sepex.backend.v1.JobRef
+	6,  // 7: sepex.backend.v1.Backend.Kill:input_type -> sepex.backend.v1.KillRequest
+	8,  // 8: sepex.backend.v1.Backend.Logs:input_type -> sepex.backend.v1.LogsRequest
+	4,  // 9: sepex.backend.v1.Backend.Metadata:input_type -> sepex.backend.v1.JobRef
+	1,  // 10: sepex.backend.v1.Backend.Info:output_type -> sepex.backend.v1.InfoResponse
+	3,  // 11: sepex.backend.v1.Backend.Create:output_type -> sepex.backend.v1.CreateResponse
+	5,  // 12: sepex.backend.v1.Backend.Status:output_type -> sepex.backend.v1.JobState
+	7,  // 13: sepex.backend.v1.Backend.Kill:output_type -> sepex.backend.v1.KillResponse
+	9,  // 14: sepex.backend.v1.Backend.Logs:output_type -> sepex.backend.v1.LogsResponse
+	10, // 15: sepex.backend.v1.Backend.Metadata:output_type -> sepex.backend.v1.JobMetadata
+	10, // [10:16] is the sub-list for method output_type
+	4,  // [4:10] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_backendplugin_backendpb_backend_proto_init() }
+func file_backendplugin_backendpb_backend_proto_init() {
+	if File_backendplugin_backendpb_backend_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_backendplugin_backendpb_backend_proto_rawDesc), len(file_backendplugin_backendpb_backend_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_backendplugin_backendpb_backend_proto_goTypes,
+		DependencyIndexes: file_backendplugin_backendpb_backend_proto_depIdxs,
+		MessageInfos:      file_backendplugin_backendpb_backend_proto_msgTypes,
+	}.Build()
+	File_backendplugin_backendpb_backend_proto = out.File
+	file_backendplugin_backendpb_backend_proto_goTypes = nil
+	file_backendplugin_backendpb_backend_proto_depIdxs = nil
+}
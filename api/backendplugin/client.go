@@ -0,0 +1,82 @@
+package backendplugin
+
+import (
+	"app/backendplugin/backendpb"
+	"app/jobs"
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// client translates the calls of plugin jobs to the Backend service of a plugin
+type client struct {
+	c backendpb.BackendClient
+}
+
+func (c *client) Create(ctx context.Context, req jobs.PluginJobRequest) (string, error) {
+	resp, err := c.c.Create(ctx, &backendpb.CreateRequest{
+		JobId:          req.JobID,
+		ProcessId:      req.ProcessID,
+		ProcessVersion: req.ProcessVersion,
+		Image:          req.Image,
+		Command:        req.Cmd,
+		Env:            req.Env,
+		Cpus:           req.Resources.CPUs,
+		Memory:         int32(req.Resources.Memory),
+		Inputs:         req.Inputs,
+		Submitter:      req.Submitter,
+		Tenant:         req.Tenant,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetBackendId(), nil
+}
+
+func (c *client) Status(ctx context.Context, backendID string) (jobs.PluginJobState, error) {
+	resp, err := c.c.Status(ctx, &backendpb.JobRef{BackendId: backendID})
+	if status.Code(err) == codes.NotFound {
+		return jobs.PluginJobState{}, jobs.ErrPluginJobNotFound
+	}
+	if err != nil {
+		return jobs.PluginJobState{}, err
+	}
+	return jobs.PluginJobState{Status: resp.GetStatus(), Updated: timeOf(resp.GetUpdated()), Reason: resp.GetReason()}, nil
+}
+
+func (c *client) Kill(ctx context.Context, backendID, reason string) error {
+	_, err := c.c.Kill(ctx, &backendpb.KillRequest{BackendId: backendID, Reason: reason})
+	return err
+}
+
+func (c *client) Logs(ctx context.Context, backendID string, offset int64) ([]string, error) {
+	resp, err := c.c.Logs(ctx, &backendpb.LogsRequest{BackendId: backendID, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetLines(), nil
+}
+
+func (c *client) Metadata(ctx context.Context, backendID string) (jobs.PluginJobMetadata, error) {
+	resp, err := c.c.Metadata(ctx, &backendpb.JobRef{BackendId: backendID})
+	if err != nil {
+		return jobs.PluginJobMetadata{}, err
+	}
+	return jobs.PluginJobMetadata{
+		ImageURI:    resp.GetImageUri(),
+		ImageDigest: resp.GetImageDigest(),
+		Started:     timeOf(resp.GetStarted()),
+		Ended:       timeOf(resp.GetEnded()),
+	}, nil
+}
+
+// Zero time for unset timestamps, so that they are treated like unknown times of other jobs
+func timeOf(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
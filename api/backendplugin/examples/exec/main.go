@@ -0,0 +1,140 @@
+// Example backend plugin running jobs of host type exec as processes of the plugin. Build it into the backend plugins
+// directory of the server:
+//
+//	go build -o $BACKEND_PLUGINS_DIR/exec ./backendplugin/examples/exec
+package main
+
+import (
+	"app/backendplugin"
+	pb "app/backendplugin/backendpb"
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type execJob struct {
+	cmd     *exec.Cmd
+	status  string
+	reason  string
+	updated time.Time
+	started time.Time
+	ended   time.Time
+	logs    []string
+}
+
+type execBackend struct {
+	pb.UnimplementedBackendServer
+	mu   sync.Mutex
+	jobs map[string]*execJob
+}
+
+func (b *execBackend) Info(context.Context, *pb.InfoRequest) (*pb.InfoResponse, error) {
+	return &pb.InfoResponse{HostType: "exec"}, nil
+}
+
+func (b *execBackend) Create(_ context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	if len(req.Command) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "command is empty")
+	}
+	cmd := exec.Command(req.Command[0], req.Command[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range req.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	now := time.Now()
+	j := &execJob{cmd: cmd, status: "running", updated: now, started: now}
+	b.mu.Lock()
+	b.jobs[req.JobId] = j
+	b.mu.Unlock()
+
+	go func() {
+		s := bufio.NewScanner(stdout)
+		for s.Scan() {
+			b.mu.Lock()
+			j.logs = append(j.logs, s.Text())
+			b.mu.Unlock()
+		}
+		err := cmd.Wait()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		j.ended, j.updated = time.Now(), time.Now()
+		switch {
+		case j.status == "dismissed":
+		case err != nil:
+			j.status, j.reason = "failed", err.Error()
+		default:
+			j.status = "successful"
+		}
+	}()
+	return &pb.CreateResponse{BackendId: req.JobId}, nil
+}
+
+func (b *execBackend) job(id string) (*execJob, error) {
+	j, ok := b.jobs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no job %s", id)
+	}
+	return j, nil
+}
+
+func (b *execBackend) Status(_ context.Context, ref *pb.JobRef) (*pb.JobState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j, err := b.job(ref.BackendId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.JobState{Status: j.status, Updated: timestamppb.New(j.updated), Reason: j.reason}, nil
+}
+
+func (b *execBackend) Kill(_ context.Context, req *pb.KillRequest) (*pb.KillResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j, err := b.job(req.BackendId)
+	if err != nil {
+		return nil, err
+	}
+	j.status, j.updated = "dismissed", time.Now()
+	_ = j.cmd.Process.Kill()
+	return &pb.KillResponse{}, nil
+}
+
+func (b *execBackend) Logs(_ context.Context, req *pb.LogsRequest) (*pb.LogsResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j, err := b.job(req.BackendId)
+	if err != nil {
+		return nil, err
+	}
+	offset := min(int(req.Offset), len(j.logs))
+	return &pb.LogsResponse{Lines: append([]string{}, j.logs[offset:]...)}, nil
+}
+
+func (b *execBackend) Metadata(_ context.Context, ref *pb.JobRef) (*pb.JobMetadata, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j, err := b.job(ref.BackendId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.JobMetadata{Started: timestamppb.New(j.started), Ended: timestamppb.New(j.ended)}, nil
+}
+
+func main() {
+	backendplugin.Serve(&execBackend{jobs: make(map[string]*execJob)})
+}
@@ -0,0 +1,98 @@
+package backendplugin
+
+import (
+	"app/backendplugin/backendpb"
+	"app/jobs"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Max time a plugin has to start and return its host type
+const startTimeout = 30 * time.Second
+
+// Load starts the executables in dir as backend plugins and registers a job backend for the host type of each.
+// Files starting with `.` and files that are not executable are skipped. Does nothing if dir is empty.
+func Load(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not read backend plugins directory: %s", err.Error())
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, e := range entries {
+		if e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		hostType, err := start(path)
+		if err != nil {
+			plugin.CleanupClients()
+			return fmt.Errorf("backend plugin %s: %s", path, err.Error())
+		}
+		log.Infof("Backend plugin %s registered for host type %s", e.Name(), hostType)
+	}
+	return nil
+}
+
+// Start the plugin at path and register its backend, returns its host type
+func start(path string) (string, error) {
+	pc := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          plugin.PluginSet{pluginName: &grpcPlugin{}},
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Managed:          true,
+		StartTimeout:     startTimeout,
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "backend-plugin." + filepath.Base(path),
+			Output: log.StandardLogger().Writer(),
+			Level:  hclog.Info,
+		}),
+	})
+	rpcClient, err := pc.Client()
+	if err != nil {
+		return "", err
+	}
+	raw, err := rpcClient.Dispense(pluginName)
+	if err != nil {
+		return "", err
+	}
+	c := raw.(*client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	info, err := c.c.Info(ctx, &backendpb.InfoRequest{})
+	if err != nil {
+		return "", fmt.Errorf("could not get host type: %s", err.Error())
+	}
+	hostType := info.GetHostType()
+	if hostType == "" {
+		return "", fmt.Errorf("plugin returned no host type")
+	}
+	if _, ok := jobs.GetBackend(hostType); ok {
+		return "", fmt.Errorf("host type %s already has a backend", hostType)
+	}
+	jobs.RegisterBackend(hostType, jobs.NewPluginBackend(hostType, c))
+	return hostType, nil
+}
+
+// Shutdown stops all backend plugins, jobs must be killed before
+func Shutdown() {
+	plugin.CleanupClients()
+}
@@ -0,0 +1,53 @@
+// Package backendplugin runs jobs on out-of-process backend plugins. A backend plugin is an executable that serves
+// the Backend service of backendpb over gRPC with hashicorp/go-plugin, plugins are written in Go with Serve:
+//
+//	func main() {
+//		backendplugin.Serve(&myScheduler{})
+//	}
+//
+// Executables in the backend plugins directory are started by the server, each registers a job backend for the host
+// type it returns from Info.
+package backendplugin
+
+import (
+	"app/backendplugin/backendpb"
+	"context"
+
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// Handshake makes sure the server and plugins speak the same contract, plugins with another protocol version are not
+// started and executables that are not plugins exit with a message when run
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "SEPEX_BACKEND_PLUGIN",
+	MagicCookieValue: "job-backend",
+}
+
+// Name of the backend plugin in the plugin set of go-plugin
+const pluginName = "backend"
+
+// Serve serves impl as backend plugin, it is called in the main function of a plugin and does not return
+func Serve(impl backendpb.BackendServer) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         plugin.PluginSet{pluginName: &grpcPlugin{impl: impl}},
+		GRPCServer:      plugin.DefaultGRPCServer,
+	})
+}
+
+// go-plugin plugin of the Backend service, impl is only set in plugins
+type grpcPlugin struct {
+	plugin.NetRPCUnsupportedPlugin
+	impl backendpb.BackendServer
+}
+
+func (p *grpcPlugin) GRPCServer(_ *plugin.GRPCBroker, s *grpc.Server) error {
+	backendpb.RegisterBackendServer(s, p.impl)
+	return nil
+}
+
+func (p *grpcPlugin) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &client{c: backendpb.NewBackendClient(conn)}, nil
+}
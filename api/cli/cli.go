@@ -0,0 +1,303 @@
+// Package cli is the client mode of sepex: `sepex <command>` calls the REST API of a deployment instead of running the server.
+// The deployment and credentials are read from a profile, see client.LoadProfile.
+package cli
+
+import (
+	"app/client"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage: sepex <command> [-profile name] [-profiles file] [flags] [args]
+
+Commands:
+  execute <processID>  run a process, the request body is read from -d
+  jobs list            list jobs, newest first
+  logs <jobID>         print the process logs of a job, -f follows them until the job finished
+  dismiss <jobID>      dismiss a job
+
+Profiles are read from %s unless -profiles or SEPEX_PROFILES_FILE is set, e.g.
+
+  default: local
+  profiles:
+    local:
+      url: http://localhost:5050
+    prod:
+      url: https://sepex.example.com
+      tokenEnv: SEPEX_PROD_TOKEN
+      email: me@example.com
+
+Without profiles file SEPEX_URL, SEPEX_TOKEN and SEPEX_EMAIL are used. SEPEX_PROFILE selects a profile.
+Run 'sepex <command> -h' for the flags of a command, 'sepex -h' for the flags of the server.
+`
+
+// Exit code of jobs that were followed and did not succeed
+const exitJobNotSuccessful = 3
+
+// errUsage is returned for invalid arguments, the message is printed as is
+type errUsage string
+
+func (e errUsage) Error() string { return string(e) }
+
+var commands = map[string]func(ctx context.Context, args []string) (int, error){
+	"execute": execute,
+	"jobs":    listJobs,
+	"logs":    logs,
+	"dismiss": dismiss,
+	"help":    help,
+}
+
+// IsCommand returns true if arg, the first argument of sepex, is a client command
+func IsCommand(arg string) bool {
+	_, ok := commands[arg]
+	return ok
+}
+
+// Run runs the client command of args, args[0] is the command. It returns the exit code.
+func Run(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	code, err := commands[args[0]](ctx, args[1:])
+	var eu errUsage
+	switch {
+	case errors.As(err, &eu):
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "sepex:", err)
+		return 1
+	}
+	return code
+}
+
+func help(context.Context, []string) (int, error) {
+	fmt.Fprintf(os.Stdout, usage, client.DefaultProfilesPath())
+	return 0, nil
+}
+
+// Flags of a command with the profile flags, that every command has
+type commandFlags struct {
+	*flag.FlagSet
+	profile  *string
+	profiles *string
+}
+
+func newFlags(name string) commandFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return commandFlags{
+		FlagSet:  fs,
+		profile:  fs.String("profile", os.Getenv("SEPEX_PROFILE"), "profile to use, default profile of the profiles file if empty"),
+		profiles: fs.String("profiles", client.DefaultProfilesPath(), "profiles file"),
+	}
+}
+
+// Client of the selected profile
+func (f commandFlags) client() (*client.Client, error) {
+	p, err := client.LoadProfile(*f.profiles, *f.profile)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(p)
+}
+
+// Parse flags of a command that takes exactly one argument, flags may come before or after it
+func (f commandFlags) parseWithArg(args []string, name string) (string, error) {
+	if err := f.Parse(args); err != nil {
+		return "", err
+	}
+	rest := f.Args()
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		arg := rest[0]
+		if err := f.Parse(rest[1:]); err != nil {
+			return "", err
+		}
+		if f.NArg() == 0 {
+			return arg, nil
+		}
+	}
+	return "", errUsage(fmt.Sprintf("usage: sepex %s <%s> [flags]", f.Name(), name))
+}
+
+// Print a JSON document indented
+func printJSON(data []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(os.Stdout)
+	return err
+}
+
+// Request body of -d: JSON, @path to read a file, or - to read stdin
+func readBody(d string) ([]byte, error) {
+	var body []byte
+	var err error
+	switch {
+	case d == "-":
+		body, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(d, "@"):
+		body, err = os.ReadFile(d[1:])
+	default:
+		body = []byte(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("request body is not valid JSON")
+	}
+	return body, nil
+}
+
+func execute(ctx context.Context, args []string) (int, error) {
+	f := newFlags("execute")
+	d := f.String("d", `{"inputs": {}}`, "execute request, JSON like {\"inputs\": {...}}, @file to read it from a file or - from stdin")
+	async := f.Bool("async", false, "ask for async execution, the job status is printed once the job is accepted")
+	follow := f.Bool("f", false, "with -async, follow the process logs of the job until it finished, exits with 3 if it did not succeed")
+	processID, err := f.parseWithArg(args, "processID")
+	if err != nil {
+		return 0, err
+	}
+	body, err := readBody(*d)
+	if err != nil {
+		return 0, err
+	}
+	c, err := f.client()
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := c.Execute(ctx, processID, body, *async)
+	if err != nil {
+		return 0, err
+	}
+	if !*follow {
+		return 0, printJSON(out)
+	}
+
+	var job struct {
+		JobID string `json:"jobID"`
+	}
+	if err := json.Unmarshal(out, &job); err != nil || job.JobID == "" {
+		// sync responses have no job to follow
+		return 0, printJSON(out)
+	}
+	fmt.Fprintln(os.Stderr, "job", job.JobID)
+	return followLogs(ctx, c, job.JobID)
+}
+
+func followLogs(ctx context.Context, c *client.Client, jobID string) (int, error) {
+	status, err := c.FollowLogs(ctx, jobID, func(line string) { fmt.Println(line) })
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(os.Stderr, "job %s %s\n", jobID, status)
+	if status != "successful" {
+		return exitJobNotSuccessful, nil
+	}
+	return 0, nil
+}
+
+func listJobs(ctx context.Context, args []string) (int, error) {
+	if len(args) == 0 || args[0] != "list" {
+		return 0, errUsage("usage: sepex jobs list [flags]")
+	}
+	f := newFlags("jobs list")
+	process := f.String("process", "", "comma separated process IDs")
+	status := f.String("status", "", "comma separated statuses: accepted, running, successful, failed, dismissed")
+	limit := f.Int("limit", 20, "max number of jobs, at most 100")
+	offset := f.Int("offset", 0, "number of jobs to skip")
+	asJSON := f.Bool("json", false, "print jobs as JSON")
+	if err := f.Parse(args[1:]); err != nil {
+		return 0, err
+	}
+	c, err := f.client()
+	if err != nil {
+		return 0, err
+	}
+
+	q := client.JobsQuery{Limit: *limit, Offset: *offset}
+	if *process != "" {
+		q.ProcessIDs = strings.Split(*process, ",")
+	}
+	if *status != "" {
+		q.Statuses = strings.Split(*status, ",")
+	}
+	jobs, err := c.ListJobs(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if *asJSON {
+		data, err := json.Marshal(jobs)
+		if err != nil {
+			return 0, err
+		}
+		return 0, printJSON(data)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB ID\tPROCESS\tSTATUS\tHOST\tSUBMITTER\tUPDATED")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", j.JobID, j.ProcessID, j.Status, j.Host, j.Submitter, j.LastUpdate.Local().Format("2006-01-02 15:04:05"))
+	}
+	return 0, w.Flush()
+}
+
+func logs(ctx context.Context, args []string) (int, error) {
+	f := newFlags("logs")
+	follow := f.Bool("f", false, "follow the logs until the job finished, exits with 3 if it did not succeed")
+	stderr := f.Bool("stderr", false, "also print stderr of the process, to stderr")
+	jobID, err := f.parseWithArg(args, "jobID")
+	if err != nil {
+		return 0, err
+	}
+	c, err := f.client()
+	if err != nil {
+		return 0, err
+	}
+	if *follow {
+		return followLogs(ctx, c, jobID)
+	}
+
+	l, err := c.Logs(ctx, jobID)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range l.ProcessLogs {
+		fmt.Println(e.Msg)
+	}
+	if *stderr {
+		for _, e := range l.StderrLogs {
+			fmt.Fprintln(os.Stderr, e.Msg)
+		}
+	}
+	return 0, nil
+}
+
+func dismiss(ctx context.Context, args []string) (int, error) {
+	f := newFlags("dismiss")
+	jobID, err := f.parseWithArg(args, "jobID")
+	if err != nil {
+		return 0, err
+	}
+	c, err := f.client()
+	if err != nil {
+		return 0, err
+	}
+	out, err := c.Dismiss(ctx, jobID)
+	if err != nil {
+		return 0, err
+	}
+	return 0, printJSON(out)
+}
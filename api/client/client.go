@@ -0,0 +1,265 @@
+// Package client calls the SEPEX REST API. It is used by the sepex CLI and does not depend on server packages.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client of one SEPEX deployment, requests are authenticated with the credentials of its profile
+type Client struct {
+	base    string
+	profile Profile
+	http    *http.Client
+}
+
+// APIError is a response of the API with an error status
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// Job is an entry of the job list
+type Job struct {
+	JobID      string    `json:"jobID"`
+	LastUpdate time.Time `json:"updated"`
+	Status     string    `json:"status"`
+	ProcessID  string    `json:"processID"`
+	Host       string    `json:"host,omitempty"`
+	Submitter  string    `json:"submitter"`
+}
+
+// JobsQuery filters the job list, empty fields do not filter. Limit is at most 100, 0 uses the default of the API (20).
+type JobsQuery struct {
+	ProcessIDs []string
+	Statuses   []string
+	Limit      int
+	Offset     int
+}
+
+type LogEntry struct {
+	Level string    `json:"level"`
+	Msg   string    `json:"msg"`
+	Time  time.Time `json:"time"`
+}
+
+// JobLogs are the logs of a job, process logs are the stdout of the process
+type JobLogs struct {
+	JobID       string     `json:"jobID"`
+	ProcessID   string     `json:"processID"`
+	Status      string     `json:"status"`
+	ProcessLogs []LogEntry `json:"process_logs"`
+	StderrLogs  []LogEntry `json:"stderr_logs"`
+	ServerLogs  []LogEntry `json:"server_logs"`
+}
+
+// New returns a client for the deployment of profile p
+func New(p Profile) (*Client, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid API URL %q", p.URL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if p.CertFile != "" || p.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", p.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Client{base: strings.TrimRight(p.URL, "/"), profile: p, http: &http.Client{Transport: transport}}, nil
+}
+
+// Send a request and return the response if its status is not an error, callers must close its body
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.profile.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.profile.Token)
+	}
+	if c.profile.Email != "" {
+		req.Header.Set("X-SEPEX-User-Email", c.profile.Email)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp, nil
+}
+
+// Error of a response, the API returns `{"message": ...}` objects and auth middlewares plain JSON strings
+func apiError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var obj struct {
+		Message string `json:"message"`
+	}
+	var s string
+	msg := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &obj); err == nil && obj.Message != "" {
+		msg = obj.Message
+	} else if err := json.Unmarshal(data, &s); err == nil {
+		msg = s
+	}
+	return &APIError{Status: resp.StatusCode, Message: msg}
+}
+
+// Decode the JSON body of a request into v
+func (c *Client) getJSON(ctx context.Context, method, path string, query url.Values, body []byte, header http.Header, v interface{}) error {
+	resp, err := c.do(ctx, method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Execute submits a job of process processID with body, the execute request (`{"inputs": ...}`), and returns the response document.
+// Async asks for async execution with `Prefer: respond-async`, processes that only support one mode ignore it.
+func (c *Client) Execute(ctx context.Context, processID string, body []byte, async bool) (json.RawMessage, error) {
+	header := http.Header{}
+	if async {
+		header.Set("Prefer", "respond-async")
+	}
+	var out json.RawMessage
+	err := c.getJSON(ctx, http.MethodPost, "/processes/"+url.PathEscape(processID)+"/execution", nil, body, header, &out)
+	return out, err
+}
+
+// Job returns the status document of a job
+func (c *Client) Job(ctx context.Context, jobID string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.getJSON(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID), nil, nil, nil, &out)
+	return out, err
+}
+
+// ListJobs returns jobs matching q, newest first
+func (c *Client) ListJobs(ctx context.Context, q JobsQuery) ([]Job, error) {
+	query := url.Values{}
+	if len(q.ProcessIDs) > 0 {
+		query.Set("processID", strings.Join(q.ProcessIDs, ","))
+	}
+	if len(q.Statuses) > 0 {
+		query.Set("status", strings.Join(q.Statuses, ","))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		query.Set("offset", strconv.Itoa(q.Offset))
+	}
+	var out struct {
+		Jobs []Job `json:"jobs"`
+	}
+	err := c.getJSON(ctx, http.MethodGet, "/jobs", query, nil, nil, &out)
+	return out.Jobs, err
+}
+
+// Logs returns the last 1000 entries of each log of a job
+func (c *Client) Logs(ctx context.Context, jobID string) (JobLogs, error) {
+	var out JobLogs
+	err := c.getJSON(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID)+"/logs", nil, nil, nil, &out)
+	return out, err
+}
+
+// FollowLogs calls line with every process log line of a job as it is written, until the job finished or ctx is cancelled.
+// Logs of finished jobs are replayed. Returns the final status of the job.
+func (c *Client) FollowLogs(ctx context.Context, jobID string, line func(string)) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID)+"/logs/stream", nil, nil, http.Header{"Accept": {"text/event-stream"}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Server-Sent Events: `event` and `data` fields, events end with an empty line, lines starting with ':' are comments
+	var event string
+	var data []string
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		l := sc.Text()
+		switch {
+		case l == "":
+			if event == "end" {
+				return strings.Join(data, "\n"), nil
+			}
+			if data != nil {
+				line(strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(l, ":"):
+		case strings.HasPrefix(l, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(l, "event:"))
+		case strings.HasPrefix(l, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(l, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("log stream of job %s ended before the job finished", jobID)
+}
+
+// Dismiss dismisses a job and returns the response document
+func (c *Client) Dismiss(ctx context.Context, jobID string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.getJSON(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil, nil, nil, &out)
+	return out, err
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a SEPEX deployment and the credentials to call it with. Tokens are read from tokenEnv if it is set,
+// so that profiles files do not need to contain secrets. Email is sent as X-SEPEX-User-Email, which keycloak auth requires
+// to match the token. certFile and keyFile are a client certificate for client-cert auth.
+type Profile struct {
+	URL      string `yaml:"url"`
+	Token    string `yaml:"token,omitempty"`
+	TokenEnv string `yaml:"tokenEnv,omitempty"`
+	Email    string `yaml:"email,omitempty"`
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	// PEM file of CAs trusted in addition to the system ones
+	CAFile string `yaml:"caFile,omitempty"`
+}
+
+// Profiles file, `default` names the profile used when none is selected
+type profilesFile struct {
+	Default  string             `yaml:"default"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// DefaultProfilesPath returns SEPEX_PROFILES_FILE if it is set, else `sepex/profiles.yaml` in the user config directory,
+// e.g. ~/.config/sepex/profiles.yaml on Linux
+func DefaultProfilesPath() string {
+	if p := os.Getenv("SEPEX_PROFILES_FILE"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sepex", "profiles.yaml")
+}
+
+// LoadProfile returns profile name of the profiles file at path, the default profile of the file if name is empty.
+// Without profiles file, the profile is read from SEPEX_URL, SEPEX_TOKEN and SEPEX_EMAIL. These env variables
+// override the URL, token and email of profiles too.
+func LoadProfile(path, name string) (Profile, error) {
+	var p Profile
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var f profilesFile
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return p, fmt.Errorf("invalid profiles file %s: %s", path, err.Error())
+		}
+		if name == "" {
+			name = f.Default
+		}
+		var ok bool
+		if p, ok = f.Profiles[name]; !ok && name != "" {
+			return p, fmt.Errorf("profile %q not found in %s", name, path)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if name != "" {
+			return p, fmt.Errorf("profile %q not found, profiles file %s does not exist", name, path)
+		}
+	default:
+		return p, err
+	}
+
+	if p.TokenEnv != "" {
+		p.Token = os.Getenv(p.TokenEnv)
+	}
+	for env, v := range map[string]*string{"SEPEX_URL": &p.URL, "SEPEX_TOKEN": &p.Token, "SEPEX_EMAIL": &p.Email} {
+		if s := os.Getenv(env); s != "" {
+			*v = s
+		}
+	}
+	if p.URL == "" {
+		return p, fmt.Errorf("no API URL, set url of a profile in %s or SEPEX_URL", path)
+	}
+	return p, nil
+}
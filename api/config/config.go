@@ -0,0 +1,300 @@
+// Package config holds the typed server configuration.
+// Settings are resolved in the order flag > environment variable > config file > default,
+// see DEV_GUIDE.md. Env variable names are set with the env tag of each field.
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config is the server configuration, field tags:
+//   - yaml: key in the config file, TOML files use the same keys
+//   - env: env variable overriding the value, empty env variables are ignored unless the option `empty` is set,
+//     the option `paths` splits lists on the path list separator (`:`) instead of commas
+//   - default: value used when neither the file nor the env variable set it
+type Config struct {
+	API           API           `yaml:"api"`
+	Logging       Logging       `yaml:"logging"`
+	Jobs          Jobs          `yaml:"jobs"`
+	Costs         Costs         `yaml:"costs"`
+	Images        Images        `yaml:"images"`
+	DB            DB            `yaml:"db"`
+	Storage       Storage       `yaml:"storage"`
+	Auth          Auth          `yaml:"auth"`
+	TLS           TLS           `yaml:"tls"`
+	CORS          CORS          `yaml:"cors"`
+	Headers       Headers       `yaml:"headers"`
+	ResultLinks   ResultLinks   `yaml:"resultLinks"`
+	Plugins       Plugins       `yaml:"plugins"`
+	RateLimit     RateLimit     `yaml:"rateLimit"`
+	Webhooks      Webhooks      `yaml:"webhooks"`
+	Events        Events        `yaml:"events"`
+	Notifications Notifications `yaml:"notifications"`
+	AWS           AWS           `yaml:"aws"`
+	MinIO         MinIO         `yaml:"minio"`
+	Vault         Vault         `yaml:"vault"`
+}
+
+type API struct {
+	Name      string `yaml:"name" env:"API_NAME"` // prefix of cloud job names and label of job containers
+	Port      string `yaml:"port" env:"API_PORT" default:"5050"`
+	RepoURL   string `yaml:"repoURL" env:"REPO_URL"`
+	PublicURL string `yaml:"publicURL" env:"API_URL_PUBLIC"` // base URL of links in notifications and result links
+	// Port of the gRPC Jobs service, it is not started if empty
+	GRPCPort string `yaml:"grpcPort" env:"GRPC_PORT"`
+	// Shape of process descriptions without profile query parameter: sepex or ogc (inputs and outputs as maps of JSON schemas)
+	DescriptionProfile string `yaml:"descriptionProfile" env:"PROCESS_DESCRIPTION_PROFILE" default:"sepex"`
+	// Serve GraphQL queries of processes and jobs on /graphql
+	GraphQL bool `yaml:"graphql" env:"GRAPHQL_ENABLED"`
+	// Limits of execute request bodies, larger bodies are rejected with 413, longer strings and arrays with 422; 0 disables a limit
+	MaxExecuteBodyKB     int `yaml:"maxExecuteBodyKB" env:"MAX_EXECUTE_BODY_KB" default:"1024"`
+	MaxInputStringLength int `yaml:"maxInputStringLength" env:"MAX_INPUT_STRING_LENGTH"`
+	MaxInputArrayLength  int `yaml:"maxInputArrayLength" env:"MAX_INPUT_ARRAY_LENGTH"`
+	// CIDRs of reverse proxies whose X-Forwarded-For header is trusted, client IPs are the direct peers if empty
+	TrustedProxies []string `yaml:"trustedProxies" env:"TRUSTED_PROXIES"`
+}
+
+type Logging struct {
+	Level      string `yaml:"level" env:"LOG_LEVEL" default:"info"`
+	File       string `yaml:"file" env:"LOG_FILE" default:"/.data/logs/api.jsonl"`
+	Stdout     bool   `yaml:"stdout" env:"LOG_STDOUT"`
+	JobLogsDir string `yaml:"jobLogsDir" env:"TMP_JOB_LOGS_DIR"`
+	// Time local copies of logs are kept after they are uploaded, so that logs of recently finished jobs are served without storage requests
+	LocalLogsTTL time.Duration `yaml:"localLogsTTL" env:"LOCAL_LOGS_TTL" default:"1h"`
+	// When job log files are synced to disk: never (left to the OS), close or always (after every write)
+	JobLogsFsync string `yaml:"jobLogsFsync" env:"JOB_LOGS_FSYNC" default:"never"`
+}
+
+type Jobs struct {
+	// Local job resource limits, 0 CPUs uses 80% of system CPUs
+	MaxLocalCPUs     float64 `yaml:"maxLocalCPUs" env:"MAX_LOCAL_CPUS"`
+	MaxLocalMemoryMB int     `yaml:"maxLocalMemoryMB" env:"MAX_LOCAL_MEMORY_MB" default:"8192"`
+	// Max number of jobs waiting for local resources, 0 does not limit the queue
+	MaxQueueLength int `yaml:"maxQueueLength" env:"MAX_QUEUE_LENGTH"`
+	// tenant=cpus:memoryMB list, e.g. acme=4:8192,globex=2:4096
+	TenantQuotas         string        `yaml:"tenantQuotas" env:"TENANT_QUOTAS"`
+	SyncWaitTimeout      time.Duration `yaml:"syncWaitTimeout" env:"SYNC_WAIT_TIMEOUT"`
+	OrphanReaperInterval time.Duration `yaml:"orphanReaperInterval" env:"ORPHAN_REAPER_INTERVAL" default:"10m"`
+	ScratchDir           string        `yaml:"scratchDir" env:"SCRATCH_DIR"`
+	ScratchHostDir       string        `yaml:"scratchHostDir" env:"SCRATCH_HOST_DIR"`
+	// Number of workers processing status updates, updates of a job are always processed by the same worker
+	StatusWorkers int `yaml:"statusWorkers" env:"STATUS_UPDATE_WORKERS" default:"4"`
+	// Max number of jobs tracked as active jobs (running, queued or waiting for batch), 0 does not limit them
+	MaxActiveJobs int `yaml:"maxActiveJobs" env:"MAX_ACTIVE_JOBS"`
+	// Interval at which running jobs are checked against their containers and Batch jobs, 0 disables the watchdog
+	WatchdogInterval time.Duration `yaml:"watchdogInterval" env:"JOB_WATCHDOG_INTERVAL" default:"1m"`
+	// Time running jobs can go without status updates, logs or progress before a warning is logged and before they are failed, 0 disables
+	SilenceWarning time.Duration `yaml:"silenceWarning" env:"JOB_SILENCE_WARNING"`
+	SilenceTimeout time.Duration `yaml:"silenceTimeout" env:"JOB_SILENCE_TIMEOUT"`
+	// Limits of local disk used by job logs and scratch directories, new local jobs are refused when usage reaches 90% of
+	// the quota or free space is below the minimum, 0 does not limit them
+	DiskQuotaMB   int `yaml:"diskQuotaMB" env:"JOB_DISK_QUOTA_MB"`
+	DiskMinFreeMB int `yaml:"diskMinFreeMB" env:"JOB_DISK_MIN_FREE_MB"`
+	// Interval at which disk usage is checked when a limit is set
+	DiskCheckInterval time.Duration `yaml:"diskCheckInterval" env:"JOB_DISK_CHECK_INTERVAL" default:"1m"`
+	// Key to sign tokens of results callbacks with, jobs get no callback if empty. Callback URLs start with CallbackURL,
+	// the base URL of the server as reached from jobs, API_URL_PUBLIC if empty
+	CallbackSecret string `yaml:"callbackSecret" env:"JOB_CALLBACK_SECRET"`
+	CallbackURL    string `yaml:"callbackURL" env:"JOB_CALLBACK_URL"`
+}
+
+// Rates of estimated job costs, recorded in the metadata of jobs. Jobs have no cost if all rates are 0.
+type Costs struct {
+	Currency string `yaml:"currency" env:"COST_CURRENCY" default:"USD"`
+	// Per vCPU-second of aws-batch jobs, per CPU-hour of docker and subprocess jobs, per GB of outputs in storage
+	BatchVCPUSecond float64 `yaml:"batchVCPUSecond" env:"COST_BATCH_VCPU_SECOND"`
+	LocalCPUHour    float64 `yaml:"localCPUHour" env:"COST_LOCAL_CPU_HOUR"`
+	StorageGB       float64 `yaml:"storageGB" env:"COST_STORAGE_GB"`
+}
+
+// Docker images of processes
+type Images struct {
+	// Interval at which image tags are pulled again to pick up patched images, 0 only pulls missing images at startup
+	RefreshInterval time.Duration `yaml:"refreshInterval" env:"IMAGE_REFRESH_INTERVAL"`
+	// Max number of images pulled at the same time
+	PullConcurrency int `yaml:"pullConcurrency" env:"IMAGE_PULL_CONCURRENCY" default:"4"`
+	// Docker config.json whose `auths` are used for images of processes without registryAuth
+	RegistryAuthFile string `yaml:"registryAuthFile" env:"REGISTRY_AUTH_FILE"`
+	// Pull ECR images of processes without registryAuth with a token of the AWS credentials of the server
+	ECRAuth bool `yaml:"ecrAuth" env:"REGISTRY_ECR_AUTH"`
+	// Register processes without waiting for their images, missing images are pulled in the background
+	LazyPull bool `yaml:"lazyPull" env:"IMAGE_LAZY_PULL"`
+}
+
+type DB struct {
+	Service            string `yaml:"service" env:"DB_SERVICE"` // sqlite | postgres
+	SQLitePath         string `yaml:"sqlitePath" env:"SQLITE_DB_PATH"`
+	PostgresConnString string `yaml:"postgresConnString" env:"POSTGRES_CONN_STRING"`
+	// Interval at which status updates of running jobs are written in batches, 0 writes every update right away
+	StatusWriteInterval time.Duration `yaml:"statusWriteInterval" env:"DB_STATUS_WRITE_INTERVAL"`
+}
+
+type Storage struct {
+	Service           string `yaml:"service" env:"STORAGE_SERVICE"` // minio | aws-s3
+	Bucket            string `yaml:"bucket" env:"STORAGE_BUCKET"`
+	MetadataPrefix    string `yaml:"metadataPrefix" env:"STORAGE_METADATA_PREFIX"`
+	ResultsPrefix     string `yaml:"resultsPrefix" env:"STORAGE_RESULTS_PREFIX"`
+	LogsPrefix        string `yaml:"logsPrefix" env:"STORAGE_LOGS_PREFIX"`
+	ArchivePrefix     string `yaml:"archivePrefix" env:"STORAGE_ARCHIVE_PREFIX" default:"archive"`
+	ChecksumMaxSizeMB int    `yaml:"checksumMaxSizeMB" env:"METADATA_CHECKSUM_MAX_SIZE_MB" default:"1024"`
+	// Role assumed for storage credentials of jobs of processes with scopedCredentials on aws-s3, MinIO issues them for
+	// the user of the server. Credentials expire after JobCredentialsTTL, it must cover queue and run time of aws-batch jobs
+	JobRoleARN        string        `yaml:"jobRoleARN" env:"STORAGE_JOB_ROLE_ARN"`
+	JobCredentialsTTL time.Duration `yaml:"jobCredentialsTTL" env:"STORAGE_JOB_CREDENTIALS_TTL" default:"1h"`
+	// Hosts of http(s) references downloaded to compute checksums, S3 checksums are only computed for objects in Bucket
+	ChecksumHosts []string `yaml:"checksumHosts" env:"METADATA_CHECKSUM_HOSTS"`
+}
+
+type Auth struct {
+	Service               string `yaml:"service" env:"AUTH_SERVICE"` // keycloak | client-cert
+	Level                 int    `yaml:"level" env:"AUTH_LEVEL"`
+	AdminRole             string `yaml:"adminRole" env:"AUTH_ADMIN_ROLE"`
+	ServiceRole           string `yaml:"serviceRole" env:"AUTH_SERVICE_ROLE"`
+	KeycloakPublicKeysURL string `yaml:"keycloakPublicKeysURL" env:"KEYCLOAK_PUBLIC_KEYS_URL"`
+}
+
+type TLS struct {
+	CertFile        string `yaml:"certFile" env:"TLS_CERT_FILE"`
+	KeyFile         string `yaml:"keyFile" env:"TLS_KEY_FILE"`
+	ClientCAFile    string `yaml:"clientCAFile" env:"TLS_CLIENT_CA_FILE"`
+	ClientAuth      string `yaml:"clientAuth" env:"TLS_CLIENT_AUTH" default:"optional"` // optional | require
+	ClientCertRoles string `yaml:"clientCertRoles" env:"TLS_CLIENT_CERT_ROLES"`
+}
+
+type CORS struct {
+	AllowOrigins     []string `yaml:"allowOrigins" env:"CORS_ALLOW_ORIGINS" default:"*"`
+	AllowMethods     []string `yaml:"allowMethods" env:"CORS_ALLOW_METHODS"` // default GET,HEAD,PUT,PATCH,POST,DELETE
+	AllowHeaders     []string `yaml:"allowHeaders" env:"CORS_ALLOW_HEADERS"`
+	ExposeHeaders    []string `yaml:"exposeHeaders" env:"CORS_EXPOSE_HEADERS"` // default Location, Preference-Applied, X-Request-Id and rate limit headers
+	AllowCredentials bool     `yaml:"allowCredentials" env:"CORS_ALLOW_CREDENTIALS" default:"true"`
+	MaxAge           int      `yaml:"maxAge" env:"CORS_MAX_AGE"`
+}
+
+type Headers struct {
+	Enabled               bool   `yaml:"enabled" env:"SECURITY_HEADERS" default:"true"`
+	HSTSMaxAge            int    `yaml:"hstsMaxAge" env:"HSTS_MAX_AGE"`
+	ContentSecurityPolicy string `yaml:"contentSecurityPolicy" env:"CONTENT_SECURITY_POLICY"`
+	XFrameOptions         string `yaml:"xFrameOptions" env:"X_FRAME_OPTIONS,empty" default:"SAMEORIGIN"`
+	// Origins allowed to embed job widget pages, space separated as in the CSP frame-ancestors directive, e.g. https://dash.example.com
+	WidgetFrameAncestors string `yaml:"widgetFrameAncestors" env:"WIDGET_FRAME_ANCESTORS"`
+}
+
+type ResultLinks struct {
+	Secret string `yaml:"secret" env:"RESULT_LINK_SECRET"`
+	MaxTTL string `yaml:"maxTTL" env:"RESULT_LINK_MAX_TTL" default:"7d"` // e.g. 24h, 7d
+}
+
+type Plugins struct {
+	LoadDir string      `yaml:"loadDir" env:"PLUGINS_LOAD_DIR"`
+	Dir     string      `yaml:"dir" env:"PLUGINS_DIR"`
+	Dirs    []string    `yaml:"dirs" env:"PLUGINS_DIRS,paths"` // read-only layers loaded before Dir, later layers override processes of earlier ones
+	Git     GitRegistry `yaml:"git"`
+	OCI     OCIRegistry `yaml:"oci"`
+	// Run health checks of processes when they are loaded, processes failing them are not registered
+	SelfTestOnLoad bool `yaml:"selfTestOnLoad" env:"PROCESS_SELFTEST_ON_LOAD"`
+	// Executables started as backend plugins, each adds a host type, see package backendplugin
+	BackendsDir string `yaml:"backendsDir" env:"BACKEND_PLUGINS_DIR"`
+}
+
+type GitRegistry struct {
+	URL          string        `yaml:"url" env:"PROCESSES_GIT_URL"`
+	Ref          string        `yaml:"ref" env:"PROCESSES_GIT_REF" default:"main"`
+	Path         string        `yaml:"path" env:"PROCESSES_GIT_PATH"`
+	DeployKey    string        `yaml:"deployKey" env:"PROCESSES_GIT_DEPLOY_KEY"`
+	Dir          string        `yaml:"dir" env:"PROCESSES_GIT_DIR"`
+	SyncInterval time.Duration `yaml:"syncInterval" env:"PROCESSES_GIT_SYNC_INTERVAL" default:"5m"`
+}
+
+type OCIRegistry struct {
+	Username  string `yaml:"username" env:"OCI_REGISTRY_USERNAME"`
+	Password  string `yaml:"password" env:"OCI_REGISTRY_PASSWORD"`
+	PlainHTTP bool   `yaml:"plainHTTP" env:"OCI_REGISTRY_PLAIN_HTTP"`
+}
+
+type RateLimit struct {
+	Execute string `yaml:"execute" env:"RATE_LIMIT_EXECUTE"` // e.g. 10/m
+	Logs    string `yaml:"logs" env:"RATE_LIMIT_LOGS"`
+	By      string `yaml:"by" env:"RATE_LIMIT_BY" default:"ip"` // ip | submitter | key
+}
+
+type Webhooks struct {
+	URLs           []string `yaml:"urls" env:"WEBHOOK_URLS"`
+	Secret         string   `yaml:"secret" env:"WEBHOOK_SECRET"`
+	Processes      []string `yaml:"processes" env:"WEBHOOK_PROCESSES"`
+	Statuses       []string `yaml:"statuses" env:"WEBHOOK_STATUSES"`
+	DeadLetterFile string   `yaml:"deadLetterFile" env:"WEBHOOK_DEAD_LETTER_FILE,empty" default:"/.data/logs/webhooks_dead_letter.jsonl"`
+}
+
+type Events struct {
+	Broker string `yaml:"broker" env:"EVENT_BROKER"` // sqs | nats | kafka
+	URL    string `yaml:"url" env:"EVENT_BROKER_URL"`
+	Topic  string `yaml:"topic" env:"EVENT_BROKER_TOPIC"`
+	Source string `yaml:"source" env:"EVENT_SOURCE"` // default /sepex/<api.name>
+}
+
+type Notifications struct {
+	SlackBotToken   string `yaml:"slackBotToken" env:"SLACK_BOT_TOKEN"`
+	SlackWebhookURL string `yaml:"slackWebhookURL" env:"SLACK_WEBHOOK_URL"`
+	SMTPHost        string `yaml:"smtpHost" env:"SMTP_HOST"`
+	SMTPPort        string `yaml:"smtpPort" env:"SMTP_PORT" default:"587"`
+	SMTPUsername    string `yaml:"smtpUsername" env:"SMTP_USERNAME"`
+	SMTPPassword    string `yaml:"smtpPassword" env:"SMTP_PASSWORD"`
+	SMTPFrom        string `yaml:"smtpFrom" env:"SMTP_FROM"`
+}
+
+type AWS struct {
+	AccessKeyID         string `yaml:"accessKeyID" env:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey     string `yaml:"secretAccessKey" env:"AWS_SECRET_ACCESS_KEY"`
+	Region              string `yaml:"region" env:"AWS_REGION"`
+	BatchLogStreamGroup string `yaml:"batchLogStreamGroup" env:"BATCH_LOG_STREAM_GROUP"`
+	// Max Batch API requests per second of all jobs, 0 does not limit them
+	BatchAPIRate float64 `yaml:"batchAPIRate" env:"BATCH_API_RATE" default:"10"`
+	// Times throttled or failed Batch API requests are retried with exponential backoff
+	BatchAPIMaxRetries int `yaml:"batchAPIMaxRetries" env:"BATCH_API_MAX_RETRIES" default:"8"`
+	// SQS queue EventBridge sends Batch job state change events to, statuses of aws-batch jobs are updated from them if set
+	BatchEventsQueueURL string `yaml:"batchEventsQueueURL" env:"BATCH_EVENTS_QUEUE_URL"`
+}
+
+type MinIO struct {
+	AccessKeyID     string `yaml:"accessKeyID" env:"MINIO_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" env:"MINIO_SECRET_ACCESS_KEY"`
+	Endpoint        string `yaml:"endpoint" env:"MINIO_S3_ENDPOINT"`
+	Region          string `yaml:"region" env:"MINIO_S3_REGION"`
+}
+
+type Vault struct {
+	Addr  string `yaml:"addr" env:"VAULT_ADDR"`
+	Token string `yaml:"token" env:"VAULT_TOKEN"`
+}
+
+var (
+	current  atomic.Pointer[Config]
+	fromEnv  *Config
+	initOnce sync.Once
+)
+
+// Get returns the configuration set with Set. Packages used without a server, e.g. by tools, get the configuration
+// from env variables and defaults.
+func Get() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	initOnce.Do(func() {
+		c, err := Load("")
+		if err != nil {
+			log.Warnf("invalid configuration: %s", err.Error())
+		}
+		fromEnv = c
+	})
+	return fromEnv
+}
+
+// Set replaces the configuration returned by Get, callers must not modify c afterwards.
+// To change settings at runtime, Set a modified copy.
+func Set(c *Config) {
+	current.Store(c)
+}
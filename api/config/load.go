@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load resolves the configuration from defaults, the YAML (.yml, .yaml) or TOML (.toml) file at path if not empty
+// and env variables. Unknown keys in the file and invalid env values are errors, all of them are returned at once.
+// The returned configuration is never nil so that callers can report errors and continue with defaults.
+func Load(path string) (*Config, error) {
+	c := &Config{}
+	var errs []error
+
+	forEachSetting(c, func(f reflect.Value, sf reflect.StructField) {
+		if def := sf.Tag.Get("default"); def != "" {
+			if err := setValue(f, def); err != nil {
+				errs = append(errs, fmt.Errorf("invalid default of %s: %s", sf.Name, err.Error()))
+			}
+		}
+	})
+
+	if path != "" {
+		if err := loadFile(c, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	forEachSetting(c, func(f reflect.Value, sf reflect.StructField) {
+		name, opts, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		if name == "" {
+			return
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok || (v == "" && opts != "empty") {
+			return
+		}
+		// Lists of paths are separated like PATH
+		if opts == "paths" {
+			v = strings.Join(filepath.SplitList(v), ",")
+		}
+		if err := setValue(f, v); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %s", name, v, err.Error()))
+		}
+	})
+
+	return c, errors.Join(errs...)
+}
+
+func loadFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %s", err.Error())
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+	case ".toml":
+		tree, err := parseTOML(data)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %s", path, err.Error())
+		}
+		// TOML files use the keys of YAML files, decoding them as YAML gives the same strict checks
+		if data, err = yaml.Marshal(tree); err != nil {
+			return fmt.Errorf("invalid config file %s: %s", path, err.Error())
+		}
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err.Error() != "EOF" {
+		return fmt.Errorf("invalid config file %s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	return nil
+}
+
+// Call fn for every setting, i.e. every non struct field, of c
+func forEachSetting(c *Config, fn func(f reflect.Value, sf reflect.StructField)) {
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			f, sf := v.Field(i), v.Type().Field(i)
+			if f.Kind() == reflect.Struct && f.Type() != durationType {
+				walk(f)
+				continue
+			}
+			fn(f, sf)
+		}
+	}
+	walk(reflect.ValueOf(c).Elem())
+}
+
+// Set a setting from its string form, lists are comma separated
+func setValue(f reflect.Value, s string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.New("must be a duration, e.g. 30s, 5m, 1h")
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		f.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Validate checks that required settings are set and that settings with a fixed set of options are valid.
+// All problems are returned at once, settings are named by their config file key and env variable.
+func (c *Config) Validate() error {
+	var errs []error
+	require := func(value, key, env, reason string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s (%s) is required%s", key, env, reason))
+		}
+	}
+	oneOf := func(value, key, env string, options ...string) {
+		for _, o := range options {
+			if value == o {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s (%s) must be one of %q, found %q", key, env, options, value))
+	}
+	notNegative := func(value int64, key, env string) {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s (%s) must not be negative", key, env))
+		}
+	}
+
+	require(c.Logging.JobLogsDir, "logging.jobLogsDir", "TMP_JOB_LOGS_DIR", "")
+	if _, err := log.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level (LOG_LEVEL) is invalid: %s", err.Error()))
+	}
+	notNegative(int64(c.Logging.LocalLogsTTL), "logging.localLogsTTL", "LOCAL_LOGS_TTL")
+	oneOf(c.API.DescriptionProfile, "api.descriptionProfile", "PROCESS_DESCRIPTION_PROFILE", "sepex", "ogc")
+	notNegative(int64(c.API.MaxExecuteBodyKB), "api.maxExecuteBodyKB", "MAX_EXECUTE_BODY_KB")
+	notNegative(int64(c.API.MaxInputStringLength), "api.maxInputStringLength", "MAX_INPUT_STRING_LENGTH")
+	notNegative(int64(c.API.MaxInputArrayLength), "api.maxInputArrayLength", "MAX_INPUT_ARRAY_LENGTH")
+	for _, p := range c.API.TrustedProxies {
+		if _, _, err := net.ParseCIDR(p); err != nil {
+			errs = append(errs, fmt.Errorf("api.trustedProxies (TRUSTED_PROXIES) must be CIDRs, e.g. 10.0.0.0/8, found %q", p))
+		}
+	}
+	oneOf(c.Logging.JobLogsFsync, "logging.jobLogsFsync", "JOB_LOGS_FSYNC", "never", "close", "always")
+
+	require(c.DB.Service, "db.service", "DB_SERVICE", "")
+	switch c.DB.Service {
+	case "":
+	case "sqlite":
+		require(c.DB.SQLitePath, "db.sqlitePath", "SQLITE_DB_PATH", " with sqlite")
+	case "postgres":
+		require(c.DB.PostgresConnString, "db.postgresConnString", "POSTGRES_CONN_STRING", " with postgres")
+	default:
+		oneOf(c.DB.Service, "db.service", "DB_SERVICE", "sqlite", "postgres")
+	}
+	notNegative(int64(c.DB.StatusWriteInterval), "db.statusWriteInterval", "DB_STATUS_WRITE_INTERVAL")
+
+	require(c.Storage.Service, "storage.service", "STORAGE_SERVICE", "")
+	switch c.Storage.Service {
+	case "":
+	case "minio":
+		require(c.MinIO.Endpoint, "minio.endpoint", "MINIO_S3_ENDPOINT", " with minio storage")
+	case "aws-s3":
+	default:
+		oneOf(c.Storage.Service, "storage.service", "STORAGE_SERVICE", "minio", "aws-s3")
+	}
+	if c.Storage.Service != "" {
+		require(c.Storage.Bucket, "storage.bucket", "STORAGE_BUCKET", "")
+	}
+	notNegative(int64(c.Storage.ChecksumMaxSizeMB), "storage.checksumMaxSizeMB", "METADATA_CHECKSUM_MAX_SIZE_MB")
+	// STS does not issue credentials for less than 15 minutes
+	if c.Storage.JobCredentialsTTL < 15*time.Minute {
+		errs = append(errs, errors.New("storage.jobCredentialsTTL (STORAGE_JOB_CREDENTIALS_TTL) must be at least 15m"))
+	}
+
+	if c.Plugins.Git.URL == "" {
+		require(c.Plugins.Dir, "plugins.dir", "PLUGINS_DIR", " unless plugins.git.url (PROCESSES_GIT_URL) is set")
+	}
+	notNegative(int64(c.Plugins.Git.SyncInterval), "plugins.git.syncInterval", "PROCESSES_GIT_SYNC_INTERVAL")
+
+	switch c.Auth.Level {
+	case 0:
+	case 1, 2:
+		switch c.Auth.Service {
+		case "keycloak":
+			require(c.Auth.KeycloakPublicKeysURL, "auth.keycloakPublicKeysURL", "KEYCLOAK_PUBLIC_KEYS_URL", " with keycloak auth")
+		case "client-cert":
+			require(c.TLS.ClientCAFile, "tls.clientCAFile", "TLS_CLIENT_CA_FILE", " with client-cert auth")
+			require(c.TLS.ClientCertRoles, "tls.clientCertRoles", "TLS_CLIENT_CERT_ROLES", " with client-cert auth")
+		default:
+			oneOf(c.Auth.Service, "auth.service", "AUTH_SERVICE", "keycloak", "client-cert")
+		}
+		require(c.Auth.AdminRole, "auth.adminRole", "AUTH_ADMIN_ROLE", " when auth is enabled")
+	default:
+		errs = append(errs, fmt.Errorf("auth.level (AUTH_LEVEL) must be 0, 1 or 2, found %d", c.Auth.Level))
+	}
+
+	if c.TLS.CertFile == "" && (c.TLS.KeyFile != "" || c.TLS.ClientCAFile != "") {
+		errs = append(errs, errors.New("tls.certFile (TLS_CERT_FILE) is required when tls.keyFile or tls.clientCAFile is set"))
+	}
+	if c.TLS.CertFile != "" {
+		require(c.TLS.KeyFile, "tls.keyFile", "TLS_KEY_FILE", " with tls.certFile")
+	}
+	oneOf(c.TLS.ClientAuth, "tls.clientAuth", "TLS_CLIENT_AUTH", "optional", "require")
+
+	notNegative(int64(c.CORS.MaxAge), "cors.maxAge", "CORS_MAX_AGE")
+	notNegative(int64(c.Headers.HSTSMaxAge), "headers.hstsMaxAge", "HSTS_MAX_AGE")
+
+	if c.Jobs.StatusWorkers < 1 {
+		errs = append(errs, errors.New("jobs.statusWorkers (STATUS_UPDATE_WORKERS) must be at least 1"))
+	}
+	if c.Jobs.MaxLocalCPUs < 0 {
+		errs = append(errs, errors.New("jobs.maxLocalCPUs (MAX_LOCAL_CPUS) must not be negative"))
+	}
+	notNegative(int64(c.Jobs.MaxLocalMemoryMB), "jobs.maxLocalMemoryMB", "MAX_LOCAL_MEMORY_MB")
+	notNegative(int64(c.Jobs.MaxQueueLength), "jobs.maxQueueLength", "MAX_QUEUE_LENGTH")
+	notNegative(int64(c.Jobs.MaxActiveJobs), "jobs.maxActiveJobs", "MAX_ACTIVE_JOBS")
+	notNegative(int64(c.Jobs.SyncWaitTimeout), "jobs.syncWaitTimeout", "SYNC_WAIT_TIMEOUT")
+	notNegative(int64(c.Jobs.OrphanReaperInterval), "jobs.orphanReaperInterval", "ORPHAN_REAPER_INTERVAL")
+	notNegative(int64(c.Jobs.WatchdogInterval), "jobs.watchdogInterval", "JOB_WATCHDOG_INTERVAL")
+	notNegative(int64(c.Jobs.SilenceWarning), "jobs.silenceWarning", "JOB_SILENCE_WARNING")
+	notNegative(int64(c.Jobs.SilenceTimeout), "jobs.silenceTimeout", "JOB_SILENCE_TIMEOUT")
+	notNegative(int64(c.Jobs.DiskQuotaMB), "jobs.diskQuotaMB", "JOB_DISK_QUOTA_MB")
+	notNegative(int64(c.Jobs.DiskMinFreeMB), "jobs.diskMinFreeMB", "JOB_DISK_MIN_FREE_MB")
+	if (c.Jobs.DiskQuotaMB > 0 || c.Jobs.DiskMinFreeMB > 0) && c.Jobs.DiskCheckInterval <= 0 {
+		errs = append(errs, errors.New("jobs.diskCheckInterval (JOB_DISK_CHECK_INTERVAL) must be positive when a disk limit is set"))
+	}
+	if c.Jobs.CallbackSecret != "" && c.Jobs.CallbackURL == "" && c.API.PublicURL == "" {
+		errs = append(errs, errors.New("jobs.callbackURL (JOB_CALLBACK_URL) or api.publicURL (API_URL_PUBLIC) must be set when jobs.callbackSecret (JOB_CALLBACK_SECRET) is set"))
+	}
+	if c.Jobs.SilenceWarning > 0 && c.Jobs.SilenceTimeout > 0 && c.Jobs.SilenceWarning >= c.Jobs.SilenceTimeout {
+		errs = append(errs, errors.New("jobs.silenceWarning (JOB_SILENCE_WARNING) must be shorter than jobs.silenceTimeout (JOB_SILENCE_TIMEOUT)"))
+	}
+
+	if c.Costs.BatchVCPUSecond < 0 || c.Costs.LocalCPUHour < 0 || c.Costs.StorageGB < 0 {
+		errs = append(errs, errors.New("costs rates (COST_BATCH_VCPU_SECOND, COST_LOCAL_CPU_HOUR, COST_STORAGE_GB) must not be negative"))
+	}
+
+	if c.AWS.BatchAPIRate < 0 {
+		errs = append(errs, errors.New("aws.batchAPIRate (BATCH_API_RATE) must not be negative"))
+	}
+	notNegative(int64(c.AWS.BatchAPIMaxRetries), "aws.batchAPIMaxRetries", "BATCH_API_MAX_RETRIES")
+
+	notNegative(int64(c.Images.RefreshInterval), "images.refreshInterval", "IMAGE_REFRESH_INTERVAL")
+	if c.Images.PullConcurrency < 1 {
+		errs = append(errs, errors.New("images.pullConcurrency (IMAGE_PULL_CONCURRENCY) must be at least 1"))
+	}
+	if c.Images.RegistryAuthFile != "" {
+		if _, err := os.Stat(c.Images.RegistryAuthFile); err != nil {
+			errs = append(errs, fmt.Errorf("images.registryAuthFile (REGISTRY_AUTH_FILE) must be a readable docker config file: %s", err.Error()))
+		}
+	}
+
+	oneOf(c.RateLimit.By, "rateLimit.by", "RATE_LIMIT_BY", "ip", "submitter", "key")
+	if c.Events.Broker != "" {
+		oneOf(c.Events.Broker, "events.broker", "EVENT_BROKER", "sqs", "nats", "kafka")
+	}
+
+	return errors.Join(errs...)
+}
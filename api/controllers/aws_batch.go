@@ -7,8 +7,6 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/batch"
 )
 
@@ -21,6 +19,8 @@ type JobDefinitionInfo struct {
 
 type AWSBatchController struct {
 	client *batch.Batch
+	// DescribeJobs requests of single jobs, sent together by describeLoop
+	describes chan describeRequest
 }
 
 // Get job def info from batch
@@ -68,25 +68,10 @@ func getResourceRequirement(resourceRequirements []*batch.ResourceRequirement, n
 	return ""
 }
 
-func NewAWSBatchController(accessKey, secretAccessKey, region string) (*AWSBatchController, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentialsFromCreds(credentials.Value{
-			AccessKeyID:     accessKey,
-			SecretAccessKey: secretAccessKey,
-		}),
-		Region: aws.String(region)},
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &AWSBatchController{batch.New(sess)}, nil
-}
-
-// returns the job id and an error
+// returns the job id and an error, vcpus and memory (MB) override resources of the job definition when they are > 0
 func (c *AWSBatchController) JobCreate(ctx context.Context,
 	jobDef, jobName, jobQueue string, commandOverride []string,
-	envVars map[string]string) (string, error) {
+	envVars map[string]string, vcpus float32, memory int) (string, error) {
 
 	envs := make([]*batch.KeyValuePair, len(envVars))
 	var i int
@@ -99,6 +84,14 @@ func (c *AWSBatchController) JobCreate(ctx context.Context,
 		Command:     aws.StringSlice(commandOverride),
 		Environment: envs,
 	}
+	if vcpus > 0 {
+		overrides.ResourceRequirements = append(overrides.ResourceRequirements,
+			&batch.ResourceRequirement{Type: aws.String(batch.ResourceTypeVcpu), Value: aws.String(strconv.FormatFloat(float64(vcpus), 'f', -1, 32))})
+	}
+	if memory > 0 {
+		overrides.ResourceRequirements = append(overrides.ResourceRequirements,
+			&batch.ResourceRequirement{Type: aws.String(batch.ResourceTypeMemory), Value: aws.String(strconv.Itoa(memory))})
+	}
 
 	input := &batch.SubmitJobInput{
 		JobDefinition:      aws.String(jobDef),
@@ -117,63 +110,52 @@ func (c *AWSBatchController) JobCreate(ctx context.Context,
 
 // Get current status of the job from Batch and formats it according to OGC Specs, also get LogStreamName
 func (c *AWSBatchController) JobMonitor(batchID string) (string, string, error) {
-	input := &batch.DescribeJobsInput{Jobs: aws.StringSlice([]string{batchID})}
-	output, err := c.client.DescribeJobs(input)
+	job, err := c.describeJob(batchID)
 	if err != nil {
 		return "", "", err
 	}
-	if len(output.Jobs) == 0 {
-		return "", "", fmt.Errorf("no such job: %s", batchID)
-	}
 
-	status := aws.StringValue(output.Jobs[0].Status)
-	lsn := aws.StringValue(output.Jobs[0].Container.LogStreamName)
+	lsn := aws.StringValue(job.Container.LogStreamName)
+	status, err := formatBatchStatus(aws.StringValue(job.Status), aws.StringValue(job.StatusReason))
+	return status, lsn, err
+}
 
+// Format status and status reason of a Batch job according to OGC Specs
+func formatBatchStatus(status, reason string) (string, error) {
 	switch status {
 	case "FAILED":
-		reason := aws.StringValue(output.Jobs[0].StatusReason)
 		// Non-standard reason used here to facilitate ogc implementation
 		if reason == "DISMISSED" {
-			return reason, lsn, nil
+			return reason, nil
 		} else {
-			return status, lsn, nil
+			return status, nil
 		}
 	case "SUBMITTED":
-		return "ACCCEPTED", lsn, nil
+		return "ACCCEPTED", nil
 	case "PENDING":
-		return "ACCCEPTED", lsn, nil
+		return "ACCCEPTED", nil
 	case "RUNNABLE":
-		return "ACCCEPTED", lsn, nil
+		return "ACCCEPTED", nil
 	case "STARTING":
-		return "RUNNING", lsn, nil
+		return "RUNNING", nil
 	case "RUNNING":
-		return status, lsn, nil
+		return status, nil
 	case "SUCCEEDED":
-		return status, lsn, nil
+		return status, nil
 
 	default:
-		return "", lsn, fmt.Errorf("unrecognized status  %s", status)
+		return "", fmt.Errorf("unrecognized status  %s", status)
 	}
 }
 
 // combines JobTerminate and JobCancel by managing calls for you based on job status
 func (c *AWSBatchController) JobKill(jobID string) (string, error) {
-	input := &batch.DescribeJobsInput{Jobs: aws.StringSlice([]string{jobID})}
-
-	output, err := c.client.DescribeJobs(input)
+	job, err := c.describeJob(jobID)
 	if err != nil {
 		return "", err
 	}
 
-	if len(output.Jobs) == 0 {
-		return "", fmt.Errorf("no such job: %s", jobID)
-	}
-
-	if len(output.Jobs) > 1 {
-		return "", fmt.Errorf("more than one job found for %s", jobID)
-	}
-
-	status := aws.StringValue(output.Jobs[0].Status)
+	status := aws.StringValue(job.Status)
 	switch status {
 	case "SUBMITTED", "PENDING", "RUNNABLE":
 		output, err := c.JobCancel(jobID, "DISMISSED")
@@ -253,28 +235,18 @@ func (c *AWSBatchController) GetImageURI(jobDef string) (string, error) {
 // Get job execution times
 func (c *AWSBatchController) GetJobTimes(batchID string) (cp time.Time, cr time.Time, st time.Time, err error) {
 
-	describeJobsInput := &batch.DescribeJobsInput{
-		Jobs: []*string{aws.String(batchID)},
-	}
-
-	describeJobsOutput, err := c.client.DescribeJobs(describeJobsInput)
+	job, err := c.describeJob(batchID)
 	if err != nil {
 		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("error describing jobs: %s", err)
 	}
 
-	if len(describeJobsOutput.Jobs) > 0 {
-		job := describeJobsOutput.Jobs[0] // Assuming only one job is returned
-
-		// Extract createdAt, startedAt, and completedAt times
-		if job.CreatedAt != nil && job.StartedAt != nil && job.StoppedAt != nil {
-			cr = time.UnixMilli(*job.CreatedAt)
-			st = time.UnixMilli(*job.StartedAt)
-			cp = time.UnixMilli(*job.StoppedAt)
-		} else {
-			return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("one of the job time value is nil")
-		}
+	// Extract createdAt, startedAt, and completedAt times
+	if job.CreatedAt != nil && job.StartedAt != nil && job.StoppedAt != nil {
+		cr = time.UnixMilli(*job.CreatedAt)
+		st = time.UnixMilli(*job.StartedAt)
+		cp = time.UnixMilli(*job.StoppedAt)
 	} else {
-		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("no job information found")
+		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("one of the job time value is nil")
 	}
 
 	return cr, st, cp, nil
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/batch"
+)
+
+// Prefix of status reasons of attempts that ended because their EC2 instance was terminated, e.g. a reclaimed Spot instance.
+// Batch documents onStatusReason "Host EC2*" for retrying these attempts.
+const interruptedStatusReasonPrefix = "Host EC2"
+
+// BatchAttempt is an attempt of a Batch job, Batch retries failed attempts according to the job definition's retry strategy
+type BatchAttempt struct {
+	Started time.Time // zero until the container started
+	Stopped time.Time // zero until the container stopped
+	// Exit code of the container, nil if it did not exit, e.g. because its instance was terminated
+	ExitCode     *int
+	Reason       string // why the container stopped
+	StatusReason string // why the attempt stopped
+}
+
+// Interrupted returns true if the attempt ended because its EC2 instance was terminated, e.g. a Spot instance that was reclaimed
+func (a BatchAttempt) Interrupted() bool {
+	return strings.HasPrefix(a.StatusReason, interruptedStatusReasonPrefix)
+}
+
+// JobAttempts returns the attempts of a Batch job that started, oldest first
+func (c *AWSBatchController) JobAttempts(batchID string) ([]BatchAttempt, error) {
+	job, err := c.describeJob(batchID)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]BatchAttempt, 0, len(job.Attempts))
+	for _, a := range job.Attempts {
+		attempts = append(attempts, batchAttempt(a))
+	}
+	return attempts, nil
+}
+
+func batchAttempt(a *batch.AttemptDetail) BatchAttempt {
+	ba := BatchAttempt{
+		Started:      unixMilli(aws.Int64Value(a.StartedAt)),
+		Stopped:      unixMilli(aws.Int64Value(a.StoppedAt)),
+		StatusReason: aws.StringValue(a.StatusReason),
+	}
+	if a.Container != nil {
+		if a.Container.ExitCode != nil {
+			ec := int(aws.Int64Value(a.Container.ExitCode))
+			ba.ExitCode = &ec
+		}
+		ba.Reason = aws.StringValue(a.Container.Reason)
+	}
+	return ba
+}
+
+// Time of milliseconds since the epoch as used by Batch, zero time for 0
+func unixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"app/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/batch"
+	"golang.org/x/time/rate"
+)
+
+// Max number of job IDs of one DescribeJobs call, as limited by the Batch API
+const describeJobsMaxIDs = 100
+
+// Time DescribeJobs requests of single jobs are collected before they are sent together
+const describeJobsWindow = 50 * time.Millisecond
+
+// Idle connections kept open to the Batch endpoint
+const batchMaxIdleConns = 32
+
+// Returned for jobs DescribeJobs does not return, e.g. jobs that finished long ago or were never submitted
+var ErrBatchJobNotFound = errors.New("no such job")
+
+// Batch controllers are shared per credentials and region, so that all jobs use the same connections,
+// and requests of all jobs count against the same rate limit, like the API limits of the account do
+var (
+	batchControllersMu sync.Mutex
+	batchControllers   = make(map[string]*AWSBatchController)
+)
+
+type describeRequest struct {
+	jobID string
+	resp  chan describeResult
+}
+
+type describeResult struct {
+	job *batch.JobDetail
+	err error
+}
+
+// NewAWSBatchController returns the Batch controller of the credentials and region, it is created on first use.
+// Requests are retried with exponential backoff and jitter, longer when they are throttled, up to BATCH_API_MAX_RETRIES times,
+// and every attempt waits for the BATCH_API_RATE limit.
+func NewAWSBatchController(accessKey, secretAccessKey, region string) (*AWSBatchController, error) {
+	key := accessKey + "\x00" + secretAccessKey + "\x00" + region
+	batchControllersMu.Lock()
+	defer batchControllersMu.Unlock()
+	if c, ok := batchControllers[key]; ok {
+		return c, nil
+	}
+
+	cfg := config.Get().AWS
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = batchMaxIdleConns
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentialsFromCreds(credentials.Value{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretAccessKey,
+		}),
+		Region:     aws.String(region),
+		HTTPClient: &http.Client{Transport: transport},
+		Retryer: client.DefaultRetryer{
+			NumMaxRetries:    cfg.BatchAPIMaxRetries,
+			MinRetryDelay:    100 * time.Millisecond,
+			MaxRetryDelay:    5 * time.Second,
+			MinThrottleDelay: 500 * time.Millisecond,
+			MaxThrottleDelay: 20 * time.Second,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svc := batch.New(sess)
+	if cfg.BatchAPIRate > 0 {
+		limiter := rate.NewLimiter(rate.Limit(cfg.BatchAPIRate), max(1, int(cfg.BatchAPIRate)))
+		// Sign runs before every attempt, retries wait for the limit too
+		svc.Handlers.Sign.PushFront(func(r *request.Request) {
+			if err := limiter.Wait(r.Context()); err != nil {
+				r.Error = err
+			}
+		})
+	}
+
+	c := &AWSBatchController{client: svc, describes: make(chan describeRequest)}
+	go c.describeLoop()
+	batchControllers[key] = c
+	return c, nil
+}
+
+// describeJob returns the details of a job. Requests of all jobs are collected for describeJobsWindow
+// and sent in DescribeJobs calls of up to describeJobsMaxIDs jobs.
+func (c *AWSBatchController) describeJob(jobID string) (*batch.JobDetail, error) {
+	req := describeRequest{jobID: jobID, resp: make(chan describeResult, 1)}
+	c.describes <- req
+	res := <-req.resp
+	return res.job, res.err
+}
+
+func (c *AWSBatchController) describeLoop() {
+	for req := range c.describes {
+		reqs := []describeRequest{req}
+		timer := time.NewTimer(describeJobsWindow)
+	collect:
+		for len(reqs) < describeJobsMaxIDs {
+			select {
+			case r := <-c.describes:
+				reqs = append(reqs, r)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		go c.describeBatch(reqs)
+	}
+}
+
+func (c *AWSBatchController) describeBatch(reqs []describeRequest) {
+	ids := make([]string, 0, len(reqs))
+	seen := make(map[string]bool, len(reqs))
+	for _, r := range reqs {
+		if !seen[r.jobID] {
+			seen[r.jobID] = true
+			ids = append(ids, r.jobID)
+		}
+	}
+
+	jobs, err := c.DescribeJobs(ids)
+	for _, r := range reqs {
+		switch {
+		case err != nil:
+			r.resp <- describeResult{err: err}
+		case jobs[r.jobID] == nil:
+			r.resp <- describeResult{err: fmt.Errorf("%w: %s", ErrBatchJobNotFound, r.jobID)}
+		default:
+			r.resp <- describeResult{job: jobs[r.jobID]}
+		}
+	}
+}
+
+// DescribeJobs returns the details of jobs by job ID, in calls of up to 100 jobs. Jobs that do not exist are not in the result.
+func (c *AWSBatchController) DescribeJobs(jobIDs []string) (map[string]*batch.JobDetail, error) {
+	jobs := make(map[string]*batch.JobDetail, len(jobIDs))
+	for start := 0; start < len(jobIDs); start += describeJobsMaxIDs {
+		end := min(start+describeJobsMaxIDs, len(jobIDs))
+		output, err := c.client.DescribeJobs(&batch.DescribeJobsInput{Jobs: aws.StringSlice(jobIDs[start:end])})
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range output.Jobs {
+			jobs[aws.StringValue(j.JobId)] = j
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Max messages of one ReceiveMessage call and time it waits for messages, as limited by the SQS API
+const (
+	batchEventsMaxMessages = 10
+	batchEventsWaitSeconds = 20
+)
+
+// BatchJobStateChange is a "Batch Job State Change" event of EventBridge received from the events queue
+type BatchJobStateChange struct {
+	BatchID string
+	JobName string
+	// Status of the Batch job formatted like statuses of JobMonitor
+	Status string
+	Time   time.Time
+	// Attempts of the Batch job that started, a job that is RUNNABLE again after an attempt stopped is retried by Batch
+	Attempts []BatchAttempt
+}
+
+// BatchEventsQueue receives Batch job state change events that an EventBridge rule sends to an SQS queue
+type BatchEventsQueue struct {
+	client   *sqs.SQS
+	queueURL string
+}
+
+// Envelope of EventBridge events, only fields of Batch job state changes are decoded
+type batchEvent struct {
+	Source     string    `json:"source"`
+	DetailType string    `json:"detail-type"`
+	Time       time.Time `json:"time"`
+	Detail     struct {
+		JobID        string `json:"jobId"`
+		JobName      string `json:"jobName"`
+		Status       string `json:"status"`
+		StatusReason string `json:"statusReason"`
+		Attempts     []struct {
+			Container struct {
+				ExitCode *int   `json:"exitCode"`
+				Reason   string `json:"reason"`
+			} `json:"container"`
+			StartedAt    int64  `json:"startedAt"`
+			StoppedAt    int64  `json:"stoppedAt"`
+			StatusReason string `json:"statusReason"`
+		} `json:"attempts"`
+	} `json:"detail"`
+}
+
+func NewBatchEventsQueue(queueURL, accessKey, secretAccessKey, region string) (*BatchEventsQueue, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentialsFromCreds(credentials.Value{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretAccessKey,
+		}),
+		Region: aws.String(region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating sqs session: %s", err.Error())
+	}
+	return &BatchEventsQueue{client: sqs.New(sess), queueURL: queueURL}, nil
+}
+
+// Receive waits up to 20 seconds for messages and returns the state changes they contain with the receipt handles
+// of all received messages. Messages that are not Batch job state changes are skipped, their handles are returned
+// too so that they are deleted instead of being received again.
+func (q *BatchEventsQueue) Receive(ctx context.Context) ([]BatchJobStateChange, []string, error) {
+	output, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: aws.Int64(batchEventsMaxMessages),
+		WaitTimeSeconds:     aws.Int64(batchEventsWaitSeconds),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make([]BatchJobStateChange, 0, len(output.Messages))
+	handles := make([]string, 0, len(output.Messages))
+	for _, m := range output.Messages {
+		handles = append(handles, aws.StringValue(m.ReceiptHandle))
+
+		var e batchEvent
+		if err := json.Unmarshal([]byte(aws.StringValue(m.Body)), &e); err != nil {
+			continue
+		}
+		if e.Source != "aws.batch" || e.DetailType != "Batch Job State Change" {
+			continue
+		}
+		status, err := formatBatchStatus(e.Detail.Status, e.Detail.StatusReason)
+		if err != nil {
+			continue
+		}
+		change := BatchJobStateChange{BatchID: e.Detail.JobID, JobName: e.Detail.JobName, Status: status, Time: e.Time}
+		for _, a := range e.Detail.Attempts {
+			change.Attempts = append(change.Attempts, BatchAttempt{
+				Started:      unixMilli(a.StartedAt),
+				Stopped:      unixMilli(a.StoppedAt),
+				ExitCode:     a.Container.ExitCode,
+				Reason:       a.Container.Reason,
+				StatusReason: a.StatusReason,
+			})
+		}
+		changes = append(changes, change)
+	}
+	return changes, handles, nil
+}
+
+// Delete removes processed messages from the queue
+func (q *BatchEventsQueue) Delete(ctx context.Context, handles []string) error {
+	for start := 0; start < len(handles); start += batchEventsMaxMessages {
+		end := min(start+batchEventsMaxMessages, len(handles))
+		entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, end-start)
+		for i, h := range handles[start:end] {
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{Id: aws.String(fmt.Sprint(i)), ReceiptHandle: aws.String(h)})
+		}
+		output, err := q.client.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(q.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.Failed) > 0 {
+			return fmt.Errorf("could not delete %d messages: %s", len(output.Failed), aws.StringValue(output.Failed[0].Message))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/batch"
+)
+
+// Tag of job definitions registered by EnsureJobDefinition, hash of the spec they were registered from
+const jobDefinitionSpecTag = "sepex:spec-hash"
+
+// JobDefinitionSpec is a container job definition generated from a process spec
+type JobDefinitionSpec struct {
+	Name   string
+	Image  string
+	VCPUs  float32
+	Memory int // MB
+	// Attempts of a job, 0 keeps the Batch default of 1
+	RetryAttempts  int
+	EvaluateOnExit []EvaluateOnExit
+	// Seconds after which attempts are terminated, 0 does not set a timeout
+	TimeoutSeconds   int64
+	JobRoleArn       string
+	ExecutionRoleArn string
+}
+
+// EvaluateOnExit retries or exits a failed attempt whose exit code, reason or status reason matches, see the Batch API
+type EvaluateOnExit struct {
+	OnExitCode     string
+	OnReason       string
+	OnStatusReason string
+	Action         string // RETRY or EXIT
+}
+
+// Hash of the spec, stored in a tag of the job definition to find out whether it changed
+func (s JobDefinitionSpec) hash() string {
+	b, _ := json.Marshal(s)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EnsureJobDefinition registers a revision of job definition spec.Name unless its latest active revision was registered
+// from the same spec. Returns the ARN of the revision and true if it was registered.
+func (c *AWSBatchController) EnsureJobDefinition(spec JobDefinitionSpec) (string, bool, error) {
+	hash := spec.hash()
+
+	output, err := c.client.DescribeJobDefinitions(&batch.DescribeJobDefinitionsInput{
+		JobDefinitionName: aws.String(spec.Name),
+		Status:            aws.String("ACTIVE"),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	var latest *batch.JobDefinition
+	for _, jd := range output.JobDefinitions {
+		if latest == nil || aws.Int64Value(jd.Revision) > aws.Int64Value(latest.Revision) {
+			latest = jd
+		}
+	}
+	if latest != nil && aws.StringValue(latest.Tags[jobDefinitionSpecTag]) == hash {
+		return aws.StringValue(latest.JobDefinitionArn), false, nil
+	}
+
+	input := &batch.RegisterJobDefinitionInput{
+		JobDefinitionName: aws.String(spec.Name),
+		Type:              aws.String(batch.JobDefinitionTypeContainer),
+		ContainerProperties: &batch.ContainerProperties{
+			Image: aws.String(spec.Image),
+			ResourceRequirements: []*batch.ResourceRequirement{
+				{Type: aws.String(batch.ResourceTypeVcpu), Value: aws.String(strconv.FormatFloat(float64(spec.VCPUs), 'f', -1, 32))},
+				{Type: aws.String(batch.ResourceTypeMemory), Value: aws.String(strconv.Itoa(spec.Memory))},
+			},
+		},
+		Tags: map[string]*string{jobDefinitionSpecTag: aws.String(hash)},
+	}
+	if spec.JobRoleArn != "" {
+		input.ContainerProperties.JobRoleArn = aws.String(spec.JobRoleArn)
+	}
+	if spec.ExecutionRoleArn != "" {
+		input.ContainerProperties.ExecutionRoleArn = aws.String(spec.ExecutionRoleArn)
+	}
+	if spec.RetryAttempts > 0 || len(spec.EvaluateOnExit) > 0 {
+		rs := &batch.RetryStrategy{}
+		if spec.RetryAttempts > 0 {
+			rs.Attempts = aws.Int64(int64(spec.RetryAttempts))
+		}
+		for _, e := range spec.EvaluateOnExit {
+			eoe := &batch.EvaluateOnExit{Action: aws.String(e.Action)}
+			if e.OnExitCode != "" {
+				eoe.OnExitCode = aws.String(e.OnExitCode)
+			}
+			if e.OnReason != "" {
+				eoe.OnReason = aws.String(e.OnReason)
+			}
+			if e.OnStatusReason != "" {
+				eoe.OnStatusReason = aws.String(e.OnStatusReason)
+			}
+			rs.EvaluateOnExit = append(rs.EvaluateOnExit, eoe)
+		}
+		input.RetryStrategy = rs
+	}
+	if spec.TimeoutSeconds > 0 {
+		input.Timeout = &batch.JobTimeout{AttemptDurationSeconds: aws.Int64(spec.TimeoutSeconds)}
+	}
+
+	registered, err := c.client.RegisterJobDefinition(input)
+	if err != nil {
+		return "", false, fmt.Errorf("could not register job definition %s: %s", spec.Name, err.Error())
+	}
+	return aws.StringValue(registered.JobDefinitionArn), true, nil
+}
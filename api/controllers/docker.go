@@ -2,23 +2,47 @@ package controllers
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
+	dockerevents "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
-	"github.com/labstack/gommon/log"
+	"github.com/docker/docker/pkg/stdcopy"
+	log "github.com/sirupsen/logrus"
 )
 
 const DOCKER_NETWORK = "process_api_net"
 
+// Labels of containers created for jobs, used to find containers left behind by jobs that are no longer active
+const (
+	LabelJobID   = "sepex.job-id"
+	LabelAPIName = "sepex.api" // API_NAME of the server that created the resource, several servers can share a Docker daemon
+	// ID of the process whose health check runs in the container, these containers are removed by the check and not reaped
+	LabelSelfTest = "sepex.selftest"
+)
+
+// JobResource is a container labeled with the ID of the job it was created for, or another resource left by a job
+type JobResource struct {
+	ID      string    `json:"id"`
+	JobID   string    `json:"jobID"`
+	Kind    string    `json:"kind"` // container, or scratch for scratch directories and inputs files
+	State   string    `json:"state,omitempty"`
+	Created time.Time `json:"created"`
+}
+
 type DockerController struct {
 	cli *client.Client
 }
@@ -41,6 +65,26 @@ func createDockerNetwork(cli *client.Client, ctx context.Context, networkName st
 
 type DockerResources container.Resources
 
+// DockerSecurity restricts what the container of a job can do, nil runs it as configured by its image on DOCKER_NETWORK
+type DockerSecurity struct {
+	// Network the container is attached to, none disables networking, DOCKER_NETWORK if empty
+	Network string
+	// Linux capabilities dropped from the container, ALL drops every capability
+	CapDrop []string
+	// Mount the root filesystem of the container read-only, volumes and the scratch directory stay writable
+	ReadOnlyRootfs bool
+	// User the process runs as, name or uid with optional group, e.g. 1000:1000, the user of the image if empty
+	User string
+}
+
+// DockerImageOverrides replaces the entrypoint and working directory of the image, nil or empty fields keep those of the image
+type DockerImageOverrides struct {
+	// Command is passed to the entrypoint as arguments
+	Entrypoint []string
+	// Absolute path in the container, created if it does not exist
+	WorkingDir string
+}
+
 func NewDockerController() (*DockerController, error) {
 	c := new(DockerController)
 	var err error
@@ -53,42 +97,65 @@ func NewDockerController() (*DockerController, error) {
 }
 
 // returns container id, error
-func (c *DockerController) ContainerRun(ctx context.Context, imageName string, command []string, volumes []string, envVars []string, resources DockerResources) (string, error) {
+func (c *DockerController) ContainerRun(ctx context.Context, imageName, platform string, command []string, volumes []string, envVars []string, resources DockerResources, security *DockerSecurity, overrides *DockerImageOverrides, labels map[string]string) (string, error) {
 	hostConfig := container.HostConfig{
 		Resources: container.Resources(resources),
 	}
+	if security == nil {
+		security = &DockerSecurity{}
+	}
+	if overrides == nil {
+		overrides = &DockerImageOverrides{}
+	}
+	hostConfig.CapDrop = security.CapDrop
+	hostConfig.ReadonlyRootfs = security.ReadOnlyRootfs
 
 	mounts := make([]mount.Mount, len(volumes))
 	for i, volumeSpec := range volumes {
-		parts := strings.Split(volumeSpec, ":") // this has been already validated
-		mount := mount.Mount{
-			Type:   mount.TypeBind,
-			Source: parts[0],
-			Target: parts[1],
+		m, err := ParseVolume(volumeSpec) // validated when the process was registered
+		if err != nil {
+			return "", err
 		}
-		mounts[i] = mount
+		mounts[i] = m
 	}
 	hostConfig.Mounts = mounts
 
-	err := createDockerNetwork(c.cli, ctx, DOCKER_NETWORK)
-	if err != nil {
-		log.Error(err)
-		return "", err
-	}
-
-	// Define the network mode
-	netConfig := &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			DOCKER_NETWORK: {},
-		},
+	// Define the network mode, custom networks are managed by the deployment and must exist
+	var netConfig *network.NetworkingConfig
+	switch security.Network {
+	case "none":
+		hostConfig.NetworkMode = "none"
+	case "", DOCKER_NETWORK:
+		err := createDockerNetwork(c.cli, ctx, DOCKER_NETWORK)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+		netConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				DOCKER_NETWORK: {},
+			},
+		}
+	default:
+		hostConfig.NetworkMode = container.NetworkMode(security.Network)
+		netConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				security.Network: {},
+			},
+		}
 	}
 
+	// No TTY so that stdout and stderr are kept apart, see ContainerLog
 	resp, err := c.cli.ContainerCreate(ctx, &container.Config{
-		Tty:   true,
-		Image: imageName,
-		Cmd:   command,
-		Env:   envVars,
-	}, &hostConfig, netConfig, nil, "")
+		Tty:        false,
+		Image:      imageName,
+		Entrypoint: overrides.Entrypoint,
+		Cmd:        command,
+		WorkingDir: overrides.WorkingDir,
+		Env:        envVars,
+		Labels:     labels,
+		User:       security.User,
+	}, &hostConfig, netConfig, ociPlatform(platform), "")
 	// log.Info("Container Create response", resp)
 	if err != nil {
 		log.Error(err)
@@ -109,17 +176,42 @@ func (c *DockerController) Version() string {
 	return c.cli.ClientVersion()
 }
 
-// returns container logs as string, error
-func (c *DockerController) ContainerLog(ctx context.Context, id string) ([]string, error) {
+// NetworkInspect returns an error if network name does not exist
+func (c *DockerController) NetworkInspect(ctx context.Context, name string) error {
+	_, err := c.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	return err
+}
+
+// returns stdout and stderr lines of container logs, error
+func (c *DockerController) ContainerLog(ctx context.Context, id string) ([]string, []string, error) {
 
 	reader, err := c.cli.ContainerLogs(ctx, id, container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	// Containers run without TTY so that streams are multiplexed with headers identifying them
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, reader); err != nil {
+		return nil, nil, err
+	}
+
+	stdoutLines, err := splitLines(&stdout)
+	if err != nil {
+		return nil, nil, err
+	}
+	stderrLines, err := splitLines(&stderr)
+	if err != nil {
+		return nil, nil, err
 	}
+	return stdoutLines, stderrLines, nil
+}
 
-	scanner := bufio.NewScanner(reader)
+func splitLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
 	var logs []string
 
 	for scanner.Scan() {
@@ -133,6 +225,178 @@ func (c *DockerController) ContainerLog(ctx context.Context, id string) ([]strin
 	return logs, nil
 }
 
+// ContainerLogStream copies stdout and stderr of a container to the writers from since, or from the start of the container
+// if since is zero, and follows them until the container stops or ctx is cancelled. It blocks until then.
+// Each write is one log message prefixed with its RFC3339Nano timestamp and a space.
+func (c *DockerController) ContainerLogStream(ctx context.Context, id string, since time.Time, stdout, stderr io.Writer) error {
+	opts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !since.IsZero() {
+		opts.Since = since.Format(time.RFC3339Nano)
+	}
+	reader, err := c.cli.ContainerLogs(ctx, id, opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	return err
+}
+
+// ContainerStatsSample is a single resource usage sample of a container
+type ContainerStatsSample struct {
+	Time        time.Time
+	CPUPercent  float64 // percentage of a single CPU, same as `docker stats`, 200 means 2 CPUs fully used
+	MemoryBytes uint64  // memory used excluding page cache
+	MemoryLimit uint64
+	NetRxBytes  uint64
+	NetTxBytes  uint64
+	BlkRead     uint64
+	BlkWrite    uint64
+}
+
+// ContainerStatsStream sends container stats samples (about one per second) to samples
+// until the container stops or ctx is cancelled. It blocks and does not close samples.
+func (c *DockerController) ContainerStatsStream(ctx context.Context, id string, samples chan<- ContainerStatsSample) error {
+	resp, err := c.cli.ContainerStats(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var st container.StatsResponse
+		if err := decoder.Decode(&st); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if st.Read.IsZero() { // container has stopped
+			return nil
+		}
+		samples <- newContainerStatsSample(st)
+	}
+}
+
+// Calculations follow docker cli
+// https://github.com/docker/cli/blob/master/cli/command/container/stats_helpers.go
+func newContainerStatsSample(st container.StatsResponse) ContainerStatsSample {
+	s := ContainerStatsSample{Time: st.Read, MemoryLimit: st.MemoryStats.Limit}
+
+	cpuDelta := float64(st.CPUStats.CPUUsage.TotalUsage) - float64(st.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(st.CPUStats.SystemUsage) - float64(st.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(st.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(st.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		s.CPUPercent = (cpuDelta / systemDelta) * onlineCPUs * 100
+	}
+
+	// cgroup v1 uses total_inactive_file, cgroup v2 uses inactive_file
+	s.MemoryBytes = st.MemoryStats.Usage
+	for _, k := range []string{"total_inactive_file", "inactive_file"} {
+		if v, ok := st.MemoryStats.Stats[k]; ok && v < s.MemoryBytes {
+			s.MemoryBytes -= v
+			break
+		}
+	}
+
+	for _, n := range st.Networks {
+		s.NetRxBytes += n.RxBytes
+		s.NetTxBytes += n.TxBytes
+	}
+
+	for _, e := range st.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			s.BlkRead += e.Value
+		case "write":
+			s.BlkWrite += e.Value
+		}
+	}
+	return s
+}
+
+// returns true if container was killed by the kernel OOM killer
+func (c *DockerController) ContainerOOMKilled(ctx context.Context, id string) (bool, error) {
+	info, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if info.State == nil {
+		return false, nil
+	}
+	return info.State.OOMKilled, nil
+}
+
+// ContainerState returns the state of the container, nil if the container does not exist
+func (c *DockerController) ContainerState(ctx context.Context, id string) (*container.State, error) {
+	info, err := c.cli.ContainerInspect(ctx, id)
+	if cerrdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.State == nil {
+		return &container.State{}, nil
+	}
+	return info.State, nil
+}
+
+// ContainerEvent is a die, oom or kill event of a job container
+type ContainerEvent struct {
+	ContainerID string
+	JobID       string
+	Action      string // die, oom or kill
+	ExitCode    int    // exit code of die events
+	Signal      string // signal of kill events
+	Time        time.Time
+}
+
+// ContainerEventStream sends die, oom and kill events of job containers of the server named apiName to events,
+// starting at since if it is set. Returns when ctx is cancelled or the stream ends.
+func (c *DockerController) ContainerEventStream(ctx context.Context, apiName string, since time.Time, events chan<- ContainerEvent) error {
+	f := filters.NewArgs(
+		filters.Arg("type", string(dockerevents.ContainerEventType)),
+		filters.Arg("label", LabelAPIName+"="+apiName),
+		filters.Arg("label", LabelJobID),
+		filters.Arg("event", string(dockerevents.ActionDie)),
+		filters.Arg("event", string(dockerevents.ActionOOM)),
+		filters.Arg("event", string(dockerevents.ActionKill)),
+	)
+	opts := dockerevents.ListOptions{Filters: f}
+	if !since.IsZero() {
+		opts.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+
+	msgs, errs := c.cli.Events(ctx, opts)
+	for {
+		select {
+		case m := <-msgs:
+			e := ContainerEvent{
+				ContainerID: m.Actor.ID,
+				JobID:       m.Actor.Attributes[LabelJobID],
+				Action:      string(m.Action),
+				Signal:      m.Actor.Attributes["signal"],
+				Time:        time.Unix(0, m.TimeNano),
+			}
+			e.ExitCode, _ = strconv.Atoi(m.Actor.Attributes["exitCode"])
+			events <- e
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
 // returns container status code, error
 func (c *DockerController) ContainerWait(ctx context.Context, id string) (int64, error) {
 	resultC, errC := c.cli.ContainerWait(ctx, id, "")
@@ -144,12 +408,42 @@ func (c *DockerController) ContainerWait(ctx context.Context, id string) (int64,
 	}
 }
 
+// ContainerRemove removes a container with its anonymous volumes, e.g. those of VOLUME instructions of its image.
+// Named volumes are kept.
 func (c *DockerController) ContainerRemove(ctx context.Context, containerID string) error {
 	return c.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
-		Force: true,
+		Force:         true,
+		RemoveVolumes: true,
 	})
 }
 
+// Containers labeled with job IDs by the server named apiName
+func (c *DockerController) ListJobResources(ctx context.Context, apiName string) ([]JobResource, error) {
+	f := filters.NewArgs(filters.Arg("label", LabelAPIName+"="+apiName), filters.Arg("label", LabelJobID))
+
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]JobResource, 0, len(containers))
+	for _, ct := range containers {
+		resources = append(resources, JobResource{
+			ID:      ct.ID,
+			JobID:   ct.Labels[LabelJobID],
+			Kind:    "container",
+			State:   ct.State,
+			Created: time.Unix(ct.Created, 0),
+		})
+	}
+	return resources, nil
+}
+
+// ContainerStop sends SIGTERM to the container and SIGKILL if it is still running after timeout
+func (c *DockerController) ContainerStop(ctx context.Context, containerID string, timeout time.Duration) error {
+	secs := int(timeout.Seconds())
+	return c.cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &secs})
+}
+
 func (c *DockerController) ContainerKill(ctx context.Context, containerID string) (err error) {
 	err = c.cli.ContainerKill(ctx, containerID, "KILL")
 	// to do ignore error if container is already killed
@@ -157,7 +451,9 @@ func (c *DockerController) ContainerKill(ctx context.Context, containerID string
 }
 
 // https://gist.github.com/miguelmota/4980b18d750fb3b1eb571c3e207b1b92
-func (c *DockerController) EnsureImage(ctx context.Context, imageName string, verbose bool) error {
+// EnsureImage pulls imageName for platform with the credentials of auth if it does not exist locally, nil auth pulls anonymously.
+// An empty platform pulls for the platform of the docker daemon. Fails if the image is not built for the platform, see CheckImagePlatform.
+func (c *DockerController) EnsureImage(ctx context.Context, imageName, platform string, auth RegistryAuthFunc, verbose bool) error {
 	images, err := c.cli.ImageList(ctx, image.ListOptions{})
 	if err != nil {
 		return err
@@ -166,13 +462,36 @@ func (c *DockerController) EnsureImage(ctx context.Context, imageName string, ve
 	for _, img := range images {
 		for _, tag := range img.RepoTags {
 			if strings.EqualFold(tag, imageName) {
-				// Image already exists, return nil
-				return nil
+				// Image already exists, it is pulled again if it was pulled for another platform than the requested one
+				err := c.CheckImagePlatform(ctx, imageName, platform)
+				if err == nil || platform == "" {
+					return err
+				}
+				return c.ImagePull(ctx, imageName, platform, auth, verbose)
 			}
 		}
 	}
 
-	reader, err := c.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	return c.ImagePull(ctx, imageName, platform, auth, verbose)
+}
+
+// ImagePull pulls imageName for platform even if it exists locally, so that a tag moved to a new image is updated.
+// Fails if the pulled image is not built for the platform, see CheckImagePlatform.
+func (c *DockerController) ImagePull(ctx context.Context, imageName, platform string, auth RegistryAuthFunc, verbose bool) error {
+	opts := image.PullOptions{Platform: platform}
+	if auth != nil {
+		a, err := auth(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get credentials of registry %s: %s", RegistryHost(imageName), err.Error())
+		}
+		if a != nil {
+			if opts.RegistryAuth, err = a.encode(); err != nil {
+				return err
+			}
+		}
+	}
+
+	reader, err := c.cli.ImagePull(ctx, imageName, opts)
 	if err != nil {
 		return err
 	}
@@ -190,7 +509,7 @@ func (c *DockerController) EnsureImage(ctx context.Context, imageName string, ve
 		return err
 	}
 
-	return nil
+	return c.CheckImagePlatform(ctx, imageName, platform)
 }
 
 // Get Image Digest from Image URI
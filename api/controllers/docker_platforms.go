@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Platforms of images as accepted by docker pull --platform, os/arch[/variant], e.g. linux/amd64 or linux/arm64/v8
+var platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// ValidPlatform reports whether platform is of the form os/arch[/variant]
+func ValidPlatform(platform string) bool {
+	return platformRegex.MatchString(platform)
+}
+
+// Platform as accepted by ContainerCreate, nil if platform is empty
+func ociPlatform(platform string) *ocispec.Platform {
+	if platform == "" {
+		return nil
+	}
+	parts := strings.SplitN(platform, "/", 3)
+	p := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) > 2 {
+		p.Variant = parts[2]
+	}
+	return p
+}
+
+// Whether an image of platform have runs on platform want, variants are only compared if both have one
+// since images and daemons often omit the default variant, e.g. v8 of arm64
+func platformMatches(have, want string) bool {
+	h, w := strings.SplitN(have, "/", 3), strings.SplitN(want, "/", 3)
+	if len(h) < 2 || len(w) < 2 || h[0] != w[0] || h[1] != w[1] {
+		return false
+	}
+	return len(h) < 3 || len(w) < 3 || h[2] == w[2]
+}
+
+// ImagePlatform returns the platform local image imageName is built for as os/arch[/variant]
+func (c *DockerController) ImagePlatform(ctx context.Context, imageName string) (string, error) {
+	img, err := c.cli.ImageInspect(ctx, imageName)
+	if err != nil {
+		return "", err
+	}
+	platform := img.Os + "/" + img.Architecture
+	if img.Variant != "" {
+		platform += "/" + img.Variant
+	}
+	return platform, nil
+}
+
+// DaemonPlatform returns the platform of the docker daemon as os/arch, images are pulled and run for it by default
+func (c *DockerController) DaemonPlatform(ctx context.Context) (string, error) {
+	v, err := c.cli.ServerVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return v.Os + "/" + v.Arch, nil
+}
+
+// CheckImagePlatform returns an error if local image imageName is not built for platform, or for the platform of
+// the docker daemon if platform is empty. Without the check such images fail when their container starts,
+// e.g. with exec format error for amd64 images on arm64 hosts.
+func (c *DockerController) CheckImagePlatform(ctx context.Context, imageName, platform string) error {
+	have, err := c.ImagePlatform(ctx, imageName)
+	if err != nil {
+		return err
+	}
+	if platform != "" {
+		if !platformMatches(have, platform) {
+			return fmt.Errorf("image %s is built for %s, not for platform %s", imageName, have, platform)
+		}
+		return nil
+	}
+
+	daemon, err := c.DaemonPlatform(ctx)
+	if err != nil {
+		return err
+	}
+	if !platformMatches(have, daemon) {
+		return fmt.Errorf("image %s is built for %s but the docker daemon runs on %s, use an image built for %s or set host.platform to %s to run it emulated", imageName, have, daemon, daemon, have)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A shared client that was not used for this long is pinged before it is returned again
+const dockerHealthInterval = 30 * time.Second
+
+// Max time to wait for the daemon to answer a ping
+const dockerPingTimeout = 5 * time.Second
+
+// SharedDockerController is a Docker controller created on first use and shared by the server and its jobs, so that
+// connections to the daemon are pooled by a single client. If the client was not checked for dockerHealthInterval
+// the daemon is pinged first, and the client is created again when the ping fails, e.g. after the daemon restarted.
+type SharedDockerController struct {
+	mu      sync.Mutex
+	c       *DockerController
+	checked time.Time
+}
+
+// Get returns the shared controller. A nil SharedDockerController returns a new controller on every call,
+// so that callers that were not given a shared controller keep working.
+func (s *SharedDockerController) Get() (*DockerController, error) {
+	if s == nil {
+		return NewDockerController()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.c != nil {
+		if time.Since(s.checked) < dockerHealthInterval {
+			return s.c, nil
+		}
+		if err := s.c.Ping(); err == nil {
+			s.checked = time.Now()
+			return s.c, nil
+		}
+		// Streams of running jobs keep their connections, only idle connections are closed
+		s.c.Close()
+		s.c = nil
+	}
+
+	c, err := NewDockerController()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Ping(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	s.c, s.checked = c, time.Now()
+	return c, nil
+}
+
+// Ping checks that the daemon answers within dockerPingTimeout
+func (c *DockerController) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+	defer cancel()
+	_, err := c.cli.Ping(ctx)
+	return err
+}
+
+// Close closes idle connections of the client
+func (c *DockerController) Close() error {
+	return c.cli.Close()
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	units "github.com/docker/go-units"
+)
+
+// Names of named docker volumes, as accepted by docker volume create
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ParseVolume parses a volume specification of a process, <source>:<container path>[:<options>], into a mount:
+//   - <host path>:<container path>[:ro|rw] bind mounts a host directory, host paths start with . or contain a path separator
+//   - <name>:<container path>[:ro|rw] mounts a named docker volume, docker creates it if it does not exist
+//   - tmpfs:<container path>[:size=<size>] mounts a tmpfs of at most size bytes, e.g. size=64m, removed with the container
+func ParseVolume(spec string) (mount.Mount, error) {
+	// Windows host paths start with a drive letter, e.g. C:\data:/data
+	drive := filepath.VolumeName(spec)
+	parts := strings.Split(spec[len(drive):], ":")
+	parts[0] = drive + parts[0]
+	if len(parts) < 2 || len(parts) > 3 {
+		return mount.Mount{}, fmt.Errorf("invalid volume specification %q: expected <source>:<container path>[:<options>]", spec)
+	}
+	source, target := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if source == "" {
+		return mount.Mount{}, fmt.Errorf("invalid volume specification %q: empty source path", spec)
+	}
+	if !strings.HasPrefix(target, "/") {
+		return mount.Mount{}, fmt.Errorf("invalid volume specification %q: container path must be absolute", spec)
+	}
+
+	m := mount.Mount{Source: source, Target: target}
+	switch {
+	case source == "tmpfs":
+		m.Type = mount.TypeTmpfs
+		m.Source = ""
+	case strings.HasPrefix(source, ".") || strings.ContainsRune(source, '/') || strings.ContainsRune(source, filepath.Separator):
+		m.Type = mount.TypeBind
+	case volumeNameRegex.MatchString(source):
+		m.Type = mount.TypeVolume
+	default:
+		return mount.Mount{}, fmt.Errorf("invalid volume specification %q: %s is neither a host path nor a volume name", spec, source)
+	}
+
+	if len(parts) < 3 {
+		return m, nil
+	}
+	for _, opt := range strings.Split(parts[2], ",") {
+		switch name, value, _ := strings.Cut(strings.TrimSpace(opt), "="); {
+		case m.Type == mount.TypeTmpfs && name == "size":
+			size, err := units.RAMInBytes(value)
+			if err != nil || size <= 0 {
+				return mount.Mount{}, fmt.Errorf("invalid volume specification %q: invalid tmpfs size %q, e.g. size=64m", spec, value)
+			}
+			m.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: size}
+		case m.Type != mount.TypeTmpfs && name == "ro" && value == "":
+			m.ReadOnly = true
+		case m.Type != mount.TypeTmpfs && name == "rw" && value == "":
+			m.ReadOnly = false
+		default:
+			return mount.Mount{}, fmt.Errorf("invalid volume specification %q: unsupported option %q for %s volumes", spec, opt, m.Type)
+		}
+	}
+	return m, nil
+}
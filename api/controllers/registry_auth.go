@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"app/config"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// Registry of images without a registry host, as named in docker config files
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// RegistryAuth are credentials of a registry passed to the docker daemon when an image is pulled.
+// They must never be logged or stored.
+type RegistryAuth struct {
+	Username      string
+	Password      string
+	ServerAddress string
+}
+
+// RegistryAuthFunc returns the credentials to pull an image with, nil credentials pull anonymously.
+// It is only called when an image is pulled, so that short lived tokens are fetched when they are needed.
+type RegistryAuthFunc func(ctx context.Context) (*RegistryAuth, error)
+
+func (a *RegistryAuth) encode() (string, error) {
+	return registry.EncodeAuthConfig(registry.AuthConfig{Username: a.Username, Password: a.Password, ServerAddress: a.ServerAddress})
+}
+
+// RegistryHost returns the registry of an image reference like docker does, docker.io for images without registry host
+func RegistryHost(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		return "docker.io"
+	}
+	return first
+}
+
+// IsECRImage returns true if image is in an ECR registry, `<account>.dkr.ecr.<region>.amazonaws.com`
+func IsECRImage(image string) bool {
+	_, _, ok := parseECRHost(RegistryHost(image))
+	return ok
+}
+
+func parseECRHost(host string) (account, region string, ok bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" || parts[4] != "amazonaws" {
+		return "", "", false
+	}
+	return parts[0], parts[3], true
+}
+
+// ECRAuth gets a token for the ECR registry of image with the AWS credentials of the server, tokens are valid for 12 hours
+func ECRAuth(ctx context.Context, image string) (*RegistryAuth, error) {
+	host := RegistryHost(image)
+	account, region, ok := parseECRHost(host)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECR registry", host)
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentialsFromCreds(credentials.Value{
+			AccessKeyID:     config.Get().AWS.AccessKeyID,
+			SecretAccessKey: config.Get().AWS.SecretAccessKey,
+		}),
+		Region: aws.String(region)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	out, err := ecr.New(sess).GetAuthorizationTokenWithContext(ctx, &ecr.GetAuthorizationTokenInput{RegistryIds: []*string{aws.String(account)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("no authorization token returned for %s", host)
+	}
+
+	token, err := base64.StdEncoding.DecodeString(aws.StringValue(out.AuthorizationData[0].AuthorizationToken))
+	if err != nil {
+		return nil, err
+	}
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return nil, errors.New("invalid ECR authorization token")
+	}
+	return &RegistryAuth{Username: username, Password: password, ServerAddress: host}, nil
+}
+
+// DockerConfigAuth returns the credentials of the registry of image from the `auths` of a docker config.json file, nil if it has none.
+// Credential helpers (`credsStore`, `credHelpers`) are not supported.
+func DockerConfigAuth(file, image string) (*RegistryAuth, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid docker config file %s: %s", file, err.Error())
+	}
+
+	host := RegistryHost(image)
+	keys := []string{host, "https://" + host, "http://" + host}
+	if host == "docker.io" {
+		keys = append([]string{dockerHubAuthKey}, keys...)
+	}
+	for _, k := range keys {
+		a, ok := cfg.Auths[k]
+		if !ok {
+			continue
+		}
+		username, password := a.Username, a.Password
+		if a.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s in %s", k, file)
+			}
+			username, password, _ = strings.Cut(string(decoded), ":")
+		}
+		return &RegistryAuth{Username: username, Password: password, ServerAddress: k}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"app/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Secret stores supported by SecretsController
+const (
+	SecretSourceSecretsManager = "secretsmanager"
+	SecretSourceSSM            = "ssm"
+	SecretSourceVault          = "vault"
+)
+
+// SecretsController reads secrets from AWS Secrets Manager, SSM Parameter Store and HashiCorp Vault.
+// Values are returned to the caller only, they are never cached or logged.
+type SecretsController struct {
+	secretsManager *secretsmanager.SecretsManager
+	ssm            *ssm.SSM
+
+	vaultAddr  string
+	vaultToken string
+	client     *http.Client
+}
+
+// NewSecretsControllerFromEnv creates a controller using AWS_* credentials and VAULT_ADDR, VAULT_TOKEN env variables.
+func NewSecretsControllerFromEnv() (*SecretsController, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentialsFromCreds(credentials.Value{
+			AccessKeyID:     config.Get().AWS.AccessKeyID,
+			SecretAccessKey: config.Get().AWS.SecretAccessKey,
+		}),
+		Region: aws.String(config.Get().AWS.Region)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretsController{
+		secretsManager: secretsmanager.New(sess),
+		ssm:            ssm.New(sess),
+		vaultAddr:      strings.TrimSuffix(config.Get().Vault.Addr, "/"),
+		vaultToken:     config.Get().Vault.Token,
+		client:         &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// GetSecret returns the value of secret ref from source.
+// If key is not empty the secret must be a JSON object and the value of key is returned.
+// For vault ref is the path after /v1/, e.g. secret/data/myapp, and key is required.
+func (c *SecretsController) GetSecret(ctx context.Context, source, ref, key string) (string, error) {
+	switch source {
+	case SecretSourceSecretsManager:
+		out, err := c.secretsManager.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(ref)})
+		if err != nil {
+			return "", err
+		}
+		return secretKey(aws.StringValue(out.SecretString), key)
+
+	case SecretSourceSSM:
+		out, err := c.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(ref), WithDecryption: aws.Bool(true)})
+		if err != nil {
+			return "", err
+		}
+		return secretKey(aws.StringValue(out.Parameter.Value), key)
+
+	case SecretSourceVault:
+		return c.getVaultSecret(ctx, ref, key)
+
+	default:
+		return "", fmt.Errorf("unknown secret source %s", source)
+	}
+}
+
+// Value of key in JSON object secret, the secret itself if key is empty
+func secretKey(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		// error is not returned as it could contain part of the secret
+		return "", fmt.Errorf("secret is not a JSON object, can not read key %s", key)
+	}
+	return fieldString(fields, key)
+}
+
+func fieldString(fields map[string]interface{}, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret", key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("could not encode key %s of secret", key)
+	}
+	return string(b), nil
+}
+
+// Read key of a KV secret, both KV version 1 (data) and version 2 (data.data) responses are supported
+func (c *SecretsController) getVaultSecret(ctx context.Context, path, key string) (string, error) {
+	if c.vaultAddr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.vaultAddr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", c.vaultToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status code %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not decode vault response for %s", path)
+	}
+
+	fields := body.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		if _, isV2 := fields["metadata"]; isV2 {
+			fields = nested
+		}
+	}
+	return fieldString(fields, key)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"app/config"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// MinIO ignores the role of AssumeRole requests, the SDK requires one of at least 20 characters
+const minioRoleARN = "arn:xxx:xxx:xxx:xxxx"
+
+// Env variables jobs get scoped storage credentials in, with the region and the endpoint of MinIO
+var StorageCredentialsEnvVars = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION", "AWS_ENDPOINT_URL_S3"}
+
+// StorageCredentials are temporary credentials of the storage service
+type StorageCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// StorageCredentialsController issues temporary storage credentials limited by a session policy, with AWS STS
+// AssumeRole of STORAGE_JOB_ROLE_ARN for aws-s3 and the STS API of MinIO for the user of the server for minio.
+type StorageCredentialsController struct {
+	sts     *sts.STS
+	roleARN string
+	ttl     time.Duration
+}
+
+// NewStorageCredentialsControllerFromConfig creates a controller using the credentials of the storage service of the server
+func NewStorageCredentialsControllerFromConfig() (*StorageCredentialsController, error) {
+	cfg := config.Get()
+	var awsCfg *aws.Config
+	roleARN := cfg.Storage.JobRoleARN
+
+	switch cfg.Storage.Service {
+	case "minio":
+		awsCfg = &aws.Config{
+			Endpoint:    aws.String(cfg.MinIO.Endpoint),
+			Region:      aws.String(cfg.MinIO.Region),
+			Credentials: credentials.NewStaticCredentials(cfg.MinIO.AccessKeyID, cfg.MinIO.SecretAccessKey, ""),
+		}
+		roleARN = minioRoleARN
+	case "aws-s3":
+		if roleARN == "" {
+			return nil, errors.New("STORAGE_JOB_ROLE_ARN env variable is not set")
+		}
+		awsCfg = &aws.Config{
+			Region:      aws.String(cfg.AWS.Region),
+			Credentials: credentials.NewStaticCredentials(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, ""),
+		}
+	default:
+		return nil, errors.New("unsupported storage provider type")
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &StorageCredentialsController{sts: sts.New(sess), roleARN: roleARN, ttl: cfg.Storage.JobCredentialsTTL}, nil
+}
+
+// Issue returns credentials named sessionName that only allow what both the role and policy, an IAM policy document, allow.
+// They expire after STORAGE_JOB_CREDENTIALS_TTL.
+func (c *StorageCredentialsController) Issue(ctx context.Context, sessionName, policy string) (StorageCredentials, error) {
+	out, err := c.sts.AssumeRoleWithContext(ctx, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(c.roleARN),
+		RoleSessionName: aws.String(sessionName),
+		Policy:          aws.String(policy),
+		DurationSeconds: aws.Int64(int64(c.ttl / time.Second)),
+	})
+	if err != nil {
+		return StorageCredentials{}, err
+	}
+	if out.Credentials == nil {
+		return StorageCredentials{}, errors.New("no credentials in AssumeRole response")
+	}
+	return StorageCredentials{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+		Expiration:      aws.TimeValue(out.Credentials.Expiration),
+	}, nil
+}
@@ -0,0 +1,247 @@
+package events
+
+import (
+	"app/config"
+	"app/jobs"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Version of the AsyncAPI specification of the event document
+// specs: https://www.asyncapi.com/docs/reference/specification/v2.6.0
+const asyncAPIVersion = "2.6.0"
+
+// Go types of event payloads, schemas are generated from them so that the document can not drift from what is sent
+var eventSchemaTypes = map[string]reflect.Type{
+	"JobEvent":    reflect.TypeOf(jobs.JobEvent{}),
+	"CloudEvent":  reflect.TypeOf(CloudEvent{}),
+	"JobProgress": reflect.TypeOf(jobs.JobProgress{}),
+}
+
+// AsyncAPIDocument returns an AsyncAPI document describing the events this server emits: webhook deliveries,
+// CloudEvents published to the configured broker and the Server-Sent Events of the job events route.
+// apiURL is the base URL of the API, title and version those of the server.
+func AsyncAPIDocument(cfg *config.Config, title, version, apiURL string) map[string]interface{} {
+	c := cfg.Events
+	refs := make(map[reflect.Type]string, len(eventSchemaTypes))
+	for name, t := range eventSchemaTypes {
+		refs[t] = "#/components/schemas/" + name
+	}
+	schemas := make(map[string]map[string]interface{}, len(eventSchemaTypes))
+	for name, t := range eventSchemaTypes {
+		schemas[name] = jsonSchema(t, refs, true)
+	}
+
+	// values the types do not tell
+	source := c.Source
+	if source == "" {
+		source = "/sepex/" + cfg.API.Name
+	}
+	property(schemas["JobEvent"], "type")["enum"] = []string{jobs.EventJobStatusUpdated}
+	property(schemas["JobEvent"], "status")["enum"] = []string{jobs.ACCEPTED, jobs.RUNNING, jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED}
+	property(schemas["JobProgress"], "type")["enum"] = []string{jobs.EventJobProgressUpdated}
+	property(schemas["JobProgress"], "progress")["minimum"] = 0
+	property(schemas["JobProgress"], "progress")["maximum"] = 100
+	property(schemas["CloudEvent"], "specversion")["enum"] = []string{cloudEventsSpecVersion}
+	property(schemas["CloudEvent"], "type")["enum"] = []string{cloudEventsTypePrefix + jobs.EventJobStatusUpdated}
+	property(schemas["CloudEvent"], "source")["examples"] = []string{source}
+
+	servers := map[string]interface{}{
+		"api": map[string]interface{}{
+			"url":         apiURL,
+			"protocol":    "http",
+			"description": "this server, job events are streamed as Server-Sent Events",
+		},
+		"webhooks": map[string]interface{}{
+			"url":         "{receiver}",
+			"protocol":    "http",
+			"description": "webhook receivers configured with WEBHOOK_URLS, events are POSTed to every receiver",
+			"variables": map[string]interface{}{
+				"receiver": map[string]interface{}{"description": "URL of a webhook receiver"},
+			},
+		},
+	}
+	channels := map[string]interface{}{
+		"/jobs/{jobID}/events": map[string]interface{}{
+			"description": "status changes and progress updates of one job, the stream ends with an end event when the job finished",
+			"servers":     []string{"api"},
+			"parameters": map[string]interface{}{
+				"jobID": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+			"subscribe": map[string]interface{}{
+				"operationId": "streamJobEvents",
+				"bindings":    map[string]interface{}{"http": map[string]interface{}{"type": "request", "method": "GET"}},
+				"message": map[string]interface{}{"oneOf": []interface{}{
+					map[string]interface{}{"$ref": "#/components/messages/status"},
+					map[string]interface{}{"$ref": "#/components/messages/progress"},
+					map[string]interface{}{"$ref": "#/components/messages/end"},
+				}},
+			},
+		},
+		"webhook": map[string]interface{}{
+			"description": "job events POSTed to webhook receivers, filtered by WEBHOOK_PROCESSES and WEBHOOK_STATUSES",
+			"servers":     []string{"webhooks"},
+			"subscribe": map[string]interface{}{
+				"operationId": "receiveWebhook",
+				"bindings":    map[string]interface{}{"http": map[string]interface{}{"type": "request", "method": "POST"}},
+				"message":     map[string]interface{}{"$ref": "#/components/messages/webhook"},
+			},
+		},
+	}
+	if c.Broker != "" {
+		servers["broker"] = brokerServer(c)
+		channels[brokerChannel(c)] = map[string]interface{}{
+			"description": "job events published as CloudEvents in structured JSON mode",
+			"servers":     []string{"broker"},
+			"subscribe": map[string]interface{}{
+				"operationId": "receiveCloudEvent",
+				"message":     map[string]interface{}{"$ref": "#/components/messages/cloudEvent"},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"asyncapi": asyncAPIVersion,
+		"info": map[string]interface{}{
+			"title":       title + " events",
+			"version":     version,
+			"description": "Job lifecycle events emitted by SEPEX. Payload schemas are generated from the types the server sends.",
+		},
+		"defaultContentType": "application/json",
+		"servers":            servers,
+		"channels":           channels,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"messages": map[string]interface{}{
+				"webhook": map[string]interface{}{
+					"name":    jobs.EventJobStatusUpdated,
+					"summary": "status change of a job",
+					"headers": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"X-SEPEX-Signature": map[string]interface{}{
+								"type":        "string",
+								"description": "sha256= followed by the hex encoded HMAC-SHA256 of the raw body with WEBHOOK_SECRET, only sent if a secret is configured",
+							},
+						},
+					},
+					"payload": map[string]interface{}{"$ref": "#/components/schemas/JobEvent"},
+				},
+				"cloudEvent": map[string]interface{}{
+					"name":        cloudEventsTypePrefix + jobs.EventJobStatusUpdated,
+					"summary":     "status change of a job",
+					"contentType": "application/cloudevents+json",
+					"payload":     map[string]interface{}{"$ref": "#/components/schemas/CloudEvent"},
+				},
+				"status": map[string]interface{}{
+					"name":    "status",
+					"summary": "SSE event status, the current status when the stream starts and every status change",
+					"payload": map[string]interface{}{"$ref": "#/components/schemas/JobEvent"},
+				},
+				"progress": map[string]interface{}{
+					"name":    "progress",
+					"summary": "SSE event progress, progress reported by the process",
+					"payload": map[string]interface{}{"$ref": "#/components/schemas/JobProgress"},
+				},
+				"end": map[string]interface{}{
+					"name":        "end",
+					"summary":     "SSE event end, the final status of the job, last event of the stream",
+					"contentType": "text/plain",
+					"payload":     map[string]interface{}{"type": "string", "enum": []string{jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED}},
+				},
+			},
+		},
+	}
+}
+
+// Server of the configured broker, credentials of the broker URL are not published
+func brokerServer(c config.Events) map[string]interface{} {
+	description := c.Broker + " broker"
+	if c.Broker == "kafka" && (strings.HasPrefix(c.URL, "http://") || strings.HasPrefix(c.URL, "https://")) {
+		// the URL is the one of the REST proxy events are published with, consumers read the topic with Kafka clients
+		description = "REST proxy of the kafka cluster"
+	}
+	serverURL := c.URL
+	if u, err := url.Parse(c.URL); err == nil {
+		u.User = nil
+		serverURL = u.String()
+	}
+	return map[string]interface{}{"url": serverURL, "protocol": c.Broker, "description": description}
+}
+
+// Channel of the configured broker: the topic or subject, the queue name for SQS
+func brokerChannel(c config.Events) string {
+	if c.Broker == "sqs" {
+		if u, err := url.Parse(c.URL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			return path.Base(u.Path)
+		}
+	}
+	if c.Topic != "" {
+		return c.Topic
+	}
+	return "events"
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema of property name of an object schema
+func property(schema map[string]interface{}, name string) map[string]interface{} {
+	return schema["properties"].(map[string]interface{})[name].(map[string]interface{})
+}
+
+// jsonSchema returns the JSON schema of values of t as encoding/json marshals them.
+// Struct types in refs are referenced instead of inlined unless top is true.
+func jsonSchema(t reflect.Type, refs map[reflect.Type]string, top bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if ref, ok := refs[t]; ok && !top {
+		return map[string]interface{}{"$ref": ref}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), refs, false)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), refs, false)}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = jsonSchema(f.Type, refs, false)
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+	default:
+		// interface values can be anything
+		return map[string]interface{}{}
+	}
+}
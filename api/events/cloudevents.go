@@ -0,0 +1,137 @@
+package events
+
+import (
+	"app/config"
+	"app/jobs"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsTypePrefix  = "io.github.sepex."
+	cloudEventsQueueSize   = 1000
+	cloudEventsMaxAttempts = 3
+)
+
+// CloudEvent is a CloudEvents v1.0 event in structured JSON mode
+// specs: https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/spec.md
+type CloudEvent struct {
+	SpecVersion     string        `json:"specversion"`
+	ID              string        `json:"id"`
+	Source          string        `json:"source"`
+	Type            string        `json:"type"`
+	Subject         string        `json:"subject,omitempty"`
+	Time            time.Time     `json:"time"`
+	DataContentType string        `json:"datacontenttype"`
+	Data            jobs.JobEvent `json:"data"`
+}
+
+// NewCloudEvent wraps a job event into a CloudEvent
+func NewCloudEvent(source string, e jobs.JobEvent) CloudEvent {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          source,
+		Type:            cloudEventsTypePrefix + e.Type,
+		Subject:         e.JobID,
+		Time:            t.UTC(),
+		DataContentType: "application/json",
+		Data:            e,
+	}
+}
+
+// broker publishes a single message body
+type broker interface {
+	publish(body []byte) error
+	name() string
+}
+
+// CloudEventsEmitter publishes job events as CloudEvents to a message broker.
+// Events are queued and published by a single routine so that publishing never blocks job status updates.
+type CloudEventsEmitter struct {
+	Source string
+
+	broker broker
+	queue  chan jobs.JobEvent
+}
+
+// NewCloudEventsEmitter creates an emitter from the events configuration, the source defaults to /sepex/<apiName>.
+// Returns nil, nil if no broker is configured.
+func NewCloudEventsEmitter(c config.Events, apiName string) (*CloudEventsEmitter, error) {
+	if c.Broker == "" {
+		return nil, nil
+	}
+
+	var b broker
+	var err error
+	switch c.Broker {
+	case "sqs":
+		b, err = newSQSBroker(c.URL)
+	case "nats":
+		b, err = newNATSBroker(c.URL, c.Topic)
+	case "kafka":
+		// Kafka REST Proxy URLs are http(s), brokers are reached with the Kafka protocol otherwise
+		if strings.HasPrefix(c.URL, "http://") || strings.HasPrefix(c.URL, "https://") {
+			b, err = newKafkaRESTBroker(c.URL, c.Topic)
+		} else {
+			b, err = newKafkaBroker(c.URL, c.Topic)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported event broker type: %s", c.Broker)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source := c.Source
+	if source == "" {
+		source = "/sepex/" + apiName
+	}
+
+	return &CloudEventsEmitter{
+		Source: source,
+		broker: b,
+		queue:  make(chan jobs.JobEvent, cloudEventsQueueSize),
+	}, nil
+}
+
+// Handle is an EventSubscriber, it queues events for publishing.
+func (ce *CloudEventsEmitter) Handle(e jobs.JobEvent) {
+	select {
+	case ce.queue <- e:
+	default:
+		log.Errorf("cloud events queue full, dropping event for job %s", e.JobID)
+	}
+}
+
+// Start publishes queued events, it blocks and should be run in a routine.
+func (ce *CloudEventsEmitter) Start() {
+	log.Infof("CloudEvents emitter started for %s broker", ce.broker.name())
+	for e := range ce.queue {
+		body, err := json.Marshal(NewCloudEvent(ce.Source, e))
+		if err != nil {
+			log.Errorf("could not marshal cloud event for job %s: %s", e.JobID, err.Error())
+			continue
+		}
+
+		for attempt := 1; attempt <= cloudEventsMaxAttempts; attempt++ {
+			if err = ce.broker.publish(body); err == nil {
+				break
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err != nil {
+			log.Errorf("could not publish cloud event for job %s to %s: %s", e.JobID, ce.broker.name(), err.Error())
+		}
+	}
+}
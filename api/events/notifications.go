@@ -0,0 +1,242 @@
+package events
+
+import (
+	"app/config"
+	"app/jobs"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	notifierQueueSize = 1000
+	slackPostURL      = "https://slack.com/api/chat.postMessage"
+)
+
+// Recipients of a job completion notification, empty fields are skipped
+type Recipients struct {
+	SlackChannel string
+	Email        string // comma separated list of addresses
+}
+
+func (r Recipients) IsZero() bool {
+	return r.SlackChannel == "" && r.Email == ""
+}
+
+// Notification sent when a job reaches a terminal state
+type notification struct {
+	event      jobs.JobEvent
+	recipients Recipients
+	duration   time.Duration
+}
+
+type registration struct {
+	recipients Recipients
+	submitted  time.Time
+}
+
+// Notifier sends Slack and email messages when jobs registered with it reach a terminal state.
+// Registrations are kept in memory, notifications for jobs submitted before a server restart are not sent.
+type Notifier struct {
+	// Slack, either a bot token (chat.postMessage) or an incoming webhook URL
+	SlackToken      string
+	SlackWebhookURL string
+
+	// SMTP
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Base URL used to build links in messages
+	PublicURL string
+
+	mu     sync.Mutex
+	jobs   map[string]registration
+	queue  chan notification
+	client *http.Client
+}
+
+// NewNotifier creates a notifier from the notifications configuration, links in messages use publicURL.
+// Returns nil if neither Slack nor SMTP is configured.
+func NewNotifier(c config.Notifications, publicURL string) *Notifier {
+	n := &Notifier{
+		SlackToken:      c.SlackBotToken,
+		SlackWebhookURL: c.SlackWebhookURL,
+		SMTPHost:        c.SMTPHost,
+		SMTPPort:        c.SMTPPort,
+		SMTPUsername:    c.SMTPUsername,
+		SMTPPassword:    c.SMTPPassword,
+		SMTPFrom:        c.SMTPFrom,
+		PublicURL:       strings.TrimSuffix(publicURL, "/"),
+		jobs:            make(map[string]registration),
+		queue:           make(chan notification, notifierQueueSize),
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if !n.slackEnabled() && !n.emailEnabled() {
+		return nil
+	}
+	return n
+}
+
+func (n *Notifier) slackEnabled() bool {
+	return n.SlackToken != "" || n.SlackWebhookURL != ""
+}
+
+func (n *Notifier) emailEnabled() bool {
+	return n.SMTPHost != "" && n.SMTPFrom != ""
+}
+
+// Validate returns an error if recipients can not be notified with the current configuration
+func (n *Notifier) Validate(r Recipients) error {
+	if r.SlackChannel != "" && !n.slackEnabled() {
+		return errors.New("slack notifications are not configured on this server")
+	}
+	if r.Email != "" {
+		if !n.emailEnabled() {
+			return errors.New("email notifications are not configured on this server")
+		}
+		for _, addr := range splitList(r.Email) {
+			if !strings.Contains(addr, "@") {
+				return fmt.Errorf("invalid email address: %s", addr)
+			}
+		}
+	}
+	return nil
+}
+
+// Register recipients to be notified when the job reaches a terminal state
+func (n *Notifier) Register(jobID string, r Recipients, submitted time.Time) {
+	if r.IsZero() {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.jobs[jobID] = registration{recipients: r, submitted: submitted}
+}
+
+// Handle is an EventSubscriber, it queues notifications for registered jobs reaching a terminal state.
+func (n *Notifier) Handle(e jobs.JobEvent) {
+	switch e.Status {
+	case jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+	default:
+		return
+	}
+
+	n.mu.Lock()
+	reg, ok := n.jobs[e.JobID]
+	delete(n.jobs, e.JobID)
+	n.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case n.queue <- notification{event: e, recipients: reg.recipients, duration: e.Time.Sub(reg.submitted)}:
+	default:
+		log.Errorf("notification queue full, dropping notification for job %s", e.JobID)
+	}
+}
+
+// Start sends queued notifications, it blocks and should be run in a routine.
+func (n *Notifier) Start() {
+	log.Info("Notifier started")
+	for nt := range n.queue {
+		subject, body := n.message(nt)
+		if nt.recipients.SlackChannel != "" {
+			if err := n.sendSlack(nt.recipients.SlackChannel, body); err != nil {
+				log.Errorf("could not send slack notification for job %s: %s", nt.event.JobID, err.Error())
+			}
+		}
+		if nt.recipients.Email != "" {
+			if err := n.sendEmail(splitList(nt.recipients.Email), subject, body); err != nil {
+				log.Errorf("could not send email notification for job %s: %s", nt.event.JobID, err.Error())
+			}
+		}
+	}
+}
+
+// Build subject and plain text body of a notification
+func (n *Notifier) message(nt notification) (string, string) {
+	e := nt.event
+	subject := fmt.Sprintf("Job %s %s", e.ProcessID, e.Status)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job %s of process %s finished with status %s.\n", e.JobID, e.ProcessID, e.Status)
+	fmt.Fprintf(&b, "Duration: %s\n", nt.duration.Round(time.Second))
+	if e.Status == jobs.SUCCESSFUL {
+		fmt.Fprintf(&b, "Results: %s/jobs/%s/results\n", n.PublicURL, e.JobID)
+	}
+	fmt.Fprintf(&b, "Logs: %s/jobs/%s/logs\n", n.PublicURL, e.JobID)
+	return subject, b.String()
+}
+
+func (n *Notifier) sendSlack(channel, text string) error {
+	payload, err := json.Marshal(map[string]string{"channel": channel, "text": text})
+	if err != nil {
+		return err
+	}
+
+	url := n.SlackWebhookURL
+	if n.SlackToken != "" {
+		url = slackPostURL
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if n.SlackToken != "" {
+		req.Header.Set("Authorization", "Bearer "+n.SlackToken)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// chat.postMessage reports errors in body with status 200
+	if n.SlackToken != "" {
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return err
+		}
+		if !result.OK {
+			return errors.New(result.Error)
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) sendEmail(to []string, subject, body string) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if n.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", n.SMTPUsername, n.SMTPPassword, n.SMTPHost)
+	}
+	return smtp.SendMail(n.SMTPHost+":"+n.SMTPPort, auth, n.SMTPFrom, to, []byte(msg.String()))
+}
@@ -0,0 +1,201 @@
+// Package events delivers job lifecycle events published on the jobs EventBus
+// to external systems such as operator webhooks.
+package events
+
+import (
+	"app/config"
+	"app/jobs"
+	"app/utils"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	webhookQueueSize   = 1000
+	webhookMaxAttempts = 3
+	webhookTimeout     = 10 * time.Second
+)
+
+// WebhookDispatcher POSTs job events to the configured URLs.
+// Events are queued per URL and delivered by a routine per URL so that publishing never blocks job status updates
+// and an unreachable URL does not delay deliveries to the others. Deliveries failing after all attempts are appended
+// to a dead-letter file.
+type WebhookDispatcher struct {
+	URLs           []string
+	Secret         string
+	Processes      []string // empty means all processes
+	Statuses       []string // empty means all statuses
+	DeadLetterFile string
+
+	queues map[string]chan jobs.JobEvent // by URL
+	client *http.Client
+	mu     sync.Mutex // guards dead-letter file writes
+}
+
+// deadLetter is a single failed delivery
+type deadLetter struct {
+	URL   string        `json:"url"`
+	Error string        `json:"error"`
+	Time  time.Time     `json:"time"`
+	Event jobs.JobEvent `json:"event"`
+}
+
+// NewWebhookDispatcher creates a dispatcher from the webhooks configuration.
+// Returns nil if no webhook URLs are configured.
+func NewWebhookDispatcher(c config.Webhooks) *WebhookDispatcher {
+	if len(c.URLs) == 0 {
+		return nil
+	}
+
+	queues := make(map[string]chan jobs.JobEvent, len(c.URLs))
+	for _, url := range c.URLs {
+		queues[url] = make(chan jobs.JobEvent, webhookQueueSize)
+	}
+	return &WebhookDispatcher{
+		URLs:           c.URLs,
+		Secret:         c.Secret,
+		Processes:      c.Processes,
+		Statuses:       c.Statuses,
+		DeadLetterFile: c.DeadLetterFile,
+		queues:         queues,
+		client:         &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Handle is an EventSubscriber, it queues matching events for delivery.
+func (wd *WebhookDispatcher) Handle(e jobs.JobEvent) {
+	if len(wd.Processes) > 0 && !utils.StringInSlice(e.ProcessID, wd.Processes) {
+		return
+	}
+	if len(wd.Statuses) > 0 && !utils.StringInSlice(e.Status, wd.Statuses) {
+		return
+	}
+
+	for url, queue := range wd.queues {
+		select {
+		case queue <- e:
+		default:
+			wd.writeDeadLetter(url, e, fmt.Errorf("webhook queue full"))
+		}
+	}
+}
+
+// Start delivers queued events, it blocks and should be run in a routine.
+func (wd *WebhookDispatcher) Start() {
+	log.Infof("Webhook dispatcher started for %d url(s)", len(wd.URLs))
+	var wg sync.WaitGroup
+	for url, queue := range wd.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wd.run(url, queue)
+		}()
+	}
+	wg.Wait()
+}
+
+// Deliver the events queued for url one after the other
+func (wd *WebhookDispatcher) run(url string, queue chan jobs.JobEvent) {
+	for e := range queue {
+		body, err := json.Marshal(e)
+		if err != nil {
+			log.Errorf("could not marshal webhook event for job %s: %s", e.JobID, err.Error())
+			continue
+		}
+		if err := wd.deliver(url, body); err != nil {
+			log.Warnf("webhook delivery to %s failed for job %s: %s", url, e.JobID, err.Error())
+			wd.writeDeadLetter(url, e, err)
+		}
+	}
+}
+
+// deliver POSTs body to url with retries and exponential backoff
+func (wd *WebhookDispatcher) deliver(url string, body []byte) (err error) {
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(1<<(attempt-2)) * time.Second)
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err // bad url, retrying won't help
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if wd.Secret != "" {
+			req.Header.Set("X-SEPEX-Signature", "sha256="+Sign(wd.Secret, body))
+		}
+
+		var resp *http.Response
+		resp, err = wd.client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return err
+}
+
+// Sign returns hex encoded HMAC-SHA256 of body using secret.
+// Receivers should compute the same over the raw request body and compare with the X-SEPEX-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Append a failed delivery to the dead-letter file
+func (wd *WebhookDispatcher) writeDeadLetter(url string, e jobs.JobEvent, deliveryErr error) {
+	if wd.DeadLetterFile == "" {
+		return
+	}
+
+	b, err := json.Marshal(deadLetter{URL: url, Error: deliveryErr.Error(), Time: time.Now(), Event: e})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(wd.DeadLetterFile), 0755); err != nil {
+		log.Errorf("could not create webhook dead-letter directory: %s", err.Error())
+		return
+	}
+	f, err := os.OpenFile(wd.DeadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf("could not open webhook dead-letter file: %s", err.Error())
+		return
+	}
+	defer f.Close()
+	f.Write(append(b, '\n'))
+}
+
+// Split a comma separated list, ignoring empty items
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
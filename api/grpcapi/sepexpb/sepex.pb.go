@@ -0,0 +1,495 @@
+// gRPC service for machine-to-machine callers of SEPEX, calls are served by the same jobs layer and middlewares as the REST API.
+// Generate the Go code from the api directory with protoc-gen-go and protoc-gen-go-grpc:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grpcapi/sepexpb/sepex.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: grpcapi/sepexpb/sepex.proto
+
+package sepexpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProcessId string                 `protobuf:"bytes,1,opt,name=process_id,json=processId,proto3" json:"process_id,omitempty"`
+	// Inputs of the execute request
+	Inputs *structpb.Struct `protobuf:"bytes,2,opt,name=inputs,proto3" json:"inputs,omitempty"`
+	// Ask for sync execution, which waits for the job and returns its outputs
+	Sync bool `protobuf:"varint,3,opt,name=sync,proto3" json:"sync,omitempty"`
+	// Overrides of env variables the process allows to override
+	Env           map[string]string `protobuf:"bytes,4,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitRequest) Reset() {
+	*x = SubmitRequest{}
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitRequest) ProtoMessage() {}
+
+func (x *SubmitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitRequest.ProtoReflect.Descriptor instead.
+func (*SubmitRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_sepexpb_sepex_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitRequest) GetProcessId() string {
+	if x != nil {
+		return x.ProcessId
+	}
+	return ""
+}
+
+func (x *SubmitRequest) GetInputs() *structpb.Struct {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *SubmitRequest) GetSync() bool {
+	if x != nil {
+		return x.Sync
+	}
+	return false
+}
+
+func (x *SubmitRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+type SubmitResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	JobId     string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	ProcessId string                 `protobuf:"bytes,2,opt,name=process_id,json=processId,proto3" json:"process_id,omitempty"`
+	Status    string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Message   string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	// Outputs of successful sync jobs
+	Outputs       *structpb.Value `protobuf:"bytes,5,opt,name=outputs,proto3" json:"outputs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitResponse) Reset() {
+	*x = SubmitResponse{}
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitResponse) ProtoMessage() {}
+
+func (x *SubmitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitResponse.ProtoReflect.Descriptor instead.
+func (*SubmitResponse) Descriptor() ([]byte, []int) {
+	return file_grpcapi_sepexpb_sepex_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetProcessId() string {
+	if x != nil {
+		return x.ProcessId
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetOutputs() *structpb.Value {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_sepexpb_sepex_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type JobStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	ProcessId     string                 `protobuf:"bytes,2,opt,name=process_id,json=processId,proto3" json:"process_id,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Updated       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=updated,proto3" json:"updated,omitempty"`
+	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobStatus) Reset() {
+	*x = JobStatus{}
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobStatus) ProtoMessage() {}
+
+func (x *JobStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobStatus.ProtoReflect.Descriptor instead.
+func (*JobStatus) Descriptor() ([]byte, []int) {
+	return file_grpcapi_sepexpb_sepex_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *JobStatus) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *JobStatus) GetProcessId() string {
+	if x != nil {
+		return x.ProcessId
+	}
+	return ""
+}
+
+func (x *JobStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobStatus) GetUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *JobStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type LogMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*LogMessage_Line
+	//	*LogMessage_FinalStatus
+	Message       isLogMessage_Message `protobuf_oneof:"message"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogMessage) Reset() {
+	*x = LogMessage{}
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogMessage) ProtoMessage() {}
+
+func (x *LogMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_sepexpb_sepex_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogMessage.ProtoReflect.Descriptor instead.
+func (*LogMessage) Descriptor() ([]byte, []int) {
+	return file_grpcapi_sepexpb_sepex_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LogMessage) GetMessage() isLogMessage_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *LogMessage) GetLine() string {
+	if x != nil {
+		if x, ok := x.Message.(*LogMessage_Line); ok {
+			return x.Line
+		}
+	}
+	return ""
+}
+
+func (x *LogMessage) GetFinalStatus() string {
+	if x != nil {
+		if x, ok := x.Message.(*LogMessage_FinalStatus); ok {
+			return x.FinalStatus
+		}
+	}
+	return ""
+}
+
+type isLogMessage_Message interface {
+	isLogMessage_Message()
+}
+
+type LogMessage_Line struct {
+	Line string `protobuf:"bytes,1,opt,name=line,proto3,oneof"`
+}
+
+type LogMessage_FinalStatus struct {
+	// Status of the job when it finished
+	FinalStatus string `protobuf:"bytes,2,opt,name=final_status,json=finalStatus,proto3,oneof"`
+}
+
+func (*LogMessage_Line) isLogMessage_Message() {}
+
+func (*LogMessage_FinalStatus) isLogMessage_Message() {}
+
+var File_grpcapi_sepexpb_sepex_proto protoreflect.FileDescriptor
+
+const file_grpcapi_sepexpb_sepex_proto_rawDesc = "" +
+	"\n" +
+	"\x1bgrpcapi/sepexpb/sepex.proto\x12\bsepex.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xdf\x01\n" +
+	"\rSubmitRequest\x12\x1d\n" +
+	"\n" +
+	"process_id\x18\x01 \x01(\tR\tprocessId\x12/\n" +
+	"\x06inputs\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06inputs\x12\x12\n" +
+	"\x04sync\x18\x03 \x01(\bR\x04sync\x122\n" +
+	"\x03env\x18\x04 \x03(\v2 .sepex.v1.SubmitRequest.EnvEntryR\x03env\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xaa\x01\n" +
+	"\x0eSubmitResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1d\n" +
+	"\n" +
+	"process_id\x18\x02 \x01(\tR\tprocessId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x120\n" +
+	"\aoutputs\x18\x05 \x01(\v2\x16.google.protobuf.ValueR\aoutputs\"#\n" +
+	"\n" +
+	"JobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\xa9\x01\n" +
+	"\tJobStatus\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1d\n" +
+	"\n" +
+	"process_id\x18\x02 \x01(\tR\tprocessId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x124\n" +
+	"\aupdated\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aupdated\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"R\n" +
+	"\n" +
+	"LogMessage\x12\x14\n" +
+	"\x04line\x18\x01 \x01(\tH\x00R\x04line\x12#\n" +
+	"\ffinal_status\x18\x02 \x01(\tH\x00R\vfinalStatusB\t\n" +
+	"\amessage2\xea\x01\n" +
+	"\x04Jobs\x12;\n" +
+	"\x06Submit\x12\x17.sepex.v1.SubmitRequest\x1a\x18.sepex.v1.SubmitResponse\x123\n" +
+	"\x06Status\x12\x14.sepex.v1.JobRequest\x1a\x13.sepex.v1.JobStatus\x124\n" +
+	"\aDismiss\x12\x14.sepex.v1.JobRequest\x1a\x13.sepex.v1.JobStatus\x12:\n" +
+	"\n" +
+	"StreamLogs\x12\x14.sepex.v1.JobRequest\x1a\x14.sepex.v1.LogMessage0\x01B\x15Z\x13app/grpcapi/sepexpbb\x06proto3"
+
+var (
+	file_grpcapi_sepexpb_sepex_proto_rawDescOnce sync.Once
+	file_grpcapi_sepexpb_sepex_proto_rawDescData []byte
+)
+
+func file_grpcapi_sepexpb_sepex_proto_rawDescGZIP() []byte {
+	file_grpcapi_sepexpb_sepex_proto_rawDescOnce.Do(func() {
+		file_grpcapi_sepexpb_sepex_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grpcapi_sepexpb_sepex_proto_rawDesc), len(file_grpcapi_sepexpb_sepex_proto_rawDesc)))
+	})
+	return file_grpcapi_sepexpb_sepex_proto_rawDescData
+}
+
+var file_grpcapi_sepexpb_sepex_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_grpcapi_sepexpb_sepex_proto_goTypes = []any{
+	(*SubmitRequest)(nil),         // 0: sepex.v1.SubmitRequest
+	(*SubmitResponse)(nil),        // 1: sepex.v1.SubmitResponse
+	(*JobRequest)(nil),            // 2: sepex.v1.JobRequest
+	(*JobStatus)(nil),             // 3: sepex.v1.JobStatus
+	(*LogMessage)(nil),            // 4: sepex.v1.LogMessage
+	nil,                           // 5: sepex.v1.SubmitRequest.EnvEntry
+	(*structpb.Struct)(nil),       // 6: google.protobuf.Struct
+	(*structpb.Value)(nil),        // 7: google.protobuf.Value
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_grpcapi_sepexpb_sepex_proto_depIdxs = []int32{
+	6, // 0: sepex.v1.SubmitRequest.inputs:type_name -> google.protobuf.Struct
+	5, // 1: sepex.v1.SubmitRequest.env:type_name -> sepex.v1.SubmitRequest.EnvEntry
+	7, // 2: sepex.v1.SubmitResponse.outputs:type_name -> google.protobuf.Value
+	8, // 3: sepex.v1.JobStatus.updated:type_name -> google.protobuf.Timestamp
+	0, // 4: sepex.v1.Jobs.Submit:input_type -> sepex.v1.SubmitRequest
+	2, // 5: sepex.v1.Jobs.Status:input_type -> sepex.v1.JobRequest
+	2, // 6: sepex.v1.Jobs.Dismiss:input_type -> sepex.v1.JobRequest
+	2, // 7: sepex.v1.Jobs.StreamLogs:input_type -> sepex.v1.JobRequest
+	1, // 8: sepex.v1.Jobs.Submit:output_type -> sepex.v1.SubmitResponse
+	3, // 9: sepex.v1.Jobs.Status:output_type -> sepex.v1.JobStatus
+	3, // 10: sepex.v1.Jobs.Dismiss:output_type -> sepex.v1.JobStatus
+	4, // 11: sepex.v1.Jobs.StreamLogs:output_type -> sepex.v1.LogMessage
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_grpcapi_sepexpb_sepex_proto_init() }
+func file_grpcapi_sepexpb_sepex_proto_init() {
+	if File_grpcapi_sepexpb_sepex_proto != nil {
+		return
+	}
+	file_grpcapi_sepexpb_sepex_proto_msgTypes[4].OneofWrappers = []any{
+		(*LogMessage_Line)(nil),
+		(*LogMessage_FinalStatus)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grpcapi_sepexpb_sepex_proto_rawDesc), len(file_grpcapi_sepexpb_sepex_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpcapi_sepexpb_sepex_proto_goTypes,
+		DependencyIndexes: file_grpcapi_sepexpb_sepex_proto_depIdxs,
+		MessageInfos:      file_grpcapi_sepexpb_sepex_proto_msgTypes,
+	}.Build()
+	File_grpcapi_sepexpb_sepex_proto = out.File
+	file_grpcapi_sepexpb_sepex_proto_goTypes = nil
+	file_grpcapi_sepexpb_sepex_proto_depIdxs = nil
+}
@@ -0,0 +1,342 @@
+// Package grpcapi serves the gRPC Jobs service, see sepexpb/sepex.proto.
+// Calls are served by the jobs operations of the REST handler, behind the auth, rate limit, audit and job ownership
+// middlewares of the equivalent REST routes, so that they apply to gRPC callers exactly like to REST callers.
+package grpcapi
+
+import (
+	"app/grpcapi/sepexpb"
+	"app/handlers"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metadata keys forwarded as headers of the requests of calls
+var forwardedMetadata = []string{"authorization", "x-sepex-user-email"}
+
+// Auth are the auth middlewares of the REST API, Public those of all routes and Protected those of the protected routes
+type Auth struct {
+	Public    []echo.MiddlewareFunc
+	Protected []echo.MiddlewareFunc
+}
+
+// Server implements the Jobs service on top of the REST handler
+type Server struct {
+	sepexpb.UnimplementedJobsServer
+	rh   *handlers.RESTHandler
+	e    *echo.Echo
+	auth Auth
+}
+
+// NewServer returns a gRPC server of the Jobs service serving calls with rh, e is the echo server of the REST API and
+// auth its auth middlewares. With tlsConfig the server uses TLS, client certificates of callers are passed on to client-cert auth.
+func NewServer(rh *handlers.RESTHandler, e *echo.Echo, auth Auth, tlsConfig *tls.Config) *grpc.Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	gs := grpc.NewServer(opts...)
+	sepexpb.RegisterJobsServer(gs, &Server{rh: rh, e: e, auth: auth})
+	return gs
+}
+
+// Middlewares of a call, the auth middlewares followed by those of its REST route
+func (s *Server) middlewares(auth []echo.MiddlewareFunc, route ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+	return append(append([]echo.MiddlewareFunc{}, auth...), route...)
+}
+
+func (s *Server) Submit(ctx context.Context, req *sepexpb.SubmitRequest) (*sepexpb.SubmitResponse, error) {
+	if req.GetProcessId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "process_id is required")
+	}
+	inputs := map[string]interface{}{}
+	if req.GetInputs() != nil {
+		inputs = req.GetInputs().AsMap()
+	}
+	header := http.Header{}
+	if !req.GetSync() {
+		header.Set("Prefer", "respond-async")
+	}
+
+	var info handlers.JobInfo
+	// calls have no request body for ExecuteLimits, SubmitJob applies its limits to the inputs and env instead
+	mws := s.middlewares(s.auth.Protected, s.rh.RateLimit(s.rh.ExecuteLimiter), s.rh.Audit(handlers.AuditJobSubmit))
+	err := s.call(ctx, http.MethodPost, "/processes/:processID/execution", []string{"processID"}, []string{req.GetProcessId()}, header, mws,
+		func(c echo.Context) (err error) {
+			info, err = s.rh.SubmitJob(c, req.GetProcessId(), inputs, req.GetEnv())
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &sepexpb.SubmitResponse{JobId: info.JobID, ProcessId: info.ProcessID, Status: info.Status, Message: info.Message}
+	if info.Outputs != nil {
+		if resp.Outputs, err = outputsValue(info.Outputs); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// Protobuf value of the outputs of a job, values that are not JSON types, e.g. structs of linked outputs, are
+// converted through their JSON encoding
+func outputsValue(outputs interface{}) (*structpb.Value, error) {
+	if v, err := structpb.NewValue(outputs); err == nil {
+		return v, nil
+	}
+	b, err := json.Marshal(outputs)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(generic)
+}
+
+func (s *Server) Status(ctx context.Context, req *sepexpb.JobRequest) (*sepexpb.JobStatus, error) {
+	if req.GetJobId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	var info handlers.JobInfo
+	mws := s.middlewares(s.auth.Public, s.rh.JobOwner())
+	err := s.call(ctx, http.MethodGet, "/jobs/:jobID", []string{"jobID"}, []string{req.GetJobId()}, nil, mws,
+		func(c echo.Context) (err error) {
+			info, err = s.rh.GetJob(req.GetJobId())
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return jobStatus(info), nil
+}
+
+func (s *Server) Dismiss(ctx context.Context, req *sepexpb.JobRequest) (*sepexpb.JobStatus, error) {
+	if req.GetJobId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	var info handlers.JobInfo
+	mws := s.middlewares(s.auth.Protected, s.rh.Audit(handlers.AuditJobDismiss), s.rh.JobOwner())
+	err := s.call(ctx, http.MethodDelete, "/jobs/:jobID", []string{"jobID"}, []string{req.GetJobId()}, nil, mws,
+		func(c echo.Context) (err error) {
+			info, err = s.rh.DismissJob(c, req.GetJobId())
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return jobStatus(info), nil
+}
+
+func jobStatus(info handlers.JobInfo) *sepexpb.JobStatus {
+	js := &sepexpb.JobStatus{JobId: info.JobID, ProcessId: info.ProcessID, Status: info.Status, Message: info.Message}
+	if !info.Updated.IsZero() {
+		js.Updated = timestamppb.New(info.Updated)
+	}
+	return js
+}
+
+func (s *Server) StreamLogs(req *sepexpb.JobRequest, stream grpc.ServerStreamingServer[sepexpb.LogMessage]) error {
+	if req.GetJobId() == "" {
+		return status.Error(codes.InvalidArgument, "job_id is required")
+	}
+
+	// following stops if sending fails
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendErr error
+	send := func(m *sepexpb.LogMessage) {
+		if sendErr == nil {
+			if sendErr = stream.Send(m); sendErr != nil {
+				cancel()
+			}
+		}
+	}
+
+	var final string
+	mws := s.middlewares(s.auth.Public, s.rh.RateLimit(s.rh.LogsLimiter), s.rh.JobOwner())
+	err := s.call(ctx, http.MethodGet, "/jobs/:jobID/logs/stream", []string{"jobID"}, []string{req.GetJobId()}, nil, mws,
+		func(c echo.Context) (err error) {
+			final, err = s.rh.FollowJobLogs(ctx, req.GetJobId(), handlers.LogFollower{
+				Start: func() {},
+				Line:  func(line string) { send(&sepexpb.LogMessage{Message: &sepexpb.LogMessage_Line{Line: line}}) },
+			})
+			return err
+		})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return err
+	}
+	send(&sepexpb.LogMessage{Message: &sepexpb.LogMessage_FinalStatus{FinalStatus: final}})
+	return sendErr
+}
+
+// Run op for a call with an echo context of a request to the REST route with the given path parameters, behind the
+// middlewares mws. Errors of op and responses of middlewares that stopped the request are converted to gRPC statuses.
+func (s *Server) call(ctx context.Context, method, route string, names, values []string, header http.Header, mws []echo.MiddlewareFunc,
+	op func(c echo.Context) error) error {
+	path := route
+	for i, n := range names {
+		path = strings.Replace(path, ":"+n, url.PathEscape(values[i]), 1)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, http.NoBody)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	req.Host = "grpc"
+	req.RequestURI = path
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, k := range forwardedMetadata {
+			if v := md.Get(k); len(v) > 0 {
+				req.Header.Set(k, v[0])
+			}
+		}
+	}
+	// remote address for rate limits by IP and the caller's certificate for client-cert auth
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+		if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := ti.State
+			req.TLS = &state
+		}
+	}
+
+	rec := newRecorder()
+	c := s.e.NewContext(req, rec)
+	c.SetPath(route)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	// like the RequestID middleware of the REST API, audit records and jobs refer to it
+	c.Response().Header().Set(echo.HeaderXRequestID, uuid.New().String())
+
+	var opErr error
+	called := false
+	h := func(c echo.Context) error {
+		called = true
+		opErr = op(c)
+		// the status of the REST response, recorded by the audit middleware
+		code := http.StatusOK
+		var je *handlers.JobError
+		if errors.As(opErr, &je) {
+			code = je.HTTPStatus
+		}
+		c.Response().WriteHeader(code)
+		return nil
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	err = h(c)
+
+	var he *echo.HTTPError
+	switch {
+	case errors.As(err, &he):
+		return status.Error(grpcCode(he.Code), http.StatusText(he.Code))
+	case err != nil:
+		return status.Error(codes.Internal, err.Error())
+	case !called:
+		return rec.err()
+	}
+	var je *handlers.JobError
+	switch {
+	case errors.As(opErr, &je):
+		return status.Error(grpcCode(je.HTTPStatus), je.Message)
+	case errors.Is(opErr, context.Canceled):
+		return status.FromContextError(opErr).Err()
+	case opErr != nil:
+		return status.Error(codes.Internal, opErr.Error())
+	}
+	return nil
+}
+
+// recorder is the response writer of calls, it records responses of middlewares that stopped a request
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}}
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// err converts an error response of a middleware to a gRPC status
+func (r *recorder) err() error {
+	msg := strings.TrimSpace(r.body.String())
+	var obj struct {
+		Message string `json:"message"`
+	}
+	var str string
+	if err := json.Unmarshal(r.body.Bytes(), &obj); err == nil && obj.Message != "" {
+		msg = obj.Message
+	} else if err := json.Unmarshal(r.body.Bytes(), &str); err == nil {
+		msg = str
+	}
+	if msg == "" {
+		msg = http.StatusText(r.status)
+	}
+	return status.Error(grpcCode(r.status), msg)
+}
+
+// gRPC code of an HTTP status, see the HTTP mapping of google.rpc.Code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
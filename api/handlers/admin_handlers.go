@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"app/config"
+	"app/jobs"
+	"app/utils"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Window used to compute per-process success rates on the dashboard
+const dashboardStatsWindow = 24 * time.Hour
+
+type dashboardJob struct {
+	JobID      string    `json:"jobID"`
+	ProcessID  string    `json:"processID"`
+	Status     string    `json:"status"`
+	Submitter  string    `json:"submitter"`
+	CPUs       float32   `json:"cpus"`
+	Memory     int       `json:"memory"`
+	LastUpdate time.Time `json:"updated"`
+	Position   int       `json:"position,omitempty"` // 1-based position in queue, only for queued jobs
+	Paused     bool      `json:"paused,omitempty"`   // only for queued jobs, paused process queues are listed separately
+}
+
+type processSuccessRate struct {
+	ProcessID  string  `json:"processID"`
+	Successful int     `json:"successful"`
+	Failed     int     `json:"failed"`
+	Dismissed  int     `json:"dismissed"`
+	Running    int     `json:"running"`
+	SuccessPct float32 `json:"successPct"`
+}
+
+func newDashboardJob(j jobs.Job) dashboardJob {
+	r := j.GetResources()
+	return dashboardJob{
+		JobID:      j.JobID(),
+		ProcessID:  j.ProcessID(),
+		Status:     j.CurrentStatus(),
+		Submitter:  j.SUBMITTER(),
+		CPUs:       r.CPUs,
+		Memory:     r.Memory,
+		LastUpdate: j.LastUpdate(),
+	}
+}
+
+// Aggregate status counts per process into success rates.
+// Success rate is computed over terminated jobs only.
+func successRates(counts []jobs.StatusCount) []processSuccessRate {
+	byProcess := make(map[string]*processSuccessRate)
+	for _, sc := range counts {
+		pr, ok := byProcess[sc.ProcessID]
+		if !ok {
+			pr = &processSuccessRate{ProcessID: sc.ProcessID}
+			byProcess[sc.ProcessID] = pr
+		}
+		switch sc.Status {
+		case jobs.SUCCESSFUL:
+			pr.Successful += sc.Count
+		case jobs.FAILED:
+			pr.Failed += sc.Count
+		case jobs.DISMISSED:
+			pr.Dismissed += sc.Count
+		default:
+			pr.Running += sc.Count
+		}
+	}
+
+	rates := make([]processSuccessRate, 0, len(byProcess))
+	for _, pr := range byProcess {
+		if total := pr.Successful + pr.Failed + pr.Dismissed; total > 0 {
+			pr.SuccessPct = float32(pr.Successful) / float32(total) * 100
+		}
+		rates = append(rates, *pr)
+	}
+	sort.Slice(rates, func(i, k int) bool { return rates[i].ProcessID < rates[k].ProcessID })
+	return rates
+}
+
+// @Summary Admin Dashboard
+// @Description Returns active jobs, queue contents, resource utilization and per-process success rates over the last 24h
+// @Tags admin
+// @Accept */*
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/dashboard [get]
+func (rh *RESTHandler) DashboardHandler(c echo.Context) error {
+	err := validateFormat(c)
+	if err != nil {
+		return err
+	}
+
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return prepareResponse(c, http.StatusForbidden, "error", errResponse{HTTPStatus: http.StatusForbidden, Message: "Forbidden"})
+		}
+	}
+
+	counts, err := rh.DB.GetProcessStatusCounts(time.Now().Add(-dashboardStatsWindow))
+	if err != nil {
+		return prepareResponse(c, http.StatusInternalServerError, "error", errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()})
+	}
+
+	queued := make(map[string]bool)
+	queuedJobs := make([]dashboardJob, 0)
+	for i, j := range rh.PendingJobs.List() {
+		dj := newDashboardJob(*j)
+		dj.Position = i + 1
+		dj.Paused = rh.PendingJobs.IsPaused(dj.JobID)
+		queuedJobs = append(queuedJobs, dj)
+		queued[dj.JobID] = true
+	}
+
+	// queued jobs are also tracked as active jobs, list them only once
+	activeJobs := make([]dashboardJob, 0)
+	for _, j := range rh.ActiveJobs.List() {
+		if queued[(*j).JobID()] {
+			continue
+		}
+		activeJobs = append(activeJobs, newDashboardJob(*j))
+	}
+	sort.Slice(activeJobs, func(i, k int) bool { return activeJobs[i].LastUpdate.After(activeJobs[k].LastUpdate) })
+
+	links := []link{
+		{Href: "/admin/dashboard", Rel: "self", Title: "this document"},
+		{Href: "/admin/resources", Rel: "related", Title: "resource status"},
+		{Href: "/stats/usage", Rel: "related", Title: "process usage"},
+	}
+
+	output := make(map[string]interface{})
+	output["resources"] = rh.resourcesStatus(true)
+	output["activeJobs"] = activeJobs
+	output["queuedJobs"] = queuedJobs
+	output["pausedProcesses"] = rh.QueueWorker.PausedProcesses()
+	output["successRates"] = successRates(counts)
+	output["links"] = links
+
+	return prepareResponse(c, http.StatusOK, "dashboard", output)
+}
+
+type queueStatsResponse struct {
+	ActiveJobs  jobs.QueueStats `json:"activeJobs"`
+	PendingJobs jobs.QueueStats `json:"pendingJobs"`
+}
+
+// @Summary Job Queue Stats
+// @Description Returns the number of active jobs and of jobs waiting for local resources, per process, the age of the oldest of them
+// @Description and their limits MAX_ACTIVE_JOBS and MAX_QUEUE_LENGTH, 0 if not limited. Queued jobs are also counted as active jobs.
+// @Description Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} queueStatsResponse
+// @Router /admin/queues [get]
+// Does not produce HTML
+func (rh *RESTHandler) QueueStatsHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	cfg := config.Get().Jobs
+	resp := queueStatsResponse{ActiveJobs: rh.ActiveJobs.Stats(), PendingJobs: rh.PendingJobs.Stats()}
+	resp.ActiveJobs.Limit = cfg.MaxActiveJobs
+	resp.PendingJobs.Limit = cfg.MaxQueueLength
+	return c.JSON(http.StatusOK, resp)
+}
+
+// formatAge formats seconds as a duration rounded to seconds, e.g. 1h2m3s
+func formatAge(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
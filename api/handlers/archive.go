@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+func archivedJobError(jobID string) errResponse {
+	return errResponse{HTTPStatus: http.StatusGone, Message: fmt.Sprintf("job %s is archived, an admin can restore it", jobID)}
+}
+
+// Archive a finished job, its metadata and logs are moved under the archive prefix and it is hidden from job lists.
+// Ownership is checked by JobOwner middleware.
+func (rh *RESTHandler) archiveJob(c echo.Context, jobID string) (jobResponse, *errResponse) {
+	c.Set(auditActionKey, AuditJobArchive)
+
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+	}
+	if !ok {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("%s job id not found", jobID)}
+	}
+	if jRcrd.Archived != nil {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusConflict, Message: fmt.Sprintf("job %s is already archived", jobID)}
+	}
+
+	switch jRcrd.Status {
+	case jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+	default:
+		// e.g. left in a non-terminal status by a server restart, batch delete removes these
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusConflict, Message: fmt.Sprintf("job %s is %s but not active, it can not be archived", jobID, strings.ToLower(jRcrd.Status))}
+	}
+
+	if err := jobs.ArchiveArtifacts(rh.StorageSvc, jobID, jRcrd.Tenant); err != nil {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: "could not archive job files: " + err.Error()}
+	}
+	if err := rh.DB.SetJobArchived(jobID, true); err != nil {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	return jobResponse{ProcessID: jRcrd.ProcessID, Type: "process", JobID: jobID, LastUpdate: jRcrd.LastUpdate, Status: jRcrd.Status, Message: fmt.Sprintf("job %s archived", jobID)}, nil
+}
+
+// @Summary Restore Archived Job
+// @Description Moves metadata and logs of a job archived by `DELETE /jobs/{jobID}` back and lists the job again. Admin only when auth is enabled.
+// @Tags admin
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobResponse
+// @Router /admin/jobs/{jobID}/restore [post]
+func (rh *RESTHandler) JobRestoreHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	jobID := c.Param("jobID")
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("%s job id not found", jobID)})
+	}
+	if jRcrd.Archived == nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("job %s is not archived", jobID)})
+	}
+
+	if err := jobs.RestoreArtifacts(rh.StorageSvc, jobID, jRcrd.Tenant); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "could not restore job files: " + err.Error()})
+	}
+	if err := rh.DB.SetJobArchived(jobID, false); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, jobResponse{ProcessID: jRcrd.ProcessID, Type: "process", JobID: jobID, LastUpdate: jRcrd.LastUpdate, Status: jRcrd.Status, Message: fmt.Sprintf("job %s restored", jobID)})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Audited actions
+const (
+	AuditJobSubmit     = "job.submit"
+	AuditJobDismiss    = "job.dismiss"
+	AuditJobRerun      = "job.rerun"
+	AuditJobDelete     = "job.delete"
+	AuditJobArchive    = "job.archive"
+	AuditJobRestore    = "job.restore"
+	AuditJobPause      = "job.pause"
+	AuditJobResume     = "job.resume"
+	AuditJobNote       = "job.note"
+	AuditJobStatus     = "job.status.update"
+	AuditProcessAdd    = "process.add"
+	AuditProcessUpdate = "process.update"
+	AuditProcessDelete = "process.delete"
+	AuditProcessDeploy = "process.deploy"
+	AuditProcessPause  = "process.queue.pause"
+	AuditProcessResume = "process.queue.resume"
+	AuditProcessTest   = "process.selftest"
+	AuditAdminAccess   = "admin.access"
+	AuditAdminReap     = "admin.orphans.reap"
+	AuditAdminConfig   = "admin.config.update"
+	AuditAdminImages   = "admin.images.refresh"
+)
+
+// Context key handlers can set to record the ID of a resource created by the request, e.g. a new job
+const auditResourceIDKey = "auditResourceID"
+
+// Context key handlers can set when the action depends on the state of the resource, e.g. DELETE archives finished jobs
+const auditActionKey = "auditAction"
+
+// Audit returns a middleware recording the action in the audit log once the handler has responded.
+// Requests are recorded regardless of outcome so that denied attempts are also reviewable.
+func (rh *RESTHandler) Audit(action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			bodyHash := ""
+			if c.Request().Body != nil {
+				body, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					return c.JSON(http.StatusBadRequest, errResponse{Message: "could not read request body"})
+				}
+				c.Request().Body = io.NopCloser(bytes.NewReader(body))
+				if len(body) > 0 {
+					sum := sha256.Sum256(body)
+					bodyHash = hex.EncodeToString(sum[:])
+				}
+			}
+
+			err := next(c)
+
+			if a, _ := c.Get(auditActionKey).(string); a != "" {
+				action = a
+			}
+
+			resourceID, _ := c.Get(auditResourceIDKey).(string)
+			if resourceID == "" {
+				resourceID = c.Param("jobID")
+			}
+			if resourceID == "" {
+				resourceID = c.Param("processID")
+			}
+
+			status := c.Response().Status
+			if he, ok := err.(*echo.HTTPError); ok && !c.Response().Committed {
+				status = he.Code
+			}
+
+			ar := jobs.AuditRecord{
+				Time:       time.Now(),
+				Action:     action,
+				Actor:      c.Request().Header.Get("X-SEPEX-User-Email"),
+				Roles:      c.Request().Header.Get("X-SEPEX-User-Roles"),
+				SourceIP:   c.RealIP(),
+				Method:     c.Request().Method,
+				Path:       c.Request().URL.Path,
+				ResourceID: resourceID,
+				StatusCode: status,
+				RequestID:  requestID(c),
+				BodySHA256: bodyHash,
+			}
+			if dbErr := rh.DB.AddAuditRecord(ar); dbErr != nil {
+				requestLogger(c).Errorf("could not write audit record for %s: %s", action, dbErr.Error())
+			}
+			return err
+		}
+	}
+}
+
+// Split comma separated query parameter, nil if empty
+func splitQueryParam(c echo.Context, name string) []string {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// @Summary Audit Log
+// @Description Lists audit records, newest first. Requires admin role when auth is enabled.
+// @Tags admin
+// @Accept */*
+// @Produce json
+// @Param actor query string false "comma separated list of actors"
+// @Param action query string false "comma separated list of actions"
+// @Param resourceID query string false "comma separated list of job or process IDs"
+// @Param since query string false "RFC3339 time"
+// @Param until query string false "RFC3339 time"
+// @Param limit query int false "maximum 1000, default 100"
+// @Param offset query int false "offset"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/audit [get]
+func (rh *RESTHandler) AuditLogHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	filter := jobs.AuditFilter{
+		Actors:      splitQueryParam(c, "actor"),
+		Actions:     splitQueryParam(c, "action"),
+		ResourceIDs: splitQueryParam(c, "resourceID"),
+	}
+
+	var err error
+	if v := c.QueryParam("since"); v != "" {
+		filter.Since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'since' must be RFC3339 time"})
+		}
+	}
+	if v := c.QueryParam("until"); v != "" {
+		filter.Until, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'until' must be RFC3339 time"})
+		}
+	}
+
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit > 1000 || limit < 1 {
+		limit = 100
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	records, err := rh.DB.GetAuditRecords(limit, offset, filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+
+	links := make([]link, 0)
+	query := c.QueryParams()
+	if offset != 0 {
+		query.Set("offset", fmt.Sprint(max(offset-limit, 0)))
+		query.Set("limit", fmt.Sprint(limit))
+		links = append(links, link{Href: "/admin/audit?" + query.Encode(), Title: "prev"})
+	}
+	if limit == len(records) {
+		query.Set("offset", fmt.Sprint(offset+limit))
+		query.Set("limit", fmt.Sprint(limit))
+		links = append(links, link{Href: "/admin/audit?" + query.Encode(), Title: "next"})
+	}
+
+	output := make(map[string]interface{})
+	output["records"] = records
+	output["links"] = links
+	return c.JSON(http.StatusOK, output)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"app/config"
+	"app/controllers"
+	"app/jobs"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Time before the events queue is polled again after a failed receive
+const batchEventsRetryDelay = 10 * time.Second
+
+// BatchEventsRoutine receives state change events of Batch jobs from the SQS queue at queueURL, where an EventBridge
+// rule sends them, and sends status updates of active aws-batch jobs to the message queue like status updates of
+// PUT /jobs/{jobID}/status. Does nothing if queueURL is empty.
+func (rh *RESTHandler) BatchEventsRoutine(queueURL string) {
+	if queueURL == "" {
+		return
+	}
+
+	cfg := config.Get().AWS
+	q, err := controllers.NewBatchEventsQueue(queueURL, cfg.AccessKeyID, cfg.SecretAccessKey, cfg.Region)
+	if err != nil {
+		log.Errorf("Batch job events are not received: %s", err.Error())
+		return
+	}
+
+	for {
+		changes, handles, err := q.Receive(context.Background())
+		if err != nil {
+			log.Warnf("Could not receive Batch job events: %s", err.Error())
+			time.Sleep(batchEventsRetryDelay)
+			continue
+		}
+		for _, change := range changes {
+			rh.applyBatchStateChange(change)
+		}
+		// Events of jobs of other servers and of inactive jobs are deleted too, nothing else processes them
+		if err := q.Delete(context.Background(), handles); err != nil {
+			log.Warnf("Could not delete Batch job events: %s", err.Error())
+		}
+	}
+}
+
+func (rh *RESTHandler) applyBatchStateChange(change controllers.BatchJobStateChange) {
+	// Batch jobs are named <API_NAME>_<jobID>
+	jobID, ok := strings.CutPrefix(change.JobName, rh.Name+"_")
+	if !ok {
+		return
+	}
+	job, ok := rh.ActiveJobs.Get(jobID)
+	if !ok {
+		return
+	}
+	bj, ok := (*job).(*jobs.AWSBatchJob)
+	if !ok || bj.ProviderID() != change.BatchID {
+		return
+	}
+	if len(change.Attempts) > 0 {
+		bj.UpdateAttempts(change.BatchID, change.Attempts)
+	}
+
+	// Events are not delivered in order, statuses of a job only move forward
+	status := jobs.BatchJobStatus(change.Status)
+	current := (*job).CurrentStatus()
+	if status == "" || status == current || (status == jobs.ACCEPTED && current == jobs.RUNNING) {
+		return
+	}
+
+	(*job).LogMessage(fmt.Sprintf("Status update received from Batch job events: %s.", status), log.InfoLevel)
+	rh.MessageQueue.SendStatus(jobs.StatusMessage{Job: job, Status: status, LastUpdate: change.Time})
+}
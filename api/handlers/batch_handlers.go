@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Maximum number of jobs processed by a single batch request
+const maxBatchJobs = 1000
+
+// batchJobsRequestBody selects the jobs of a batch operation, either by ID or by filter
+type batchJobsRequestBody struct {
+	JobIDs []string         `json:"jobIDs,omitempty"`
+	Filter *batchJobsFilter `json:"filter,omitempty"`
+}
+
+// batchJobsFilter matches jobs by process, status and time since last update, empty fields match all jobs
+type batchJobsFilter struct {
+	ProcessIDs []string `json:"processID,omitempty"`
+	Statuses   []string `json:"status,omitempty"`
+	OlderThan  string   `json:"olderThan,omitempty"` // e.g. 30m, 2h, 7d
+
+	cutoff time.Time
+}
+
+type batchJobResult struct {
+	JobID   string `json:"jobID"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+type batchJobsResponse struct {
+	Results   []batchJobResult `json:"results"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+}
+
+func (br *batchJobsResponse) add(jobID string, err error) {
+	if err != nil {
+		br.Results = append(br.Results, batchJobResult{JobID: jobID, Message: err.Error()})
+		br.Failed++
+		return
+	}
+	br.Results = append(br.Results, batchJobResult{JobID: jobID, Success: true})
+	br.Succeeded++
+}
+
+// Bind and validate a batch request body
+func bindBatchJobs(c echo.Context) (batchJobsRequestBody, error) {
+	var params batchJobsRequestBody
+	if err := c.Bind(&params); err != nil {
+		return params, errors.New("could not parse request body")
+	}
+
+	if (len(params.JobIDs) > 0) == (params.Filter != nil) {
+		return params, errors.New("exactly one of 'jobIDs' or 'filter' must be provided")
+	}
+	if len(params.JobIDs) > maxBatchJobs {
+		return params, fmt.Errorf("at most %d jobIDs can be provided", maxBatchJobs)
+	}
+
+	if f := params.Filter; f != nil {
+		for _, st := range f.Statuses {
+			switch st {
+			case jobs.ACCEPTED, jobs.RUNNING, jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+			default:
+				return params, fmt.Errorf("invalid status %s", st)
+			}
+		}
+		if f.OlderThan != "" {
+			d, err := parseStatsDuration(f.OlderThan)
+			if err != nil || d <= 0 {
+				return params, errors.New("'olderThan' must be a positive duration, e.g. 30m, 2h, 7d")
+			}
+			f.cutoff = time.Now().Add(-d)
+		}
+	}
+	return params, nil
+}
+
+// @Summary Batch Dismiss Jobs
+// @Description Dismisses active (queued or running) jobs selected by `jobIDs` or by `filter` (`processID`, `status`, `olderThan`), at most 1000 jobs per request.
+// @Description Each job is dismissed like with `DELETE /jobs/{jobID}`, the outcome of every job is reported in `results`.
+// @Description When auth is enabled non-admins can only dismiss jobs they submitted, jobs selected by filter are limited to those.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Success 200 {object} batchJobsResponse
+// @Router /jobs:batchDismiss [post]
+func (rh *RESTHandler) BatchDismissHandler(c echo.Context) error {
+	params, err := bindBatchJobs(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+
+	ownershipEnforced := rh.Config.AuthLevel > 0
+	resp := batchJobsResponse{Results: make([]batchJobResult, 0)}
+
+	if f := params.Filter; f != nil {
+		for _, j := range rh.ActiveJobs.List() {
+			if len(resp.Results) >= maxBatchJobs {
+				break
+			}
+			if len(f.ProcessIDs) > 0 && !utils.StringInSlice((*j).ProcessID(), f.ProcessIDs) {
+				continue
+			}
+			if len(f.Statuses) > 0 && !utils.StringInSlice((*j).CurrentStatus(), f.Statuses) {
+				continue
+			}
+			if !f.cutoff.IsZero() && !(*j).LastUpdate().Before(f.cutoff) {
+				continue
+			}
+			if ownershipEnforced && !rh.ownsJob(c, (*j).SUBMITTER()) {
+				continue
+			}
+			resp.add((*j).JobID(), rh.dismissJob(j))
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	for _, jobID := range params.JobIDs {
+		j, ok := rh.ActiveJobs.Get(jobID)
+		if !ok {
+			resp.add(jobID, fmt.Errorf("job %s not in the active jobs list", jobID))
+			continue
+		}
+		if ownershipEnforced && !rh.ownsJob(c, (*j).SUBMITTER()) {
+			resp.add(jobID, errors.New("forbidden"))
+			continue
+		}
+		resp.add(jobID, rh.dismissJob(j))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Batch Delete Jobs
+// @Description Deletes records of jobs that are no longer active, selected by `jobIDs` or by `filter` (`processID`, `status`, `olderThan`), at most 1000 jobs per request.
+// @Description `olderThan` is required with `filter`. This also removes records of jobs left in a non-terminal status by a server restart.
+// @Description Only job records and execution parameters are deleted, logs and results in storage are kept. Admin only when auth is enabled.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Success 200 {object} batchJobsResponse
+// @Router /jobs:batchDelete [post]
+func (rh *RESTHandler) BatchDeleteHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	params, err := bindBatchJobs(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+
+	resp := batchJobsResponse{Results: make([]batchJobResult, 0)}
+	var deletable []string
+
+	if f := params.Filter; f != nil {
+		if f.cutoff.IsZero() {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "'olderThan' is required with 'filter'"})
+		}
+		records, err := rh.DB.GetJobsUpdatedBefore(f.cutoff, maxBatchJobs, f.ProcessIDs, f.Statuses)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		for _, jr := range records {
+			if rh.ActiveJobs.Has(jr.JobID) {
+				resp.add(jr.JobID, errors.New("job is active, dismiss it first"))
+				continue
+			}
+			deletable = append(deletable, jr.JobID)
+		}
+	} else {
+		for _, jobID := range params.JobIDs {
+			if rh.ActiveJobs.Has(jobID) {
+				resp.add(jobID, errors.New("job is active, dismiss it first"))
+				continue
+			}
+			if ok, err := rh.DB.CheckJobExist(jobID); err != nil {
+				resp.add(jobID, err)
+				continue
+			} else if !ok {
+				resp.add(jobID, fmt.Errorf("job %s not found", jobID))
+				continue
+			}
+			deletable = append(deletable, jobID)
+		}
+	}
+
+	if _, err := rh.DB.DeleteJobs(deletable); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	for _, jobID := range deletable {
+		resp.add(jobID, nil)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
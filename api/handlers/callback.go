@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"app/config"
+	"app/jobs"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Body of PUT /internal/jobs/{jobID}/results, fields that are not set are not updated
+type jobCallbackBody struct {
+	// Results of the job, replacing results pushed before
+	Results json.RawMessage `json:"results,omitempty"`
+	// Progress in percent and a message describing it, as in status updates
+	Progress *int   `json:"progress,omitempty"`
+	Message  string `json:"message,omitempty"`
+	// Merged into custom metadata pushed before, null values remove keys
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Token of the results callback of a job, base64url(HMAC-SHA256 of the job ID).
+// Tokens expire with their job, callbackJob rejects them once the job is no longer active or finished.
+func (rh *RESTHandler) callbackToken(jobID string) string {
+	mac := hmac.New(sha256.New, rh.Config.CallbackSecret)
+	mac.Write([]byte("callback:" + jobID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Results callback of job jobID, nil if JOB_CALLBACK_SECRET is not set
+func (rh *RESTHandler) jobCallback(jobID string) *jobs.Callback {
+	if len(rh.Config.CallbackSecret) == 0 {
+		return nil
+	}
+	base := config.Get().Jobs.CallbackURL
+	if base == "" {
+		base = config.Get().API.PublicURL
+	}
+	return &jobs.Callback{
+		URL:   fmt.Sprintf("%s/internal/jobs/%s/results", strings.TrimSuffix(base, "/"), url.PathEscape(jobID)),
+		Token: rh.callbackToken(jobID),
+	}
+}
+
+// CallbackRequest reports whether the request is a results callback with a valid token of its job.
+// Such requests skip authorization, it is used as skipper of the auth middleware with ResultLinkRequest.
+func (rh *RESTHandler) CallbackRequest(c echo.Context) bool {
+	if c.Path() != "/internal/jobs/:jobID/results" {
+		return false
+	}
+	_, ok := rh.callbackJob(c)
+	return ok
+}
+
+// Job of the request if it has the callback token of the job as bearer token and the job is active and not finished
+func (rh *RESTHandler) callbackJob(c echo.Context) (*jobs.Job, bool) {
+	if len(rh.Config.CallbackSecret) == 0 {
+		return nil, false
+	}
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok || !hmac.Equal([]byte(token), []byte(rh.callbackToken(c.Param("jobID")))) {
+		return nil, false
+	}
+	job, ok := rh.ActiveJobs.Get(c.Param("jobID"))
+	if !ok {
+		return nil, false
+	}
+	switch (*job).CurrentStatus() {
+	case jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+		return nil, false
+	}
+	return job, true
+}
+
+// @Summary Job Results Callback
+// @Description Lets the process of a running job push its results, progress and custom metadata to the server instead of
+// @Description reporting them in its logs or a shared bucket. Jobs get the URL in env variable SEPEX_CALLBACK_URL and a
+// @Description token for the `Authorization: Bearer` header in SEPEX_CALLBACK_TOKEN when JOB_CALLBACK_SECRET is set.
+// @Description Pushed results replace results pushed before and are validated against the schemas of the outputs,
+// @Description metadata is added under `custom` to the metadata of the job when it is written. Tokens are only valid
+// @Description while the job runs, bodies must not be larger than MAX_EXECUTE_BODY_KB.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param body body jobCallbackBody true "results, progress and metadata"
+// @Success 202 {string} string "callback received"
+// @Failure 401 {object} errResponse "invalid token or the job is no longer active"
+// @Failure 413 {object} limitErrResponse
+// @Router /internal/jobs/{jobID}/results [put]
+func (rh *RESTHandler) JobCallbackHandler(c echo.Context) error {
+	if len(rh.Config.CallbackSecret) == 0 {
+		return c.JSON(http.StatusNotImplemented, errResponse{Message: "results callbacks are not configured on this server"})
+	}
+
+	jobID := c.Param("jobID")
+	job, ok := rh.callbackJob(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, errResponse{HTTPStatus: http.StatusUnauthorized, Message: "invalid callback token or the job is no longer active"})
+	}
+
+	// the route skips auth, bodies are limited like execute request bodies
+	maxKB := config.Get().API.MaxExecuteBodyKB
+	var reader io.Reader = c.Request().Body
+	if maxKB > 0 {
+		reader = http.MaxBytesReader(c.Response(), c.Request().Body, int64(maxKB)<<10)
+	}
+	defer c.Request().Body.Close()
+	dataBytes, err := io.ReadAll(reader)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, limitErrResponse{Message: fmt.Sprintf("request body must not be larger than %dKB", maxKB), Limit: maxKB})
+		}
+		return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "could not read message body"})
+	}
+	var body jobCallbackBody
+	if err = json.Unmarshal(dataBytes, &body); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "incorrect message body"})
+	}
+	if body.Progress != nil && (*body.Progress < 0 || *body.Progress > 100) {
+		return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "progress must be between 0 and 100"})
+	}
+
+	var results interface{}
+	if len(body.Results) > 0 {
+		if err := json.Unmarshal(body.Results, &results); err != nil || results == nil {
+			return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "results must be a JSON document"})
+		}
+		// Jobs validate their results again before they succeed, with the schemas of the process version they run
+		if p, _, err := rh.ProcessList.Get((*job).ProcessID()); err == nil {
+			if validate := p.ResultsValidator(); validate != nil {
+				if err := validate(results); err != nil {
+					return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "results do not match the schemas of the outputs: " + err.Error()})
+				}
+			}
+		}
+	}
+
+	tenant := (*job).TENANT()
+	if results != nil {
+		if err := jobs.StoreCallbackResults(rh.StorageSvc, jobID, tenant, results); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{http.StatusInternalServerError, "could not store results: " + err.Error()})
+		}
+		(*job).LogMessage("Results received from callback.", logrus.InfoLevel)
+	}
+	if len(body.Metadata) > 0 {
+		if err := jobs.StoreCustomMetadata(rh.StorageSvc, jobID, tenant, body.Metadata); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{http.StatusInternalServerError, "could not store metadata: " + err.Error()})
+		}
+	}
+	if body.Progress != nil || body.Message != "" {
+		rh.JobEvents.PublishProgress(jobs.JobProgress{JobID: jobID, Progress: body.Progress, Message: body.Message})
+	}
+	return c.JSON(http.StatusAccepted, "callback received")
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"app/jobs"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jobChildrenResponse lists the jobs a pipeline or fan-out job submitted with their status rolled up
+type jobChildrenResponse struct {
+	JobID string `json:"jobID"`
+	// Last progress of the parent in percent while it is running
+	Progress *int `json:"progress,omitempty"`
+	// Number of jobs by status
+	Counts map[string]int   `json:"counts"`
+	Jobs   []jobs.JobRecord `json:"jobs"`
+}
+
+// @Summary Job Children
+// @Description Lists the jobs submitted by a pipeline or fan-out job in the order they were submitted, with the number of jobs by status
+// @Description and the progress of the parent while it is running. Jobs of other hosts have no children, the list is empty.
+// @Tags jobs
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobChildrenResponse
+// @Router /jobs/{jobID}/children [get]
+func (rh *RESTHandler) JobChildrenHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+
+	// Ownership is checked by JobOwner middleware, children belong to the submitter of the parent
+	if !rh.ActiveJobs.Has(jobID) {
+		exists, err := rh.DB.CheckJobExist(jobID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		if !exists {
+			return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("%s job id not found", jobID)})
+		}
+	}
+
+	children, err := rh.DB.GetJobChildren(jobID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+
+	resp := jobChildrenResponse{JobID: jobID, Counts: make(map[string]int), Jobs: children}
+	for _, child := range children {
+		resp.Counts[child.Status]++
+	}
+	if p, ok := rh.JobEvents.LastProgress(jobID); ok {
+		resp.Progress = p.Progress
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"app/backendplugin"
+	"app/config"
+	"app/controllers"
+	"app/events"
 	"app/jobs"
 	pr "app/processes"
 	"encoding/json"
@@ -12,12 +16,15 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/graphql-go/graphql"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,8 +39,8 @@ func (t Template) Render(w io.Writer, name string, data interface{}, c echo.Cont
 }
 
 // ResourceLimits holds the maximum resource limits for job scheduling.
-// This is read once at startup and shared across the application to ensure
-// consistent validation between process registration and job execution.
+// They are set on the ResourcePool, which is the source of truth once the server runs so that
+// validation of processes and job execution stay consistent when limits are changed with PATCH /admin/config.
 type ResourceLimits struct {
 	MaxCPUs   float32
 	MaxMemory int // in MB
@@ -50,8 +57,30 @@ type Config struct {
 	AdminRoleName   string
 	ServiceRoleName string
 
-	// Resource limits for local job scheduling (docker/subprocess)
-	ResourceLimits *ResourceLimits
+	// Key to sign result links with, result links are disabled if empty. Their max lifetime is a runtime setting, see resultLinkMaxTTL
+	ResultLinkSecret []byte
+
+	// Key to sign tokens of results callbacks with, jobs get no callback if empty
+	CallbackSecret []byte
+
+	// CORS and security headers of responses, SecureHeaders is nil if disabled
+	CORS          middleware.CORSConfig
+	SecureHeaders *middleware.SecureConfig
+
+	// Maximum time sync execute requests wait for the job, 0 waits until the job finishes
+	SyncWaitTimeout time.Duration
+
+	// Interval at which orphaned job containers and scratch entries are removed, 0 disables the reaper
+	OrphanReaperInterval time.Duration
+
+	// Interval at which running jobs are checked by the job watchdog, 0 disables it
+	WatchdogInterval time.Duration
+	// Time without activity after which running jobs are warned about and failed, 0 disables
+	SilenceWarning time.Duration
+	SilenceTimeout time.Duration
+
+	// Interval at which image tags of docker processes are pulled again, 0 disables re-pulls
+	ImageRefreshInterval time.Duration
 }
 
 // RESTHandler encapsulates the operational components and dependencies necessary for handling
@@ -68,12 +97,34 @@ type RESTHandler struct {
 	StorageSvc   *s3.S3
 	DB           jobs.Database
 	MessageQueue *jobs.MessageQueue
+	EventBus     *jobs.EventBus
+	JobEvents    *jobs.JobEventHub
+	Notifier     *events.Notifier // nil if notifications are not configured
 	ActiveJobs   *jobs.ActiveJobs
 	PendingJobs  *jobs.PendingJobs
 	ResourcePool *jobs.ResourcePool
 	QueueWorker  *jobs.QueueWorker
 	ProcessList  *pr.ProcessList
+	Images       *ImageManager
 	Config       *Config
+
+	// Git repository processes are loaded from, nil if they are loaded from PLUGINS_DIR
+	ProcessRegistry *pr.GitRegistry
+	// Commit of ProcessRegistry the process list was loaded from
+	registryCommit string
+
+	// Rate limiters of execute and logs routes, nil if not configured
+	ExecuteLimiter *RateLimiter
+	LogsLimiter    *RateLimiter
+
+	// Docker controller shared by handlers and docker jobs, connected on first use
+	Docker *controllers.SharedDockerController
+
+	// Schema of the GraphQL endpoint, nil if it is disabled
+	GraphQL *graphql.Schema
+
+	// Usage of local disk by job logs and scratch directories, checked when JOB_DISK_QUOTA_MB or JOB_DISK_MIN_FREE_MB is set
+	DiskMonitor *jobs.DiskMonitor
 }
 
 // Pretty print a JSON
@@ -87,22 +138,22 @@ func prettyPrint(v interface{}) string {
 
 // Initializes resources and return a new handler
 // errors are fatal
-func NewRESTHander(gitTag string, maxLocalCPUs string, maxLocalMemory string) *RESTHandler {
-	apiName, exist := os.LookupEnv("API_NAME")
-	if !exist {
+func NewRESTHander(gitTag string, cfg *config.Config) *RESTHandler {
+	apiName := cfg.API.Name
+	if apiName == "" {
 		log.Warn("env variable API_NAME not set")
 	}
 
-	repoURL, exist := os.LookupEnv("REPO_URL")
-	if !exist {
+	repoURL := cfg.API.RepoURL
+	if repoURL == "" {
 		log.Warn("env variable REPO_URL not set")
 	}
 
 	// Calculate resource limits once at startup
-	resourceLimits := newResourceLimits(maxLocalCPUs, maxLocalMemory)
+	resourceLimits := newResourceLimits(cfg.Jobs.MaxLocalCPUs, cfg.Jobs.MaxLocalMemoryMB)
 
 	// working with pointers here so as not to copy large templates, yamls, and ActiveJobs
-	config := RESTHandler{
+	rh := RESTHandler{
 		Name:        apiName,
 		Title:       "sepex",
 		Description: "SEPEX - Service for Encapsulated Processes Execution. An OGC API - Processes compliant server for executing processes locally or on cloud at scale.",
@@ -118,28 +169,43 @@ func NewRESTHander(gitTag string, maxLocalCPUs string, maxLocalMemory string) *R
 			"http://www.opengis.net/spec/ogcapi-processes-1/1.0/conf/dismiss",
 		},
 		Config: &Config{
-			AdminRoleName:   os.Getenv("AUTH_ADMIN_ROLE"),
-			ServiceRoleName: os.Getenv("AUTH_SERVICE_ROLE"),
-			ResourceLimits:  resourceLimits,
+			AdminRoleName:        cfg.Auth.AdminRole,
+			ServiceRoleName:      cfg.Auth.ServiceRole,
+			ResultLinkSecret:     []byte(cfg.ResultLinks.Secret),
+			CallbackSecret:       []byte(cfg.Jobs.CallbackSecret),
+			CORS:                 newCORSConfig(cfg.CORS),
+			SecureHeaders:        newSecureConfig(cfg.Headers),
+			SyncWaitTimeout:      cfg.Jobs.SyncWaitTimeout,
+			OrphanReaperInterval: cfg.Jobs.OrphanReaperInterval,
+			WatchdogInterval:     cfg.Jobs.WatchdogInterval,
+			SilenceWarning:       cfg.Jobs.SilenceWarning,
+			SilenceTimeout:       cfg.Jobs.SilenceTimeout,
+			ImageRefreshInterval: cfg.Images.RefreshInterval,
 		},
 	}
+	rh.Docker = &controllers.SharedDockerController{}
+	rh.Images = NewImageManager(rh.Docker)
 
-	dbType, exist := os.LookupEnv("DB_SERVICE")
-	if !exist {
-		log.Fatal("env variable DB_SERVICE not set")
+	if v := cfg.ResultLinks.MaxTTL; v != "" {
+		if ttl, err := parseStatsDuration(v); err != nil || ttl <= 0 {
+			log.Fatalf("invalid RESULT_LINK_MAX_TTL: %s", v)
+		}
 	}
 
-	db, err := jobs.NewDatabase(dbType)
+	// DB_SERVICE is checked by config validation
+	db, err := jobs.NewDatabase(cfg.DB)
 	if err != nil {
 		log.Fatalf("Failed to create database: %v", err)
 	}
-	config.DB = db
+	rh.DB = db
 
 	// Read all the html templates
 	funcMap := template.FuncMap{
 		"prettyPrint":   prettyPrint, // to pretty print JSONs for results and metadata
 		"lower":         strings.ToLower,
 		"upper":         strings.ToUpper,
+		"percent":       func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+		"formInputType": formInputType, // field kinds of the execute form of process pages
 		"lastSegment": func(s string) string {
 			parts := strings.Split(strings.TrimSuffix(s, "/"), "/")
 			if len(parts) > 0 {
@@ -149,27 +215,18 @@ func NewRESTHander(gitTag string, maxLocalCPUs string, maxLocalMemory string) *R
 		},
 	}
 
-	config.T = Template{
+	rh.T = Template{
 		templates: template.Must(template.New("").Funcs(funcMap).ParseGlob("views/*.html")),
 	}
 
-	stType, exist := os.LookupEnv("STORAGE_SERVICE")
-	if !exist {
-		log.Fatal("env variable STORAGE_SERVICE not set")
-	}
-
-	stSvc, err := NewStorageService(stType)
+	stSvc, err := NewStorageService(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	config.StorageSvc = stSvc
+	rh.StorageSvc = stSvc
 
 	// Create local logs directory if not exist
-	localLogsDir, exist := os.LookupEnv("TMP_JOB_LOGS_DIR")
-	if !exist {
-		log.Fatal("env variable TMP_JOB_LOGS_DIR not set")
-	}
-	err = os.MkdirAll(localLogsDir, 0755)
+	err = os.MkdirAll(cfg.Logging.JobLogsDir, 0755)
 	if err != nil {
 		log.Fatalf("Failed to create logs directory: %v", err)
 	}
@@ -177,66 +234,118 @@ func NewRESTHander(gitTag string, maxLocalCPUs string, maxLocalMemory string) *R
 	// Setup Active Jobs that will store all jobs currently in process
 	ac := jobs.ActiveJobs{}
 	ac.Jobs = make(map[string]*jobs.Job)
-	config.ActiveJobs = &ac
+	rh.ActiveJobs = &ac
+
+	rh.DiskMonitor = jobs.NewDiskMonitor()
 
 	// Setup Pending Jobs queue for async jobs waiting for resources
-	config.PendingJobs = jobs.NewPendingJobs()
+	rh.PendingJobs = jobs.NewPendingJobs()
 
 	// Setup Resource Pool for tracking CPU/memory availability
-	config.ResourcePool = jobs.NewResourcePool(resourceLimits.MaxCPUs, resourceLimits.MaxMemory)
+	rh.ResourcePool = jobs.NewResourcePool(resourceLimits.MaxCPUs, resourceLimits.MaxMemory)
+	rh.ResourcePool.SetTenantQuotas(parseTenantQuotas(cfg.Jobs.TenantQuotas))
 
 	// Setup Queue Worker to process pending jobs
-	config.QueueWorker = jobs.NewQueueWorker(config.PendingJobs, config.ResourcePool)
+	rh.QueueWorker = jobs.NewQueueWorker(rh.PendingJobs, rh.ResourcePool)
+
+	rh.MessageQueue = jobs.NewMessageQueue(cfg.Jobs.StatusWorkers)
 
-	config.MessageQueue = &jobs.MessageQueue{
-		StatusChan: make(chan jobs.StatusMessage, 500),
-		JobDone:    make(chan jobs.Job, 1),
+	// Setup Event Bus for job lifecycle events and its consumers
+	rh.EventBus = jobs.NewEventBus()
+	rh.JobEvents = jobs.NewJobEventHub()
+	rh.EventBus.Subscribe(rh.JobEvents.Handle)
+	if wh := events.NewWebhookDispatcher(cfg.Webhooks); wh != nil {
+		rh.EventBus.Subscribe(wh.Handle)
+		go wh.Start()
+	}
+	ce, err := events.NewCloudEventsEmitter(cfg.Events, cfg.API.Name)
+	if err != nil {
+		log.Fatalf("Failed to create event broker publisher: %v", err)
+	}
+	if ce != nil {
+		rh.EventBus.Subscribe(ce.Handle)
+		go ce.Start()
+	}
+	if nt := events.NewNotifier(cfg.Notifications, cfg.API.PublicURL); nt != nil {
+		rh.Notifier = nt
+		rh.EventBus.Subscribe(nt.Handle)
+		go nt.Start()
 	}
 
-	// Create local logs directory if not exist
-	pluginsDir := os.Getenv("PLUGINS_DIR") // We already know this env variable exist because it is being checked in plguinsInit function
-	processList, err := pr.LoadProcesses(pluginsDir, resourceLimits.MaxCPUs, resourceLimits.MaxMemory)
+	rh.ExecuteLimiter, err = NewRateLimiter("RATE_LIMIT_EXECUTE", cfg.RateLimit.Execute, cfg.RateLimit.By)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rh.LogsLimiter, err = NewRateLimiter("RATE_LIMIT_LOGS", cfg.RateLimit.Logs, cfg.RateLimit.By)
 	if err != nil {
 		log.Fatal(err)
 	}
-	config.ProcessList = &processList
 
-	return &config
-}
+	// Create local logs directory if not exist
+	pluginsDir := cfg.Plugins.Dir
+	rh.ProcessRegistry, err = pr.NewGitRegistry(cfg.Plugins.Git)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rh.ProcessRegistry != nil {
+		rh.registryCommit, err = rh.ProcessRegistry.Sync()
+		if err != nil {
+			log.Fatalf("could not sync process registry %s: %s", rh.ProcessRegistry.URL, err.Error())
+		}
+		log.Infof("Loading processes from %s at %s", rh.ProcessRegistry.URL, rh.registryCommit)
+		pluginsDir = rh.ProcessRegistry.ProcessesDir()
+	}
+	if err := backendplugin.Load(cfg.Plugins.BackendsDir); err != nil {
+		log.Fatal(err)
+	}
+	// Processes can have the host types of all registered job backends
+	for _, hostType := range jobs.BackendHostTypes() {
+		pr.RegisterHostType(hostType)
+	}
+	// PLUGINS_DIRS are layers below the writable plugins directory or the registry
+	layers := append(append([]string{}, cfg.Plugins.Dirs...), pluginsDir)
+	processList, err := pr.LoadProcesses(layers, resourceLimits.MaxCPUs, resourceLimits.MaxMemory)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rh.ProcessList = &processList
 
-// This routine sequentially updates status.
-// So that order of status updates received is preserved.
-func (rh *RESTHandler) StatusUpdateRoutine() {
-	for {
-		sm := <-rh.MessageQueue.StatusChan
-		jobs.ProcessStatusMessageUpdate(sm)
+	if cfg.API.GraphQL {
+		schema, err := rh.newGraphQLSchema()
+		if err != nil {
+			log.Fatalf("could not create GraphQL schema: %s", err.Error())
+		}
+		rh.GraphQL = &schema
 	}
+
+	return &rh
 }
 
-func (rh *RESTHandler) JobCompletionRoutine() {
-	for {
-		j := <-rh.MessageQueue.JobDone
-		rh.ActiveJobs.Remove(&j)
+// This routine starts one worker per shard of the message queue, each worker sequentially updates status.
+// So that order of status updates received for a job is preserved.
+func (rh *RESTHandler) StatusUpdateRoutine() {
+	for _, ch := range rh.MessageQueue.StatusChans {
+		go func(ch chan jobs.StatusMessage) {
+			for sm := range ch {
+				jobs.ProcessStatusMessageUpdate(sm)
+			}
+		}(ch)
 	}
 }
 
-// Constructor to create storage service based on the type provided
-func NewStorageService(providerType string) (*s3.S3, error) {
+// Constructor to create storage service of the storage settings
+func NewStorageService(cfg *config.Config) (*s3.S3, error) {
 
-	switch providerType {
+	switch cfg.Storage.Service {
 	case "minio":
-		region := os.Getenv("MINIO_S3_REGION")
-		accessKeyID := os.Getenv("MINIO_ACCESS_KEY_ID")
-		secretAccessKey := os.Getenv("MINIO_SECRET_ACCESS_KEY")
-		endpoint := os.Getenv("MINIO_S3_ENDPOINT")
-		if endpoint == "" {
+		if cfg.MinIO.Endpoint == "" {
 			return nil, errors.New("`MINIO_S3_ENDPOINT` env var required if STORAGE_SERVICE='minio'")
 		}
 
 		sess, err := session.NewSession(&aws.Config{
-			Endpoint:         aws.String(endpoint),
-			Region:           aws.String(region),
-			Credentials:      credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+			Endpoint:         aws.String(cfg.MinIO.Endpoint),
+			Region:           aws.String(cfg.MinIO.Region),
+			Credentials:      credentials.NewStaticCredentials(cfg.MinIO.AccessKeyID, cfg.MinIO.SecretAccessKey, ""),
 			S3ForcePathStyle: aws.Bool(true),
 		})
 		if err != nil {
@@ -245,13 +354,9 @@ func NewStorageService(providerType string) (*s3.S3, error) {
 		return s3.New(sess), nil
 
 	case "aws-s3":
-		region := os.Getenv("AWS_REGION")
-		accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
-		secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-
 		sess, err := session.NewSession(&aws.Config{
-			Region:      aws.String(region),
-			Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+			Region:      aws.String(cfg.AWS.Region),
+			Credentials: credentials.NewStaticCredentials(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, ""),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("error creating s3 session: %s", err.Error())
@@ -263,36 +368,56 @@ func NewStorageService(providerType string) (*s3.S3, error) {
 	}
 }
 
-// newResourceLimits creates ResourceLimits from the provided values.
-// Values come from CLI flags which already have env var fallback via resolveValue().
-// Falls back to 80% of system CPUs and 8GB memory if not specified.
-func newResourceLimits(maxLocalCPUsStr string, maxLocalMemoryStr string) *ResourceLimits {
-	numCPUs := float32(runtime.NumCPU())
-
-	// Default to 80% of system CPUs
-	maxCPUs := numCPUs * 0.8
-	if maxLocalCPUsStr != "" {
-		if parsed, err := strconv.ParseFloat(maxLocalCPUsStr, 32); err == nil {
-			maxCPUs = float32(parsed)
-		} else {
-			log.Warnf("Invalid MAX_LOCAL_CPUS value: %s, using default %.2f", maxLocalCPUsStr, maxCPUs)
-		}
+// newResourceLimits creates ResourceLimits from the jobs settings (MAX_LOCAL_CPUS, MAX_LOCAL_MEMORY_MB or their flags).
+// Falls back to 80% of system CPUs if maxCPUs is 0.
+func newResourceLimits(maxCPUs float64, maxMemory int) *ResourceLimits {
+	limits := &ResourceLimits{
+		MaxCPUs:   float32(maxCPUs),
+		MaxMemory: maxMemory,
 	}
+	if limits.MaxCPUs == 0 {
+		// Default to 80% of system CPUs
+		limits.MaxCPUs = float32(runtime.NumCPU()) * 0.8
+	}
+
+	return limits
+}
 
-	// Default to 8GB
-	maxMemory := 8192
-	if maxLocalMemoryStr != "" {
-		if parsed, err := strconv.Atoi(maxLocalMemoryStr); err == nil {
-			maxMemory = parsed
-		} else {
-			log.Warnf("Invalid MAX_LOCAL_MEMORY_MB value: %s, using default %d", maxLocalMemoryStr, maxMemory)
+// parseTenantQuotas parses comma separated tenant quotas of the form tenant=cpus:memoryMB, e.g. acme=4:8192,globex=2:4096.
+// Either limit can be left empty or 0 to not limit that resource. Invalid entries are skipped.
+func parseTenantQuotas(s string) map[string]jobs.TenantQuota {
+	quotas := make(map[string]jobs.TenantQuota)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
 		}
-	}
 
-	log.Infof("ResourceLimits initialized: maxCPUs=%.2f, maxMemory=%dMB", maxCPUs, maxMemory)
+		tenant, limits, ok := strings.Cut(entry, "=")
+		cpusStr, memoryStr, _ := strings.Cut(limits, ":")
+		if !ok || jobs.ValidateTenant(tenant) != nil || tenant == "" {
+			log.Warnf("Invalid TENANT_QUOTAS entry: %s, skipping", entry)
+			continue
+		}
 
-	return &ResourceLimits{
-		MaxCPUs:   maxCPUs,
-		MaxMemory: maxMemory,
+		var q jobs.TenantQuota
+		if cpusStr != "" {
+			cpus, err := strconv.ParseFloat(cpusStr, 32)
+			if err != nil {
+				log.Warnf("Invalid TENANT_QUOTAS entry: %s, skipping", entry)
+				continue
+			}
+			q.CPUs = float32(cpus)
+		}
+		if memoryStr != "" {
+			memory, err := strconv.Atoi(memoryStr)
+			if err != nil {
+				log.Warnf("Invalid TENANT_QUOTAS entry: %s, skipping", entry)
+				continue
+			}
+			q.Memory = memory
+		}
+		quotas[tenant] = q
 	}
+	return quotas
 }
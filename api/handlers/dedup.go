@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"app/jobs"
+	pr "app/processes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Outcome of the execute request with results of an identical successful job of a process with deduplicate enabled.
+// Returns false if there is none and a new job has to be run.
+// Requests with `Cache-Control: no-cache` always run a new job. Results are raw output values if s asks for them.
+func (rh *RESTHandler) reuseJob(c echo.Context, p pr.Process, s submission, inputHash, mode string) (submitResult, bool) {
+	if strings.Contains(c.Request().Header.Get("Cache-Control"), "no-cache") {
+		return submitResult{}, false
+	}
+
+	// With auth users can only read their own jobs, so only their own jobs are reused
+	submitter := ""
+	if rh.Config.AuthLevel > 0 {
+		submitter = c.Request().Header.Get("X-SEPEX-User-Email")
+	}
+
+	jobID, ok, err := rh.DB.FindSuccessfulJob(inputHash, rh.requestTenant(c), submitter, time.Now().Add(-p.Config.DedupTTL()))
+	if err != nil {
+		requestLogger(c).Errorf("could not look up identical jobs: %s", err.Error())
+		return submitResult{}, false
+	}
+	if !ok {
+		return submitResult{}, false
+	}
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil || !ok || jRcrd.Archived != nil {
+		return submitResult{}, false
+	}
+
+	resp := jobResponse{ProcessID: p.Info.ID, Type: "process", JobID: jobID, LastUpdate: jRcrd.LastUpdate, Status: jobs.SUCCESSFUL, Message: "results of identical job " + jobID + " reused"}
+	if mode == "sync-execute" && p.Outputs != nil {
+		outputs, err := jobs.FetchResults(rh.StorageSvc, jobID, jRcrd.Tenant)
+		if err != nil {
+			// e.g. logs of the job were deleted, run it again
+			requestLogger(c).Warnf("could not fetch results of identical job %s: %s", jobID, err.Error())
+			return submitResult{}, false
+		}
+		resp.Outputs = p.LinkOutputs(outputs)
+	}
+
+	c.Set(auditResourceIDKey, jobID)
+	c.Response().Header().Set("Location", "/jobs/"+jobID)
+	return submitResult{HTTPStatus: http.StatusOK, Resp: resp, Raw: mode == "sync-execute" && wantsRaw(s)}, true
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"app/config"
+	"app/processes"
+	"app/utils"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+type deployRequestBody struct {
+	Artifact string `json:"artifact"` // e.g. oci://ghcr.io/org/process:1.0.0
+}
+
+// @Summary Deploy Process Artifact
+// @Description Pulls a process packaged as an OCI artifact (process spec YAML layer plus optional schema and docs layers) and adds or updates the process.
+// @Description The process records the artifact and its manifest digest in `source`. A version can only be deployed from one digest,
+// @Description deploying the same version from another digest returns 409, deploying the same digest again is a no-op.
+// @Description Requires admin role when auth is enabled.
+// @Tags processes
+// @Accept json
+// @Produce json
+// @Param body body deployRequestBody true "artifact reference"
+// @Success 200 {object} map[string]interface{}
+// @Router /processes:deploy [post]
+func (rh *RESTHandler) DeployProcessHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	if rh.ProcessRegistry != nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("processes are loaded from %s, change them in the repository", rh.ProcessRegistry.URL)})
+	}
+
+	var params deployRequestBody
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "invalid request body: " + err.Error()})
+	}
+	if err := processes.ValidateArtifactRef(params.Artifact); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+
+	a, err := processes.PullArtifact(c.Request().Context(), params.Artifact)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, errResponse{Message: fmt.Sprintf("could not pull %s: %s", params.Artifact, err.Error())})
+	}
+
+	// MarshallProcess reads from a file and resolves aws-batch job definitions and resource defaults
+	tmpDir, err := os.MkdirTemp("", "sepex-artifact-")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	defer os.RemoveAll(tmpDir)
+	specPath := filepath.Join(tmpDir, a.SpecFile)
+	if err := os.WriteFile(specPath, a.Files[a.SpecFile], 0644); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	p, err := processes.MarshallProcess(specPath)
+	if err != nil {
+		return invalidSpecResponse(c, err, fmt.Sprintf("invalid process spec %s: %s", a.SpecFile, err.Error()))
+	}
+	p.Source = &a.Source
+	processID := p.Info.ID
+
+	if err := p.Validate(rh.ResourcePool.Limits()); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+
+	oldProcess, i, err := rh.ProcessList.Get(processID)
+	exists := err == nil
+	if exists && oldProcess.Info.Version == p.Info.Version {
+		if oldProcess.Source != nil && oldProcess.Source.Digest == a.Digest {
+			return c.JSON(http.StatusOK, map[string]interface{}{"message": "Process already deployed from this artifact", "processID": processID, "source": p.Source})
+		}
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("version %s of process %s is already deployed from another source, bump the version of the artifact", p.Info.Version, processID)})
+	}
+
+	pluginsDir := config.Get().Plugins.Dir
+	processDir := filepath.Join(pluginsDir, processID)
+	filename := filepath.Join(processDir, processID+".yml")
+
+	// to do: this should be atomic
+
+	// Processes of read-only PLUGINS_DIRS have no file to deprecate, the deployed spec overrides them
+	if _, err := os.Stat(filename); exists && err == nil {
+		destDir := filepath.Join(pluginsDir, "deprecated", processID)
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
+		}
+		if err := os.Rename(filename, filepath.Join(destDir, fmt.Sprintf("%s_%s.yml", processID, oldProcess.Info.Version))); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
+		}
+	}
+
+	if err := os.MkdirAll(processDir, 0755); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to create process directory"})
+	}
+	// Schemas and docs are kept in a subdirectory so that YAML files among them are not loaded as processes,
+	// the spec itself is written with its source below
+	artifactDir := filepath.Join(processDir, "artifact")
+	if err := os.RemoveAll(artifactDir); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to remove old artifact files"})
+	}
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to create process directory"})
+	}
+	for name, content := range a.Files {
+		if name == a.SpecFile {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(artifactDir, name), content, 0644); err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to write artifact file " + name})
+		}
+	}
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to marshal process data"})
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to write process file"})
+	}
+
+	if exists {
+		rh.ProcessList.List[i] = p
+		rh.ProcessList.InfoList[i] = p.Info
+	} else {
+		rh.ProcessList.List = append(rh.ProcessList.List, p)
+		rh.ProcessList.InfoList = append(rh.ProcessList.InfoList, p.Info)
+	}
+
+	c.Set(auditResourceIDKey, processID)
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Process deployed successfully", "processID": processID, "source": p.Source})
+}
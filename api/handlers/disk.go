@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"app/config"
+	"app/jobs"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DiskMonitorRoutine checks local disk usage of job logs and scratch directories every interval and when cleaning is
+// requested, files of jobs that are no longer active are removed while usage is nearly full.
+// It returns right away if neither JOB_DISK_QUOTA_MB nor JOB_DISK_MIN_FREE_MB is set.
+func (rh *RESTHandler) DiskMonitorRoutine(interval time.Duration) {
+	check := func() {
+		usage, enabled, err := rh.DiskMonitor.Check()
+		if err != nil {
+			log.Errorf("Disk monitor could not check local disk usage: %s", err.Error())
+			return
+		}
+		if !enabled {
+			return
+		}
+		reason := usage.NearlyFull()
+		if reason == "" {
+			return
+		}
+
+		log.Warnf("Local disk of job logs and scratch directories is nearly full (%s), new local jobs are refused", reason)
+		freed, err := jobs.CleanLocalFiles(rh.StorageSvc, rh.DB, rh.ActiveJobs, usage)
+		if err != nil {
+			log.Errorf("Disk janitor could not clean local files: %s", err.Error())
+		}
+		if freed > 0 {
+			log.Infof("Disk janitor removed %dMB of local files of jobs that are no longer active", freed>>20)
+		}
+		// Jobs are accepted again as soon as cleaning freed enough space
+		if _, _, err := rh.DiskMonitor.Check(); err != nil {
+			log.Errorf("Disk monitor could not check local disk usage: %s", err.Error())
+		}
+	}
+
+	if cfg := config.Get().Jobs; (cfg.DiskQuotaMB <= 0 && cfg.DiskMinFreeMB <= 0) || interval <= 0 {
+		return
+	}
+	check()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-rh.DiskMonitor.CleanRequests():
+		}
+		check()
+	}
+}
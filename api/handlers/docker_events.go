@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"app/controllers"
+	"app/jobs"
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Time before the Docker events stream is followed again after it ended or when Docker is not available
+const dockerEventsRetryDelay = 10 * time.Second
+
+// DockerEventsRoutine follows die, oom and kill events of job containers and passes them to their docker jobs, so that
+// jobs learn about the end of their container even if waiting for it fails. After the stream ended it is followed again
+// from the time of the last event, events of that second are received again.
+func (rh *RESTHandler) DockerEventsRoutine() {
+	var since time.Time
+	for {
+		c, err := rh.Docker.Get()
+		if err != nil {
+			// Deployments running only aws-batch or subprocess jobs have no Docker daemon
+			log.Debugf("Docker events are not followed, could not connect to Docker: %s", err.Error())
+			time.Sleep(dockerEventsRetryDelay)
+			continue
+		}
+
+		events := make(chan controllers.ContainerEvent)
+		go func() {
+			defer close(events)
+			if err := c.ContainerEventStream(context.Background(), rh.Name, since, events); err != nil {
+				log.Warnf("Docker events stream ended: %s", err.Error())
+			}
+		}()
+		for e := range events {
+			since = e.Time
+			rh.applyContainerEvent(e)
+		}
+		time.Sleep(dockerEventsRetryDelay)
+	}
+}
+
+func (rh *RESTHandler) applyContainerEvent(e controllers.ContainerEvent) {
+	job, ok := rh.ActiveJobs.Get(e.JobID)
+	if !ok {
+		return
+	}
+	if dj, ok := (*job).(*jobs.DockerJob); ok {
+		dj.HandleContainerEvent(e)
+	}
+}
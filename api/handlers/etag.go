@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// Results and metadata of finished jobs rarely change, clients may keep them but must revalidate them with If-None-Match
+const revalidateCacheControl = "private, no-cache"
+
+// etagMatches returns true if the If-None-Match header of the request matches etag, compared weakly as required for GET
+func etagMatches(c echo.Context, etag string) bool {
+	inm := c.Request().Header.Get("If-None-Match")
+	if inm == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(inm) == "*" {
+		return true
+	}
+	for _, t := range strings.Split(inm, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonWithETag responds with output as JSON and a weak ETag of the body, or with 304 if the request has the same ETag in If-None-Match.
+// The body is still built for every request, the ETag saves clients polling unchanged documents from downloading them again.
+func jsonWithETag(c echo.Context, status int, output interface{}) error {
+	body, err := json.Marshal(output)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := c.Response().Header()
+	h.Set("ETag", etag)
+	h.Set(echo.HeaderCacheControl, revalidateCacheControl)
+	if etagMatches(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSONBlob(status, body)
+}
+
+// streamS3Object copies an object from storage to the response as it is read, with its Content-Length, ETag and Last-Modified
+func streamS3Object(c echo.Context, out *s3.GetObjectOutput, contentType string) error {
+	defer out.Body.Close()
+
+	h := c.Response().Header()
+	h.Set(echo.HeaderContentType, contentType)
+	h.Set(echo.HeaderCacheControl, revalidateCacheControl)
+	if out.ContentLength != nil {
+		h.Set(echo.HeaderContentLength, strconv.FormatInt(*out.ContentLength, 10))
+	}
+	if out.ETag != nil {
+		h.Set("ETag", aws.StringValue(out.ETag))
+	}
+	if out.LastModified != nil {
+		h.Set(echo.HeaderLastModified, out.LastModified.UTC().Format(http.TimeFormat))
+	}
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := io.Copy(c.Response(), out.Body)
+	return err
+}
+
+// notModified responds with 304 to a conditional request that storage answered with not modified,
+// the ETag of the request is returned if it names a single one
+func notModified(c echo.Context) error {
+	if inm := c.Request().Header.Get("If-None-Match"); !strings.Contains(inm, ",") && strings.TrimSpace(inm) != "*" {
+		c.Response().Header().Set("ETag", strings.TrimSpace(inm))
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, revalidateCacheControl)
+	return c.NoContent(http.StatusNotModified)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"app/utils"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ExecutionModeResult contains the determined execution mode and whether
@@ -81,3 +83,21 @@ func parseRespondAsyncPreference(preferHeader string) bool {
 
 	return false
 }
+
+// parseWaitPreference returns the duration of the "wait" preference (RFC 7240) in the Prefer header, 0 if absent or invalid.
+// Example: "wait=10" or "respond-async, wait=10"
+func parseWaitPreference(preferHeader string) time.Duration {
+	for _, pref := range strings.Split(preferHeader, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pref), ";", 2)
+		name, value, ok := strings.Cut(strings.TrimSpace(parts[0]), "=")
+		if !ok || strings.TrimSpace(name) != "wait" {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+		if err != nil || secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"app/jobs"
+	pr "app/processes"
+	"fmt"
+)
+
+// fanOutRunner runs a job of process p per element of the array input of a fan-out request as the only step of
+// the parent job, see jobs.PipelineRunner. The jobs of the elements get the resources and env overrides of the request.
+type fanOutRunner struct {
+	rh        *RESTHandler
+	p         pr.Process
+	input     string
+	resources pr.Resources
+	env       map[string]string
+}
+
+func (r *fanOutRunner) StartStep(j *jobs.PipelineJob, i int) ([]jobs.Job, bool, error) {
+	inputs, err := r.p.FanOutInputs(r.input, j.Inputs)
+	if err != nil {
+		return nil, true, err
+	}
+
+	runs := make([]jobs.Job, 0, len(inputs))
+	for k, in := range inputs {
+		run, err := r.rh.submitStep(j, r.p, in, r.resources, r.env)
+		if err != nil {
+			return runs, true, fmt.Errorf("element %d: %v", k, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, true, nil
+}
+
+// RunResults returns the job ID and outputs of the job of an element, outputs are linked when the parent job finishes
+// since the results document of the parent is not linked per request like results of other jobs
+func (r *fanOutRunner) RunResults(run jobs.Job) (interface{}, error) {
+	var outputs interface{}
+	if r.p.Outputs != nil {
+		var err error
+		outputs, err = jobs.FetchResults(r.rh.StorageSvc, run.JobID(), run.TENANT())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"jobID": run.JobID(), "outputs": r.p.LinkOutputs(outputs)}, nil
+}
+
+// Results returns the results document of the parent job, the element, job ID and outputs of every element in order
+func (r *fanOutRunner) Results(j *jobs.PipelineJob) (interface{}, error) {
+	runs, _ := j.StepResults()[r.input].([]interface{})
+	items, _ := j.Inputs[r.input].([]interface{})
+	if len(runs) != len(items) {
+		return nil, fmt.Errorf("%d results for %d elements", len(runs), len(items))
+	}
+
+	elements := make([]interface{}, len(runs))
+	for k, run := range runs {
+		element, _ := run.(map[string]interface{})
+		if element == nil {
+			return nil, fmt.Errorf("element %d has no results", k)
+		}
+		element["input"] = items[k]
+		elements[k] = element
+	}
+	return map[string]interface{}{"elements": elements}, nil
+}
+
+func (r *fanOutRunner) Dismiss(run jobs.Job) error {
+	return r.rh.dismissJob(&run)
+}
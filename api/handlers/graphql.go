@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"app/jobs"
+	pr "app/processes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/labstack/echo/v4"
+)
+
+// Default and max number of items of lists in GraphQL queries, the same as for job lists of the REST API
+const (
+	graphQLDefaultLimit = 20
+	graphQLMaxLimit     = 100
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// key of the echo context of the request in the context of resolvers
+type graphQLContextKey struct{}
+
+// @Summary GraphQL queries
+// @Description Query processes, their jobs, job statuses and results in one request. Queries are sent as JSON body
+// @Description `{"query": ..., "variables": {...}, "operationName": ...}` or as `query` parameter of GET requests.
+// @Description The same restrictions as for REST routes apply: processes with access blocks are only listed for allowed users,
+// @Description users only see their own jobs when AUTH_LEVEL requires it. Only available if GRAPHQL_ENABLED is true.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /graphql [get]
+// @Router /graphql [post]
+// Does not produce HTML
+func (rh *RESTHandler) GraphQLHandler(c echo.Context) error {
+	var req graphQLRequest
+	if c.Request().Method == http.MethodGet {
+		req.Query = c.QueryParam("query")
+		req.OperationName = c.QueryParam("operationName")
+		if v := c.QueryParam("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return c.JSON(http.StatusBadRequest, errResponse{Message: "'variables' is not a JSON object"})
+			}
+		}
+	} else if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "'query' is required"})
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         *rh.GraphQL,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(c.Request().Context(), graphQLContextKey{}, c),
+	})
+	// errors of single fields are part of the result, like in all GraphQL implementations the status is 200
+	return c.JSON(http.StatusOK, result)
+}
+
+// Echo context of the request of a resolver
+func graphQLEcho(p graphql.ResolveParams) echo.Context {
+	return p.Context.Value(graphQLContextKey{}).(echo.Context)
+}
+
+// Processes with access blocks are hidden from users that are not allowed to use them, as on the describe route
+func (rh *RESTHandler) processVisible(c echo.Context, p pr.Process) bool {
+	return rh.Config.AuthLevel == 0 || p.Access == nil || rh.processAllowed(c, p)
+}
+
+// Limit and offset arguments of a list field
+func listWindow(p graphql.ResolveParams) (int, int) {
+	limit, _ := p.Args["limit"].(int)
+	if limit < 1 || limit > graphQLMaxLimit {
+		limit = graphQLDefaultLimit
+	}
+	offset, _ := p.Args["offset"].(int)
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// Jobs visible to the user of the request, newest first. processID and status are optional filters.
+func (rh *RESTHandler) graphQLJobs(p graphql.ResolveParams, processID string) (interface{}, error) {
+	c := graphQLEcho(p)
+	limit, offset := listWindow(p)
+
+	var processIDs, statuses, submitters []string
+	if processID != "" {
+		processIDs = []string{processID}
+	}
+	if st, ok := p.Args["status"].(string); ok && st != "" {
+		statuses = []string{st}
+	}
+	submitter, _ := p.Args["submitter"].(string)
+	submitter, tenants := rh.visibleJobs(c, submitter, "")
+	if submitter != "" {
+		submitters = strings.Split(submitter, ",")
+	}
+	return rh.DB.GetJobs(limit, offset, processIDs, statuses, submitters, tenants, true)
+}
+
+// Results of a job, nil if the job has none (yet). Failed and dismissed jobs return the results they reported before they stopped.
+func (rh *RESTHandler) graphQLResults(jr jobs.JobRecord) (interface{}, error) {
+	if rh.ActiveJobs.Has(jr.JobID) || jr.Archived != nil {
+		return nil, nil
+	}
+	var outputs interface{}
+	var err error
+	switch jr.Status {
+	case jobs.SUCCESSFUL:
+		outputs, err = jobs.FetchResults(rh.StorageSvc, jr.JobID, jr.Tenant)
+		if err != nil && err.Error() == "not found" {
+			return nil, nil
+		}
+	case jobs.FAILED, jobs.DISMISSED:
+		// partial results are optional, jobs that did not report any have none
+		outputs, err = jobs.FetchPartialResults(rh.StorageSvc, jr.JobID, jr.Tenant)
+		if err != nil {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rh.linkOutputs(jr.ProcessID, outputs), nil
+}
+
+// Links of a job to its REST routes
+func jobLinks(jr jobs.JobRecord) []link {
+	links := []link{
+		{Href: "/jobs/" + jr.JobID, Rel: "status", Type: "application/json", Title: "job status"},
+		{Href: "/jobs/" + jr.JobID + "/logs", Rel: "logs", Type: "application/json", Title: "job logs"},
+	}
+	switch jr.Status {
+	case jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+		if jr.Archived == nil {
+			links = append(links, link{Href: "/jobs/" + jr.JobID + "/results", Rel: "results", Type: "application/json", Title: "job results"})
+		}
+	default:
+		links = append(links, link{Href: "/jobs/" + jr.JobID + "/events", Rel: "monitor", Type: "text/event-stream", Title: "job events"})
+	}
+	return links
+}
+
+// JSON values of any shape, e.g. results of jobs
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize:   func(v interface{}) interface{} { return v },
+})
+
+var linkType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Link",
+	Fields: graphql.Fields{
+		"href":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"rel":   &graphql.Field{Type: graphql.String},
+		"type":  &graphql.Field{Type: graphql.String},
+		"title": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var jobStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "JobStatus",
+	Values: graphql.EnumValueConfigMap{
+		"accepted":   &graphql.EnumValueConfig{Value: jobs.ACCEPTED},
+		"running":    &graphql.EnumValueConfig{Value: jobs.RUNNING},
+		"successful": &graphql.EnumValueConfig{Value: jobs.SUCCESSFUL},
+		"failed":     &graphql.EnumValueConfig{Value: jobs.FAILED},
+		"dismissed":  &graphql.EnumValueConfig{Value: jobs.DISMISSED},
+	},
+})
+
+// Resolver of a field of a job record
+func jobField(f func(jr jobs.JobRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(jobs.JobRecord)), nil
+	}
+}
+
+// Resolver of a field of a process
+func processField(f func(p pr.Process) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(pr.Process)), nil
+	}
+}
+
+// newGraphQLSchema returns the schema of the GraphQL endpoint, resolvers query the database, process list and storage of rh.
+func (rh *RESTHandler) newGraphQLSchema() (graphql.Schema, error) {
+	listArgs := graphql.FieldConfigArgument{
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, Description: fmt.Sprintf("at most %d, default %d", graphQLMaxLimit, graphQLDefaultLimit)},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+	jobListArgs := graphql.FieldConfigArgument{
+		"status":    &graphql.ArgumentConfig{Type: jobStatusEnum},
+		"submitter": &graphql.ArgumentConfig{Type: graphql.String, Description: "ignored for users that only see their own jobs"},
+	}
+	for k, v := range listArgs {
+		jobListArgs[k] = v
+	}
+
+	var processType *graphql.Object
+	jobType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Job",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"jobID":        &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.JobID })},
+				"processID":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.ProcessID })},
+				"status":       &graphql.Field{Type: graphql.NewNonNull(jobStatusEnum), Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.Status })},
+				"updated":      &graphql.Field{Type: graphql.DateTime, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.LastUpdate })},
+				"host":         &graphql.Field{Type: graphql.String, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.Host })},
+				"mode":         &graphql.Field{Type: graphql.String, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.Mode })},
+				"submitter":    &graphql.Field{Type: graphql.String, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.Submitter })},
+				"tenant":       &graphql.Field{Type: graphql.String, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.Tenant })},
+				"failureClass": &graphql.Field{Type: graphql.String, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.FailureClass })},
+				"exitCode": &graphql.Field{Type: graphql.Int, Resolve: jobField(func(jr jobs.JobRecord) interface{} {
+					if jr.ExitCode == nil {
+						return nil
+					}
+					return *jr.ExitCode
+				})},
+				"oomKilled": &graphql.Field{Type: graphql.Boolean, Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jr.OOMKilled })},
+				"archived": &graphql.Field{Type: graphql.DateTime, Resolve: jobField(func(jr jobs.JobRecord) interface{} {
+					if jr.Archived == nil {
+						return nil
+					}
+					return *jr.Archived
+				})},
+				"links": &graphql.Field{Type: graphql.NewList(linkType), Resolve: jobField(func(jr jobs.JobRecord) interface{} { return jobLinks(jr) })},
+				"results": &graphql.Field{
+					Type:        jsonScalar,
+					Description: "outputs of the job, null until it finished. Fetched from storage, select it for few jobs only",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return rh.graphQLResults(p.Source.(jobs.JobRecord))
+					},
+				},
+				"process": &graphql.Field{
+					Type:        processType,
+					Description: "null if the process was deleted",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						proc, _, err := rh.ProcessList.Get(p.Source.(jobs.JobRecord).ProcessID)
+						if err != nil || !rh.processVisible(graphQLEcho(p), proc) {
+							return nil, nil
+						}
+						return proc, nil
+					},
+				},
+			}
+		}),
+	})
+
+	processType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Process",
+		Fields: graphql.Fields{
+			"id":                &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: processField(func(p pr.Process) interface{} { return p.Info.ID })},
+			"version":           &graphql.Field{Type: graphql.String, Resolve: processField(func(p pr.Process) interface{} { return p.Info.Version })},
+			"title":             &graphql.Field{Type: graphql.String, Resolve: processField(func(p pr.Process) interface{} { return p.Info.Title })},
+			"description":       &graphql.Field{Type: graphql.String, Resolve: processField(func(p pr.Process) interface{} { return p.Info.Description })},
+			"keywords":          &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: processField(func(p pr.Process) interface{} { return p.Info.Keywords })},
+			"tags":              &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: processField(func(p pr.Process) interface{} { return p.Info.Tags })},
+			"jobControlOptions": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: processField(func(p pr.Process) interface{} { return p.Info.JobControlOptions })},
+			"hostType":          &graphql.Field{Type: graphql.String, Resolve: processField(func(p pr.Process) interface{} { return p.Host.Type })},
+			"readiness": &graphql.Field{Type: graphql.String, Resolve: processField(func(p pr.Process) interface{} {
+				return string(rh.ProcessList.ReadinessOf(p).State)
+			})},
+			"jobs": &graphql.Field{
+				Type:        graphql.NewList(jobType),
+				Description: "jobs of the process, newest first",
+				Args:        jobListArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return rh.graphQLJobs(p, p.Source.(pr.Process).Info.ID)
+				},
+			},
+		},
+	})
+
+	processesArgs := graphql.FieldConfigArgument{
+		"q":        &graphql.ArgumentConfig{Type: graphql.String, Description: "free text, every word must be in the ID, title, description or keywords"},
+		"keyword":  &graphql.ArgumentConfig{Type: graphql.String},
+		"tag":      &graphql.ArgumentConfig{Type: graphql.String},
+		"hostType": &graphql.ArgumentConfig{Type: graphql.String},
+	}
+	for k, v := range listArgs {
+		processesArgs[k] = v
+	}
+	jobsArgs := graphql.FieldConfigArgument{"processID": &graphql.ArgumentConfig{Type: graphql.String}}
+	for k, v := range jobListArgs {
+		jobsArgs[k] = v
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"processes": &graphql.Field{
+				Type: graphql.NewList(processType),
+				Args: processesArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					c := graphQLEcho(p)
+					q := pr.ProcessQuery{}
+					q.Text, _ = p.Args["q"].(string)
+					q.Keyword, _ = p.Args["keyword"].(string)
+					q.Tag, _ = p.Args["tag"].(string)
+					q.HostType, _ = p.Args["hostType"].(string)
+					limit, offset := listWindow(p)
+
+					result := make([]pr.Process, 0)
+					for _, info := range rh.ProcessList.Search(q) {
+						proc, _, err := rh.ProcessList.Get(info.ID)
+						if err != nil || !rh.processVisible(c, proc) {
+							continue
+						}
+						if offset > 0 {
+							offset--
+							continue
+						}
+						if len(result) == limit {
+							break
+						}
+						result = append(result, proc)
+					}
+					return result, nil
+				},
+			},
+			"process": &graphql.Field{
+				Type: processType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					proc, _, err := rh.ProcessList.Get(p.Args["id"].(string))
+					if err != nil {
+						return nil, nil
+					}
+					if !rh.processVisible(graphQLEcho(p), proc) {
+						return nil, errors.New("Forbidden")
+					}
+					return proc, nil
+				},
+			},
+			"jobs": &graphql.Field{
+				Type:        graphql.NewList(jobType),
+				Description: "jobs, newest first",
+				Args:        jobsArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					processID, _ := p.Args["processID"].(string)
+					return rh.graphQLJobs(p, processID)
+				},
+			},
+			"job": &graphql.Field{
+				Type: jobType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					jr, ok, err := rh.DB.GetJob(p.Args["id"].(string))
+					if err != nil || !ok {
+						return nil, err
+					}
+					// the same restriction as JobOwner of the job status route
+					if rh.Config.AuthLevel > 0 && !rh.ownsJob(graphQLEcho(p), jr.Submitter) {
+						return nil, errors.New("Forbidden")
+					}
+					return jr, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
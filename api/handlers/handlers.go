@@ -8,9 +8,13 @@ package handlers
 // These rules are in compliance with Specs
 
 import (
+	"app/config"
+	"app/events"
 	"app/jobs"
+	pr "app/processes"
 	"app/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,9 +22,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/gommon/log"
 	"github.com/sirupsen/logrus"
 )
 
@@ -39,6 +44,23 @@ type jobResponse struct {
 	ProcessID  string      `json:"processID,omitempty"`
 	Message    string      `json:"message,omitempty"`
 	Outputs    interface{} `json:"outputs,omitempty"`
+	// Why the job failed, one of oom, bad_input, upstream_timeout, invalid_results, unknown or a process specific class
+	FailureClass string `json:"failureClass,omitempty"`
+	// Exit code and OOM kill flag of docker and subprocess jobs once their process exited
+	ExitCode  *int `json:"exitCode,omitempty"`
+	OOMKilled bool `json:"oomKilled,omitempty"`
+	// Outputs were reported by a failed or dismissed job and may be incomplete
+	Partial bool `json:"partial,omitempty"`
+	// ID of the pipeline or fan-out job the job is a step or element of
+	ParentID string `json:"parentID,omitempty"`
+	// Last progress in percent reported by a running job, rolled up from their jobs for pipeline and fan-out jobs
+	Progress *int `json:"progress,omitempty"`
+	// When the job was archived, its logs, results and metadata are not available until it is restored
+	Archived *time.Time `json:"archived,omitempty"`
+	// Notes attached to the job, rendered on the HTML status page, see /jobs/{jobID}/notes for JSON
+	Notes []jobs.JobNote `json:"-"`
+	// Attempts of aws-batch jobs whose container started
+	Attempts []jobs.Attempt `json:"attempts,omitempty"`
 }
 
 type link struct {
@@ -70,33 +92,88 @@ func validateFormat(c echo.Context) error {
 // If query parameter not defined then fall back to Accept header as suggested in OGC Specs
 // Both are not defined then return JSON
 func prepareResponse(c echo.Context, httpStatus int, renderName string, output interface{}) error {
+	if respondsHTML(c) {
+		return c.Render(httpStatus, renderName, output)
+	}
+	return c.JSON(httpStatus, output)
+}
+
+// respondsHTML returns true if prepareResponse renders HTML for the request
+func respondsHTML(c echo.Context) bool {
 	// this is to conform to OGC Process API classes: /req/html/definition and /req/json/definition
-	outputFormat := c.QueryParam("f")
-	switch outputFormat {
+	switch c.QueryParam("f") {
 	case "html":
-		return c.Render(httpStatus, renderName, output)
+		return true
 	case "json":
-		return c.JSON(httpStatus, output)
+		return false
 	default:
 		accept := c.Request().Header.Get("Accept")
-		if strings.Contains(accept, "application/json") {
-			// Browsers generally send text/html as an accept header
-			// return c.Render(httpStatus, renderName, output)
-			return c.JSON(httpStatus, output)
-		} else if strings.Contains(accept, "text/html") {
-			// Browsers generally send text/html as an accept header
-			return c.Render(httpStatus, renderName, output)
-		} else {
-			// Default to JSON for any other cases, including 'Accept: */*'
-			return c.JSON(httpStatus, output)
-		}
+		// Browsers generally send text/html as an accept header
+		// Default to JSON for any other cases, including 'Accept: */*'
+		return !strings.Contains(accept, "application/json") && strings.Contains(accept, "text/html")
+	}
+}
+
+// ID assigned to the request by the RequestID middleware
+func requestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
+
+// Tenant of the user making the request, injected by auth middleware from the token's tenant claim.
+// Without auth the header is not set by the server but by clients, requests have no tenant.
+func (rh *RESTHandler) requestTenant(c echo.Context) string {
+	if rh.Config.AuthLevel == 0 {
+		return ""
 	}
+	return c.Request().Header.Get("X-SEPEX-User-Tenant")
+}
+
+// Server logger for the request, every entry carries the request ID.
+// Handlers must use this instead of the package level logger.
+func requestLogger(c echo.Context) *logrus.Entry {
+	return logrus.WithField("request_id", requestID(c))
+}
+
+// Origin of a job: who submitted it, in which tenant, with which request and for which pipeline or fan-out job
+type jobOrigin struct {
+	Submitter string
+	Tenant    string
+	RequestID string
+	// ID of the pipeline or fan-out job the job is a step of, empty for jobs submitted directly
+	ParentID string
+}
+
+// Origin of a job submitted with request c
+func (rh *RESTHandler) requestOrigin(c echo.Context) jobOrigin {
+	return jobOrigin{
+		Submitter: c.Request().Header.Get("X-SEPEX-User-Email"),
+		Tenant:    rh.requestTenant(c),
+		RequestID: requestID(c),
+	}
+}
+
+// Logger with the request ID of the job's origin
+func (o jobOrigin) logger() *logrus.Entry {
+	return logrus.WithField("request_id", o.RequestID)
 }
 
 // runRequestBody provides the required inputs for containerized processes
 // specs: https://developer.ogc.org/api/processes/index.html#tag/Execute
 type runRequestBody struct {
 	Inputs map[string]interface{} `json:"inputs"`
+	// Outputs to return and response type (raw or document), stored with the job
+	Outputs  map[string]interface{} `json:"outputs,omitempty"`
+	Response string                 `json:"response,omitempty"`
+	// Overrides notification settings of the process, not part of OGC specs
+	Notify *pr.Notify `json:"notify,omitempty"`
+	// Env variables for the job, restricted to allowedEnvOverrides of the process, not part of OGC specs
+	Env map[string]string `json:"env,omitempty"`
+	// CPUs and memory for the job, clamped to maxResources of the process, not part of OGC specs
+	Resources *pr.ResourceRequest `json:"resources,omitempty"`
+	// ID of an array input to split into one job per element under a parent job, not part of OGC specs
+	FanOut string `json:"fanOut,omitempty"`
+	// Level of the server logs of the job, set as LOG_LEVEL env variable of the process, not part of OGC specs
+	LogLevel string `json:"logLevel,omitempty"`
 }
 
 // LandingPage godoc
@@ -150,8 +227,56 @@ func (rh *RESTHandler) Conformance(c echo.Context) error {
 	return prepareResponse(c, http.StatusOK, "conformance", output)
 }
 
+// Returns true if the user may execute the process.
+// Admins are allowed to execute all processes. If the process has an access block users need one of its roles or groups,
+// else they need a role with same name as processID.
+func (rh *RESTHandler) processAllowed(c echo.Context, p pr.Process) bool {
+	roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+	if utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+		return true
+	}
+	if p.Access != nil {
+		groups := strings.Split(c.Request().Header.Get("X-SEPEX-User-Groups"), ",")
+		return p.Access.Allows(roles, groups)
+	}
+	return utils.StringInSlice(p.Info.ID, roles)
+}
+
+// Recipients of job notifications, settings in the request override those of the process.
+// Returns an error if the request asks for notifications that can not be sent.
+func (rh *RESTHandler) notifyRecipients(c echo.Context, p pr.Process, notify *pr.Notify) (events.Recipients, error) {
+	if notify != nil {
+		recipients := events.Recipients(*notify)
+		if !recipients.IsZero() {
+			if rh.Notifier == nil {
+				return events.Recipients{}, errors.New("notifications are not configured on this server")
+			}
+			if err := rh.Notifier.Validate(recipients); err != nil {
+				return events.Recipients{}, err
+			}
+		}
+		return recipients, nil
+	}
+
+	if p.Config.Notify == nil || rh.Notifier == nil {
+		return events.Recipients{}, nil
+	}
+	recipients := events.Recipients(*p.Config.Notify)
+	if err := rh.Notifier.Validate(recipients); err != nil {
+		requestLogger(c).Warnf("ignoring notify settings of process %s: %s", p.Info.ID, err.Error())
+		return events.Recipients{}, nil
+	}
+	return recipients, nil
+}
+
 // @Summary Execute Process
 // @Description [Execute Process Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#sc_create_job)
+// @Description Sync jobs that don't finish within SYNC_WAIT_TIMEOUT or the `Prefer: wait=<seconds>` preference are returned as statusInfo with a Location header.
+// @Description Optional `resources` (`cpus`, `memory` in MB) set what the job reserves, values not set use `defaultResources` of the process and values above its `maxResources` are clamped.
+// @Description Optional `logLevel` (`trace`, `debug`, `info`, `warn`, `error`) sets the level of the server logs of the job and the `LOG_LEVEL` env variable of its process.
+// @Description Optional `fanOut` with the ID of an array input runs a job per element under a parent job, the results of the parent list the input, job ID and outputs of every element.
+// @Description With `response: raw` results of successful sync jobs are returned as raw output values instead of a document: a single value with its media type, e.g. a PNG streamed from storage, several values as multipart/related parts with the output ID as Content-ID. `outputs` selects the outputs to return.
+// @Description Bodies larger than MAX_EXECUTE_BODY_KB are rejected with 413, bodies with strings longer than MAX_INPUT_STRING_LENGTH or arrays longer than MAX_INPUT_ARRAY_LENGTH with 422 and the JSON pointer of the value in `path`.
 // @Tags processes
 // @Accept json
 // @Produce json
@@ -172,13 +297,8 @@ func (rh *RESTHandler) Execution(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, errResponse{Message: "'processID' incorrect"})
 	}
 
-	if rh.Config.AuthLevel > 0 {
-		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
-
-		// admins are allowed to execute all processes, else you need to have a role with same name as processId
-		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) && !utils.StringInSlice(processID, roles) {
-			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
-		}
+	if rh.Config.AuthLevel > 0 && !rh.processAllowed(c, p) {
+		return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
 	}
 
 	var params runRequestBody
@@ -187,29 +307,146 @@ func (rh *RESTHandler) Execution(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
 	}
 
+	sub, errResp := rh.newSubmission(c, p, params)
+	if errResp != nil {
+		return c.JSON(errResp.HTTPStatus, *errResp)
+	}
+	return rh.submitJob(c, p, sub)
+}
+
+// Verify the parameters of an execute request of process p and prepare the submission of its job
+func (rh *RESTHandler) newSubmission(c echo.Context, p pr.Process, params runRequestBody) (submission, *errResponse) {
+	badRequest := func(err error) *errResponse {
+		return &errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
+	}
 	if params.Inputs == nil {
-		return c.JSON(http.StatusBadRequest, errResponse{Message: "'inputs' is required in the body of the request"})
+		return submission{}, &errResponse{HTTPStatus: http.StatusBadRequest, Message: "'inputs' is required in the body of the request"}
 	}
 
-	err = p.VerifyInputs(params.Inputs)
+	// Inputs of fan-out requests are verified per element, the array input may have more elements than the input allows
+	var err error
+	if params.FanOut != "" {
+		_, err = p.FanOutInputs(params.FanOut, params.Inputs)
+	} else {
+		err = p.VerifyInputs(params.Inputs)
+	}
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+		return submission{}, badRequest(err)
+	}
+
+	if err := p.VerifyEnvOverrides(params.Env); err != nil {
+		return submission{}, badRequest(err)
+	}
+
+	if err := p.VerifyOutputs(params.Outputs); err != nil {
+		return submission{}, badRequest(err)
+	}
+	resources, err := p.JobResources(params.Resources)
+	if err != nil {
+		return submission{}, badRequest(err)
+	}
+	switch params.Response {
+	case "", "raw", "document":
+	default:
+		return submission{}, &errResponse{HTTPStatus: http.StatusBadRequest, Message: "'response' must be one of raw, document"}
+	}
+	if params.LogLevel != "" && !jobs.ValidLogLevel(params.LogLevel) {
+		return submission{}, &errResponse{HTTPStatus: http.StatusBadRequest, Message: "'logLevel' must be one of trace, debug, info, warn, error"}
+	}
+	var outputs json.RawMessage
+	if params.Outputs != nil {
+		outputs, err = json.Marshal(params.Outputs)
+		if err != nil {
+			return submission{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+		}
+	}
+
+	recipients, err := rh.notifyRecipients(c, p, params.Notify)
+	if err != nil {
+		return submission{}, badRequest(err)
 	}
 
 	jsonParams, err := json.Marshal(params.Inputs)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		return submission{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
 	}
 
-	// If `"Inputs": {}` in `/execution` payload. Nothing will be appended to process commands.
-	// This allow running processes that do not have any inputs.
+	// Parents of fan-out jobs run no command, commands are those of the jobs of the elements
+	var cmd []string
+	if params.FanOut == "" {
+		cmd, err = jobCommand(p, params.Inputs, jsonParams)
+		if err != nil {
+			return submission{}, badRequest(err)
+		}
+	}
+
+	return submission{Inputs: jsonParams, Outputs: outputs, Response: params.Response, Cmd: cmd, Recipients: recipients, Env: params.Env, Resources: resources, FanOut: params.FanOut, LogLevel: params.LogLevel}, nil
+}
+
+// Command of a job of process p with inputs, jsonParams are the JSON encoded inputs.
+// Inputs are rendered into templated commands, otherwise they are appended as a single JSON argument
+// unless they are delivered as a file.
+// If `"Inputs": {}` in `/execution` payload. Nothing will be appended to process commands.
+// This allow running processes that do not have any inputs.
+func jobCommand(p pr.Process, inputs map[string]interface{}, jsonParams []byte) ([]string, error) {
+	if p.IsCommandTemplated() {
+		return p.RenderCommand(inputs)
+	}
 	var cmd = []string{}
 	if p.Command != nil {
 		cmd = append(cmd, p.Command...)
 	}
-	if string(jsonParams) != "{}" {
+	if string(jsonParams) != "{}" && p.Config.InputDelivery != pr.InputDeliveryFile {
 		cmd = append(cmd, string(jsonParams))
 	}
+	return cmd, nil
+}
+
+// submission are the parameters of a new job, from an execute request or a stored job request
+type submission struct {
+	Inputs     json.RawMessage
+	Outputs    json.RawMessage
+	Response   string
+	Cmd        []string
+	Recipients events.Recipients
+	Env        map[string]string
+	Resources  pr.Resources
+	RerunOf    string
+	FanOut     string
+	LogLevel   string
+}
+
+// Whether results of a sync job of submission s are returned as raw output values.
+// Results of fan-out jobs are the list of their jobs, they are always a document.
+func wantsRaw(s submission) bool {
+	return s.Response == "raw" && s.FanOut == ""
+}
+
+// Create a job of process p, store its execution parameters and run or queue it, responding like the execute endpoint
+func (rh *RESTHandler) submitJob(c echo.Context, p pr.Process, s submission) error {
+	res, errResp := rh.runSubmission(c, p, s)
+	if errResp != nil {
+		return c.JSON(errResp.HTTPStatus, *errResp)
+	}
+	if res.Raw {
+		return rh.writeRawResults(c, res.Resp.Outputs, s.Outputs)
+	}
+	return c.JSON(res.HTTPStatus, res.Resp)
+}
+
+// Outcome of a submission, the job response with the status of the execute response.
+// Jobs that were created but did not succeed are outcomes too, their status and message tell what happened.
+type submitResult struct {
+	HTTPStatus int
+	Resp       jobResponse
+	// Results of the job are returned as raw output values
+	Raw bool
+}
+
+// Create a job of process p, store its execution parameters and run or queue it. Sync jobs are waited for.
+// Headers of the execute response are set on the response of c.
+func (rh *RESTHandler) runSubmission(c echo.Context, p pr.Process, s submission) (submitResult, *errResponse) {
+	processID := p.Info.ID
 
 	// Determine execution mode based on process capabilities and client preference
 	// per OGC API - Processes Requirements 25, 26 and Recommendation 12A
@@ -218,9 +455,148 @@ func (rh *RESTHandler) Execution(c echo.Context) error {
 	mode := modeResult.Mode
 	host := p.Host.Type
 
+	// Images of processes registered with IMAGE_LAZY_PULL may still be pulled
+	switch r := rh.ProcessList.ReadinessOf(p); r.State {
+	case pr.ReadinessPulling:
+		c.Response().Header().Set("Retry-After", "30")
+		return submitResult{}, &errResponse{HTTPStatus: http.StatusServiceUnavailable, Message: fmt.Sprintf("The image of process %s is being pulled. Retry later.", processID)}
+	case pr.ReadinessFailed:
+		return submitResult{}, &errResponse{HTTPStatus: http.StatusServiceUnavailable, Message: fmt.Sprintf("The image of process %s could not be pulled: %s", processID, r.Error)}
+	}
+
 	// ----------- Process related setup is complete at this point ---------
 
+	// Re-runs are explicit requests to run a job again, they are never deduplicated
+	inputHash := jobs.InputHash(processID, p.Info.Version, s.Inputs, s.Env, s.FanOut)
+	if p.Config.Deduplicate && s.RerunOf == "" {
+		if res, reused := rh.reuseJob(c, p, s, inputHash, mode); reused {
+			return res, nil
+		}
+	}
+
+	if host == pr.HostPipeline {
+		if errResp := rh.checkPipeline(c, p); errResp != nil {
+			return submitResult{}, errResp
+		}
+	}
+
+	j, errResp := rh.createJob(rh.requestOrigin(c), p, s, mode, inputHash)
+	if errResp != nil {
+		return submitResult{}, errResp
+	}
+	jobID := j.JobID()
+	c.Set(auditResourceIDKey, jobID)
+
+	// Add Preference-Applied header if a preference was honored (Rec 14)
+	if modeResult.PreferenceApplied != "" {
+		c.Response().Header().Set("Preference-Applied", modeResult.PreferenceApplied)
+	}
+
+	resp := jobResponse{ProcessID: j.ProcessID(), Type: "process", JobID: jobID, Status: j.CurrentStatus()}
+	switch mode {
+	case "sync-execute":
+		// Run() blocks until the job ends, it runs in the background so the sync wait timeout can fire.
+		// wgRun.Add(1) is called in Create() so WaitForRunCompletion() blocks correctly
+		go j.Run()
+		if !rh.waitForSyncJob(c, j, parseWaitPreference(preferHeader)) {
+			// Job keeps running, client continues like for an async job
+			c.Response().Header().Set("Location", "/jobs/"+jobID)
+			resp.Status = j.CurrentStatus()
+			resp.Message = "job did not finish within the sync wait timeout, poll the job status for completion"
+			return submitResult{HTTPStatus: http.StatusOK, Resp: resp}, nil
+		}
+		resp.Status = j.CurrentStatus()
+
+		if resp.Status == "successful" {
+			var outputs interface{}
+
+			// Results of fan-out jobs list the jobs of the elements even if the process has no outputs
+			if p.Outputs != nil || s.FanOut != "" {
+				var err error
+				outputs, err = jobs.FetchResults(rh.StorageSvc, j.JobID(), j.TENANT())
+				if err != nil {
+					resp.Message = "error fetching results. Error: " + err.Error()
+					return submitResult{HTTPStatus: http.StatusInternalServerError, Resp: resp}, nil
+				}
+			}
+			resp.Outputs = p.LinkOutputs(outputs)
+			if wantsRaw(s) {
+				c.Response().Header().Set("Location", "/jobs/"+jobID)
+				return submitResult{HTTPStatus: http.StatusOK, Resp: resp, Raw: true}, nil
+			}
+			return submitResult{HTTPStatus: http.StatusOK, Resp: resp}, nil
+		} else {
+			resp.Message = "job unsuccessful. Call logs route for details"
+			return submitResult{HTTPStatus: http.StatusInternalServerError, Resp: resp}, nil
+		}
+	case "async-execute":
+		rh.startJob(j, p.Host.Type)
+		resp.Status = j.CurrentStatus()
+		return submitResult{HTTPStatus: http.StatusCreated, Resp: resp}, nil
+	default:
+		resp := jobResponse{ProcessID: j.ProcessID(), Type: "process", JobID: jobID, Status: "0", Message: "incorrect controller option defined in process configuration"}
+		return submitResult{HTTPStatus: http.StatusInternalServerError, Resp: resp}, nil
+	}
+}
+
+// How jobs of process p capture their results from stdout, nil if they report them in the process logs
+func stdoutResults(p pr.Process) *jobs.StdoutResults {
+	if p.Config.ResultsFrom != pr.ResultsFromStdout {
+		return nil
+	}
+	return &jobs.StdoutResults{Marker: p.Config.ResultsMarker}
+}
+
+// Scoped storage access of jobs of process p, nil if they don't get scoped storage credentials
+func storageAccess(p pr.Process, jobID, tenant string, inputs json.RawMessage) *jobs.StorageAccess {
+	if !p.Config.ScopedCredentials {
+		return nil
+	}
+	return jobs.NewStorageAccess(jobID, tenant, inputs)
+}
+
+// Create a job of process p and store its execution parameters, the job is not started.
+// Returns the response to send if the job could not be created.
+func (rh *RESTHandler) createJob(o jobOrigin, p pr.Process, s submission, mode, inputHash string) (jobs.Job, *errResponse) {
+	processID := p.Info.ID
+	cmd := s.Cmd
+	host := p.Host.Type
+
+	// Only async local jobs wait in the queue, see MAX_QUEUE_LENGTH
+	if mode == "async-execute" && s.FanOut == "" && rh.queuedHost(host) {
+		if maxQueue := config.Get().Jobs.MaxQueueLength; maxQueue > 0 && rh.PendingJobs.Len() >= maxQueue {
+			stats := rh.PendingJobs.Stats()
+			return nil, &errResponse{
+				HTTPStatus: http.StatusServiceUnavailable,
+				Message: fmt.Sprintf("The local job queue is full (%d jobs waiting, %d of them for process %s, the oldest for %s). Retry later.",
+					maxQueue, stats.ByProcess[processID], processID, formatAge(stats.OldestAge)),
+			}
+		}
+	}
+
+	// Local jobs write logs and scratch files to local disk, see JOB_DISK_QUOTA_MB
+	if rh.queuedHost(host) {
+		if reason := rh.DiskMonitor.NearlyFull(); reason != "" {
+			rh.DiskMonitor.RequestClean()
+			return nil, &errResponse{
+				HTTPStatus: http.StatusInsufficientStorage,
+				Message:    fmt.Sprintf("Local disk of job logs and scratch directories is nearly full (%s). Files of finished jobs are being cleaned up, retry later.", reason),
+			}
+		}
+	}
+
+	// Every job is tracked as an active job until it is closed, see MAX_ACTIVE_JOBS
+	if maxActive := config.Get().Jobs.MaxActiveJobs; maxActive > 0 && rh.ActiveJobs.Len() >= maxActive {
+		stats := rh.ActiveJobs.Stats()
+		return nil, &errResponse{
+			HTTPStatus: http.StatusServiceUnavailable,
+			Message: fmt.Sprintf("The server has reached its limit of %d active jobs (%d of them for process %s, the oldest active for %s). Retry later.",
+				maxActive, stats.ByProcess[processID], processID, formatAge(stats.OldestAge)),
+		}
+	}
+
 	jobID := uuid.New().String()
+	parentID := o.ParentID
 
 	// switch host {
 	// case "docker":
@@ -229,126 +605,230 @@ func (rh *RESTHandler) Execution(c echo.Context) error {
 	// 	params.Inputs["resultsCallbackUri"] = fmt.Sprintf("%s/jobs/%s/results_update", os.Getenv("API_URL_PUBLIC"), jobID)
 	// }
 
-	submitter := c.Request().Header.Get("X-SEPEX-User-Email")
+	submitter, tenant := o.Submitter, o.Tenant
+	if err := jobs.ValidateTenant(tenant); err != nil {
+		return nil, &errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
+	}
+	errorPatterns := make([]jobs.ErrorPattern, len(p.Config.ErrorPatterns))
+	for i, ep := range p.Config.ErrorPatterns {
+		errorPatterns[i] = jobs.ErrorPattern(ep)
+	}
+	processEnv, processEnvFrom := p.JobEnv()
+	envVars := make([]jobs.EnvVar, len(processEnv))
+	for i, ev := range processEnv {
+		envVars[i] = jobs.EnvVar(ev)
+	}
+	envVarsFrom := make([]jobs.EnvVarFrom, len(processEnvFrom))
+	for i, ev := range processEnvFrom {
+		envVarsFrom[i] = jobs.EnvVarFrom(ev)
+	}
+	var inputsFile json.RawMessage
+	if p.Config.InputDelivery == pr.InputDeliveryFile {
+		inputsFile = s.Inputs
+	}
 	var j jobs.Job
-	switch host {
-	case "docker":
-		j = &jobs.DockerJob{
+	switch {
+	case s.FanOut != "":
+		var inputs map[string]interface{}
+		if err := json.Unmarshal(s.Inputs, &inputs); err != nil {
+			return nil, &errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
+		}
+		j = &jobs.PipelineJob{
 			UUID:           jobID,
 			ProcessName:    processID,
 			ProcessVersion: p.Info.Version,
-			Image:          p.Host.Image,
 			Submitter:      submitter,
-			EnvVars:        p.Config.EnvVars,
-			Volumes:        p.Config.Volumes,
-			Resources:      jobs.Resources(p.Config.Resources),
-			Cmd:            cmd,
-			StorageSvc:     rh.StorageSvc,
+			Tenant:         tenant,
+			RequestID:      o.RequestID,
+			RerunOf:        s.RerunOf,
+			ParentID:       parentID,
+			LogLevel:       s.LogLevel,
+			Inputs:         inputs,
+			Steps:          []string{s.FanOut},
 			DB:             rh.DB,
-			DoneChan:       rh.MessageQueue.JobDone,
-			ResourcePool:   rh.ResourcePool,
+			Events:         rh.EventBus,
+			JobEvents:      rh.JobEvents,
+			StorageSvc:     rh.StorageSvc,
+			ActiveJobs:     rh.ActiveJobs,
+			Runner:         &fanOutRunner{rh: rh, p: p, input: s.FanOut, resources: s.Resources, env: s.Env},
 			IsSync:         mode == "sync-execute",
 		}
 
-	case "aws-batch":
-		j = &jobs.AWSBatchJob{
-			UUID:           jobID,
-			ProcessName:    processID,
-			Image:          p.Host.Image,
-			Submitter:      submitter,
-			EnvVars:        p.Config.EnvVars,
-			Cmd:            cmd,
-			JobDef:         p.Host.JobDefinition,
-			JobQueue:       p.Host.JobQueue,
-			JobName:        fmt.Sprintf("%s_%s", rh.Name, jobID),
-			ProcessVersion: p.Info.Version,
-			StorageSvc:     rh.StorageSvc,
-			DB:             rh.DB,
-			DoneChan:       rh.MessageQueue.JobDone,
+	case host == pr.HostPipeline:
+		var inputs map[string]interface{}
+		if err := json.Unmarshal(s.Inputs, &inputs); err != nil {
+			return nil, &errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
 		}
-
-	case "subprocess":
-		j = &jobs.SubprocessJob{
+		j = &jobs.PipelineJob{
 			UUID:           jobID,
 			ProcessName:    processID,
+			ProcessVersion: p.Info.Version,
 			Submitter:      submitter,
-			EnvVars:        p.Config.EnvVars,
+			Tenant:         tenant,
+			RequestID:      o.RequestID,
+			RerunOf:        s.RerunOf,
+			ParentID:       parentID,
+			LogLevel:       s.LogLevel,
+			Inputs:         inputs,
+			Steps:          p.Pipeline.StepIDs(),
 			Cmd:            cmd,
-			ProcessVersion: p.Info.Version,
-			Resources:      jobs.Resources(p.Config.Resources),
-			StorageSvc:     rh.StorageSvc,
 			DB:             rh.DB,
-			DoneChan:       rh.MessageQueue.JobDone,
-			ResourcePool:   rh.ResourcePool,
+			Events:         rh.EventBus,
+			JobEvents:      rh.JobEvents,
+			StorageSvc:     rh.StorageSvc,
+			ActiveJobs:     rh.ActiveJobs,
+			Runner:         &pipelineRunner{rh: rh, p: p},
 			IsSync:         mode == "sync-execute",
 		}
+
+	default:
+		backend, ok := jobs.GetBackend(host)
+		if !ok {
+			return nil, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: fmt.Sprintf("no job backend registered for host type %s", host)}
+		}
+		var err error
+		j, err = backend.NewJob(jobs.JobSpec{
+			JobID:           jobID,
+			ProcessID:       processID,
+			ProcessVersion:  p.Info.Version,
+			Image:           p.Host.Image,
+			Platform:        p.Host.Platform,
+			PullAuth:        p.PullAuth(),
+			JobDefinition:   p.Host.JobDefinition,
+			JobQueue:        p.Host.JobQueue,
+			Submitter:       submitter,
+			Tenant:          tenant,
+			RequestID:       o.RequestID,
+			RerunOf:         s.RerunOf,
+			ParentID:        parentID,
+			LogLevel:        s.LogLevel,
+			Cmd:             cmd,
+			EnvVars:         envVars,
+			EnvVarsFrom:     envVarsFrom,
+			EnvOverrides:    s.Env,
+			Volumes:         p.Config.Volumes,
+			InputsFile:      inputsFile,
+			Resources:       jobs.Resources(s.Resources),
+			ErrorPatterns:   errorPatterns,
+			OutputTypes:     p.OutputMediaTypes(),
+			OutputSchemas:   p.ResultsValidator(),
+			StdoutResults:   stdoutResults(p),
+			Callback:        rh.jobCallback(jobID),
+			StorageAccess:   storageAccess(p, jobID, tenant, s.Inputs),
+			Security:        p.DockerSecurity(),
+			ImageOverrides:  p.DockerImageOverrides(),
+			IsSync:          mode == "sync-execute",
+			StopGracePeriod: p.Config.StopGrace(),
+			MaxResubmits:    p.Host.ResubmitInterrupted,
+		}, rh.jobServices())
+		if err != nil {
+			o.logger().Errorf("could not create job %s: %s", jobID, err.Error())
+			return nil, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: fmt.Sprintf("submission error %s", err.Error())}
+		}
 	}
 
 	// Create job (reserves resources for sync docker/subprocess jobs)
-	err = j.Create()
+	err := j.Create()
 	if err != nil {
 		if err.Error() == "resources unavailable" {
 			// Only sync jobs can fail with this error
-			return c.JSON(http.StatusServiceUnavailable, errResponse{
-				Message: "Server resources are backlogged for local job execution. Use async-execute mode (if available for this process) or retry later.",
-			})
+			return nil, &errResponse{
+				HTTPStatus: http.StatusServiceUnavailable,
+				Message:    "Server resources are backlogged for local job execution. Use async-execute mode (if available for this process) or retry later.",
+			}
 		}
-		return c.JSON(http.StatusInternalServerError, errResponse{Message: fmt.Sprintf("submission error %s", err.Error())})
+		o.logger().Errorf("could not create job %s: %s", jobID, err.Error())
+		return nil, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: fmt.Sprintf("submission error %s", err.Error())}
 	}
 
 	// Add to active jobs
 	rh.ActiveJobs.Add(&j)
 
-	// Add Preference-Applied header if a preference was honored (Rec 14)
-	if modeResult.PreferenceApplied != "" {
-		c.Response().Header().Set("Preference-Applied", modeResult.PreferenceApplied)
+	if !s.Recipients.IsZero() {
+		rh.Notifier.Register(jobID, s.Recipients, time.Now())
 	}
 
-	resp := jobResponse{ProcessID: j.ProcessID(), Type: "process", JobID: jobID, Status: j.CurrentStatus()}
-	switch mode {
-	case "sync-execute":
-		j.Run()
-		// wgRun.Add(1) is called in Create() so WaitForRunCompletion() blocks correctly
-		j.WaitForRunCompletion()
-		resp.Status = j.CurrentStatus()
+	// Stored after the job record so that requests are not kept for jobs that failed to be created
+	jr := jobs.JobRequest{
+		JobID: jobID, ProcessID: processID, ProcessVersion: p.Info.Version, Inputs: s.Inputs, Command: cmd, RerunOf: s.RerunOf,
+		Outputs: s.Outputs, Response: s.Response, Mode: mode, InputHash: inputHash, CPUs: s.Resources.CPUs, Memory: s.Resources.Memory,
+		FanOut: s.FanOut, LogLevel: s.LogLevel,
+	}
+	if err := rh.DB.AddJobRequest(jr); err != nil {
+		o.logger().Errorf("could not store execution parameters of job %s: %s", jobID, err.Error())
+	}
+	return j, nil
+}
 
-		if resp.Status == "successful" {
-			var outputs interface{}
+// Start an async job of a process of host type host
+func (rh *RESTHandler) startJob(j jobs.Job, host string) {
+	if _, ok := j.(*jobs.PipelineJob); ok {
+		// Pipelines only wait for the jobs of their steps
+		go j.Run()
+		return
+	}
+	// Only queue jobs that need local resources, e.g. AWS Batch jobs auto-start in Create(), no queuing needed
+	if rh.queuedHost(host) {
+		// Track queued resources, add to queue, and notify worker
+		res := j.GetResources()
+		rh.ResourcePool.AddQueued(res.CPUs, res.Memory)
+		rh.PendingJobs.Enqueue(&j)
+		rh.QueueWorker.NotifyNewJob()
+	}
+}
 
-			if p.Outputs != nil {
-				outputs, err = jobs.FetchResults(rh.StorageSvc, j.JobID())
-				if err != nil {
-					resp.Message = "error fetching results. Error: " + err.Error()
-					return c.JSON(http.StatusInternalServerError, resp)
-				}
-			}
-			resp.Outputs = outputs
-			return c.JSON(http.StatusOK, resp)
-		} else {
-			resp.Message = "job unsuccessful. Call logs route for details"
-			return c.JSON(http.StatusInternalServerError, resp)
-		}
-	case "async-execute":
-		// Only queue Docker/Subprocess jobs that need local resources
-		// AWS Batch auto-starts in Create(), no queuing needed
-		switch j.(type) {
-		case *jobs.DockerJob, *jobs.SubprocessJob:
-			// Track queued resources, add to queue, and notify worker
-			res := j.GetResources()
-			rh.ResourcePool.AddQueued(res.CPUs, res.Memory)
-			rh.PendingJobs.Enqueue(&j)
-			rh.QueueWorker.NotifyNewJob()
-		}
-		resp.Status = j.CurrentStatus()
-		return c.JSON(http.StatusCreated, resp)
-	default:
-		resp := jobResponse{ProcessID: j.ProcessID(), Type: "process", JobID: jobID, Status: "0", Message: "incorrect controller option defined in process configuration"}
-		return c.JSON(http.StatusInternalServerError, resp)
+// Jobs of backends that need local resources wait in the queue
+func (rh *RESTHandler) queuedHost(host string) bool {
+	backend, ok := jobs.GetBackend(host)
+	return ok && backend.Queued()
+}
+
+// Services of the server passed to job backends
+func (rh *RESTHandler) jobServices() jobs.JobServices {
+	return jobs.JobServices{
+		APIName:      rh.Name,
+		DB:           rh.DB,
+		Events:       rh.EventBus,
+		StorageSvc:   rh.StorageSvc,
+		ActiveJobs:   rh.ActiveJobs,
+		ResourcePool: rh.ResourcePool,
+		Docker:       rh.Docker,
+	}
+}
+
+// Wait for a sync job to finish for at most the configured sync wait timeout or the client's wait preference, whichever is shorter.
+// Returns false if the job has not finished in time.
+func (rh *RESTHandler) waitForSyncJob(c echo.Context, j jobs.Job, preferredWait time.Duration) bool {
+	timeout := rh.Config.SyncWaitTimeout
+	if preferredWait > 0 && (timeout == 0 || preferredWait < timeout) {
+		timeout = preferredWait
+		c.Response().Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(timeout.Seconds())))
+	}
+	if timeout == 0 {
+		j.WaitForRunCompletion()
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		j.WaitForRunCompletion()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
 	}
 }
 
 // @Summary Dismiss Job
 // @Description [Dismss Job Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#ats_dismiss)
+// @Description Active jobs are dismissed. Finished jobs are archived, their metadata and logs are moved under the archive prefix
+// @Description and they are hidden from job lists until restored by an admin with `POST /admin/jobs/{jobID}/restore`.
 // @Tags jobs
 // @Accept */*
 // @Produce json
@@ -356,36 +836,38 @@ func (rh *RESTHandler) Execution(c echo.Context) error {
 // @Router /jobs/{jobID} [delete]
 // Does not produce HTML
 func (rh *RESTHandler) JobDismissHandler(c echo.Context) error {
-	jobID := c.Param("jobID")
+	resp, errResp := rh.dismissOrArchiveJob(c, c.Param("jobID"))
+	if errResp != nil {
+		return c.JSON(errResp.HTTPStatus, *errResp)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
 
-	// 1. Check if job exists in active jobs
-	j, ok := rh.ActiveJobs.Jobs[jobID]
+// Dismiss an active job or archive a finished one, ownership is checked by JobOwner middleware
+func (rh *RESTHandler) dismissOrArchiveJob(c echo.Context, jobID string) (jobResponse, *errResponse) {
+	// 1. Check if job exists in active jobs, finished jobs are archived
+	j, ok := rh.ActiveJobs.Get(jobID)
 	if !ok {
-		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("job %s not in the active jobs list", jobID)})
+		return rh.archiveJob(c, jobID)
 	}
 
-	// 2. Check auth
-	if rh.Config.AuthLevel > 0 {
-		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
-		if (*j).SUBMITTER() != c.Request().Header.Get("X-SEPEX-User-Email") && !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
-			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
-		}
+	// 2. Dequeue and kill the job
+	err := rh.dismissJob(j)
+	if err != nil {
+		return jobResponse{}, &errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
 	}
+	return jobResponse{ProcessID: (*j).ProcessID(), Type: "process", JobID: jobID, Status: (*j).CurrentStatus(), Message: fmt.Sprintf("job %s dismissed", jobID)}, nil
+}
 
-	// 3. Remove from pending queue if it exists there (job hasn't started yet)
-	removed := rh.PendingJobs.Remove(jobID)
+// Remove job from pending queue if it exists there (job hasn't started yet) and kill it
+func (rh *RESTHandler) dismissJob(j *jobs.Job) error {
+	removed := rh.PendingJobs.Remove((*j).JobID())
 	if removed != nil {
 		// Job was in queue - update queued resource tracking
 		res := (*removed).GetResources()
 		rh.ResourcePool.RemoveQueued(res.CPUs, res.Memory)
 	}
-
-	// 4. Kill the job
-	err := (*j).Kill()
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
-	}
-	return c.JSON(http.StatusOK, jobResponse{ProcessID: (*j).ProcessID(), Type: "process", JobID: jobID, Status: (*j).CurrentStatus(), Message: fmt.Sprintf("job %s dismissed", jobID)})
+	return (*j).Kill()
 }
 
 // @Summary Job Status
@@ -405,20 +887,41 @@ func (rh *RESTHandler) JobStatusHandler(c echo.Context) (err error) {
 
 	var jRcrd jobs.JobRecord
 	jobID := c.Param("jobID")
-	if job, ok := rh.ActiveJobs.Jobs[jobID]; ok {
+	if job, ok := rh.ActiveJobs.Get(jobID); ok {
 		resp := jobResponse{
 			ProcessID:  (*job).ProcessID(),
 			JobID:      (*job).JobID(),
 			LastUpdate: (*job).LastUpdate(),
 			Status:     (*job).CurrentStatus(),
+			ParentID:   (*job).PARENT(),
+		}
+		if p, ok := rh.JobEvents.LastProgress(jobID); ok {
+			resp.Progress = p.Progress
+		}
+		if fc, ok := (*job).(jobs.FailureClassifier); ok {
+			resp.FailureClass = fc.FailureClassification()
 		}
+		if er, ok := (*job).(jobs.ExitReporter); ok {
+			resp.ExitCode, resp.OOMKilled = er.ExitDetail()
+		}
+		if ar, ok := (*job).(jobs.AttemptReporter); ok {
+			resp.Attempts = ar.Attempts()
+		}
+		resp.Notes = rh.jobNotes(c, jobID)
 		return prepareResponse(c, http.StatusOK, "jobStatus", resp)
 	} else if jRcrd, ok, err = rh.DB.GetJob(jobID); ok {
 		resp := jobResponse{
-			ProcessID:  jRcrd.ProcessID,
-			JobID:      jRcrd.JobID,
-			LastUpdate: jRcrd.LastUpdate,
-			Status:     jRcrd.Status,
+			ProcessID:    jRcrd.ProcessID,
+			JobID:        jRcrd.JobID,
+			LastUpdate:   jRcrd.LastUpdate,
+			Status:       jRcrd.Status,
+			FailureClass: jRcrd.FailureClass,
+			ExitCode:     jRcrd.ExitCode,
+			OOMKilled:    jRcrd.OOMKilled,
+			Archived:     jRcrd.Archived,
+			ParentID:     jRcrd.ParentID,
+			Notes:        rh.jobNotes(c, jobID),
+			Attempts:     jRcrd.Attempts,
 		}
 		return prepareResponse(c, http.StatusOK, "jobStatus", resp)
 	}
@@ -431,12 +934,25 @@ func (rh *RESTHandler) JobStatusHandler(c echo.Context) (err error) {
 	return prepareResponse(c, http.StatusNotFound, "error", output)
 }
 
+// Results of a job with outputs declaring a media type as links, see Process.LinkOutputs.
+// Results are returned unchanged if the process was deleted.
+func (rh *RESTHandler) linkOutputs(processID string, outputs interface{}) interface{} {
+	p, _, err := rh.ProcessList.Get(processID)
+	if err != nil {
+		return outputs
+	}
+	return p.LinkOutputs(outputs)
+}
+
 // @Summary Job Results
 // @Description [Job Results Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#sc_retrieve_job_results)
+// @Description For failed and dismissed jobs the latest results the process reported are returned with `partial: true`, 404 if it reported none.
+// @Description Results of successful jobs executed with `response: raw` are returned as raw output values, see the execute endpoint.
 // @Tags jobs
 // @Accept */*
 // @Produce json
 // @Param jobID path string true "ex: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param token query string false "result link token from /jobs/{jobID}/results/share, allows access without credentials"
 // @Success 200 {object} map[string]interface{}
 // @Router /jobs/{jobID}/results [get]
 // Does not produce HTML
@@ -448,15 +964,18 @@ func (rh *RESTHandler) JobResultsHandler(c echo.Context) (err error) {
 
 	var jRcrd jobs.JobRecord
 	jobID := c.Param("jobID")
-	if job, ok := rh.ActiveJobs.Jobs[jobID]; ok { // ActiveJobs hit
+	if job, ok := rh.ActiveJobs.Get(jobID); ok { // ActiveJobs hit
 		output := errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("results not ready, job %s", (*job).CurrentStatus())}
 		return prepareResponse(c, http.StatusNotFound, "error", output)
 
 	} else if jRcrd, ok, err = rh.DB.GetJob(jobID); ok { // db hit
+		if jRcrd.Archived != nil {
+			return prepareResponse(c, http.StatusGone, "error", archivedJobError(jobID))
+		}
 
 		switch jRcrd.Status {
 		case jobs.SUCCESSFUL:
-			outputs, err := jobs.FetchResults(rh.StorageSvc, jRcrd.JobID)
+			outputs, err := jobs.FetchResults(rh.StorageSvc, jRcrd.JobID, jRcrd.Tenant)
 			if err != nil {
 				if err.Error() == "not found" {
 					output := errResponse{HTTPStatus: http.StatusNotFound, Message: "results not available"}
@@ -465,12 +984,45 @@ func (rh *RESTHandler) JobResultsHandler(c echo.Context) (err error) {
 				output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
 				return prepareResponse(c, http.StatusInternalServerError, "error", output)
 			}
+			outputs, errResp := rh.tokenScopedResults(c, jobID, rh.linkOutputs(jRcrd.ProcessID, outputs))
+			if errResp != nil {
+				return prepareResponse(c, errResp.HTTPStatus, "error", *errResp)
+			}
+			if !respondsHTML(c) {
+				if jr, ok, err := rh.DB.GetJobRequest(jobID); err == nil && ok && jr.Response == "raw" && jr.FanOut == "" {
+					// Result link tokens already select the output of the link
+					requested := jr.Outputs
+					if c.QueryParam(resultTokenParam) != "" {
+						requested = nil
+					}
+					return rh.writeRawResults(c, outputs, requested)
+				}
+			}
 			output := jobResponse{JobID: jobID, Outputs: outputs}
-			return prepareResponse(c, http.StatusOK, "jobResults", output)
+			if respondsHTML(c) {
+				return prepareResponse(c, http.StatusOK, "jobResults", output)
+			}
+			return jsonWithETag(c, http.StatusOK, output)
 
 		case jobs.FAILED, jobs.DISMISSED:
-			output := errResponse{HTTPStatus: http.StatusNotFound, Message: "job Failed or Dismissed. Call logs route for details"}
-			return prepareResponse(c, http.StatusNotFound, "error", output)
+			// Results reported before the job stopped are returned flagged as partial, users decide if they are usable
+			outputs, err := jobs.FetchPartialResults(rh.StorageSvc, jRcrd.JobID, jRcrd.Tenant)
+			if err != nil {
+				output := errResponse{HTTPStatus: http.StatusNotFound, Message: "job Failed or Dismissed. Call logs route for details"}
+				return prepareResponse(c, http.StatusNotFound, "error", output)
+			}
+			outputs, errResp := rh.tokenScopedResults(c, jobID, rh.linkOutputs(jRcrd.ProcessID, outputs))
+			if errResp != nil {
+				return prepareResponse(c, errResp.HTTPStatus, "error", *errResp)
+			}
+			output := jobResponse{
+				JobID: jobID, Status: jRcrd.Status, Outputs: outputs, Partial: true,
+				Message: fmt.Sprintf("job %s, results reported before it stopped may be incomplete", strings.ToLower(jRcrd.Status)),
+			}
+			if respondsHTML(c) {
+				return prepareResponse(c, http.StatusOK, "jobResults", output)
+			}
+			return jsonWithETag(c, http.StatusOK, output)
 
 		default:
 			output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: "job status out of sync in database"}
@@ -489,6 +1041,24 @@ func (rh *RESTHandler) JobResultsHandler(c echo.Context) (err error) {
 	return prepareResponse(c, http.StatusNotFound, "error", output)
 }
 
+// Limit results to the output of the result link token in the request, if any.
+// Returns an error response if the token is invalid or its output does not exist.
+func (rh *RESTHandler) tokenScopedResults(c echo.Context, jobID string, outputs interface{}) (interface{}, *errResponse) {
+	token := c.QueryParam(resultTokenParam)
+	if token == "" {
+		return outputs, nil
+	}
+	rt, err := rh.verifyResultToken(token, jobID)
+	if err != nil {
+		return nil, &errResponse{HTTPStatus: http.StatusForbidden, Message: err.Error()}
+	}
+	outputs, ok := scopeResults(outputs, rt.OutputID)
+	if !ok {
+		return nil, &errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("output %s not found", rt.OutputID)}
+	}
+	return outputs, nil
+}
+
 // @Summary Job Metadata
 // @Description Provides metadata associated with a job
 // @Tags jobs
@@ -507,14 +1077,32 @@ func (rh *RESTHandler) JobMetaDataHandler(c echo.Context) (err error) {
 	var jRcrd jobs.JobRecord
 
 	jobID := c.Param("jobID")
-	if job, ok := rh.ActiveJobs.Jobs[jobID]; ok { // ActiveJobs hit
+	if job, ok := rh.ActiveJobs.Get(jobID); ok { // ActiveJobs hit
 		output := errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("metadata not ready, job %s", (*job).CurrentStatus())}
 		return prepareResponse(c, http.StatusNotFound, "error", output)
 
 	} else if jRcrd, ok, err = rh.DB.GetJob(jobID); ok { // db hit
+		if jRcrd.Archived != nil {
+			return prepareResponse(c, http.StatusGone, "error", archivedJobError(jobID))
+		}
 		switch jRcrd.Status {
 		case jobs.SUCCESSFUL:
-			md, err := jobs.FetchMeta(rh.StorageSvc, jobID)
+			// JSON is streamed from storage as stored, conditional requests are answered by storage
+			if !respondsHTML(c) {
+				out, unchanged, err := utils.GetS3ObjectIfNoneMatch(jobs.MetadataStorageKey(jobID, jRcrd.Tenant), "", c.Request().Header.Get("If-None-Match"), rh.StorageSvc)
+				var aerr awserr.Error
+				if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+					return c.JSON(http.StatusInternalServerError, errResponse{Message: "metadata not found"})
+				}
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+				}
+				if unchanged {
+					return notModified(c)
+				}
+				return streamS3Object(c, out, echo.MIMEApplicationJSON)
+			}
+			md, err := jobs.FetchMeta(rh.StorageSvc, jobID, jRcrd.Tenant)
 			if err != nil {
 				if err.Error() == "not found" {
 					output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: "metadata not found"}
@@ -546,28 +1134,155 @@ func (rh *RESTHandler) JobMetaDataHandler(c echo.Context) (err error) {
 	return prepareResponse(c, http.StatusNotFound, "error", output)
 }
 
-// @Summary Job Logs
-// @Description
+// @Summary Job Resource Usage
+// @Description Provides CPU, memory, network and disk usage sampled while a docker job runs.
+// @Description For running jobs usage sampled so far is returned, for finished jobs it is read from job metadata.
 // @Tags jobs
 // @Accept */*
 // @Produce json
 // @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobs.ResourceUsage
+// @Router /jobs/{jobID}/usage [get]
+// Does not produce HTML
+func (rh *RESTHandler) JobUsageHandler(c echo.Context) (err error) {
+	jobID := c.Param("jobID")
+	if job, ok := rh.ActiveJobs.Get(jobID); ok { // ActiveJobs hit
+		ur, ok := (*job).(jobs.UsageReporter)
+		if !ok {
+			return c.JSON(http.StatusNotFound, errResponse{Message: "usage metrics are only available for docker jobs"})
+		}
+		return c.JSON(http.StatusOK, ur.ResourceUsage())
+	}
+
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("%s job id not found", jobID)})
+	}
+	if jRcrd.Archived != nil {
+		return c.JSON(http.StatusGone, archivedJobError(jobID))
+	}
+	if jRcrd.Status != jobs.SUCCESSFUL {
+		return c.JSON(http.StatusNotFound, errResponse{Message: "job Failed or Dismissed. Usage only available for running and successful jobs"})
+	}
+
+	md, err := jobs.FetchMeta(rh.StorageSvc, jobID, jRcrd.Tenant)
+	if err != nil {
+		if err.Error() == "not found" {
+			return c.JSON(http.StatusNotFound, errResponse{Message: "metadata not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+
+	mdMap, _ := md.(map[string]interface{})
+	usage, ok := mdMap["usage"]
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: "usage metrics are only available for docker jobs"})
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+// Maximum number of log entries that can be requested per page
+const maxLogsLimit = 10000
+
+// Number of last entries of each source returned by the logs route without query, full logs are at /logs/raw
+const defaultLogsTail = 1000
+
+// Parse and validate query parameters of logs route.
+func parseLogQuery(c echo.Context) (jobs.LogQuery, error) {
+	var q jobs.LogQuery
+
+	q.Source = c.QueryParam("source")
+	if !utils.StringInSlice(q.Source, jobs.LogSources) {
+		return q, fmt.Errorf("invalid option for query parameter 'source'. Valid options are 'process' or 'server'")
+	}
+
+	if levels := c.QueryParam("level"); levels != "" {
+		for _, l := range strings.Split(levels, ",") {
+			lvl, ok := jobs.NormalizeLogLevel(l)
+			if !ok {
+				return q, fmt.Errorf("invalid option %q for query parameter 'level'", l)
+			}
+			q.Levels = append(q.Levels, lvl)
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return q, fmt.Errorf("query parameter 'offset' must be a non-negative integer")
+		}
+		q.Offset = offset
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLogsLimit {
+			return q, fmt.Errorf("query parameter 'limit' must be an integer between 1 and %d", maxLogsLimit)
+		}
+		q.Limit = limit
+	}
+
+	if tailStr := c.QueryParam("tail"); tailStr != "" {
+		tail, err := strconv.Atoi(tailStr)
+		if err != nil || tail < 1 || tail > maxLogsLimit {
+			return q, fmt.Errorf("query parameter 'tail' must be an integer between 1 and %d", maxLogsLimit)
+		}
+		if q.Offset > 0 || q.Limit > 0 {
+			return q, fmt.Errorf("query parameter 'tail' can not be combined with 'offset' or 'limit'")
+		}
+		q.Tail = tail
+	}
+
+	return q, nil
+}
+
+// @Summary Job Logs
+// @Description Logs of the job. Filters and pagination are applied to each log source independently.
+// @Description If no pagination is requested the last 1000 entries of each source are returned, `*_total` has the number of
+// @Description all entries. Complete log files are returned by `GET /jobs/{jobID}/logs/raw`.
+// @Description Logs are returned as JSON, an HTML page with colorized levels, NDJSON with one entry per line or plain text
+// @Description per query parameter `f` or else the Accept header (`application/x-ndjson`, `text/plain`). NDJSON and plain
+// @Description text merge the entries of all sources ordered by time, each entry has its source.
+// @Tags jobs
+// @Accept */*
+// @Produce json,html,application/x-ndjson,plain
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param source query string false "process | server, default both"
+// @Param level query string false "comma separated levels, example: error,warning"
+// @Param offset query int false "number of entries to skip"
+// @Param limit query int false "maximum number of entries to return"
+// @Param tail query int false "number of last entries to return, default 1000 without offset and limit"
+// @Param f query string false "json | html | ndjson | text"
 // @Success 200 {object} jobs.JobLogs
 // @Router /jobs/{jobID}/logs [get]
 func (rh *RESTHandler) JobLogsHandler(c echo.Context) (err error) {
 	jobID := c.Param("jobID")
 
-	err = validateFormat(c)
+	format, ok := logsFormat(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "Invalid option for query parameter 'f'. Valid options are 'json', 'html', 'ndjson' or 'text'. Default (i.e. not specified) is per the Accept header, json if it accepts none of them."})
+	}
+
+	query, err := parseLogQuery(c)
 	if err != nil {
-		return err
+		output := errResponse{HTTPStatus: http.StatusBadRequest, Message: err.Error()}
+		return prepareResponse(c, http.StatusBadRequest, "error", output)
+	}
+	// Without pagination only the tail is read into memory
+	if c.QueryParam("offset") == "" && query.Limit == 0 && query.Tail == 0 {
+		query.Tail = defaultLogsTail
 	}
 
-	var pid, status string
+	var pid, status, tenant string
 	var jRcrd jobs.JobRecord
 
-	if job, ok := rh.ActiveJobs.Jobs[jobID]; ok { // ActiveJobs hit
+	if job, ok := rh.ActiveJobs.Get(jobID); ok { // ActiveJobs hit
 		pid = (*job).ProcessID()
 		status = (*job).CurrentStatus()
+		tenant = (*job).TENANT()
 		if status == jobs.ACCEPTED { // this prevents AWS Cloudwatch errors where logs are not available till some time after job is started
 			output := errResponse{HTTPStatus: http.StatusBadRequest, Message: "Logs will be available after the job has reached running state."}
 			return prepareResponse(c, http.StatusBadRequest, "error", output)
@@ -577,17 +1292,22 @@ func (rh *RESTHandler) JobLogsHandler(c echo.Context) (err error) {
 	} else if jRcrd, ok, err = rh.DB.GetJob(jobID); ok { // db hit
 		pid = jRcrd.ProcessID
 		status = jRcrd.Status
+		tenant = jRcrd.Tenant
 
 		if err != nil {
 			output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
 			return prepareResponse(c, http.StatusInternalServerError, "error", output)
 		}
+		if jRcrd.Archived != nil {
+			return prepareResponse(c, http.StatusGone, "error", archivedJobError(jobID))
+		}
 	} else { // miss
 		output := errResponse{HTTPStatus: http.StatusNotFound, Message: "jobID not found"}
 		return prepareResponse(c, http.StatusNotFound, "error", output)
 	}
 
-	logs, err := jobs.FetchLogs(rh.StorageSvc, jobID, false)
+	// Queries are applied while files are read so that only the requested page is kept in memory
+	logs, err := jobs.FetchLogsQuery(rh.StorageSvc, jobID, tenant, query)
 	if err != nil {
 		output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: "error while fetching logs: " + err.Error()}
 		return prepareResponse(c, http.StatusInternalServerError, "error", output)
@@ -595,12 +1315,174 @@ func (rh *RESTHandler) JobLogsHandler(c echo.Context) (err error) {
 
 	logs.ProcessID = pid
 	logs.Status = status
-	return prepareResponse(c, http.StatusOK, "jobLogs", logs)
+	switch format {
+	case "html":
+		return c.Render(http.StatusOK, "jobLogs", logs)
+	case "ndjson":
+		return writeLogsNDJSON(c, logs)
+	case "text":
+		return writeLogsText(c, logs)
+	}
+	return c.JSON(http.StatusOK, logs)
+
+}
+
+// Interval at which keep-alive comments are sent on idle log streams
+const logStreamHeartbeat = 15 * time.Second
+
+// @Summary Job Logs Stream
+// @Description Streams process logs of an active job as Server-Sent Events. Each log line is sent as a `data` event.
+// @Description An `end` event carrying the final job status is sent when the job finishes.
+// @Description For finished jobs stored logs are sent followed by the `end` event.
+// @Tags jobs
+// @Accept */*
+// @Produce text/event-stream
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {string} string
+// @Router /jobs/{jobID}/logs/stream [get]
+// Does not produce HTML
+func (rh *RESTHandler) JobLogsStreamHandler(c echo.Context) error {
+	status, err := rh.FollowJobLogs(c.Request().Context(), c.Param("jobID"), LogFollower{
+		Start: func() { startEventStream(c) },
+		Line:  func(line string) { writeEvent(c, "", line) },
+		KeepAlive: func() {
+			fmt.Fprint(c.Response(), ": keep-alive\n\n")
+			c.Response().Flush()
+		},
+	})
+	if err != nil {
+		if je, ok := err.(*JobError); ok {
+			return c.JSON(je.HTTPStatus, errResponse{Message: je.Message})
+		}
+		return nil // client went away
+	}
+	writeEvent(c, "end", status)
+	return nil
+}
+
+// @Summary Stream Job Events
+// @Description Server-Sent Events of status changes and progress updates of a job, as they happen.
+// @Description The first `status` event is the current status of the job, followed by `status` events of status changes
+// @Description and `progress` events of progress updates reported by the process. An `end` event with the final status
+// @Description is sent when the job finished, finished jobs only receive their status and the `end` event.
+// @Tags jobs
+// @Produce text/event-stream
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {string} string "event stream"
+// @Router /jobs/{jobID}/events [get]
+// Does not produce HTML
+func (rh *RESTHandler) JobEventsHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
 
+	job, ok := rh.ActiveJobs.Get(jobID)
+	if !ok {
+		jRcrd, ok, err := rh.DB.GetJob(jobID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		if !ok {
+			return c.JSON(http.StatusNotFound, errResponse{Message: "jobID not found"})
+		}
+		startEventStream(c)
+		writeJSONEvent(c, "status", jobs.JobEvent{
+			Type:      jobs.EventJobStatusUpdated,
+			JobID:     jobID,
+			ProcessID: jRcrd.ProcessID,
+			Submitter: jRcrd.Submitter,
+			Status:    jRcrd.Status,
+			Time:      jRcrd.LastUpdate,
+		})
+		writeEvent(c, "end", jRcrd.Status)
+		return nil
+	}
+
+	// subscribe before reading the current status, so that no change is missed in between
+	events, unsubscribe := rh.JobEvents.Subscribe(jobID)
+	defer unsubscribe()
+
+	startEventStream(c)
+	status := (*job).CurrentStatus()
+	writeJSONEvent(c, "status", jobs.JobEvent{
+		Type:           jobs.EventJobStatusUpdated,
+		JobID:          jobID,
+		ProcessID:      (*job).ProcessID(),
+		ProcessVersion: (*job).ProcessVersionID(),
+		Submitter:      (*job).SUBMITTER(),
+		Status:         status,
+		Time:           (*job).LastUpdate(),
+	})
+	switch status {
+	case jobs.SUCCESSFUL, jobs.FAILED, jobs.DISMISSED:
+		writeEvent(c, "end", status)
+		return nil
+	}
+
+	ticker := time.NewTicker(logStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done(): // client went away
+			return nil
+		case <-ticker.C:
+			fmt.Fprint(c.Response(), ": keep-alive\n\n")
+			c.Response().Flush()
+		case e, open := <-events:
+			if !open {
+				writeEvent(c, "end", (*job).CurrentStatus())
+				return nil
+			}
+			switch e := e.(type) {
+			case jobs.JobEvent:
+				if e.Status != status {
+					status = e.Status
+					writeJSONEvent(c, "status", e)
+				}
+			case jobs.JobProgress:
+				writeJSONEvent(c, "progress", e)
+			}
+		}
+	}
+}
+
+// Write a Server-Sent Event with v as JSON data
+func writeJSONEvent(c echo.Context, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		requestLogger(c).Errorf("could not marshal %s event: %s", event, err.Error())
+		return
+	}
+	writeEvent(c, event, string(data))
+}
+
+// Write headers for a Server-Sent Events response
+func startEventStream(c echo.Context) {
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set(echo.HeaderConnection, "keep-alive")
+	c.Response().Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+}
+
+// Write a single Server-Sent Event, empty event name means a default message event.
+// Multi-line data is split into multiple data fields as required by the SSE spec.
+func writeEvent(c echo.Context, event string, data string) {
+	w := c.Response()
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	for _, l := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", l)
+	}
+	fmt.Fprint(w, "\n")
+	w.Flush()
 }
 
 // @Summary Summary of all (active) Jobs
 // @Description [Job List Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#sc_retrieve_job_results)
+// @Description Jobs submitted by pipeline and fan-out jobs are only listed with `children=true`, parents have the number of their jobs in `children`.
+// @Description The HTML page has filters and keeps the listed jobs up to date with /jobs/events.
 // @Tags jobs
 // @Accept */*
 // @Produce json
@@ -617,6 +1499,8 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 	processIDs := c.QueryParam("processID") // assuming comma-separated list: "process1,process2"
 	statuses := c.QueryParam("status")
 	submitters := c.QueryParam("submitter")
+	// jobs of pipeline and fan-out jobs are listed with /jobs/{jobID}/children unless asked for
+	includeChildren := c.QueryParam("children") == "true"
 
 	var processIDList []string
 	if processIDs != "" {
@@ -637,13 +1521,8 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 		}
 	}
 
-	if rh.Config.AuthLevel > 1 { // changed for hotfix, should be > 0 when clients are updated
-		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
-
-		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
-			submitters = c.Request().Header.Get("X-SEPEX-User-Email")
-		}
-	}
+	submitters, tenantsList := rh.visibleJobs(c, submitters, c.QueryParam("tenant"))
+	tenants := strings.Join(tenantsList, ",")
 
 	var submittersList []string
 	if submitters != "" {
@@ -660,7 +1539,7 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 		offset = 0
 	}
 
-	result, err := rh.DB.GetJobs(limit, offset, processIDList, statusList, submittersList)
+	result, err := rh.DB.GetJobs(limit, offset, processIDList, statusList, submittersList, tenantsList, includeChildren)
 	if err != nil {
 		output := errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
 		return prepareResponse(c, http.StatusNotFound, "error", output)
@@ -669,14 +1548,14 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 	links := make([]link, 0)
 	if offset != 0 {
 		lnk := link{
-			Href:  fmt.Sprintf("/jobs?offset=%v&limit=%v&processID=%v&status=%v&submitter=%v", offset-limit, limit, processIDs, statuses, submitters),
+			Href:  fmt.Sprintf("/jobs?offset=%v&limit=%v&processID=%v&status=%v&submitter=%v&tenant=%v&children=%v", offset-limit, limit, processIDs, statuses, submitters, tenants, includeChildren),
 			Title: "prev",
 		}
 		links = append(links, lnk)
 	}
 	if limit == len(result) {
 		lnk := link{
-			Href:  fmt.Sprintf("/jobs?offset=%v&limit=%v&processID=%v&status=%v&submitter=%v", offset+limit, limit, processIDs, statuses, submitters),
+			Href:  fmt.Sprintf("/jobs?offset=%v&limit=%v&processID=%v&status=%v&submitter=%v&tenant=%v&children=%v", offset+limit, limit, processIDs, statuses, submitters, tenants, includeChildren),
 			Title: "next",
 		}
 		links = append(links, lnk)
@@ -685,9 +1564,41 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 	output := make(map[string]interface{}, 0)
 	output["jobs"] = result
 	output["links"] = links
+	if respondsHTML(c) {
+		// Offered by the process filter of the jobs page
+		processes := rh.ProcessList.Search(pr.ProcessQuery{})
+		ids := make([]string, len(processes))
+		for i, p := range processes {
+			ids[i] = p.ID
+		}
+		output["processIDs"] = ids
+	}
 	return prepareResponse(c, http.StatusOK, "jobs", output)
 }
 
+// Restrict the submitters and tenants filters of job lists, comma separated, to the jobs the user may see.
+// The tenants are returned as list since users without tenant may only see jobs of the empty tenant, an empty list
+// does not filter.
+func (rh *RESTHandler) visibleJobs(c echo.Context, submitters, tenants string) (string, []string) {
+	if rh.Config.AuthLevel > 1 { // changed for hotfix, should be > 0 when clients are updated
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			submitters = c.Request().Header.Get("X-SEPEX-User-Email")
+		}
+	}
+
+	// with auth users only see jobs of their tenant, only admins see all jobs and can filter by tenant
+	if rh.Config.AuthLevel == 0 {
+		return submitters, nil
+	}
+	roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+	if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+		return submitters, []string{rh.requestTenant(c)}
+	}
+	return submitters, splitFilter(tenants)
+}
+
 // Sample message body:
 //
 //	{
@@ -695,7 +1606,10 @@ func (rh *RESTHandler) ListJobsHandler(c echo.Context) error {
 //		"updated": "2023-08-28T18:25:44.731Z"
 //	}
 //
-// Time must be in RFC3339(ISO) format
+// Time must be in RFC3339(ISO) format.
+//
+// Messages may have a progress in percent and a message, which are sent to subscribers of the job's events.
+// Status may be omitted for progress updates, e.g. {"progress": 40, "message": "tile 4 of 10"}
 func (rh *RESTHandler) JobStatusUpdateHandler(c echo.Context) error {
 	if rh.Config.AuthLevel > 0 {
 		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
@@ -708,7 +1622,7 @@ func (rh *RESTHandler) JobStatusUpdateHandler(c echo.Context) error {
 
 	jobID := c.Param("jobID")
 
-	if job, ok := rh.ActiveJobs.Jobs[jobID]; ok { // ActiveJobs hit
+	if job, ok := rh.ActiveJobs.Get(jobID); ok { // ActiveJobs hit
 		var sm jobs.StatusMessage
 		sm.Job = job
 		// setup some kind of token/auth to allow only the allowed agents to post to this route
@@ -720,6 +1634,15 @@ func (rh *RESTHandler) JobStatusUpdateHandler(c echo.Context) error {
 		if err = json.Unmarshal(dataBytes, &sm); err != nil {
 			return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "incorrect message body"})
 		}
+		if sm.Progress != nil && (*sm.Progress < 0 || *sm.Progress > 100) {
+			return c.JSON(http.StatusBadRequest, errResponse{http.StatusBadRequest, "progress must be between 0 and 100"})
+		}
+		if sm.Progress != nil || sm.Message != "" {
+			rh.JobEvents.PublishProgress(jobs.JobProgress{JobID: jobID, Progress: sm.Progress, Message: sm.Message, Time: sm.LastUpdate})
+			if sm.Status == "" {
+				return c.JSON(http.StatusAccepted, "progress update received")
+			}
+		}
 		// check status valid
 		switch sm.Status {
 		case jobs.ACCEPTED, jobs.RUNNING, jobs.DISMISSED, jobs.FAILED, jobs.SUCCESSFUL:
@@ -728,11 +1651,11 @@ func (rh *RESTHandler) JobStatusUpdateHandler(c echo.Context) error {
 			return c.JSON(http.StatusBadRequest, fmt.Sprintf("status not valid, valid options are: %s, %s, %s, %s, %s", jobs.ACCEPTED, jobs.RUNNING, jobs.DISMISSED, jobs.FAILED, jobs.SUCCESSFUL))
 		}
 		(*sm.Job).LogMessage(fmt.Sprintf("Status update received: %s.", sm.Status), logrus.InfoLevel)
-		rh.MessageQueue.StatusChan <- sm
+		rh.MessageQueue.SendStatus(sm)
 		return c.JSON(http.StatusAccepted, "status update received")
 	} else if ok, err := rh.DB.CheckJobExist(jobID); ok || err != nil { // db hit or error
 		if ok {
-			log.Infof("Status update received for inactive job: %s", jobID)
+			requestLogger(c).Infof("Status update received for inactive job: %s", jobID)
 			// returning Accepted here so that callers do not retry
 			return c.JSON(http.StatusAccepted, "job not an active job")
 		}
@@ -781,10 +1704,14 @@ type resourcesResponse struct {
 	QueuedCPUsPct float32 `json:"queuedCPUsPct"`
 	UsedMemPct    float32 `json:"usedMemPct"`
 	QueuedMemPct  float32 `json:"queuedMemPct"`
+	// Usage of tenants with a quota, 0 max means resource is not limited for the tenant. Only shown to admins
+	Tenants map[string]jobs.TenantUsage `json:"tenants,omitempty"`
+	// Last check of local disk usage by job logs and scratch directories, only checked when a disk limit is set
+	Disk *jobs.DiskUsage `json:"disk,omitempty"`
 }
 
 // @Summary Resource Status
-// @Description Returns current resource utilization for local job scheduling
+// @Description Returns current resource utilization for local job scheduling, usage of tenants is only returned to admins
 // @Tags admin
 // @Accept */*
 // @Produce json
@@ -796,6 +1723,23 @@ func (rh *RESTHandler) ResourceStatusHandler(c echo.Context) error {
 		return err
 	}
 
+	// the route is public, tenants and their usage are only listed for admins
+	roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+	resources := rh.resourcesStatus(rh.Config.AuthLevel > 0 && utils.StringInSlice(rh.Config.AdminRoleName, roles))
+
+	links := []link{
+		{Href: "/admin/resources", Rel: "self", Title: "this document"},
+	}
+
+	output := make(map[string]interface{})
+	output["resources"] = resources
+	output["links"] = links
+
+	return prepareResponse(c, http.StatusOK, "resourceStatus", output)
+}
+
+// Current resource utilization of the resource pool, with the usage of tenants if withTenants is set
+func (rh *RESTHandler) resourcesStatus(withTenants bool) resourcesResponse {
 	status := rh.ResourcePool.GetStatus()
 
 	resources := resourcesResponse{
@@ -806,6 +1750,9 @@ func (rh *RESTHandler) ResourceStatusHandler(c echo.Context) error {
 		MaxCPUs:      status.MaxCPUs,
 		MaxMemory:    status.MaxMemory,
 	}
+	if withTenants {
+		resources.Tenants = status.Tenants
+	}
 
 	if status.MaxCPUs > 0 {
 		resources.UsedCPUsPct = (status.UsedCPUs / status.MaxCPUs) * 100
@@ -815,14 +1762,8 @@ func (rh *RESTHandler) ResourceStatusHandler(c echo.Context) error {
 		resources.UsedMemPct = (float32(status.UsedMemory) / float32(status.MaxMemory)) * 100
 		resources.QueuedMemPct = (float32(status.QueuedMemory) / float32(status.MaxMemory)) * 100
 	}
-
-	links := []link{
-		{Href: "/admin/resources", Rel: "self", Title: "this document"},
+	if usage, ok := rh.DiskMonitor.Usage(); ok {
+		resources.Disk = &usage
 	}
-
-	output := make(map[string]interface{})
-	output["resources"] = resources
-	output["links"] = links
-
-	return prepareResponse(c, http.StatusOK, "resourceStatus", output)
+	return resources
 }
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"app/config"
+
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Response headers browser clients need to read, exposed by default
+var defaultCORSExposeHeaders = []string{
+	"Location", "Preference-Applied", "X-Request-Id", "Retry-After",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+}
+
+// CORS settings of the cors config (CORS_* env variables), defaults allow all origins with credentials
+func newCORSConfig(c config.CORS) middleware.CORSConfig {
+	cfg := middleware.CORSConfig{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+	if len(cfg.AllowOrigins) == 0 {
+		cfg.AllowOrigins = []string{"*"}
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = middleware.DefaultCORSConfig.AllowMethods
+	}
+	if len(cfg.ExposeHeaders) == 0 {
+		cfg.ExposeHeaders = defaultCORSExposeHeaders
+	}
+	return cfg
+}
+
+// Security header settings of the headers config (SECURITY_HEADERS, HSTS_MAX_AGE, CONTENT_SECURITY_POLICY and X_FRAME_OPTIONS env variables).
+// Returns nil if security headers are disabled.
+func newSecureConfig(h config.Headers) *middleware.SecureConfig {
+	if !h.Enabled {
+		return nil
+	}
+	return &middleware.SecureConfig{
+		ContentTypeNosniff:    "nosniff",
+		XFrameOptions:         h.XFrameOptions,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: h.ContentSecurityPolicy,
+		// only sent over TLS (or with X-Forwarded-Proto: https)
+		HSTSMaxAge: h.HSTSMaxAge,
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"app/config"
+	"app/controllers"
+	pr "app/processes"
+	"app/utils"
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// imageStatus is the local state of a docker image of registered processes
+type imageStatus struct {
+	Image     string   `json:"image"`
+	Processes []string `json:"processes"`
+	// ID of the local image, new jobs of the processes run this image
+	Digest   string    `json:"digest,omitempty"`
+	PulledAt time.Time `json:"pulledAt,omitempty"`
+	// Error of the last pull, the previous image is kept
+	Error string `json:"error,omitempty"`
+}
+
+type imagesResponse struct {
+	Refreshing  bool          `json:"refreshing"`
+	LastRefresh time.Time     `json:"lastRefresh,omitempty"`
+	Images      []imageStatus `json:"images"`
+}
+
+// ImageManager pulls images of docker processes again to pick up patched images pushed to the same tag,
+// and records the image each process currently runs.
+type ImageManager struct {
+	mu          sync.Mutex
+	status      map[string]imageStatus
+	refreshing  bool
+	lastRefresh time.Time
+	docker      *controllers.SharedDockerController
+}
+
+func NewImageManager(docker *controllers.SharedDockerController) *ImageManager {
+	return &ImageManager{status: make(map[string]imageStatus), docker: docker}
+}
+
+// Start a refresh unless one is running, returns false if one is running. Images pinned by digest are not pulled again.
+func (im *ImageManager) startRefresh(pl *pr.ProcessList, pull bool) bool {
+	im.mu.Lock()
+	if im.refreshing {
+		im.mu.Unlock()
+		return false
+	}
+	im.refreshing = true
+	im.mu.Unlock()
+
+	go im.refresh(pl, pull)
+	return true
+}
+
+func (im *ImageManager) refresh(pl *pr.ProcessList, pull bool) {
+	defer func() {
+		im.mu.Lock()
+		im.refreshing = false
+		im.lastRefresh = time.Now()
+		im.mu.Unlock()
+	}()
+
+	images := pl.Images()
+	var errs map[string]error
+	if pull {
+		var tags []pr.ProcessImage
+		for _, img := range images {
+			if !pr.IsPinned(img.Image) {
+				tags = append(tags, img)
+			}
+		}
+		errs = pr.PullImages(context.Background(), tags, true, config.Get().Images.PullConcurrency)
+	}
+
+	c, err := im.docker.Get()
+	if err != nil {
+		log.Warnf("Image manager could not connect to docker: %s", err.Error())
+		return
+	}
+
+	next := make(map[string]imageStatus, len(images))
+	for _, pi := range images {
+		img := pi.Image
+		im.mu.Lock()
+		st := im.status[img]
+		im.mu.Unlock()
+
+		st.Image, st.Processes, st.Error = img, pi.ProcessIDs, ""
+		if pull && !pr.IsPinned(img) {
+			if err := errs[img]; err != nil {
+				st.Error = err.Error()
+				log.Errorf("Image manager could not pull %s: %s", img, err.Error())
+			} else {
+				st.PulledAt = time.Now()
+			}
+		}
+
+		digest, err := c.GetImageDigest(img)
+		if err != nil {
+			st.Error = err.Error()
+		} else {
+			if st.Digest != "" && st.Digest != digest {
+				log.Infof("Image %s of processes %s updated from %s to %s", img, strings.Join(st.Processes, ", "), st.Digest, digest)
+			}
+			st.Digest = digest
+		}
+		next[img] = st
+	}
+
+	im.mu.Lock()
+	im.status = next
+	im.mu.Unlock()
+}
+
+func (im *ImageManager) response() imagesResponse {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	resp := imagesResponse{Refreshing: im.refreshing, LastRefresh: im.lastRefresh, Images: make([]imageStatus, 0, len(im.status))}
+	for _, st := range im.status {
+		resp.Images = append(resp.Images, st)
+	}
+	sort.Slice(resp.Images, func(i, j int) bool { return resp.Images[i].Image < resp.Images[j].Image })
+	return resp
+}
+
+// ImageRefreshRoutine records the images of docker processes at startup and pulls their tags again every interval, 0 disables re-pulls.
+// Missing images are pulled when processes are loaded. Running jobs keep the image they were started with.
+func (rh *RESTHandler) ImageRefreshRoutine(interval time.Duration) {
+	rh.Images.startRefresh(rh.ProcessList, false)
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !rh.Images.startRefresh(rh.ProcessList, true) {
+			log.Warn("Image refresh skipped, the previous refresh is still running")
+		}
+	}
+}
+
+// @Summary Process Images
+// @Description Docker images of registered processes with the processes using them, the ID of the local image new jobs run and the result of the last pull.
+// @Description Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} imagesResponse
+// @Router /admin/images [get]
+func (rh *RESTHandler) ImagesHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, rh.Images.response())
+}
+
+// @Summary Refresh Process Images
+// @Description Pulls the image tags of docker processes again now instead of waiting for IMAGE_REFRESH_INTERVAL, images pinned by digest are not pulled.
+// @Description The refresh runs in the background, poll `GET /admin/images` until `refreshing` is false. A refresh already running is not restarted.
+// @Description Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 202 {object} imagesResponse
+// @Router /admin/images/refresh [post]
+func (rh *RESTHandler) RefreshImagesHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	rh.Images.startRefresh(rh.ProcessList, true)
+	return c.JSON(http.StatusAccepted, rh.Images.response())
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"app/jobs"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Job operations for callers of the jobs layer other than the REST routes, e.g. the gRPC service.
+// The echo context is the one of the caller's request, its user headers are used like those of REST requests.
+// Auth, rate limit, audit and JobOwner middlewares are not applied, callers run them around the operations.
+
+// JobError is an error of a job operation with the HTTP status the REST API responds with
+type JobError struct {
+	HTTPStatus int
+	Message    string
+}
+
+func (e *JobError) Error() string {
+	return e.Message
+}
+
+func newJobError(er *errResponse) error {
+	return &JobError{HTTPStatus: er.HTTPStatus, Message: er.Message}
+}
+
+// JobInfo is the status of a job, Outputs are set for sync jobs that succeeded
+type JobInfo struct {
+	JobID     string
+	ProcessID string
+	Status    string
+	Updated   time.Time
+	Message   string
+	Outputs   interface{}
+}
+
+func newJobInfo(resp jobResponse) JobInfo {
+	return JobInfo{
+		JobID:     resp.JobID,
+		ProcessID: resp.ProcessID,
+		Status:    resp.Status,
+		Updated:   resp.LastUpdate,
+		Message:   resp.Message,
+		Outputs:   resp.Outputs,
+	}
+}
+
+// SubmitJob executes process processID with inputs and env overrides like an execute request, the execution mode is
+// negotiated with the Prefer header of c. Jobs that were created but did not succeed are not errors, their status and
+// message tell what happened.
+func (rh *RESTHandler) SubmitJob(c echo.Context, processID string, inputs map[string]interface{}, env map[string]string) (JobInfo, error) {
+	p, _, err := rh.ProcessList.Get(processID)
+	if err != nil {
+		return JobInfo{}, &JobError{HTTPStatus: http.StatusBadRequest, Message: "'processID' incorrect"}
+	}
+	if rh.Config.AuthLevel > 0 && !rh.processAllowed(c, p) {
+		return JobInfo{}, &JobError{HTTPStatus: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	// the limits ExecuteLimits applies to execute requests, on the body the request would have
+	body := runRequestBody{Inputs: inputs, Env: env}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return JobInfo{}, &JobError{HTTPStatus: http.StatusBadRequest, Message: "inputs are not JSON values: " + err.Error()}
+	}
+	if status, resp := executeBodyLimitsError(b); resp != nil {
+		msg := resp.Message
+		if resp.Path != "" {
+			msg += " at " + resp.Path
+		}
+		return JobInfo{}, &JobError{HTTPStatus: status, Message: msg}
+	}
+
+	sub, errResp := rh.newSubmission(c, p, body)
+	if errResp != nil {
+		return JobInfo{}, newJobError(errResp)
+	}
+	res, errResp := rh.runSubmission(c, p, sub)
+	if errResp != nil {
+		return JobInfo{}, newJobError(errResp)
+	}
+	return newJobInfo(res.Resp), nil
+}
+
+// GetJob returns the status of an active job or of a job recorded in the database
+func (rh *RESTHandler) GetJob(jobID string) (JobInfo, error) {
+	if job, ok := rh.ActiveJobs.Get(jobID); ok {
+		return JobInfo{
+			JobID:     (*job).JobID(),
+			ProcessID: (*job).ProcessID(),
+			Status:    (*job).CurrentStatus(),
+			Updated:   (*job).LastUpdate(),
+		}, nil
+	}
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil {
+		return JobInfo{}, &JobError{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+	}
+	if !ok {
+		return JobInfo{}, &JobError{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("%s job id not found", jobID)}
+	}
+	return JobInfo{JobID: jRcrd.JobID, ProcessID: jRcrd.ProcessID, Status: jRcrd.Status, Updated: jRcrd.LastUpdate}, nil
+}
+
+// DismissJob dismisses an active job or archives a finished one like the dismiss endpoint
+func (rh *RESTHandler) DismissJob(c echo.Context, jobID string) (JobInfo, error) {
+	resp, errResp := rh.dismissOrArchiveJob(c, jobID)
+	if errResp != nil {
+		return JobInfo{}, newJobError(errResp)
+	}
+	return newJobInfo(resp), nil
+}
+
+// LogFollower receives the logs of a job followed with FollowJobLogs
+type LogFollower struct {
+	// Called once the job was found, before the first line
+	Start func()
+	// Called for each log line
+	Line func(line string)
+	// Called when no line was written for a while, optional
+	KeepAlive func()
+}
+
+// FollowJobLogs passes the process logs of job jobID to f as they are written and returns the final status of the job
+// once it finished. For finished jobs their stored logs are passed. If ctx is done first its error is returned.
+func (rh *RESTHandler) FollowJobLogs(ctx context.Context, jobID string, f LogFollower) (string, error) {
+	job, ok := rh.ActiveJobs.Get(jobID)
+	if !ok {
+		jRcrd, ok, err := rh.DB.GetJob(jobID)
+		if err != nil {
+			return "", &JobError{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+		}
+		if !ok {
+			return "", &JobError{HTTPStatus: http.StatusNotFound, Message: "jobID not found"}
+		}
+		if jRcrd.Archived != nil {
+			errResp := archivedJobError(jobID)
+			return "", newJobError(&errResp)
+		}
+
+		// job already finished, replay stored logs
+		logs, err := jobs.FetchLogs(rh.StorageSvc, jobID, jRcrd.Tenant, true)
+		if err != nil {
+			return "", &JobError{HTTPStatus: http.StatusInternalServerError, Message: "error while fetching logs: " + err.Error()}
+		}
+		f.Start()
+		for _, l := range logs.ProcessLogs {
+			f.Line(l.Msg)
+		}
+		for _, l := range logs.StderrLogs {
+			f.Line(l.Msg)
+		}
+		return jRcrd.Status, nil
+	}
+
+	streamer, ok := (*job).(jobs.LogStreamer)
+	if !ok {
+		return "", &JobError{HTTPStatus: http.StatusBadRequest, Message: "live logs are not available for this job's host, use logs route instead"}
+	}
+
+	lines, unsubscribe := streamer.SubscribeProcessLogs()
+	defer unsubscribe()
+
+	f.Start()
+	ticker := time.NewTicker(logStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+			if f.KeepAlive != nil {
+				f.KeepAlive()
+			}
+		case line, open := <-lines:
+			if !open {
+				return (*job).CurrentStatus(), nil
+			}
+			f.Line(line)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Split a comma separated filter of the jobs list, nil if it is empty
+func splitFilter(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// Whether lists empty or containing v match v
+func filterMatches(list []string, v string) bool {
+	return len(list) == 0 || utils.StringInSlice(v, list)
+}
+
+// @Summary Stream Jobs Events
+// @Description Server-Sent Events of status changes of all jobs the user may see as they happen, e.g. to keep the jobs list up to date.
+// @Description Every change is a `status` event with the job event. Filters are those of the jobs list: comma separated `processID`,
+// @Description `status` and `submitter`, and `children=true` to include jobs submitted by pipeline and fan-out jobs.
+// @Tags jobs
+// @Produce text/event-stream
+// @Param processID query string false "comma separated process IDs"
+// @Param status query string false "comma separated statuses"
+// @Param submitter query string false "comma separated submitters"
+// @Param children query bool false "include jobs of pipeline and fan-out jobs"
+// @Success 200 {string} string "event stream"
+// @Router /jobs/events [get]
+func (rh *RESTHandler) JobsEventsHandler(c echo.Context) error {
+	processIDs := splitFilter(c.QueryParam("processID"))
+	statuses := splitFilter(c.QueryParam("status"))
+	for _, st := range statuses {
+		switch st {
+		case jobs.ACCEPTED, jobs.RUNNING, jobs.DISMISSED, jobs.FAILED, jobs.SUCCESSFUL:
+		default:
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "One or more status values not valid"})
+		}
+	}
+	submitters, tenantList := rh.visibleJobs(c, c.QueryParam("submitter"), c.QueryParam("tenant"))
+	submitterList := splitFilter(submitters)
+	includeChildren := c.QueryParam("children") == "true"
+
+	visible := func(e jobs.JobEvent) bool {
+		return filterMatches(processIDs, e.ProcessID) && filterMatches(statuses, e.Status) && filterMatches(submitterList, e.Submitter) &&
+			filterMatches(tenantList, e.Tenant) && (includeChildren || e.ParentID == "")
+	}
+
+	events, unsubscribe := rh.JobEvents.SubscribeAll()
+	defer unsubscribe()
+	startEventStream(c)
+
+	ticker := time.NewTicker(logStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done(): // client went away
+			return nil
+		case <-ticker.C:
+			fmt.Fprint(c.Response(), ": keep-alive\n\n")
+			c.Response().Flush()
+		case e, open := <-events:
+			if !open {
+				return nil
+			}
+			if je, ok := e.(jobs.JobEvent); ok && visible(je) {
+				writeJSONEvent(c, "status", je)
+			}
+		}
+	}
+}
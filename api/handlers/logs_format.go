@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Formats of GET /jobs/{jobID}/logs, text and ndjson merge the entries of all sources ordered by time
+var logsFormats = []string{"json", "html", "ndjson", "text"}
+
+// Log entry of a merged log, with the log it comes from
+type sourcedLogEntry struct {
+	Source string `json:"source"`
+	jobs.LogEntry
+}
+
+// Format of the logs response, from query parameter f or else the Accept header. False if f is not a valid format.
+func logsFormat(c echo.Context) (string, bool) {
+	if f := c.QueryParam("f"); f != "" {
+		return f, utils.StringInSlice(f, logsFormats)
+	}
+
+	accept := c.Request().Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, mimeNDJSON):
+		return "ndjson", true
+	case strings.Contains(accept, echo.MIMETextPlain) && !strings.Contains(accept, echo.MIMETextHTML):
+		return "text", true
+	case respondsHTML(c):
+		return "html", true
+	}
+	return "json", true
+}
+
+// Entries of all sources of logs ordered by time, entries with the same time keep the order server, process, stderr
+func mergedLogs(logs jobs.JobLogs) []sourcedLogEntry {
+	entries := make([]sourcedLogEntry, 0, len(logs.ServerLogs)+len(logs.ProcessLogs)+len(logs.StderrLogs))
+	for _, l := range logs.ServerLogs {
+		entries = append(entries, sourcedLogEntry{"server", l})
+	}
+	for _, l := range logs.ProcessLogs {
+		entries = append(entries, sourcedLogEntry{"process", l})
+	}
+	for _, l := range logs.StderrLogs {
+		entries = append(entries, sourcedLogEntry{"stderr", l})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+// Write logs as one JSON entry per line
+func writeLogsNDJSON(c echo.Context, logs jobs.JobLogs) error {
+	c.Response().Header().Set(echo.HeaderContentType, mimeNDJSON)
+	c.Response().WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(c.Response())
+	for _, e := range mergedLogs(logs) {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write logs as lines of time, source, level and message
+func writeLogsText(c echo.Context, logs jobs.JobLogs) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	for _, e := range mergedLogs(logs) {
+		line := fmt.Sprintf("%s %-7s %-7s %s\n", e.Time.Format(time.RFC3339), e.Source, strings.ToUpper(e.Level), e.Msg)
+		if _, err := c.Response().Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Content type of jsonl log files
+const mimeNDJSON = "application/x-ndjson"
+
+// LogsGzip compresses log responses for clients accepting gzip. Range requests are not compressed,
+// ranges are offsets in the uncompressed file.
+func LogsGzip() echo.MiddlewareFunc {
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		Skipper: func(c echo.Context) bool {
+			return c.Request().Header.Get("Range") != ""
+		},
+	})
+}
+
+// @Summary Job Log File
+// @Description A log file of the job as stored, one JSON entry per line. Files are streamed from disk or storage
+// @Description and support HTTP Range requests, e.g. `Range: bytes=1048576-` to continue after the first MB.
+// @Description Responses are gzip compressed if the client accepts it and no range is requested.
+// @Tags jobs
+// @Accept */*
+// @Produce application/x-ndjson
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param file query string false "process | stderr | server, default process"
+// @Success 200 {string} string
+// @Success 206 {string} string
+// @Router /jobs/{jobID}/logs/raw [get]
+// Does not produce HTML
+func (rh *RESTHandler) JobLogsRawHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+	file := c.QueryParam("file")
+	if file == "" {
+		file = "process"
+	}
+	if !utils.StringInSlice(file, jobs.LogFiles) {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'file' must be one of process, stderr, server"})
+	}
+
+	var tenant string
+	if job, ok := rh.ActiveJobs.Get(jobID); ok {
+		if (*job).CurrentStatus() == jobs.ACCEPTED {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "Logs will be available after the job has reached running state."})
+		}
+		tenant = (*job).TENANT()
+		_ = (*job).UpdateProcessLogs()
+	} else {
+		jRcrd, ok, err := rh.DB.GetJob(jobID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		if !ok {
+			return c.JSON(http.StatusNotFound, errResponse{Message: "jobID not found"})
+		}
+		if jRcrd.Archived != nil {
+			return c.JSON(http.StatusGone, archivedJobError(jobID))
+		}
+		tenant = jRcrd.Tenant
+	}
+
+	name := jobID + "." + file + ".jsonl"
+	c.Response().Header().Set(echo.HeaderContentDisposition, "inline; filename=\""+name+"\"")
+	c.Response().Header().Set(echo.HeaderContentType, mimeNDJSON)
+
+	// http.ServeContent answers Range and If-Range requests from the open file
+	if f, err := os.Open(jobs.LocalLogPath(jobID, file)); err == nil {
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), f)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+
+	// Not on disk anymore, the range and If-None-Match are passed to storage
+	key := jobs.LogStorageKey(jobID, tenant, file)
+	exists, err := utils.KeyExists(key, rh.StorageSvc)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if !exists {
+		return c.JSON(http.StatusNotFound, errResponse{Message: file + " log file not found"})
+	}
+	out, unchanged, err := utils.GetS3ObjectIfNoneMatch(key, c.Request().Header.Get("Range"), c.Request().Header.Get("If-None-Match"), rh.StorageSvc)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == "InvalidRange" {
+		return c.JSON(http.StatusRequestedRangeNotSatisfiable, errResponse{Message: "requested range is not satisfiable"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if unchanged {
+		return notModified(c)
+	}
+	defer out.Body.Close()
+
+	h := c.Response().Header()
+	h.Set("Accept-Ranges", "bytes")
+	if out.ETag != nil {
+		h.Set("ETag", aws.StringValue(out.ETag))
+	}
+	if out.ContentLength != nil {
+		h.Set(echo.HeaderContentLength, strconv.FormatInt(*out.ContentLength, 10))
+	}
+	if out.LastModified != nil {
+		h.Set(echo.HeaderLastModified, out.LastModified.UTC().Format(http.TimeFormat))
+	}
+	status := http.StatusOK
+	if out.ContentRange != nil {
+		h.Set("Content-Range", aws.StringValue(out.ContentRange))
+		status = http.StatusPartialContent
+	}
+	c.Response().WriteHeader(status)
+	_, err = io.Copy(c.Response(), out.Body)
+	return err
+}
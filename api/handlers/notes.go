@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"app/jobs"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Maximum length of a job note in characters
+const maxJobNoteLength = 4096
+
+type jobNoteRequestBody struct {
+	Text string `json:"text"`
+}
+
+// @Summary Add Job Note
+// @Description Attaches a free-text note to a job, e.g. why it was dismissed or re-run. The author is the user making the request.
+// @Description Notes are listed on the job status page and deleted with the job.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param body body jobNoteRequestBody true "note"
+// @Success 201 {object} jobs.JobNote
+// @Router /jobs/{jobID}/notes [post]
+func (rh *RESTHandler) JobNoteAddHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+
+	var params jobNoteRequestBody
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "invalid request body: " + err.Error()})
+	}
+	text := strings.TrimSpace(params.Text)
+	if text == "" {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "text is required"})
+	}
+	if len([]rune(text)) > maxJobNoteLength {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: fmt.Sprintf("text is longer than %d characters", maxJobNoteLength)})
+	}
+
+	// Ownership is checked by JobOwner middleware
+	if !rh.ActiveJobs.Has(jobID) {
+		exists, err := rh.DB.CheckJobExist(jobID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+		}
+		if !exists {
+			return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("%s job id not found", jobID)})
+		}
+	}
+
+	note := jobs.JobNote{
+		JobID:   jobID,
+		Author:  c.Request().Header.Get("X-SEPEX-User-Email"),
+		Text:    text,
+		Created: time.Now().UTC(),
+	}
+	id, err := rh.DB.AddJobNote(note)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	note.ID = id
+
+	return c.JSON(http.StatusCreated, note)
+}
+
+// @Summary Job Notes
+// @Description Lists notes of a job, oldest first.
+// @Tags jobs
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {array} jobs.JobNote
+// @Router /jobs/{jobID}/notes [get]
+func (rh *RESTHandler) JobNotesHandler(c echo.Context) error {
+	notes, err := rh.DB.GetJobNotes(c.Param("jobID"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, notes)
+}
+
+// Notes of a job for the status page, errors are logged so that the status is still returned
+func (rh *RESTHandler) jobNotes(c echo.Context, jobID string) []jobs.JobNote {
+	notes, err := rh.DB.GetJobNotes(jobID)
+	if err != nil {
+		requestLogger(c).Errorf("could not get notes of job %s: %s", jobID, err.Error())
+	}
+	return notes
+}
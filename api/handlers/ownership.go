@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"app/utils"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JobOwner returns a middleware restricting job routes to the submitter of the job, admins and service accounts.
+// It is enforced whenever auth is enabled. Unknown jobs are passed to the handler, which responds with not found.
+// Results and widget requests with a valid link token are allowed for anyone.
+func (rh *RESTHandler) JobOwner() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if rh.Config.AuthLevel == 0 || rh.ResultLinkRequest(c) {
+				return next(c)
+			}
+
+			submitter, ok, err := rh.jobSubmitter(c.Param("jobID"))
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+			}
+			if ok && !rh.ownsJob(c, submitter) {
+				return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// Submitter of an active job or of a job recorded in the database, false if job does not exist
+func (rh *RESTHandler) jobSubmitter(jobID string) (string, bool, error) {
+	if job, ok := rh.ActiveJobs.Get(jobID); ok {
+		return (*job).SUBMITTER(), true, nil
+	}
+	jRcrd, ok, err := rh.DB.GetJob(jobID)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return jRcrd.Submitter, true, nil
+}
+
+// Returns true if the user of the request submitted the job or is an admin or service account
+func (rh *RESTHandler) ownsJob(c echo.Context, submitter string) bool {
+	roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+	for _, r := range []string{rh.Config.AdminRoleName, rh.Config.ServiceRoleName} {
+		if r != "" && utils.StringInSlice(r, roles) {
+			return true
+		}
+	}
+	email := c.Request().Header.Get("X-SEPEX-User-Email")
+	return email != "" && email == submitter
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"app/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary Pause Job
+// @Description Pauses a queued job, it keeps its position in the queue but is not started until resumed.
+// @Description Returns 409 if the job is not queued, e.g. it is already running or is not a local async job.
+// @Tags jobs
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobResponse
+// @Router /jobs/{jobID}/pause [post]
+func (rh *RESTHandler) JobPauseHandler(c echo.Context) error {
+	return rh.setJobPaused(c, true)
+}
+
+// @Summary Resume Job
+// @Description Resumes a paused queued job. Jobs of a paused process queue stay queued until the process queue is resumed.
+// @Tags jobs
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobResponse
+// @Router /jobs/{jobID}/resume [post]
+func (rh *RESTHandler) JobResumeHandler(c echo.Context) error {
+	return rh.setJobPaused(c, false)
+}
+
+func (rh *RESTHandler) setJobPaused(c echo.Context, pause bool) error {
+	jobID := c.Param("jobID")
+
+	// Ownership is checked by JobOwner middleware
+	j, ok := rh.ActiveJobs.Get(jobID)
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("job %s not in the active jobs list", jobID)})
+	}
+
+	action := "resumed"
+	if pause {
+		action = "paused"
+		ok = rh.PendingJobs.Pause(jobID)
+	} else {
+		ok = rh.PendingJobs.Resume(jobID)
+	}
+	if !ok {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("job %s is not queued", jobID)})
+	}
+	if !pause {
+		rh.QueueWorker.NotifyNewJob()
+	}
+
+	return c.JSON(http.StatusOK, jobResponse{ProcessID: (*j).ProcessID(), Type: "process", JobID: jobID, Status: (*j).CurrentStatus(), Message: fmt.Sprintf("job %s %s", jobID, action)})
+}
+
+// @Summary Pause Process Queue
+// @Description Stops queued jobs of the process from being started, e.g. during maintenance of a service the process depends on.
+// @Description New jobs are still accepted and queued, running jobs are not affected. Pause state is not persisted across server restarts.
+// @Tags processes
+// @Accept */*
+// @Produce json
+// @Param processID path string true "example: pyecho"
+// @Success 200 {object} map[string]interface{}
+// @Router /processes/{processID}/queue/pause [post]
+func (rh *RESTHandler) ProcessQueuePauseHandler(c echo.Context) error {
+	return rh.setProcessQueuePaused(c, true)
+}
+
+// @Summary Resume Process Queue
+// @Description Allows queued jobs of the process to be started again. Individually paused jobs stay paused.
+// @Tags processes
+// @Accept */*
+// @Produce json
+// @Param processID path string true "example: pyecho"
+// @Success 200 {object} map[string]interface{}
+// @Router /processes/{processID}/queue/resume [post]
+func (rh *RESTHandler) ProcessQueueResumeHandler(c echo.Context) error {
+	return rh.setProcessQueuePaused(c, false)
+}
+
+func (rh *RESTHandler) setProcessQueuePaused(c echo.Context, pause bool) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	processID := c.Param("processID")
+	if _, _, err := rh.ProcessList.Get(processID); err != nil {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("process %s does not exist", processID)})
+	}
+
+	if pause {
+		rh.QueueWorker.PauseProcess(processID)
+	} else {
+		rh.QueueWorker.ResumeProcess(processID)
+	}
+
+	queued := 0
+	for _, j := range rh.PendingJobs.List() {
+		if (*j).ProcessID() == processID {
+			queued++
+		}
+	}
+
+	output := make(map[string]interface{})
+	output["processID"] = processID
+	output["paused"] = pause
+	output["queuedJobs"] = queued
+	return c.JSON(http.StatusOK, output)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"app/jobs"
+	pr "app/processes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Check that all processes of the steps of pipeline p exist and the user may execute them
+func (rh *RESTHandler) checkPipeline(c echo.Context, p pr.Process) *errResponse {
+	for _, step := range p.Pipeline.Steps {
+		sp, _, err := rh.ProcessList.Get(step.Process)
+		if err != nil {
+			return &errResponse{HTTPStatus: http.StatusConflict, Message: fmt.Sprintf("process %s of step %s is not registered", step.Process, step.ID)}
+		}
+		if sp.Host.Type == pr.HostPipeline {
+			return &errResponse{HTTPStatus: http.StatusConflict, Message: fmt.Sprintf("process %s of step %s is a pipeline, pipelines can not be steps", step.Process, step.ID)}
+		}
+		if rh.Config.AuthLevel > 0 && !rh.processAllowed(c, sp) {
+			return &errResponse{HTTPStatus: http.StatusForbidden, Message: fmt.Sprintf("Forbidden: process %s of step %s", step.Process, step.ID)}
+		}
+	}
+	return nil
+}
+
+// pipelineRunner submits the steps of a job of pipeline p, see jobs.PipelineRunner.
+// p is the process the job was submitted with, so that updates of the pipeline do not change running jobs.
+type pipelineRunner struct {
+	rh *RESTHandler
+	p  pr.Process
+}
+
+func (r *pipelineRunner) scope(j *jobs.PipelineJob) pr.PipelineScope {
+	return pr.PipelineScope{Inputs: j.Inputs, Steps: j.StepResults()}
+}
+
+func (r *pipelineRunner) StartStep(j *jobs.PipelineJob, i int) ([]jobs.Job, bool, error) {
+	step := r.p.Pipeline.Steps[i]
+	sp, _, err := r.rh.ProcessList.Get(step.Process)
+	if err != nil {
+		return nil, false, fmt.Errorf("process %s is not registered", step.Process)
+	}
+
+	scope := r.scope(j)
+	items, err := r.p.Pipeline.Items(i, scope)
+	if err != nil {
+		return nil, false, err
+	}
+	fanOut := step.ForEach != ""
+	if !fanOut {
+		items = []interface{}{nil}
+	}
+
+	// All inputs are resolved before the first job is submitted, so that a step does not fail half submitted because of them
+	inputs := make([]map[string]interface{}, len(items))
+	for k, item := range items {
+		scope.Item = item
+		if inputs[k], err = r.p.Pipeline.StepInputs(i, scope); err != nil {
+			return nil, fanOut, err
+		}
+		if err := sp.VerifyInputs(inputs[k]); err != nil {
+			return nil, fanOut, fmt.Errorf("step %s: %v", step.ID, err)
+		}
+	}
+
+	resources, err := sp.JobResources(nil)
+	if err != nil {
+		return nil, fanOut, fmt.Errorf("step %s: %v", step.ID, err)
+	}
+	runs := make([]jobs.Job, 0, len(items))
+	for _, in := range inputs {
+		run, err := r.rh.submitStep(j, sp, in, resources, nil)
+		if err != nil {
+			return runs, fanOut, fmt.Errorf("step %s: %v", step.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, fanOut, nil
+}
+
+func (r *pipelineRunner) RunResults(run jobs.Job) (interface{}, error) {
+	// Like sync execute responses, jobs of processes without outputs have no results
+	if sp, _, err := r.rh.ProcessList.Get(run.ProcessID()); err == nil && sp.Outputs == nil {
+		return nil, nil
+	}
+	return jobs.FetchResults(r.rh.StorageSvc, run.JobID(), run.TENANT())
+}
+
+func (r *pipelineRunner) Results(j *jobs.PipelineJob) (interface{}, error) {
+	return r.p.Pipeline.Results(r.scope(j))
+}
+
+func (r *pipelineRunner) Dismiss(run jobs.Job) error {
+	return r.rh.dismissJob(&run)
+}
+
+// Create and start a job of process p with inputs, resources and env overrides for a step of pipeline job j,
+// as async job of the submitter of j with the log level of j
+func (rh *RESTHandler) submitStep(j *jobs.PipelineJob, p pr.Process, inputs map[string]interface{}, resources pr.Resources, env map[string]string) (jobs.Job, error) {
+	jsonParams, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := jobCommand(p, inputs, jsonParams)
+	if err != nil {
+		return nil, err
+	}
+	s := submission{Inputs: jsonParams, Cmd: cmd, Resources: resources, Env: env, LogLevel: j.LogLevel}
+	inputHash := jobs.InputHash(p.Info.ID, p.Info.Version, s.Inputs, s.Env, "")
+	run, errResp := rh.createJob(jobOrigin{Submitter: j.SUBMITTER(), Tenant: j.TENANT(), RequestID: j.RequestID, ParentID: j.UUID}, p, s, "async-execute", inputHash)
+	if errResp != nil {
+		return nil, errors.New(errResp.Message)
+	}
+	rh.startJob(run, p.Host.Type)
+	return run, nil
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"app/processes"
+	"app/utils"
+	"strings"
+)
+
+// Data types of inputs that are references to files, e.g. s3:// or https:// links, the execute form asks for a URL
+var hrefDataTypes = []string{"href", "uri", "url", "file"}
+
+// formInputType returns the kind of field of an input in the execute form of the process page:
+// select for inputs with possible values, number, checkbox, url for file and href inputs, or text
+func formInputType(in processes.Inputs) string {
+	d := in.Input.LiteralDataDomain
+	if !d.ValueDefinition.AnyValue && len(d.ValueDefinition.PossibleValues) > 0 {
+		return "select"
+	}
+	switch dt := strings.ToLower(d.DataType); {
+	case dt == "integer" || dt == "int" || dt == "number" || dt == "float" || dt == "double":
+		return "number"
+	case dt == "boolean" || dt == "bool":
+		return "checkbox"
+	case utils.StringInSlice(dt, hrefDataTypes):
+		return "url"
+	default:
+		return "text"
+	}
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"app/config"
+	"app/events"
 	"app/processes"
 	"app/utils"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -16,9 +23,17 @@ import (
 // ProcessListHandler godoc
 // @Summary List Available Processes
 // @Description [Process List Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#sc_process_list)
+// @Description Processes can be searched with `q`, every word must be in the ID, title, description or keywords, and filtered by `type`, `keyword` and `tag`.
+// @Description Matching is case insensitive, `numberMatched` is the number of processes matching before pagination.
 // @Tags processes
 // @Accept */*
 // @Produce json
+// @Param q query string false "free text search"
+// @Param type query string false "host type: docker, aws-batch, subprocess or pipeline"
+// @Param keyword query string false "keyword of the process"
+// @Param tag query string false "tag of the process"
+// @Param limit query int false "max number of processes, 1 to 100, default 20"
+// @Param offset query int false "number of processes skipped"
 // @Success 200 {object} map[string]interface{}
 // @Router /processes [get]
 func (rh *RESTHandler) ProcessListHandler(c echo.Context) error {
@@ -41,33 +56,58 @@ func (rh *RESTHandler) ProcessListHandler(c echo.Context) error {
 		offset = 0
 	}
 
-	// instantiate result variable without importing processes pkg
-	result := rh.ProcessList.InfoList[0:0]
+	query := processes.ProcessQuery{
+		Text:     c.QueryParam("q"),
+		HostType: c.QueryParam("type"),
+		Keyword:  c.QueryParam("keyword"),
+		Tag:      c.QueryParam("tag"),
+	}
+	if hostTypes := processes.HostTypes(); query.HostType != "" && !utils.StringInSlice(query.HostType, hostTypes) {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: fmt.Sprintf("Invalid option for query parameter 'type'. Valid options are '%s'.", strings.Join(hostTypes, "', '"))})
+	}
+	matched := rh.ProcessList.Search(query)
 
-	if offset < len(rh.ProcessList.InfoList) {
+	result := matched[0:0]
+	if offset < len(matched) {
 		upperBound := offset + limit
-		if upperBound > len(rh.ProcessList.InfoList) {
-			upperBound = len(rh.ProcessList.InfoList)
+		if upperBound > len(matched) {
+			upperBound = len(matched)
+		}
+		result = matched[offset:upperBound]
+	}
+	for i := range result {
+		if p, _, err := rh.ProcessList.Get(result[i].ID); err == nil {
+			result[i].Readiness = rh.ProcessList.ReadinessOf(p).State
 		}
-		result = rh.ProcessList.InfoList[offset:upperBound]
 	}
 
-	// required by /req/core/process-list-success
+	// required by /req/core/process-list-success, links keep the search parameters
 	links := make([]link, 0)
+	pageLink := func(offset int) string {
+		params := url.Values{}
+		for _, k := range []string{"q", "type", "keyword", "tag", "f"} {
+			if v := c.QueryParam(k); v != "" {
+				params.Set(k, v)
+			}
+		}
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("limit", strconv.Itoa(limit))
+		return "/processes?" + params.Encode()
+	}
 
 	// if offset is not 0
 	if offset != 0 {
 		lnk := link{
-			Href:  fmt.Sprintf("/processes?offset=%v&limit=%v", offset-limit, limit),
+			Href:  pageLink(max(offset-limit, 0)),
 			Title: "prev",
 		}
 		links = append(links, lnk)
 	}
 
 	// if limit is not exhausted
-	if limit == len(result) {
+	if offset+limit < len(matched) {
 		lnk := link{
-			Href:  fmt.Sprintf("/processes?offset=%v&limit=%v", offset+limit, limit),
+			Href:  pageLink(offset + limit),
 			Title: "next",
 		}
 		links = append(links, lnk)
@@ -75,6 +115,7 @@ func (rh *RESTHandler) ProcessListHandler(c echo.Context) error {
 
 	output := make(map[string]interface{}, 0)
 	output["processes"] = result
+	output["numberMatched"] = len(matched)
 	output["links"] = links
 
 	return prepareResponse(c, http.StatusOK, "processes", output)
@@ -85,6 +126,7 @@ func (rh *RESTHandler) ProcessListHandler(c echo.Context) error {
 // @Description [Process Description Specification](https://docs.ogc.org/is/18-062r2/18-062r2.html#sc_process_description)
 // @Tags processes
 // @Param processID path string true "example: pyecho"
+// @Param profile query string false "sepex or ogc, ogc describes inputs and outputs as maps of JSON schemas like pygeoapi and GeoServer; default PROCESS_DESCRIPTION_PROFILE"
 // @Accept */*
 // @Produce json
 // @Success 200 {object} processes.processDescription
@@ -96,19 +138,90 @@ func (rh *RESTHandler) ProcessDescribeHandler(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	profile := c.QueryParam("profile")
+	if profile == "" {
+		profile = config.Get().API.DescriptionProfile
+	}
+	if profile != processes.ProfileSepex && profile != processes.ProfileOGC {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "Invalid option for query parameter 'profile'. Valid options are 'sepex' or 'ogc'."})
+	}
 
 	p, _, err := rh.ProcessList.Get(processID)
 	if err != nil {
 		return prepareResponse(c, http.StatusBadRequest, "error", errResponse{Message: err.Error(), HTTPStatus: http.StatusBadRequest})
 	}
 
+	// processes without access block are public
+	if rh.Config.AuthLevel > 0 && p.Access != nil && !rh.processAllowed(c, p) {
+		return prepareResponse(c, http.StatusForbidden, "error", errResponse{Message: "Forbidden", HTTPStatus: http.StatusForbidden})
+	}
+
+	// the OGC profile only changes JSON responses, HTML pages render the sepex description
+	if profile == processes.ProfileOGC && !respondsHTML(c) {
+		return c.JSON(http.StatusOK, p.DescribeOGC())
+	}
+
 	description, err := p.Describe()
 	if err != nil {
 		return prepareResponse(c, http.StatusInternalServerError, "error", errResponse{Message: err.Error(), HTTPStatus: http.StatusInternalServerError})
 	}
+	description.Readiness = rh.ProcessList.ReadinessOf(p).State
 	return prepareResponse(c, http.StatusOK, "process", description)
 }
 
+// ProcessSchemaHandler godoc
+// @Summary Process Spec Schema
+// @Description JSON Schema process YAMLs and the bodies of POST and PUT /processes/{processID} are validated against,
+// @Description can be used by editors to validate specs while writing them.
+// @Tags processes
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /schemas/process [get]
+func (rh *RESTHandler) ProcessSchemaHandler(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/schema+json", processes.ProcessSchema())
+}
+
+// EventSchemaHandler godoc
+// @Summary Event Schema
+// @Description AsyncAPI 2.6 document of the events this server emits: webhook deliveries, CloudEvents published to the configured broker
+// @Description and the Server-Sent Events of /jobs/{jobID}/events. Payload schemas are generated from the event types, consumers can generate handlers from it.
+// @Tags info
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /schemas/events [get]
+// Does not produce HTML
+func (rh *RESTHandler) EventSchemaHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, events.AsyncAPIDocument(config.Get(), rh.Title, rh.GitTag, publicBaseURL(c)))
+}
+
+type specErrorResponse struct {
+	Message string               `json:"message"`
+	Errors  processes.SpecErrors `json:"errors"`
+}
+
+// Read a process spec from the request body after validating it against the process schema
+func bindProcessSpec(c echo.Context) (processes.Process, error) {
+	var p processes.Process
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return p, err
+	}
+	if err := processes.ValidateSpec(body); err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(body, &p)
+	return p, err
+}
+
+// Respond with all problems if err is a schema validation error, msg otherwise
+func invalidSpecResponse(c echo.Context, err error, msg string) error {
+	var specErrs processes.SpecErrors
+	if errors.As(err, &specErrs) {
+		return c.JSON(http.StatusBadRequest, specErrorResponse{Message: "Invalid process spec", Errors: specErrs})
+	}
+	return c.JSON(http.StatusBadRequest, errResponse{Message: msg})
+}
+
 // AddProcessHandler adds a new process configuration
 func (rh *RESTHandler) AddProcessHandler(c echo.Context) error {
 
@@ -121,16 +234,19 @@ func (rh *RESTHandler) AddProcessHandler(c echo.Context) error {
 		}
 	}
 
+	if rh.ProcessRegistry != nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("processes are loaded from %s, change them in the repository", rh.ProcessRegistry.URL)})
+	}
+
 	processID := c.Param("processID")
 	_, _, err := rh.ProcessList.Get(processID)
 	if err == nil {
 		return prepareResponse(c, http.StatusBadRequest, "error", errResponse{Message: "Process already exist. Use PUT method to update", HTTPStatus: http.StatusBadRequest})
 	}
 
-	var newProcess processes.Process
-
-	if err := c.Bind(&newProcess); err != nil {
-		return c.JSON(http.StatusBadRequest, errResponse{Message: "Invalid process data"})
+	newProcess, err := bindProcessSpec(c)
+	if err != nil {
+		return invalidSpecResponse(c, err, "Invalid process data")
 	}
 
 	bodyProcessID := newProcess.Info.ID
@@ -138,13 +254,16 @@ func (rh *RESTHandler) AddProcessHandler(c echo.Context) error {
 		return prepareResponse(c, http.StatusBadRequest, "error", errResponse{Message: "Process ID mismatch", HTTPStatus: http.StatusBadRequest})
 	}
 
-	err = newProcess.Validate(rh.Config.ResourceLimits.MaxCPUs, rh.Config.ResourceLimits.MaxMemory)
+	err = newProcess.Validate(rh.ResourcePool.Limits())
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
 	}
+	if err := newProcess.RegisterJobDefinition(); err != nil {
+		return c.JSON(http.StatusBadGateway, errResponse{Message: "could not register job definition: " + err.Error()})
+	}
 
-	pluginsDir := os.Getenv("PLUGINS_DIR") // We already know this env variable exist because it is being checked in plguinsInit function
-	filename := fmt.Sprintf("%s/%s/%s.yml", pluginsDir, processID, processID)
+	pluginsDir := config.Get().Plugins.Dir
+	filename := filepath.Join(pluginsDir, processID, processID+".yml")
 
 	data, err := yaml.Marshal(newProcess)
 	if err != nil {
@@ -152,7 +271,7 @@ func (rh *RESTHandler) AddProcessHandler(c echo.Context) error {
 	}
 
 	// Destination directory
-	destDir := fmt.Sprintf("%s/%s", pluginsDir, processID)
+	destDir := filepath.Join(pluginsDir, processID)
 
 	// Create the destination directory including all intermediate directories
 	err = os.MkdirAll(destDir, 0755)
@@ -183,6 +302,10 @@ func (rh *RESTHandler) UpdateProcessHandler(c echo.Context) error {
 		}
 	}
 
+	if rh.ProcessRegistry != nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("processes are loaded from %s, change them in the repository", rh.ProcessRegistry.URL)})
+	}
+
 	processID := c.Param("processID")
 
 	oldProcess, i, err := rh.ProcessList.Get(processID)
@@ -190,41 +313,48 @@ func (rh *RESTHandler) UpdateProcessHandler(c echo.Context) error {
 		return prepareResponse(c, http.StatusBadRequest, "error", errResponse{Message: "Process does not exist", HTTPStatus: http.StatusBadRequest})
 	}
 
-	var updatedProcess processes.Process
-
-	if err := c.Bind(&updatedProcess); err != nil {
-		return c.JSON(http.StatusBadRequest, errResponse{Message: "Invalid process data, partial updates are not allowed"})
+	updatedProcess, err := bindProcessSpec(c)
+	if err != nil {
+		return invalidSpecResponse(c, err, "Invalid process data, partial updates are not allowed")
 	}
 
 	if processID != updatedProcess.Info.ID {
 		return c.JSON(http.StatusBadRequest, errResponse{Message: "Process ID mismatch"})
 	}
 
-	err = updatedProcess.Validate(rh.Config.ResourceLimits.MaxCPUs, rh.Config.ResourceLimits.MaxMemory)
+	err = updatedProcess.Validate(rh.ResourcePool.Limits())
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
 	}
+	if err := updatedProcess.RegisterJobDefinition(); err != nil {
+		return c.JSON(http.StatusBadGateway, errResponse{Message: "could not register job definition: " + err.Error()})
+	}
 
-	pluginsDir := os.Getenv("PLUGINS_DIR") // We already know this env variable exist because it is being checked in plguinsInit function
-	filename := fmt.Sprintf("%s/%s/%s.yml", pluginsDir, processID, processID)
+	pluginsDir := config.Get().Plugins.Dir
+	filename := filepath.Join(pluginsDir, processID, processID+".yml")
 
 	oldV := oldProcess.Info.Version
 
 	// to do: this should be atomic
 
-	// Destination directory
-	destDir := fmt.Sprintf("%s/deprecated/%s", pluginsDir, processID)
+	// Processes of read-only PLUGINS_DIRS have no file to deprecate, the updated spec overrides them
+	if _, err := os.Stat(filename); err == nil {
+		// Destination directory
+		destDir := filepath.Join(pluginsDir, "deprecated", processID)
 
-	// Create the destination directory including all intermediate directories
-	err = os.MkdirAll(destDir, 0755)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
-	}
+		// Create the destination directory including all intermediate directories
+		err = os.MkdirAll(destDir, 0755)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
+		}
 
-	// Move the file
-	err = os.Rename(filename, fmt.Sprintf("%s/%s_%s.yml", destDir, processID, oldV))
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
+		// Move the file
+		err = os.Rename(filename, filepath.Join(destDir, fmt.Sprintf("%s_%s.yml", processID, oldV)))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
+		}
+	} else if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to create process directory"})
 	}
 
 	data, err := yaml.Marshal(updatedProcess)
@@ -254,6 +384,10 @@ func (rh *RESTHandler) DeleteProcessHandler(c echo.Context) error {
 		}
 	}
 
+	if rh.ProcessRegistry != nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("processes are loaded from %s, change them in the repository", rh.ProcessRegistry.URL)})
+	}
+
 	processID := c.Param("processID")
 
 	oldProcess, i, err := rh.ProcessList.Get(processID)
@@ -261,15 +395,18 @@ func (rh *RESTHandler) DeleteProcessHandler(c echo.Context) error {
 		return prepareResponse(c, http.StatusBadRequest, "error", errResponse{Message: "Process does not exist", HTTPStatus: http.StatusBadRequest})
 	}
 
-	pluginsDir := os.Getenv("PLUGINS_DIR") // We already know this env variable exist because it is being checked in plguinsInit function
-	filename := fmt.Sprintf("%s/%s/%s.yml", pluginsDir, processID, processID)
+	pluginsDir := config.Get().Plugins.Dir
+	filename := filepath.Join(pluginsDir, processID, processID+".yml")
+	if _, err := os.Stat(filename); err != nil {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("process %s is defined in a read-only plugin directory of PLUGINS_DIRS and can not be deleted", processID)})
+	}
 
 	oldV := oldProcess.Info.Version
 
 	// to do: this should be atomic
 
 	// Create the destination directory including all intermediate directories
-	destDir := fmt.Sprintf("%s/deprecated/%s", pluginsDir, processID)
+	destDir := filepath.Join(pluginsDir, "deprecated", processID)
 
 	err = os.MkdirAll(destDir, 0755)
 	if err != nil {
@@ -277,11 +414,25 @@ func (rh *RESTHandler) DeleteProcessHandler(c echo.Context) error {
 	}
 
 	// Move the file
-	err = os.Rename(filename, fmt.Sprintf("%s/%s_%s.yml", destDir, processID, oldV))
+	err = os.Rename(filename, filepath.Join(destDir, fmt.Sprintf("%s_%s.yml", processID, oldV)))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, errResponse{Message: "Failed to deprecate old process"})
 	}
 
+	// The process of read-only PLUGINS_DIRS it overrode is used again, like after a restart
+	base, found, err := processes.LoadLayeredProcess(config.Get().Plugins.Dirs, processID)
+	if err == nil && found {
+		err = base.Validate(rh.ResourcePool.Limits())
+	}
+	if err != nil {
+		requestLogger(c).Errorf("could not restore process %s of PLUGINS_DIRS: %s", processID, err.Error())
+	}
+	if err == nil && found {
+		rh.ProcessList.List[i] = base
+		rh.ProcessList.InfoList[i] = base.Info
+		return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Process deleted successfully, version %s of PLUGINS_DIRS is used again", base.Info.Version)})
+	}
+
 	rh.ProcessList.List = append(rh.ProcessList.List[:i], rh.ProcessList.List[i+1:]...)
 	rh.ProcessList.InfoList = append(rh.ProcessList.InfoList[:i], rh.ProcessList.InfoList[i+1:]...)
 
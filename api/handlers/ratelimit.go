@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"app/auth"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Clients idle for this long with a full bucket are forgotten
+const rateLimitClientTTL = 10 * time.Minute
+
+// RateLimiter limits requests per client with token buckets.
+// A bucket holds up to Limit tokens and is refilled at Limit tokens per Period, every request takes one token.
+type RateLimiter struct {
+	Limit  int
+	Period time.Duration
+	KeyBy  string // ip | submitter | key
+
+	mu      sync.Mutex
+	clients map[string]*rateClient
+}
+
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a rate limiter from spec set in the env variable or config key name, e.g. RATE_LIMIT_EXECUTE='10/m'.
+// Clients are identified according to keyBy (RATE_LIMIT_BY). Returns nil if spec is empty.
+func NewRateLimiter(name, spec, keyBy string) (*RateLimiter, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	limit, period, err := parseRate(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+
+	switch keyBy {
+	case "":
+		keyBy = "ip"
+	case "ip", "submitter", "key":
+	default:
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BY: %s, valid options are ip, submitter, key", keyBy)
+	}
+
+	rl := &RateLimiter{Limit: limit, Period: period, KeyBy: keyBy, clients: make(map[string]*rateClient)}
+	go rl.cleanup()
+	log.Infof("Rate limit %s: %d requests per %s per %s", name, limit, period, keyBy)
+	return rl, nil
+}
+
+// Parse a rate of the form <requests>/<s|m|h>, e.g. 10/m
+func parseRate(s string) (int, time.Duration, error) {
+	countStr, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected <requests>/<s|m|h>, found %s", s)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 {
+		return 0, 0, fmt.Errorf("requests must be a positive integer, found %s", countStr)
+	}
+
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return 0, 0, fmt.Errorf("period must be one of s, m, h, found %s", unit)
+	}
+	return count, period, nil
+}
+
+// Key identifying the client of the request. Submitters and keys are only used once auth verified them, clients could
+// otherwise send another email or Authorization header with every request and get a new bucket each time.
+func (rl *RateLimiter) clientKey(c echo.Context) string {
+	if !auth.Authenticated(c) {
+		return "ip:" + c.RealIP()
+	}
+	switch rl.KeyBy {
+	case "submitter":
+		if email := c.Request().Header.Get("X-SEPEX-User-Email"); email != "" {
+			return "submitter:" + email
+		}
+	case "key":
+		if authHead := c.Request().Header.Get("Authorization"); authHead != "" {
+			sum := sha256.Sum256([]byte(authHead)) // do not keep credentials in memory
+			return "key:" + hex.EncodeToString(sum[:])
+		}
+	}
+	return "ip:" + c.RealIP()
+}
+
+func (rl *RateLimiter) limiter(key string, now time.Time) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rc, ok := rl.clients[key]
+	if !ok {
+		rc = &rateClient{limiter: rate.NewLimiter(rate.Limit(float64(rl.Limit)/rl.Period.Seconds()), rl.Limit)}
+		rl.clients[key] = rc
+	}
+	rc.lastSeen = now
+	return rc.limiter
+}
+
+// Remove idle clients periodically so that memory does not grow with the number of clients seen
+func (rl *RateLimiter) cleanup() {
+	ticker := time.NewTicker(rateLimitClientTTL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		rl.mu.Lock()
+		for key, rc := range rl.clients {
+			if now.Sub(rc.lastSeen) > rateLimitClientTTL && rc.limiter.TokensAt(now) >= float64(rl.Limit) {
+				delete(rl.clients, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// RateLimit returns a middleware rejecting requests with 429 once the client has used up its bucket.
+// Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, rejected ones also Retry-After.
+// A nil rate limiter lets all requests through.
+func (rh *RESTHandler) RateLimit(rl *RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if rl == nil {
+			return next
+		}
+		return func(c echo.Context) error {
+			now := time.Now()
+			lim := rl.limiter(rl.clientKey(c), now)
+			allowed := lim.AllowN(now, 1)
+
+			tokens := math.Max(lim.TokensAt(now), 0)
+			perSecond := float64(lim.Limit())
+			h := c.Response().Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(rl.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+			h.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(rl.Limit)-tokens)/perSecond))))
+
+			if !allowed {
+				retryAfter := int(math.Ceil((1 - tokens) / perSecond))
+				h.Set("Retry-After", strconv.Itoa(retryAfter))
+				return c.JSON(http.StatusTooManyRequests, errResponse{Message: fmt.Sprintf("rate limit exceeded, retry after %d seconds", retryAfter)})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"app/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// Value of an output in a raw results response, elements of array outputs are values of their own
+type rawValue struct {
+	OutputID string
+	Value    interface{}
+}
+
+// Opened raw value, either a body with its content type or only the location of a reference that is not streamed
+type rawBody struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64 // -1 if unknown
+	Location      string
+	Filename      string
+}
+
+// Values of the outputs selected in the execute request, all outputs if none were selected, ordered by output ID.
+// Results that are not a map of outputs are a single value.
+func rawValues(results interface{}, requested json.RawMessage) []rawValue {
+	m, ok := results.(map[string]interface{})
+	if !ok {
+		if results == nil {
+			return nil
+		}
+		return []rawValue{{Value: results}}
+	}
+
+	var selected map[string]interface{}
+	if len(requested) > 0 {
+		_ = json.Unmarshal(requested, &selected)
+	}
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		if _, ok := selected[id]; ok || len(selected) == 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var values []rawValue
+	for _, id := range ids {
+		if arr, ok := m[id].([]interface{}); ok {
+			for _, e := range arr {
+				values = append(values, rawValue{OutputID: id, Value: e})
+			}
+			continue
+		}
+		values = append(values, rawValue{OutputID: id, Value: m[id]})
+	}
+	return values
+}
+
+// Write results as raw output values, response=raw of OGC API - Processes. A single value is returned as is with its
+// media type, several values as parts of a multipart/related response with the output ID as Content-ID.
+// Objects in the storage bucket referenced by s3:// URLs are streamed, other references are returned in
+// Content-Location, a single http(s) reference is redirected to with 303. Strings are returned as text/plain,
+// other values as application/json.
+func (rh *RESTHandler) writeRawResults(c echo.Context, results interface{}, requested json.RawMessage) error {
+	values := rawValues(results, requested)
+	switch len(values) {
+	case 0:
+		return c.NoContent(http.StatusNoContent)
+	case 1:
+		return rh.writeRawValue(c, values[0])
+	}
+
+	// Values are opened before the status is written so that storage errors are responded like for a single value.
+	// Errors while writing parts can not change the status anymore, the multipart body is still closed.
+	bodies := make([]rawBody, 0, len(values))
+	defer func() {
+		for _, b := range bodies {
+			if b.Body != nil {
+				b.Body.Close()
+			}
+		}
+	}()
+	for _, v := range values {
+		b, err := rh.openRawValue(v.Value)
+		if err != nil {
+			return rawValueError(c, v, err)
+		}
+		bodies = append(bodies, b)
+	}
+
+	mw := multipart.NewWriter(c.Response())
+	c.Response().Header().Set(echo.HeaderContentType, "multipart/related; boundary="+mw.Boundary())
+	c.Response().WriteHeader(http.StatusOK)
+	for i, v := range values {
+		b := bodies[i]
+		h := textproto.MIMEHeader{}
+		if v.OutputID != "" {
+			h.Set("Content-ID", "<"+v.OutputID+">")
+		}
+		if b.Location != "" {
+			h.Set("Content-Location", b.Location)
+		} else {
+			h.Set(echo.HeaderContentType, b.ContentType)
+		}
+		pw, err := mw.CreatePart(h)
+		if err == nil && b.Body != nil {
+			_, err = io.Copy(pw, b.Body)
+		}
+		if err != nil {
+			requestLogger(c).Errorf("Could not write output %s of raw results: %s", v.OutputID, err.Error())
+			break
+		}
+	}
+	return mw.Close()
+}
+
+// Respond with the error of opening raw value v
+func rawValueError(c echo.Context, v rawValue, err error) error {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("object of output %s not found", v.OutputID)})
+	}
+	return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+}
+
+// Write a single raw value as the response
+func (rh *RESTHandler) writeRawValue(c echo.Context, v rawValue) error {
+	b, err := rh.openRawValue(v.Value)
+	if err != nil {
+		return rawValueError(c, v, err)
+	}
+
+	h := c.Response().Header()
+	if b.Location != "" {
+		h.Set("Content-Location", b.Location)
+		if u, err := url.Parse(b.Location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return c.Redirect(http.StatusSeeOther, b.Location)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+	defer b.Body.Close()
+
+	h.Set(echo.HeaderContentType, b.ContentType)
+	if b.ContentLength >= 0 {
+		h.Set(echo.HeaderContentLength, strconv.FormatInt(b.ContentLength, 10))
+	}
+	if b.Filename != "" {
+		h.Set(echo.HeaderContentDisposition, "inline; filename=\""+b.Filename+"\"")
+	}
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = io.Copy(c.Response(), b.Body)
+	return err
+}
+
+// Open a raw value, references are links {"href": ..., "type": ...} or URL strings, see LinkOutputs.
+// Callers must close the body.
+func (rh *RESTHandler) openRawValue(v interface{}) (rawBody, error) {
+	href, mediaType := "", ""
+	switch vv := v.(type) {
+	case string:
+		if !strings.Contains(vv, "://") {
+			return rawBody{Body: io.NopCloser(strings.NewReader(vv)), ContentType: echo.MIMETextPlainCharsetUTF8, ContentLength: int64(len(vv))}, nil
+		}
+		href = vv
+	case map[string]interface{}:
+		href, _ = vv["href"].(string)
+		mediaType, _ = vv["type"].(string)
+	}
+	if href == "" {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return rawBody{}, err
+		}
+		return rawBody{Body: io.NopCloser(strings.NewReader(string(b))), ContentType: echo.MIMEApplicationJSON, ContentLength: int64(len(b))}, nil
+	}
+
+	// Only objects of the storage bucket are served with the credentials of the server
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "s3" || u.Host != config.Get().Storage.Bucket {
+		return rawBody{Location: href}, nil
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	out, err := rh.StorageSvc.GetObject(&s3.GetObjectInput{Bucket: aws.String(u.Host), Key: aws.String(key)})
+	if err != nil {
+		return rawBody{}, err
+	}
+	if mediaType == "" {
+		mediaType = aws.StringValue(out.ContentType)
+	}
+	if mediaType == "" {
+		mediaType = echo.MIMEOctetStream
+	}
+	length := int64(-1)
+	if out.ContentLength != nil {
+		length = *out.ContentLength
+	}
+	return rawBody{Body: out.Body, ContentType: mediaType, ContentLength: length, Filename: path.Base(key)}, nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"app/controllers"
+	"app/jobs"
+	"app/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// Resources younger than this are never reaped, so that a container started by a job that is being registered is not removed
+const orphanMinAge = time.Minute
+
+type orphanResource struct {
+	controllers.JobResource
+	Removed bool   `json:"removed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type orphansResponse struct {
+	DryRun    bool             `json:"dryRun"`
+	Orphans   []orphanResource `json:"orphans"`
+	Removed   int              `json:"removed"`
+	Failed    int              `json:"failed"`
+	CheckedAt time.Time        `json:"checkedAt"`
+}
+
+// Find containers labeled with IDs of jobs that are not active, e.g. left behind by a server crash, and scratch
+// directories and inputs files of such jobs, and remove them unless dryRun is set. Scratch entries are reaped
+// even if docker is not available.
+func (rh *RESTHandler) reapOrphans(ctx context.Context, dryRun bool) (orphansResponse, error) {
+	resp := orphansResponse{DryRun: dryRun, Orphans: make([]orphanResource, 0), CheckedAt: time.Now()}
+
+	active := make(map[string]bool)
+	for _, j := range rh.ActiveJobs.List() {
+		active[(*j).JobID()] = true
+	}
+	orphaned := func(r controllers.JobResource) bool {
+		return !active[r.JobID] && resp.CheckedAt.Sub(r.Created) >= orphanMinAge
+	}
+	reap := func(r controllers.JobResource, remove func() error) {
+		o := orphanResource{JobResource: r}
+		if !dryRun {
+			if err := remove(); err != nil {
+				o.Error = err.Error()
+				resp.Failed++
+			} else {
+				o.Removed = true
+				resp.Removed++
+			}
+		}
+		resp.Orphans = append(resp.Orphans, o)
+	}
+
+	// scratch entries are removed when jobs close, jobs are added to ActiveJobs after their scratch directory was created
+	entries, err := jobs.ScratchEntries()
+	if err != nil {
+		return resp, err
+	}
+	for _, e := range entries {
+		r := controllers.JobResource{ID: e.Name, JobID: e.JobID, Kind: "scratch", Created: e.Modified}
+		if orphaned(r) {
+			reap(r, func() error { return jobs.RemoveScratchEntry(e.Name) })
+		}
+	}
+
+	c, err := rh.Docker.Get()
+	if err != nil {
+		return resp, err
+	}
+	resources, err := c.ListJobResources(ctx, rh.Name)
+	if err != nil {
+		return resp, err
+	}
+	for _, r := range resources {
+		if orphaned(r) {
+			reap(r, func() error { return c.ContainerRemove(ctx, r.ID) })
+		}
+	}
+	return resp, nil
+}
+
+// OrphanReaperRoutine removes orphaned containers and scratch entries at startup and then every interval, 0 disables it.
+func (rh *RESTHandler) OrphanReaperRoutine(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	reap := func() {
+		resp, err := rh.reapOrphans(context.Background(), false)
+		if err != nil {
+			log.Warnf("Orphan reaper could not list job resources: %s", err.Error())
+		}
+		for _, o := range resp.Orphans {
+			if o.Error != "" {
+				log.Errorf("Orphan reaper could not remove %s %s of job %s: %s", o.Kind, o.ID, o.JobID, o.Error)
+			} else {
+				log.Infof("Orphan reaper removed %s %s of job %s", o.Kind, o.ID, o.JobID)
+			}
+		}
+	}
+
+	// Containers of jobs that were running when the server stopped are orphaned right away
+	reap()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reap()
+	}
+}
+
+// @Summary Orphaned Job Resources
+// @Description Lists containers labeled with IDs of jobs that are no longer active, e.g. after a server crash, and scratch directories and inputs files of such jobs.
+// @Description This is a dry run of the orphan reaper, nothing is removed. Resources created less than a minute ago are not considered orphaned.
+// @Description Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} orphansResponse
+// @Router /admin/orphans [get]
+func (rh *RESTHandler) OrphansHandler(c echo.Context) error {
+	return rh.respondOrphans(c, true)
+}
+
+// @Summary Reap Orphaned Job Resources
+// @Description Removes containers and scratch entries listed by `GET /admin/orphans` now instead of waiting for the next run of the orphan reaper.
+// @Description Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} orphansResponse
+// @Router /admin/orphans/reap [post]
+func (rh *RESTHandler) ReapOrphansHandler(c echo.Context) error {
+	return rh.respondOrphans(c, false)
+}
+
+func (rh *RESTHandler) respondOrphans(c echo.Context, dryRun bool) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	resp, err := rh.reapOrphans(c.Request().Context(), dryRun)
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, errResponse{Message: fmt.Sprintf("could not list job resources: %s", err.Error())})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"app/config"
+	pr "app/processes"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProcessRegistrySyncRoutine syncs the git process registry every sync interval and reloads processes when the commit changed.
+// Jobs already created keep running with the definition they were created with.
+func (rh *RESTHandler) ProcessRegistrySyncRoutine() {
+	if rh.ProcessRegistry == nil || rh.ProcessRegistry.SyncInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(rh.ProcessRegistry.SyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		commit, err := rh.ProcessRegistry.Sync()
+		if err != nil {
+			log.Errorf("Could not sync process registry %s: %s", rh.ProcessRegistry.URL, err.Error())
+			continue
+		}
+		if commit == rh.registryCommit {
+			continue
+		}
+
+		maxCPUs, maxMemory := rh.ResourcePool.Limits()
+		layers := append(append([]string{}, config.Get().Plugins.Dirs...), rh.ProcessRegistry.ProcessesDir())
+		processList, err := pr.LoadProcesses(layers, maxCPUs, maxMemory)
+		if err != nil {
+			log.Errorf("Could not load processes of %s at %s: %s", rh.ProcessRegistry.URL, commit, err.Error())
+			continue
+		}
+		*rh.ProcessList = processList
+		rh.registryCommit = commit
+		log.Infof("Loaded %d processes from %s at %s", len(processList.List), rh.ProcessRegistry.URL, commit)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"app/config"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Error of requests exceeding a limit, path is the JSON pointer of the value exceeding it
+type limitErrResponse struct {
+	Message string `json:"message"`
+	Limit   int    `json:"limit"`
+	Path    string `json:"path,omitempty"`
+}
+
+// ExecuteLimits returns a middleware rejecting execute requests whose body is larger than MAX_EXECUTE_BODY_KB with 413,
+// and bodies with strings longer than MAX_INPUT_STRING_LENGTH characters or arrays with more than MAX_INPUT_ARRAY_LENGTH
+// elements with 422. Limits of 0 are disabled. Bodies that are not valid JSON are left to the handler.
+func (rh *RESTHandler) ExecuteLimits() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cfg := config.Get().API
+			maxBody := int64(cfg.MaxExecuteBodyKB) << 10
+			req := c.Request()
+			if req.Body == nil || (maxBody <= 0 && cfg.MaxInputStringLength <= 0 && cfg.MaxInputArrayLength <= 0) {
+				return next(c)
+			}
+
+			tooLarge := limitErrResponse{Message: fmt.Sprintf("request body must not be larger than %dKB", cfg.MaxExecuteBodyKB), Limit: cfg.MaxExecuteBodyKB}
+			if maxBody > 0 && req.ContentLength > maxBody {
+				return c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+			}
+			var reader io.Reader = req.Body
+			if maxBody > 0 {
+				reader = io.LimitReader(req.Body, maxBody+1)
+			}
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, errResponse{Message: "could not read request body"})
+			}
+			if status, resp := executeBodyLimitsError(body); resp != nil {
+				return c.JSON(status, resp)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return next(c)
+		}
+	}
+}
+
+// Status and error of an execute request body exceeding the limits of ExecuteLimits, nil if it is within them.
+// Also used for submissions that do not pass the middleware, e.g. of the gRPC service.
+func executeBodyLimitsError(body []byte) (int, *limitErrResponse) {
+	cfg := config.Get().API
+	if maxBody := int64(cfg.MaxExecuteBodyKB) << 10; maxBody > 0 && int64(len(body)) > maxBody {
+		return http.StatusRequestEntityTooLarge, &limitErrResponse{Message: fmt.Sprintf("request body must not be larger than %dKB", cfg.MaxExecuteBodyKB), Limit: cfg.MaxExecuteBodyKB}
+	}
+	if cfg.MaxInputStringLength > 0 || cfg.MaxInputArrayLength > 0 {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err == nil {
+			if resp := checkLengths(v, "", cfg.MaxInputStringLength, cfg.MaxInputArrayLength); resp != nil {
+				return http.StatusUnprocessableEntity, resp
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Check strings, including object keys, and arrays of v at JSON pointer path against the limits, 0 disables a limit.
+// Returns the first value exceeding a limit, keys of objects are checked in order.
+func checkLengths(v interface{}, path string, maxString, maxArray int) *limitErrResponse {
+	switch vv := v.(type) {
+	case string:
+		if maxString > 0 && utf8.RuneCountInString(vv) > maxString {
+			return &limitErrResponse{Message: fmt.Sprintf("strings must not be longer than %d characters", maxString), Limit: maxString, Path: path}
+		}
+	case []interface{}:
+		if maxArray > 0 && len(vv) > maxArray {
+			return &limitErrResponse{Message: fmt.Sprintf("arrays must not have more than %d elements", maxArray), Limit: maxArray, Path: path}
+		}
+		for i, e := range vv {
+			if resp := checkLengths(e, path+"/"+strconv.Itoa(i), maxString, maxArray); resp != nil {
+				return resp
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			// the path of long keys is the object, it would repeat the key
+			if maxString > 0 && utf8.RuneCountInString(k) > maxString {
+				return &limitErrResponse{Message: fmt.Sprintf("object keys must not be longer than %d characters", maxString), Limit: maxString, Path: path}
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			if resp := checkLengths(vv[k], p, maxString, maxArray); resp != nil {
+				return resp
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"app/jobs"
+	pr "app/processes"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rerunRequestBody is the optional body of a re-run request.
+// Env overrides and notification settings of the original job are not stored, so they have to be provided again.
+// Resources and log level default to those of the original job.
+type rerunRequestBody struct {
+	Notify    *pr.Notify          `json:"notify,omitempty"`
+	Env       map[string]string   `json:"env,omitempty"`
+	Resources *pr.ResourceRequest `json:"resources,omitempty"`
+	LogLevel  string              `json:"logLevel,omitempty"`
+}
+
+// @Summary Re-run Job
+// @Description Creates a new job with the inputs, command and process version of a previous job.
+// @Description The new job records the original job as `rerunOf` in its metadata. It reserves the resources and uses the `logLevel` of the original job unless the body asks for others. Execution mode is determined from the `Prefer` header like for execute requests.
+// @Description Returns 409 if the process has been updated to another version since the original job was submitted.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 201 {object} jobResponse
+// @Router /jobs/{jobID}/rerun [post]
+func (rh *RESTHandler) JobRerunHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+
+	jr, ok, err := rh.DB.GetJobRequest(jobID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("execution parameters of job %s not found, jobs submitted before re-runs were supported can't be re-run", jobID)})
+	}
+
+	p, _, err := rh.ProcessList.Get(jr.ProcessID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("process %s of job %s no longer exists", jr.ProcessID, jobID)})
+	}
+	if p.Info.Version != jr.ProcessVersion {
+		return c.JSON(http.StatusConflict, errResponse{Message: fmt.Sprintf("process %s was updated from version %s to %s since job %s was submitted", jr.ProcessID, jr.ProcessVersion, p.Info.Version, jobID)})
+	}
+
+	if rh.Config.AuthLevel > 0 && !rh.processAllowed(c, p) {
+		return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+	}
+
+	var params rerunRequestBody
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(&params); err != nil {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+		}
+	}
+	if err := p.VerifyEnvOverrides(params.Env); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+	recipients, err := rh.notifyRecipients(c, p, params.Notify)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+	if params.Resources == nil {
+		params.Resources = &pr.ResourceRequest{CPUs: jr.CPUs, Memory: jr.Memory}
+	}
+	resources, err := p.JobResources(params.Resources)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: err.Error()})
+	}
+	if params.LogLevel == "" {
+		params.LogLevel = jr.LogLevel
+	} else if !jobs.ValidLogLevel(params.LogLevel) {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: "'logLevel' must be one of trace, debug, info, warn, error"})
+	}
+
+	return rh.submitJob(c, p, submission{Inputs: jr.Inputs, Outputs: jr.Outputs, Response: jr.Response, Cmd: jr.Command, Recipients: recipients, Env: params.Env, Resources: resources, RerunOf: jobID, FanOut: jr.FanOut, LogLevel: params.LogLevel})
+}
+
+// @Summary Job Definition
+// @Description The execute request a job was submitted with: inputs, outputs selection and response type,
+// @Description together with the resulting command, process version and execution mode. Env overrides and notification settings are not included.
+// @Tags jobs
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Success 200 {object} jobs.JobRequest
+// @Router /jobs/{jobID}/definition [get]
+func (rh *RESTHandler) JobDefinitionHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+
+	jr, ok, err := rh.DB.GetJobRequest(jobID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("definition of job %s not found", jobID)})
+	}
+	return c.JSON(http.StatusOK, jr)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"app/config"
+	"app/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	resultTokenParam        = "token"
+	defaultResultLinkTTL    = time.Hour
+	defaultResultLinkMaxTTL = 7 * 24 * time.Hour
+)
+
+// Scope of widget link tokens, they grant the job widget, job events and results, see tokenRoutes
+const tokenScopeWidget = "widget"
+
+// Routes that can be requested with a link token by scope, result links have no scope
+var tokenRoutes = map[string][]string{
+	"":               {"/jobs/:jobID/results"},
+	tokenScopeWidget: {"/jobs/:jobID/widget", "/jobs/:jobID/events", "/jobs/:jobID/results"},
+}
+
+// Claims of a result link token, OutputID empty means all outputs of the job
+type resultToken struct {
+	JobID    string `json:"job"`
+	OutputID string `json:"out,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	Expires  int64  `json:"exp"`
+}
+
+// Sign a result token, the token is base64url(JSON claims).base64url(HMAC-SHA256 of claims)
+func (rh *RESTHandler) signResultToken(rt resultToken) (string, error) {
+	payload, err := json.Marshal(rt)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, rh.Config.ResultLinkSecret)
+	mac.Write(payload)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Max lifetime of result links, RESULT_LINK_MAX_TTL is checked at startup and when it is changed at runtime
+func resultLinkMaxTTL() time.Duration {
+	ttl, err := parseStatsDuration(config.Get().ResultLinks.MaxTTL)
+	if err != nil || ttl <= 0 {
+		return defaultResultLinkMaxTTL
+	}
+	return ttl
+}
+
+// Verify signature and expiry of a result token issued for jobID
+func (rh *RESTHandler) verifyResultToken(token, jobID string) (resultToken, error) {
+	var rt resultToken
+	if len(rh.Config.ResultLinkSecret) == 0 {
+		return rt, errors.New("result links are not configured on this server")
+	}
+
+	enc := base64.RawURLEncoding
+	payloadStr, sigStr, ok := strings.Cut(token, ".")
+	if !ok {
+		return rt, errors.New("malformed token")
+	}
+	payload, err := enc.DecodeString(payloadStr)
+	if err != nil {
+		return rt, errors.New("malformed token")
+	}
+	sig, err := enc.DecodeString(sigStr)
+	if err != nil {
+		return rt, errors.New("malformed token")
+	}
+
+	mac := hmac.New(sha256.New, rh.Config.ResultLinkSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return rt, errors.New("invalid token signature")
+	}
+
+	if err := json.Unmarshal(payload, &rt); err != nil {
+		return rt, errors.New("malformed token")
+	}
+	if rt.JobID != jobID {
+		return rt, errors.New("token was not issued for this job")
+	}
+	if time.Now().Unix() > rt.Expires {
+		return rt, errors.New("token expired")
+	}
+	return rt, nil
+}
+
+// ResultLinkRequest reports whether the request fetches job results, or the job widget, with a valid link token of its scope.
+// Such requests skip authorization and ownership checks, it is used as skipper of the auth middleware.
+func (rh *RESTHandler) ResultLinkRequest(c echo.Context) bool {
+	token := c.QueryParam(resultTokenParam)
+	if token == "" || c.Request().Method != http.MethodGet {
+		return false
+	}
+	rt, err := rh.verifyResultToken(token, c.Param("jobID"))
+	return err == nil && utils.StringInSlice(c.Path(), tokenRoutes[rt.Scope])
+}
+
+type resultLinkResponse struct {
+	URL      string    `json:"url"`
+	Expires  time.Time `json:"expires"`
+	OutputID string    `json:"outputID,omitempty"`
+}
+
+// @Summary Share Job Results
+// @Description Creates a link to the results of a job that can be used without API credentials until it expires.
+// @Description The link can be limited to a single output with outputID. Links can not be revoked before they expire, except by rotating RESULT_LINK_SECRET.
+// @Tags jobs
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param outputID query string false "limit the link to one output"
+// @Param expiresIn query string false "e.g. 30m, 24h, 7d; default 1h"
+// @Success 201 {object} resultLinkResponse
+// @Router /jobs/{jobID}/results/share [post]
+func (rh *RESTHandler) ShareResultsHandler(c echo.Context) error {
+	if len(rh.Config.ResultLinkSecret) == 0 {
+		return c.JSON(http.StatusNotImplemented, errResponse{Message: "result links are not configured on this server"})
+	}
+
+	rt := resultToken{JobID: c.Param("jobID"), OutputID: c.QueryParam("outputID")}
+	link, expires, errResp := rh.shareLink(c, rt, "results")
+	if errResp != nil {
+		return c.JSON(errResp.HTTPStatus, *errResp)
+	}
+	return c.JSON(http.StatusCreated, resultLinkResponse{URL: link, Expires: expires, OutputID: rt.OutputID})
+}
+
+// Sign a link token for rt with the expiry of the expiresIn query parameter, and return the link to route of the job with it.
+// Returns an error response if the job does not exist or expiresIn is invalid.
+func (rh *RESTHandler) shareLink(c echo.Context, rt resultToken, route string) (string, time.Time, *errResponse) {
+	if _, ok, err := rh.jobSubmitter(rt.JobID); err != nil {
+		return "", time.Time{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+	} else if !ok {
+		return "", time.Time{}, &errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("%s job id not found", rt.JobID)}
+	}
+
+	ttl := defaultResultLinkTTL
+	if v := c.QueryParam("expiresIn"); v != "" {
+		var err error
+		ttl, err = parseStatsDuration(v)
+		maxTTL := resultLinkMaxTTL()
+		if err != nil || ttl <= 0 || ttl > maxTTL {
+			return "", time.Time{}, &errResponse{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("query parameter 'expiresIn' must be a positive duration up to %s, e.g. 30m, 24h, 7d", maxTTL)}
+		}
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	rt.Expires = expires.Unix()
+	token, err := rh.signResultToken(rt)
+	if err != nil {
+		return "", time.Time{}, &errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	link := fmt.Sprintf("%s/jobs/%s/%s?%s=%s", publicBaseURL(c), url.PathEscape(rt.JobID), route, resultTokenParam, url.QueryEscape(token))
+	return link, expires, nil
+}
+
+// Base URL of links in responses, API_URL_PUBLIC or the URL the request was sent to
+func publicBaseURL(c echo.Context) string {
+	base := strings.TrimSuffix(config.Get().API.PublicURL, "/")
+	if base == "" {
+		base = c.Scheme() + "://" + c.Request().Host
+	}
+	return base
+}
+
+// Limit results to the output of a result link token, false if the output does not exist
+func scopeResults(outputs interface{}, outputID string) (interface{}, bool) {
+	if outputID == "" {
+		return outputs, true
+	}
+	m, ok := outputs.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok := m[outputID]
+	if !ok {
+		return nil, false
+	}
+	return map[string]interface{}{outputID: v}, true
+}
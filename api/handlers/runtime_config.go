@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"app/config"
+	"app/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// Settings that can be changed at runtime with PATCH /admin/config, they are applied without restart.
+// Other settings need a restart because they are used to set up long lived components (database, storage, auth...).
+type runtimeConfig struct {
+	LogLevel string `json:"logLevel"`
+	// Resource limits for local jobs, as used by the resource pool
+	MaxLocalCPUs     float32 `json:"maxLocalCPUs"`
+	MaxLocalMemoryMB int     `json:"maxLocalMemoryMB"`
+	// Max number of jobs waiting for local resources, 0 does not limit the queue
+	MaxQueueLength int `json:"maxQueueLength"`
+	// Max number of active jobs, 0 does not limit them
+	MaxActiveJobs int `json:"maxActiveJobs"`
+	// Time local copies of job logs are kept after upload, e.g. 1h
+	LocalLogsTTL string `json:"localLogsTTL"`
+	// Max lifetime of result links, e.g. 24h, 7d
+	ResultLinkMaxTTL string `json:"resultLinkMaxTTL"`
+}
+
+// Body of PATCH /admin/config, settings that are not set are not changed
+type runtimeConfigPatch struct {
+	LogLevel *string `json:"logLevel"`
+	// 0 uses 80% of system CPUs
+	MaxLocalCPUs     *float64 `json:"maxLocalCPUs"`
+	MaxLocalMemoryMB *int     `json:"maxLocalMemoryMB"`
+	MaxQueueLength   *int     `json:"maxQueueLength"`
+	LocalLogsTTL     *string  `json:"localLogsTTL"`
+	ResultLinkMaxTTL *string  `json:"resultLinkMaxTTL"`
+	MaxActiveJobs    *int     `json:"maxActiveJobs"`
+}
+
+// Serializes runtime changes, each change copies the current configuration
+var runtimeConfigMu sync.Mutex
+
+func (rh *RESTHandler) runtimeConfig() runtimeConfig {
+	cfg := config.Get()
+	maxCPUs, maxMemory := rh.ResourcePool.Limits()
+	return runtimeConfig{
+		LogLevel:         log.GetLevel().String(),
+		MaxLocalCPUs:     maxCPUs,
+		MaxLocalMemoryMB: maxMemory,
+		MaxQueueLength:   cfg.Jobs.MaxQueueLength,
+		MaxActiveJobs:    cfg.Jobs.MaxActiveJobs,
+		LocalLogsTTL:     cfg.Logging.LocalLogsTTL.String(),
+		ResultLinkMaxTTL: cfg.ResultLinks.MaxTTL,
+	}
+}
+
+// @Summary Runtime Settings
+// @Description Returns settings that can be changed without restart with `PATCH /admin/config`. Requires admin role when auth is enabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} runtimeConfig
+// @Router /admin/config [get]
+func (rh *RESTHandler) RuntimeConfigHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, rh.runtimeConfig())
+}
+
+// @Summary Update Runtime Settings
+// @Description Changes log level, resource limits of local jobs, max queue length, max active jobs and retention TTLs without restart. Only settings in the body are changed.
+// @Description Changes are validated together and applied only if all of them are valid. They are not persisted, a restart restores the configured settings.
+// @Description Resource limits can be lowered below resources reserved by running jobs, these jobs keep running and queued jobs wait until enough resources are released.
+// @Description Limits lower than the requirements of a loaded process are rejected, since its jobs could never start. Requires admin role when auth is enabled.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param body body runtimeConfigPatch true "settings to change"
+// @Success 200 {object} runtimeConfig
+// @Router /admin/config [patch]
+func (rh *RESTHandler) RuntimeConfigUpdateHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	var patch runtimeConfigPatch
+	dec := json.NewDecoder(c.Request().Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: fmt.Sprintf("invalid request body: %s. Settings that can be changed are logLevel, maxLocalCPUs, maxLocalMemoryMB, maxQueueLength, maxActiveJobs, localLogsTTL and resultLinkMaxTTL", err.Error())})
+	}
+
+	runtimeConfigMu.Lock()
+	defer runtimeConfigMu.Unlock()
+
+	next := *config.Get()
+	var changed []string
+	var errs []string
+
+	var lvl log.Level
+	if patch.LogLevel != nil {
+		var err error
+		if lvl, err = log.ParseLevel(*patch.LogLevel); err != nil {
+			errs = append(errs, "logLevel: "+err.Error())
+		}
+		next.Logging.Level = *patch.LogLevel
+		changed = append(changed, "logLevel")
+	}
+	if patch.MaxLocalCPUs != nil {
+		if *patch.MaxLocalCPUs < 0 {
+			errs = append(errs, "maxLocalCPUs must not be negative")
+		}
+		next.Jobs.MaxLocalCPUs = *patch.MaxLocalCPUs
+		changed = append(changed, "maxLocalCPUs")
+	}
+	if patch.MaxLocalMemoryMB != nil {
+		if *patch.MaxLocalMemoryMB <= 0 {
+			errs = append(errs, "maxLocalMemoryMB must be positive")
+		}
+		next.Jobs.MaxLocalMemoryMB = *patch.MaxLocalMemoryMB
+		changed = append(changed, "maxLocalMemoryMB")
+	}
+	if patch.MaxQueueLength != nil {
+		if *patch.MaxQueueLength < 0 {
+			errs = append(errs, "maxQueueLength must not be negative")
+		}
+		next.Jobs.MaxQueueLength = *patch.MaxQueueLength
+		changed = append(changed, "maxQueueLength")
+	}
+	if patch.MaxActiveJobs != nil {
+		if *patch.MaxActiveJobs < 0 {
+			errs = append(errs, "maxActiveJobs must not be negative")
+		}
+		next.Jobs.MaxActiveJobs = *patch.MaxActiveJobs
+		changed = append(changed, "maxActiveJobs")
+	}
+	if patch.LocalLogsTTL != nil {
+		d, err := time.ParseDuration(*patch.LocalLogsTTL)
+		if err != nil || d < 0 {
+			errs = append(errs, "localLogsTTL must be a duration, e.g. 30m, 1h, 24h")
+		}
+		next.Logging.LocalLogsTTL = d
+		changed = append(changed, "localLogsTTL")
+	}
+	if patch.ResultLinkMaxTTL != nil {
+		if d, err := parseStatsDuration(*patch.ResultLinkMaxTTL); err != nil || d <= 0 {
+			errs = append(errs, "resultLinkMaxTTL must be a positive duration, e.g. 24h, 7d")
+		}
+		next.ResultLinks.MaxTTL = *patch.ResultLinkMaxTTL
+		changed = append(changed, "resultLinkMaxTTL")
+	}
+
+	if len(errs) > 0 {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: strings.Join(errs, "; ")})
+	}
+	if len(changed) == 0 {
+		return c.JSON(http.StatusOK, rh.runtimeConfig())
+	}
+
+	resize := patch.MaxLocalCPUs != nil || patch.MaxLocalMemoryMB != nil
+	limits := newResourceLimits(next.Jobs.MaxLocalCPUs, next.Jobs.MaxLocalMemoryMB)
+	if resize {
+		for i := range rh.ProcessList.List {
+			p := &rh.ProcessList.List[i]
+			if err := p.CheckResourceLimits(limits.MaxCPUs, limits.MaxMemory); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", p.Info.ID, strings.ReplaceAll(err.Error(), "\n", ", ")))
+			}
+		}
+		// Queued jobs of processes updated or deleted since they were submitted
+		for _, j := range rh.PendingJobs.List() {
+			if res := (*j).GetResources(); res.CPUs > limits.MaxCPUs || res.Memory > limits.MaxMemory {
+				errs = append(errs, fmt.Sprintf("queued job %s requires %.2f CPUs and %dMB memory", (*j).JobID(), res.CPUs, res.Memory))
+			}
+		}
+		if len(errs) > 0 {
+			return c.JSON(http.StatusConflict, errResponse{Message: "resource limits are lower than requirements of processes or queued jobs: " + strings.Join(errs, "; ")})
+		}
+	}
+
+	config.Set(&next)
+	if patch.LogLevel != nil {
+		log.SetLevel(lvl)
+	}
+	if resize {
+		rh.ResourcePool.Resize(limits.MaxCPUs, limits.MaxMemory)
+	}
+	requestLogger(c).Infof("Runtime settings changed by %s: %s", c.Request().Header.Get("X-SEPEX-User-Email"), strings.Join(changed, ", "))
+
+	return c.JSON(http.StatusOK, rh.runtimeConfig())
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"app/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary Process Self Test
+// @Description Runs the `healthCheck` command of the process with small resources and reports whether it passed, so that broken images or hosts are found before jobs fail.
+// @Description The request waits for the check to finish, at most its timeout. A failed check returns 200 with `passed` false; 400 if the process has no health check.
+// @Description Requires admin role when auth is enabled.
+// @Tags processes
+// @Accept */*
+// @Produce json
+// @Param processID path string true "example: pyecho"
+// @Success 200 {object} processes.SelfTestResult
+// @Router /processes/{processID}/selftest [post]
+func (rh *RESTHandler) ProcessSelfTestHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+
+		// non-admins are not allowed
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	processID := c.Param("processID")
+	p, _, err := rh.ProcessList.Get(processID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, errResponse{Message: fmt.Sprintf("process %s does not exist", processID)})
+	}
+	if p.HealthCheck == nil {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: fmt.Sprintf("process %s has no healthCheck", processID)})
+	}
+
+	res := p.SelfTest(c.Request().Context())
+	if res.Passed {
+		requestLogger(c).Infof("Health check of process %s passed in %.1fs", processID, res.Duration)
+	} else {
+		requestLogger(c).Warnf("Health check of process %s failed: %s", processID, res.Error)
+	}
+	return c.JSON(http.StatusOK, res)
+}
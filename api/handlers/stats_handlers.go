@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"app/jobs"
+	"app/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultStatsWindow = 24 * time.Hour
+	defaultUsageWindow = "24h" // defaultStatsWindow as written in the window query parameter
+	maxStatsWindow     = 90 * 24 * time.Hour
+	maxSeriesPoints    = 1000
+	defaultCostsWindow = 30 * 24 * time.Hour
+	maxCostsWindow     = 366 * 24 * time.Hour
+	defaultUsageTop    = 5
+	maxUsageTop        = 100
+)
+
+// Time ranges offered by the usage page
+var usageWindows = []string{"24h", "7d", "30d", "90d"}
+
+// Parse a duration, in addition to time.ParseDuration units `d` is accepted for days, e.g. 7d
+func parseStatsDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Parse window query parameter and fetch timings of jobs updated in the window, filtered by processID query parameter
+func (rh *RESTHandler) statsTimings(c echo.Context) (time.Duration, []jobs.JobTiming, error) {
+	window := defaultStatsWindow
+	if v := c.QueryParam("window"); v != "" {
+		var err error
+		window, err = parseStatsDuration(v)
+		if err != nil || window <= 0 || window > maxStatsWindow {
+			return 0, nil, echo.NewHTTPError(http.StatusBadRequest, "query parameter 'window' must be a positive duration up to 90d, e.g. 1h, 24h, 7d")
+		}
+	}
+
+	timings, err := rh.DB.GetJobTimings(time.Now().Add(-window))
+	if err != nil {
+		return 0, nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	processIDs := splitQueryParam(c, "processID")
+	if len(processIDs) > 0 {
+		filtered := make([]jobs.JobTiming, 0, len(timings))
+		for _, jt := range timings {
+			if utils.StringInSlice(jt.ProcessID, processIDs) {
+				filtered = append(filtered, jt)
+			}
+		}
+		timings = filtered
+	}
+	return window, timings, nil
+}
+
+// Convert error from statsTimings to response
+func statsError(c echo.Context, err error) error {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return c.JSON(he.Code, errResponse{Message: fmt.Sprint(he.Message)})
+	}
+	return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+}
+
+// @Summary Process Statistics
+// @Description Job counts, failure rate and p50/p95/average durations (seconds) per process for jobs updated within the window
+// @Tags stats
+// @Accept */*
+// @Produce json
+// @Param window query string false "e.g. 1h, 24h, 7d; default 24h, max 90d"
+// @Param processID query string false "comma separated list of process IDs"
+// @Success 200 {object} map[string]interface{}
+// @Router /stats/processes [get]
+func (rh *RESTHandler) ProcessStatsHandler(c echo.Context) error {
+	window, timings, err := rh.statsTimings(c)
+	if err != nil {
+		return statsError(c, err)
+	}
+
+	output := make(map[string]interface{})
+	output["window"] = window.String()
+	output["generatedAt"] = time.Now()
+	output["processes"] = jobs.AggregateByProcess(timings)
+	return c.JSON(http.StatusOK, output)
+}
+
+// @Summary Job Statistics Time Series
+// @Description Job counts, failure rate and p50/p95/average durations (seconds) bucketed by last update time.
+// @Description Every interval of the window is present, so series can be plotted directly, e.g. with Grafana JSON datasources.
+// @Tags stats
+// @Accept */*
+// @Produce json
+// @Param window query string false "e.g. 1h, 24h, 7d; default 24h, max 90d"
+// @Param interval query string false "bucket size, e.g. 5m, 1h; default 1h"
+// @Param processID query string false "comma separated list of process IDs"
+// @Success 200 {object} map[string]interface{}
+// @Router /stats/jobs [get]
+func (rh *RESTHandler) JobStatsHandler(c echo.Context) error {
+	window, timings, err := rh.statsTimings(c)
+	if err != nil {
+		return statsError(c, err)
+	}
+
+	interval := time.Hour
+	if v := c.QueryParam("interval"); v != "" {
+		interval, err = parseStatsDuration(v)
+		if err != nil || interval < time.Minute {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'interval' must be a duration of at least 1m, e.g. 5m, 1h"})
+		}
+	}
+	if window/interval > maxSeriesPoints {
+		return c.JSON(http.StatusBadRequest, errResponse{Message: fmt.Sprintf("window/interval must not exceed %d points, increase interval", maxSeriesPoints)})
+	}
+
+	now := time.Now()
+	output := make(map[string]interface{})
+	output["window"] = window.String()
+	output["interval"] = interval.String()
+	output["generatedAt"] = now
+	output["series"] = jobs.AggregateSeries(timings, now.Add(-window), now, interval)
+	return c.JSON(http.StatusOK, output)
+}
+
+// @Summary Job Cost Report
+// @Description Estimated costs of jobs updated within the window, summed per process, submitter and/or month.
+// @Description Costs are recorded when the metadata of a job is written, at the rates of the costs settings at that time.
+// @Description Requires the admin role when auth is enabled.
+// @Tags stats
+// @Accept */*
+// @Produce json
+// @Param window query string false "e.g. 24h, 30d; default 30d, max 366d"
+// @Param groupBy query string false "comma separated list of process, submitter and month; default process"
+// @Param processID query string false "comma separated list of process IDs"
+// @Param submitter query string false "comma separated list of submitters"
+// @Success 200 {object} map[string]interface{}
+// @Router /stats/costs [get]
+func (rh *RESTHandler) CostStatsHandler(c echo.Context) error {
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return c.JSON(http.StatusForbidden, errResponse{Message: "Forbidden"})
+		}
+	}
+
+	window := defaultCostsWindow
+	if v := c.QueryParam("window"); v != "" {
+		var err error
+		window, err = parseStatsDuration(v)
+		if err != nil || window <= 0 || window > maxCostsWindow {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'window' must be a positive duration up to 366d, e.g. 24h, 30d"})
+		}
+	}
+
+	groupBy := splitQueryParam(c, "groupBy")
+	if len(groupBy) == 0 {
+		groupBy = []string{jobs.CostGroupProcess}
+	}
+	for _, g := range groupBy {
+		if !utils.StringInSlice(g, []string{jobs.CostGroupProcess, jobs.CostGroupSubmitter, jobs.CostGroupMonth}) {
+			return c.JSON(http.StatusBadRequest, errResponse{Message: "query parameter 'groupBy' must be a comma separated list of process, submitter and month"})
+		}
+	}
+
+	records, err := rh.DB.GetJobCosts(time.Now().Add(-window))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, errResponse{Message: err.Error()})
+	}
+	processIDs, submitters := splitQueryParam(c, "processID"), splitQueryParam(c, "submitter")
+	if len(processIDs) > 0 || len(submitters) > 0 {
+		filtered := make([]jobs.JobCostRecord, 0, len(records))
+		for _, r := range records {
+			if (len(processIDs) == 0 || utils.StringInSlice(r.ProcessID, processIDs)) && (len(submitters) == 0 || utils.StringInSlice(r.Submitter, submitters)) {
+				filtered = append(filtered, r)
+			}
+		}
+		records = filtered
+	}
+
+	output := make(map[string]interface{})
+	output["window"] = window.String()
+	output["groupBy"] = groupBy
+	output["generatedAt"] = time.Now()
+	output["costs"] = jobs.AggregateCosts(records, groupBy)
+	return c.JSON(http.StatusOK, output)
+}
+
+// @Summary Process Usage
+// @Description Invocations, success rate, mean duration (seconds) and top submitters per process for jobs updated within the window,
+// @Description most invoked processes first, e.g. for capacity planning. Requires the admin role when auth is enabled.
+// @Tags stats
+// @Accept */*
+// @Produce json,html
+// @Param f query string false "format: json or html"
+// @Param window query string false "e.g. 24h, 7d, 30d; default 24h, max 90d"
+// @Param processID query string false "comma separated list of process IDs"
+// @Param top query int false "number of top submitters per process; default 5, max 100"
+// @Success 200 {object} map[string]interface{}
+// @Router /stats/usage [get]
+func (rh *RESTHandler) ProcessUsageHandler(c echo.Context) error {
+	err := validateFormat(c)
+	if err != nil {
+		return err
+	}
+
+	if rh.Config.AuthLevel > 0 {
+		roles := strings.Split(c.Request().Header.Get("X-SEPEX-User-Roles"), ",")
+		if !utils.StringInSlice(rh.Config.AdminRoleName, roles) {
+			return prepareResponse(c, http.StatusForbidden, "error", errResponse{HTTPStatus: http.StatusForbidden, Message: "Forbidden"})
+		}
+	}
+
+	top := defaultUsageTop
+	if v := c.QueryParam("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top < 1 || top > maxUsageTop {
+			return prepareResponse(c, http.StatusBadRequest, "error", errResponse{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("query parameter 'top' must be an integer from 1 to %d", maxUsageTop)})
+		}
+	}
+
+	_, timings, err := rh.statsTimings(c)
+	if err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			return prepareResponse(c, he.Code, "error", errResponse{HTTPStatus: he.Code, Message: fmt.Sprint(he.Message)})
+		}
+		return prepareResponse(c, http.StatusInternalServerError, "error", errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()})
+	}
+
+	// the window as requested, e.g. 7d rather than 168h0m0s, so that it matches the options of the form
+	window := c.QueryParam("window")
+	if window == "" {
+		window = defaultUsageWindow
+	}
+
+	output := make(map[string]interface{})
+	output["window"] = window
+	output["generatedAt"] = time.Now()
+	output["processes"] = jobs.AggregateUsage(timings, top)
+	if respondsHTML(c) {
+		output["windows"] = usageWindows
+	}
+	return prepareResponse(c, http.StatusOK, "usage", output)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"app/jobs"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type jobWatchdog struct {
+	rh *RESTHandler
+	// Last activity of the jobs a warning was logged for, a job is warned about once until it is active again
+	warned map[string]time.Time
+}
+
+// JobWatchdogRoutine checks running jobs every interval, 0 disables it. Jobs whose container or Batch job finished or
+// no longer exists are reconciled with it. Jobs without status updates, logs or progress for SilenceWarning are warned
+// about in their server logs and jobs without any for SilenceTimeout are failed, 0 disables either.
+func (rh *RESTHandler) JobWatchdogRoutine(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	w := &jobWatchdog{rh: rh, warned: make(map[string]time.Time)}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.check(time.Now())
+	}
+}
+
+func (w *jobWatchdog) check(now time.Time) {
+	running := make(map[string]bool)
+	for _, j := range w.rh.ActiveJobs.List() {
+		job := *j
+		watched, ok := job.(jobs.Watched)
+		if !ok || job.CurrentStatus() != jobs.RUNNING {
+			continue
+		}
+		jobID := job.JobID()
+		running[jobID] = true
+
+		status, err := watched.ReconcileStatus()
+		if err != nil {
+			log.Warnf("Job watchdog could not check the backend of job %s: %s", jobID, err.Error())
+		} else if status != "" {
+			log.Warnf("Job watchdog updated job %s to %s, its container or Batch job was no longer running", jobID, status)
+			continue
+		}
+
+		threshold := w.rh.Config.SilenceWarning
+		if threshold == 0 || (w.rh.Config.SilenceTimeout > 0 && w.rh.Config.SilenceTimeout < threshold) {
+			threshold = w.rh.Config.SilenceTimeout
+		}
+		if threshold == 0 || now.Sub(w.lastActivity(job, watched)) < threshold {
+			continue
+		}
+
+		// Logs of aws-batch jobs are only fetched from CloudWatch on request
+		if err := job.UpdateProcessLogs(); err != nil {
+			log.Warnf("Job watchdog could not update process logs of job %s: %s", jobID, err.Error())
+		}
+		last := w.lastActivity(job, watched)
+		silence := now.Sub(last).Round(time.Second)
+
+		switch {
+		case w.rh.Config.SilenceTimeout > 0 && silence >= w.rh.Config.SilenceTimeout:
+			reason := fmt.Sprintf("no status updates, logs or progress for %s", silence)
+			if err := watched.Fail(jobs.FailureStalled, reason); err != nil {
+				log.Errorf("Job watchdog could not fail job %s: %s", jobID, err.Error())
+				continue
+			}
+			log.Warnf("Job watchdog failed job %s: %s", jobID, reason)
+		case w.rh.Config.SilenceWarning > 0 && silence >= w.rh.Config.SilenceWarning && !w.warned[jobID].Equal(last):
+			w.warned[jobID] = last
+			msg := fmt.Sprintf("No status updates, logs or progress for %s.", silence)
+			if w.rh.Config.SilenceTimeout > 0 {
+				msg += fmt.Sprintf(" The job is failed after %s.", w.rh.Config.SilenceTimeout)
+			}
+			job.LogMessage(msg, log.WarnLevel)
+			log.Warnf("Job watchdog: job %s had no status updates, logs or progress for %s", jobID, silence)
+		}
+	}
+
+	for jobID := range w.warned {
+		if !running[jobID] {
+			delete(w.warned, jobID)
+		}
+	}
+}
+
+// Last status update, process log line or progress update of a job
+func (w *jobWatchdog) lastActivity(job jobs.Job, watched jobs.Watched) time.Time {
+	last := job.LastUpdate()
+	if t := watched.LastProcessLog(); t.After(last) {
+		last = t
+	}
+	if p, ok := w.rh.JobEvents.LastProgress(job.JobID()); ok && p.Time.After(last) {
+		last = p.Time
+	}
+	return last
+}
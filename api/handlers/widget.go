@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"app/config"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Job of the widget page, Token is the widget token of the request, used for the events and results requests of the page
+type widgetPage struct {
+	JobID      string
+	ProcessID  string
+	Status     string
+	LastUpdate time.Time
+	Token      string
+}
+
+type widgetLinkResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// @Summary Share Job Widget
+// @Description Creates a link to a minimal HTML page of the job that can be used without API credentials until it expires,
+// @Description e.g. to embed it in dashboards. The page shows live status, progress and result links. Links can not be revoked
+// @Description before they expire, except by rotating RESULT_LINK_SECRET.
+// @Tags jobs
+// @Accept */*
+// @Produce json
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param expiresIn query string false "e.g. 30m, 24h, 7d; default 1h"
+// @Success 201 {object} widgetLinkResponse
+// @Router /jobs/{jobID}/widget/share [post]
+// Does not produce HTML
+func (rh *RESTHandler) ShareWidgetHandler(c echo.Context) error {
+	if len(rh.Config.ResultLinkSecret) == 0 {
+		return c.JSON(http.StatusNotImplemented, errResponse{Message: "result links are not configured on this server"})
+	}
+
+	rt := resultToken{JobID: c.Param("jobID"), Scope: tokenScopeWidget}
+	link, expires, errResp := rh.shareLink(c, rt, "widget")
+	if errResp != nil {
+		return c.JSON(errResp.HTTPStatus, *errResp)
+	}
+	return c.JSON(http.StatusCreated, widgetLinkResponse{URL: link, Expires: expires})
+}
+
+// @Summary Job Widget
+// @Description Minimal HTML page of a job with live status, progress and result links, for links created with POST /jobs/{jobID}/widget/share.
+// @Description The page can be embedded by the origins of WIDGET_FRAME_ANCESTORS.
+// @Tags jobs
+// @Produce html
+// @Param jobID path string true "example: 44d9ca0e-2ca7-4013-907f-a8ccc60da3b4"
+// @Param token query string true "widget token of the link"
+// @Success 200 {string} string "HTML page"
+// @Router /jobs/{jobID}/widget [get]
+func (rh *RESTHandler) JobWidgetHandler(c echo.Context) error {
+	jobID := c.Param("jobID")
+	token := c.QueryParam(resultTokenParam)
+	// the token is required even for users who could see the job otherwise, the page only works with it
+	rt, err := rh.verifyResultToken(token, jobID)
+	if err != nil || rt.Scope != tokenScopeWidget {
+		if err == nil {
+			err = fmt.Errorf("token was not issued for the widget")
+		}
+		return c.Render(http.StatusForbidden, "error", errResponse{HTTPStatus: http.StatusForbidden, Message: err.Error()})
+	}
+
+	page := widgetPage{JobID: jobID, Token: token}
+	if job, ok := rh.ActiveJobs.Get(jobID); ok {
+		page.ProcessID, page.Status, page.LastUpdate = (*job).ProcessID(), (*job).CurrentStatus(), (*job).LastUpdate()
+	} else if jRcrd, ok, err := rh.DB.GetJob(jobID); err != nil {
+		return c.Render(http.StatusInternalServerError, "error", errResponse{HTTPStatus: http.StatusInternalServerError, Message: err.Error()})
+	} else if ok {
+		page.ProcessID, page.Status, page.LastUpdate = jRcrd.ProcessID, jRcrd.Status, jRcrd.LastUpdate
+	} else {
+		return c.Render(http.StatusNotFound, "error", errResponse{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("%s job id not found", jobID)})
+	}
+
+	allowFraming(c, config.Get().Headers.WidgetFrameAncestors)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Render(http.StatusOK, "jobWidget", page)
+}
+
+// Allow pages to be embedded by ancestors, space separated origins as in the frame-ancestors directive of CSP.
+// X-Frame-Options is removed as it can not list origins. Empty ancestors keep the security headers as they are.
+func allowFraming(c echo.Context, ancestors string) {
+	if ancestors == "" {
+		return
+	}
+	h := c.Response().Header()
+	h.Del(echo.HeaderXFrameOptions)
+	// keep other directives of the configured policy, browsers only use the first frame-ancestors directive
+	directives := []string{"frame-ancestors " + ancestors}
+	for _, d := range strings.Split(h.Get(echo.HeaderContentSecurityPolicy), ";") {
+		if d = strings.TrimSpace(d); d != "" && !strings.HasPrefix(d, "frame-ancestors") {
+			directives = append(directives, d)
+		}
+	}
+	h.Set(echo.HeaderContentSecurityPolicy, strings.Join(directives, "; "))
+}
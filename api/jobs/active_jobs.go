@@ -2,26 +2,108 @@ package jobs
 
 import (
 	"sync"
+	"time"
 )
 
 // It is the resoponsibility of originator to add and remove job from ActiveJobs
 type ActiveJobs struct {
 	Jobs map[string]*Job `json:"jobs"`
 	mu   sync.Mutex
+	// When jobs were added, for the age of the oldest job in Stats()
+	added map[string]time.Time
+}
+
+// QueueStats are the size of an internal job structure, the number of its jobs per process
+// and how long its oldest job has been in it. Limit is the configured cap, 0 if not limited.
+type QueueStats struct {
+	Count       int            `json:"count"`
+	Limit       int            `json:"limit"`
+	ByProcess   map[string]int `json:"byProcess"`
+	OldestJobID string         `json:"oldestJobID,omitempty"`
+	// Seconds since the oldest job was added
+	OldestAge float64 `json:"oldestAgeSeconds"`
 }
 
 func (ac *ActiveJobs) Add(j *Job) {
 	ac.mu.Lock()
 	defer ac.mu.Unlock()
 
+	if ac.added == nil {
+		ac.added = make(map[string]time.Time)
+	}
 	ac.Jobs[(*j).JobID()] = j
+	ac.added[(*j).JobID()] = time.Now()
 }
 
 func (ac *ActiveJobs) Remove(j *Job) {
+	ac.RemoveID((*j).JobID())
+}
+
+// RemoveID removes the job with ID jid. Jobs call it from Close() when they are done, it only holds the lock
+// for the delete so that closing jobs never wait on each other. Does nothing on a nil ActiveJobs.
+func (ac *ActiveJobs) RemoveID(jid string) {
+	if ac == nil {
+		return
+	}
 	ac.mu.Lock()
 	defer ac.mu.Unlock()
 
-	delete(ac.Jobs, (*j).JobID())
+	delete(ac.Jobs, jid)
+	delete(ac.added, jid)
+}
+
+// Has returns true if the job with ID jid is active
+func (ac *ActiveJobs) Has(jid string) bool {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	_, ok := ac.Jobs[jid]
+	return ok
+}
+
+// Get returns the active job with ID jid, jobs remove themselves concurrently so the map must not be read directly
+func (ac *ActiveJobs) Get(jid string) (*Job, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	j, ok := ac.Jobs[jid]
+	return j, ok
+}
+
+// Len returns the number of active jobs
+func (ac *ActiveJobs) Len() int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return len(ac.Jobs)
+}
+
+// Stats returns the number of active jobs, per process and the oldest of them
+func (ac *ActiveJobs) Stats() QueueStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	stats := QueueStats{Count: len(ac.Jobs), ByProcess: make(map[string]int)}
+	var oldest time.Time
+	for jid, j := range ac.Jobs {
+		stats.ByProcess[(*j).ProcessID()]++
+		if t, ok := ac.added[jid]; ok && (oldest.IsZero() || t.Before(oldest)) {
+			oldest, stats.OldestJobID = t, jid
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestAge = time.Since(oldest).Seconds()
+	}
+	return stats
+}
+
+// List returns a snapshot of all active jobs
+func (ac *ActiveJobs) List() []*Job {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	list := make([]*Job, 0, len(ac.Jobs))
+	for _, j := range ac.Jobs {
+		list = append(list, j)
+	}
+	return list
 }
 
 // Revised to kill only currently active jobs
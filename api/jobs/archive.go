@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Storage keys of the metadata, results and log files of a job
+func artifactKeys(jid, tenant string) []string {
+	return []string{
+		StorageKey(config.Get().Storage.MetadataPrefix, tenant, jid+".json"),
+		ResultsStorageKey(jid, tenant),
+		CustomMetadataStorageKey(jid, tenant),
+		StorageKey(config.Get().Storage.LogsPrefix, tenant, jid+".process.jsonl"),
+		StorageKey(config.Get().Storage.LogsPrefix, tenant, jid+".stderr.jsonl"),
+		StorageKey(config.Get().Storage.LogsPrefix, tenant, jid+".server.jsonl"),
+	}
+}
+
+// ArchiveKey is the key of an archived job file, the original key under the storage archive prefix.
+// Tenant prefixes are kept so that per tenant access policies can cover archived files.
+func ArchiveKey(key string) string {
+	prefix := strings.Trim(config.Get().Storage.ArchivePrefix, "/")
+	if prefix == "" {
+		prefix = "archive"
+	}
+	return prefix + "/" + key
+}
+
+// ArchiveArtifacts moves metadata and logs of a job under the archive prefix and removes local copies of its logs.
+// Files that do not exist, e.g. metadata of a failed job, are skipped.
+func ArchiveArtifacts(svc *s3.S3, jid, tenant string) error {
+	for _, key := range artifactKeys(jid, tenant) {
+		if err := moveIfExists(svc, key, ArchiveKey(key)); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range []string{"process", "stderr", "server"} {
+		localPath := LocalLogPath(jid, k)
+		if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreArtifacts moves archived metadata and logs of a job back to their original keys.
+func RestoreArtifacts(svc *s3.S3, jid, tenant string) error {
+	for _, key := range artifactKeys(jid, tenant) {
+		if err := moveIfExists(svc, ArchiveKey(key), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func moveIfExists(svc *s3.S3, srcKey, dstKey string) error {
+	exists, err := utils.KeyExists(srcKey, svc)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	if err := utils.MoveS3Object(svc, srcKey, dstKey); err != nil {
+		return fmt.Errorf("could not move %s to %s: %w", srcKey, dstKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import "time"
+
+// AuditRecord is a single security relevant action performed through the API.
+// Audit records are append-only, they are never updated or deleted by the server.
+type AuditRecord struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor"`
+	Roles      string    `json:"roles"`
+	SourceIP   string    `json:"sourceIP"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ResourceID string    `json:"resourceID"`
+	StatusCode int       `json:"statusCode"`
+	RequestID  string    `json:"requestID"`
+	BodySHA256 string    `json:"bodySHA256"` // hex encoded SHA-256 of request body, empty if no body
+}
+
+// AuditFilter restricts audit records returned by GetAuditRecords. Zero values are ignored.
+type AuditFilter struct {
+	Actors      []string
+	Actions     []string
+	ResourceIDs []string
+	Since       time.Time
+	Until       time.Time
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"app/config"
+	"app/controllers"
+	"time"
+)
+
+// Attempt is an attempt of a job's process. Batch retries failed attempts of aws-batch jobs according to the retry
+// strategy of their job definition, jobs that are resubmitted have the attempts of all their Batch jobs.
+type Attempt struct {
+	BatchID  string     `json:"batchID,omitempty"`
+	Started  *time.Time `json:"started,omitempty"`
+	Stopped  *time.Time `json:"stopped,omitempty"`
+	ExitCode *int       `json:"exitCode,omitempty"`
+	// Why the container stopped and why the attempt stopped
+	Reason       string `json:"reason,omitempty"`
+	StatusReason string `json:"statusReason,omitempty"`
+	// The attempt ended because its instance was terminated, e.g. a Spot instance that was reclaimed
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// AttemptReporter is implemented by jobs that record the attempts of their process.
+type AttemptReporter interface {
+	Attempts() []Attempt
+}
+
+// Resubmitter is implemented by jobs that can be submitted again instead of failing.
+// Resubmit is called before the status of the job is updated to failed and returns true if the job was submitted again,
+// its status is then accepted.
+type Resubmitter interface {
+	Resubmit() bool
+}
+
+func (j *AWSBatchJob) Attempts() []Attempt {
+	j.attemptsMu.Lock()
+	defer j.attemptsMu.Unlock()
+	return append([]Attempt(nil), j.attempts...)
+}
+
+// UpdateAttempts replaces the attempts of Batch job batchID and stores the attempts of the job,
+// attempts of Batch jobs the job was submitted as before are kept.
+func (j *AWSBatchJob) UpdateAttempts(batchID string, batchAttempts []controllers.BatchAttempt) {
+	j.attemptsMu.Lock()
+	attempts := make([]Attempt, 0, len(j.attempts)+len(batchAttempts))
+	var previous int
+	for _, a := range j.attempts {
+		if a.BatchID != batchID {
+			attempts = append(attempts, a)
+		} else {
+			previous++
+		}
+	}
+	for _, ba := range batchAttempts {
+		attempts = append(attempts, attemptOf(batchID, ba))
+	}
+	j.attempts = attempts
+	j.attemptsMu.Unlock()
+
+	if len(batchAttempts) > previous {
+		last := batchAttempts[len(batchAttempts)-1]
+		j.logger.Infof("Attempt %d of Batch job %s stopped: %s.", len(batchAttempts), batchID, last.StatusReason)
+	}
+	if err := j.DB.updateAttempts(j.UUID, attempts); err != nil {
+		j.logger.Errorf("Could not store attempts. Error: %s", err.Error())
+	}
+}
+
+func attemptOf(batchID string, ba controllers.BatchAttempt) Attempt {
+	a := Attempt{
+		BatchID:      batchID,
+		ExitCode:     ba.ExitCode,
+		Reason:       ba.Reason,
+		StatusReason: ba.StatusReason,
+		Interrupted:  ba.Interrupted(),
+	}
+	if !ba.Started.IsZero() {
+		a.Started = &ba.Started
+	}
+	if !ba.Stopped.IsZero() {
+		a.Stopped = &ba.Stopped
+	}
+	return a
+}
+
+// Describe the Batch job and update its attempts
+func (j *AWSBatchJob) refreshAttempts(c *controllers.AWSBatchController) error {
+	batchID := j.ProviderID()
+	batchAttempts, err := c.JobAttempts(batchID)
+	if err != nil {
+		return err
+	}
+	j.UpdateAttempts(batchID, batchAttempts)
+	return nil
+}
+
+// Resubmit submits the job again if the last attempt of its Batch job was interrupted and it was resubmitted
+// less than MaxResubmits times. Jobs whose interrupted Batch job is not resubmitted fail with failure class interrupted.
+func (j *AWSBatchJob) Resubmit() bool {
+	if j.failing {
+		return false
+	}
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
+	if err != nil {
+		j.logger.Errorf("Could not get attempts of Batch job %s. Error: %s", j.ProviderID(), err.Error())
+		return false
+	}
+	if err := j.refreshAttempts(c); err != nil {
+		j.logger.Errorf("Could not get attempts of Batch job %s. Error: %s", j.ProviderID(), err.Error())
+		return false
+	}
+	attempts := j.Attempts()
+	if len(attempts) == 0 || !attempts[len(attempts)-1].Interrupted {
+		return false
+	}
+	last := attempts[len(attempts)-1]
+
+	if j.Resubmits >= j.MaxResubmits {
+		j.logger.Errorf("Batch job %s was interrupted: %s.", j.ProviderID(), last.StatusReason)
+		j.failInterrupted()
+		return false
+	}
+
+	// Process logs are fetched when requested, those of the interrupted Batch job are fetched before its stream is replaced
+	if err := j.UpdateProcessLogs(); err != nil {
+		j.logger.Warnf("Logs of Batch job %s may be incomplete.", j.ProviderID())
+	}
+	batchID, err := j.submit(c)
+	if err != nil {
+		j.logger.Errorf("Could not resubmit interrupted Batch job %s. Error: %s", j.ProviderID(), err.Error())
+		j.failInterrupted()
+		return false
+	}
+
+	j.logsMu.Lock()
+	j.logStreamName = ""
+	j.cloudWatchForwardToken = ""
+	j.logsMu.Unlock()
+
+	j.Resubmits++
+	j.logger.Warnf("Batch job %s was interrupted: %s. Resubmitted as Batch job %s (%d of %d).", j.ProviderID(), last.StatusReason, batchID, j.Resubmits, j.MaxResubmits)
+	j.setBatchID(batchID)
+	j.NewStatusUpdate(ACCEPTED, time.Time{})
+	return true
+}
+
+func (j *AWSBatchJob) setBatchID(batchID string) {
+	j.attemptsMu.Lock()
+	j.AWSBatchID = batchID
+	j.attemptsMu.Unlock()
+}
+
+func (j *AWSBatchJob) failInterrupted() {
+	if err := j.DB.updateFailureClass(j.UUID, FailureInterrupted); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+}
@@ -1,11 +1,11 @@
 package jobs
 
 import (
+	"app/config"
 	"app/controllers"
-	"app/utils"
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -19,6 +19,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// Interval at which CloudWatch is polled while logs are streamed
+	cloudWatchPollInterval = 5 * time.Second
+	// Maximum number of GetLogEvents pages (up to 10,000 events or 1MB each) read in one update
+	cloudWatchMaxPages = 100
+)
+
 // Fields are exported so that gob can access it
 type AWSBatchJob struct {
 	ctx       context.Context
@@ -34,6 +41,11 @@ type AWSBatchJob struct {
 	ProcessName    string `json:"processID"`
 	ProcessVersion string
 	Submitter      string
+	Tenant         string
+	RequestID      string   // ID of the API request that created the job
+	RerunOf        string   // ID of the job this job re-runs
+	LogLevel       string   // level of server logs and LOG_LEVEL of the container, LOG_LEVEL of the server if empty
+	ParentID       string   // ID of the pipeline or fan-out job this job is a step of
 	Cmd            []string `json:"commandOverride"`
 	UpdateTime     time.Time
 	Status         string `json:"status"`
@@ -47,16 +59,76 @@ type AWSBatchJob struct {
 
 	// Job Name in Batch for this job
 	JobName                string `json:"jobName"`
-	EnvVars                []string
+	EnvVars                []EnvVar
+	EnvOverrides           map[string]string // set by the execute request
+	OutputTypes            map[string]string // media types declared by outputs of the process, set on objects referenced by results
+	OutputSchemas          ResultsValidator  // validates results before the job succeeds, nil if no output declares a schema
+	StdoutResults          *StdoutResults    // captures results from stdout when the job succeeds, nil reads them from the process logs
+	Callback               *Callback         // results callback of the job, its env variables are set if not nil
 	batchContext           *controllers.AWSBatchController
 	logStreamName          string
 	cloudWatchForwardToken string
+	cloudWatchClient       *cloudwatchlogs.CloudWatchLogs
+	// Serializes CloudWatch fetches of log routes and log stream poller
+	logsMu         sync.Mutex
+	logBroadcaster *LogBroadcaster
+	pollOnce       sync.Once
 	// MetaData
 
 	DB         Database
+	Events     *EventBus
 	StorageSvc *s3.S3
-	DoneChan   chan Job
-	Resources  // AWS Batch manages its own resources, but field needed for interface
+	ActiveJobs *ActiveJobs
+	Resources  // Overrides resources of the job definition, 0 values keep the job definition's
+
+	// Times the job is submitted again when its Batch job failed because its instance was interrupted, and times it was
+	MaxResubmits int
+	Resubmits    int
+	attempts     []Attempt
+	// Guards attempts and AWSBatchID, which is replaced by Resubmit while handlers read it
+	attemptsMu sync.Mutex
+	// Set by Fail, jobs failed by the server are not resubmitted
+	failing bool
+}
+
+func init() {
+	RegisterBackend("aws-batch", awsBatchBackend{})
+}
+
+// Submits jobs to AWS Batch in Create, they are not queued locally
+type awsBatchBackend struct{}
+
+func (awsBatchBackend) Queued() bool { return false }
+
+func (awsBatchBackend) NewJob(spec JobSpec, svc JobServices) (Job, error) {
+	return &AWSBatchJob{
+		UUID:           spec.JobID,
+		ProcessName:    spec.ProcessID,
+		Image:          spec.Image,
+		Submitter:      spec.Submitter,
+		Tenant:         spec.Tenant,
+		RequestID:      spec.RequestID,
+		RerunOf:        spec.RerunOf,
+		ParentID:       spec.ParentID,
+		LogLevel:       spec.LogLevel,
+		EnvVars:        spec.EnvVars,
+		EnvOverrides:   spec.EnvOverrides,
+		OutputTypes:    spec.OutputTypes,
+		OutputSchemas:  spec.OutputSchemas,
+		StdoutResults:  spec.StdoutResults,
+		Callback:       spec.Callback,
+		Cmd:            spec.Cmd,
+		JobDef:         spec.JobDefinition,
+		JobQueue:       spec.JobQueue,
+		JobName:        fmt.Sprintf("%s_%s", svc.APIName, spec.JobID),
+		ProcessVersion: spec.ProcessVersion,
+		Resources:      spec.Resources,
+		MaxResubmits:   spec.MaxResubmits,
+		StorageSvc:     svc.StorageSvc,
+		DB:             svc.DB,
+		Events:         svc.Events,
+		ActiveJobs:     svc.ActiveJobs,
+	}, nil
 }
 
 func (j *AWSBatchJob) WaitForRunCompletion() {
@@ -75,6 +147,14 @@ func (j *AWSBatchJob) SUBMITTER() string {
 	return j.Submitter
 }
 
+func (j *AWSBatchJob) TENANT() string {
+	return j.Tenant
+}
+
+func (j *AWSBatchJob) PARENT() string {
+	return j.ParentID
+}
+
 func (j *AWSBatchJob) ProcessVersionID() string {
 	return j.ProcessVersion
 }
@@ -107,36 +187,43 @@ func (j *AWSBatchJob) IsSyncJob() bool {
 // Update container logs
 // Fetches Container logs from CloudWatch.
 func (j *AWSBatchJob) UpdateProcessLogs() (err error) {
+	j.logsMu.Lock()
+	defer j.logsMu.Unlock()
 
 	j.logger.Debug("Updating container logs by fetching cloud watch logs.")
 	// we are fetching logs here and not in run function because we only want to fetch logs when needed
-	containerLogs, err := j.fetchCloudWatchLogs()
+	err = j.fetchCloudWatchLogs()
 	if err != nil {
 		j.logger.Errorf("Error fetching cloud watch logs: %s", err.Error())
-		return
 	}
+	return
+}
 
-	if len(containerLogs) == 0 {
-		return
-	}
-
-	file, err := os.OpenFile(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID), os.O_APPEND|os.O_WRONLY, 0666)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+// SubscribeProcessLogs returns container log lines as they are delivered to CloudWatch.
+// CloudWatch is polled only once the first subscriber arrives.
+func (j *AWSBatchJob) SubscribeProcessLogs() (<-chan string, func()) {
+	j.pollOnce.Do(func() {
+		go j.pollCloudWatchLogs()
+	})
+	return j.logBroadcaster.Subscribe()
+}
 
-	for _, line := range containerLogs {
+// Poll CloudWatch for new container logs until the job is closed
+func (j *AWSBatchJob) pollCloudWatchLogs() {
+	ticker := time.NewTicker(cloudWatchPollInterval)
+	defer ticker.Stop()
 
-		_, err = writer.WriteString(line + "\n")
-		if err != nil {
-			j.logger.Errorf("Error writing log: %s", err.Error())
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if j.CurrentStatus() == ACCEPTED { // log stream does not exist before job starts
+				continue
+			}
+			_ = j.UpdateProcessLogs()
 		}
 	}
-	return nil
 }
 
 func (j *AWSBatchJob) ClearOutputs() {
@@ -184,13 +271,17 @@ func (j *AWSBatchJob) NewStatusUpdate(status string, updateTime time.Time) {
 	}
 	j.DB.updateJobRecord(j.UUID, status, j.UpdateTime)
 	j.logger.Infof("Status changed to %s.", status)
+	publishStatusEvent(j.Events, j, status, j.UpdateTime)
 }
 
 func (j *AWSBatchJob) CurrentStatus() string {
 	return j.Status
 }
 
+// ProviderID is the ID of the current Batch job, it changes when the job is resubmitted
 func (j *AWSBatchJob) ProviderID() string {
+	j.attemptsMu.Lock()
+	defer j.attemptsMu.Unlock()
 	return j.AWSBatchID
 }
 
@@ -205,7 +296,7 @@ func (j *AWSBatchJob) Equals(job Job) bool {
 
 func (j *AWSBatchJob) initLogger() error {
 	// Create a place holder file for container logs
-	file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err := os.Create(LocalLogPath(j.UUID, "process"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
@@ -214,20 +305,23 @@ func (j *AWSBatchJob) initLogger() error {
 	// Create logger for server logs
 	j.logger = log.New()
 
-	file, err = os.Create(fmt.Sprintf("%s/%s.server.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err = os.Create(LocalLogPath(j.UUID, "server"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
 
-	j.logger.SetOutput(file)
+	j.logger.SetOutput(ServerLogWriter(file))
 	j.logger.SetFormatter(&log.JSONFormatter{})
+	j.logger.AddHook(newJobFieldsHook(j.UUID, j.RequestID))
 
-	lvl, err := log.ParseLevel(os.Getenv("LOG_LEVEL"))
+	lvl, err := log.ParseLevel(jobLogLevel(j.LogLevel))
 	if err != nil {
-		j.logger.Warnf("Invalid LOG_LEVEL set: %s, defaulting to INFO", os.Getenv("LOG_LEVEL"))
+		j.logger.Warnf("Invalid LOG_LEVEL set: %s, defaulting to INFO", jobLogLevel(j.LogLevel))
 		lvl = log.InfoLevel
 	}
 	j.logger.SetLevel(lvl)
+
+	j.logBroadcaster = NewLogBroadcaster()
 	return nil
 }
 
@@ -243,21 +337,13 @@ func (j *AWSBatchJob) Create() error {
 	j.ctx = ctx
 	j.ctxCancel = cancelFunc
 
-	batchContext, err := controllers.NewAWSBatchController(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_REGION"))
+	batchContext, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
 	if err != nil {
 		j.ctxCancel()
 		return err
 	}
 
-	// get environment variables
-	envs := make(map[string]string, len(j.EnvVars))
-	for _, k := range j.EnvVars {
-		name := strings.TrimPrefix(k, strings.ToUpper(j.ProcessName)+"_")
-		envs[name] = os.Getenv(k)
-	}
-	j.logger.Debugf("Registered %v env vars", len(envs))
-
-	aWSBatchID, err := batchContext.JobCreate(j.ctx, j.JobDef, j.JobName, j.JobQueue, j.Cmd, envs)
+	aWSBatchID, err := j.submit(batchContext)
 	if err != nil {
 		j.ctxCancel()
 		return err
@@ -265,11 +351,11 @@ func (j *AWSBatchJob) Create() error {
 
 	j.wgRun.Add(1) // When status is one of the final status this should be decremented, this is the responsibility of who ever is updating status
 
-	j.AWSBatchID = aWSBatchID
+	j.setBatchID(aWSBatchID)
 	j.batchContext = batchContext
 
 	// At this point job is ready to be added to database
-	err = j.DB.addJob(j.UUID, "accepted", "", "aws-batch", j.ProcessName, j.Submitter, time.Now())
+	err = j.DB.addJob(j.UUID, "accepted", "", "aws-batch", j.ProcessName, j.Submitter, j.Tenant, j.RequestID, j.ParentID, time.Now())
 	if err != nil {
 		j.ctxCancel()
 		return err
@@ -282,6 +368,28 @@ func (j *AWSBatchJob) Create() error {
 	return nil
 }
 
+// Submit a Batch job of the job, returns its ID
+func (j *AWSBatchJob) submit(c *controllers.AWSBatchController) (string, error) {
+	// get environment variables
+	envs := make(map[string]string, len(j.EnvVars))
+	for _, ev := range j.EnvVars {
+		envs[ev.Name] = ev.resolve()
+	}
+	if j.LogLevel != "" {
+		envs[LogLevelEnvVar] = j.LogLevel
+	}
+	for k, v := range j.EnvOverrides {
+		envs[k] = v
+	}
+	for _, kv := range j.Callback.envVars() {
+		k, v, _ := strings.Cut(kv, "=")
+		envs[k] = v
+	}
+	j.logger.Debugf("Registered %v env vars", len(envs))
+
+	return c.JobCreate(j.ctx, j.JobDef, j.JobName, j.JobQueue, j.Cmd, envs, j.Resources.CPUs, j.Resources.Memory)
+}
+
 func (j *AWSBatchJob) Kill() error {
 	j.logger.Info("Received dismiss signal.")
 
@@ -291,13 +399,13 @@ func (j *AWSBatchJob) Kill() error {
 		return fmt.Errorf("can't call delete on an already completed, failed, or dismissed job")
 	}
 
-	c, err := controllers.NewAWSBatchController(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_REGION"))
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
 	if err != nil {
 		j.logger.Errorf("Could not send kill signal to AWS Batch API. Error: %s", err.Error())
 		return err
 	}
 
-	_, err = c.JobKill(j.AWSBatchID)
+	_, err = c.JobKill(j.ProviderID())
 	if err != nil {
 		j.logger.Errorf("Could not send kill signal to AWS Batch API. Error: %s", err.Error())
 		return err
@@ -313,14 +421,102 @@ func (j *AWSBatchJob) Kill() error {
 	return nil
 }
 
+// BatchJobStatus returns the job status of a Batch job status formatted by the Batch controller, empty if it is unknown
+func BatchJobStatus(batchStatus string) string {
+	switch batchStatus {
+	case "ACCCEPTED":
+		return ACCEPTED
+	case "RUNNING":
+		return RUNNING
+	case "SUCCEEDED":
+		return SUCCESSFUL
+	case "FAILED":
+		return FAILED
+	case "DISMISSED":
+		return DISMISSED
+	}
+	return ""
+}
+
+// LastProcessLog returns when the container last wrote a log line that was fetched from CloudWatch
+func (j *AWSBatchJob) LastProcessLog() time.Time {
+	return j.logBroadcaster.LastPublished()
+}
+
+// ReconcileStatus updates the status of a running job whose Batch job finished or no longer exists,
+// e.g. when its status update was not delivered. Jobs are not described when their statuses come from Batch job events,
+// SQS keeps events until they were received and deleted.
+func (j *AWSBatchJob) ReconcileStatus() (string, error) {
+	if j.CurrentStatus() != RUNNING || config.Get().AWS.BatchEventsQueueURL != "" {
+		return "", nil
+	}
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
+	if err != nil {
+		return "", err
+	}
+
+	var status string
+	batchStatus, _, err := c.JobMonitor(j.ProviderID())
+	switch {
+	case errors.Is(err, controllers.ErrBatchJobNotFound):
+		j.logger.Errorf("Batch job %s no longer exists.", j.ProviderID())
+		if err := j.DB.updateFailureClass(j.UUID, FailureLost); err != nil {
+			j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+		}
+		status = FAILED
+	case err != nil:
+		return "", err
+	default:
+		switch status = BatchJobStatus(batchStatus); status {
+		case SUCCESSFUL, FAILED, DISMISSED:
+		default:
+			return "", nil
+		}
+	}
+	if batchStatus != "" {
+		j.logger.Warnf("Batch job %s is %s while the job was running, its status update was missed.", j.ProviderID(), batchStatus)
+	}
+
+	var job Job = j
+	ProcessStatusMessageUpdate(StatusMessage{Job: &job, Status: status, LastUpdate: time.Now()})
+	return status, nil
+}
+
+// Fail terminates the Batch job of a stuck job and updates the status of the job to failed
+func (j *AWSBatchJob) Fail(class, reason string) error {
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		return fmt.Errorf("can't fail an already completed, failed, or dismissed job")
+	}
+
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
+	if err != nil {
+		j.logger.Errorf("Could not send terminate signal to AWS Batch API. Error: %s", err.Error())
+		return err
+	}
+	if _, err := c.JobTerminate(j.ProviderID(), reason); err != nil {
+		j.logger.Errorf("Could not send terminate signal to AWS Batch API. Error: %s", err.Error())
+		return err
+	}
+
+	j.failing = true
+	j.logger.Errorf("Failing job: %s.", reason)
+	if err := j.DB.updateFailureClass(j.UUID, class); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+	var job Job = j
+	ProcessStatusMessageUpdate(StatusMessage{Job: &job, Status: FAILED, LastUpdate: time.Now()})
+	return nil
+}
+
 // Get log stream name for this job
 func (j *AWSBatchJob) getLogStreamName() (err error) {
-	c, err := controllers.NewAWSBatchController(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_DEFAULT_REGION"))
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
 	if err != nil {
 		return
 	}
 
-	_, logStreamName, err := c.JobMonitor(j.AWSBatchID)
+	_, logStreamName, err := c.JobMonitor(j.ProviderID())
 	if err != nil {
 		return
 	}
@@ -328,35 +524,38 @@ func (j *AWSBatchJob) getLogStreamName() (err error) {
 	return
 }
 
-// Fetches logs from CloudWatch using the AWS Go SDK
-func (j *AWSBatchJob) fetchCloudWatchLogs() ([]string, error) {
+// Fetches new logs from CloudWatch using the AWS Go SDK, appends them to the process log file and publishes them to subscribers.
+// Long streams are read page by page starting from the last forward token, so that only new events are fetched
+// and a page is written before the next one is requested.
+func (j *AWSBatchJob) fetchCloudWatchLogs() error {
 	if j.logStreamName == "" {
 		err := j.getLogStreamName()
 		if err != nil {
-			return nil, fmt.Errorf("could not get aws log stream name: %s", err.Error())
+			return fmt.Errorf("could not get aws log stream name: %s", err.Error())
 		}
 
 		if j.logStreamName == "" {
-			return nil, fmt.Errorf("aws log stream name is empty")
+			return fmt.Errorf("aws log stream name is empty")
 		} else {
 			j.logger.Info("AWS Log Stream Name: ", j.logStreamName)
 		}
 	}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("Error creating session: %w", err)
+	if j.cloudWatchClient == nil {
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(config.Get().AWS.Region),
+		})
+		if err != nil {
+			return fmt.Errorf("Error creating session: %w", err)
+		}
+		j.cloudWatchClient = cloudwatchlogs.New(sess)
 	}
 
-	svc := cloudwatchlogs.New(sess)
-
-	logs := make([]string, 0)
-	for {
+	logPath := LocalLogPath(j.UUID, "process")
+	for page := 0; page < cloudWatchMaxPages; page++ {
 		// Define the parameters for the log stream
 		params := &cloudwatchlogs.GetLogEventsInput{
-			LogGroupName:  aws.String(os.Getenv("BATCH_LOG_STREAM_GROUP")),
+			LogGroupName:  aws.String(config.Get().AWS.BatchLogStreamGroup),
 			LogStreamName: aws.String(j.logStreamName),
 			StartFromHead: aws.Bool(true),
 		}
@@ -366,51 +565,82 @@ func (j *AWSBatchJob) fetchCloudWatchLogs() ([]string, error) {
 		}
 
 		// Call the GetLogEvents API to read the log events
-		resp, err := svc.GetLogEvents(params)
+		resp, err := j.cloudWatchClient.GetLogEvents(params)
 		if err != nil {
 			// If token error, reset the token and start from the beginning
 			if strings.Contains(err.Error(), "InvalidParameterException") {
 				j.logger.Error(err)
 				// reset everything
 				j.cloudWatchForwardToken = ""
-				logs = make([]string, 0)
 				// overwrite file
-				file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+				file, err := os.Create(logPath)
 				if err != nil {
-					return nil, fmt.Errorf("failed to open log file: %s", err.Error())
+					return fmt.Errorf("failed to open log file: %s", err.Error())
 				}
 				file.Close()
 				continue
-			} else if err.Error() == "ResourceNotFoundException: The specified log stream does not exist." {
-				return []string{}, nil
+			} else if strings.HasPrefix(err.Error(), "ResourceNotFoundException") {
+				// stream is created when the container writes its first line
+				return nil
 			} else {
 				j.logger.Error(err)
-				return nil, err
+				return err
 			}
 		}
 
-		// Get the log events
-		for _, event := range resp.Events {
-			logs = append(logs, *event.Message)
+		if len(resp.Events) > 0 {
+			if err := j.appendProcessLogs(logPath, resp.Events); err != nil {
+				return err
+			}
 		}
 
-		if len(resp.Events) > 0 {
-			j.cloudWatchForwardToken = *resp.NextForwardToken
-		} else {
-			break
+		// End of stream is reached when the same token is returned
+		nextToken := aws.StringValue(resp.NextForwardToken)
+		if len(resp.Events) == 0 || nextToken == j.cloudWatchForwardToken {
+			j.cloudWatchForwardToken = nextToken
+			return nil
 		}
+		j.cloudWatchForwardToken = nextToken
 	}
-	return logs, nil
+
+	j.logger.Warnf("Stopped reading CloudWatch logs after %d pages, remaining logs will be fetched on next update", cloudWatchMaxPages)
+	return nil
 }
 
-// Write metadata at the job's metadata location
+// Append log events to process log file and publish them to subscribers
+func (j *AWSBatchJob) appendProcessLogs(logPath string, events []*cloudwatchlogs.OutputLogEvent) error {
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, event := range events {
+		line := aws.StringValue(event.Message)
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("error writing log: %s", err.Error())
+		}
+		j.logBroadcaster.Publish(line)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	// the file is closed after every append, syncing it with close and always policies
+	if config.Get().Logging.JobLogsFsync != FsyncNever {
+		return file.Sync()
+	}
+	return nil
+}
+
+// Write metadata at the job's metadata location and set the content types of objects referenced by its results
 func (j *AWSBatchJob) WriteMetaData() {
 	j.logger.Info("Starting metadata writing routine.")
 	j.wg.Add(1)
 	defer j.wg.Done()
 	defer j.logger.Info("Finished metadata writing routine.")
 
-	c, err := controllers.NewAWSBatchController(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_REGION"))
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
 	if err != nil {
 		j.logger.Errorf("Error writing metadata: %s", err.Error())
 		return
@@ -446,38 +676,48 @@ func (j *AWSBatchJob) WriteMetaData() {
 		}
 	}
 
-	p := process{j.ProcessID(), j.ProcessVersion}
-	i := image{imgURI, imgDgst}
-
-	g, s, e, err := c.GetJobTimes(j.AWSBatchID)
+	// Times are those recorded by Batch, job creation in Batch is not recorded since the job was created earlier by the API
+	_, s, e, err := c.GetJobTimes(j.ProviderID())
 	if err != nil {
 		j.logger.Errorf("Error writing metadata: %s", err.Error())
 		return
 	}
 
-	repoURL := os.Getenv("REPO_URL")
-
-	md := metaData{
-		Context:         fmt.Sprintf("%s/blob/main/context.jsonld", repoURL),
-		JobID:           j.UUID,
-		Process:         p,
-		Image:           i,
-		Commands:        j.Cmd,
-		GeneratedAtTime: g,
-		StartedAtTime:   s,
-		EndedAtTime:     e,
+	// vCPUs of the job definition unless the job overrides them, attempts that were retried or interrupted are billed too
+	cpus := j.Resources.CPUs
+	if cpus == 0 {
+		if info, err := c.GetJobDefInfo(j.JobDef); err == nil {
+			cpus = info.VCPUs
+		}
+	}
+	var billed time.Duration
+	for _, a := range j.Attempts() {
+		if a.Started != nil && a.Stopped != nil {
+			billed += a.Stopped.Sub(*a.Started)
+		}
 	}
 
-	jsonBytes, err := json.Marshal(md)
+	// TODO: Determine if batch metadata should be put on aws...currently this is the case
+	err = writeProvDocument(j.StorageSvc, j.DB, provRecord{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersion,
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+		RerunOf:        j.RerunOf,
+		Commands:       j.Cmd,
+		ImageURI:       imgURI,
+		ImageDigest:    imgDgst,
+		Started:        s,
+		Ended:          e,
+		Host:           "aws-batch",
+		CPUs:           cpus,
+		Billed:         billed,
+	})
 	if err != nil {
 		j.logger.Errorf("Error writing metadata: %s", err.Error())
-		return
 	}
-
-	metadataDir := os.Getenv("STORAGE_METADATA_PREFIX")
-	mdLocation := fmt.Sprintf("%s/%s.json", metadataDir, j.UUID)
-	// TODO: Determine if batch metadata should be put on aws...currently this is the case
-	utils.WriteToS3(j.StorageSvc, jsonBytes, mdLocation, "application/json", 0)
+	setOutputContentTypes(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.OutputTypes)
 }
 
 // func (j *AWSBatchJob) WriteResults(data []byte) (err error) {
@@ -517,16 +757,24 @@ func (j *AWSBatchJob) Close() {
 		}
 	}
 
-	j.DoneChan <- j // At this point job can be safely removed from active jobs
+	// Attempts are stored when Batch job events are received, the last ones may not have been received yet
+	if c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region); err == nil {
+		if err := j.refreshAttempts(c); err != nil {
+			j.logger.Warnf("Could not get attempts of Batch job %s. Error: %s", j.ProviderID(), err.Error())
+		}
+	}
+
+	j.logBroadcaster.Close()
+	j.ActiveJobs.RemoveID(j.UUID) // At this point job can be safely removed from active jobs
 
 	go func() {
 		j.wg.Wait() // wait if other routines like metadata are running because they can send logs
 		j.logFile.Close()
-		UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName)
+		UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName, j.Tenant)
 		// It is expected that logs will be requested multiple times for a recently finished job
-		// so we are waiting for one hour to before deleting the local copy
+		// so we are waiting for the local logs TTL (default one hour) before deleting the local copy
 		// so that we can avoid repetitive request to storage service
-		time.Sleep(time.Hour)
+		time.Sleep(config.Get().Logging.LocalLogsTTL)
 		DeleteLocalLogs(j.StorageSvc, j.UUID, j.ProcessName)
 	}()
 }
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"app/controllers"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Backend creates the jobs of processes of a host type. The job it returns implements how its jobs are run:
+// Create, Run, Kill, UpdateProcessLogs (logs) and WriteMetaData (metadata), see Job.
+type Backend interface {
+	// NewJob returns a job of spec that is not created yet, the server calls Create and then runs or queues it
+	NewJob(spec JobSpec, svc JobServices) (Job, error)
+	// Queued returns true if async jobs wait in PendingJobs until resources of the ResourcePool are available
+	// and are run by the QueueWorker, false if they start in Create
+	Queued() bool
+}
+
+// JobSpec is what backends get to know about a job, from its process and execute request
+type JobSpec struct {
+	JobID          string
+	ProcessID      string
+	ProcessVersion string
+	Image          string
+	// Platform of the image of docker jobs, os/arch[/variant], the platform of the docker daemon if empty
+	Platform string
+	// Credentials to pull Image with if it is missing, nil pulls anonymously
+	PullAuth      controllers.RegistryAuthFunc
+	JobDefinition string
+	JobQueue      string
+	Submitter     string
+	Tenant        string
+	RequestID     string
+	RerunOf       string
+	ParentID      string
+	LogLevel      string
+	Cmd           []string
+	EnvVars       []EnvVar
+	EnvVarsFrom   []EnvVarFrom
+	EnvOverrides  map[string]string
+	Volumes       []string
+	InputsFile    json.RawMessage // inputs of processes with inputDelivery file
+	Resources     Resources
+	ErrorPatterns []ErrorPattern
+	OutputTypes   map[string]string
+	OutputSchemas ResultsValidator
+	StdoutResults *StdoutResults
+	Callback      *Callback
+	StorageAccess *StorageAccess
+	Security      *controllers.DockerSecurity
+	IsSync        bool
+	// Time between SIGTERM and SIGKILL when the job is dismissed, 0 kills immediately
+	StopGracePeriod time.Duration
+	// Times the job is submitted again when it failed because its instance was interrupted, e.g. a reclaimed Spot instance
+	MaxResubmits int
+	// Entrypoint and working directory of docker jobs, nil keeps those of the image
+	ImageOverrides *controllers.DockerImageOverrides
+}
+
+// JobServices are the services of the server jobs use
+type JobServices struct {
+	APIName      string
+	DB           Database
+	Events       *EventBus
+	StorageSvc   *s3.S3
+	ActiveJobs   *ActiveJobs
+	ResourcePool *ResourcePool
+	Docker       *controllers.SharedDockerController
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// RegisterBackend makes b run the jobs of processes of host type hostType. Backends register in init functions,
+// it panics if a host type is registered twice.
+func RegisterBackend(hostType string, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[hostType]; ok {
+		panic(fmt.Sprintf("backend of host type %s registered twice", hostType))
+	}
+	backends[hostType] = b
+}
+
+// GetBackend returns the backend of host type hostType, false if none is registered
+func GetBackend(hostType string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	b, ok := backends[hostType]
+	return b, ok
+}
+
+// BackendHostTypes returns the host types of registered backends in alphabetical order
+func BackendHostTypes() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	types := make([]string, 0, len(backends))
+	for t := range backends {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
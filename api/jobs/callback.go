@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Env variables with the URL a running process pushes results, progress and metadata to, and the token it authenticates with
+const (
+	CallbackURLEnvVar   = "SEPEX_CALLBACK_URL"
+	CallbackTokenEnvVar = "SEPEX_CALLBACK_TOKEN"
+)
+
+// Callback is the results callback of a job, PUT /internal/jobs/{jobID}/results with the token as bearer token.
+// Jobs have no callback if the server has no JOB_CALLBACK_SECRET.
+type Callback struct {
+	URL   string
+	Token string
+}
+
+// Env variables of the callback as NAME=value, none if c is nil
+func (c *Callback) envVars() []string {
+	if c == nil {
+		return nil
+	}
+	return []string{CallbackURLEnvVar + "=" + c.URL, CallbackTokenEnvVar + "=" + c.Token}
+}
+
+// CustomMetadataStorageKey returns the storage key of the metadata a job pushed with its callback,
+// it is added to the metadata of the job under custom when the metadata is written
+func CustomMetadataStorageKey(jid, tenant string) string {
+	return StorageKey(config.Get().Storage.MetadataPrefix, tenant, jid+".custom.json")
+}
+
+// StoreCallbackResults stores results pushed by a running job, they replace results pushed before and are returned by
+// FetchResults. Jobs of processes with resultsFrom stdout that pushed results do not capture them from stdout.
+func StoreCallbackResults(svc *s3.S3, jid, tenant string, results interface{}) error {
+	b, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return utils.WriteToS3(svc, b, ResultsStorageKey(jid, tenant), "application/json", 0)
+}
+
+// StoreCustomMetadata merges metadata pushed by a running job into the metadata it pushed before,
+// keys replace keys of earlier pushes and null values remove them
+func StoreCustomMetadata(svc *s3.S3, jid, tenant string, md map[string]interface{}) error {
+	key := CustomMetadataStorageKey(jid, tenant)
+	merged := map[string]interface{}{}
+	if stored, err := fetchCustomMetadata(svc, key); err != nil {
+		return err
+	} else if stored != nil {
+		merged = stored
+	}
+	for k, v := range md {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	return utils.WriteToS3(svc, b, key, "application/json", 0)
+}
+
+// Metadata pushed by a job, nil if it pushed none
+func fetchCustomMetadata(svc *s3.S3, key string) (map[string]interface{}, error) {
+	exists, err := utils.KeyExists(key, svc)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := utils.GetS3JsonData(key, svc)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("custom metadata at %s is not an object", key)
+	}
+	return md, nil
+}
+
+// Whether results were pushed with the callback of the job, or captured from stdout already
+func resultsStored(svc *s3.S3, jid, tenant string) bool {
+	exists, err := utils.KeyExists(ResultsStorageKey(jid, tenant), svc)
+	return err == nil && exists
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+var errChecksumTooLarge = errors.New("too large to compute checksum")
+
+// Max bytes downloaded to compute a checksum, 0 if checksums of references are not computed
+func checksumMaxSize() int64 {
+	return int64(config.Get().Storage.ChecksumMaxSizeMB) * 1024 * 1024
+}
+
+// ReferenceChecksum returns the SHA-256 of an input or output referenced by `s3://` or `http(s)://` URL as `sha256:<hex>`.
+// S3 objects uploaded with a SHA-256 checksum are not downloaded. Other references are streamed through the hash,
+// references larger than METADATA_CHECKSUM_MAX_SIZE_MB fall back to their S3 ETag as `etag:<value>`.
+// Only objects of the storage bucket and URLs of METADATA_CHECKSUM_HOSTS are read, since references are fetched with
+// the credentials and network access of the server. Other references are recorded without checksum.
+// Returns an empty string for references that can not be read, failures are logged since metadata is written regardless.
+func ReferenceChecksum(svc *s3.S3, href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	var sum string
+	switch u.Scheme {
+	case "s3":
+		if svc == nil || u.Host != config.Get().Storage.Bucket {
+			return ""
+		}
+		sum, err = s3Checksum(svc, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "http", "https":
+		if !utils.StringInSlice(u.Hostname(), config.Get().Storage.ChecksumHosts) {
+			return ""
+		}
+		sum, err = httpChecksum(href)
+	default:
+		return ""
+	}
+	if err != nil {
+		log.Warnf("Could not compute checksum of %s: %s", href, err.Error())
+	}
+	return sum
+}
+
+func s3Checksum(svc *s3.S3, bucket, key string) (string, error) {
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Full object checksums are stored base64 encoded, multipart checksums (checksum of part checksums) end with -<parts>
+	if stored := aws.StringValue(head.ChecksumSHA256); stored != "" && !strings.Contains(stored, "-") {
+		if b, err := base64.StdEncoding.DecodeString(stored); err == nil {
+			return "sha256:" + hex.EncodeToString(b), nil
+		}
+	}
+
+	etag := "etag:" + strings.Trim(aws.StringValue(head.ETag), `"`)
+	maxSize := checksumMaxSize()
+	if maxSize == 0 || aws.Int64Value(head.ContentLength) > maxSize {
+		return etag, nil
+	}
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return etag, err
+	}
+	defer obj.Body.Close()
+
+	sum, err := hashReader(obj.Body, maxSize)
+	if err != nil {
+		return etag, err
+	}
+	return sum, nil
+}
+
+func httpChecksum(href string) (string, error) {
+	maxSize := checksumMaxSize()
+	if maxSize == 0 {
+		return "", nil
+	}
+
+	// Redirects must stay on allowed hosts too
+	client := http.Client{Timeout: 10 * time.Minute, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if !utils.StringInSlice(req.URL.Hostname(), config.Get().Storage.ChecksumHosts) {
+			return fmt.Errorf("redirect to %s, host is not in METADATA_CHECKSUM_HOSTS", req.URL.Hostname())
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}}
+	resp, err := client.Get(href)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return "", nil
+	}
+	sum, err := hashReader(resp.Body, maxSize)
+	if err == errChecksumTooLarge {
+		return "", nil
+	}
+	return sum, err
+}
+
+// SHA-256 of at most maxSize bytes of r, errChecksumTooLarge if r is larger
+func hashReader(r io.Reader, maxSize int64) (string, error) {
+	if maxSize == 0 {
+		return "", errChecksumTooLarge
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if n > maxSize {
+		return "", errChecksumTooLarge
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
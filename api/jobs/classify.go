@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+)
+
+// Failure classes assigned to failed jobs
+const (
+	FailureOOM             = "oom"
+	FailureBadInput        = "bad_input"
+	FailureUpstreamTimeout = "upstream_timeout"
+	FailureUnknown         = "unknown"
+	// Instance of the job was terminated, e.g. a reclaimed Spot instance
+	FailureInterrupted = "interrupted"
+	// Results of the process do not match the schemas of its outputs
+	FailureInvalidResults = "invalid_results"
+)
+
+// Number of process log lines from the end inspected for error patterns
+const classifyLogTail = 50
+
+// ErrorPattern assigns Class to a failed job if Pattern (regular expression) matches any of the last process log lines
+type ErrorPattern struct {
+	Class   string
+	Pattern string
+}
+
+// FailureClassifier is implemented by jobs that classify their failures.
+type FailureClassifier interface {
+	FailureClassification() string
+}
+
+// ExitReporter is implemented by jobs that record how their process exited.
+// Exit code is nil until the process exited.
+type ExitReporter interface {
+	ExitDetail() (exitCode *int, oomKilled bool)
+}
+
+// Checked after process specific patterns
+var defaultErrorPatterns = []ErrorPattern{
+	{FailureOOM, `(?i)out of memory|MemoryError|std::bad_alloc|OOMKilled|Cannot allocate memory`},
+	{FailureUpstreamTimeout, `(?i)timed? ?out|deadline exceeded|ETIMEDOUT|504 Gateway`},
+	{FailureBadInput, `(?i)invalid (input|argument|parameter)|ValueError|json\.decoder\.JSONDecodeError|no such file or directory`},
+}
+
+// ClassifyFailure determines why a job failed from its exit code, OOM kill flag and the tail of its process logs.
+// Process specific patterns take precedence over default patterns. Invalid patterns are skipped.
+func ClassifyFailure(exitCode int, oomKilled bool, logTail []string, patterns []ErrorPattern) string {
+	if oomKilled {
+		return FailureOOM
+	}
+
+	for _, ep := range append(append([]ErrorPattern{}, patterns...), defaultErrorPatterns...) {
+		re, err := regexp.Compile(ep.Pattern)
+		if err != nil {
+			continue
+		}
+		// most recent lines are most likely to contain the cause
+		for i := len(logTail) - 1; i >= 0; i-- {
+			if re.MatchString(logTail[i]) {
+				return ep.Class
+			}
+		}
+	}
+
+	// conventional exit code of timeout(1)
+	if exitCode == 124 {
+		return FailureUpstreamTimeout
+	}
+	return FailureUnknown
+}
+
+// Last n lines of a slice
+func lastLines(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Last n lines of a file, nil if file can not be read
+func tailFile(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > 2*n {
+			lines = lastLines(lines, n)
+		}
+	}
+	return lastLines(lines, n)
+}
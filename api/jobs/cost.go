@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"app/config"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bytes of a GB as priced by storage rates
+const bytesPerGB = 1 << 30
+
+// JobCost is the estimated cost of a job at the rates of the costs settings when its metadata was written
+type JobCost struct {
+	VCPUSeconds  float64 `json:"vcpuSeconds,omitempty"` // aws-batch jobs
+	CPUHours     float64 `json:"cpuHours,omitempty"`    // docker and subprocess jobs
+	StorageBytes int64   `json:"storageBytes"`          // size of outputs referenced by the results in the storage bucket
+	Total        float64 `json:"total"`
+	Currency     string  `json:"currency"`
+}
+
+// JobCostRecord is the cost of a job with what cost reports are grouped by
+type JobCostRecord struct {
+	ProcessID string
+	Submitter string
+	Updated   time.Time
+	JobCost
+}
+
+// Estimate the cost of a job from its CPUs and run time and the size of its outputs. Compute time is Billed if set,
+// otherwise the time from Started to Ended. Returns nil for pipeline jobs, their steps have their own costs, and if
+// all rates are 0, such jobs have no cost and are left out of cost reports.
+func estimateCost(svc *s3.S3, r provRecord) *JobCost {
+	rates := config.Get().Costs
+	if r.Host == "pipeline" || (rates.BatchVCPUSecond == 0 && rates.LocalCPUHour == 0 && rates.StorageGB == 0) {
+		return nil
+	}
+	c := JobCost{Currency: rates.Currency}
+
+	billed := r.Billed
+	if billed == 0 && !r.Started.IsZero() && r.Ended.After(r.Started) {
+		billed = r.Ended.Sub(r.Started)
+	}
+	switch r.Host {
+	case "aws-batch":
+		c.VCPUSeconds = float64(r.CPUs) * billed.Seconds()
+	case "docker", "subprocess":
+		c.CPUHours = float64(r.CPUs) * billed.Hours()
+	}
+	c.StorageBytes = outputsSize(svc, r.JobID, r.Tenant)
+
+	c.Total = c.VCPUSeconds*rates.BatchVCPUSecond + c.CPUHours*rates.LocalCPUHour + float64(c.StorageBytes)/bytesPerGB*rates.StorageGB
+	return &c
+}
+
+// Total size of objects in the storage bucket referenced by the outputs of a job, outputs that are prefixes
+// (e.g. zarr stores) count their objects. Objects of other buckets are not the server's to measure.
+func outputsSize(svc *s3.S3, jid, tenant string) int64 {
+	if svc == nil {
+		return 0
+	}
+	results, err := FetchResults(svc, jid, tenant)
+	if err != nil {
+		return 0
+	}
+	outputs, ok := results.(map[string]interface{})
+	if !ok {
+		outputs = map[string]interface{}{"results": results}
+	}
+
+	var size int64
+	for _, v := range outputs {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, v := range values {
+			u, err := url.Parse(referenceHref(v))
+			if err != nil || u.Scheme != "s3" || u.Host != config.Get().Storage.Bucket {
+				continue
+			}
+			n, err := s3Size(svc, u.Host, strings.TrimPrefix(u.Path, "/"))
+			if err != nil {
+				log.Warnf("Could not get size of output %s of job %s: %s", u.String(), jid, err.Error())
+				continue
+			}
+			size += n
+		}
+	}
+	return size
+}
+
+// Size of the object at key, or of the objects under key/ if there is none
+func s3Size(svc *s3.S3, bucket, key string) (int64, error) {
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err == nil {
+		return aws.Int64Value(head.ContentLength), nil
+	}
+
+	var size int64
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(strings.TrimSuffix(key, "/") + "/"),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			size += aws.Int64Value(o.Size)
+		}
+		return true
+	})
+	return size, err
+}
+
+// CostReport is the cost of a group of jobs, fields jobs are not grouped by are empty
+type CostReport struct {
+	ProcessID    string  `json:"processID,omitempty"`
+	Submitter    string  `json:"submitter,omitempty"`
+	Month        string  `json:"month,omitempty"` // UTC month of the last update of the jobs, e.g. 2024-05
+	Jobs         int     `json:"jobs"`
+	VCPUSeconds  float64 `json:"vcpuSeconds"`
+	CPUHours     float64 `json:"cpuHours"`
+	StorageBytes int64   `json:"storageBytes"`
+	Total        float64 `json:"total"`
+	Currency     string  `json:"currency"`
+}
+
+// Fields cost reports can be grouped by
+const (
+	CostGroupProcess   = "process"
+	CostGroupSubmitter = "submitter"
+	CostGroupMonth     = "month"
+)
+
+// AggregateCosts sums costs of jobs grouped by the fields of groupBy, sorted by month, process, submitter and currency.
+// Costs recorded in different currencies are not added up.
+func AggregateCosts(records []JobCostRecord, groupBy []string) []CostReport {
+	byGroup := make(map[CostReport]*CostReport)
+	for _, r := range records {
+		var key CostReport
+		for _, g := range groupBy {
+			switch g {
+			case CostGroupProcess:
+				key.ProcessID = r.ProcessID
+			case CostGroupSubmitter:
+				key.Submitter = r.Submitter
+			case CostGroupMonth:
+				key.Month = r.Updated.UTC().Format("2006-01")
+			}
+		}
+		key.Currency = r.Currency
+
+		cr, ok := byGroup[key]
+		if !ok {
+			cr = &CostReport{ProcessID: key.ProcessID, Submitter: key.Submitter, Month: key.Month, Currency: key.Currency}
+			byGroup[key] = cr
+		}
+		cr.Jobs++
+		cr.VCPUSeconds += r.VCPUSeconds
+		cr.CPUHours += r.CPUHours
+		cr.StorageBytes += r.StorageBytes
+		cr.Total += r.Total
+	}
+
+	res := make([]CostReport, 0, len(byGroup))
+	for _, cr := range byGroup {
+		res = append(res, *cr)
+	}
+	sort.Slice(res, func(i, k int) bool {
+		a, b := res[i], res[k]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.ProcessID != b.ProcessID {
+			return a.ProcessID < b.ProcessID
+		}
+		if a.Submitter != b.Submitter {
+			return a.Submitter < b.Submitter
+		}
+		return a.Currency < b.Currency
+	})
+	return res
+}
@@ -1,43 +1,70 @@
 package jobs
 
 import (
+	"app/config"
 	"fmt"
-	"os"
 	"time"
 )
 
 // Database interface abstracts database operations
 type Database interface {
-	addJob(jid, status, mode, host, processID, submitter string, updated time.Time) error
+	addJob(jid, status, mode, host, processID, submitter, tenant, requestID, parentID string, updated time.Time) error
 	updateJobRecord(jid, status string, now time.Time) error
+	updateJobRecords(updates []statusWrite) error
+	updateFailureClass(jid, class string) error
+	updateExitDetail(jid string, exitCode int, oomKilled bool) error
+	updateAttempts(jid string, attempts []Attempt) error
 	GetJob(jid string) (JobRecord, bool, error)
 	CheckJobExist(jid string) (bool, error)
-	GetJobs(limit, offset int, processIDs, statuses, submitters []string) ([]JobRecord, error)
+	GetJobs(limit, offset int, processIDs, statuses, submitters, tenants []string, includeChildren bool) ([]JobRecord, error)
+	GetJobChildren(parentID string) ([]JobRecord, error)
+	GetJobsUpdatedBefore(before time.Time, limit int, processIDs, statuses []string) ([]JobRecord, error)
+	DeleteJobs(jids []string) (int64, error)
+	SetJobArchived(jid string, archived bool) error
+	GetProcessStatusCounts(since time.Time) ([]StatusCount, error)
+	GetJobTimings(since time.Time) ([]JobTiming, error)
+	updateJobCost(jid string, c JobCost) error
+	GetJobCosts(since time.Time) ([]JobCostRecord, error)
+	AddJobRequest(jr JobRequest) error
+	GetJobRequest(jid string) (JobRequest, bool, error)
+	FindSuccessfulJob(inputHash, tenant, submitter string, since time.Time) (string, bool, error)
+	AddJobNote(n JobNote) (int64, error)
+	GetJobNotes(jid string) ([]JobNote, error)
+	AddAuditRecord(ar AuditRecord) error
+	GetAuditRecords(limit, offset int, filter AuditFilter) ([]AuditRecord, error)
 	Close() error
 }
 
-func NewDatabase(dbType string) (db Database, err error) {
+// StatusCount is the number of jobs of a process in a status
+type StatusCount struct {
+	ProcessID string `json:"processID"`
+	Status    string `json:"status"`
+	Count     int    `json:"count"`
+}
+
+func NewDatabase(c config.DB) (db Database, err error) {
 
-	switch dbType {
+	switch c.Service {
 	case "sqlite":
-		dbPath, exist := os.LookupEnv("SQLITE_DB_PATH")
-		if !exist {
+		if c.SQLitePath == "" {
 			return nil, fmt.Errorf("env variable SQLITE_DB_PATH not set")
 		}
-		db, err = NewSQLiteDB(dbPath)
+		db, err = NewSQLiteDB(c.SQLitePath)
 	case "postgres":
-		connString, exist := os.LookupEnv("POSTGRES_CONN_STRING")
-		if !exist {
+		if c.PostgresConnString == "" {
 			return nil, fmt.Errorf("env variable POSTGRES_CONN_STRING not set")
 		}
-		db, err = NewPostgresDB(connString)
+		db, err = NewPostgresDB(c.PostgresConnString)
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+		return nil, fmt.Errorf("unsupported database type: %s", c.Service)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	if c.StatusWriteInterval > 0 {
+		db = newWriteBehindDB(db, c.StatusWriteInterval)
+	}
 	return db, nil
 }
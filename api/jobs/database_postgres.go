@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -56,13 +57,117 @@ func (postgresDB *PostgresDB) createTables() error {
 	if err != nil {
 		return fmt.Errorf("error creating tables: %s", err)
 	}
+
+	// audit_log is append-only, a trigger rejects updates and deletes
+	queryAudit := `
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id BIGSERIAL PRIMARY KEY,
+        created TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+        action TEXT NOT NULL,
+        actor TEXT NOT NULL DEFAULT '',
+        roles TEXT NOT NULL DEFAULT '',
+        source_ip TEXT NOT NULL DEFAULT '',
+        method TEXT NOT NULL,
+        path TEXT NOT NULL,
+        resource_id TEXT NOT NULL DEFAULT '',
+        status_code INTEGER NOT NULL,
+        request_id TEXT NOT NULL DEFAULT '',
+        body_sha256 TEXT NOT NULL DEFAULT ''
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created);
+    CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+
+    CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END;
+    $$ LANGUAGE plpgsql;
+
+    DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+    CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+        FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+    `
+
+	_, err = postgresDB.Handle.Exec(queryAudit)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Execution parameters of jobs, command is a JSON array
+	queryRequests := `
+    CREATE TABLE IF NOT EXISTS job_requests (
+        job_id TEXT PRIMARY KEY,
+        process_id TEXT NOT NULL,
+        process_version TEXT NOT NULL DEFAULT '',
+        inputs TEXT NOT NULL,
+        command TEXT NOT NULL,
+        rerun_of TEXT NOT NULL DEFAULT ''
+    );
+    `
+
+	_, err = postgresDB.Handle.Exec(queryRequests)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Notes of jobs, deleted with their job
+	queryNotes := `
+    CREATE TABLE IF NOT EXISTS job_notes (
+        id BIGSERIAL PRIMARY KEY,
+        job_id TEXT NOT NULL,
+        author TEXT NOT NULL DEFAULT '',
+        text TEXT NOT NULL,
+        created TIMESTAMP WITHOUT TIME ZONE NOT NULL
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_job_notes_job_id ON job_notes(job_id);
+    `
+
+	_, err = postgresDB.Handle.Exec(queryNotes)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Columns added after the initial schema, existing databases are migrated in place
+	queryMigrations := `
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS created TIMESTAMP WITHOUT TIME ZONE;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS failure_class TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS archived TIMESTAMP WITHOUT TIME ZONE;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS exit_code INTEGER;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS oom_killed BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS parent_id TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost REAL;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost_currency TEXT NOT NULL DEFAULT '';
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost_vcpu_seconds REAL NOT NULL DEFAULT 0;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost_cpu_hours REAL NOT NULL DEFAULT 0;
+    ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost_storage_bytes BIGINT NOT NULL DEFAULT 0;
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS outputs TEXT NOT NULL DEFAULT '';
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS response TEXT NOT NULL DEFAULT '';
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT '';
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS input_hash TEXT NOT NULL DEFAULT '';
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS cpus REAL NOT NULL DEFAULT 0;
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS memory INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS fan_out TEXT NOT NULL DEFAULT '';
+    ALTER TABLE job_requests ADD COLUMN IF NOT EXISTS log_level TEXT NOT NULL DEFAULT '';
+    CREATE INDEX IF NOT EXISTS idx_job_requests_input_hash ON job_requests(input_hash);
+    CREATE INDEX IF NOT EXISTS idx_jobs_parent_id ON jobs(parent_id);
+    `
+
+	_, err = postgresDB.Handle.Exec(queryMigrations)
+	if err != nil {
+		return fmt.Errorf("error migrating tables: %s", err)
+	}
 	return nil
 }
 
 // AddJob adds a new job to the database
-func (db *PostgresDB) addJob(jid, status, mode, host, processID, submitter string, updated time.Time) error {
-	query := `INSERT INTO jobs (id, status, updated, mode, host, process_id, submitter) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := db.Handle.Exec(query, jid, status, updated, mode, host, processID, submitter)
+func (db *PostgresDB) addJob(jid, status, mode, host, processID, submitter, tenant, requestID, parentID string, updated time.Time) error {
+	query := `INSERT INTO jobs (id, status, updated, mode, host, process_id, submitter, tenant, request_id, parent_id, created) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $3)`
+	_, err := db.Handle.Exec(query, jid, status, updated, mode, host, processID, submitter, tenant, requestID, parentID)
 	return err
 }
 
@@ -73,20 +178,227 @@ func (db *PostgresDB) updateJobRecord(jid, status string, now time.Time) error {
 	return err
 }
 
+// updateJobRecords updates status and time of several jobs in one transaction
+func (db *PostgresDB) updateJobRecords(updates []statusWrite) error {
+	tx, err := db.Handle.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE jobs SET status = $2, updated = $3 WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, u := range updates {
+		if _, err := stmt.Exec(u.JobID, u.Status, u.Updated); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateFailureClass updates failure class of a job
+func (db *PostgresDB) updateFailureClass(jid, class string) error {
+	query := `UPDATE jobs SET failure_class = $2 WHERE id = $1`
+	_, err := db.Handle.Exec(query, jid, class)
+	return err
+}
+
+// updateExitDetail updates exit code and OOM killed flag of a job
+func (db *PostgresDB) updateExitDetail(jid string, exitCode int, oomKilled bool) error {
+	query := `UPDATE jobs SET exit_code = $2, oom_killed = $3 WHERE id = $1`
+	_, err := db.Handle.Exec(query, jid, exitCode, oomKilled)
+	return err
+}
+
+// updateAttempts updates attempts of a job
+func (db *PostgresDB) updateAttempts(jid string, attempts []Attempt) error {
+	b, err := json.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+	_, err = db.Handle.Exec(`UPDATE jobs SET attempts = $2 WHERE id = $1`, jid, string(b))
+	return err
+}
+
 // GetJob retrieves a job record by id
 func (db *PostgresDB) GetJob(jid string) (JobRecord, bool, error) {
-	query := `SELECT * FROM jobs WHERE id = $1`
+	query := `SELECT id, status, updated, mode, host, process_id, submitter, tenant, request_id, parent_id, failure_class, exit_code, oom_killed, archived, attempts FROM jobs WHERE id = $1`
 	var jr JobRecord
-	err := db.Handle.QueryRow(query, jid).Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.Mode, &jr.Host, &jr.ProcessID, &jr.Submitter)
+	var exitCode sql.NullInt64
+	var archived sql.NullTime
+	var attempts string
+	err := db.Handle.QueryRow(query, jid).Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.Mode, &jr.Host, &jr.ProcessID, &jr.Submitter, &jr.Tenant, &jr.RequestID, &jr.ParentID, &jr.FailureClass, &exitCode, &jr.OOMKilled, &archived, &attempts)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return JobRecord{}, false, nil
 		}
 		return JobRecord{}, false, err
 	}
+	if exitCode.Valid {
+		ec := int(exitCode.Int64)
+		jr.ExitCode = &ec
+	}
+	if archived.Valid {
+		jr.Archived = &archived.Time
+	}
+	if attempts != "" {
+		if err := json.Unmarshal([]byte(attempts), &jr.Attempts); err != nil {
+			return JobRecord{}, false, err
+		}
+	}
+	return jr, true, nil
+}
+
+// SetJobArchived marks a job archived at the current time, or not archived
+func (db *PostgresDB) SetJobArchived(jid string, archived bool) error {
+	var at interface{}
+	if archived {
+		at = time.Now()
+	}
+	_, err := db.Handle.Exec(`UPDATE jobs SET archived = $2 WHERE id = $1`, jid, at)
+	return err
+}
+
+// AddJobRequest stores execution parameters of a job
+func (db *PostgresDB) AddJobRequest(jr JobRequest) error {
+	command, err := json.Marshal(jr.Command)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO job_requests (job_id, process_id, process_version, inputs, command, rerun_of, outputs, response, mode, input_hash, cpus, memory, fan_out, log_level) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err = db.Handle.Exec(query, jr.JobID, jr.ProcessID, jr.ProcessVersion, string(jr.Inputs), string(command), jr.RerunOf, rawOrEmpty(jr.Outputs), jr.Response, jr.Mode, jr.InputHash, jr.CPUs, jr.Memory, jr.FanOut, jr.LogLevel)
+	return err
+}
+
+// GetJobRequest retrieves execution parameters of a job, false if they were not stored
+func (db *PostgresDB) GetJobRequest(jid string) (JobRequest, bool, error) {
+	query := `SELECT job_id, process_id, process_version, inputs, command, rerun_of, outputs, response, mode, input_hash, cpus, memory, fan_out, log_level FROM job_requests WHERE job_id = $1`
+
+	jr := JobRequest{}
+	var inputs, command, outputs string
+	err := db.Handle.QueryRow(query, jid).Scan(&jr.JobID, &jr.ProcessID, &jr.ProcessVersion, &inputs, &command, &jr.RerunOf, &outputs, &jr.Response, &jr.Mode, &jr.InputHash, &jr.CPUs, &jr.Memory, &jr.FanOut, &jr.LogLevel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return JobRequest{}, false, nil
+		}
+		return JobRequest{}, false, err
+	}
+	jr.Inputs = json.RawMessage(inputs)
+	jr.Outputs = emptyOrRaw(outputs)
+	if err := json.Unmarshal([]byte(command), &jr.Command); err != nil {
+		return JobRequest{}, false, err
+	}
 	return jr, true, nil
 }
 
+// FindSuccessfulJob retrieves the most recently updated successful job with the input hash, updated since the given time,
+// of the tenant and, if not empty, the submitter
+func (db *PostgresDB) FindSuccessfulJob(inputHash, tenant, submitter string, since time.Time) (string, bool, error) {
+	query := `SELECT j.id FROM jobs j JOIN job_requests r ON r.job_id = j.id
+		WHERE r.input_hash = $1 AND j.status = $2 AND j.archived IS NULL AND j.updated >= $3 AND j.tenant = $4 AND ($5 = '' OR j.submitter = $5)
+		ORDER BY j.updated DESC LIMIT 1`
+
+	var jid string
+	err := db.Handle.QueryRow(query, inputHash, SUCCESSFUL, since, tenant, submitter).Scan(&jid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return jid, true, nil
+}
+
+// GetJobsUpdatedBefore retrieves jobs last updated before a time, oldest first, optionally filtered by process and status
+func (db *PostgresDB) GetJobsUpdatedBefore(before time.Time, limit int, processIDs, statuses []string) ([]JobRecord, error) {
+	whereClauses := []string{"updated < $1"}
+	args := []interface{}{before}
+
+	argIndex := 2 // $1 is before
+
+	if len(processIDs) > 0 {
+		placeholders := make([]string, len(processIDs))
+		for i := range processIDs {
+			placeholders[i] = fmt.Sprintf("$%d", argIndex)
+			argIndex++
+		}
+		whereClauses = append(whereClauses, "process_id IN ("+strings.Join(placeholders, ", ")+")")
+		for _, pid := range processIDs {
+			args = append(args, pid)
+		}
+	}
+
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i := range statuses {
+			placeholders[i] = fmt.Sprintf("$%d", argIndex)
+			argIndex++
+		}
+		whereClauses = append(whereClauses, "status IN ("+strings.Join(placeholders, ", ")+")")
+		for _, st := range statuses {
+			args = append(args, st)
+		}
+	}
+
+	query := fmt.Sprintf("SELECT id, status, updated, process_id, submitter FROM jobs WHERE %s ORDER BY updated ASC LIMIT $%d", strings.Join(whereClauses, " AND "), argIndex)
+	args = append(args, limit)
+
+	rows, err := db.Handle.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []JobRecord
+	for rows.Next() {
+		var jr JobRecord
+		if err := rows.Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.ProcessID, &jr.Submitter); err != nil {
+			return nil, err
+		}
+		res = append(res, jr)
+	}
+	return res, rows.Err()
+}
+
+// DeleteJobs deletes job records and their execution parameters, returns number of deleted jobs
+func (db *PostgresDB) DeleteJobs(jids []string) (int64, error) {
+	if len(jids) == 0 {
+		return 0, nil
+	}
+	placeholders := make([]string, len(jids))
+	args := make([]interface{}, len(jids))
+	for i, jid := range jids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = jid
+	}
+	in := strings.Join(placeholders, ", ")
+
+	tx, err := db.Handle.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM job_requests WHERE job_id IN ("+in+")", args...); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM job_notes WHERE job_id IN ("+in+")", args...); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM jobs WHERE id IN ("+in+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 // CheckJobExist checks if a job exists in the database
 func (db *PostgresDB) CheckJobExist(jid string) (bool, error) {
 	query := `SELECT 1 FROM jobs WHERE id = $1`
@@ -102,9 +414,12 @@ func (db *PostgresDB) CheckJobExist(jid string) (bool, error) {
 }
 
 // Assumes query parameters are valid
-func (pgDB *PostgresDB) GetJobs(limit, offset int, processIDs, statuses, submitters []string) ([]JobRecord, error) {
-	baseQuery := `SELECT id, status, updated, process_id, submitter FROM jobs`
-	whereClauses := []string{}
+func (pgDB *PostgresDB) GetJobs(limit, offset int, processIDs, statuses, submitters, tenants []string, includeChildren bool) ([]JobRecord, error) {
+	baseQuery := `SELECT id, status, updated, process_id, submitter, parent_id, (SELECT COUNT(*) FROM jobs c WHERE c.parent_id = jobs.id) FROM jobs`
+	whereClauses := []string{"archived IS NULL"}
+	if !includeChildren {
+		whereClauses = append(whereClauses, "parent_id = ''")
+	}
 	args := []interface{}{}
 
 	argIndex := 1 // Start from 1 for PostgreSQL placeholders
@@ -145,6 +460,18 @@ func (pgDB *PostgresDB) GetJobs(limit, offset int, processIDs, statuses, submitt
 		}
 	}
 
+	if len(tenants) > 0 {
+		placeholders := make([]string, len(tenants))
+		for i := range tenants {
+			placeholders[i] = fmt.Sprintf("$%d", argIndex)
+			argIndex++
+		}
+		whereClauses = append(whereClauses, "tenant IN ("+strings.Join(placeholders, ", ")+")")
+		for _, t := range tenants {
+			args = append(args, t)
+		}
+	}
+
 	if len(whereClauses) > 0 {
 		baseQuery += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
@@ -163,7 +490,213 @@ func (pgDB *PostgresDB) GetJobs(limit, offset int, processIDs, statuses, submitt
 
 	for rows.Next() {
 		var r JobRecord
-		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter); err != nil {
+		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter, &r.ParentID, &r.Children); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetJobChildren retrieves the jobs submitted by a pipeline or fan-out job in the order they were created
+func (pgDB *PostgresDB) GetJobChildren(parentID string) ([]JobRecord, error) {
+	query := `SELECT id, status, updated, process_id, submitter, failure_class FROM jobs WHERE parent_id = $1 AND archived IS NULL ORDER BY created, id`
+
+	rows, err := pgDB.Handle.Query(query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobRecord{}
+	for rows.Next() {
+		r := JobRecord{ParentID: parentID}
+		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter, &r.FailureClass); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// Get number of jobs per process and status updated since the given time
+func (pgDB *PostgresDB) GetProcessStatusCounts(since time.Time) ([]StatusCount, error) {
+	query := `SELECT process_id, status, COUNT(*) FROM jobs WHERE updated >= $1 GROUP BY process_id, status ORDER BY process_id`
+
+	rows, err := pgDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []StatusCount{}
+	for rows.Next() {
+		var sc StatusCount
+		if err := rows.Scan(&sc.ProcessID, &sc.Status, &sc.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, sc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get submission and last update times of jobs updated since the given time
+func (pgDB *PostgresDB) GetJobTimings(since time.Time) ([]JobTiming, error) {
+	query := `SELECT process_id, submitter, status, failure_class, created, updated FROM jobs WHERE updated >= $1 ORDER BY updated`
+
+	rows, err := pgDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobTiming{}
+	for rows.Next() {
+		var jt JobTiming
+		var created sql.NullTime
+		if err := rows.Scan(&jt.ProcessID, &jt.Submitter, &jt.Status, &jt.FailureClass, &created, &jt.Updated); err != nil {
+			return nil, err
+		}
+		jt.Created = created.Time
+		res = append(res, jt)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// updateJobCost stores the estimated cost of a job
+func (pgDB *PostgresDB) updateJobCost(jid string, c JobCost) error {
+	query := `UPDATE jobs SET cost = $2, cost_currency = $3, cost_vcpu_seconds = $4, cost_cpu_hours = $5, cost_storage_bytes = $6 WHERE id = $1`
+	_, err := pgDB.Handle.Exec(query, jid, c.Total, c.Currency, c.VCPUSeconds, c.CPUHours, c.StorageBytes)
+	return err
+}
+
+// GetJobCosts returns costs of jobs updated since the given time, jobs without a cost are left out
+func (pgDB *PostgresDB) GetJobCosts(since time.Time) ([]JobCostRecord, error) {
+	query := `SELECT process_id, submitter, updated, cost, cost_currency, cost_vcpu_seconds, cost_cpu_hours, cost_storage_bytes FROM jobs WHERE updated >= $1 AND cost IS NOT NULL ORDER BY updated`
+
+	rows, err := pgDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobCostRecord{}
+	for rows.Next() {
+		var r JobCostRecord
+		if err := rows.Scan(&r.ProcessID, &r.Submitter, &r.Updated, &r.Total, &r.Currency, &r.VCPUSeconds, &r.CPUHours, &r.StorageBytes); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// Add a note to a job, returns the ID of the note
+func (pgDB *PostgresDB) AddJobNote(n JobNote) (int64, error) {
+	query := `INSERT INTO job_notes (job_id, author, text, created) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	var id int64
+	err := pgDB.Handle.QueryRow(query, n.JobID, n.Author, n.Text, n.Created).Scan(&id)
+	return id, err
+}
+
+// Get notes of a job, oldest first
+func (pgDB *PostgresDB) GetJobNotes(jid string) ([]JobNote, error) {
+	query := `SELECT id, job_id, author, text, created FROM job_notes WHERE job_id = $1 ORDER BY id`
+
+	rows, err := pgDB.Handle.Query(query, jid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobNote{}
+	for rows.Next() {
+		var n JobNote
+		if err := rows.Scan(&n.ID, &n.JobID, &n.Author, &n.Text, &n.Created); err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
+
+// AddAuditRecord appends an audit record
+func (pgDB *PostgresDB) AddAuditRecord(ar AuditRecord) error {
+	query := `INSERT INTO audit_log (created, action, actor, roles, source_ip, method, path, resource_id, status_code, request_id, body_sha256) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := pgDB.Handle.Exec(query, ar.Time, ar.Action, ar.Actor, ar.Roles, ar.SourceIP, ar.Method, ar.Path, ar.ResourceID, ar.StatusCode, ar.RequestID, ar.BodySHA256)
+	return err
+}
+
+// Get audit records, newest first. Assumes query parameters are valid
+func (pgDB *PostgresDB) GetAuditRecords(limit, offset int, filter AuditFilter) ([]AuditRecord, error) {
+	baseQuery := `SELECT id, created, action, actor, roles, source_ip, method, path, resource_id, status_code, request_id, body_sha256 FROM audit_log`
+	whereClauses := []string{}
+	args := []interface{}{}
+
+	argIndex := 1 // Start from 1 for PostgreSQL placeholders
+
+	inClause := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, len(values))
+		for i := range values {
+			placeholders[i] = fmt.Sprintf("$%d", argIndex)
+			argIndex++
+		}
+		whereClauses = append(whereClauses, column+" IN ("+strings.Join(placeholders, ", ")+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	inClause("actor", filter.Actors)
+	inClause("action", filter.Actions)
+	inClause("resource_id", filter.ResourceIDs)
+
+	if !filter.Since.IsZero() {
+		whereClauses = append(whereClauses, fmt.Sprintf("created >= $%d", argIndex))
+		args = append(args, filter.Since)
+		argIndex++
+	}
+	if !filter.Until.IsZero() {
+		whereClauses = append(whereClauses, fmt.Sprintf("created <= $%d", argIndex))
+		args = append(args, filter.Until)
+		argIndex++
+	}
+
+	if len(whereClauses) > 0 {
+		baseQuery += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	query := baseQuery + fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+	args = append(args, limit, offset)
+
+	rows, err := pgDB.Handle.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []AuditRecord{}
+	for rows.Next() {
+		var r AuditRecord
+		if err := rows.Scan(&r.ID, &r.Time, &r.Action, &r.Actor, &r.Roles, &r.SourceIP, &r.Method, &r.Path, &r.ResourceID, &r.StatusCode, &r.RequestID, &r.BodySHA256); err != nil {
 			return nil, err
 		}
 		res = append(res, r)
@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -82,14 +83,150 @@ func (sqliteDB *SQLiteDB) createTables() error {
 	if err != nil {
 		return fmt.Errorf("error creating tables: %s", err)
 	}
+
+	// audit_log is append-only, triggers reject updates and deletes
+	queryAudit := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created TIMESTAMP NOT NULL,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		roles TEXT NOT NULL DEFAULT '',
+		source_ip TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		resource_id TEXT NOT NULL DEFAULT '',
+		status_code INTEGER NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		body_sha256 TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
+	`
+
+	_, err = sqliteDB.Handle.Exec(queryAudit)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Execution parameters of jobs, command is a JSON array
+	queryRequests := `
+	CREATE TABLE IF NOT EXISTS job_requests (
+		job_id TEXT PRIMARY KEY,
+		process_id TEXT NOT NULL,
+		process_version TEXT NOT NULL DEFAULT '',
+		inputs TEXT NOT NULL,
+		command TEXT NOT NULL,
+		rerun_of TEXT NOT NULL DEFAULT ''
+	);
+	`
+
+	_, err = sqliteDB.Handle.Exec(queryRequests)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Notes of jobs, deleted with their job
+	queryNotes := `
+	CREATE TABLE IF NOT EXISTS job_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_id TEXT NOT NULL,
+		author TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL,
+		created TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_job_notes_job_id ON job_notes(job_id);
+	`
+
+	_, err = sqliteDB.Handle.Exec(queryNotes)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %s", err)
+	}
+
+	// Columns added after the initial schema, existing databases are migrated in place
+	migrations := []struct{ table, column, definition string }{
+		{"jobs", "request_id", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "created", "TIMESTAMP"}, // NULL for jobs created before this column was added
+		{"jobs", "failure_class", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "tenant", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "archived", "TIMESTAMP"}, // NULL unless archived
+		{"jobs", "exit_code", "INTEGER"},  // NULL until the process exited
+		{"jobs", "oom_killed", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"jobs", "parent_id", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "attempts", "TEXT NOT NULL DEFAULT ''"}, // JSON array of attempts of aws-batch jobs
+		{"jobs", "cost", "REAL"},                         // NULL until the metadata of the job was written
+		{"jobs", "cost_currency", "TEXT NOT NULL DEFAULT ''"},
+		{"jobs", "cost_vcpu_seconds", "REAL NOT NULL DEFAULT 0"},
+		{"jobs", "cost_cpu_hours", "REAL NOT NULL DEFAULT 0"},
+		{"jobs", "cost_storage_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		{"job_requests", "outputs", "TEXT NOT NULL DEFAULT ''"},
+		{"job_requests", "response", "TEXT NOT NULL DEFAULT ''"},
+		{"job_requests", "mode", "TEXT NOT NULL DEFAULT ''"},
+		{"job_requests", "input_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"job_requests", "cpus", "REAL NOT NULL DEFAULT 0"},
+		{"job_requests", "memory", "INTEGER NOT NULL DEFAULT 0"},
+		{"job_requests", "fan_out", "TEXT NOT NULL DEFAULT ''"},
+		{"job_requests", "log_level", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, m := range migrations {
+		err = sqliteDB.addColumnIfNotExists(m.table, m.column, m.definition)
+		if err != nil {
+			return fmt.Errorf("error migrating tables: %s", err)
+		}
+	}
+
+	_, err = sqliteDB.Handle.Exec(`CREATE INDEX IF NOT EXISTS idx_job_requests_input_hash ON job_requests(input_hash);
+	CREATE INDEX IF NOT EXISTS idx_jobs_parent_id ON jobs(parent_id);`)
+	if err != nil {
+		return fmt.Errorf("error migrating tables: %s", err)
+	}
 	return nil
 }
 
+// SQLite does not support ADD COLUMN IF NOT EXISTS, so check table info first
+func (sqliteDB *SQLiteDB) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := sqliteDB.Handle.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = sqliteDB.Handle.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // Add job to the database. Will return error if job exist.
-func (sqliteDB *SQLiteDB) addJob(jid, status, mode, host, processID, submitter string, updated time.Time) error {
-	query := `INSERT INTO jobs (id, status, updated, mode, host, process_id, submitter) VALUES (?, ?, ?, ?, ?, ?, ?)`
+func (sqliteDB *SQLiteDB) addJob(jid, status, mode, host, processID, submitter, tenant, requestID, parentID string, updated time.Time) error {
+	query := `INSERT INTO jobs (id, status, updated, mode, host, process_id, submitter, tenant, request_id, parent_id, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := sqliteDB.Handle.Exec(query, jid, status, updated, mode, host, processID, submitter)
+	_, err := sqliteDB.Handle.Exec(query, jid, status, updated, mode, host, processID, submitter, tenant, requestID, parentID, updated)
 	if err != nil {
 		return err
 	}
@@ -106,16 +243,64 @@ func (sqliteDB *SQLiteDB) updateJobRecord(jid, status string, now time.Time) err
 	return nil
 }
 
+// Update status and time of several jobs in one transaction.
+func (sqliteDB *SQLiteDB) updateJobRecords(updates []statusWrite) error {
+	tx, err := sqliteDB.Handle.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE jobs SET status = ?, updated = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, u := range updates {
+		if _, err := stmt.Exec(u.Status, u.Updated, u.JobID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Update failure class of a job.
+func (sqliteDB *SQLiteDB) updateFailureClass(jid, class string) error {
+	query := `UPDATE jobs SET failure_class = ? WHERE id = ?`
+	_, err := sqliteDB.Handle.Exec(query, class, jid)
+	return err
+}
+
+// Update exit code and OOM killed flag of a job.
+func (sqliteDB *SQLiteDB) updateExitDetail(jid string, exitCode int, oomKilled bool) error {
+	query := `UPDATE jobs SET exit_code = ?, oom_killed = ? WHERE id = ?`
+	_, err := sqliteDB.Handle.Exec(query, exitCode, oomKilled, jid)
+	return err
+}
+
+// Update attempts of a job.
+func (sqliteDB *SQLiteDB) updateAttempts(jid string, attempts []Attempt) error {
+	b, err := json.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+	_, err = sqliteDB.Handle.Exec(`UPDATE jobs SET attempts = ? WHERE id = ?`, string(b), jid)
+	return err
+}
+
 // Get Job Record from database given a job id.
 // If job do not exists, or error encountered bool would be false.
 // Similar behavior as key exist in hashmap.
 func (sqliteDB *SQLiteDB) GetJob(jid string) (JobRecord, bool, error) {
-	query := `SELECT * FROM jobs WHERE id = ?`
+	query := `SELECT id, status, updated, mode, host, process_id, submitter, tenant, request_id, parent_id, failure_class, exit_code, oom_killed, archived, attempts FROM jobs WHERE id = ?`
 
 	jr := JobRecord{}
+	var exitCode sql.NullInt64
+	var archived sql.NullTime
+	var attempts string
 
 	row := sqliteDB.Handle.QueryRow(query, jid)
-	err := row.Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.Mode, &jr.Host, &jr.ProcessID, &jr.Submitter)
+	err := row.Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.Mode, &jr.Host, &jr.ProcessID, &jr.Submitter, &jr.Tenant, &jr.RequestID, &jr.ParentID, &jr.FailureClass, &exitCode, &jr.OOMKilled, &archived, &attempts)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return JobRecord{}, false, nil
@@ -124,9 +309,155 @@ func (sqliteDB *SQLiteDB) GetJob(jid string) (JobRecord, bool, error) {
 			return JobRecord{}, false, err
 		}
 	}
+	if exitCode.Valid {
+		ec := int(exitCode.Int64)
+		jr.ExitCode = &ec
+	}
+	if archived.Valid {
+		jr.Archived = &archived.Time
+	}
+	if attempts != "" {
+		if err := json.Unmarshal([]byte(attempts), &jr.Attempts); err != nil {
+			return JobRecord{}, false, err
+		}
+	}
 	return jr, true, nil
 }
 
+// Mark a job archived at the current time, or not archived.
+func (sqliteDB *SQLiteDB) SetJobArchived(jid string, archived bool) error {
+	var at interface{}
+	if archived {
+		at = time.Now()
+	}
+	_, err := sqliteDB.Handle.Exec(`UPDATE jobs SET archived = ? WHERE id = ?`, at, jid)
+	return err
+}
+
+// Store execution parameters of a job.
+func (sqliteDB *SQLiteDB) AddJobRequest(jr JobRequest) error {
+	command, err := json.Marshal(jr.Command)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO job_requests (job_id, process_id, process_version, inputs, command, rerun_of, outputs, response, mode, input_hash, cpus, memory, fan_out, log_level) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = sqliteDB.Handle.Exec(query, jr.JobID, jr.ProcessID, jr.ProcessVersion, string(jr.Inputs), string(command), jr.RerunOf, rawOrEmpty(jr.Outputs), jr.Response, jr.Mode, jr.InputHash, jr.CPUs, jr.Memory, jr.FanOut, jr.LogLevel)
+	return err
+}
+
+// Get execution parameters of a job, false if they were not stored.
+func (sqliteDB *SQLiteDB) GetJobRequest(jid string) (JobRequest, bool, error) {
+	query := `SELECT job_id, process_id, process_version, inputs, command, rerun_of, outputs, response, mode, input_hash, cpus, memory, fan_out, log_level FROM job_requests WHERE job_id = ?`
+
+	jr := JobRequest{}
+	var inputs, command, outputs string
+	err := sqliteDB.Handle.QueryRow(query, jid).Scan(&jr.JobID, &jr.ProcessID, &jr.ProcessVersion, &inputs, &command, &jr.RerunOf, &outputs, &jr.Response, &jr.Mode, &jr.InputHash, &jr.CPUs, &jr.Memory, &jr.FanOut, &jr.LogLevel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return JobRequest{}, false, nil
+		}
+		return JobRequest{}, false, err
+	}
+	jr.Inputs = json.RawMessage(inputs)
+	jr.Outputs = emptyOrRaw(outputs)
+	if err := json.Unmarshal([]byte(command), &jr.Command); err != nil {
+		return JobRequest{}, false, err
+	}
+	return jr, true, nil
+}
+
+// Find the most recently updated successful job with the input hash, updated since the given time, of the tenant and, if not empty, the submitter.
+func (sqliteDB *SQLiteDB) FindSuccessfulJob(inputHash, tenant, submitter string, since time.Time) (string, bool, error) {
+	query := `SELECT j.id FROM jobs j JOIN job_requests r ON r.job_id = j.id
+		WHERE r.input_hash = ? AND j.status = ? AND j.archived IS NULL AND j.updated >= ? AND j.tenant = ? AND (? = '' OR j.submitter = ?)
+		ORDER BY j.updated DESC LIMIT 1`
+
+	var jid string
+	err := sqliteDB.Handle.QueryRow(query, inputHash, SUCCESSFUL, since, tenant, submitter, submitter).Scan(&jid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return jid, true, nil
+}
+
+// Get jobs last updated before a time, oldest first, optionally filtered by process and status. Assumes query parameters are valid
+func (sqliteDB *SQLiteDB) GetJobsUpdatedBefore(before time.Time, limit int, processIDs, statuses []string) ([]JobRecord, error) {
+	whereClauses := []string{"updated < ?"}
+	args := []interface{}{before}
+
+	if len(processIDs) > 0 {
+		placeholders := strings.Repeat("?,", len(processIDs)-1) + "?"
+		whereClauses = append(whereClauses, fmt.Sprintf("process_id IN (%s)", placeholders))
+		for _, pid := range processIDs {
+			args = append(args, pid)
+		}
+	}
+
+	if len(statuses) > 0 {
+		placeholders := strings.Repeat("?,", len(statuses)-1) + "?"
+		whereClauses = append(whereClauses, fmt.Sprintf("status IN (%s)", placeholders))
+		for _, st := range statuses {
+			args = append(args, st)
+		}
+	}
+
+	query := fmt.Sprintf("SELECT id, status, updated, process_id, submitter FROM jobs WHERE %s ORDER BY updated ASC LIMIT ?", strings.Join(whereClauses, " AND "))
+	args = append(args, limit)
+
+	rows, err := sqliteDB.Handle.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []JobRecord
+	for rows.Next() {
+		var jr JobRecord
+		if err := rows.Scan(&jr.JobID, &jr.Status, &jr.LastUpdate, &jr.ProcessID, &jr.Submitter); err != nil {
+			return nil, err
+		}
+		res = append(res, jr)
+	}
+	return res, rows.Err()
+}
+
+// Delete job records and their execution parameters, returns number of deleted jobs
+func (sqliteDB *SQLiteDB) DeleteJobs(jids []string) (int64, error) {
+	if len(jids) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.Repeat("?,", len(jids)-1) + "?"
+	args := make([]interface{}, len(jids))
+	for i, jid := range jids {
+		args[i] = jid
+	}
+
+	tx, err := sqliteDB.Handle.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM job_requests WHERE job_id IN (%s)", placeholders), args...); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM job_notes WHERE job_id IN (%s)", placeholders), args...); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(fmt.Sprintf("DELETE FROM jobs WHERE id IN (%s)", placeholders), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 // Check if a job exists in database.
 func (sqliteDB *SQLiteDB) CheckJobExist(jid string) (bool, error) {
 	query := `SELECT id FROM jobs WHERE id = ?`
@@ -146,9 +477,12 @@ func (sqliteDB *SQLiteDB) CheckJobExist(jid string) (bool, error) {
 }
 
 // Assumes query parameters are valid
-func (sqliteDB *SQLiteDB) GetJobs(limit, offset int, processIDs, statuses []string, submitters []string) ([]JobRecord, error) {
-	baseQuery := `SELECT id, status, updated, process_id, submitter FROM jobs`
-	whereClauses := []string{}
+func (sqliteDB *SQLiteDB) GetJobs(limit, offset int, processIDs, statuses, submitters, tenants []string, includeChildren bool) ([]JobRecord, error) {
+	baseQuery := `SELECT id, status, updated, process_id, submitter, parent_id, (SELECT COUNT(*) FROM jobs c WHERE c.parent_id = jobs.id) FROM jobs`
+	whereClauses := []string{"archived IS NULL"}
+	if !includeChildren {
+		whereClauses = append(whereClauses, "parent_id = ''")
+	}
 	args := []interface{}{}
 
 	if len(processIDs) > 0 {
@@ -175,6 +509,14 @@ func (sqliteDB *SQLiteDB) GetJobs(limit, offset int, processIDs, statuses []stri
 		}
 	}
 
+	if len(tenants) > 0 {
+		placeholders := strings.Repeat("?,", len(tenants)-1) + "?"
+		whereClauses = append(whereClauses, fmt.Sprintf("tenant IN (%s)", placeholders))
+		for _, t := range tenants {
+			args = append(args, t)
+		}
+	}
+
 	if len(whereClauses) > 0 {
 		baseQuery += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
@@ -192,7 +534,208 @@ func (sqliteDB *SQLiteDB) GetJobs(limit, offset int, processIDs, statuses []stri
 
 	for rows.Next() {
 		var r JobRecord
-		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter); err != nil {
+		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter, &r.ParentID, &r.Children); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get the jobs submitted by a pipeline or fan-out job in the order they were created.
+func (sqliteDB *SQLiteDB) GetJobChildren(parentID string) ([]JobRecord, error) {
+	query := `SELECT id, status, updated, process_id, submitter, failure_class FROM jobs WHERE parent_id = ? AND archived IS NULL ORDER BY created, id`
+
+	rows, err := sqliteDB.Handle.Query(query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobRecord{}
+	for rows.Next() {
+		r := JobRecord{ParentID: parentID}
+		if err := rows.Scan(&r.JobID, &r.Status, &r.LastUpdate, &r.ProcessID, &r.Submitter, &r.FailureClass); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// Get number of jobs per process and status updated since the given time
+func (sqliteDB *SQLiteDB) GetProcessStatusCounts(since time.Time) ([]StatusCount, error) {
+	query := `SELECT process_id, status, COUNT(*) FROM jobs WHERE updated >= ? GROUP BY process_id, status ORDER BY process_id`
+
+	rows, err := sqliteDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []StatusCount{}
+	for rows.Next() {
+		var sc StatusCount
+		if err := rows.Scan(&sc.ProcessID, &sc.Status, &sc.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, sc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get submission and last update times of jobs updated since the given time
+func (sqliteDB *SQLiteDB) GetJobTimings(since time.Time) ([]JobTiming, error) {
+	query := `SELECT process_id, submitter, status, failure_class, created, updated FROM jobs WHERE updated >= ? ORDER BY updated`
+
+	rows, err := sqliteDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobTiming{}
+	for rows.Next() {
+		var jt JobTiming
+		var created sql.NullTime
+		if err := rows.Scan(&jt.ProcessID, &jt.Submitter, &jt.Status, &jt.FailureClass, &created, &jt.Updated); err != nil {
+			return nil, err
+		}
+		jt.Created = created.Time
+		res = append(res, jt)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Store the estimated cost of a job.
+func (sqliteDB *SQLiteDB) updateJobCost(jid string, c JobCost) error {
+	query := `UPDATE jobs SET cost = ?, cost_currency = ?, cost_vcpu_seconds = ?, cost_cpu_hours = ?, cost_storage_bytes = ? WHERE id = ?`
+	_, err := sqliteDB.Handle.Exec(query, c.Total, c.Currency, c.VCPUSeconds, c.CPUHours, c.StorageBytes, jid)
+	return err
+}
+
+// Get costs of jobs updated since the given time, jobs without a cost are left out
+func (sqliteDB *SQLiteDB) GetJobCosts(since time.Time) ([]JobCostRecord, error) {
+	query := `SELECT process_id, submitter, updated, cost, cost_currency, cost_vcpu_seconds, cost_cpu_hours, cost_storage_bytes FROM jobs WHERE updated >= ? AND cost IS NOT NULL ORDER BY updated`
+
+	rows, err := sqliteDB.Handle.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobCostRecord{}
+	for rows.Next() {
+		var r JobCostRecord
+		if err := rows.Scan(&r.ProcessID, &r.Submitter, &r.Updated, &r.Total, &r.Currency, &r.VCPUSeconds, &r.CPUHours, &r.StorageBytes); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// Add a note to a job, returns the ID of the note
+func (sqliteDB *SQLiteDB) AddJobNote(n JobNote) (int64, error) {
+	query := `INSERT INTO job_notes (job_id, author, text, created) VALUES (?, ?, ?, ?)`
+
+	res, err := sqliteDB.Handle.Exec(query, n.JobID, n.Author, n.Text, n.Created)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Get notes of a job, oldest first
+func (sqliteDB *SQLiteDB) GetJobNotes(jid string) ([]JobNote, error) {
+	query := `SELECT id, job_id, author, text, created FROM job_notes WHERE job_id = ? ORDER BY id`
+
+	rows, err := sqliteDB.Handle.Query(query, jid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []JobNote{}
+	for rows.Next() {
+		var n JobNote
+		if err := rows.Scan(&n.ID, &n.JobID, &n.Author, &n.Text, &n.Created); err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
+
+// Append an audit record
+func (sqliteDB *SQLiteDB) AddAuditRecord(ar AuditRecord) error {
+	query := `INSERT INTO audit_log (created, action, actor, roles, source_ip, method, path, resource_id, status_code, request_id, body_sha256) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := sqliteDB.Handle.Exec(query, ar.Time, ar.Action, ar.Actor, ar.Roles, ar.SourceIP, ar.Method, ar.Path, ar.ResourceID, ar.StatusCode, ar.RequestID, ar.BodySHA256)
+	return err
+}
+
+// Get audit records, newest first. Assumes query parameters are valid
+func (sqliteDB *SQLiteDB) GetAuditRecords(limit, offset int, filter AuditFilter) ([]AuditRecord, error) {
+	baseQuery := `SELECT id, created, action, actor, roles, source_ip, method, path, resource_id, status_code, request_id, body_sha256 FROM audit_log`
+	whereClauses := []string{}
+	args := []interface{}{}
+
+	inClause := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := strings.Repeat("?,", len(values)-1) + "?"
+		whereClauses = append(whereClauses, fmt.Sprintf("%s IN (%s)", column, placeholders))
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	inClause("actor", filter.Actors)
+	inClause("action", filter.Actions)
+	inClause("resource_id", filter.ResourceIDs)
+
+	if !filter.Since.IsZero() {
+		whereClauses = append(whereClauses, "created >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		whereClauses = append(whereClauses, "created <= ?")
+		args = append(args, filter.Until)
+	}
+
+	if len(whereClauses) > 0 {
+		baseQuery += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	query := baseQuery + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := sqliteDB.Handle.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []AuditRecord{}
+	for rows.Next() {
+		var r AuditRecord
+		if err := rows.Scan(&r.ID, &r.Time, &r.Action, &r.Actor, &r.Roles, &r.SourceIP, &r.Method, &r.Path, &r.ResourceID, &r.StatusCode, &r.RequestID, &r.BodySHA256); err != nil {
 			return nil, err
 		}
 		res = append(res, r)
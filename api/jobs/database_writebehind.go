@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// statusWrite is the latest status of a job that is not written to the database yet
+type statusWrite struct {
+	JobID   string
+	Status  string
+	Updated time.Time
+}
+
+// writeBehindDB batches status updates of jobs and writes them every interval in one transaction,
+// so that only the latest of several updates of a job between two flushes is written.
+// Terminal statuses are written right away after the pending updates. Reads see pending updates:
+// GetJob returns the pending status, queries over many jobs flush first.
+type writeBehindDB struct {
+	Database
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]statusWrite
+	// Held while writing so that an older pending status can not be written after a newer one
+	flushMu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWriteBehindDB(db Database, interval time.Duration) *writeBehindDB {
+	wb := &writeBehindDB{
+		Database: db,
+		interval: interval,
+		pending:  make(map[string]statusWrite),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+func (wb *writeBehindDB) run() {
+	defer close(wb.done)
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := wb.flush(); err != nil {
+				log.Errorf("Could not write job statuses. Error: %s", err.Error())
+			}
+		case <-wb.stop:
+			return
+		}
+	}
+}
+
+// Write pending updates, they are kept for the next flush if the write fails unless a newer update replaced them
+func (wb *writeBehindDB) flush() error {
+	wb.flushMu.Lock()
+	defer wb.flushMu.Unlock()
+
+	wb.mu.Lock()
+	if len(wb.pending) == 0 {
+		wb.mu.Unlock()
+		return nil
+	}
+	updates := make([]statusWrite, 0, len(wb.pending))
+	for _, u := range wb.pending {
+		updates = append(updates, u)
+	}
+	wb.pending = make(map[string]statusWrite)
+	wb.mu.Unlock()
+
+	err := wb.Database.updateJobRecords(updates)
+	if err != nil {
+		wb.mu.Lock()
+		for _, u := range updates {
+			if _, ok := wb.pending[u.JobID]; !ok {
+				wb.pending[u.JobID] = u
+			}
+		}
+		wb.mu.Unlock()
+	}
+	return err
+}
+
+func (wb *writeBehindDB) updateJobRecord(jid, status string, now time.Time) error {
+	switch status {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		wb.flushMu.Lock()
+		defer wb.flushMu.Unlock()
+		wb.mu.Lock()
+		delete(wb.pending, jid)
+		wb.mu.Unlock()
+		return wb.Database.updateJobRecord(jid, status, now)
+	}
+
+	wb.mu.Lock()
+	wb.pending[jid] = statusWrite{JobID: jid, Status: status, Updated: now}
+	wb.mu.Unlock()
+	return nil
+}
+
+func (wb *writeBehindDB) GetJob(jid string) (JobRecord, bool, error) {
+	jr, ok, err := wb.Database.GetJob(jid)
+	if err != nil || !ok {
+		return jr, ok, err
+	}
+	wb.mu.Lock()
+	if u, found := wb.pending[jid]; found {
+		jr.Status, jr.LastUpdate = u.Status, u.Updated
+	}
+	wb.mu.Unlock()
+	return jr, ok, nil
+}
+
+func (wb *writeBehindDB) GetJobs(limit, offset int, processIDs, statuses, submitters, tenants []string, includeChildren bool) ([]JobRecord, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetJobs(limit, offset, processIDs, statuses, submitters, tenants, includeChildren)
+}
+
+func (wb *writeBehindDB) GetJobChildren(parentID string) ([]JobRecord, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetJobChildren(parentID)
+}
+
+func (wb *writeBehindDB) GetJobsUpdatedBefore(before time.Time, limit int, processIDs, statuses []string) ([]JobRecord, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetJobsUpdatedBefore(before, limit, processIDs, statuses)
+}
+
+func (wb *writeBehindDB) GetProcessStatusCounts(since time.Time) ([]StatusCount, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetProcessStatusCounts(since)
+}
+
+func (wb *writeBehindDB) GetJobTimings(since time.Time) ([]JobTiming, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetJobTimings(since)
+}
+
+func (wb *writeBehindDB) GetJobCosts(since time.Time) ([]JobCostRecord, error) {
+	if err := wb.flush(); err != nil {
+		return nil, err
+	}
+	return wb.Database.GetJobCosts(since)
+}
+
+func (wb *writeBehindDB) DeleteJobs(jids []string) (int64, error) {
+	wb.mu.Lock()
+	for _, jid := range jids {
+		delete(wb.pending, jid)
+	}
+	wb.mu.Unlock()
+	return wb.Database.DeleteJobs(jids)
+}
+
+// Close writes pending updates before the database is closed
+func (wb *writeBehindDB) Close() error {
+	close(wb.stop)
+	<-wb.done
+	if err := wb.flush(); err != nil {
+		log.Errorf("Could not write job statuses. Error: %s", err.Error())
+	}
+	return wb.Database.Close()
+}
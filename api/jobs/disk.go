@@ -0,0 +1,250 @@
+package jobs
+
+import (
+	"app/config"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Share of JOB_DISK_QUOTA_MB at which local disk is considered nearly full
+const diskQuotaNearFull = 0.9
+
+// Local files of jobs modified more recently are not cleaned, the job may not be active yet
+const diskCleanMinAge = time.Minute
+
+// DiskUsage of the local directories jobs write to, TMP_JOB_LOGS_DIR and the scratch directory
+type DiskUsage struct {
+	LogsBytes    int64 `json:"logsBytes"`
+	ScratchBytes int64 `json:"scratchBytes"`
+	// Available bytes of the filesystem of the directories, the smaller one if they are on different filesystems
+	FreeBytes int64 `json:"freeBytes"`
+	// Limits of JOB_DISK_QUOTA_MB and JOB_DISK_MIN_FREE_MB, 0 if not set
+	QuotaBytes   int64     `json:"quotaBytes,omitempty"`
+	MinFreeBytes int64     `json:"minFreeBytes,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+// Sizes in MB for display
+func (u DiskUsage) UsedMB() int64  { return (u.LogsBytes + u.ScratchBytes) >> 20 }
+func (u DiskUsage) FreeMB() int64  { return u.FreeBytes >> 20 }
+func (u DiskUsage) QuotaMB() int64 { return u.QuotaBytes >> 20 }
+
+// NearlyFull returns why local disk is nearly full, empty if it is not
+func (u DiskUsage) NearlyFull() string {
+	used := u.LogsBytes + u.ScratchBytes
+	if u.QuotaBytes > 0 && float64(used) >= diskQuotaNearFull*float64(u.QuotaBytes) {
+		return fmt.Sprintf("%dMB of the %dMB quota used", u.UsedMB(), u.QuotaMB())
+	}
+	if u.MinFreeBytes > 0 && u.FreeBytes < u.MinFreeBytes {
+		return fmt.Sprintf("%dMB free, %dMB required", u.FreeMB(), u.MinFreeBytes>>20)
+	}
+	return ""
+}
+
+// DiskMonitor keeps the last measured usage of local disk by job logs and scratch directories, safe for concurrent use
+type DiskMonitor struct {
+	mu      sync.RWMutex
+	usage   DiskUsage
+	checked bool
+	// Requests cleaning before the next interval, buffered so that requests are not lost while cleaning
+	clean chan struct{}
+}
+
+func NewDiskMonitor() *DiskMonitor {
+	return &DiskMonitor{clean: make(chan struct{}, 1)}
+}
+
+// Check measures disk usage, it is a no-op returning false if neither JOB_DISK_QUOTA_MB nor JOB_DISK_MIN_FREE_MB is set
+func (m *DiskMonitor) Check() (DiskUsage, bool, error) {
+	cfg := config.Get().Jobs
+	if cfg.DiskQuotaMB <= 0 && cfg.DiskMinFreeMB <= 0 {
+		return DiskUsage{}, false, nil
+	}
+
+	u := DiskUsage{
+		QuotaBytes:   int64(cfg.DiskQuotaMB) << 20,
+		MinFreeBytes: int64(cfg.DiskMinFreeMB) << 20,
+		CheckedAt:    time.Now(),
+	}
+	var err error
+	if u.LogsBytes, err = dirSize(config.Get().Logging.JobLogsDir); err != nil {
+		return DiskUsage{}, true, err
+	}
+	if u.ScratchBytes, err = dirSize(scratchRoot()); err != nil {
+		return DiskUsage{}, true, err
+	}
+	u.FreeBytes = -1
+	for _, dir := range []string{config.Get().Logging.JobLogsDir, scratchRoot()} {
+		free, err := availableDiskBytes(dir)
+		if err != nil {
+			continue // scratch directory is created with the first scratch directory of a job
+		}
+		if u.FreeBytes < 0 || free < u.FreeBytes {
+			u.FreeBytes = free
+		}
+	}
+	if u.FreeBytes < 0 {
+		return DiskUsage{}, true, fmt.Errorf("could not get free space of %s", config.Get().Logging.JobLogsDir)
+	}
+
+	m.mu.Lock()
+	m.usage, m.checked = u, true
+	m.mu.Unlock()
+	return u, true, nil
+}
+
+// Usage returns the last measured usage, false if it was not measured yet
+func (m *DiskMonitor) Usage() (DiskUsage, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.usage, m.checked
+}
+
+// NearlyFull returns why local disk was nearly full when it was last checked, empty if it was not.
+// Limits that were removed since are not enforced.
+func (m *DiskMonitor) NearlyFull() string {
+	cfg := config.Get().Jobs
+	if cfg.DiskQuotaMB <= 0 && cfg.DiskMinFreeMB <= 0 {
+		return ""
+	}
+	u, ok := m.Usage()
+	if !ok {
+		return ""
+	}
+	return u.NearlyFull()
+}
+
+// RequestClean asks the routine of the monitor to clean local files without waiting for the next check
+func (m *DiskMonitor) RequestClean() {
+	select {
+	case m.clean <- struct{}{}:
+	default:
+	}
+}
+
+// CleanRequests receives a value when cleaning was requested
+func (m *DiskMonitor) CleanRequests() <-chan struct{} {
+	return m.clean
+}
+
+// Total size of the files under dir, 0 if dir does not exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed while walking
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Local log files and scratch directory of a job
+type localJobFiles struct {
+	jobID        string
+	paths        []string
+	logsBytes    int64
+	scratchBytes int64
+	modified     time.Time // of the most recently modified file
+}
+
+// CleanLocalFiles removes log files and scratch directories of jobs that are not active, least recently modified first,
+// until usage is no longer nearly full. Logs of jobs are uploaded to storage before they are removed and are kept if
+// that fails. Returns the number of bytes removed.
+func CleanLocalFiles(svc *s3.S3, db Database, activeJobs *ActiveJobs, usage DiskUsage) (int64, error) {
+	files := map[string]*localJobFiles{}
+	add := func(path, jid string, isLog bool) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		size := info.Size()
+		if info.IsDir() {
+			size, _ = dirSize(path)
+		}
+		f, ok := files[jid]
+		if !ok {
+			f = &localJobFiles{jobID: jid}
+			files[jid] = f
+		}
+		f.paths = append(f.paths, path)
+		if isLog {
+			f.logsBytes += size
+		} else {
+			f.scratchBytes += size
+		}
+		if info.ModTime().After(f.modified) {
+			f.modified = info.ModTime()
+		}
+	}
+
+	logsDir := config.Get().Logging.JobLogsDir
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		// <jobID>.<process|stderr|server>.jsonl
+		if jid, _, ok := strings.Cut(e.Name(), "."); ok && !e.IsDir() && strings.HasSuffix(e.Name(), ".jsonl") {
+			add(filepath.Join(logsDir, e.Name()), jid, true)
+		}
+	}
+	// <jobID> directories and <jobID>.inputs.json files, the scratch directory may not exist yet
+	if entries, err := os.ReadDir(scratchRoot()); err == nil {
+		for _, e := range entries {
+			jid, _, _ := strings.Cut(e.Name(), ".")
+			add(filepath.Join(scratchRoot(), e.Name()), jid, false)
+		}
+	}
+
+	candidates := make([]*localJobFiles, 0, len(files))
+	for jid, f := range files {
+		if activeJobs.Has(jid) || time.Since(f.modified) < diskCleanMinAge {
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+	sort.Slice(candidates, func(i, k int) bool { return candidates[i].modified.Before(candidates[k].modified) })
+
+	var freed int64
+	for _, f := range candidates {
+		if usage.NearlyFull() == "" {
+			break
+		}
+		// logs of jobs that were closed normally are already uploaded, uploading them again is harmless
+		if jr, ok, err := db.GetJob(f.jobID); err == nil && ok && f.logsBytes > 0 {
+			if err := UploadLogsToStorage(svc, f.jobID, jr.ProcessID, jr.Tenant); err != nil {
+				log.Warnf("Disk janitor kept local logs of job %s, they could not be uploaded: %s", f.jobID, err.Error())
+				continue
+			}
+		}
+		for _, p := range f.paths {
+			if err := os.RemoveAll(p); err != nil {
+				log.Errorf("Disk janitor could not remove %s: %s", p, err.Error())
+			}
+		}
+		freed += f.logsBytes + f.scratchBytes
+		usage.LogsBytes -= f.logsBytes
+		usage.ScratchBytes -= f.scratchBytes
+		usage.FreeBytes += f.logsBytes + f.scratchBytes
+	}
+	return freed, nil
+}
@@ -1,14 +1,12 @@
 package jobs
 
 import (
+	"app/config"
 	"app/controllers"
-	"app/utils"
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
@@ -29,26 +27,121 @@ type DockerJob struct {
 	UUID           string `json:"jobID"`
 	ContainerID    string
 	Image          string `json:"image"`
+	ImageDigest    string `json:"imageDigest"` // ID of the local image Image resolved to in Create, the container runs this image
+	Platform       string // os/arch[/variant] Image is pulled and run for, the platform of the docker daemon if empty
 	ProcessName    string `json:"processID"`
 	ProcessVersion string `json:"processVersion"`
 	Submitter      string
-	EnvVars        []string
-	Volumes        []string `json:"volumes"`
-	Cmd            []string `json:"commandOverride"`
+	Tenant         string
+	RequestID      string // ID of the API request that created the job
+	RerunOf        string // ID of the job this job re-runs
+	LogLevel       string // level of server logs and LOG_LEVEL of the container, LOG_LEVEL of the server if empty
+	ParentID       string // ID of the pipeline or fan-out job this job is a step of
+	ErrorPatterns  []ErrorPattern
+	OutputTypes    map[string]string // media types declared by outputs of the process, set on objects referenced by results
+	OutputSchemas  ResultsValidator  // validates results before the job succeeds, nil if no output declares a schema
+	StdoutResults  *StdoutResults    // captures results from stdout when the job succeeds, nil reads them from the process logs
+	Callback       *Callback         // results callback of the job, its env variables are set if not nil
+	StorageAccess  *StorageAccess    // scoped storage credentials are issued when the job starts if not nil
+	FailureClass   string
+	ExitCode       *int // nil until the container exited
+	OOMKilled      bool
+	EnvVars        []EnvVar
+	EnvVarsFrom    []EnvVarFrom      // resolved when the job starts
+	EnvOverrides   map[string]string // set by the execute request
+	Volumes        []string          `json:"volumes"`
+	ScratchDir     string            // created by Create, mounted at ScratchMountPath and removed by Close
+	ScratchBytes   int64             // size of ScratchDir when the container exited
+	InputsFile     json.RawMessage   // inputs of processes with inputDelivery file, written by Create and mounted read-only at InputsMountPath
+	inputsPath     string            // path of the written inputs file, as seen by the server
+	Cmd            []string          `json:"commandOverride"`
 	UpdateTime     time.Time
 	Status         string `json:"status"`
 
-	logger  *log.Logger
-	logFile *os.File
+	logger         *log.Logger
+	logFile        *os.File
+	logBroadcaster *LogBroadcaster
+	logsDone       chan struct{} // closed when followContainerLogs wrote all container logs
+	containerDied  chan int      // exit code of the container from its die event, see HandleContainerEvent
+	usage          *usageTracker
 
 	Resources
 	DB           Database
+	Events       *EventBus
 	StorageSvc   *s3.S3
-	DoneChan     chan Job
+	ActiveJobs   *ActiveJobs
 	ResourcePool *ResourcePool
 	IsSync       bool
+	// Time between SIGTERM and SIGKILL when the job is dismissed, 0 kills immediately
+	StopGracePeriod time.Duration
+	// Network, capabilities, root filesystem and user of the container, nil runs it as configured by the image
+	Security *controllers.DockerSecurity `json:"-"`
+	// Entrypoint and working directory of the container, nil keeps those of the image
+	ImageOverrides *controllers.DockerImageOverrides `json:"-"`
+	// Credentials to pull Image with if it is missing, nil pulls anonymously
+	PullAuth controllers.RegistryAuthFunc `json:"-"`
+	// Docker controller shared with the server, a new controller is created per call if nil
+	Docker *controllers.SharedDockerController `json:"-"`
 }
 
+func init() {
+	RegisterBackend("docker", dockerBackend{})
+}
+
+// Runs jobs in containers of the local Docker daemon, async jobs wait for local resources in the queue
+type dockerBackend struct{}
+
+func (dockerBackend) Queued() bool { return true }
+
+func (dockerBackend) NewJob(spec JobSpec, svc JobServices) (Job, error) {
+	return &DockerJob{
+		UUID:            spec.JobID,
+		ProcessName:     spec.ProcessID,
+		ProcessVersion:  spec.ProcessVersion,
+		Image:           spec.Image,
+		Platform:        spec.Platform,
+		PullAuth:        spec.PullAuth,
+		Submitter:       spec.Submitter,
+		Tenant:          spec.Tenant,
+		RequestID:       spec.RequestID,
+		RerunOf:         spec.RerunOf,
+		ParentID:        spec.ParentID,
+		LogLevel:        spec.LogLevel,
+		EnvVars:         spec.EnvVars,
+		EnvVarsFrom:     spec.EnvVarsFrom,
+		EnvOverrides:    spec.EnvOverrides,
+		Volumes:         spec.Volumes,
+		InputsFile:      spec.InputsFile,
+		Resources:       spec.Resources,
+		ErrorPatterns:   spec.ErrorPatterns,
+		OutputTypes:     spec.OutputTypes,
+		OutputSchemas:   spec.OutputSchemas,
+		StdoutResults:   spec.StdoutResults,
+		Callback:        spec.Callback,
+		StorageAccess:   spec.StorageAccess,
+		Security:        spec.Security,
+		ImageOverrides:  spec.ImageOverrides,
+		Cmd:             spec.Cmd,
+		StorageSvc:      svc.StorageSvc,
+		DB:              svc.DB,
+		Events:          svc.Events,
+		ActiveJobs:      svc.ActiveJobs,
+		ResourcePool:    svc.ResourcePool,
+		IsSync:          spec.IsSync,
+		StopGracePeriod: spec.StopGracePeriod,
+		Docker:          svc.Docker,
+	}, nil
+}
+
+// Max time Close waits for the logs of a stopped container to be written
+const logsFlushTimeout = 10 * time.Second
+
+// Times a container log stream that ended with an error is followed again since its last message, and the delay before
+const (
+	logStreamRetries    = 3
+	logStreamRetryDelay = time.Second
+)
+
 func (j *DockerJob) WaitForRunCompletion() {
 	j.wgRun.Wait()
 }
@@ -69,6 +162,14 @@ func (j *DockerJob) SUBMITTER() string {
 	return j.Submitter
 }
 
+func (j *DockerJob) TENANT() string {
+	return j.Tenant
+}
+
+func (j *DockerJob) PARENT() string {
+	return j.ParentID
+}
+
 func (j *DockerJob) CMD() []string {
 	return j.Cmd
 }
@@ -81,44 +182,9 @@ func (j *DockerJob) GetResources() Resources {
 	return j.Resources
 }
 
-// Update container logs
+// Container logs are appended to the log files as they are written, see followContainerLogs
 func (j *DockerJob) UpdateProcessLogs() (err error) {
-	// If old status is one of the terminated status, close has already been called and container logs fetched, container killed
-	switch j.Status {
-	case SUCCESSFUL, DISMISSED, FAILED:
-		return
-	}
-
-	j.logger.Debug("Updating container logss")
-	containerLogs, err := j.fetchContainerLogs()
-	if err != nil {
-		j.logger.Error(err.Error())
-		return
-	}
-
-	if len(containerLogs) == 0 || containerLogs == nil {
-		return
-	}
-
-	// Create a new file or overwrite if it exists
-	file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	for i, line := range containerLogs {
-		if i != len(containerLogs)-1 {
-			_, err = writer.WriteString(line + "\n")
-		} else {
-			_, err = writer.WriteString(line)
-		}
-	}
-
-	return
+	return nil
 }
 
 func (j *DockerJob) LogMessage(m string, level log.Level) {
@@ -162,6 +228,7 @@ func (j *DockerJob) NewStatusUpdate(status string, updateTime time.Time) {
 	}
 	j.DB.updateJobRecord(j.UUID, status, j.UpdateTime)
 	j.logger.Infof("Status changed to %s.", status)
+	publishStatusEvent(j.Events, j, status, j.UpdateTime)
 }
 
 func (j *DockerJob) CurrentStatus() string {
@@ -183,7 +250,12 @@ func (j *DockerJob) Equals(job Job) bool {
 
 func (j *DockerJob) initLogger() error {
 	// Create a place holder file for container logs
-	file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err := os.Create(LocalLogPath(j.UUID, "process"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	file.Close()
+	file, err = os.Create(LocalLogPath(j.UUID, "stderr"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
@@ -192,17 +264,18 @@ func (j *DockerJob) initLogger() error {
 	// Create logger for server logs
 	j.logger = log.New()
 
-	file, err = os.Create(fmt.Sprintf("%s/%s.server.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err = os.Create(LocalLogPath(j.UUID, "server"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
 
-	j.logger.SetOutput(file)
+	j.logger.SetOutput(ServerLogWriter(file))
 	j.logger.SetFormatter(&log.JSONFormatter{})
+	j.logger.AddHook(newJobFieldsHook(j.UUID, j.RequestID))
 
-	lvl, err := log.ParseLevel(os.Getenv("LOG_LEVEL"))
+	lvl, err := log.ParseLevel(jobLogLevel(j.LogLevel))
 	if err != nil {
-		j.logger.Warnf("Invalid LOG_LEVEL set, %s; defaulting to INFO", os.Getenv("LOG_LEVEL"))
+		j.logger.Warnf("Invalid LOG_LEVEL set, %s; defaulting to INFO", jobLogLevel(j.LogLevel))
 		lvl = log.InfoLevel
 	}
 	j.logger.SetLevel(lvl)
@@ -213,7 +286,7 @@ func (j *DockerJob) Create() error {
 	// Only reserve resources for sync jobs at creation time
 	// Async jobs will have resources reserved when QueueWorker starts them
 	if j.IsSync {
-		if !j.ResourcePool.TryReserve(j.Resources.CPUs, j.Resources.Memory) {
+		if !j.ResourcePool.TryReserve(j.Tenant, j.Resources.CPUs, j.Resources.Memory) {
 			return fmt.Errorf("resources unavailable")
 		}
 	}
@@ -222,7 +295,11 @@ func (j *DockerJob) Create() error {
 	success := false
 	defer func() {
 		if !success && j.IsSync {
-			j.ResourcePool.Release(j.Resources.CPUs, j.Resources.Memory)
+			j.ResourcePool.Release(j.Tenant, j.Resources.CPUs, j.Resources.Memory)
+		}
+		if !success {
+			removeScratchDir(j.logger, j.ScratchDir)
+			removeInputsFile(j.logger, j.inputsPath)
 		}
 	}()
 
@@ -232,12 +309,36 @@ func (j *DockerJob) Create() error {
 	}
 	j.logger.Info("Container Commands: ", j.CMD())
 
+	// The tag is resolved once so that a tag pushed while the job is queued does not change what the job runs
+	if err := j.resolveImageDigest(); err != nil {
+		return err
+	}
+
+	// Created here rather than in Run so that Close always sees it, even if the job is dismissed while starting
+	owner := ""
+	if j.Security != nil {
+		owner = j.Security.User
+	}
+	j.ScratchDir, err = createScratchDir(j.UUID, j.Resources.Disk, owner)
+	if err != nil {
+		return err
+	}
+	if j.InputsFile != nil {
+		j.inputsPath, err = writeInputsFile(j.UUID, j.InputsFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.TODO())
 	j.ctx = ctx
 	j.ctxCancel = cancelFunc
+	j.logBroadcaster = NewLogBroadcaster()
+	j.usage = newUsageTracker(j.Resources)
+	j.containerDied = make(chan int, 1)
 
 	// At this point job is ready to be added to database
-	err = j.DB.addJob(j.UUID, "accepted", "", "local", j.ProcessName, j.Submitter, time.Now())
+	err = j.DB.addJob(j.UUID, "accepted", "", "local", j.ProcessName, j.Submitter, j.Tenant, j.RequestID, j.ParentID, time.Now())
 	if err != nil {
 		j.ctxCancel()
 		return err
@@ -253,6 +354,23 @@ func (j *DockerJob) Create() error {
 	return nil
 }
 
+// Resolve Image to the ID of the local image, pulling it if it is missing
+func (j *DockerJob) resolveImageDigest() error {
+	c, err := j.Docker.Get()
+	if err != nil {
+		return err
+	}
+	if err := c.EnsureImage(context.TODO(), j.Image, j.Platform, j.PullAuth, false); err != nil {
+		return fmt.Errorf("could not ensure image %s available: %s", j.Image, err.Error())
+	}
+	j.ImageDigest, err = c.GetImageDigest(j.Image)
+	if err != nil {
+		return fmt.Errorf("could not resolve image %s: %s", j.Image, err.Error())
+	}
+	j.logger.Infof("Image %s resolved to %s", j.Image, j.ImageDigest)
+	return nil
+}
+
 func (j *DockerJob) IsSyncJob() bool {
 	return j.IsSync
 }
@@ -270,47 +388,64 @@ func (j *DockerJob) Run() {
 			j.logger.Errorf("Run() panicked: %v", r)
 			j.NewStatusUpdate(FAILED, time.Time{})
 		}
-		j.ResourcePool.Release(j.Resources.CPUs, j.Resources.Memory)
+		j.ResourcePool.Release(j.Tenant, j.Resources.CPUs, j.Resources.Memory)
 		j.Close()
 		j.wgRun.Done()
 	}()
 
-	c, err := controllers.NewDockerController()
+	c, err := j.Docker.Get()
 	if err != nil {
-		j.logger.Errorf("Failed creating NewDockerController. Error: %s", err.Error())
+		j.logger.Errorf("Could not get docker controller. Error: %s", err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
 
-	err = c.EnsureImage(j.ctx, j.Image, false)
-	if err != nil {
-		j.logger.Infof("Could not ensure image %s available", j.Image)
+	// The image resolved at submission could have been removed since, e.g. by an image prune after its tag moved
+	if _, err := c.GetImageDigest(j.ImageDigest); err != nil {
+		j.logger.Errorf("Image %s resolved from %s at submission is no longer available. Error: %s", j.ImageDigest, j.Image, err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
 
 	// get environment variables
-	envs := make([]string, len(j.EnvVars))
-	for i, k := range j.EnvVars {
-		name := strings.TrimPrefix(k, strings.ToUpper(j.ProcessName)+"_")
-		envs[i] = name + "=" + os.Getenv(k)
+	envs := resolveEnvVars(j.EnvVars)
+	secretEnvs, err := resolveEnvVarsFrom(j.ctx, j.EnvVarsFrom)
+	if err != nil {
+		j.logger.Errorf("Failed to resolve secrets. Error: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+	envs = append(envs, secretEnvs...)
+	if j.LogLevel != "" {
+		envs = append(envs, LogLevelEnvVar+"="+j.LogLevel)
+	}
+	for k, v := range j.EnvOverrides {
+		envs = append(envs, k+"="+v)
+	}
+	envs = append(envs, ScratchEnvVar+"="+ScratchMountPath)
+	envs = append(envs, j.Callback.envVars()...)
+	storageEnvs, err := j.StorageAccess.envVars(j.ctx, j.UUID)
+	if err != nil {
+		j.logger.Errorf("Failed to issue storage credentials. Error: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
 	}
+	envs = append(envs, storageEnvs...)
 	j.logger.Debugf("Registered %v env vars", len(envs))
 
+	volumes := append(append([]string{}, j.Volumes...), scratchHostPath(j.ScratchDir)+":"+ScratchMountPath)
+	if j.inputsPath != "" {
+		volumes = append(volumes, scratchHostPath(j.inputsPath)+":"+InputsMountPath+":ro")
+		envs = append(envs, InputsEnvVar+"="+InputsMountPath)
+	}
+
 	resources := controllers.DockerResources{}
 	resources.NanoCPUs = int64(j.Resources.CPUs * 1e9)         // Docker controller needs cpu in nano ints
 	resources.Memory = int64(j.Resources.Memory * 1024 * 1024) // Docker controller needs memory in bytes
 
-	// although we have already checked if image is available at the time of process init, we are doing it again just to be explicit
-	err = c.EnsureImage(j.ctx, j.Image, false)
-	if err != nil {
-		j.logger.Infof("Could not ensure image %s available", j.Image)
-		j.NewStatusUpdate(FAILED, time.Time{})
-		return
-	}
-
 	// start container
-	containerID, err := c.ContainerRun(j.ctx, j.Image, j.Cmd, j.Volumes, envs, resources)
+	labels := map[string]string{controllers.LabelJobID: j.UUID, controllers.LabelAPIName: config.Get().API.Name}
+	containerID, err := c.ContainerRun(j.ctx, j.ImageDigest, j.Platform, j.Cmd, volumes, envs, resources, j.Security, j.ImageOverrides, labels)
 	if err != nil {
 		j.logger.Errorf("Failed to run container. Error: %s", err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
@@ -319,6 +454,9 @@ func (j *DockerJob) Run() {
 	j.NewStatusUpdate(RUNNING, time.Time{})
 
 	j.ContainerID = containerID
+	j.logsDone = make(chan struct{})
+	go j.followContainerLogs(c)
+	go j.sampleContainerStats(c)
 
 	// Check if job was cancelled (Kill() was called) before waiting for container
 	select {
@@ -328,25 +466,129 @@ func (j *DockerJob) Run() {
 	}
 
 	// wait for process to finish
-	exitCode, err := c.ContainerWait(j.ctx, j.ContainerID)
+	exitCode, err := j.waitForExit(c)
 	if err != nil {
+		// The wait is cancelled when the job is dismissed or its status is updated by the job watchdog
+		if j.CurrentStatus() != RUNNING {
+			return
+		}
 		// to do: check what would happen if container exited because of dismiss signal and hanlde it similar to subprocess_job
 		j.logger.Errorf("Failed waiting for container to finish. Error: %s", err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
 
+	j.recordExit(c, exitCode)
+
 	if exitCode != 0 {
-		j.logger.Errorf("Container failure, exit code: %d", exitCode)
+		j.logger.Errorf("Container failure, exit code: %d, OOM killed: %t", exitCode, j.OOMKilled)
+		j.classifyFailure(c, exitCode)
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
 
 	j.logger.Info("Container process finished successfully.")
+	if !j.CheckResults() {
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
 	j.NewStatusUpdate(SUCCESSFUL, time.Time{})
 	go j.WriteMetaData()
 }
 
+// Wait until the container exited and return its exit code, from ContainerWait or the die event of the container,
+// whichever comes first. If ContainerWait fails, e.g. when the connection to the daemon drops, the exit code is
+// taken from the state of the container if it already exited and from its die event otherwise.
+func (j *DockerJob) waitForExit(c *controllers.DockerController) (int, error) {
+	type waitResult struct {
+		exitCode int64
+		err      error
+	}
+	waited := make(chan waitResult, 1)
+	go func() {
+		exitCode, err := c.ContainerWait(j.ctx, j.ContainerID)
+		waited <- waitResult{exitCode, err}
+	}()
+
+	select {
+	case r := <-waited:
+		if r.err == nil || j.ctx.Err() != nil {
+			return int(r.exitCode), r.err
+		}
+		j.logger.Warnf("Failed waiting for container to finish, waiting for its die event. Error: %s", r.err.Error())
+		if state, err := c.ContainerState(context.TODO(), j.ContainerID); err == nil && state != nil && (state.Status == "exited" || state.Status == "dead") {
+			return state.ExitCode, nil
+		}
+		select {
+		case exitCode := <-j.containerDied:
+			return exitCode, nil
+		case <-j.ctx.Done():
+			return 0, r.err
+		}
+	case exitCode := <-j.containerDied:
+		return exitCode, nil
+	case <-j.ctx.Done():
+		return 0, j.ctx.Err()
+	}
+}
+
+// HandleContainerEvent logs die, oom and kill events of the container of the job, the exit code of a die event
+// ends the wait of Run. Events can be received more than once.
+func (j *DockerJob) HandleContainerEvent(e controllers.ContainerEvent) {
+	switch e.Action {
+	case "oom":
+		j.logger.Warn("Container ran out of memory.")
+	case "kill":
+		j.logger.Infof("Container received signal %s.", e.Signal)
+	case "die":
+		j.logger.Debugf("Container died with exit code %d.", e.ExitCode)
+		select {
+		case j.containerDied <- e.ExitCode:
+		default:
+		}
+	}
+}
+
+// Store exit code and OOM kill flag of the container
+func (j *DockerJob) recordExit(c *controllers.DockerController, exitCode int) {
+	oomKilled, err := c.ContainerOOMKilled(context.TODO(), j.ContainerID)
+	if err != nil {
+		j.logger.Warnf("Could not inspect container. Error: %s", err.Error())
+	}
+	j.ExitCode = &exitCode
+	j.OOMKilled = oomKilled
+	j.ScratchBytes = scratchDirSize(j.logger, j.ScratchDir)
+	if err := j.DB.updateExitDetail(j.UUID, exitCode, oomKilled); err != nil {
+		j.logger.Errorf("Could not store exit code. Error: %s", err.Error())
+	}
+}
+
+// ExitDetail returns exit code of the container, nil if it has not exited, and whether it was OOM killed
+func (j *DockerJob) ExitDetail() (*int, bool) {
+	return j.ExitCode, j.OOMKilled
+}
+
+// Classify failure from exit code, OOM kill flag and tail of container logs, and store it
+func (j *DockerJob) classifyFailure(c *controllers.DockerController, exitCode int) {
+	stdout, stderr, err := c.ContainerLog(context.TODO(), j.ContainerID)
+	if err != nil {
+		j.logger.Warnf("Could not fetch container logs. Error: %s", err.Error())
+	}
+
+	// stderr is checked first since patterns are matched from the last line
+	logTail := append(lastLines(stdout, classifyLogTail), lastLines(stderr, classifyLogTail)...)
+	j.FailureClass = ClassifyFailure(exitCode, j.OOMKilled, logTail, j.ErrorPatterns)
+	j.logger.Infof("Failure classified as: %s", j.FailureClass)
+	if err := j.DB.updateFailureClass(j.UUID, j.FailureClass); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+}
+
+// FailureClassification returns why the job failed, empty if not classified
+func (j *DockerJob) FailureClassification() string {
+	return j.FailureClass
+}
+
 // kill local container
 func (j *DockerJob) Kill() error {
 	j.logger.Info("Received dismiss signal.")
@@ -369,58 +611,127 @@ func (j *DockerJob) Kill() error {
 	return nil
 }
 
-// Write metadata at the job's metadata location
-func (j *DockerJob) WriteMetaData() {
-	j.logger.Info("Starting metadata writing routine.")
-	j.wg.Add(1)
-	defer j.wg.Done()
-	defer j.logger.Info("Finished metadata writing routine.")
+// LastProcessLog returns when the container last wrote a log line
+func (j *DockerJob) LastProcessLog() time.Time {
+	return j.logBroadcaster.LastPublished()
+}
 
-	c, err := controllers.NewDockerController()
+// ReconcileStatus updates the status of a running job whose container exited more than driftGracePeriod ago
+// or no longer exists, Run is stopped waiting for it
+func (j *DockerJob) ReconcileStatus() (string, error) {
+	if j.ContainerID == "" || j.CurrentStatus() != RUNNING {
+		return "", nil
+	}
+	c, err := j.Docker.Get()
 	if err != nil {
-		j.logger.Errorf("Could not create controller. Error: %s", err.Error())
+		return "", err
 	}
-
-	p := process{j.ProcessID(), j.ProcessVersionID()}
-	imageDigest, err := c.GetImageDigest(j.IMAGE()) // what if image is update between start of job and this call?
+	state, err := c.ContainerState(context.TODO(), j.ContainerID)
 	if err != nil {
-		j.logger.Errorf("Error getting Image Digest: %s", err.Error())
-		return
+		return "", err
 	}
 
-	i := image{j.IMAGE(), imageDigest}
+	switch {
+	case state == nil:
+		j.logger.Errorf("Container %s no longer exists.", j.ContainerID)
+		j.setFailureClass(FailureLost)
+		j.NewStatusUpdate(FAILED, time.Time{})
+	case state.Running || state.Restarting || state.Status == "created":
+		return "", nil
+	default:
+		finished, _ := time.Parse(time.RFC3339Nano, state.FinishedAt)
+		if time.Since(finished) < driftGracePeriod {
+			return "", nil
+		}
+		j.logger.Warnf("Container exited at %s with exit code %d while the job was running.", state.FinishedAt, state.ExitCode)
+		j.recordExit(c, state.ExitCode)
+		if state.ExitCode != 0 {
+			j.classifyFailure(c, state.ExitCode)
+			j.NewStatusUpdate(FAILED, time.Time{})
+		} else if !j.CheckResults() {
+			j.NewStatusUpdate(FAILED, time.Time{})
+		} else {
+			j.NewStatusUpdate(SUCCESSFUL, time.Time{})
+			// Written before Close removes the container, metadata includes its times
+			j.WriteMetaData()
+		}
+	}
+	j.ctxCancel()
+	return j.CurrentStatus(), nil
+}
 
-	g, s, e, err := c.GetJobTimes(j.ContainerID)
-	if err != nil {
-		j.logger.Errorf("Error getting job times: %s", err.Error())
-		return
+// Fail kills the container of a stuck job and updates the status of the job to failed
+func (j *DockerJob) Fail(class, reason string) error {
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		return fmt.Errorf("can't fail an already completed, failed, or dismissed job")
 	}
 
-	repoURL := os.Getenv("REPO_URL")
+	j.logger.Errorf("Failing job: %s.", reason)
+	j.setFailureClass(class)
+	j.NewStatusUpdate(FAILED, time.Time{})
+	j.ctxCancel()
 
-	md := metaData{
-		Context:         fmt.Sprintf("%s/blob/main/context.jsonld", repoURL),
-		JobID:           j.UUID,
-		Process:         p,
-		Image:           i,
-		Commands:        j.Cmd,
-		GeneratedAtTime: g,
-		StartedAtTime:   s,
-		EndedAtTime:     e,
+	if j.ContainerID != "" {
+		c, err := j.Docker.Get()
+		if err != nil {
+			j.logger.Errorf("Could not create controller. Error: %s", err.Error())
+		} else if err := c.ContainerKill(context.TODO(), j.ContainerID); err != nil {
+			j.logger.Errorf("Could not kill container. Error: %s", err.Error())
+		}
 	}
+	go j.Close()
+	return nil
+}
+
+func (j *DockerJob) setFailureClass(class string) {
+	j.FailureClass = class
+	if err := j.DB.updateFailureClass(j.UUID, class); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+}
+
+// Write metadata at the job's metadata location and set the content types of objects referenced by its results
+func (j *DockerJob) WriteMetaData() {
+	j.logger.Info("Starting metadata writing routine.")
+	j.wg.Add(1)
+	defer j.wg.Done()
+	defer j.logger.Info("Finished metadata writing routine.")
 
-	jsonBytes, err := json.Marshal(md)
+	c, err := j.Docker.Get()
 	if err != nil {
-		j.logger.Errorf("Error marshalling metadata to JSON bytes: %s", err.Error())
+		j.logger.Errorf("Could not create controller. Error: %s", err.Error())
 		return
 	}
 
-	metadataDir := os.Getenv("STORAGE_METADATA_PREFIX")
-	mdLocation := fmt.Sprintf("%s/%s.json", metadataDir, j.UUID)
-	err = utils.WriteToS3(j.StorageSvc, jsonBytes, mdLocation, "application/json", 0)
+	_, s, e, err := c.GetJobTimes(j.ContainerID)
 	if err != nil {
+		j.logger.Errorf("Error getting job times: %s", err.Error())
 		return
 	}
+
+	usage := j.ResourceUsage()
+	err = writeProvDocument(j.StorageSvc, j.DB, provRecord{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersionID(),
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+		RerunOf:        j.RerunOf,
+		Commands:       j.Cmd,
+		ImageURI:       j.IMAGE(),
+		ImageDigest:    j.ImageDigest,
+		Started:        s,
+		Ended:          e,
+		Usage:          &usage,
+		ScratchBytes:   j.ScratchBytes,
+		Host:           "docker",
+		CPUs:           j.Resources.CPUs,
+	})
+	if err != nil {
+		j.logger.Errorf("Error writing metadata: %s", err.Error())
+	}
+	setOutputContentTypes(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.OutputTypes)
 }
 
 // func (j *DockerJob) WriteResults(data []byte) (err error) {
@@ -437,16 +748,99 @@ func (j *DockerJob) WriteMetaData() {
 // 	return
 // }
 
-func (j *DockerJob) fetchContainerLogs() ([]string, error) {
-	c, err := controllers.NewDockerController()
+// SubscribeProcessLogs returns live container log lines
+func (j *DockerJob) SubscribeProcessLogs() (<-chan string, func()) {
+	return j.logBroadcaster.Subscribe()
+}
+
+// Follow container logs from the start of the container, append stdout to the process log file and stderr
+// to the stderr log file and publish lines to subscribers. Returns when the container stops, closes logsDone.
+func (j *DockerJob) followContainerLogs(c *controllers.DockerController) {
+	defer close(j.logsDone)
+
+	stdoutFile, err := openLogFile(LocalLogPath(j.UUID, "process"))
 	if err != nil {
-		return nil, fmt.Errorf("could not create controller to fetch container logs")
+		j.logger.Errorf("Could not open process logs file. Error: %s", err.Error())
+		return
 	}
-	containerLogs, err := c.ContainerLog(context.TODO(), j.ContainerID)
+	defer j.closeLogFile(stdoutFile)
+	stderrFile, err := openLogFile(LocalLogPath(j.UUID, "stderr"))
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch container logs")
+		j.logger.Errorf("Could not open stderr logs file. Error: %s", err.Error())
+		return
+	}
+	defer j.closeLogFile(stderrFile)
+
+	stdout := &logLineWriter{file: stdoutFile, publish: j.logBroadcaster.Publish}
+	stderr := &logLineWriter{file: stderrFile, publish: j.logBroadcaster.Publish}
+	stdoutTS := &timestampedWriter{w: stdout}
+	stderrTS := &timestampedWriter{w: stderr}
+	// Not cancelled with the job context, so that output written while a dismissed container stops is kept.
+	// A stream that ends with an error, e.g. when the connection to the daemon drops, is followed again since the
+	// older of the last messages of stdout and stderr so that no message is lost, messages already written are dropped.
+	var since time.Time
+	for attempt := 0; ; attempt++ {
+		err := c.ContainerLogStream(context.Background(), j.ContainerID, since, stdoutTS, stderrTS)
+		if err == nil {
+			break
+		}
+		if attempt == logStreamRetries {
+			j.logger.Warnf("Container log stream ended. Error: %s", err.Error())
+			break
+		}
+		since = stdoutTS.last
+		if stderrTS.last.Before(since) {
+			since = stderrTS.last
+		}
+		j.logger.Debugf("Container log stream ended, following it again. Error: %s", err.Error())
+		time.Sleep(logStreamRetryDelay)
+	}
+	for _, w := range []*logLineWriter{stdout, stderr} {
+		if err := w.Flush(); err != nil {
+			j.logger.Errorf("Could not write container logs. Error: %s", err.Error())
+		}
 	}
-	return containerLogs, nil
+}
+
+func (j *DockerJob) closeLogFile(f *os.File) {
+	if err := closeLogFile(f); err != nil {
+		j.logger.Errorf("Could not close logs file. Error: %s", err.Error())
+	}
+}
+
+// Wait until container logs are written, at most logsFlushTimeout
+func (j *DockerJob) waitForContainerLogs() {
+	if j.logsDone == nil {
+		return
+	}
+	select {
+	case <-j.logsDone:
+	case <-time.After(logsFlushTimeout):
+		j.logger.Warnf("Container logs were not written within %s, they may be incomplete", logsFlushTimeout)
+	}
+}
+
+// Sample container stats until the container stops or job is closed
+func (j *DockerJob) sampleContainerStats(c *controllers.DockerController) {
+	samples := make(chan controllers.ContainerStatsSample)
+	go func() {
+		defer close(samples)
+		if err := c.ContainerStatsStream(j.ctx, j.ContainerID, samples); err != nil {
+			j.logger.Warnf("Could not sample container stats. Error: %s", err.Error())
+		}
+	}()
+
+	for s := range samples {
+		j.usage.add(s)
+	}
+}
+
+// ResourceUsage returns resource usage sampled so far
+func (j *DockerJob) ResourceUsage() ResourceUsage {
+	if j.usage == nil {
+		return ResourceUsage{}
+	}
+	return j.usage.snapshot()
 }
 
 func (j *DockerJob) RunFinished() {
@@ -465,38 +859,27 @@ func (j *DockerJob) Close() {
 	j.closeOnce.Do(func() {
 		j.logger.Info("Starting closing routine.")
 		j.ctxCancel() // Signal Run function to terminate if running
+		defer j.logBroadcaster.Close()
 
 		if j.ContainerID != "" { // Container related cleanups if container exists
-			c, err := controllers.NewDockerController()
+			c, err := j.Docker.Get()
 			if err != nil {
 				j.logger.Errorf("Could not create controller. Error: %s", err.Error())
 			} else {
-				containerLogs, err := c.ContainerLog(context.TODO(), j.ContainerID)
-				if err != nil {
-					j.logger.Errorf("Could not fetch container logs. Error: %s", err.Error())
-				}
-
-				file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
-				if err != nil {
-					j.logger.Errorf("Could not create process logs file. Error: %s", err.Error())
-					return
-				}
-
-				writer := bufio.NewWriter(file)
-
-				for i, line := range containerLogs {
-					if i != len(containerLogs)-1 {
-						_, err = writer.WriteString(line + "\n")
-					} else {
-						_, err = writer.WriteString(line)
+				// Let dismissed processes flush partial outputs before the container is force removed
+				if j.StopGracePeriod > 0 && j.CurrentStatus() == DISMISSED {
+					j.logger.Infof("Stopping container with grace period %s.", j.StopGracePeriod)
+					if err := c.ContainerStop(context.TODO(), j.ContainerID, j.StopGracePeriod); err != nil {
+						j.logger.Errorf("Could not stop container. Error: %s", err.Error())
 					}
-					if err != nil {
-						j.logger.Errorf("Could not write log %s to file.", line)
+				} else if j.CurrentStatus() == DISMISSED {
+					if err := c.ContainerKill(context.TODO(), j.ContainerID); err != nil {
+						j.logger.Errorf("Could not kill container. Error: %s", err.Error())
 					}
 				}
 
-				writer.Flush()
-				file.Close()
+				// The log stream ends when the container stopped
+				j.waitForContainerLogs()
 
 				err = c.ContainerRemove(context.TODO(), j.ContainerID)
 				if err != nil {
@@ -504,17 +887,20 @@ func (j *DockerJob) Close() {
 				}
 			}
 		}
-		j.DoneChan <- j // At this point job can be safely removed from active jobs
+		// The container is removed at this point, nothing writes to the scratch directory anymore
+		removeScratchDir(j.logger, j.ScratchDir)
+		removeInputsFile(j.logger, j.inputsPath)
+		j.ActiveJobs.RemoveID(j.UUID) // At this point job can be safely removed from active jobs
 
 		go func() {
 			j.wg.Wait() // wait if other routines like metadata are running
 			j.logFile.Close()
-			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName)
+			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName, j.Tenant)
 			// It is expected that logs will be requested multiple times for a recently finished job
-			// so we are waiting for one hour to before deleting the local copy
+			// so we are waiting for the local logs TTL (default one hour) before deleting the local copy
 			// so that we can avoid repetitive request to storage service.
 			// If the server shutdown, these files would need to be manually deleted
-			time.Sleep(time.Hour)
+			time.Sleep(config.Get().Logging.LocalLogsTTL)
 			DeleteLocalLogs(j.StorageSvc, j.UUID, j.ProcessName)
 		}()
 	})
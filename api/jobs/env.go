@@ -0,0 +1,27 @@
+package jobs
+
+import "os"
+
+// EnvVar is env variable Name of a job, set to Value or to the value of server env variable FromEnv if it is set
+type EnvVar struct {
+	Name    string
+	FromEnv string
+	Value   string
+}
+
+// Value of the env variable when the job starts
+func (ev EnvVar) resolve() string {
+	if ev.FromEnv != "" {
+		return os.Getenv(ev.FromEnv)
+	}
+	return ev.Value
+}
+
+// Resolve env variables to NAME=value pairs
+func resolveEnvVars(vars []EnvVar) []string {
+	envs := make([]string, len(vars))
+	for i, ev := range vars {
+		envs[i] = ev.Name + "=" + ev.resolve()
+	}
+	return envs
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types emitted on the EventBus
+const (
+	EventJobStatusUpdated = "job.status.updated"
+)
+
+// JobEvent describes a job lifecycle event
+type JobEvent struct {
+	Type           string    `json:"type"`
+	JobID          string    `json:"jobID"`
+	ProcessID      string    `json:"processID"`
+	ProcessVersion string    `json:"processVersion"`
+	Submitter      string    `json:"submitter"`
+	Status         string    `json:"status"`
+	Time           time.Time `json:"updated"`
+	// For filtering events by visibility of the job, not sent to subscribers outside the server
+	Tenant   string `json:"-"`
+	ParentID string `json:"-"`
+}
+
+// EventSubscriber receives events published on the EventBus.
+// Subscribers are called synchronously from the routine updating job status,
+// they must not block, long running work such as network calls must be handed off to another routine.
+type EventSubscriber func(JobEvent)
+
+// EventBus fans out job events to all subscribers.
+// A nil *EventBus is valid and drops all events.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []EventSubscriber
+}
+
+// NewEventBus creates a new EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers a subscriber for all future events.
+func (eb *EventBus) Subscribe(s EventSubscriber) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.subscribers = append(eb.subscribers, s)
+}
+
+// Publish sends the event to all subscribers.
+func (eb *EventBus) Publish(e JobEvent) {
+	if eb == nil {
+		return
+	}
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	for _, s := range eb.subscribers {
+		s(e)
+	}
+}
+
+// Helper to publish a status update event for a job
+func publishStatusEvent(eb *EventBus, j Job, status string, updateTime time.Time) {
+	eb.Publish(JobEvent{
+		Type:           EventJobStatusUpdated,
+		JobID:          j.JobID(),
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersionID(),
+		Submitter:      j.SUBMITTER(),
+		Status:         status,
+		Time:           updateTime,
+		Tenant:         j.TENANT(),
+		ParentID:       j.PARENT(),
+	})
+}
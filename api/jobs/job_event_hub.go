@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// Event type of progress updates, these are only sent to subscribers of a job's event stream, not on the EventBus,
+// so that webhooks and brokers only receive status changes
+const EventJobProgressUpdated = "job.progress.updated"
+
+// JobProgress is a progress update reported by a process through the status route or its results callback
+type JobProgress struct {
+	Type     string    `json:"type"`
+	JobID    string    `json:"jobID"`
+	Progress *int      `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"updated"`
+}
+
+// JobEventHub fans out status and progress events of single jobs to their subscribers, e.g. clients of the job events endpoint.
+// It receives status events from the EventBus, see Handle. Publishing never blocks, a subscriber that is not keeping up misses events.
+type JobEventHub struct {
+	mu   sync.Mutex
+	jobs map[string]*jobSubscribers
+	// subscribers of status events of all jobs, e.g. the live jobs list
+	all map[chan interface{}]struct{}
+}
+
+type jobSubscribers struct {
+	subscribers map[chan interface{}]struct{}
+	// last progress of the job, sent to new subscribers
+	progress *JobProgress
+}
+
+// NewJobEventHub creates a new JobEventHub.
+func NewJobEventHub() *JobEventHub {
+	return &JobEventHub{jobs: make(map[string]*jobSubscribers), all: make(map[chan interface{}]struct{})}
+}
+
+// SubscribeAll returns a channel that receives the JobEvent of every status change of any job published after the call,
+// and a function to unsubscribe. The channel is only closed when the subscriber unsubscribes.
+func (h *JobEventHub) SubscribeAll() (<-chan interface{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan interface{}, subscriberBufferSize)
+	h.all[ch] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.all[ch]; ok {
+			delete(h.all, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Subscribe returns a channel that receives JobEvent and JobProgress values of job jobID published after the call,
+// starting with the last progress of the job if there is one, and a function to unsubscribe.
+// The channel is closed after the event of a terminal status or when the subscriber unsubscribes.
+func (h *JobEventHub) Subscribe(jobID string) (<-chan interface{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	js, ok := h.jobs[jobID]
+	if !ok {
+		js = &jobSubscribers{subscribers: make(map[chan interface{}]struct{})}
+		h.jobs[jobID] = js
+	}
+	ch := make(chan interface{}, subscriberBufferSize)
+	if js.progress != nil {
+		ch <- *js.progress
+	}
+	js.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		js, ok := h.jobs[jobID]
+		if !ok {
+			return
+		}
+		if _, ok := js.subscribers[ch]; ok {
+			delete(js.subscribers, ch)
+			close(ch)
+		}
+		if len(js.subscribers) == 0 && js.progress == nil {
+			delete(h.jobs, jobID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Handle is an EventSubscriber, it sends status events to the subscribers of all jobs and of the job,
+// and closes the channels of the subscribers of the job once it reached a terminal status.
+func (h *JobEventHub) Handle(e JobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.all {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+
+	js, ok := h.jobs[e.JobID]
+	if !ok {
+		return
+	}
+	js.publish(e)
+
+	switch e.Status {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		for ch := range js.subscribers {
+			close(ch)
+		}
+		delete(h.jobs, e.JobID)
+	}
+}
+
+// PublishProgress sends a progress update to the subscribers of the job, the update is kept for later subscribers
+// until the job finished.
+func (h *JobEventHub) PublishProgress(p JobProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p.Type = EventJobProgressUpdated
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	js, ok := h.jobs[p.JobID]
+	if !ok {
+		js = &jobSubscribers{subscribers: make(map[chan interface{}]struct{})}
+		h.jobs[p.JobID] = js
+	}
+	js.progress = &p
+	js.publish(p)
+}
+
+// LastProgress returns the last progress update of a job that has not finished, false if it reported none
+func (h *JobEventHub) LastProgress(jobID string) (JobProgress, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	js, ok := h.jobs[jobID]
+	if !ok || js.progress == nil {
+		return JobProgress{}, false
+	}
+	return *js.progress, true
+}
+
+// publish assumes the lock of the hub is held by the caller.
+func (js *jobSubscribers) publish(e interface{}) {
+	for ch := range js.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// slow subscriber, drop the event rather than blocking the status routine
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// JobRequest is the execute request and resulting parameters a job was submitted with, stored so that the job can be inspected and re-run.
+// Env overrides and notification settings are not stored since they may contain secrets.
+type JobRequest struct {
+	JobID          string          `json:"jobID"`
+	ProcessID      string          `json:"processID"`
+	ProcessVersion string          `json:"processVersion"`
+	Inputs         json.RawMessage `json:"inputs"`
+	Command        []string        `json:"command"`
+	RerunOf        string          `json:"rerunOf,omitempty"` // ID of the job this job re-runs
+	// Outputs selection and response type of the execute request, see OGC API - Processes execute schema
+	Outputs  json.RawMessage `json:"outputs,omitempty"`
+	Response string          `json:"response,omitempty"`
+	Mode     string          `json:"mode"` // sync-execute or async-execute, as determined from process and Prefer header
+	// Hash of process ID, version, inputs and env overrides, identical requests have the same hash, see InputHash
+	InputHash string `json:"inputHash,omitempty"`
+	// CPUs and memory (MB) of the job, as requested or defaulted by the process. 0 for jobs submitted before resources could be requested
+	CPUs   float32 `json:"cpus,omitempty"`
+	Memory int     `json:"memory,omitempty"`
+	// ID of the array input the request fanned out over, one job per element, see FanOut of execute requests
+	FanOut string `json:"fanOut,omitempty"`
+	// Log level of the job set by the execute request, empty if the server's LOG_LEVEL was used
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
+// InputHash identifies identical execute requests of a process version.
+// Inputs must be marshalled by encoding/json so that keys are sorted. Env overrides are hashed since they can change results but are not stored.
+// Requests fanning out over an input are not identical to requests running a single job with the same inputs.
+func InputHash(processID, version string, inputs json.RawMessage, env map[string]string, fanOut string) string {
+	h := sha256.New()
+	for _, s := range []string{processID, version, string(inputs)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	if len(env) > 0 {
+		envJSON, _ := json.Marshal(env) // map keys are sorted
+		h.Write(envJSON)
+	}
+	if fanOut != "" {
+		h.Write([]byte{0})
+		h.Write([]byte("fanOut:" + fanOut))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Column value of an optional JSON field
+func rawOrEmpty(r json.RawMessage) string {
+	if len(r) == 0 {
+		return ""
+	}
+	return string(r)
+}
+
+// JSON field of an optional column value
+func emptyOrRaw(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
@@ -1,21 +1,23 @@
 package jobs
 
 import (
+	"app/config"
 	"app/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/labstack/gommon/log"
 	"github.com/sirupsen/logrus"
 )
 
 type Resources struct {
 	CPUs   float32
 	Memory int
+	Disk   int
 }
 
 // Job refers to any process that has been created through
@@ -29,6 +31,10 @@ type Job interface {
 	ProcessID() string
 	ProcessVersionID() string
 	SUBMITTER() string
+	// TENANT returns the tenant the job belongs to, empty if tenants are not used
+	TENANT() string
+	// PARENT returns the ID of the job this job was submitted by as a step or element, empty for jobs submitted by users
+	PARENT() string
 
 	// UpdateProcessLogs must provide most upto date process logs
 	// for containerized processes, first fetch the current container logs
@@ -77,6 +83,14 @@ type Job interface {
 	IsSyncJob() bool
 }
 
+// LogStreamer is implemented by jobs that can stream process logs in real time.
+// Not part of Job interface since not all hosts can provide live logs.
+type LogStreamer interface {
+	// SubscribeProcessLogs returns a channel of process log lines produced after subscription
+	// and a function to unsubscribe. Channel is closed when the job is closed.
+	SubscribeProcessLogs() (<-chan string, func())
+}
+
 // JobRecord contains details about a job
 type JobRecord struct {
 	JobID      string    `json:"jobID"`
@@ -87,6 +101,22 @@ type JobRecord struct {
 	Host       string    `json:"host,omitempty"`
 	Mode       string    `json:"mode,omitempty"`
 	Submitter  string    `json:"submitter"`
+	Tenant     string    `json:"tenant,omitempty"`
+	RequestID  string    `json:"requestID,omitempty"`
+	// ID of the pipeline or fan-out job the job is a step or element of
+	ParentID string `json:"parentID,omitempty"`
+	// Number of jobs the job submitted, set in job lists
+	Children int `json:"children,omitempty"`
+	// Why the job failed, see ClassifyFailure
+	FailureClass string `json:"failureClass,omitempty"`
+	// Exit code of the container or subprocess, nil until it exited and for jobs of other hosts
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Container was killed because it exceeded its memory limit
+	OOMKilled bool `json:"oomKilled,omitempty"`
+	// When the job was archived, nil if it is not archived. Archived jobs are hidden from job lists and their artifacts are under the archive prefix
+	Archived *time.Time `json:"archived,omitempty"`
+	// Attempts of aws-batch jobs, set by GetJob
+	Attempts []Attempt `json:"attempts,omitempty"`
 }
 
 type LogEntry struct {
@@ -99,28 +129,67 @@ type LogEntry struct {
 func DecodeLogStrings(s []string) []LogEntry {
 	logs := make([]LogEntry, 0)
 	for _, s := range s {
-		if s == "" {
-			continue
-		}
-		var log LogEntry
-		err := json.Unmarshal([]byte(s), &log)
-		if err != nil || (log.Msg == "" && s != "") { // incase log is not valid JSON or log is valid but does not have msg field or have other fields
-			log = LogEntry{Msg: s}
-		}
-		if log.Msg != "" {
+		if log, ok := decodeLogLine(s); ok {
 			logs = append(logs, log)
 		}
 	}
 	return logs
 }
 
+// Decode a log line, lines that are not JSON entries become the message of an entry. Returns false for empty entries.
+func decodeLogLine(s string) (LogEntry, bool) {
+	if s == "" {
+		return LogEntry{}, false
+	}
+	var log LogEntry
+	err := json.Unmarshal([]byte(s), &log)
+	if err != nil || (log.Msg == "" && s != "") { // incase log is not valid JSON or log is valid but does not have msg field or have other fields
+		log = LogEntry{Msg: s}
+	}
+	return log, log.Msg != ""
+}
+
 // JobLogs describes logs for the job
 type JobLogs struct {
 	JobID       string     `json:"jobID"`
 	ProcessID   string     `json:"processID"`
 	Status      string     `json:"status"`
 	ProcessLogs []LogEntry `json:"process_logs"`
-	ServerLogs  []LogEntry `json:"server_logs"`
+	// stderr of docker and subprocess jobs, process_logs has their stdout
+	StderrLogs []LogEntry `json:"stderr_logs"`
+	ServerLogs []LogEntry `json:"server_logs"`
+	// Number of log entries matching the query before pagination, only set when a query is applied
+	ProcessLogsTotal int `json:"process_logs_total,omitempty"`
+	StderrLogsTotal  int `json:"stderr_logs_total,omitempty"`
+	ServerLogsTotal  int `json:"server_logs_total,omitempty"`
+}
+
+// LogQuery describes filters and pagination for JobLogs.
+// Pagination is applied to each source independently.
+type LogQuery struct {
+	Source string   // "process" (stdout and stderr), "server" or "" for all
+	Levels []string // lower case level names, empty means all levels
+	Offset int
+	Limit  int // 0 means no limit
+	// Keep only the last Tail matching entries instead of paginating with Offset and Limit, 0 means no tail
+	Tail int
+}
+
+// Valid values for LogQuery.Source
+var LogSources = []string{"", "process", "server"}
+
+// NormalizeLogLevel lower cases a level name and maps aliases to logrus names.
+// Returns false if level is not valid.
+func NormalizeLogLevel(level string) (string, bool) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "warn" {
+		level = "warning"
+	}
+	switch level {
+	case "trace", "debug", "info", "warning", "error", "fatal", "panic":
+		return level, true
+	}
+	return "", false
 }
 
 // Prettify JobLogs by replacing nil with empty []LogEntry{}
@@ -128,6 +197,9 @@ func (jl *JobLogs) Prettify() {
 	if jl.ProcessLogs == nil {
 		jl.ProcessLogs = []LogEntry{}
 	}
+	if jl.StderrLogs == nil {
+		jl.StderrLogs = []LogEntry{}
+	}
 	if jl.ServerLogs == nil {
 		jl.ServerLogs = []LogEntry{}
 	}
@@ -142,11 +214,16 @@ const (
 	DISMISSED  string = "dismissed"
 )
 
-// FetchResults by parsing logs
+// FetchResults by parsing logs, or the stored results of jobs whose results were captured from stdout
 // Assumes last log will be results always
-func FetchResults(svc *s3.S3, jid string) (interface{}, error) {
+func FetchResults(svc *s3.S3, jid, tenant string) (interface{}, error) {
+	// Results captured from stdout when the job succeeded, or pushed with the callback of the job, are stored
+	key := ResultsStorageKey(jid, tenant)
+	if exists, err := utils.KeyExists(key, svc); err == nil && exists {
+		return utils.GetS3JsonData(key, svc)
+	}
 
-	logs, err := FetchLogs(svc, jid, true)
+	logs, err := FetchLogs(svc, jid, tenant, true)
 	if err != nil {
 		return nil, err
 	}
@@ -158,23 +235,46 @@ func FetchResults(svc *s3.S3, jid string) (interface{}, error) {
 	}
 
 	lastLog := processLogs[lastLogIdx]
-	lastLogMsg := lastLog.Msg
-	lastLogMsg = strings.ReplaceAll(lastLogMsg, "'", "\"")
-
-	var data map[string]interface{}
-	err = json.Unmarshal([]byte(lastLogMsg), &data)
+	pluginResults, err := parsePluginResults(lastLog.Msg)
 	if err != nil {
 		return nil, fmt.Errorf(`unable to parse results, expected {"plugin_results": {....}}, found : %s. Error: %s`, lastLog, err.Error())
 	}
-
-	pluginResults, ok := data["plugin_results"]
-	if !ok {
+	if pluginResults == nil {
 		return nil, fmt.Errorf("'plugin_results' key not found")
 	}
 
 	return pluginResults, nil
 }
 
+// FetchPartialResults returns the most recent results reported by a failed or dismissed job.
+// Processes can report results of completed steps at any point by logging {"plugin_results": {....}},
+// results are partial because the job did not finish. Returns "not found" error if no results were reported.
+func FetchPartialResults(svc *s3.S3, jid, tenant string) (interface{}, error) {
+	logs, err := FetchLogs(svc, jid, tenant, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(logs.ProcessLogs) - 1; i >= 0; i-- {
+		if pluginResults, err := parsePluginResults(logs.ProcessLogs[i].Msg); err == nil && pluginResults != nil {
+			return pluginResults, nil
+		}
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// Value of plugin_results key of a process log message, nil if message is JSON without the key
+func parsePluginResults(msg string) (interface{}, error) {
+	msg = strings.ReplaceAll(msg, "'", "\"")
+
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(msg), &data)
+	if err != nil {
+		return nil, err
+	}
+	return data["plugin_results"], nil
+}
+
 // // If JobID exists but results file doesn't then it raises an error
 // // Assumes jobID is valid
 // func FetchResults(svc *s3.S3, jid string) (interface{}, error) {
@@ -197,10 +297,15 @@ func FetchResults(svc *s3.S3, jid string) (interface{}, error) {
 // 	return data, nil
 // }
 
+// MetadataStorageKey returns the storage key of the metadata file of a job
+func MetadataStorageKey(jid, tenant string) string {
+	return StorageKey(config.Get().Storage.MetadataPrefix, tenant, jid+".json")
+}
+
 // If JobID exists but metadata file doesn't then it raises an error
 // Assumes jobID is valid
-func FetchMeta(svc *s3.S3, jid string) (interface{}, error) {
-	key := fmt.Sprintf("%s/%s.json", os.Getenv("STORAGE_METADATA_PREFIX"), jid)
+func FetchMeta(svc *s3.S3, jid, tenant string) (interface{}, error) {
+	key := MetadataStorageKey(jid, tenant)
 
 	exist, err := utils.KeyExists(key, svc)
 	if err != nil {
@@ -221,100 +326,165 @@ func FetchMeta(svc *s3.S3, jid string) (interface{}, error) {
 
 // Check for logs in local disk and storage svc
 // Assumes jobID is valid, if log file doesn't exist then it raises an error
-func FetchLogs(svc *s3.S3, jid string, onlyContainer bool) (JobLogs, error) {
+func FetchLogs(svc *s3.S3, jid, tenant string, onlyContainer bool) (JobLogs, error) {
+	return fetchLogs(svc, jid, tenant, onlyContainer, nil)
+}
+
+// FetchLogsQuery is FetchLogs with the filters and pagination of q applied while the files are read,
+// so that only the entries of the requested page are kept in memory.
+func FetchLogsQuery(svc *s3.S3, jid, tenant string, q LogQuery) (JobLogs, error) {
+	return fetchLogs(svc, jid, tenant, q.Source == "process", &q)
+}
+
+// logPage collects the entries of a log source matching q and keeps the ones of the page of q, nil q keeps all entries
+type logPage struct {
+	q       *LogQuery
+	entries []LogEntry
+	total   int
+	// Position of the oldest entry once a tail page is full, entries are a ring buffer then
+	next int
+}
+
+func (p *logPage) add(l LogEntry) {
+	if p.q == nil {
+		p.entries = append(p.entries, l)
+		return
+	}
+	if len(p.q.Levels) > 0 {
+		lvl, _ := NormalizeLogLevel(l.Level)
+		if !utils.StringInSlice(lvl, p.q.Levels) {
+			return
+		}
+	}
+	p.total++
+	if p.q.Tail > 0 {
+		if len(p.entries) < p.q.Tail {
+			p.entries = append(p.entries, l)
+		} else {
+			p.entries[p.next] = l
+			p.next = (p.next + 1) % p.q.Tail
+		}
+		return
+	}
+	if p.total > p.q.Offset && (p.q.Limit == 0 || p.total <= p.q.Offset+p.q.Limit) {
+		p.entries = append(p.entries, l)
+	}
+}
+
+// Entries of the page in log order
+func (p *logPage) page() []LogEntry {
+	if p.next == 0 {
+		return p.entries
+	}
+	return append(append(make([]LogEntry, 0, len(p.entries)), p.entries[p.next:]...), p.entries[:p.next]...)
+}
+
+func fetchLogs(svc *s3.S3, jid, tenant string, onlyContainer bool, q *LogQuery) (JobLogs, error) {
 	var result JobLogs
 	result.JobID = jid
-	localDir := os.Getenv("TMP_JOB_LOGS_DIR") // Local directory where logs are stored
 
 	keys := []struct {
 		key    string
 		target *[]LogEntry
+		total  *int
+		// Jobs of hosts that do not separate stderr and jobs run before it was separated have no stderr logs
+		optional bool
 	}{
 		{
 			"process",
 			&result.ProcessLogs,
+			&result.ProcessLogsTotal,
+			false,
+		},
+		{
+			"stderr",
+			&result.StderrLogs,
+			&result.StderrLogsTotal,
+			true,
 		},
 		{
 			"server",
 			&result.ServerLogs,
+			&result.ServerLogsTotal,
+			false,
 		},
 	}
 
 	for _, k := range keys {
-		// First, check locally
-
 		if k.key == "server" && onlyContainer {
 			continue
 		}
-
-		localPath := fmt.Sprintf("%s/%s.%s.jsonl", localDir, jid, k.key)
-		if localContent, err := os.ReadFile(localPath); err == nil {
-			logStrings := strings.Split(string(localContent), "\n")
-			structuredLogs := DecodeLogStrings(logStrings)
-			*k.target = structuredLogs
+		if q != nil && q.Source == "server" && k.key != "server" {
 			continue
 		}
 
-		// If not found locally, check storage
-		storageKey := fmt.Sprintf("%s/%s.%s.jsonl", os.Getenv("STORAGE_LOGS_PREFIX"), jid, k.key)
-		exists, err := utils.KeyExists(storageKey, svc)
+		r, err := OpenLogFile(svc, jid, tenant, k.key)
+		if errors.Is(err, ErrLogFileNotFound) {
+			if k.optional {
+				continue
+			}
+			return JobLogs{}, fmt.Errorf("%s log file not found on storage", k.key)
+		}
 		if err != nil {
 			return JobLogs{}, err
 		}
-		if !exists {
-			return JobLogs{}, fmt.Errorf("%s log file not found on storage", k.key)
-		}
-		logs, err := utils.GetS3LinesData(storageKey, svc)
+
+		page := logPage{q: q}
+		err = readLogLines(r, func(line string) {
+			if l, ok := decodeLogLine(line); ok {
+				page.add(l)
+			}
+		})
+		r.Close()
 		if err != nil {
-			return JobLogs{}, fmt.Errorf("failed to read %s logs from storage: %v", k.key, err)
+			return JobLogs{}, fmt.Errorf("failed to read %s logs: %v", k.key, err)
 		}
-		structuredLogs := DecodeLogStrings(logs)
-		*k.target = structuredLogs
+		*k.target = page.page()
+		*k.total = page.total
 	}
 
 	result.Prettify()
 	return result, nil
 }
 
-// Upload log files from local disk to storage service
-func UploadLogsToStorage(svc *s3.S3, jid, pid string) {
-
-	localDir := os.Getenv("TMP_JOB_LOGS_DIR") // Local directory where logs are stored
-
+// Upload log files from local disk to storage service, returns the last error of a log file that exists
+func UploadLogsToStorage(svc *s3.S3, jid, pid, tenant string) (uploadErr error) {
 	keys := []string{
 		"process",
+		"stderr",
 		"server",
 	}
 
 	for _, k := range keys {
-		localPath := fmt.Sprintf("%s/%s.%s.jsonl", localDir, jid, k)
-		bytes, err := os.ReadFile(localPath)
-		if err != nil {
-			log.Error(err.Error())
-		}
-
-		storageKey := fmt.Sprintf("%s/%s.%s.jsonl", os.Getenv("STORAGE_LOGS_PREFIX"), jid, k)
-		err = utils.WriteToS3(svc, bytes, storageKey, "text/plain", 0)
+		localPath := LocalLogPath(jid, k)
+		storageKey := StorageKey(config.Get().Storage.LogsPrefix, tenant, fmt.Sprintf("%s.%s.jsonl", jid, k))
+		err := utils.WriteFileToS3(svc, localPath, storageKey, "text/plain")
 		if err != nil {
-			log.Error(err.Error())
+			if k == "stderr" && os.IsNotExist(err) {
+				continue // stderr is only separated for docker and subprocess jobs
+			}
+			logrus.Error(err.Error())
+			if !os.IsNotExist(err) {
+				uploadErr = err
+			}
 		}
 	}
+	return uploadErr
 }
 
 func DeleteLocalLogs(svc *s3.S3, jid, pid string) {
-	localDir := os.Getenv("TMP_JOB_LOGS_DIR") // Local directory where logs are stored
-
 	// List of log types
 	keys := []string{
 		"process",
+		"stderr",
 		"server",
 	}
 
 	for _, k := range keys {
-		localPath := fmt.Sprintf("%s/%s.%s.jsonl", localDir, jid, k)
+		localPath := LocalLogPath(jid, k)
 		err := os.Remove(localPath)
-		if err != nil {
-			log.Error(fmt.Sprintf("Failed to delete local file %s: %v", localPath, err))
+		if err != nil && !(k == "stderr" && os.IsNotExist(err)) {
+			logrus.Errorf("Failed to delete local file %s: %v", localPath, err)
 		}
 	}
 }
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// LogBroadcaster fans out process log lines of a running job to all
+// subscribers, e.g. clients of the logs stream endpoint.
+//
+// It implements io.Writer so that it can be used as a tee target for
+// subprocess stdout/stderr. Written bytes are split into lines, partial
+// lines are buffered until a newline is received or the broadcaster is closed.
+//
+// Publishing never blocks, a subscriber that is not keeping up misses lines.
+type LogBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan string]struct{}
+	partial     []byte
+	closed      bool
+	// When the last line was published
+	last time.Time
+}
+
+// Number of lines buffered per subscriber before lines are dropped for that subscriber.
+const subscriberBufferSize = 256
+
+// NewLogBroadcaster creates a new LogBroadcaster.
+func NewLogBroadcaster() *LogBroadcaster {
+	return &LogBroadcaster{
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives every line published after the call,
+// and a function to unsubscribe. The channel is closed when the broadcaster is closed
+// or the subscriber unsubscribes. Subscribing to a closed broadcaster returns a closed channel.
+func (b *LogBroadcaster) Subscribe() (<-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan string, subscriberBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends a single line to all subscribers.
+func (b *LogBroadcaster) Publish(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.publish(line)
+}
+
+// publish assumes lock is held by the caller.
+func (b *LogBroadcaster) publish(line string) {
+	if b.closed {
+		return
+	}
+	b.last = time.Now()
+	for ch := range b.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// slow subscriber, drop the line rather than blocking the job
+		}
+	}
+}
+
+// Write splits p into lines and publishes each complete line.
+// It always reports len(p) bytes written so that it never fails a tee.
+func (b *LogBroadcaster) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.publish(string(bytes.TrimRight(data[:i], "\r")))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Close flushes any buffered partial line and closes all subscriber channels.
+// Calling Close multiple times is safe.
+func (b *LogBroadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if len(b.partial) > 0 {
+		b.publish(string(b.partial))
+		b.partial = nil
+	}
+	b.closed = true
+	for ch := range b.subscribers {
+		close(ch)
+		delete(b.subscribers, ch)
+	}
+}
+
+// LastPublished returns when the last line was published, zero if no line was published
+func (b *LogBroadcaster) LastPublished() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
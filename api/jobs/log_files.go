@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Log files of a job, stderr only exists for docker and subprocess jobs
+var LogFiles = []string{"process", "stderr", "server"}
+
+// ErrLogFileNotFound is returned when a log file is neither on local disk nor in storage
+var ErrLogFileNotFound = errors.New("log file not found")
+
+// LocalLogPath returns the path of log file name (process, stderr or server) of a job on local disk
+func LocalLogPath(jid, name string) string {
+	return filepath.Join(config.Get().Logging.JobLogsDir, fmt.Sprintf("%s.%s.jsonl", jid, name))
+}
+
+// LogStorageKey returns the storage key of log file name of a job
+func LogStorageKey(jid, tenant, name string) string {
+	return StorageKey(config.Get().Storage.LogsPrefix, tenant, fmt.Sprintf("%s.%s.jsonl", jid, name))
+}
+
+// OpenLogFile opens log file name of a job from local disk, or from storage if it is not on disk anymore.
+// The file is streamed, callers must close it.
+func OpenLogFile(svc *s3.S3, jid, tenant, name string) (io.ReadCloser, error) {
+	f, err := os.Open(LocalLogPath(jid, name))
+	if err == nil {
+		return f, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := LogStorageKey(jid, tenant, name)
+	exists, err := utils.KeyExists(key, svc)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrLogFileNotFound
+	}
+	out, err := utils.GetS3Object(key, "", svc)
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Call fn for every line of r without the newline. Lines are not limited in length.
+func readLogLines(r io.Reader, fn func(string)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] == '\n' {
+				line = line[:len(line)-1]
+			}
+			fn(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"app/config"
+)
+
+// Env variable with the log level of the job, only set for jobs whose execute request set a log level
+const LogLevelEnvVar = "LOG_LEVEL"
+
+// Log levels execute requests can set, see ValidLogLevel
+var jobLogLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// ValidLogLevel returns true if level can be set as log level of a job
+func ValidLogLevel(level string) bool {
+	for _, l := range jobLogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Level of the server logs of a job, the level of the execute request or LOG_LEVEL of the server
+func jobLogLevel(level string) string {
+	if level != "" {
+		return level
+	}
+	return config.Get().Logging.Level
+}
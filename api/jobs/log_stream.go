@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"app/config"
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// Policies of JOB_LOGS_FSYNC: never leaves flushing log files to disk to the OS, close syncs them when they are closed
+// and always syncs them after every write
+const (
+	FsyncNever  = "never"
+	FsyncClose  = "close"
+	FsyncAlways = "always"
+)
+
+// logLineWriter appends what is written to it to a log file and publishes complete lines.
+// Writes are appended as they come, so that the file is never rewritten while a job runs.
+// A line without newline is kept until its end is written or Flush is called.
+type logLineWriter struct {
+	file    *os.File
+	publish func(string)
+	partial []byte
+}
+
+// Open path for appending, the file is created if it does not exist
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// Close a log file opened with openLogFile, it is synced first unless JOB_LOGS_FSYNC is never
+func closeLogFile(f *os.File) error {
+	if config.Get().Logging.JobLogsFsync != FsyncNever {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Sync f after a write if JOB_LOGS_FSYNC is always
+func syncLogWrite(f *os.File) error {
+	if config.Get().Logging.JobLogsFsync == FsyncAlways {
+		return f.Sync()
+	}
+	return nil
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	data := append(w.partial, p...)
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		w.partial = data
+		return len(p), nil
+	}
+	if _, err := w.file.Write(data[:end+1]); err != nil {
+		return 0, err
+	}
+	if err := syncLogWrite(w.file); err != nil {
+		return 0, err
+	}
+	for _, line := range bytes.Split(data[:end], []byte("\n")) {
+		w.publish(string(line))
+	}
+	w.partial = append([]byte{}, data[end+1:]...)
+	return len(p), nil
+}
+
+// Flush writes and publishes the last line if it has no newline
+func (w *logLineWriter) Flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	line := w.partial
+	w.partial = nil
+	w.publish(string(line))
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return syncLogWrite(w.file)
+}
+
+// timestampedWriter strips the timestamp docker prefixes log messages with and writes them to w. It remembers the
+// timestamp of the last message written and drops messages that are not newer, so that a log stream followed again
+// since that timestamp only appends new messages. Each write must be one message.
+type timestampedWriter struct {
+	w    io.Writer
+	last time.Time
+}
+
+func (t *timestampedWriter) Write(p []byte) (int, error) {
+	prefix, msg, found := bytes.Cut(p, []byte(" "))
+	ts, err := time.Parse(time.RFC3339Nano, string(prefix))
+	if !found || err != nil {
+		msg = p
+	} else if !ts.After(t.last) {
+		return len(p), nil
+	} else {
+		t.last = ts
+	}
+	if _, err := t.w.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"app/config"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Add job_id and request_id fields to every entry of a job's server logs so that
+// entries can be correlated with the API call that created the job once logs are aggregated.
+type jobFieldsHook struct {
+	fields log.Fields
+}
+
+func newJobFieldsHook(jobID, requestID string) *jobFieldsHook {
+	fields := log.Fields{"job_id": jobID}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	return &jobFieldsHook{fields: fields}
+}
+
+func (h *jobFieldsHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *jobFieldsHook) Fire(e *log.Entry) error {
+	for k, v := range h.fields {
+		if _, exist := e.Data[k]; !exist {
+			e.Data[k] = v
+		}
+	}
+	return nil
+}
+
+// ServerLogWriter returns w, teed to stdout when LOG_STDOUT is set to true
+// so that all server logs can be collected by log aggregators.
+func ServerLogWriter(w io.Writer) io.Writer {
+	if config.Get().Logging.Stdout {
+		return io.MultiWriter(w, os.Stdout)
+	}
+	return w
+}
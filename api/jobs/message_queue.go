@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"hash/fnv"
 	"time"
 )
 
@@ -8,6 +9,9 @@ type StatusMessage struct {
 	Job        *Job
 	Status     string    `json:"status"`
 	LastUpdate time.Time `json:"updated"`
+	// Optional progress of the process in percent and a message describing it, sent to subscribers of the job's events
+	Progress *int   `json:"progress,omitempty"`
+	Message  string `json:"message,omitempty"`
 }
 
 type ResultsMessage struct {
@@ -15,9 +19,32 @@ type ResultsMessage struct {
 	Results interface{} `json:"outputs"`
 }
 
+// Number of status messages each shard of a MessageQueue buffers before senders block
+const statusShardSize = 500
+
+// MessageQueue shards status messages by job ID, each shard is processed by one worker so that updates of a job
+// are processed in the order they were received while updates of different jobs are processed in parallel.
 type MessageQueue struct {
-	StatusChan chan StatusMessage
-	JobDone    chan Job
+	StatusChans []chan StatusMessage
+}
+
+// NewMessageQueue creates a queue with one shard per worker, at least one
+func NewMessageQueue(workers int) *MessageQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	mq := &MessageQueue{StatusChans: make([]chan StatusMessage, workers)}
+	for i := range mq.StatusChans {
+		mq.StatusChans[i] = make(chan StatusMessage, statusShardSize)
+	}
+	return mq
+}
+
+// SendStatus queues sm on the shard of its job
+func (mq *MessageQueue) SendStatus(sm StatusMessage) {
+	h := fnv.New32a()
+	h.Write([]byte((*sm.Job).JobID()))
+	mq.StatusChans[h.Sum32()%uint32(len(mq.StatusChans))] <- sm
 }
 
 // Job should not be a docker job
@@ -30,6 +57,13 @@ func ProcessStatusMessageUpdate(sm StatusMessage) {
 	case SUCCESSFUL, DISMISSED, FAILED:
 		return
 	}
+	// Jobs that are submitted again stay active
+	if r, ok := (*sm.Job).(Resubmitter); ok && sm.Status == FAILED && r.Resubmit() {
+		return
+	}
+	if rc, ok := (*sm.Job).(ResultsChecker); ok && sm.Status == SUCCESSFUL && !rc.CheckResults() {
+		sm.Status = FAILED
+	}
 	(*sm.Job).NewStatusUpdate(sm.Status, sm.LastUpdate)
 
 	switch sm.Status {
@@ -13,31 +13,6 @@ import (
 	"github.com/aws/aws-sdk-go/service/ecr"
 )
 
-type process struct {
-	ProcessID      string `json:"processId"`
-	ProcessVersion string `json:"processVersion"`
-}
-
-type image struct {
-	ImageURI    string `json:"imageURI"`
-	ImageDigest string `json:"imageDigest"`
-}
-
-// Define a metaData object
-type metaData struct {
-	Context string `json:"@context"`
-	JobID   string `json:"apiJobId"`
-	// User    string  `json:"apiUser"`
-	Process process `json:"process"`
-	Image   image   `json:"image,omitempty"`
-	// ComputeEnvironmentURI    string    // ARN
-	// ComputeEnvironmentDigest string    // required for reproducibility, will need to be custom implemented
-	Commands        []string  `json:"commands"`
-	GeneratedAtTime time.Time `json:"generatedAtTime"` // not implemented
-	StartedAtTime   time.Time `json:"startedAtTime"`   // not implemented
-	EndedAtTime     time.Time `json:"endedAtTime"`
-}
-
 // Get image digest from ecr
 func getECRImageDigest(imgURI string) (string, error) {
 	var imgDgst string
@@ -0,0 +1,13 @@
+package jobs
+
+import "time"
+
+// JobNote is a free-text annotation of a job, e.g. an operator documenting why a job was dismissed or re-run.
+// Notes are deleted with their job.
+type JobNote struct {
+	ID      int64     `json:"id"`
+	JobID   string    `json:"jobID"`
+	Author  string    `json:"author"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
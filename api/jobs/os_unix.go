@@ -0,0 +1,22 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"os"
+	"syscall"
+)
+
+// Bytes of the filesystem of dir available to the server
+func availableDiskBytes(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// Ask a subprocess to stop with SIGTERM, it is killed if it is still running after the stop grace period
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Bytes of the volume of dir available to the server
+func availableDiskBytes(dir string) (int64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
+
+// Windows can not signal other processes to stop, subprocesses are killed without a stop grace period
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
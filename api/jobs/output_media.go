@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"app/utils"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Set the Content-Type of S3 objects referenced by the results of a successful job, so that downstream tools recognize them
+// without sniffing. Objects of outputs in mediaTypes get the declared media type, other objects the media type of their extension
+// if they were uploaded with a generic content type. Zarr stores are prefixes, not objects, and are left as they are.
+func setOutputContentTypes(logger *log.Logger, svc *s3.S3, jid, tenant string, mediaTypes map[string]string) {
+	results, err := FetchResults(svc, jid, tenant)
+	if err != nil {
+		return
+	}
+	outputs, ok := results.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for id, v := range outputs {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, v := range values {
+			u, err := url.Parse(referenceHref(v))
+			if err != nil || u.Scheme != "s3" {
+				continue
+			}
+			key := strings.TrimPrefix(u.Path, "/")
+			declared, replace := mediaTypes[id]
+			mt := declared
+			if !replace {
+				mt = utils.MediaTypeByExtension(key)
+			}
+			if mt == "" || mt == utils.MediaTypeZarr {
+				continue
+			}
+			if err := utils.SetS3ContentType(svc, u.Host, key, mt, replace); err != nil {
+				logger.Warnf("Could not set content type of output %s at %s: %s", id, u.String(), err.Error())
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package jobs
 import (
 	"container/list"
 	"sync"
+	"time"
 )
 
 // PendingJobs is a pure FIFO queue for jobs waiting to be executed.
@@ -26,17 +27,24 @@ import (
 //	  1. Map lookup: O(1) to find element
 //	  2. List remove: O(1) to update prev/next pointers
 //	  Result: job1 ◄──► job3
+//
+// Paused jobs keep their position in the queue, QueueWorker skips them until they are resumed.
 type PendingJobs struct {
-	list  *list.List
-	index map[string]*list.Element
-	mu    sync.Mutex
+	list   *list.List
+	index  map[string]*list.Element
+	paused map[string]bool
+	mu     sync.Mutex
+	// When jobs were enqueued, the front of the list is the oldest job
+	enqueued map[string]time.Time
 }
 
 // NewPendingJobs creates a new PendingJobs queue.
 func NewPendingJobs() *PendingJobs {
 	return &PendingJobs{
-		list:  list.New(),
-		index: make(map[string]*list.Element),
+		list:     list.New(),
+		index:    make(map[string]*list.Element),
+		paused:   make(map[string]bool),
+		enqueued: make(map[string]time.Time),
 	}
 }
 
@@ -47,6 +55,7 @@ func (pj *PendingJobs) Enqueue(j *Job) {
 
 	elem := pj.list.PushBack(j)
 	pj.index[(*j).JobID()] = elem
+	pj.enqueued[(*j).JobID()] = time.Now()
 }
 
 // Peek returns the job at the front of the queue without removing it.
@@ -76,12 +85,75 @@ func (pj *PendingJobs) Remove(jobID string) *Job {
 	}
 
 	delete(pj.index, jobID)
+	delete(pj.paused, jobID)
+	delete(pj.enqueued, jobID)
 	return pj.list.Remove(elem).(*Job)
 }
 
+// Pause marks a queued job as paused.
+// Returns false if the job is not in the queue.
+func (pj *PendingJobs) Pause(jobID string) bool {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	if _, ok := pj.index[jobID]; !ok {
+		return false
+	}
+	pj.paused[jobID] = true
+	return true
+}
+
+// Resume clears the paused mark of a queued job.
+// Returns false if the job is not in the queue.
+func (pj *PendingJobs) Resume(jobID string) bool {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	if _, ok := pj.index[jobID]; !ok {
+		return false
+	}
+	delete(pj.paused, jobID)
+	return true
+}
+
+// IsPaused returns true if the job is in the queue and paused.
+func (pj *PendingJobs) IsPaused(jobID string) bool {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	return pj.paused[jobID]
+}
+
 // Len returns the number of jobs in the queue.
 func (pj *PendingJobs) Len() int {
 	pj.mu.Lock()
 	defer pj.mu.Unlock()
 	return pj.list.Len()
 }
+
+// List returns a snapshot of the queue in FIFO order.
+func (pj *PendingJobs) List() []*Job {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	list := make([]*Job, 0, pj.list.Len())
+	for e := pj.list.Front(); e != nil; e = e.Next() {
+		list = append(list, e.Value.(*Job))
+	}
+	return list
+}
+
+// Stats returns the number of queued jobs, per process and the job at the front of the queue.
+func (pj *PendingJobs) Stats() QueueStats {
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+
+	stats := QueueStats{Count: pj.list.Len(), ByProcess: make(map[string]int)}
+	for e := pj.list.Front(); e != nil; e = e.Next() {
+		stats.ByProcess[(*e.Value.(*Job)).ProcessID()]++
+	}
+	if front := pj.list.Front(); front != nil {
+		stats.OldestJobID = (*front.Value.(*Job)).JobID()
+		stats.OldestAge = time.Since(pj.enqueued[stats.OldestJobID]).Seconds()
+	}
+	return stats
+}
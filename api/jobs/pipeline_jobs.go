@@ -0,0 +1,504 @@
+package jobs
+
+import (
+	"app/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// PipelineRunner runs the steps of pipeline jobs as jobs of their processes. Implemented by the API handler,
+// which knows the processes and how jobs of each host are submitted.
+type PipelineRunner interface {
+	// StartStep submits the jobs of step i and returns them, one job per element of fan-out steps.
+	// Already submitted jobs are returned with the error if not all jobs of the step could be submitted.
+	StartStep(j *PipelineJob, i int) (runs []Job, fanOut bool, err error)
+	// RunResults returns the results of a successful job of a step
+	RunResults(run Job) (interface{}, error)
+	// Results returns the results of the pipeline job once all steps succeeded
+	Results(j *PipelineJob) (interface{}, error)
+	// Dismiss dismisses a job of a step that has not finished
+	Dismiss(run Job) error
+}
+
+// Time between status checks of step jobs, in case a status change was missed
+const pipelinePollInterval = 10 * time.Second
+
+// PipelineJob runs the steps of a pipeline process one after the other. Every run of a step is a job of the step's process,
+// the pipeline job only waits for them. Steps and their results are logged as process logs,
+// the results of the pipeline are logged last like plugins do.
+type PipelineJob struct {
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	// Used for monitoring meta data and other routines
+	wg sync.WaitGroup
+	// Used for monitoring running complete for sync jobs
+	wgRun sync.WaitGroup
+	// closeOnce ensures Close() body executes exactly once
+	closeOnce sync.Once
+
+	UUID           string `json:"jobID"`
+	ProcessName    string `json:"processID"`
+	ProcessVersion string `json:"processVersion"`
+	Submitter      string
+	Tenant         string
+	RequestID      string // ID of the API request that created the job
+	RerunOf        string // ID of the job this job re-runs
+	LogLevel       string // level of server logs of the job and of the jobs of its steps, LOG_LEVEL of the server if empty
+	ParentID       string // ID of the fan-out job this job is an element of
+	Inputs         map[string]interface{}
+	Steps          []string // IDs of the steps in order
+	Cmd            []string `json:"commandOverride"`
+	UpdateTime     time.Time
+	Status         string `json:"status"`
+
+	// guards results and runs, they are read by the runner and Kill while Run updates them
+	mu      sync.Mutex
+	results map[string]interface{}
+	// Jobs of the running step
+	runs []Job
+
+	startTime time.Time
+	endTime   time.Time
+
+	logger     *log.Logger
+	logFile    *os.File
+	stepLogger *log.Logger // writes process logs
+
+	DB         Database
+	Events     *EventBus
+	JobEvents  *JobEventHub
+	StorageSvc *s3.S3
+	ActiveJobs *ActiveJobs
+	Runner     PipelineRunner
+	IsSync     bool
+}
+
+func (j *PipelineJob) WaitForRunCompletion() {
+	j.wgRun.Wait()
+}
+
+func (j *PipelineJob) JobID() string {
+	return j.UUID
+}
+
+func (j *PipelineJob) ProcessID() string {
+	return j.ProcessName
+}
+
+func (j *PipelineJob) ProcessVersionID() string {
+	return j.ProcessVersion
+}
+
+func (j *PipelineJob) SUBMITTER() string {
+	return j.Submitter
+}
+
+func (j *PipelineJob) TENANT() string {
+	return j.Tenant
+}
+
+func (j *PipelineJob) PARENT() string {
+	return j.ParentID
+}
+
+func (j *PipelineJob) CMD() []string {
+	return j.Cmd
+}
+
+// GetResources returns no resources, resources are reserved by the jobs of the steps
+func (j *PipelineJob) GetResources() Resources {
+	return Resources{}
+}
+
+func (j *PipelineJob) LogMessage(m string, level log.Level) {
+	switch level {
+	case 2:
+		j.logger.Error(m)
+	case 3:
+		j.logger.Warn(m)
+	case 4:
+		j.logger.Info(m)
+	case 5:
+		j.logger.Debug(m)
+	case 6:
+		j.logger.Trace(m)
+	default:
+		j.logger.Info(m) // default to Info level if level is out of range
+	}
+}
+
+func (j *PipelineJob) LastUpdate() time.Time {
+	return j.UpdateTime
+}
+
+func (j *PipelineJob) NewStatusUpdate(status string, updateTime time.Time) {
+
+	// If old status is one of the terminated status, it should not update status.
+	switch j.Status {
+	case SUCCESSFUL, DISMISSED, FAILED:
+		return
+	}
+
+	j.Status = status
+	if updateTime.IsZero() {
+		j.UpdateTime = time.Now()
+	} else {
+		j.UpdateTime = updateTime
+	}
+	j.DB.updateJobRecord(j.UUID, status, j.UpdateTime)
+	j.logger.Infof("Status changed to %s.", status)
+	publishStatusEvent(j.Events, j, status, j.UpdateTime)
+}
+
+func (j *PipelineJob) CurrentStatus() string {
+	return j.Status
+}
+
+func (j *PipelineJob) Equals(job Job) bool {
+	switch jj := job.(type) {
+	case *PipelineJob:
+		return j.ctx == jj.ctx
+	default:
+		return false
+	}
+}
+
+// StepResults returns the results of the steps that finished so far by step ID
+func (j *PipelineJob) StepResults() map[string]interface{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	results := make(map[string]interface{}, len(j.results))
+	for k, v := range j.results {
+		results[k] = v
+	}
+	return results
+}
+
+func (j *PipelineJob) initLogger() error {
+	// Process logs are written by the pipeline itself
+	file, err := os.Create(LocalLogPath(j.UUID, "process"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	j.logFile = file
+	j.stepLogger = log.New()
+	j.stepLogger.SetOutput(file)
+	j.stepLogger.SetFormatter(&log.JSONFormatter{})
+
+	// Create logger for server logs
+	j.logger = log.New()
+
+	file, err = os.Create(LocalLogPath(j.UUID, "server"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+
+	j.logger.SetOutput(ServerLogWriter(file))
+	j.logger.SetFormatter(&log.JSONFormatter{})
+	j.logger.AddHook(newJobFieldsHook(j.UUID, j.RequestID))
+
+	lvl, err := log.ParseLevel(jobLogLevel(j.LogLevel))
+	if err != nil {
+		j.logger.Warnf("Invalid LOG_LEVEL set, %s; defaulting to INFO", jobLogLevel(j.LogLevel))
+		lvl = log.InfoLevel
+	}
+	j.logger.SetLevel(lvl)
+	return nil
+}
+
+func (j *PipelineJob) Create() error {
+	err := j.initLogger()
+	if err != nil {
+		return err
+	}
+	j.logger.Info("Pipeline steps: ", strings.Join(j.Steps, ", "))
+
+	ctx, cancelFunc := context.WithCancel(context.TODO())
+	j.ctx = ctx
+	j.ctxCancel = cancelFunc
+	j.results = make(map[string]interface{}, len(j.Steps))
+
+	// At this point job is ready to be added to database
+	err = j.DB.addJob(j.UUID, "accepted", "", "local", j.ProcessName, j.Submitter, j.Tenant, j.RequestID, j.ParentID, time.Now())
+	if err != nil {
+		j.ctxCancel()
+		return err
+	}
+
+	j.NewStatusUpdate(ACCEPTED, time.Time{})
+
+	// Increment wgRun here so WaitForRunCompletion() blocks until Run() finished
+	j.wgRun.Add(1)
+	return nil
+}
+
+func (j *PipelineJob) IsSyncJob() bool {
+	return j.IsSync
+}
+
+// Run runs the steps one after the other, the job fails with the first step that fails
+func (j *PipelineJob) Run() {
+	defer func() {
+		if r := recover(); r != nil {
+			j.logger.Errorf("Run() panicked: %v", r)
+			j.NewStatusUpdate(FAILED, time.Time{})
+		}
+		j.Close()
+		j.wgRun.Done()
+	}()
+
+	j.startTime = time.Now()
+	j.NewStatusUpdate(RUNNING, time.Time{})
+
+	for i, step := range j.Steps {
+		if j.ctx.Err() != nil {
+			return
+		}
+		runs, fanOut, err := j.Runner.StartStep(j, i)
+		j.setRuns(runs)
+		if err != nil {
+			j.stepLogger.Errorf("Step %s could not be started: %s", step, err.Error())
+			j.dismissRuns()
+			j.endTime = time.Now()
+			j.NewStatusUpdate(FAILED, time.Time{})
+			return
+		}
+		j.stepLogger.Infof("Step %s started: jobs %s", step, strings.Join(jobIDs(runs), ", "))
+
+		results, err := j.waitForRuns(i, runs)
+		j.setRuns(nil)
+		if err != nil {
+			j.endTime = time.Now()
+			if j.CurrentStatus() == DISMISSED {
+				return
+			}
+			j.stepLogger.Errorf("Step %s failed: %s", step, err.Error())
+			j.NewStatusUpdate(FAILED, time.Time{})
+			return
+		}
+
+		j.mu.Lock()
+		if fanOut {
+			j.results[step] = results
+		} else {
+			j.results[step] = results[0]
+		}
+		j.mu.Unlock()
+		j.stepLogger.Infof("Step %s finished.", step)
+	}
+	j.endTime = time.Now()
+
+	results, err := j.Runner.Results(j)
+	if err != nil {
+		j.stepLogger.Errorf("Results could not be resolved: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+	b, err := json.Marshal(map[string]interface{}{"plugin_results": results})
+	if err != nil {
+		j.stepLogger.Errorf("Results could not be written: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+	// Results are the last line of the process logs, see FetchResults
+	if _, err := fmt.Fprintf(j.logFile, "%s\n", b); err != nil {
+		j.logger.Errorf("Results could not be written: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+
+	j.logger.Info("Pipeline finished successfully.")
+	j.NewStatusUpdate(SUCCESSFUL, time.Time{})
+	go j.WriteMetaData()
+}
+
+func (j *PipelineJob) setRuns(runs []Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.runs = runs
+}
+
+// Dismiss jobs of the running step that have not finished
+func (j *PipelineJob) dismissRuns() {
+	j.mu.Lock()
+	runs := j.runs
+	j.mu.Unlock()
+	for _, run := range runs {
+		switch run.CurrentStatus() {
+		case SUCCESSFUL, FAILED, DISMISSED:
+			continue
+		}
+		if err := j.Runner.Dismiss(run); err != nil {
+			j.logger.Errorf("Could not dismiss job %s of the pipeline. Error: %s", run.JobID(), err.Error())
+		}
+	}
+}
+
+// Wait until all jobs of step i finished and return their results in order, progress is published whenever a job finished.
+// Returns an error once a job did not succeed, the other jobs of the step are dismissed.
+func (j *PipelineJob) waitForRuns(i int, runs []Job) ([]interface{}, error) {
+	results := make([]interface{}, len(runs))
+	errs := make(chan error, len(runs))
+	for k, run := range runs {
+		go func(k int, run Job) {
+			status := j.waitForRun(run)
+			switch status {
+			case SUCCESSFUL:
+				res, err := j.Runner.RunResults(run)
+				if err != nil {
+					errs <- fmt.Errorf("results of job %s: %s", run.JobID(), err.Error())
+					return
+				}
+				results[k] = res
+				errs <- nil
+			case "":
+				errs <- fmt.Errorf("pipeline was dismissed")
+			default:
+				errs <- fmt.Errorf("job %s %s", run.JobID(), status)
+			}
+		}(k, run)
+	}
+
+	var err error
+	for done := range runs {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			j.dismissRuns()
+		}
+		if err == nil {
+			j.publishProgress(i, done+1, len(runs))
+		}
+	}
+	return results, err
+}
+
+// Publish the progress of the job when done of total jobs of step i finished,
+// every step counts the same whatever the number of its jobs
+func (j *PipelineJob) publishProgress(i, done, total int) {
+	progress := (100*i + 100*done/total) / len(j.Steps)
+	j.JobEvents.PublishProgress(JobProgress{
+		JobID:    j.UUID,
+		Progress: &progress,
+		Message:  fmt.Sprintf("step %s: %d of %d jobs finished", j.Steps[i], done, total),
+	})
+}
+
+// Wait until run reached a terminal status and return it, empty if the pipeline job was dismissed first
+func (j *PipelineJob) waitForRun(run Job) string {
+	events, unsubscribe := j.JobEvents.Subscribe(run.JobID())
+	defer unsubscribe()
+
+	ticker := time.NewTicker(pipelinePollInterval)
+	defer ticker.Stop()
+	for {
+		switch s := run.CurrentStatus(); s {
+		case SUCCESSFUL:
+			// results are available once the job finished running
+			run.WaitForRunCompletion()
+			return s
+		case FAILED, DISMISSED:
+			return s
+		}
+		select {
+		case _, ok := <-events:
+			if !ok {
+				// closed after the terminal status event, the status is checked again
+				events = nil
+			}
+		case <-ticker.C:
+		case <-j.ctx.Done():
+			return ""
+		}
+	}
+}
+
+func jobIDs(js []Job) []string {
+	ids := make([]string, len(js))
+	for i, j := range js {
+		ids[i] = j.JobID()
+	}
+	return ids
+}
+
+// Kill dismisses the pipeline and the jobs of its running step
+func (j *PipelineJob) Kill() error {
+	j.logger.Info("Received dismiss signal.")
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		// if these jobs have been loaded from previous snapshot they would not have context etc
+		return fmt.Errorf("can't call delete on an already completed, failed, or dismissed job")
+	}
+
+	j.NewStatusUpdate(DISMISSED, time.Time{})
+	j.ctxCancel()
+	j.dismissRuns()
+
+	go j.Close()
+	return nil
+}
+
+// Write metadata at the job's metadata location
+func (j *PipelineJob) WriteMetaData() {
+	j.logger.Info("Starting metadata writing routine.")
+	j.wg.Add(1)
+	defer j.wg.Done()
+	defer j.logger.Info("Finished metadata writing routine.")
+
+	err := writeProvDocument(j.StorageSvc, j.DB, provRecord{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersionID(),
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+		RerunOf:        j.RerunOf,
+		Commands:       j.Cmd,
+		Started:        j.startTime,
+		Ended:          j.endTime,
+		Host:           "pipeline",
+	})
+	if err != nil {
+		j.logger.Errorf("Error writing metadata: %s", err.Error())
+	}
+}
+
+func (j *PipelineJob) RunFinished() {
+	// do nothing because decrementing wgRun is handled by Run Function
+}
+
+// Write final logs, cancelCtx
+func (j *PipelineJob) Close() {
+	j.closeOnce.Do(func() {
+		j.logger.Info("Starting closing routine.")
+		j.ctxCancel() // Signal Run function to terminate if running
+
+		j.ActiveJobs.RemoveID(j.UUID) // At this point job can be safely removed from active jobs
+
+		go func() {
+			j.wg.Wait() // wait if other routines like metadata are running
+			j.logFile.Close()
+			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName, j.Tenant)
+			// It is expected that logs will be requested multiple times for a recently finished job
+			// so we are waiting for the local logs TTL (default one hour) before deleting the local copy
+			// so that we can avoid repetitive request to storage service.
+			// If the server shutdown, these files would need to be manually deleted
+			time.Sleep(config.Get().Logging.LocalLogsTTL)
+			DeleteLocalLogs(j.StorageSvc, j.UUID, j.ProcessName)
+		}()
+	})
+}
+
+func (j *PipelineJob) IMAGE() string {
+	return ""
+}
+
+func (j *PipelineJob) UpdateProcessLogs() (err error) {
+	return nil
+}
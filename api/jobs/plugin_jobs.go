@@ -0,0 +1,632 @@
+package jobs
+
+import (
+	"app/config"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Interval at which statuses of plugin jobs are polled, and their logs while they are streamed
+	pluginPollInterval = 5 * time.Second
+	// Max time of a call to a backend plugin
+	pluginCallTimeout = 30 * time.Second
+)
+
+// ErrPluginJobNotFound is returned by PluginClient.Status if the backend does not know the job
+var ErrPluginJobNotFound = errors.New("no such job")
+
+// PluginClient is the job backend contract of an out-of-process backend plugin, see package backendplugin.
+// Jobs are identified by the backend ID returned by Create.
+type PluginClient interface {
+	// Create submits a job, the backend starts it
+	Create(ctx context.Context, req PluginJobRequest) (string, error)
+	// Status returns the status of a job, ErrPluginJobNotFound if the backend does not know it
+	Status(ctx context.Context, backendID string) (PluginJobState, error)
+	// Kill stops an accepted or running job
+	Kill(ctx context.Context, backendID, reason string) error
+	// Logs returns the process log lines of a job after the first offset lines
+	Logs(ctx context.Context, backendID string, offset int64) ([]string, error)
+	// Metadata returns the image and start and end times of a finished job
+	Metadata(ctx context.Context, backendID string) (PluginJobMetadata, error)
+}
+
+// PluginJobRequest is the job submitted to a backend plugin, env variables are resolved
+type PluginJobRequest struct {
+	JobID          string
+	ProcessID      string
+	ProcessVersion string
+	Image          string
+	Cmd            []string
+	Env            map[string]string
+	Resources      Resources
+	Inputs         json.RawMessage
+	Submitter      string
+	Tenant         string
+}
+
+// PluginJobState is the status of a job on a backend plugin, Reason is set for failed jobs
+type PluginJobState struct {
+	Status  string
+	Updated time.Time
+	Reason  string
+}
+
+// PluginJobMetadata is what a backend plugin knows about how a job ran
+type PluginJobMetadata struct {
+	ImageURI    string
+	ImageDigest string
+	Started     time.Time
+	Ended       time.Time
+}
+
+// NewPluginBackend returns the backend of host type hostType whose jobs run on a backend plugin.
+// Jobs are started by the plugin in Create, they are not queued locally.
+func NewPluginBackend(hostType string, client PluginClient) Backend {
+	return pluginBackend{hostType: hostType, client: client}
+}
+
+type pluginBackend struct {
+	hostType string
+	client   PluginClient
+}
+
+func (pluginBackend) Queued() bool { return false }
+
+func (b pluginBackend) NewJob(spec JobSpec, svc JobServices) (Job, error) {
+	return &PluginJob{
+		UUID:           spec.JobID,
+		HostType:       b.hostType,
+		ProcessName:    spec.ProcessID,
+		ProcessVersion: spec.ProcessVersion,
+		Image:          spec.Image,
+		Submitter:      spec.Submitter,
+		Tenant:         spec.Tenant,
+		RequestID:      spec.RequestID,
+		RerunOf:        spec.RerunOf,
+		ParentID:       spec.ParentID,
+		LogLevel:       spec.LogLevel,
+		Cmd:            spec.Cmd,
+		EnvVars:        spec.EnvVars,
+		EnvVarsFrom:    spec.EnvVarsFrom,
+		EnvOverrides:   spec.EnvOverrides,
+		InputsFile:     spec.InputsFile,
+		OutputTypes:    spec.OutputTypes,
+		OutputSchemas:  spec.OutputSchemas,
+		StdoutResults:  spec.StdoutResults,
+		Callback:       spec.Callback,
+		StorageAccess:  spec.StorageAccess,
+		Resources:      spec.Resources,
+		StorageSvc:     svc.StorageSvc,
+		DB:             svc.DB,
+		Events:         svc.Events,
+		ActiveJobs:     svc.ActiveJobs,
+		Client:         b.client,
+	}, nil
+}
+
+// PluginJob is a job run by a backend plugin. Its status is polled from the plugin until it finished.
+type PluginJob struct {
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	// Used for monitoring meta data and other routines
+	wg sync.WaitGroup
+	// Used for monitoring running complete for sync jobs
+	wgRun sync.WaitGroup
+	// closeOnce ensures Close() body executes exactly once
+	closeOnce sync.Once
+
+	UUID           string `json:"jobID"`
+	BackendID      string // ID of the job on the plugin
+	HostType       string `json:"hostType"`
+	Image          string `json:"image"`
+	ProcessName    string `json:"processID"`
+	ProcessVersion string `json:"processVersion"`
+	Submitter      string
+	Tenant         string
+	RequestID      string            // ID of the API request that created the job
+	RerunOf        string            // ID of the job this job re-runs
+	LogLevel       string            // level of server logs and LOG_LEVEL of the process, LOG_LEVEL of the server if empty
+	ParentID       string            // ID of the pipeline or fan-out job this job is a step of
+	OutputTypes    map[string]string // media types declared by outputs of the process, set on objects referenced by results
+	OutputSchemas  ResultsValidator  // validates results before the job succeeds, nil if no output declares a schema
+	StdoutResults  *StdoutResults    // captures results from stdout when the job succeeds, nil reads them from the process logs
+	Callback       *Callback         // results callback of the job, its env variables are set if not nil
+	StorageAccess  *StorageAccess    // scoped storage credentials are issued when the job starts if not nil
+	EnvVars        []EnvVar
+	EnvVarsFrom    []EnvVarFrom      // resolved when the job is submitted to the plugin
+	EnvOverrides   map[string]string // set by the execute request
+	InputsFile     json.RawMessage   // inputs of processes with inputDelivery file, sent to the plugin
+	Cmd            []string          `json:"commandOverride"`
+	UpdateTime     time.Time
+	Status         string `json:"status"`
+
+	logger         *log.Logger
+	logFile        *os.File
+	logBroadcaster *LogBroadcaster
+	// Serializes log fetches of log routes and the log poller, logOffset is the number of lines fetched
+	logsMu    sync.Mutex
+	logOffset int64
+	pollOnce  sync.Once
+
+	Resources
+	DB         Database
+	Events     *EventBus
+	StorageSvc *s3.S3
+	ActiveJobs *ActiveJobs
+	Client     PluginClient `json:"-"`
+}
+
+func (j *PluginJob) WaitForRunCompletion() {
+	j.wgRun.Wait()
+}
+
+func (j *PluginJob) JobID() string {
+	return j.UUID
+}
+
+func (j *PluginJob) ProcessID() string {
+	return j.ProcessName
+}
+
+func (j *PluginJob) SUBMITTER() string {
+	return j.Submitter
+}
+
+func (j *PluginJob) TENANT() string {
+	return j.Tenant
+}
+
+func (j *PluginJob) PARENT() string {
+	return j.ParentID
+}
+
+func (j *PluginJob) ProcessVersionID() string {
+	return j.ProcessVersion
+}
+
+func (j *PluginJob) CMD() []string {
+	return j.Cmd
+}
+
+func (j *PluginJob) IMAGE() string {
+	return j.Image
+}
+
+func (j *PluginJob) GetResources() Resources {
+	return j.Resources
+}
+
+// Run is a no-op, jobs are started by the plugin in Create()
+func (j *PluginJob) Run() {}
+
+// IsSyncJob returns false, plugins manage their own resources
+func (j *PluginJob) IsSyncJob() bool {
+	return false
+}
+
+func (j *PluginJob) LogMessage(m string, level log.Level) {
+	switch level {
+	case 2:
+		j.logger.Error(m)
+	case 3:
+		j.logger.Warn(m)
+	case 4:
+		j.logger.Info(m)
+	case 5:
+		j.logger.Debug(m)
+	case 6:
+		j.logger.Trace(m)
+	default:
+		j.logger.Info(m) // default to Info level if level is out of range
+	}
+}
+
+func (j *PluginJob) LastUpdate() time.Time {
+	return j.UpdateTime
+}
+
+func (j *PluginJob) NewStatusUpdate(status string, updateTime time.Time) {
+
+	// If old status is one of the terminated status, it should not update status.
+	switch j.Status {
+	case SUCCESSFUL, DISMISSED, FAILED:
+		return
+	}
+
+	j.Status = status
+	if updateTime.IsZero() {
+		j.UpdateTime = time.Now()
+	} else {
+		j.UpdateTime = updateTime
+	}
+	j.DB.updateJobRecord(j.UUID, status, j.UpdateTime)
+	j.logger.Infof("Status changed to %s.", status)
+	publishStatusEvent(j.Events, j, status, j.UpdateTime)
+}
+
+func (j *PluginJob) CurrentStatus() string {
+	return j.Status
+}
+
+func (j *PluginJob) ProviderID() string {
+	return j.BackendID
+}
+
+func (j *PluginJob) Equals(job Job) bool {
+	switch jj := job.(type) {
+	case *PluginJob:
+		return j.ctx == jj.ctx
+	default:
+		return false
+	}
+}
+
+func (j *PluginJob) initLogger() error {
+	// Create a place holder file for process logs
+	file, err := os.Create(LocalLogPath(j.UUID, "process"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	file.Close()
+
+	// Create logger for server logs
+	j.logger = log.New()
+
+	j.logFile, err = os.Create(LocalLogPath(j.UUID, "server"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+
+	j.logger.SetOutput(ServerLogWriter(j.logFile))
+	j.logger.SetFormatter(&log.JSONFormatter{})
+	j.logger.AddHook(newJobFieldsHook(j.UUID, j.RequestID))
+
+	lvl, err := log.ParseLevel(jobLogLevel(j.LogLevel))
+	if err != nil {
+		j.logger.Warnf("Invalid LOG_LEVEL set: %s, defaulting to INFO", jobLogLevel(j.LogLevel))
+		lvl = log.InfoLevel
+	}
+	j.logger.SetLevel(lvl)
+
+	j.logBroadcaster = NewLogBroadcaster()
+	return nil
+}
+
+// Env variables of the process, secrets are resolved
+func (j *PluginJob) env() (map[string]string, error) {
+	envs := make(map[string]string, len(j.EnvVars)+len(j.EnvVarsFrom))
+	for _, ev := range j.EnvVars {
+		envs[ev.Name] = ev.resolve()
+	}
+	secretEnvs, err := resolveEnvVarsFrom(j.ctx, j.EnvVarsFrom)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range secretEnvs {
+		k, v, _ := strings.Cut(kv, "=")
+		envs[k] = v
+	}
+	if j.LogLevel != "" {
+		envs[LogLevelEnvVar] = j.LogLevel
+	}
+	for k, v := range j.EnvOverrides {
+		envs[k] = v
+	}
+	storageEnvs, err := j.StorageAccess.envVars(j.ctx, j.UUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range append(j.Callback.envVars(), storageEnvs...) {
+		k, v, _ := strings.Cut(kv, "=")
+		envs[k] = v
+	}
+	return envs, nil
+}
+
+func (j *PluginJob) Create() error {
+	err := j.initLogger()
+	if err != nil {
+		return err
+	}
+	j.logger.Info("Commands: ", j.CMD())
+
+	ctx, cancelFunc := context.WithCancel(context.TODO())
+	j.ctx = ctx
+	j.ctxCancel = cancelFunc
+
+	envs, err := j.env()
+	if err != nil {
+		j.ctxCancel()
+		return err
+	}
+	j.logger.Debugf("Registered %v env vars", len(envs))
+
+	callCtx, cancel := context.WithTimeout(j.ctx, pluginCallTimeout)
+	defer cancel()
+	backendID, err := j.Client.Create(callCtx, PluginJobRequest{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessName,
+		ProcessVersion: j.ProcessVersion,
+		Image:          j.Image,
+		Cmd:            j.Cmd,
+		Env:            envs,
+		Resources:      j.Resources,
+		Inputs:         j.InputsFile,
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+	})
+	if err != nil {
+		j.ctxCancel()
+		return fmt.Errorf("%s backend plugin could not create job: %s", j.HostType, err.Error())
+	}
+
+	j.wgRun.Add(1) // When status is one of the final status this should be decremented, this is the responsibility of who ever is updating status
+
+	j.BackendID = backendID
+
+	// At this point job is ready to be added to database
+	err = j.DB.addJob(j.UUID, "accepted", "", j.HostType, j.ProcessName, j.Submitter, j.Tenant, j.RequestID, j.ParentID, time.Now())
+	if err != nil {
+		j.ctxCancel()
+		return err
+	}
+
+	j.NewStatusUpdate(ACCEPTED, time.Time{})
+	j.logger.Infof("Submitted to %s backend plugin as %s.", j.HostType, backendID)
+
+	go j.pollStatus()
+	return nil
+}
+
+// Poll the status of the job on the plugin until it finished or the job is closed
+func (j *PluginJob) pollStatus() {
+	ticker := time.NewTicker(pluginPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if j.updateStatus() {
+				return
+			}
+		}
+	}
+}
+
+// Update the status of the job to its status on the plugin, returns true if the job finished
+func (j *PluginJob) updateStatus() bool {
+	ctx, cancel := context.WithTimeout(j.ctx, pluginCallTimeout)
+	defer cancel()
+	state, err := j.Client.Status(ctx, j.BackendID)
+	switch {
+	case errors.Is(err, ErrPluginJobNotFound):
+		j.logger.Errorf("Job %s no longer exists on the %s backend plugin.", j.BackendID, j.HostType)
+		if err := j.DB.updateFailureClass(j.UUID, FailureLost); err != nil {
+			j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+		}
+		state = PluginJobState{Status: FAILED}
+	case err != nil:
+		if j.ctx.Err() == nil {
+			j.logger.Warnf("Could not get status from %s backend plugin. Error: %s", j.HostType, err.Error())
+		}
+		return false
+	}
+
+	switch state.Status {
+	case ACCEPTED, RUNNING:
+		if state.Status != j.CurrentStatus() {
+			j.NewStatusUpdate(state.Status, state.Updated)
+		}
+		return false
+	case SUCCESSFUL, FAILED, DISMISSED:
+		if state.Reason != "" {
+			j.logger.Errorf("Job %s: %s", state.Status, state.Reason)
+		}
+		var job Job = j
+		ProcessStatusMessageUpdate(StatusMessage{Job: &job, Status: state.Status, LastUpdate: state.Updated})
+		return true
+	default:
+		j.logger.Warnf("Unknown status %q from %s backend plugin.", state.Status, j.HostType)
+		return false
+	}
+}
+
+// UpdateProcessLogs fetches new process logs from the plugin
+func (j *PluginJob) UpdateProcessLogs() error {
+	j.logsMu.Lock()
+	defer j.logsMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginCallTimeout)
+	defer cancel()
+	lines, err := j.Client.Logs(ctx, j.BackendID, j.logOffset)
+	if err != nil {
+		j.logger.Errorf("Error fetching logs from %s backend plugin: %s", j.HostType, err.Error())
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(LocalLogPath(j.UUID, "process"), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("error writing log: %s", err.Error())
+		}
+		j.logBroadcaster.Publish(line)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	j.logOffset += int64(len(lines))
+	if config.Get().Logging.JobLogsFsync != FsyncNever {
+		return file.Sync()
+	}
+	return nil
+}
+
+// SubscribeProcessLogs returns process log lines as they are fetched from the plugin.
+// The plugin is polled for logs only once the first subscriber arrives.
+func (j *PluginJob) SubscribeProcessLogs() (<-chan string, func()) {
+	j.pollOnce.Do(func() {
+		go j.pollLogs()
+	})
+	return j.logBroadcaster.Subscribe()
+}
+
+// Poll the plugin for new process logs until the job is closed
+func (j *PluginJob) pollLogs() {
+	ticker := time.NewTicker(pluginPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			_ = j.UpdateProcessLogs()
+		}
+	}
+}
+
+func (j *PluginJob) Kill() error {
+	j.logger.Info("Received dismiss signal.")
+
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		return fmt.Errorf("can't call delete on an already completed, failed, or dismissed job")
+	}
+
+	if err := j.kill("dismissed"); err != nil {
+		return err
+	}
+
+	var job Job = j
+	ProcessStatusMessageUpdate(StatusMessage{Job: &job, Status: DISMISSED, LastUpdate: time.Now()})
+	return nil
+}
+
+func (j *PluginJob) kill(reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginCallTimeout)
+	defer cancel()
+	if err := j.Client.Kill(ctx, j.BackendID, reason); err != nil {
+		j.logger.Errorf("Could not send kill signal to %s backend plugin. Error: %s", j.HostType, err.Error())
+		return err
+	}
+	return nil
+}
+
+// LastProcessLog returns when the process last wrote a log line that was fetched from the plugin
+func (j *PluginJob) LastProcessLog() time.Time {
+	return j.logBroadcaster.LastPublished()
+}
+
+// ReconcileStatus does nothing, statuses of plugin jobs are polled by the job
+func (j *PluginJob) ReconcileStatus() (string, error) {
+	return "", nil
+}
+
+// Fail kills the job on the plugin and updates the status of the job to failed
+func (j *PluginJob) Fail(class, reason string) error {
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		return fmt.Errorf("can't fail an already completed, failed, or dismissed job")
+	}
+	if err := j.kill(reason); err != nil {
+		return err
+	}
+
+	j.logger.Errorf("Failing job: %s.", reason)
+	if err := j.DB.updateFailureClass(j.UUID, class); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+	var job Job = j
+	ProcessStatusMessageUpdate(StatusMessage{Job: &job, Status: FAILED, LastUpdate: time.Now()})
+	return nil
+}
+
+// Write metadata at the job's metadata location and set the content types of objects referenced by its results
+func (j *PluginJob) WriteMetaData() {
+	j.logger.Info("Starting metadata writing routine.")
+	j.wg.Add(1)
+	defer j.wg.Done()
+	defer j.logger.Info("Finished metadata writing routine.")
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginCallTimeout)
+	defer cancel()
+	md, err := j.Client.Metadata(ctx, j.BackendID)
+	if err != nil {
+		j.logger.Errorf("Error writing metadata: %s", err.Error())
+		return
+	}
+	imageURI := md.ImageURI
+	if imageURI == "" {
+		imageURI = j.Image
+	}
+
+	err = writeProvDocument(j.StorageSvc, j.DB, provRecord{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersion,
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+		RerunOf:        j.RerunOf,
+		Commands:       j.Cmd,
+		ImageURI:       imageURI,
+		ImageDigest:    md.ImageDigest,
+		Started:        md.Started,
+		Ended:          md.Ended,
+		Host:           j.HostType,
+	})
+	if err != nil {
+		j.logger.Errorf("Error writing metadata: %s", err.Error())
+	}
+	setOutputContentTypes(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.OutputTypes)
+}
+
+func (j *PluginJob) RunFinished() {
+	j.wgRun.Done()
+}
+
+// Fetch final logs, cancel ctx, upload logs
+func (j *PluginJob) Close() {
+	j.closeOnce.Do(func() {
+		j.ctxCancel()
+
+		if err := j.UpdateProcessLogs(); err != nil {
+			j.logger.Errorf("Could not update process logs. Error: %s", err.Error())
+		}
+
+		j.logBroadcaster.Close()
+		j.ActiveJobs.RemoveID(j.UUID) // At this point job can be safely removed from active jobs
+
+		go func() {
+			j.wg.Wait() // wait if other routines like metadata are running because they can send logs
+			j.logFile.Close()
+			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName, j.Tenant)
+			// It is expected that logs will be requested multiple times for a recently finished job
+			// so we are waiting for the local logs TTL (default one hour) before deleting the local copy
+			// so that we can avoid repetitive request to storage service
+			time.Sleep(config.Get().Logging.LocalLogsTTL)
+			DeleteLocalLogs(j.StorageSvc, j.UUID, j.ProcessName)
+		}()
+	})
+}
@@ -0,0 +1,297 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Terms used in provenance documents, mirrors context.jsonld at the root of the repository.
+// Embedded so that documents can be processed without fetching the context, the sepex prefix is added per document from REPO_URL.
+var provContext = map[string]interface{}{
+	"prov":   "http://www.w3.org/ns/prov#",
+	"xsd":    "http://www.w3.org/2001/XMLSchema#",
+	"schema": "https://schema.org/",
+
+	"apiJobId":             "schema:identifier",
+	"name":                 "schema:name",
+	"version":              "schema:version",
+	"email":                "schema:email",
+	"href":                 map[string]string{"@id": "prov:atLocation", "@type": "@id"},
+	"value":                map[string]string{"@id": "prov:value", "@type": "@json"},
+	"checksum":             "sepex:checksum",
+	"commands":             "sepex:commands",
+	"scratchBytes":         map[string]string{"@id": "sepex:scratchBytes", "@type": "xsd:long"},
+	"usage":                map[string]string{"@id": "sepex:usage", "@type": "@json"},
+	"cost":                 map[string]string{"@id": "sepex:cost", "@type": "@json"},
+	"custom":               map[string]string{"@id": "sepex:custom", "@type": "@json"},
+	"startedAtTime":        map[string]string{"@id": "prov:startedAtTime", "@type": "xsd:dateTime"},
+	"endedAtTime":          map[string]string{"@id": "prov:endedAtTime", "@type": "xsd:dateTime"},
+	"generatedAtTime":      map[string]string{"@id": "prov:generatedAtTime", "@type": "xsd:dateTime"},
+	"wasAssociatedWith":    map[string]string{"@id": "prov:wasAssociatedWith", "@type": "@id"},
+	"qualifiedAssociation": "prov:qualifiedAssociation",
+	"agent":                map[string]string{"@id": "prov:agent", "@type": "@id"},
+	"hadPlan":              map[string]string{"@id": "prov:hadPlan", "@type": "@id"},
+	"used":                 map[string]string{"@id": "prov:used", "@type": "@id"},
+	"wasGeneratedBy":       map[string]string{"@id": "prov:wasGeneratedBy", "@type": "@id"},
+	"wasInformedBy":        map[string]string{"@id": "prov:wasInformedBy", "@type": "@id"},
+	"wasAttributedTo":      map[string]string{"@id": "prov:wasAttributedTo", "@type": "@id"},
+}
+
+// provDocument is the metadata of a job, a W3C PROV-O JSON-LD document.
+// apiJobId and usage are kept at the top level for clients of the metadata and usage routes.
+type provDocument struct {
+	Context map[string]interface{} `json:"@context"`
+	ID      string                 `json:"@id"`
+	JobID   string                 `json:"apiJobId"`
+	Graph   []interface{}          `json:"@graph"`
+	Usage   *ResourceUsage         `json:"usage,omitempty"` // only for docker jobs
+	Cost    *JobCost               `json:"cost,omitempty"`
+	Custom  map[string]interface{} `json:"custom,omitempty"` // pushed by the job with its callback
+	// Size of the scratch directory when the process exited, only for docker and subprocess jobs
+	ScratchBytes int64 `json:"scratchBytes,omitempty"`
+}
+
+// Agent who submitted the job
+type provAgent struct {
+	ID    string `json:"@id"`
+	Type  string `json:"@type"`
+	Email string `json:"email,omitempty"`
+}
+
+// Association of the job with its submitter and the process it ran
+type provAssociation struct {
+	Type    string `json:"@type"`
+	Agent   string `json:"agent"`
+	HadPlan string `json:"hadPlan"`
+}
+
+// The job
+type provActivity struct {
+	ID                   string          `json:"@id"`
+	Type                 string          `json:"@type"`
+	JobID                string          `json:"apiJobId"`
+	StartedAtTime        *time.Time      `json:"startedAtTime,omitempty"`
+	EndedAtTime          *time.Time      `json:"endedAtTime,omitempty"`
+	WasAssociatedWith    string          `json:"wasAssociatedWith"`
+	QualifiedAssociation provAssociation `json:"qualifiedAssociation"`
+	Used                 []string        `json:"used,omitempty"`
+	WasInformedBy        string          `json:"wasInformedBy,omitempty"` // job this job re-runs
+	Commands             []string        `json:"commands"`
+}
+
+// Process, image, inputs and outputs of the job
+type provEntity struct {
+	ID              string      `json:"@id"`
+	Type            interface{} `json:"@type"`
+	Name            string      `json:"name,omitempty"`
+	Version         string      `json:"version,omitempty"`
+	Href            string      `json:"href,omitempty"`
+	Value           interface{} `json:"value,omitempty"`
+	Checksum        string      `json:"checksum,omitempty"`
+	WasGeneratedBy  string      `json:"wasGeneratedBy,omitempty"`
+	GeneratedAtTime *time.Time  `json:"generatedAtTime,omitempty"`
+	WasAttributedTo string      `json:"wasAttributedTo,omitempty"`
+}
+
+// provRecord collects what is known about a job when its metadata is written
+type provRecord struct {
+	JobID          string
+	ProcessID      string
+	ProcessVersion string
+	Submitter      string
+	Tenant         string
+	RerunOf        string
+	Commands       []string
+	ImageURI       string // empty for subprocess jobs
+	ImageDigest    string
+	Started        time.Time
+	Ended          time.Time
+	Usage          *ResourceUsage
+	ScratchBytes   int64 // size of the scratch directory when the process exited, docker and subprocess jobs
+
+	// Priced by estimateCost, CPUs of the job and the time compute was billed for, Ended - Started if 0
+	Host   string
+	CPUs   float32
+	Billed time.Duration
+}
+
+func jobIRI(jid string) string {
+	return "urn:sepex:job:" + jid
+}
+
+// Build the provenance document of a job. Inputs are read from the job request stored at submission and outputs from the
+// results the job reported, both are left out if unavailable, e.g. for jobs submitted before job requests were stored.
+func newProvDocument(svc *s3.S3, db Database, r provRecord) provDocument {
+	activityID := jobIRI(r.JobID)
+
+	agent := provAgent{ID: "urn:sepex:agent:anonymous", Type: "prov:Agent"}
+	if r.Submitter != "" {
+		agent = provAgent{ID: "mailto:" + r.Submitter, Type: "prov:Person", Email: r.Submitter}
+	}
+
+	plan := provEntity{
+		ID:      fmt.Sprintf("urn:sepex:process:%s:%s", r.ProcessID, r.ProcessVersion),
+		Type:    []string{"prov:Plan", "schema:SoftwareApplication"},
+		Name:    r.ProcessID,
+		Version: r.ProcessVersion,
+	}
+
+	activity := provActivity{
+		ID:                   activityID,
+		Type:                 "prov:Activity",
+		JobID:                r.JobID,
+		StartedAtTime:        timeOrNil(r.Started),
+		EndedAtTime:          timeOrNil(r.Ended),
+		WasAssociatedWith:    agent.ID,
+		QualifiedAssociation: provAssociation{Type: "prov:Association", Agent: agent.ID, HadPlan: plan.ID},
+		Commands:             r.Commands,
+	}
+	if r.RerunOf != "" {
+		activity.WasInformedBy = jobIRI(r.RerunOf)
+	}
+
+	var entities []provEntity
+	if r.ImageURI != "" {
+		img := provEntity{ID: activityID + ":image", Type: "prov:Entity", Name: r.ImageURI, Checksum: r.ImageDigest}
+		entities = append(entities, img)
+		activity.Used = append(activity.Used, img.ID)
+	}
+
+	if jr, ok, err := db.GetJobRequest(r.JobID); err == nil && ok && len(jr.Inputs) > 0 {
+		var inputs map[string]interface{}
+		if err := json.Unmarshal(jr.Inputs, &inputs); err == nil {
+			for _, e := range valueEntities(svc, activityID+":input:", inputs) {
+				entities = append(entities, e)
+				activity.Used = append(activity.Used, e.ID)
+			}
+		}
+	}
+
+	if results, err := FetchResults(svc, r.JobID, r.Tenant); err == nil {
+		outputs, ok := results.(map[string]interface{})
+		if !ok {
+			outputs = map[string]interface{}{"results": results}
+		}
+		for _, e := range valueEntities(svc, activityID+":output:", outputs) {
+			e.WasGeneratedBy = activityID
+			e.GeneratedAtTime = activity.EndedAtTime
+			e.WasAttributedTo = agent.ID
+			entities = append(entities, e)
+		}
+	}
+
+	graph := []interface{}{agent, activity, plan}
+	for _, e := range entities {
+		graph = append(graph, e)
+	}
+
+	ctx := map[string]interface{}{"sepex": fmt.Sprintf("%s/blob/main/context.jsonld#", config.Get().API.RepoURL)}
+	for k, v := range provContext {
+		ctx[k] = v
+	}
+
+	custom, _ := fetchCustomMetadata(svc, CustomMetadataStorageKey(r.JobID, r.Tenant))
+
+	return provDocument{
+		Context:      ctx,
+		ID:           activityID + ":provenance",
+		JobID:        r.JobID,
+		Graph:        graph,
+		Usage:        r.Usage,
+		Custom:       custom,
+		ScratchBytes: r.ScratchBytes,
+	}
+}
+
+// Marshal the provenance document of a job and write it at the job's metadata location
+// and store the estimated cost of the job
+func writeProvDocument(svc *s3.S3, db Database, r provRecord) error {
+	doc := newProvDocument(svc, db, r)
+	doc.Cost = estimateCost(svc, r)
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error marshalling metadata to JSON bytes: %s", err.Error())
+	}
+
+	mdLocation := MetadataStorageKey(r.JobID, r.Tenant)
+	if err := utils.WriteToS3(svc, jsonBytes, mdLocation, "application/json", 0); err != nil {
+		return err
+	}
+	if doc.Cost != nil {
+		if err := db.updateJobCost(r.JobID, *doc.Cost); err != nil {
+			return fmt.Errorf("error storing cost: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// One entity per input or output ID, sorted by ID. Elements of arrays are separate entities.
+// References (`{"href": ...}` objects and URL strings) are recorded with their location, other values with the value itself.
+func valueEntities(svc *s3.S3, idPrefix string, values map[string]interface{}) []provEntity {
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var entities []provEntity
+	for _, id := range ids {
+		if arr, ok := values[id].([]interface{}); ok {
+			for i, v := range arr {
+				entities = append(entities, valueEntity(svc, fmt.Sprintf("%s%s:%d", idPrefix, id, i), id, v))
+			}
+			continue
+		}
+		entities = append(entities, valueEntity(svc, idPrefix+id, id, values[id]))
+	}
+	return entities
+}
+
+func valueEntity(svc *s3.S3, id, name string, v interface{}) provEntity {
+	e := provEntity{ID: id, Type: "prov:Entity", Name: name}
+
+	if href := referenceHref(v); href != "" {
+		e.Href = href
+		e.Checksum = ReferenceChecksum(svc, href)
+		return e
+	}
+
+	e.Value = v
+	b, _ := json.Marshal(v) // map keys are sorted
+	sum := sha256.Sum256(b)
+	e.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+	return e
+}
+
+// Location of a referenced input or output, empty if the value is not a reference
+func referenceHref(v interface{}) string {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if href, ok := vv["href"].(string); ok {
+			return href
+		}
+	case string:
+		for _, scheme := range []string{"s3://", "http://", "https://"} {
+			if strings.HasPrefix(vv, scheme) {
+				return vv
+			}
+		}
+	}
+	return ""
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"sort"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -15,12 +16,18 @@ import (
 //   - Moves resources from "queued" to "used" when jobs start
 //
 // Event-driven: wakes on new job signal or resource release signal.
+//
+// Paused jobs and jobs of paused processes stay in the queue and are skipped.
+// Pause state is kept in memory.
 type QueueWorker struct {
 	pendingJobs  *PendingJobs
 	resourcePool *ResourcePool
 	workSignal   chan struct{} // Signals that new work may be available
 	shutdown     chan struct{}
 	wg           sync.WaitGroup
+
+	mu              sync.Mutex
+	pausedProcesses map[string]bool
 }
 
 // NewQueueWorker creates a new QueueWorker.
@@ -30,6 +37,8 @@ func NewQueueWorker(pendingJobs *PendingJobs, resourcePool *ResourcePool) *Queue
 		resourcePool: resourcePool,
 		workSignal:   make(chan struct{}, 1),
 		shutdown:     make(chan struct{}),
+
+		pausedProcesses: make(map[string]bool),
 	}
 }
 
@@ -57,6 +66,42 @@ func (qw *QueueWorker) NotifyNewJob() {
 	}
 }
 
+// PauseProcess stops queued jobs of the process from being started until it is resumed.
+// Jobs already running are not affected.
+func (qw *QueueWorker) PauseProcess(processID string) {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+	qw.pausedProcesses[processID] = true
+}
+
+// ResumeProcess allows queued jobs of the process to be started again.
+func (qw *QueueWorker) ResumeProcess(processID string) {
+	qw.mu.Lock()
+	delete(qw.pausedProcesses, processID)
+	qw.mu.Unlock()
+	qw.NotifyNewJob()
+}
+
+// ProcessPaused returns true if the queue of the process is paused.
+func (qw *QueueWorker) ProcessPaused(processID string) bool {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+	return qw.pausedProcesses[processID]
+}
+
+// PausedProcesses returns IDs of processes with a paused queue, sorted.
+func (qw *QueueWorker) PausedProcesses() []string {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+
+	ids := make([]string, 0, len(qw.pausedProcesses))
+	for id := range qw.pausedProcesses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // processLoop waits for signals and processes pending jobs.
 func (qw *QueueWorker) processLoop() {
 	defer qw.wg.Done()
@@ -75,15 +120,16 @@ func (qw *QueueWorker) processLoop() {
 }
 
 // tryStartJobs processes pending jobs until queue is empty or resources unavailable.
+// Jobs of tenants that reached their quota are skipped so that they don't block jobs of other tenants.
 func (qw *QueueWorker) tryStartJobs() {
 	for {
-		job := qw.pendingJobs.Peek()
+		job := qw.nextJob()
 		if job == nil {
 			return
 		}
 
 		res := (*job).GetResources()
-		if !qw.resourcePool.TryReserve(res.CPUs, res.Memory) {
+		if !qw.resourcePool.TryReserve((*job).TENANT(), res.CPUs, res.Memory) {
 			return // Not enough resources, wait for release
 		}
 
@@ -91,7 +137,7 @@ func (qw *QueueWorker) tryStartJobs() {
 		removed := qw.pendingJobs.Remove((*job).JobID())
 		if removed == nil {
 			// Job disappeared between peek and remove; release reservation and retry.
-			qw.resourcePool.Release(res.CPUs, res.Memory)
+			qw.resourcePool.Release((*job).TENANT(), res.CPUs, res.Memory)
 			continue
 		}
 
@@ -103,3 +149,17 @@ func (qw *QueueWorker) tryStartJobs() {
 		go (*removed).Run()
 	}
 }
+
+// nextJob returns the oldest pending job that is not paused and whose tenant has not reached its quota, nil if there is none.
+func (qw *QueueWorker) nextJob() *Job {
+	for _, job := range qw.pendingJobs.List() {
+		if qw.pendingJobs.IsPaused((*job).JobID()) || qw.ProcessPaused((*job).ProcessID()) {
+			continue
+		}
+		res := (*job).GetResources()
+		if qw.resourcePool.TenantFits((*job).TENANT(), res.CPUs, res.Memory) {
+			return job
+		}
+	}
+	return nil
+}
@@ -17,6 +17,23 @@ type StatusResponse struct {
 	// Maximum available resources
 	MaxCPUs   float32
 	MaxMemory int
+	// Resources used by running jobs per tenant with a quota
+	Tenants map[string]TenantUsage
+}
+
+// TenantQuota limits resources used by running jobs of a tenant.
+// Zero value of a field means that resource is not limited for the tenant.
+type TenantQuota struct {
+	CPUs   float32
+	Memory int // in MB
+}
+
+// TenantUsage is resource utilization of a tenant with a quota
+type TenantUsage struct {
+	UsedCPUs   float32 `json:"usedCPUs"`
+	UsedMemory int     `json:"usedMemory"`
+	MaxCPUs    float32 `json:"maxCPUs"`
+	MaxMemory  int     `json:"maxMemory"`
 }
 
 // ResourcePool tracks available vs used resources for job scheduling.
@@ -33,6 +50,10 @@ type ResourcePool struct {
 	queuedCPUs   float32
 	queuedMemory int
 
+	// Per tenant quotas and resources used by running jobs of these tenants
+	tenantQuotas map[string]TenantQuota
+	tenantUsed   map[string]TenantQuota
+
 	releaseNotify chan struct{} // Signals QueueWorker when resources are released
 }
 
@@ -45,19 +66,90 @@ func NewResourcePool(maxCPUs float32, maxMemory int) *ResourcePool {
 	return &ResourcePool{
 		maxCPUs:       maxCPUs,
 		maxMemory:     maxMemory,
+		tenantQuotas:  make(map[string]TenantQuota),
+		tenantUsed:    make(map[string]TenantQuota),
 		releaseNotify: make(chan struct{}, 1),
 	}
 }
 
-// TryReserve attempts to reserve resources for a running job.
-// Returns true if successful, false if not enough resources available.
-func (rp *ResourcePool) TryReserve(cpus float32, memory int) bool {
+// Resize changes the max limits of the pool at runtime. Resources reserved by running jobs stay reserved,
+// if they exceed the new limits no job is started until enough of them are released.
+// Waiting jobs are signaled when the limits grow.
+func (rp *ResourcePool) Resize(maxCPUs float32, maxMemory int) {
+	rp.mu.Lock()
+	grown := maxCPUs > rp.maxCPUs || maxMemory > rp.maxMemory
+	rp.maxCPUs = maxCPUs
+	rp.maxMemory = maxMemory
+	if rp.usedCPUs > maxCPUs || rp.usedMemory > maxMemory {
+		log.Warnf("ResourcePool resized below reserved resources: used cpus=%.2f/%.2f, memory=%d/%dMB. New jobs wait until running jobs finish",
+			rp.usedCPUs, maxCPUs, rp.usedMemory, maxMemory)
+	} else {
+		log.Infof("ResourcePool resized: maxCPUs=%.2f, maxMemory=%dMB", maxCPUs, maxMemory)
+	}
+	rp.mu.Unlock()
+
+	if grown {
+		select {
+		case rp.releaseNotify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Limits returns the max limits of the pool.
+func (rp *ResourcePool) Limits() (maxCPUs float32, maxMemory int) {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+	return rp.maxCPUs, rp.maxMemory
+}
+
+// SetTenantQuotas sets resource quotas of tenants, tenants without quota can use the whole pool.
+func (rp *ResourcePool) SetTenantQuotas(quotas map[string]TenantQuota) {
 	rp.mu.Lock()
 	defer rp.mu.Unlock()
 
-	if rp.usedCPUs+cpus <= rp.maxCPUs && rp.usedMemory+memory <= rp.maxMemory {
+	rp.tenantQuotas = quotas
+	for t, q := range quotas {
+		log.Infof("Tenant quota set: tenant=%s, cpus=%.2f, memory=%dMB", t, q.CPUs, q.Memory)
+	}
+}
+
+// TenantFits returns true if reserving resources would not exceed tenant's quota.
+func (rp *ResourcePool) TenantFits(tenant string, cpus float32, memory int) bool {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+	return rp.tenantFits(tenant, cpus, memory)
+}
+
+// tenantFits assumes lock is held by the caller.
+func (rp *ResourcePool) tenantFits(tenant string, cpus float32, memory int) bool {
+	q, ok := rp.tenantQuotas[tenant]
+	if !ok {
+		return true
+	}
+	used := rp.tenantUsed[tenant]
+	if q.CPUs > 0 && used.CPUs+cpus > q.CPUs {
+		return false
+	}
+	if q.Memory > 0 && used.Memory+memory > q.Memory {
+		return false
+	}
+	return true
+}
+
+// TryReserve attempts to reserve resources for a running job of tenant.
+// Returns true if successful, false if not enough resources available in the pool or tenant's quota.
+func (rp *ResourcePool) TryReserve(tenant string, cpus float32, memory int) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.usedCPUs+cpus <= rp.maxCPUs && rp.usedMemory+memory <= rp.maxMemory && rp.tenantFits(tenant, cpus, memory) {
 		rp.usedCPUs += cpus
 		rp.usedMemory += memory
+		if _, ok := rp.tenantQuotas[tenant]; ok {
+			used := rp.tenantUsed[tenant]
+			rp.tenantUsed[tenant] = TenantQuota{CPUs: used.CPUs + cpus, Memory: used.Memory + memory}
+		}
 		log.Debugf("Resources reserved: cpus=%.2f, memory=%dMB. Used: cpus=%.2f/%.2f, memory=%d/%dMB",
 			cpus, memory, rp.usedCPUs, rp.maxCPUs, rp.usedMemory, rp.maxMemory)
 		return true
@@ -65,11 +157,20 @@ func (rp *ResourcePool) TryReserve(cpus float32, memory int) bool {
 	return false
 }
 
-// Release returns resources to the pool when a job finishes.
-func (rp *ResourcePool) Release(cpus float32, memory int) {
+// Release returns resources of a tenant's job to the pool when the job finishes.
+func (rp *ResourcePool) Release(tenant string, cpus float32, memory int) {
 	rp.mu.Lock()
 	rp.usedCPUs -= cpus
 	rp.usedMemory -= memory
+	if used, ok := rp.tenantUsed[tenant]; ok {
+		used.CPUs -= cpus
+		used.Memory -= memory
+		if used.CPUs <= 0 && used.Memory <= 0 {
+			delete(rp.tenantUsed, tenant)
+		} else {
+			rp.tenantUsed[tenant] = used
+		}
+	}
 
 	// Clamp to zero (safety check)
 	if rp.usedCPUs < 0 {
@@ -133,7 +234,21 @@ func (rp *ResourcePool) GetStatus() StatusResponse {
 		QueuedMemory: rp.queuedMemory,
 		MaxCPUs:      rp.maxCPUs,
 		MaxMemory:    rp.maxMemory,
+		Tenants:      rp.tenantStatus(),
+	}
+}
+
+// tenantStatus assumes lock is held by the caller.
+func (rp *ResourcePool) tenantStatus() map[string]TenantUsage {
+	if len(rp.tenantQuotas) == 0 {
+		return nil
+	}
+	res := make(map[string]TenantUsage, len(rp.tenantQuotas))
+	for t, q := range rp.tenantQuotas {
+		used := rp.tenantUsed[t]
+		res[t] = TenantUsage{UsedCPUs: used.CPUs, UsedMemory: used.Memory, MaxCPUs: q.CPUs, MaxMemory: q.Memory}
 	}
+	return res
 }
 
 // ReleaseChan returns the channel that signals when resources are released.
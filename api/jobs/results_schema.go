@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResultsValidator validates the results reported by a job against the schemas declared by the outputs of its process
+type ResultsValidator func(results interface{}) error
+
+// ResultsChecker is implemented by jobs that validate their results before they succeed, results captured from stdout
+// are stored by CheckResults.
+// CheckResults is called before the status of the job is updated to successful and returns false if the results are
+// invalid, the job then fails with failure class invalid_results.
+// Results that can not be captured from stdout are invalid.
+type ResultsChecker interface {
+	CheckResults() bool
+}
+
+// Validate the results of a job whose process exited successfully, logging why they are invalid.
+// Returns true if validate is nil.
+func resultsValid(logger *log.Logger, svc *s3.S3, jid, tenant string, validate ResultsValidator) bool {
+	if validate == nil {
+		return true
+	}
+	results, err := FetchResults(svc, jid, tenant)
+	if err != nil {
+		logger.Errorf("Process did not report results matching the schemas of its outputs: %s", err.Error())
+		return false
+	}
+	if err := validate(results); err != nil {
+		logger.Errorf("Results do not match the schemas of the outputs: %s", err.Error())
+		return false
+	}
+	return true
+}
+
+func (j *DockerJob) CheckResults() bool {
+	if j.OutputSchemas == nil && j.StdoutResults == nil {
+		return true
+	}
+	// the last line of the process logs has the results
+	j.waitForContainerLogs()
+	if !finishResults(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.StdoutResults, j.OutputSchemas) {
+		j.setFailureClass(FailureInvalidResults)
+		return false
+	}
+	return true
+}
+
+func (j *SubprocessJob) CheckResults() bool {
+	if j.OutputSchemas == nil && j.StdoutResults == nil {
+		return true
+	}
+	if !finishResults(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.StdoutResults, j.OutputSchemas) {
+		j.FailureClass = FailureInvalidResults
+		storeInvalidResults(j.logger, j.DB, j.UUID)
+		return false
+	}
+	return true
+}
+
+func (j *AWSBatchJob) CheckResults() bool {
+	if j.OutputSchemas == nil && j.StdoutResults == nil {
+		return true
+	}
+	// process logs are copied from CloudWatch on demand
+	if err := j.UpdateProcessLogs(); err != nil {
+		j.logger.Warnf("Could not update process logs to check results. Error: %s", err.Error())
+	}
+	if !finishResults(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.StdoutResults, j.OutputSchemas) {
+		storeInvalidResults(j.logger, j.DB, j.UUID)
+		return false
+	}
+	return true
+}
+
+func (j *PluginJob) CheckResults() bool {
+	if j.OutputSchemas == nil && j.StdoutResults == nil {
+		return true
+	}
+	if err := j.UpdateProcessLogs(); err != nil {
+		j.logger.Warnf("Could not update process logs to check results. Error: %s", err.Error())
+	}
+	if !finishResults(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.StdoutResults, j.OutputSchemas) {
+		storeInvalidResults(j.logger, j.DB, j.UUID)
+		return false
+	}
+	return true
+}
+
+func storeInvalidResults(logger *log.Logger, db Database, jid string) {
+	if err := db.updateFailureClass(jid, FailureInvalidResults); err != nil {
+		logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"app/config"
+	"app/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Lines from the end of stdout searched for the results document, and the max lines of a document
+const stdoutResultsMaxLines = 500
+
+// StdoutResults captures the results of jobs from the stdout of their process when they succeed,
+// they are stored at ResultsStorageKey instead of being read from the process logs when requested.
+type StdoutResults struct {
+	// Prefix of the line with the results, the rest of the line is the JSON document.
+	// The last JSON document written to stdout if empty, it can span several lines.
+	Marker string
+}
+
+// ResultsStorageKey returns the storage key of the results of a job captured from stdout
+func ResultsStorageKey(jid, tenant string) string {
+	return StorageKey(config.Get().Storage.ResultsPrefix, tenant, jid+".json")
+}
+
+// Read the results document from the process logs of a job
+func captureStdoutResults(svc *s3.S3, jid, tenant, marker string) (interface{}, error) {
+	r, err := OpenLogFile(svc, jid, tenant, "process")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var markerLine string
+	var found bool
+	tail := make([]string, 0, stdoutResultsMaxLines)
+	err = readLogLines(r, func(line string) {
+		if marker != "" {
+			if rest, ok := strings.CutPrefix(line, marker); ok {
+				markerLine, found = rest, true
+			}
+			return
+		}
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+		if len(tail) == stdoutResultsMaxLines {
+			tail = append(tail[:0], tail[1:]...)
+		}
+		tail = append(tail, line)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results interface{}
+	if marker != "" {
+		if !found {
+			return nil, fmt.Errorf("no line starting with %q", marker)
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(markerLine)), &results); err != nil {
+			return nil, fmt.Errorf("line starting with %q is not a JSON document: %s", marker, err.Error())
+		}
+		return results, nil
+	}
+
+	// The document ends on the last line ending a JSON object or array that parses from a line starting one
+	for end := len(tail) - 1; end >= 0; end-- {
+		if last := strings.TrimSpace(tail[end]); !strings.HasSuffix(last, "}") && !strings.HasSuffix(last, "]") {
+			continue
+		}
+		for start := end; start >= 0; start-- {
+			if first := strings.TrimSpace(tail[start]); !strings.HasPrefix(first, "{") && !strings.HasPrefix(first, "[") {
+				continue
+			}
+			if err := json.Unmarshal([]byte(strings.Join(tail[start:end+1], "\n")), &results); err == nil {
+				return results, nil
+			}
+		}
+	}
+	return nil, errors.New("no JSON document in stdout")
+}
+
+// Capture the results of a job whose process exited successfully if sr is set, validate and store them.
+// Results the job pushed with its callback are validated instead of capturing them.
+// Logs why results are invalid or could not be captured and returns false.
+func finishResults(logger *log.Logger, svc *s3.S3, jid, tenant string, sr *StdoutResults, validate ResultsValidator) bool {
+	if sr == nil || resultsStored(svc, jid, tenant) {
+		return resultsValid(logger, svc, jid, tenant, validate)
+	}
+
+	results, err := captureStdoutResults(svc, jid, tenant, sr.Marker)
+	if err != nil {
+		logger.Errorf("Could not capture results from stdout: %s", err.Error())
+		return false
+	}
+	if validate != nil {
+		if err := validate(results); err != nil {
+			logger.Errorf("Results do not match the schemas of the outputs: %s", err.Error())
+			return false
+		}
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		logger.Errorf("Could not encode results: %s", err.Error())
+		return false
+	}
+	if err := utils.WriteToS3(svc, b, ResultsStorageKey(jid, tenant), "application/json", 0); err != nil {
+		logger.Errorf("Could not store results: %s", err.Error())
+		return false
+	}
+	logger.Info("Results captured from stdout.")
+	return true
+}
@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"app/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Path the scratch directory of docker jobs is mounted at
+const ScratchMountPath = "/workspace"
+
+// Env variable with the path of the scratch directory, ScratchMountPath for docker jobs and the host path for subprocess jobs
+const ScratchEnvVar = "SEPEX_SCRATCH_DIR"
+
+// Path the inputs file of docker jobs of processes with inputDelivery file is mounted at, read-only
+const InputsMountPath = "/sepex/inputs.json"
+
+// Env variable with the path of the inputs file, InputsMountPath for docker jobs and the host path for subprocess jobs
+const InputsEnvVar = "SEPEX_INPUTS_FILE"
+
+// Directory scratch directories of jobs are created in, as seen by the server
+func scratchRoot() string {
+	if dir := config.Get().Jobs.ScratchDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "sepex-scratch")
+}
+
+// Scratch space in MB reserved by jobs with a disk resource, by job ID, until their scratch directory is removed
+var scratchReservations = struct {
+	sync.Mutex
+	mb map[string]int
+}{mb: map[string]int{}}
+
+// Create an empty scratch directory for a job, only accessible by the server's user, or by owner if it is a numeric
+// uid[:gid] of the user the process runs as. Users given by name are resolved in the image, their directory stays the
+// server's, root in a container is not restricted by the permissions.
+// If diskMB is set, the filesystem must have that much space available besides the space reserved by other jobs,
+// the space is reserved until the directory is removed.
+func createScratchDir(jid string, diskMB int, owner string) (string, error) {
+	dir := filepath.Join(scratchRoot(), jid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create scratch directory: %s", err.Error())
+	}
+	mode := os.FileMode(0700)
+	if uid, gid, ok := parseOwner(owner); ok {
+		if err := os.Chown(dir, uid, gid); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("could not give scratch directory to user %s: %s", owner, err.Error())
+		}
+		if gid >= 0 {
+			mode = 0770
+		}
+	}
+	if err := os.Chmod(dir, mode); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("could not create scratch directory: %s", err.Error())
+	}
+
+	if diskMB > 0 {
+		scratchReservations.Lock()
+		defer scratchReservations.Unlock()
+		availableBytes, err := availableDiskBytes(dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("could not check available disk space: %s", err.Error())
+		}
+		reserved := reservedScratchMB()
+		available := int(availableBytes/(1024*1024)) - reserved
+		if available < diskMB {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("insufficient disk space for scratch directory, %dMB required, %dMB available (%dMB reserved by running jobs)", diskMB, max(available, 0), reserved)
+		}
+		scratchReservations.mb[jid] = diskMB
+	}
+	return dir, nil
+}
+
+// Space reserved by other jobs that they did not use yet, used space is no longer available on the filesystem.
+// Must be called with scratchReservations locked.
+func reservedScratchMB() int {
+	reserved := 0
+	for jid, mb := range scratchReservations.mb {
+		used, err := dirSize(filepath.Join(scratchRoot(), jid))
+		if err != nil {
+			used = 0
+		}
+		if rest := mb - int(used/(1024*1024)); rest > 0 {
+			reserved += rest
+		}
+	}
+	return reserved
+}
+
+// Numeric uid and gid of a user "uid[:gid]", gid is -1 without group. ok is false for empty users and names.
+func parseOwner(user string) (uid, gid int, ok bool) {
+	if user == "" {
+		return 0, 0, false
+	}
+	u, g, hasGroup := strings.Cut(user, ":")
+	uid, err := strconv.Atoi(u)
+	if err != nil {
+		return 0, 0, false
+	}
+	gid = -1
+	if hasGroup {
+		if gid, err = strconv.Atoi(g); err != nil {
+			return 0, 0, false
+		}
+	}
+	return uid, gid, true
+}
+
+// Path of a scratch directory as seen by the docker daemon. SCRATCH_HOST_DIR is needed when the server itself
+// runs in a container and SCRATCH_DIR is a mount of another host directory.
+func scratchHostPath(dir string) string {
+	hostRoot := config.Get().Jobs.ScratchHostDir
+	if hostRoot == "" {
+		return dir
+	}
+	return filepath.Join(hostRoot, strings.TrimPrefix(dir, scratchRoot()))
+}
+
+// Size of the scratch directory of a job in bytes, measured when its process exited and recorded in its metadata.
+// 0 if it was not created or could not be measured.
+func scratchDirSize(logger *log.Logger, dir string) int64 {
+	if dir == "" {
+		return 0
+	}
+	size, err := dirSize(dir)
+	if err != nil {
+		logger.Warnf("Could not measure scratch directory. Error: %s", err.Error())
+		return 0
+	}
+	logger.Infof("Scratch directory used %.1fMB.", float64(size)/(1024*1024))
+	return size
+}
+
+// Remove the scratch directory of a job, no-op if it was not created
+func removeScratchDir(logger *log.Logger, dir string) {
+	if dir == "" {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Errorf("Could not remove scratch directory. Error: %s", err.Error())
+	}
+	scratchReservations.Lock()
+	delete(scratchReservations.mb, filepath.Base(dir))
+	scratchReservations.Unlock()
+}
+
+// ScratchEntry is a scratch directory or inputs file in the scratch root
+type ScratchEntry struct {
+	Name     string
+	JobID    string
+	Modified time.Time
+}
+
+// ScratchEntries lists the scratch directories and inputs files of jobs, none if no scratch directory was created yet
+func ScratchEntries() ([]ScratchEntry, error) {
+	entries, err := os.ReadDir(scratchRoot())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	list := make([]ScratchEntry, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue // removed since
+		}
+		// <jobID> directories and <jobID>.inputs.json files
+		jid, _, _ := strings.Cut(e.Name(), ".")
+		list = append(list, ScratchEntry{Name: e.Name(), JobID: jid, Modified: info.ModTime()})
+	}
+	return list, nil
+}
+
+// RemoveScratchEntry removes an entry listed by ScratchEntries
+func RemoveScratchEntry(name string) error {
+	return os.RemoveAll(filepath.Join(scratchRoot(), filepath.Base(name)))
+}
+
+// Write the inputs document of a job next to its scratch directory, so that the scratch directory starts empty
+func writeInputsFile(jid string, inputs []byte) (string, error) {
+	path := filepath.Join(scratchRoot(), jid+".inputs.json")
+	// Processes may run as any user
+	if err := os.WriteFile(path, inputs, 0644); err != nil {
+		return "", fmt.Errorf("could not write inputs file: %s", err.Error())
+	}
+	return path, nil
+}
+
+// Remove the inputs file of a job, no-op if it was not written
+func removeInputsFile(logger *log.Logger, path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("Could not remove inputs file. Error: %s", err.Error())
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"app/controllers"
+	"context"
+	"fmt"
+)
+
+// EnvVarFrom is an environment variable Name whose value is read from secret Ref in Source when the job starts.
+// If Key is set the secret is a JSON object and the value of Key is used.
+type EnvVarFrom struct {
+	Name   string
+	Source string
+	Ref    string
+	Key    string
+}
+
+// Resolve secrets to NAME=value pairs. Values must only be passed to the process, never logged or stored.
+func resolveEnvVarsFrom(ctx context.Context, refs []EnvVarFrom) ([]string, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	sc, err := controllers.NewSecretsControllerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]string, len(refs))
+	for i, r := range refs {
+		v, err := sc.GetSecret(ctx, r.Source, r.Ref, r.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve env variable %s from %s %s: %s", r.Name, r.Source, r.Ref, err.Error())
+		}
+		envs[i] = r.Name + "=" + v
+	}
+	return envs, nil
+}
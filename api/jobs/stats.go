@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// JobTiming is the submission and last update time of a job.
+// Created is zero for jobs submitted before submission times were recorded.
+type JobTiming struct {
+	ProcessID    string
+	Submitter    string
+	Status       string
+	FailureClass string
+	Created      time.Time
+	Updated      time.Time
+}
+
+// Duration of a terminated job, false if job is not terminated or its submission time is unknown
+func (jt JobTiming) duration() (time.Duration, bool) {
+	switch jt.Status {
+	case SUCCESSFUL, FAILED, DISMISSED:
+	default:
+		return 0, false
+	}
+	if jt.Created.IsZero() || jt.Updated.Before(jt.Created) {
+		return 0, false
+	}
+	return jt.Updated.Sub(jt.Created), true
+}
+
+// JobStats are aggregated metrics of a set of jobs.
+// Durations are from submission to termination (including time in queue) in seconds.
+type JobStats struct {
+	Total       int            `json:"total"`
+	Counts      map[string]int `json:"counts"`
+	FailureRate float64        `json:"failureRate"` // failed / terminated, 0 if none terminated
+	DurationP50 float64        `json:"durationP50"`
+	DurationP95 float64        `json:"durationP95"`
+	DurationAvg float64        `json:"durationAvg"`
+	// Number of failed jobs per failure class
+	FailureClasses map[string]int `json:"failureClasses"`
+
+	durations []float64
+}
+
+func newJobStats() JobStats {
+	return JobStats{
+		Counts:         map[string]int{ACCEPTED: 0, RUNNING: 0, SUCCESSFUL: 0, FAILED: 0, DISMISSED: 0},
+		FailureClasses: map[string]int{},
+	}
+}
+
+func (s *JobStats) add(jt JobTiming) {
+	s.Total++
+	s.Counts[jt.Status]++
+	if jt.Status == FAILED {
+		class := jt.FailureClass
+		if class == "" {
+			class = FailureUnknown
+		}
+		s.FailureClasses[class]++
+	}
+	if d, ok := jt.duration(); ok {
+		s.durations = append(s.durations, d.Seconds())
+	}
+}
+
+// Compute derived metrics once all jobs are added
+func (s *JobStats) finalize() {
+	if terminated := s.Counts[SUCCESSFUL] + s.Counts[FAILED] + s.Counts[DISMISSED]; terminated > 0 {
+		s.FailureRate = float64(s.Counts[FAILED]) / float64(terminated)
+	}
+
+	if len(s.durations) == 0 {
+		return
+	}
+	sort.Float64s(s.durations)
+	s.DurationP50 = percentile(s.durations, 50)
+	s.DurationP95 = percentile(s.durations, 95)
+	sum := 0.0
+	for _, d := range s.durations {
+		sum += d
+	}
+	s.DurationAvg = sum / float64(len(s.durations))
+	s.durations = nil
+}
+
+// Nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// ProcessStats are job metrics of a single process
+type ProcessStats struct {
+	ProcessID string `json:"processID"`
+	JobStats
+}
+
+// AggregateByProcess computes metrics per process, sorted by process ID.
+func AggregateByProcess(timings []JobTiming) []ProcessStats {
+	byProcess := make(map[string]*ProcessStats)
+	for _, jt := range timings {
+		ps, ok := byProcess[jt.ProcessID]
+		if !ok {
+			ps = &ProcessStats{ProcessID: jt.ProcessID, JobStats: newJobStats()}
+			byProcess[jt.ProcessID] = ps
+		}
+		ps.add(jt)
+	}
+
+	res := make([]ProcessStats, 0, len(byProcess))
+	for _, ps := range byProcess {
+		ps.finalize()
+		res = append(res, *ps)
+	}
+	sort.Slice(res, func(i, k int) bool { return res[i].ProcessID < res[k].ProcessID })
+	return res
+}
+
+// SubmitterCount is the number of jobs of a submitter
+type SubmitterCount struct {
+	Submitter string `json:"submitter"`
+	Count     int    `json:"count"`
+}
+
+// ProcessUsage summarizes how much a process is used, for capacity planning
+type ProcessUsage struct {
+	ProcessID   string         `json:"processID"`
+	Invocations int            `json:"invocations"`
+	Counts      map[string]int `json:"counts"`
+	SuccessRate float64        `json:"successRate"` // successful / terminated, 0 if none terminated
+	DurationAvg float64        `json:"durationAvg"`
+	// Submitters with the most jobs, most jobs first
+	TopSubmitters []SubmitterCount `json:"topSubmitters"`
+}
+
+// AggregateUsage computes usage per process with up to top submitters each, most invoked processes first.
+func AggregateUsage(timings []JobTiming, top int) []ProcessUsage {
+	stats := make(map[string]*JobStats)
+	submitters := make(map[string]map[string]int)
+	for _, jt := range timings {
+		s, ok := stats[jt.ProcessID]
+		if !ok {
+			js := newJobStats()
+			s = &js
+			stats[jt.ProcessID] = s
+			submitters[jt.ProcessID] = make(map[string]int)
+		}
+		s.add(jt)
+		submitters[jt.ProcessID][jt.Submitter]++
+	}
+
+	res := make([]ProcessUsage, 0, len(stats))
+	for pid, s := range stats {
+		s.finalize()
+		pu := ProcessUsage{ProcessID: pid, Invocations: s.Total, Counts: s.Counts, DurationAvg: s.DurationAvg}
+		if terminated := s.Counts[SUCCESSFUL] + s.Counts[FAILED] + s.Counts[DISMISSED]; terminated > 0 {
+			pu.SuccessRate = float64(s.Counts[SUCCESSFUL]) / float64(terminated)
+		}
+
+		pu.TopSubmitters = make([]SubmitterCount, 0, len(submitters[pid]))
+		for sub, n := range submitters[pid] {
+			pu.TopSubmitters = append(pu.TopSubmitters, SubmitterCount{Submitter: sub, Count: n})
+		}
+		sort.Slice(pu.TopSubmitters, func(i, k int) bool {
+			if pu.TopSubmitters[i].Count != pu.TopSubmitters[k].Count {
+				return pu.TopSubmitters[i].Count > pu.TopSubmitters[k].Count
+			}
+			return pu.TopSubmitters[i].Submitter < pu.TopSubmitters[k].Submitter
+		})
+		if len(pu.TopSubmitters) > top {
+			pu.TopSubmitters = pu.TopSubmitters[:top]
+		}
+		res = append(res, pu)
+	}
+	sort.Slice(res, func(i, k int) bool {
+		if res[i].Invocations != res[k].Invocations {
+			return res[i].Invocations > res[k].Invocations
+		}
+		return res[i].ProcessID < res[k].ProcessID
+	})
+	return res
+}
+
+// SeriesPoint are metrics of jobs last updated in the interval starting at Time
+type SeriesPoint struct {
+	Time time.Time `json:"time"`
+	JobStats
+}
+
+// AggregateSeries buckets jobs by last update time into intervals from since to until.
+// Every interval is present in the result, including empty ones, so that series have no gaps.
+func AggregateSeries(timings []JobTiming, since, until time.Time, interval time.Duration) []SeriesPoint {
+	start := since.Truncate(interval)
+	n := int(until.Sub(start)/interval) + 1
+
+	points := make([]SeriesPoint, n)
+	for i := range points {
+		points[i] = SeriesPoint{Time: start.Add(time.Duration(i) * interval), JobStats: newJobStats()}
+	}
+
+	for _, jt := range timings {
+		i := int(jt.Updated.Sub(start) / interval)
+		if i < 0 || i >= n {
+			continue
+		}
+		points[i].add(jt)
+	}
+
+	for i := range points {
+		points[i].finalize()
+	}
+	return points
+}
@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"app/config"
+	"app/controllers"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Env variable with the location jobs with scoped storage credentials write their files to, s3://<bucket>/<prefix>/
+const ResultsPrefixEnvVar = "SEPEX_RESULTS_PREFIX"
+
+// Max length of session policies, STS rejects longer policies
+const maxSessionPolicyLength = 2048
+
+// StorageAccess is what the scoped storage credentials of a job allow, reading the objects its inputs reference and
+// reading and writing objects below its own results prefix. Credentials are issued when docker, subprocess and plugin
+// jobs start, aws-batch jobs use the job role of their job definition instead.
+type StorageAccess struct {
+	// Objects referenced by s3:// URLs in the inputs by bucket
+	ReadObjects map[string][]string
+	// Bucket and key prefix ending with / of the results prefix of the job
+	Bucket      string
+	WritePrefix string
+	// Tenant of the job, objects of the storage bucket are only readable below <tenant>/
+	tenant string
+}
+
+// NewStorageAccess returns the storage access of job jid of tenant, the results prefix of
+// the job is <STORAGE_RESULTS_PREFIX>/<jobID>/.
+func NewStorageAccess(jid, tenant string, inputs json.RawMessage) *StorageAccess {
+	a := &StorageAccess{
+		ReadObjects: map[string][]string{},
+		Bucket:      config.Get().Storage.Bucket,
+		WritePrefix: StorageKey(config.Get().Storage.ResultsPrefix, tenant, jid) + "/",
+		tenant:      tenant,
+	}
+	var v interface{}
+	if err := json.Unmarshal(inputs, &v); err == nil {
+		a.addReferences(v)
+	}
+	for bucket, keys := range a.ReadObjects {
+		sort.Strings(keys)
+		a.ReadObjects[bucket] = keys
+	}
+	return a
+}
+
+// Add objects of s3:// URLs in v, including hrefs of links and URLs in arrays and objects.
+// Keys are put in the ARNs of the policy as they are, keys with IAM wildcards or policy variables and keys ending with /
+// would grant more than one object and are not granted. Neither are objects of the storage bucket outside of the
+// tenant's files, which hold files of other tenants.
+func (a *StorageAccess) addReferences(v interface{}) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, e := range vv {
+			a.addReferences(e)
+		}
+	case []interface{}:
+		for _, e := range vv {
+			a.addReferences(e)
+		}
+	case string:
+		bucket, key, ok := strings.Cut(strings.TrimPrefix(vv, "s3://"), "/")
+		// buckets are not granted as a whole
+		if !strings.HasPrefix(vv, "s3://") || !ok || bucket == "" || key == "" {
+			return
+		}
+		if strings.ContainsAny(key, "*?") || strings.Contains(key, "${") || strings.HasSuffix(key, "/") {
+			return
+		}
+		if bucket == a.Bucket && a.tenant != "" && !strings.HasPrefix(key, a.tenant+"/") {
+			return
+		}
+		for _, k := range a.ReadObjects[bucket] {
+			if k == key {
+				return
+			}
+		}
+		a.ReadObjects[bucket] = append(a.ReadObjects[bucket], key)
+	}
+}
+
+type policyStatement struct {
+	Effect    string                 `json:"Effect"`
+	Action    []string               `json:"Action"`
+	Resource  []string               `json:"Resource"`
+	Condition map[string]interface{} `json:"Condition,omitempty"`
+}
+
+// Session policy of the access, an IAM policy document. Fails if it is too long for STS, e.g. when inputs reference
+// too many objects.
+func (a *StorageAccess) policy() (string, error) {
+	statements := []policyStatement{
+		{
+			Effect:   "Allow",
+			Action:   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+			Resource: []string{fmt.Sprintf("arn:aws:s3:::%s/%s*", a.Bucket, a.WritePrefix)},
+		},
+		{
+			Effect:    "Allow",
+			Action:    []string{"s3:ListBucket"},
+			Resource:  []string{"arn:aws:s3:::" + a.Bucket},
+			Condition: map[string]interface{}{"StringLike": map[string][]string{"s3:prefix": {a.WritePrefix + "*"}}},
+		},
+	}
+
+	buckets := make([]string, 0, len(a.ReadObjects))
+	for bucket := range a.ReadObjects {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+	var objects []string
+	for _, bucket := range buckets {
+		for _, key := range a.ReadObjects[bucket] {
+			objects = append(objects, fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, key))
+		}
+	}
+	if len(objects) > 0 {
+		statements = append(statements, policyStatement{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: objects})
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"Version": "2012-10-17", "Statement": statements})
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxSessionPolicyLength {
+		return "", fmt.Errorf("inputs reference too many objects for scoped storage credentials, policy has %d characters, at most %d are allowed", len(b), maxSessionPolicyLength)
+	}
+	return string(b), nil
+}
+
+// Issue scoped storage credentials of job jid and return them as NAME=value env variables, with the region and
+// for MinIO the endpoint of the storage service. None if a is nil.
+func (a *StorageAccess) envVars(ctx context.Context, jid string) ([]string, error) {
+	if a == nil {
+		return nil, nil
+	}
+	policy, err := a.policy()
+	if err != nil {
+		return nil, err
+	}
+	sc, err := controllers.NewStorageCredentialsControllerFromConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not issue scoped storage credentials: %s", err.Error())
+	}
+	creds, err := sc.Issue(ctx, "sepex-"+jid, policy)
+	if err != nil {
+		return nil, fmt.Errorf("could not issue scoped storage credentials: %s", err.Error())
+	}
+
+	cfg := config.Get()
+	region := cfg.AWS.Region
+	envs := []string{
+		"AWS_ACCESS_KEY_ID=" + creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + creds.SessionToken,
+		ResultsPrefixEnvVar + "=" + fmt.Sprintf("s3://%s/%s", a.Bucket, a.WritePrefix),
+	}
+	if cfg.Storage.Service == "minio" {
+		region = cfg.MinIO.Region
+		envs = append(envs, "AWS_ENDPOINT_URL_S3="+cfg.MinIO.Endpoint)
+	}
+	if region != "" {
+		envs = append(envs, "AWS_REGION="+region)
+	}
+	return envs, nil
+}
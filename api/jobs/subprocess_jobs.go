@@ -1,13 +1,13 @@
 package jobs
 
 import (
-	"app/utils"
+	"app/config"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"strings"
 	"sync"
 	"time"
 
@@ -30,22 +30,90 @@ type SubprocessJob struct {
 	ProcessName    string `json:"processID"`
 	ProcessVersion string `json:"processVersion"`
 	Submitter      string
-	EnvVars        []string
-	Cmd            []string `json:"commandOverride"`
+	Tenant         string
+	RequestID      string // ID of the API request that created the job
+	RerunOf        string // ID of the job this job re-runs
+	LogLevel       string // level of server logs and LOG_LEVEL of the process, LOG_LEVEL of the server if empty
+	ParentID       string // ID of the pipeline or fan-out job this job is a step of
+	ErrorPatterns  []ErrorPattern
+	OutputTypes    map[string]string // media types declared by outputs of the process, set on objects referenced by results
+	OutputSchemas  ResultsValidator  // validates results before the job succeeds, nil if no output declares a schema
+	StdoutResults  *StdoutResults    // captures results from stdout when the job succeeds, nil reads them from the process logs
+	Callback       *Callback         // results callback of the job, its env variables are set if not nil
+	StorageAccess  *StorageAccess    // scoped storage credentials are issued when the job starts if not nil
+	FailureClass   string
+	ExitCode       *int // nil until the process exited
+	EnvVars        []EnvVar
+	EnvVarsFrom    []EnvVarFrom      // resolved when the job starts
+	EnvOverrides   map[string]string // set by the execute request
+	ScratchDir     string            // created by Create, removed by Close once the process exited
+	ScratchBytes   int64             // size of ScratchDir when the process exited
+	InputsFile     json.RawMessage   // inputs of processes with inputDelivery file, written by Create and its path is passed in InputsEnvVar
+	inputsPath     string            // path of the written inputs file, as seen by the server
+	Cmd            []string          `json:"commandOverride"`
 	UpdateTime     time.Time
 	Status         string `json:"status"`
 
 	execCmd *exec.Cmd
+	// When the process was started and exited, recorded in metadata
+	startTime time.Time
+	endTime   time.Time
 
-	logger  *log.Logger
-	logFile *os.File
+	logger         *log.Logger
+	logFile        *os.File
+	logBroadcaster *LogBroadcaster
 
 	Resources
 	DB           Database
+	Events       *EventBus
 	StorageSvc   *s3.S3
-	DoneChan     chan Job
+	ActiveJobs   *ActiveJobs
 	ResourcePool *ResourcePool
 	IsSync       bool
+	// Time between SIGTERM and SIGKILL when the job is dismissed, 0 kills immediately
+	StopGracePeriod time.Duration
+}
+
+func init() {
+	RegisterBackend("subprocess", subprocessBackend{})
+}
+
+// Runs jobs as processes of the server, async jobs wait for local resources in the queue
+type subprocessBackend struct{}
+
+func (subprocessBackend) Queued() bool { return true }
+
+func (subprocessBackend) NewJob(spec JobSpec, svc JobServices) (Job, error) {
+	return &SubprocessJob{
+		UUID:            spec.JobID,
+		ProcessName:     spec.ProcessID,
+		Submitter:       spec.Submitter,
+		Tenant:          spec.Tenant,
+		RequestID:       spec.RequestID,
+		RerunOf:         spec.RerunOf,
+		ParentID:        spec.ParentID,
+		LogLevel:        spec.LogLevel,
+		EnvVars:         spec.EnvVars,
+		EnvVarsFrom:     spec.EnvVarsFrom,
+		EnvOverrides:    spec.EnvOverrides,
+		Cmd:             spec.Cmd,
+		InputsFile:      spec.InputsFile,
+		ProcessVersion:  spec.ProcessVersion,
+		Resources:       spec.Resources,
+		ErrorPatterns:   spec.ErrorPatterns,
+		OutputTypes:     spec.OutputTypes,
+		OutputSchemas:   spec.OutputSchemas,
+		StdoutResults:   spec.StdoutResults,
+		Callback:        spec.Callback,
+		StorageAccess:   spec.StorageAccess,
+		StorageSvc:      svc.StorageSvc,
+		DB:              svc.DB,
+		Events:          svc.Events,
+		ActiveJobs:      svc.ActiveJobs,
+		ResourcePool:    svc.ResourcePool,
+		IsSync:          spec.IsSync,
+		StopGracePeriod: spec.StopGracePeriod,
+	}, nil
 }
 
 func (j *SubprocessJob) WaitForRunCompletion() {
@@ -68,6 +136,14 @@ func (j *SubprocessJob) SUBMITTER() string {
 	return j.Submitter
 }
 
+func (j *SubprocessJob) TENANT() string {
+	return j.Tenant
+}
+
+func (j *SubprocessJob) PARENT() string {
+	return j.ParentID
+}
+
 func (j *SubprocessJob) CMD() []string {
 	return j.Cmd
 }
@@ -113,6 +189,7 @@ func (j *SubprocessJob) NewStatusUpdate(status string, updateTime time.Time) {
 	}
 	j.DB.updateJobRecord(j.UUID, status, j.UpdateTime)
 	j.logger.Infof("Status changed to %s.", status)
+	publishStatusEvent(j.Events, j, status, j.UpdateTime)
 }
 
 func (j *SubprocessJob) CurrentStatus() string {
@@ -134,7 +211,12 @@ func (j *SubprocessJob) Equals(job Job) bool {
 
 func (j *SubprocessJob) initLogger() error {
 	// Create a place holder file for subprocess logs
-	file, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err := os.Create(LocalLogPath(j.UUID, "process"))
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err.Error())
+	}
+	file.Close()
+	file, err = os.Create(LocalLogPath(j.UUID, "stderr"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
@@ -143,17 +225,18 @@ func (j *SubprocessJob) initLogger() error {
 	// Create logger for server logs
 	j.logger = log.New()
 
-	file, err = os.Create(fmt.Sprintf("%s/%s.server.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	file, err = os.Create(LocalLogPath(j.UUID, "server"))
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %s", err.Error())
 	}
 
-	j.logger.SetOutput(file)
+	j.logger.SetOutput(ServerLogWriter(file))
 	j.logger.SetFormatter(&log.JSONFormatter{})
+	j.logger.AddHook(newJobFieldsHook(j.UUID, j.RequestID))
 
-	lvl, err := log.ParseLevel(os.Getenv("LOG_LEVEL"))
+	lvl, err := log.ParseLevel(jobLogLevel(j.LogLevel))
 	if err != nil {
-		j.logger.Warnf("Invalid LOG_LEVEL set, %s; defaulting to INFO", os.Getenv("LOG_LEVEL"))
+		j.logger.Warnf("Invalid LOG_LEVEL set, %s; defaulting to INFO", jobLogLevel(j.LogLevel))
 		lvl = log.InfoLevel
 	}
 	j.logger.SetLevel(lvl)
@@ -164,7 +247,7 @@ func (j *SubprocessJob) Create() error {
 	// Only reserve resources for sync jobs at creation time
 	// Async jobs will have resources reserved when QueueWorker starts them
 	if j.IsSync {
-		if !j.ResourcePool.TryReserve(j.Resources.CPUs, j.Resources.Memory) {
+		if !j.ResourcePool.TryReserve(j.Tenant, j.Resources.CPUs, j.Resources.Memory) {
 			return fmt.Errorf("resources unavailable")
 		}
 	}
@@ -173,7 +256,11 @@ func (j *SubprocessJob) Create() error {
 	success := false
 	defer func() {
 		if !success && j.IsSync {
-			j.ResourcePool.Release(j.Resources.CPUs, j.Resources.Memory)
+			j.ResourcePool.Release(j.Tenant, j.Resources.CPUs, j.Resources.Memory)
+		}
+		if !success {
+			removeScratchDir(j.logger, j.ScratchDir)
+			removeInputsFile(j.logger, j.inputsPath)
 		}
 	}()
 
@@ -183,12 +270,25 @@ func (j *SubprocessJob) Create() error {
 	}
 	j.logger.Info("Subprocess Commands: ", j.CMD())
 
+	// Created here rather than in Run so that Close always sees it, even if the job is dismissed while starting
+	j.ScratchDir, err = createScratchDir(j.UUID, j.Resources.Disk, "")
+	if err != nil {
+		return err
+	}
+	if j.InputsFile != nil {
+		j.inputsPath, err = writeInputsFile(j.UUID, j.InputsFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.TODO())
 	j.ctx = ctx
 	j.ctxCancel = cancelFunc
+	j.logBroadcaster = NewLogBroadcaster()
 
 	// At this point job is ready to be added to database
-	err = j.DB.addJob(j.UUID, "accepted", "", "local", j.ProcessName, j.Submitter, time.Now())
+	err = j.DB.addJob(j.UUID, "accepted", "", "local", j.ProcessName, j.Submitter, j.Tenant, j.RequestID, j.ParentID, time.Now())
 	if err != nil {
 		j.ctxCancel()
 		return err
@@ -221,69 +321,144 @@ func (j *SubprocessJob) Run() {
 			j.logger.Errorf("Run() panicked: %v", r)
 			j.NewStatusUpdate(FAILED, time.Time{})
 		}
-		j.ResourcePool.Release(j.Resources.CPUs, j.Resources.Memory)
+		j.ResourcePool.Release(j.Tenant, j.Resources.CPUs, j.Resources.Memory)
 		j.Close()
 		j.wgRun.Done()
 	}()
 
 	// Prepare the command
 	j.execCmd = exec.CommandContext(j.ctx, j.Cmd[0], j.Cmd[1:]...)
+	if j.StopGracePeriod > 0 {
+		// On dismiss send SIGTERM instead of SIGKILL, Wait kills the process if it is still running after the grace period
+		j.execCmd.Cancel = func() error { return terminateProcess(j.execCmd.Process) }
+		j.execCmd.WaitDelay = j.StopGracePeriod
+	}
 
-	envs := make([]string, len(j.EnvVars))
-	for i, k := range j.EnvVars {
-		name := strings.TrimPrefix(k, strings.ToUpper(j.ProcessName)+"_")
-		envs[i] = name + "=" + os.Getenv(k)
+	envs := resolveEnvVars(j.EnvVars)
+	secretEnvs, err := resolveEnvVarsFrom(j.ctx, j.EnvVarsFrom)
+	if err != nil {
+		j.logger.Errorf("Failed to resolve secrets. Error: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+	envs = append(envs, secretEnvs...)
+	if j.LogLevel != "" {
+		envs = append(envs, LogLevelEnvVar+"="+j.LogLevel)
+	}
+	for k, v := range j.EnvOverrides {
+		envs = append(envs, k+"="+v)
+	}
+	envs = append(envs, ScratchEnvVar+"="+j.ScratchDir)
+	if j.inputsPath != "" {
+		envs = append(envs, InputsEnvVar+"="+j.inputsPath)
+	}
+	envs = append(envs, j.Callback.envVars()...)
+	storageEnvs, err := j.StorageAccess.envVars(j.ctx, j.UUID)
+	if err != nil {
+		j.logger.Errorf("Failed to issue storage credentials. Error: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
 	}
+	envs = append(envs, storageEnvs...)
 	j.execCmd.Env = envs
 	j.logger.Debugf("Registered %v env vars", len(envs))
 
 	// Create a new file or overwrite if it exists
-	logFile, err := os.Create(fmt.Sprintf("%s/%s.process.jsonl", os.Getenv("TMP_JOB_LOGS_DIR"), j.UUID))
+	logFile, err := os.Create(LocalLogPath(j.UUID, "process"))
 	if err != nil {
 		j.logger.Errorf("Failed to create log file: %s", err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
 	defer logFile.Close()
+	stderrFile, err := os.Create(LocalLogPath(j.UUID, "stderr"))
+	if err != nil {
+		j.logger.Errorf("Failed to create log file: %s", err.Error())
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
+	defer stderrFile.Close()
 
-	// Redirect stdout and stderr to the log file
-	j.execCmd.Stdout = logFile
-	j.execCmd.Stderr = logFile
+	// Redirect stdout and stderr to separate log files, tee both through broadcaster for live log subscribers
+	j.execCmd.Stdout = io.MultiWriter(logFile, j.logBroadcaster)
+	j.execCmd.Stderr = io.MultiWriter(stderrFile, j.logBroadcaster)
 
-	// Start the command
+	// Start the command, logs are uploaded by Close() only after the process exited
+	// so that output written during the stop grace period is kept
+	j.wg.Add(1)
 	err = j.execCmd.Start()
 	if err != nil {
+		j.wg.Done()
 		j.logger.Errorf("Failed to start subprocess. Error: %s", err.Error())
 		j.NewStatusUpdate(FAILED, time.Time{})
 		return
 	}
+	j.startTime = time.Now()
 	j.PID = fmt.Sprintf("%d", j.execCmd.Process.Pid)
 	j.NewStatusUpdate(RUNNING, time.Time{})
 
-	// Check if job was cancelled (Kill() was called) before waiting for process
-	select {
-	case <-j.ctx.Done():
-		return
-	default:
-	}
-
-	// Wait for the process to finish
+	// Wait for the process to finish, if Kill() was called the process is signalled and Wait returns once it exited
 	err = j.execCmd.Wait()
+	j.endTime = time.Now()
+	j.recordExit()
+	j.wg.Done()
 	if err != nil {
-		if j.CurrentStatus() == DISMISSED {
+		// The process is signalled when the job is dismissed or failed by the job watchdog
+		if j.CurrentStatus() != RUNNING {
 			return
 		} else {
 			j.logger.Errorf("Subprocess failure. Error: %s", err.Error())
+			j.classifyFailure(j.execCmd.ProcessState.ExitCode())
 			j.NewStatusUpdate(FAILED, time.Time{})
 			return
 		}
 	}
 
 	j.logger.Info("Subprocess finished successfully.")
+	if !j.CheckResults() {
+		j.NewStatusUpdate(FAILED, time.Time{})
+		return
+	}
 	j.NewStatusUpdate(SUCCESSFUL, time.Time{})
 	go j.WriteMetaData()
 }
 
+// Store exit code of the process, -1 if it was terminated by a signal
+func (j *SubprocessJob) recordExit() {
+	if j.execCmd.ProcessState == nil {
+		return
+	}
+	exitCode := j.execCmd.ProcessState.ExitCode()
+	j.ExitCode = &exitCode
+	j.ScratchBytes = scratchDirSize(j.logger, j.ScratchDir)
+	if err := j.DB.updateExitDetail(j.UUID, exitCode, false); err != nil {
+		j.logger.Errorf("Could not store exit code. Error: %s", err.Error())
+	}
+}
+
+// ExitDetail returns exit code of the process, nil if it has not exited. Subprocesses are never reported as OOM killed
+func (j *SubprocessJob) ExitDetail() (*int, bool) {
+	return j.ExitCode, false
+}
+
+// Classify failure from exit code and tail of process logs, and store it
+func (j *SubprocessJob) classifyFailure(exitCode int) {
+	// stderr is checked first since patterns are matched from the last line
+	logTail := append(tailFile(LocalLogPath(j.UUID, "process"), classifyLogTail),
+		tailFile(LocalLogPath(j.UUID, "stderr"), classifyLogTail)...)
+
+	j.FailureClass = ClassifyFailure(exitCode, false, logTail, j.ErrorPatterns)
+	j.logger.Infof("Failure classified as: %s", j.FailureClass)
+	if err := j.DB.updateFailureClass(j.UUID, j.FailureClass); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+}
+
+// FailureClassification returns why the job failed, empty if not classified
+func (j *SubprocessJob) FailureClassification() string {
+	return j.FailureClass
+}
+
 // Kill subprocess
 func (j *SubprocessJob) Kill() error {
 	j.logger.Info("Received dismiss signal.")
@@ -306,38 +481,64 @@ func (j *SubprocessJob) Kill() error {
 	return nil
 }
 
-// Write metadata at the job's metadata location
+// LastProcessLog returns when the process last wrote a log line
+func (j *SubprocessJob) LastProcessLog() time.Time {
+	return j.logBroadcaster.LastPublished()
+}
+
+// ReconcileStatus has nothing to reconcile, Run waits for the process and updates the status when it exits
+func (j *SubprocessJob) ReconcileStatus() (string, error) {
+	return "", nil
+}
+
+// Fail signals the process of a stuck job like a dismiss and updates the status of the job to failed
+func (j *SubprocessJob) Fail(class, reason string) error {
+	switch j.CurrentStatus() {
+	case SUCCESSFUL, FAILED, DISMISSED:
+		return fmt.Errorf("can't fail an already completed, failed, or dismissed job")
+	}
+
+	j.logger.Errorf("Failing job: %s.", reason)
+	j.FailureClass = class
+	if err := j.DB.updateFailureClass(j.UUID, class); err != nil {
+		j.logger.Errorf("Could not store failure class. Error: %s", err.Error())
+	}
+	j.NewStatusUpdate(FAILED, time.Time{})
+	j.ctxCancel()
+	go j.Close()
+	return nil
+}
+
+// Write metadata at the job's metadata location and set the content types of objects referenced by its results
 func (j *SubprocessJob) WriteMetaData() {
 	j.logger.Info("Starting metadata writing routine.")
 	j.wg.Add(1)
 	defer j.wg.Done()
 	defer j.logger.Info("Finished metadata writing routine.")
 
-	p := process{j.ProcessID(), j.ProcessVersionID()}
-	repoURL := os.Getenv("REPO_URL")
-
-	md := metaData{
-		Context:         fmt.Sprintf("%s/blob/main/context.jsonld", repoURL),
-		JobID:           j.UUID,
-		Process:         p,
-		Commands:        j.Cmd,
-		GeneratedAtTime: j.UpdateTime,
-		StartedAtTime:   j.UpdateTime,
-		EndedAtTime:     j.UpdateTime,
-	}
-
-	jsonBytes, err := json.Marshal(md)
+	err := writeProvDocument(j.StorageSvc, j.DB, provRecord{
+		JobID:          j.UUID,
+		ProcessID:      j.ProcessID(),
+		ProcessVersion: j.ProcessVersionID(),
+		Submitter:      j.Submitter,
+		Tenant:         j.Tenant,
+		RerunOf:        j.RerunOf,
+		Commands:       j.Cmd,
+		Started:        j.startTime,
+		Ended:          j.endTime,
+		ScratchBytes:   j.ScratchBytes,
+		Host:           "subprocess",
+		CPUs:           j.Resources.CPUs,
+	})
 	if err != nil {
-		j.logger.Errorf("Error marshalling metadata to JSON bytes: %s", err.Error())
-		return
+		j.logger.Errorf("Error writing metadata: %s", err.Error())
 	}
+	setOutputContentTypes(j.logger, j.StorageSvc, j.UUID, j.Tenant, j.OutputTypes)
+}
 
-	metadataDir := os.Getenv("STORAGE_METADATA_PREFIX")
-	mdLocation := fmt.Sprintf("%s/%s.json", metadataDir, j.UUID)
-	err = utils.WriteToS3(j.StorageSvc, jsonBytes, mdLocation, "application/json", 0)
-	if err != nil {
-		return
-	}
+// SubscribeProcessLogs returns live subprocess log lines
+func (j *SubprocessJob) SubscribeProcessLogs() (<-chan string, func()) {
+	return j.logBroadcaster.Subscribe()
 }
 
 func (j *SubprocessJob) RunFinished() {
@@ -356,6 +557,7 @@ func (j *SubprocessJob) Close() {
 	j.closeOnce.Do(func() {
 		j.logger.Info("Starting closing routine.")
 		j.ctxCancel() // Signal Run function to terminate if running
+		j.logBroadcaster.Close()
 
 		// // Following is not needed since we are using context to signal job termination
 		// if j.execCmd.Process != nil && j.execCmd.ProcessState == nil {
@@ -366,17 +568,19 @@ func (j *SubprocessJob) Close() {
 		// 	}
 		// }
 
-		j.DoneChan <- j // At this point job can be safely removed from active jobs
+		j.ActiveJobs.RemoveID(j.UUID) // At this point job can be safely removed from active jobs
 
 		go func() {
 			j.wg.Wait() // wait if other routines like metadata are running
+			removeScratchDir(j.logger, j.ScratchDir)
+			removeInputsFile(j.logger, j.inputsPath)
 			j.logFile.Close()
-			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName)
+			UploadLogsToStorage(j.StorageSvc, j.UUID, j.ProcessName, j.Tenant)
 			// It is expected that logs will be requested multiple times for a recently finished job
-			// so we are waiting for one hour to before deleting the local copy
+			// so we are waiting for the local logs TTL (default one hour) before deleting the local copy
 			// so that we can avoid repetitive request to storage service.
 			// If the server shutdown, these files would need to be manually deleted
-			time.Sleep(time.Hour)
+			time.Sleep(config.Get().Logging.LocalLogsTTL)
 			DeleteLocalLogs(j.StorageSvc, j.UUID, j.ProcessName)
 		}()
 	})
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Tenants are used in storage keys, so they are restricted to characters safe in S3 keys and IAM policies
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateTenant returns an error if tenant can not be used in storage keys
+func ValidateTenant(tenant string) error {
+	if tenant != "" && !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant %q, only letters, digits, '_' and '-' are allowed", tenant)
+	}
+	return nil
+}
+
+// StorageKey builds the key of a job file under prefix, e.g. the storage metadata or logs prefix.
+// Files of jobs with a tenant are stored under <tenant>/<prefix>/ so that access can be granted per tenant.
+func StorageKey(prefix, tenant, name string) string {
+	if tenant == "" {
+		return fmt.Sprintf("%s/%s", prefix, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", tenant, prefix, name)
+}
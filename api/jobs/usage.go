@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"app/controllers"
+	"sync"
+	"time"
+)
+
+// ResourceUsage summarizes resources consumed by a job, sampled while it runs.
+// It is meant to help users right-size maxResources of a process.
+type ResourceUsage struct {
+	Samples       int       `json:"samples"`
+	FirstSampleAt time.Time `json:"firstSampleAt,omitempty"`
+	LastSampleAt  time.Time `json:"lastSampleAt,omitempty"`
+
+	// CPU percentages are of a single CPU, 200 means 2 CPUs fully used
+	CPUPercentAvg float64 `json:"cpuPercentAvg"`
+	CPUPercentMax float64 `json:"cpuPercentMax"`
+	MemoryPeakMB  float64 `json:"memoryPeakMB"`
+	MemoryLimitMB float64 `json:"memoryLimitMB"`
+
+	// Cumulative counters reported at last sample
+	NetworkRxBytes  uint64 `json:"networkRxBytes"`
+	NetworkTxBytes  uint64 `json:"networkTxBytes"`
+	BlockReadBytes  uint64 `json:"blockReadBytes"`
+	BlockWriteBytes uint64 `json:"blockWriteBytes"`
+
+	// Resources requested by the job
+	RequestedCPUs     float32 `json:"requestedCPUs"`
+	RequestedMemoryMB int     `json:"requestedMemoryMB"`
+}
+
+// UsageReporter is implemented by jobs that sample their resource usage.
+type UsageReporter interface {
+	ResourceUsage() ResourceUsage
+}
+
+// Aggregates container stats samples into a ResourceUsage, safe for concurrent use
+type usageTracker struct {
+	mu     sync.Mutex
+	usage  ResourceUsage
+	cpuSum float64
+}
+
+func newUsageTracker(requested Resources) *usageTracker {
+	return &usageTracker{usage: ResourceUsage{RequestedCPUs: requested.CPUs, RequestedMemoryMB: requested.Memory}}
+}
+
+func (ut *usageTracker) add(s controllers.ContainerStatsSample) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	u := &ut.usage
+	if u.Samples == 0 {
+		u.FirstSampleAt = s.Time
+	}
+	u.Samples++
+	u.LastSampleAt = s.Time
+
+	ut.cpuSum += s.CPUPercent
+	u.CPUPercentAvg = ut.cpuSum / float64(u.Samples)
+	if s.CPUPercent > u.CPUPercentMax {
+		u.CPUPercentMax = s.CPUPercent
+	}
+
+	memMB := float64(s.MemoryBytes) / (1024 * 1024)
+	if memMB > u.MemoryPeakMB {
+		u.MemoryPeakMB = memMB
+	}
+	u.MemoryLimitMB = float64(s.MemoryLimit) / (1024 * 1024)
+
+	u.NetworkRxBytes = s.NetRxBytes
+	u.NetworkTxBytes = s.NetTxBytes
+	u.BlockReadBytes = s.BlkRead
+	u.BlockWriteBytes = s.BlkWrite
+}
+
+func (ut *usageTracker) snapshot() ResourceUsage {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	return ut.usage
+}
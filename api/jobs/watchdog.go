@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"time"
+)
+
+// Failure classes assigned by the job watchdog
+const (
+	// The job was running without status updates, logs or progress for longer than the silence timeout
+	FailureStalled = "stalled"
+	// The container or Batch job of the running job no longer exists
+	FailureLost = "lost"
+)
+
+// A container that exited is only reconciled after this time, so that the job is not updated while Run handles the exit
+const driftGracePeriod = time.Minute
+
+// Watched is implemented by jobs whose running container, process or Batch job is checked by the job watchdog.
+// Pipeline jobs do not implement it, their steps are checked instead.
+type Watched interface {
+	// LastProcessLog returns when the process of the job last wrote a log line, zero if it has not
+	LastProcessLog() time.Time
+
+	// ReconcileStatus compares the running job with its backend. If the container or Batch job finished or
+	// no longer exists, e.g. because the end of the container or a status update was missed, the status of the job
+	// is updated and returned. Returns an empty status if the backend job is still running.
+	ReconcileStatus() (string, error)
+
+	// Fail stops the container, process or Batch job of a running job that is stuck and updates the status of the
+	// job to failed with failure class class. reason is logged in the server logs of the job.
+	Fail(class, reason string) error
+}
@@ -2,21 +2,27 @@ package main
 
 import (
 	"app/auth"
+	"app/backendplugin"
+	"app/cli"
+	"app/config"
 	_ "app/docs"
+	"app/grpcapi"
 	"app/handlers"
+	"app/jobs"
 	"fmt"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"context"
 	"flag"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -24,6 +30,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -32,26 +39,34 @@ var (
 )
 
 var (
-	envFP          string
-	pluginsLoadDir string
-	dbPath         string
-	port           string
-	logFile        string
-	authSvc        string
-	authLvl        string
-	maxLocalCPUs   string
-	maxLocalMemory string
+	envFP  string
+	confFP string
+	cfg    *config.Config
 )
 
+// `sepex <command>` runs a client command instead of the server, see package cli
+func clientMode() bool {
+	return len(os.Args) > 1 && cli.IsCommand(os.Args[1])
+}
+
 func init() {
-	// The order of precedence as Flag > Environment variable > Default value
+	if clientMode() {
+		return
+	}
+	// The order of precedence as Flag > Environment variable > Config file > Default value
 
-	// Manually parse command line arguments to find the -e value since flag.Parse() can't be used
+	// Manually parse command line arguments to find the -e and -c values since flag.Parse() can't be used
+	// before the configuration providing the defaults of flags is loaded
 	for i, arg := range os.Args {
-		if arg == "-e" && i+1 < len(os.Args) {
-			envFP = os.Args[i+1]
+		if i+1 >= len(os.Args) {
 			break
 		}
+		switch arg {
+		case "-e":
+			envFP = os.Args[i+1]
+		case "-c":
+			confFP = os.Args[i+1]
+		}
 	}
 
 	if envFP != "" {
@@ -60,33 +75,40 @@ func init() {
 			log.Fatalf("could not read environment file: %s", err.Error())
 		}
 	}
+	if confFP == "" {
+		confFP = os.Getenv("CONFIG_FILE")
+	}
+
+	var err error
+	cfg, err = config.Load(confFP)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%s", err.Error())
+	}
 
 	// Only variables that are needed at startup and will not be used after startup are available as CLI flags
 	flag.StringVar(&envFP, "e", "", "specify the path of the dot env file to load")
-	flag.StringVar(&pluginsLoadDir, "pld", resolveValue("PLUGINS_LOAD_DIR", ""), "specify the relative path of the directory to load plugins from")
-	flag.StringVar(&port, "p", resolveValue("API_PORT", "5050"), "specify the port to run the api on")
-	flag.StringVar(&logFile, "lf", resolveValue("LOG_FILE", "/.data/logs/api.jsonl"), "specify the log file")
-	flag.StringVar(&authSvc, "au", resolveValue("AUTH_SERVICE", ""), "specify the auth service")
-	flag.StringVar(&authLvl, "al", resolveValue("AUTH_LEVEL", "0"), "specify the authorization striction level")
-	flag.StringVar(&maxLocalCPUs, "mlc", resolveValue("MAX_LOCAL_CPUS", ""), "max CPUs for local jobs (default: 80% of system CPUs)")
-	flag.StringVar(&maxLocalMemory, "mlm", resolveValue("MAX_LOCAL_MEMORY_MB", ""), "max memory in MB for local jobs (default: 8192)")
+	flag.StringVar(&confFP, "c", confFP, "specify the path of the YAML or TOML config file to load")
+	flag.StringVar(&cfg.Plugins.LoadDir, "pld", cfg.Plugins.LoadDir, "specify the relative path of the directory to load plugins from")
+	flag.StringVar(&cfg.API.Port, "p", cfg.API.Port, "specify the port to run the api on")
+	flag.StringVar(&cfg.Logging.File, "lf", cfg.Logging.File, "specify the log file")
+	flag.StringVar(&cfg.Auth.Service, "au", cfg.Auth.Service, "specify the auth service")
+	flag.IntVar(&cfg.Auth.Level, "al", cfg.Auth.Level, "specify the authorization striction level")
+	flag.Float64Var(&cfg.Jobs.MaxLocalCPUs, "mlc", cfg.Jobs.MaxLocalCPUs, "max CPUs for local jobs (default: 80% of system CPUs)")
+	flag.IntVar(&cfg.Jobs.MaxLocalMemoryMB, "mlm", cfg.Jobs.MaxLocalMemoryMB, "max memory in MB for local jobs")
 
 	flag.Parse()
-}
 
-// Checks if there's an environment variable for this configuration,
-// if yes, return the env value, if not, return the default value.
-func resolveValue(envVar string, defaultValue string) string {
-	if value, exists := os.LookupEnv(envVar); exists {
-		return value
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%s", err.Error())
 	}
-	return defaultValue
+	config.Set(cfg)
 }
 
 // Init logrus logging, returning, lvl and rotating log writer
 // that can be used for middleware logging
 func initLogger() (log.Level, *lumberjack.Logger) {
 
+	logFile := cfg.Logging.File
 	dir := filepath.Dir(logFile)
 	err := os.MkdirAll(dir, 0755)
 	if err != nil {
@@ -109,13 +131,13 @@ func initLogger() (log.Level, *lumberjack.Logger) {
 		Compress: true,    // Whether to compress the rotated files
 	}
 
-	log.SetOutput(logWriter)
+	log.SetOutput(jobs.ServerLogWriter(logWriter))
 	log.SetFormatter(&log.JSONFormatter{}) // Set formatter to JSON
 	log.SetReportCaller(true)              // Enable logging the calling method
 
-	lvl, err := log.ParseLevel(os.Getenv("LOG_LEVEL"))
+	lvl, err := log.ParseLevel(cfg.Logging.Level)
 	if err != nil {
-		log.Warnf("Invalid LOG_LEVEL set: %s, defaulting to INFO", os.Getenv("LOG_LEVEL"))
+		log.Warnf("Invalid LOG_LEVEL set: %s, defaulting to INFO", cfg.Logging.Level)
 		lvl = log.InfoLevel
 	}
 	log.SetLevel(lvl)
@@ -129,49 +151,81 @@ const (
 	authLevelAll     = 2
 )
 
-func applyAuthMiddleware(e *echo.Echo, protected *echo.Group, as auth.AuthStrategy, authLevel int) {
+// Auth middlewares of the REST routes, applied to the calls of the gRPC service like to their equivalent routes
+var grpcAuth grpcapi.Auth
+
+// Client IPs of rate limits and the audit log. Without trusted proxies the peer of the connection is the client,
+// X-Forwarded-For and X-Real-IP are set by clients and would let them pick their IP.
+func ipExtractor(proxies []string) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	// only the configured proxies are trusted, not every private network
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, p := range proxies {
+		_, ipNet, _ := net.ParseCIDR(p) // checked by config validation
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+func applyAuthMiddleware(e *echo.Echo, protected *echo.Group, as auth.AuthStrategy, authLevel int, skipper middleware.Skipper) {
 	switch authLevel {
 	case authLevelPartial:
-		// Apply the Authorize middleware only to protected group
-		protected.Use(auth.Authorize(as))
+		// Identify users on public routes so that process access rules can be applied,
+		// apply the Authorize middleware only to protected group
+		identify, authorize := auth.Identify(as), auth.Authorize(as)
+		e.Use(identify)
+		protected.Use(authorize)
+		grpcAuth.Public = append(grpcAuth.Public, identify)
+		grpcAuth.Protected = append(grpcAuth.Protected, identify, authorize)
 	case authLevelAll:
-		// Apply the Authorize middleware to all routes
-		e.Use(auth.Authorize(as))
+		// Apply the Authorize middleware to all routes, except requests the skipper allows (e.g. result links)
+		authorize := auth.Authorize(as, skipper)
+		e.Use(authorize)
+		grpcAuth.Public = append(grpcAuth.Public, authorize)
+		grpcAuth.Protected = append(grpcAuth.Protected, authorize)
 	}
 }
 
-func initAuth(e *echo.Echo, protected *echo.Group) int {
+func initAuth(e *echo.Echo, protected *echo.Group, skipper middleware.Skipper) int {
 	var as auth.AuthStrategy
 	var err error
 
-	authLvlInt, err := strconv.Atoi(authLvl)
-	if err != nil {
-		log.Fatalf("Error converting AUTH_LEVEL to number: %s", err.Error())
-	}
-
-	if authLvlInt == 0 {
+	if cfg.Auth.Level == 0 {
 		log.Warn("No authentication set up.")
 		return 0
 	} else {
-		switch authSvc {
+		switch cfg.Auth.Service {
 		case "keycloak":
 			as, err = auth.NewKeycloakAuthStrategy()
 			if err != nil {
 				log.Fatalf("Error creating KeyCloak auth service: %s", err.Error())
 			}
+		case "client-cert":
+			// TLS_CLIENT_CA_FILE and TLS_CLIENT_CERT_ROLES are checked by config validation
+			as = auth.ClientCertAuthStrategy{}
 		default:
 			log.Fatal("unsupported auth service provider type")
 		}
 	}
 
-	applyAuthMiddleware(e, protected, as, authLvlInt)
-	return authLvlInt
+	applyAuthMiddleware(e, protected, as, cfg.Auth.Level, skipper)
+	return cfg.Auth.Level
 }
 
 func initPlugins() {
-	pluginsDir, exist := os.LookupEnv("PLUGINS_DIR")
-	if !exist {
-		log.Fatal("env variable PLUGINS_DIR not set")
+	// read-only layers are never created, a missing layer would silently drop its processes
+	for _, dir := range cfg.Plugins.Dirs {
+		if _, err := os.Stat(dir); err != nil {
+			log.Fatalf("plugin directory %s of PLUGINS_DIRS: %s", dir, err.Error())
+		}
+	}
+
+	// processes are loaded from a git repository if plugins.dir is not set, see config validation
+	pluginsDir := cfg.Plugins.Dir
+	if pluginsDir == "" {
+		return
 	}
 
 	deprecatedDir := filepath.Join(pluginsDir, "deprecated")
@@ -191,27 +245,27 @@ func initPlugins() {
 		if err != nil {
 			log.Fatal(err)
 		}
-	} else if pluginsLoadDir != "" { // old plugins data exist, yet pluginsLoadDir option specified
+	} else if cfg.Plugins.LoadDir != "" { // old plugins data exist, yet cfg.Plugins.LoadDir option specified
 		log.Fatal("Plugins Load Directory specified, but old plugins data folder exist. Remove old plugins data first.")
 	}
 }
 
 func copyPlugins(dstDir string) error {
 
-	if pluginsLoadDir == "" {
+	if cfg.Plugins.LoadDir == "" {
 		return nil
 	}
 
-	if _, err := os.Stat(pluginsLoadDir); os.IsNotExist(err) {
-		return fmt.Errorf("specified directory to load plugins from does not exist: %s", pluginsLoadDir)
+	if _, err := os.Stat(cfg.Plugins.LoadDir); os.IsNotExist(err) {
+		return fmt.Errorf("specified directory to load plugins from does not exist: %s", cfg.Plugins.LoadDir)
 	}
 
 	// Match only .yml and .yaml files one level down
-	ymls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yml", pluginsLoadDir))
+	ymls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yml", cfg.Plugins.LoadDir))
 	if err != nil {
 		return err
 	}
-	yamls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yaml", pluginsLoadDir))
+	yamls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yaml", cfg.Plugins.LoadDir))
 	if err != nil {
 		return err
 	}
@@ -260,17 +314,26 @@ func copyFile(src, dst string) error {
 // @externalDocs.description   Schemas
 // @externalDocs.url    http://schemas.opengis.net/ogcapi/processes/part1/1.0/openapi/schemas/
 func main() {
+	if clientMode() {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
 	initPlugins()
 
 	// Initialize resources
-	rh := handlers.NewRESTHander(GitTag, maxLocalCPUs, maxLocalMemory)
+	rh := handlers.NewRESTHander(GitTag, cfg)
 	// todo: handle this error: Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running
 	// todo: all non terminated job statuses should be updated to unknown
 	// todo: all logs in the logs directory should be moved to storage
 
 	// Goroutines
-	go rh.StatusUpdateRoutine()
-	go rh.JobCompletionRoutine()
+	rh.StatusUpdateRoutine() // spawns one goroutine per shard of the message queue
+	go rh.OrphanReaperRoutine(rh.Config.OrphanReaperInterval)
+	go rh.JobWatchdogRoutine(rh.Config.WatchdogInterval)
+	go rh.BatchEventsRoutine(cfg.AWS.BatchEventsQueueURL)
+	go rh.DockerEventsRoutine()
+	go rh.ProcessRegistrySyncRoutine()
+	go rh.ImageRefreshRoutine(rh.Config.ImageRefreshInterval)
+	go rh.DiskMonitorRoutine(cfg.Jobs.DiskCheckInterval)
 	rh.QueueWorker.Start() // Start() spawns its own goroutine and supports Stop() for graceful shutdown
 
 	// Set server configuration
@@ -279,16 +342,23 @@ func main() {
 
 	// e.HideBanner = true
 	e.HidePort = true
+	e.IPExtractor = ipExtractor(cfg.API.TrustedProxies)
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowCredentials: true,
-		AllowOrigins:     []string{"*"},
+	// Request ID is returned in X-Request-Id header, included in access logs and stored on jobs created by the request
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Generator: func() string { return uuid.New().String() },
 	}))
+	e.Use(middleware.CORSWithConfig(rh.Config.CORS))
+	if rh.Config.SecureHeaders != nil {
+		e.Use(middleware.SecureWithConfig(*rh.Config.SecureHeaders))
+	}
 	e.Renderer = &rh.T
 
+	tlsConfig := initTLS(e)
+
 	// Create a group for all routes that need to be protected when AUTH_LEVEL = protected
 	pg := e.Group("")
-	authLvl := initAuth(e, pg)
+	authLvl := initAuth(e, pg, func(c echo.Context) bool { return rh.ResultLinkRequest(c) || rh.CallbackRequest(c) })
 	rh.Config.AuthLevel = authLvl
 
 	// Server
@@ -299,11 +369,17 @@ func main() {
 	// Processes
 	e.GET("/processes", rh.ProcessListHandler)
 	e.GET("/processes/:processID", rh.ProcessDescribeHandler)
-	pg.POST("/processes/:processID", rh.AddProcessHandler)
-	pg.PUT("/processes/:processID", rh.UpdateProcessHandler)
-	pg.DELETE("/processes/:processID", rh.DeleteProcessHandler)
-
-	pg.POST("/processes/:processID/execution", rh.Execution)
+	e.GET("/schemas/process", rh.ProcessSchemaHandler)
+	e.GET("/schemas/events", rh.EventSchemaHandler)
+	pg.POST("/processes/:processID", rh.AddProcessHandler, rh.Audit(handlers.AuditProcessAdd))
+	pg.PUT("/processes/:processID", rh.UpdateProcessHandler, rh.Audit(handlers.AuditProcessUpdate))
+	pg.DELETE("/processes/:processID", rh.DeleteProcessHandler, rh.Audit(handlers.AuditProcessDelete))
+	pg.POST("/processes\\:deploy", rh.DeployProcessHandler, rh.Audit(handlers.AuditProcessDeploy))
+
+	pg.POST("/processes/:processID/execution", rh.Execution, rh.RateLimit(rh.ExecuteLimiter), rh.ExecuteLimits(), rh.Audit(handlers.AuditJobSubmit))
+	pg.POST("/processes/:processID/queue/pause", rh.ProcessQueuePauseHandler, rh.Audit(handlers.AuditProcessPause))
+	pg.POST("/processes/:processID/queue/resume", rh.ProcessQueueResumeHandler, rh.Audit(handlers.AuditProcessResume))
+	pg.POST("/processes/:processID/selftest", rh.ProcessSelfTestHandler, rh.Audit(handlers.AuditProcessTest))
 
 	// TODO
 	// pg.Post("processes/:processID/new, rh.RegisterNewProcess)
@@ -311,34 +387,101 @@ func main() {
 
 	// Jobs
 	e.GET("/jobs", rh.ListJobsHandler) // changed for hotfix, should be pg.GET when clients are updated
-	e.GET("/jobs/:jobID", rh.JobStatusHandler)
-	e.GET("/jobs/:jobID/results", rh.JobResultsHandler)
-	e.GET("/jobs/:jobID/logs", rh.JobLogsHandler)
-	e.GET("/jobs/:jobID/metadata", rh.JobMetaDataHandler)
-	pg.DELETE("/jobs/:jobID", rh.JobDismissHandler)
+	e.GET("/jobs/events", rh.JobsEventsHandler)
+	// Job routes are public with partial auth, so like job listing their ownership is only enforced when all routes are protected
+	e.GET("/jobs/:jobID", rh.JobStatusHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/results", rh.JobResultsHandler, rh.JobOwner())
+	pg.POST("/jobs/:jobID/results/share", rh.ShareResultsHandler, rh.JobOwner())
+	pg.POST("/jobs/:jobID/widget/share", rh.ShareWidgetHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/widget", rh.JobWidgetHandler)
+	e.GET("/jobs/:jobID/logs", rh.JobLogsHandler, rh.RateLimit(rh.LogsLimiter), rh.JobOwner(), handlers.LogsGzip())
+	e.GET("/jobs/:jobID/logs/raw", rh.JobLogsRawHandler, rh.RateLimit(rh.LogsLimiter), rh.JobOwner(), handlers.LogsGzip())
+	e.GET("/jobs/:jobID/logs/stream", rh.JobLogsStreamHandler, rh.RateLimit(rh.LogsLimiter), rh.JobOwner())
+	e.GET("/jobs/:jobID/events", rh.JobEventsHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/metadata", rh.JobMetaDataHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/definition", rh.JobDefinitionHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/children", rh.JobChildrenHandler, rh.JobOwner())
+	e.GET("/jobs/:jobID/usage", rh.JobUsageHandler, rh.JobOwner())
+	pg.DELETE("/jobs/:jobID", rh.JobDismissHandler, rh.Audit(handlers.AuditJobDismiss), rh.JobOwner())
+	pg.POST("/jobs/:jobID/rerun", rh.JobRerunHandler, rh.RateLimit(rh.ExecuteLimiter), rh.Audit(handlers.AuditJobRerun), rh.JobOwner())
+	pg.POST("/jobs/:jobID/pause", rh.JobPauseHandler, rh.Audit(handlers.AuditJobPause), rh.JobOwner())
+	pg.POST("/jobs/:jobID/resume", rh.JobResumeHandler, rh.Audit(handlers.AuditJobResume), rh.JobOwner())
+	e.GET("/jobs/:jobID/notes", rh.JobNotesHandler, rh.JobOwner())
+	pg.POST("/jobs/:jobID/notes", rh.JobNoteAddHandler, rh.Audit(handlers.AuditJobNote), rh.JobOwner())
+	pg.POST("/jobs\\:batchDismiss", rh.BatchDismissHandler, rh.Audit(handlers.AuditJobDismiss))
+	pg.POST("/jobs\\:batchDelete", rh.BatchDeleteHandler, rh.Audit(handlers.AuditJobDelete))
 
 	// Callbacks
-	pg.PUT("/jobs/:jobID/status", rh.JobStatusUpdateHandler)
+	pg.PUT("/jobs/:jobID/status", rh.JobStatusUpdateHandler, rh.Audit(handlers.AuditJobStatus))
 	// e.POST("/jobs/:jobID/results", rh.JobResultsUpdateHandler)
+	// Authenticated with the callback token of the job, not user credentials
+	e.PUT("/internal/jobs/:jobID/results", rh.JobCallbackHandler)
+
+	// Stats
+	pg.GET("/stats/processes", rh.ProcessStatsHandler)
+	pg.GET("/stats/jobs", rh.JobStatsHandler)
+	pg.GET("/stats/costs", rh.CostStatsHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.GET("/stats/usage", rh.ProcessUsageHandler, rh.Audit(handlers.AuditAdminAccess))
 
 	// Admin
-	e.GET("/admin/resources", rh.ResourceStatusHandler)
+	e.GET("/admin/resources", rh.ResourceStatusHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.GET("/admin/dashboard", rh.DashboardHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.GET("/admin/queues", rh.QueueStatsHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.GET("/admin/audit", rh.AuditLogHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.POST("/admin/jobs/:jobID/restore", rh.JobRestoreHandler, rh.Audit(handlers.AuditJobRestore))
+	pg.GET("/admin/orphans", rh.OrphansHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.POST("/admin/orphans/reap", rh.ReapOrphansHandler, rh.Audit(handlers.AuditAdminReap))
+	pg.GET("/admin/images", rh.ImagesHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.POST("/admin/images/refresh", rh.RefreshImagesHandler, rh.Audit(handlers.AuditAdminImages))
+	pg.GET("/admin/config", rh.RuntimeConfigHandler, rh.Audit(handlers.AuditAdminAccess))
+	pg.PATCH("/admin/config", rh.RuntimeConfigUpdateHandler, rh.Audit(handlers.AuditAdminConfig))
+
+	// GraphQL
+	if rh.GraphQL != nil {
+		pg.GET("/graphql", rh.GraphQLHandler)
+		pg.POST("/graphql", rh.GraphQLHandler)
+	}
 
 	_, lw := initLogger()
-	fmt.Println("Logging to", logFile)
+	fmt.Println("Logging to", cfg.Logging.File)
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Output: lw,
+		Output: jobs.ServerLogWriter(lw),
 	}))
 
 	// Start server
 	go func() {
-		log.Info("server starting on port: ", port)
-		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			log.Info("server starting with TLS on port: ", cfg.API.Port)
+			e.TLSServer.Addr = ":" + cfg.API.Port
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			log.Info("server starting on port: ", cfg.API.Port)
+			err = e.Start(":" + cfg.API.Port)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Error("server error : ", err.Error())
 			log.Fatal("shutting down the server")
 		}
 	}()
 
+	// gRPC Jobs service, calls are served by the jobs operations of the REST handler
+	var grpcServer *grpc.Server
+	if cfg.API.GRPCPort != "" {
+		lis, err := net.Listen("tcp", ":"+cfg.API.GRPCPort)
+		if err != nil {
+			log.Fatalf("could not listen on GRPC_PORT: %s", err.Error())
+		}
+		grpcServer = grpcapi.NewServer(rh, e, grpcAuth, tlsConfig)
+		go func() {
+			log.Info("gRPC server starting on port: ", cfg.API.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error("gRPC server error : ", err.Error())
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server with a timeout of 10 seconds.
 	// Use a buffered channel to avoid missing signals as recommended for signal.Notify
 	quit := make(chan os.Signal, 1)
@@ -353,6 +496,10 @@ func main() {
 			log.Error(err)
 		}
 	}()
+	if grpcServer != nil {
+		// streams of running jobs end when the jobs are killed below
+		go grpcServer.GracefulStop()
+	}
 
 	// Shutdown the server
 	// By default, Docker provides a grace period of 10 seconds with the docker stop command.
@@ -370,6 +517,9 @@ func main() {
 	// aws batch jobs close() methods take minimum of 5 seconds
 	time.Sleep(5 * time.Second)
 
+	// jobs of backend plugins were killed above
+	backendplugin.Shutdown()
+
 	if err := rh.DB.Close(); err != nil {
 		log.Error(err)
 	} else {
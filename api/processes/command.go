@@ -0,0 +1,152 @@
+package processes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+)
+
+// Functions available in command templates. scalar is appended to every action by parseCommandTemplate, so it
+// is not meant to be used directly.
+var commandFuncs = template.FuncMap{
+	"json":       commandJSON,
+	"shellquote": shellQuote,
+	"scalar":     commandScalar,
+}
+
+// IsCommandTemplated reports whether any element of the command has template placeholders, e.g. `{{ .inputs.region }}`.
+// Inputs of templated commands are rendered into the command instead of being appended as a JSON argument.
+func (p Process) IsCommandTemplated() bool {
+	for _, arg := range p.Command {
+		if strings.Contains(arg, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderCommand renders each element of a templated command with the inputs of an execute request,
+// which must already be verified with VerifyInputs.
+//
+// Every element renders to exactly one argument, commands are not run by a shell so values are never split or interpreted.
+// Elements rendering to an empty string are dropped so that optional arguments can be written as
+// `{{ with index .inputs "x" }}--x={{ . }}{{ end }}`. Values inserted by an action must be a string, number or boolean
+// without control characters, objects and arrays have to be inserted with `json`. Referencing a missing input is an error,
+// use `index` for optional inputs. Commands passing an element to a shell, e.g. `sh -c`, must quote values with `shellquote`.
+func (p Process) RenderCommand(inputs map[string]interface{}) ([]string, error) {
+	data := map[string]interface{}{"inputs": inputs}
+
+	cmd := make([]string, 0, len(p.Command))
+	for i, arg := range p.Command {
+		tmpl, err := parseCommandTemplate(i, arg)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("could not render command: %s", strings.TrimPrefix(err.Error(), "template: "))
+		}
+		if b.Len() > 0 {
+			cmd = append(cmd, b.String())
+		}
+	}
+	return cmd, nil
+}
+
+// Check that all command templates parse, used when the process is loaded
+func (p Process) validateCommandTemplate() error {
+	for i, arg := range p.Command {
+		if _, err := parseCommandTemplate(i, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseCommandTemplate(i int, arg string) (*template.Template, error) {
+	tmpl, err := template.New(fmt.Sprintf("command %d", i)).Option("missingkey=error").Funcs(commandFuncs).Parse(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command template: %s", strings.TrimPrefix(err.Error(), "template: "))
+	}
+	appendScalar(tmpl.Tree.Root)
+	return tmpl, nil
+}
+
+// Pipe the output of every action through scalar, so that no value is inserted without being checked
+func appendScalar(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			appendScalar(c)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 { // variable declarations do not output anything
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{parse.NewIdentifier("scalar").SetPos(n.Pos)}})
+	case *parse.IfNode:
+		appendScalar(n.List)
+		appendScalar(n.ElseList)
+	case *parse.RangeNode:
+		appendScalar(n.List)
+		appendScalar(n.ElseList)
+	case *parse.WithNode:
+		appendScalar(n.List)
+		appendScalar(n.ElseList)
+	}
+}
+
+func commandScalar(v interface{}) (string, error) {
+	var s string
+	switch vv := v.(type) {
+	case string:
+		s = vv
+	case bool:
+		s = strconv.FormatBool(vv)
+	case float64:
+		// JSON numbers are decoded as float64, avoid exponent notation of large integers
+		s = strconv.FormatFloat(vv, 'f', -1, 64)
+	case json.Number:
+		s = vv.String()
+	case nil:
+		return "", errors.New("no value, use index and with for optional inputs")
+	default:
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32:
+			s = fmt.Sprint(v)
+		default:
+			return "", fmt.Errorf("%T values can not be inserted into the command, use json", v)
+		}
+	}
+
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("value %q contains control characters", s)
+		}
+	}
+	return s, nil
+}
+
+func commandJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Quote a value for POSIX shells
+func shellQuote(v interface{}) (string, error) {
+	s, err := commandScalar(v)
+	if err != nil {
+		return "", err
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'", nil
+}
@@ -0,0 +1,43 @@
+package processes
+
+import (
+	"app/controllers"
+	"errors"
+	"fmt"
+	"path"
+)
+
+// Check entrypoint and workingDir of the host, they are only supported for docker
+func (p *Process) validateImageOverrides() error {
+	h := p.Host
+	if len(h.Entrypoint) == 0 && h.WorkingDir == "" {
+		return nil
+	}
+	if h.Type != "docker" {
+		return errors.New("host.entrypoint, host.workingDir: only supported for docker host type")
+	}
+
+	var errs []error
+	if len(h.Entrypoint) > 0 && h.Entrypoint[0] == "" {
+		errs = append(errs, errors.New("host.entrypoint: first element must be the executable"))
+	}
+	// Images of docker processes are linux images, paths in the container are slash separated
+	if h.WorkingDir != "" && !path.IsAbs(h.WorkingDir) {
+		errs = append(errs, fmt.Errorf("host.workingDir: must be an absolute path, found %q", h.WorkingDir))
+	}
+	return errors.Join(errs...)
+}
+
+// DockerImageOverrides returns the entrypoint and working directory containers of the process run with,
+// nil if neither is set
+func (p Process) DockerImageOverrides() *controllers.DockerImageOverrides {
+	if p.Host.Type != "docker" || (len(p.Host.Entrypoint) == 0 && p.Host.WorkingDir == "") {
+		return nil
+	}
+	o := &controllers.DockerImageOverrides{WorkingDir: p.Host.WorkingDir}
+	// Docker replaces the entrypoint of the image with an empty one if it is not nil
+	if len(p.Host.Entrypoint) > 0 {
+		o.Entrypoint = append([]string{}, p.Host.Entrypoint...)
+	}
+	return o
+}
@@ -0,0 +1,85 @@
+package processes
+
+import (
+	"app/config"
+	"app/controllers"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvValue is the source of an env variable of `config.env`, exactly one of fromEnv (env variable of the server),
+// value (literal) and fromSecret (secret store, resolved when a job starts) must be set.
+type EnvValue struct {
+	FromEnv    string     `yaml:"fromEnv,omitempty" json:"fromEnv,omitempty"`
+	Value      *string    `yaml:"value,omitempty" json:"value,omitempty"`
+	FromSecret *SecretRef `yaml:"fromSecret,omitempty" json:"fromSecret,omitempty"`
+}
+
+// EnvVar is env variable Name of jobs, set to Value or to the value of server env variable FromEnv if it is set
+type EnvVar struct {
+	Name    string
+	FromEnv string
+	Value   string
+}
+
+// JobEnv returns the env variables of jobs from envVars and env, and the ones read from secret stores
+// from envVarsFrom and env. Names of envVars lose the `<PROCESSID>_` prefix if they have it. env is sorted by name.
+func (p Process) JobEnv() ([]EnvVar, []EnvVarFrom) {
+	vars := make([]EnvVar, 0, len(p.Config.EnvVars)+len(p.Config.Env))
+	for _, k := range p.Config.EnvVars {
+		vars = append(vars, EnvVar{Name: strings.TrimPrefix(k, strings.ToUpper(p.Info.ID)+"_"), FromEnv: k})
+	}
+	from := append([]EnvVarFrom{}, p.Config.EnvVarsFrom...)
+
+	for _, name := range p.envNames() {
+		ev := p.Config.Env[name]
+		switch {
+		case ev.FromSecret != nil:
+			from = append(from, EnvVarFrom{Name: name, Source: ev.FromSecret.Source, Ref: ev.FromSecret.Ref, Key: ev.FromSecret.Key})
+		case ev.Value != nil:
+			vars = append(vars, EnvVar{Name: name, Value: *ev.Value})
+		default:
+			vars = append(vars, EnvVar{Name: name, FromEnv: ev.FromEnv})
+		}
+	}
+	return vars, from
+}
+
+// Names of env sorted, maps are iterated in random order
+func (p Process) envNames() []string {
+	names := make([]string, 0, len(p.Config.Env))
+	for name := range p.Config.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p Process) validateEnv() error {
+	var errs []error
+	for _, name := range p.envNames() {
+		ev := p.Config.Env[name]
+		sources := 0
+		for _, set := range []bool{ev.FromEnv != "", ev.Value != nil, ev.FromSecret != nil} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			errs = append(errs, fmt.Errorf("env %s: exactly one of fromEnv, value and fromSecret is required", name))
+			continue
+		}
+		if ev.FromSecret != nil {
+			if ev.FromSecret.Source == controllers.SecretSourceVault && config.Get().Vault.Addr == "" {
+				errs = append(errs, fmt.Errorf("env %s: VAULT_ADDR env variable is not set", name))
+			}
+			// Batch container overrides are stored in job descriptions, secrets must be set in the job definition instead
+			if p.Host.Type == "aws-batch" {
+				errs = append(errs, fmt.Errorf("env %s: fromSecret is not supported for aws-batch, use secrets of the job definition", name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+package processes
+
+import (
+	"fmt"
+)
+
+// Maximum number of elements of an input a request can fan out over, every element is a job
+const MaxFanOutElements = 1000
+
+// FanOutInputs returns the inputs of the jobs of a request fanning out over the array input inputID:
+// one inputs map per element, with the element as value of inputID and all other inputs unchanged.
+// The inputs of every element are verified like the inputs of an execute request.
+func (p Process) FanOutInputs(inputID string, inputs map[string]interface{}) ([]map[string]interface{}, error) {
+	found := false
+	for _, i := range p.Inputs {
+		if i.ID == inputID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("fanOut: %s is not an input of this process", inputID)
+	}
+	items, ok := inputs[inputID].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("fanOut: input %s must be an array", inputID)
+	}
+	if len(items) == 0 || len(items) > MaxFanOutElements {
+		return nil, fmt.Errorf("fanOut: input %s must have between 1 and %d elements, found %d", inputID, MaxFanOutElements, len(items))
+	}
+
+	out := make([]map[string]interface{}, len(items))
+	for k, item := range items {
+		in := make(map[string]interface{}, len(inputs))
+		for id, v := range inputs {
+			in[id] = v
+		}
+		in[inputID] = item
+		if err := p.VerifyInputs(in); err != nil {
+			return nil, fmt.Errorf("fanOut: element %d: %v", k, err)
+		}
+		out[k] = in
+	}
+	return out, nil
+}
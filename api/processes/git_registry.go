@@ -0,0 +1,102 @@
+package processes
+
+import (
+	"app/config"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitRegistry is a Git repository process YAMLs are loaded from instead of PLUGINS_DIR.
+// The repository has the same layout as PLUGINS_DIR, `<processID>/<processID>.yml`, at its root or at Path.
+type GitRegistry struct {
+	URL       string
+	Ref       string // branch, tag or commit
+	Path      string // directory of process YAMLs within the repository
+	DeployKey string // path of an SSH private key, optional
+	Dir       string // local checkout
+	// Time between syncs, 0 only syncs at startup
+	SyncInterval time.Duration
+}
+
+// NewGitRegistry configures the git registry, nil if the repository URL is not set
+func NewGitRegistry(c config.GitRegistry) (*GitRegistry, error) {
+	if c.URL == "" {
+		return nil, nil
+	}
+
+	g := &GitRegistry{
+		URL:          c.URL,
+		Ref:          c.Ref,
+		Path:         c.Path,
+		DeployKey:    c.DeployKey,
+		Dir:          c.Dir,
+		SyncInterval: c.SyncInterval,
+	}
+	if g.Ref == "" {
+		g.Ref = "main"
+	}
+	if g.Dir == "" {
+		g.Dir = filepath.Join(os.TempDir(), "sepex-process-registry")
+	}
+	if g.DeployKey != "" {
+		if _, err := os.Stat(g.DeployKey); err != nil {
+			return nil, fmt.Errorf("invalid PROCESSES_GIT_DEPLOY_KEY: %s", err.Error())
+		}
+	}
+	return g, nil
+}
+
+// ProcessesDir is the directory of the checkout process YAMLs are loaded from
+func (g *GitRegistry) ProcessesDir() string {
+	return filepath.Join(g.Dir, g.Path)
+}
+
+// Sync fetches Ref and checks it out, discarding local changes. Returns the commit checked out.
+// Only the commit of Ref is fetched, so syncs stay cheap for repositories with long histories.
+func (g *GitRegistry) Sync() (string, error) {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(g.Dir, 0755); err != nil {
+			return "", fmt.Errorf("could not create git registry directory: %s", err.Error())
+		}
+		if _, err := g.git("init", "--quiet"); err != nil {
+			return "", err
+		}
+		if _, err := g.git("remote", "add", "origin", g.URL); err != nil {
+			return "", err
+		}
+	} else if _, err := g.git("remote", "set-url", "origin", g.URL); err != nil {
+		return "", err
+	}
+
+	if _, err := g.git("fetch", "--quiet", "--depth", "1", "origin", g.Ref); err != nil {
+		return "", err
+	}
+	if _, err := g.git("checkout", "--quiet", "--force", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := g.git("clean", "--quiet", "-fdx"); err != nil {
+		return "", err
+	}
+	return g.git("rev-parse", "HEAD")
+}
+
+func (g *GitRegistry) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.Dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if g.DeployKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", g.DeployKey))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %s %s", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
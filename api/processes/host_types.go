@@ -0,0 +1,62 @@
+package processes
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Host types of process.schema.json
+var builtinHostTypes = []string{"docker", "aws-batch", "subprocess", HostPipeline}
+
+var (
+	hostTypesMu sync.RWMutex
+	hostTypes   = append([]string{}, builtinHostTypes...)
+)
+
+// RegisterHostType allows processes of host type hostType, e.g. of a job backend registered by a plugin.
+// Host types must be registered before specs are validated, registering a host type twice has no effect.
+func RegisterHostType(hostType string) {
+	hostTypesMu.Lock()
+	defer hostTypesMu.Unlock()
+	for _, t := range hostTypes {
+		if t == hostType {
+			return
+		}
+	}
+	hostTypes = append(hostTypes, hostType)
+}
+
+// HostTypes returns the host types processes can have, built-in host types first
+func HostTypes() []string {
+	hostTypesMu.RLock()
+	defer hostTypesMu.RUnlock()
+	return append([]string{}, hostTypes...)
+}
+
+// Values of the enum of host.type in the process schema
+func hostTypeEnum() []interface{} {
+	types := HostTypes()
+	enum := make([]interface{}, len(types))
+	for i, t := range types {
+		enum[i] = t
+	}
+	return enum
+}
+
+// processSchemaWithHostTypes returns the process schema with registered host types in the enum of host.type
+func processSchemaWithHostTypes() ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(processSchemaJSON, &doc); err != nil {
+		return nil, err
+	}
+	node := doc
+	for _, key := range []string{"properties", "host", "properties", "type"} {
+		next, ok := node[key].(map[string]interface{})
+		if !ok {
+			return processSchemaJSON, nil
+		}
+		node = next
+	}
+	node["enum"] = hostTypeEnum()
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,102 @@
+package processes
+
+import (
+	"app/controllers"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProcessImage is a docker image of processes with the credentials it is pulled with, nil Auth pulls anonymously
+type ProcessImage struct {
+	Image      string
+	ProcessIDs []string
+	Auth       controllers.RegistryAuthFunc
+	// Platform of the first process of the image that sets host.platform, the platform of the docker daemon if empty
+	Platform string
+}
+
+// Images returns the docker images of processes in pl sorted by image. An image used by several processes
+// is pulled with the credentials of the first process that has credentials for it.
+func (pl *ProcessList) Images() []ProcessImage {
+	var images []ProcessImage
+	index := make(map[string]int)
+	for _, p := range pl.List {
+		if p.Host.Type != "docker" || p.Host.Image == "" {
+			continue
+		}
+		i, ok := index[p.Host.Image]
+		if !ok {
+			i = len(images)
+			index[p.Host.Image] = i
+			images = append(images, ProcessImage{Image: p.Host.Image})
+		}
+		images[i].ProcessIDs = append(images[i].ProcessIDs, p.Info.ID)
+		if images[i].Auth == nil {
+			images[i].Auth = p.PullAuth()
+		}
+		if images[i].Platform == "" {
+			images[i].Platform = p.Host.Platform
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Image < images[j].Image })
+	return images
+}
+
+// IsPinned returns true if image is referenced by digest, pulling it again can not change it
+func IsPinned(image string) bool {
+	return strings.Contains(image, "@")
+}
+
+// PullImages pulls images with at most concurrency pulls at the same time and returns errors of images that could not be pulled.
+// With force images are pulled even if they exist locally so that moved tags are updated, otherwise only missing images are pulled.
+func PullImages(ctx context.Context, images []ProcessImage, force bool, concurrency int) map[string]error {
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	pullImages(ctx, images, force, concurrency, func(image string, err error) {
+		if err != nil {
+			mu.Lock()
+			errs[image] = err
+			mu.Unlock()
+		}
+	})
+	return errs
+}
+
+// Pull images like PullImages, done is called for every image when its pull finished
+func pullImages(ctx context.Context, images []ProcessImage, force bool, concurrency int, done func(image string, err error)) {
+	if len(images) == 0 {
+		return
+	}
+	c, err := controllers.NewDockerController()
+	if err != nil {
+		for _, img := range images {
+			done(img.Image, err)
+		}
+		return
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, img := range images {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(img ProcessImage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var err error
+			if force {
+				err = c.ImagePull(ctx, img.Image, img.Platform, img.Auth, false)
+			} else {
+				err = c.EnsureImage(ctx, img.Image, img.Platform, img.Auth, false)
+			}
+			done(img.Image, err)
+		}(img)
+	}
+	wg.Wait()
+}
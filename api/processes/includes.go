@@ -0,0 +1,217 @@
+package processes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tag of spec values replaced by the content of another YAML file, e.g. `envVars: !include ../_shared/env.yml`.
+// Paths are relative to the including file. An included list in a list is spliced into it,
+// so that shared items can be combined with the items of the process.
+const includeTag = "!include"
+
+// Resolves includes of a spec file, included files must be inside one of roots
+type includeResolver struct {
+	roots []string
+	stack []string // files being resolved, to detect cycles
+}
+
+// parseSpec parses a spec document, replaces includes by the content of the included files and expands merge keys.
+// file is the path of the spec, includes are errors if it is empty or roots is empty.
+func parseSpec(data []byte, file string, roots []string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	r := includeResolver{roots: roots}
+	if file != "" {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		r.stack = []string{abs}
+	}
+	if err := r.resolve(&doc); err != nil {
+		return nil, err
+	}
+	expandMerges(&doc, make(map[*yaml.Node]bool))
+	dropExtensionFields(&doc)
+	return &doc, nil
+}
+
+// Top level keys starting with `x-` hold anchors of blocks shared within the spec, like extension fields of compose files.
+// They are removed after merges are expanded, aliases keep pointing to their nodes.
+func dropExtensionFields(doc *yaml.Node) {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	root := doc.Content[0]
+	content := make([]*yaml.Node, 0, len(root.Content))
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if !strings.HasPrefix(root.Content[i].Value, "x-") {
+			content = append(content, root.Content[i], root.Content[i+1])
+		}
+	}
+	root.Content = content
+}
+
+func (r *includeResolver) resolve(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.AliasNode:
+		// resolved where the anchor is defined
+		return nil
+	case yaml.ScalarNode:
+		if n.Tag != includeTag {
+			return nil
+		}
+		included, err := r.include(n)
+		if err != nil {
+			return err
+		}
+		*n = *included
+		return nil
+	}
+
+	content := make([]*yaml.Node, 0, len(n.Content))
+	for _, c := range n.Content {
+		if n.Kind == yaml.SequenceNode && c.Kind == yaml.ScalarNode && c.Tag == includeTag {
+			included, err := r.include(c)
+			if err != nil {
+				return err
+			}
+			if included.Kind == yaml.SequenceNode {
+				content = append(content, included.Content...)
+			} else {
+				content = append(content, included)
+			}
+			continue
+		}
+		if err := r.resolve(c); err != nil {
+			return err
+		}
+		content = append(content, c)
+	}
+	n.Content = content
+	return nil
+}
+
+// Load the file of an include node, the returned node is the root of the included document
+func (r *includeResolver) include(n *yaml.Node) (*yaml.Node, error) {
+	fail := func(format string, a ...interface{}) error {
+		return SpecErrors{{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, a...)}}
+	}
+	if len(r.stack) == 0 || len(r.roots) == 0 {
+		return nil, fail("%s is only supported in spec files of plugin directories", includeTag)
+	}
+	if n.Value == "" {
+		return nil, fail("%s requires a file path", includeTag)
+	}
+
+	current := r.stack[len(r.stack)-1]
+	path := filepath.Clean(n.Value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(current), path)
+	}
+	if !r.allowed(path) {
+		return nil, fail("could not include %s: file is not in a plugin directory", n.Value)
+	}
+	if slices.Contains(r.stack, path) {
+		return nil, fail("could not include %s: include cycle", n.Value)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fail("could not include %s: %s", n.Value, err.Error())
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fail("could not include %s: %s", n.Value, err.Error())
+	}
+	if len(doc.Content) == 0 {
+		return nil, fail("could not include %s: file is empty", n.Value)
+	}
+	r.stack = append(r.stack, path)
+	err = r.resolve(doc.Content[0])
+	r.stack = r.stack[:len(r.stack)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Content[0], nil
+}
+
+// Included files must not leave the plugin directories, specs added through the API must not read arbitrary files
+func (r *includeResolver) allowed(path string) bool {
+	for _, root := range r.roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(abs, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace merge keys (`<<: *defaults`, `<<: [*a, *b]`) by the keys of the merged mappings that the mapping does not set itself,
+// earlier mappings of a list take precedence like in YAML 1.1. The schema validator then sees the keys the spec decodes to.
+func expandMerges(n *yaml.Node, done map[*yaml.Node]bool) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n == nil || done[n] {
+		return
+	}
+	done[n] = true
+	for _, c := range n.Content {
+		expandMerges(c, done)
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	var own, sources []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge" {
+			if value.Kind == yaml.SequenceNode {
+				sources = append(sources, value.Content...)
+			} else {
+				sources = append(sources, value)
+			}
+			continue
+		}
+		own = append(own, key, value)
+	}
+	if sources == nil {
+		return
+	}
+
+	set := make(map[string]bool)
+	for i := 0; i < len(own); i += 2 {
+		set[own[i].Value] = true
+	}
+	for _, src := range sources {
+		if src.Kind == yaml.AliasNode {
+			src = src.Alias
+		}
+		if src.Kind != yaml.MappingNode {
+			// left for the decoder to report
+			own = append(own, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!merge", Value: "<<", Line: src.Line, Column: src.Column}, src)
+			continue
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			if key := src.Content[i]; !set[key.Value] {
+				set[key.Value] = true
+				own = append(own, key, src.Content[i+1])
+			}
+		}
+	}
+	n.Content = own
+}
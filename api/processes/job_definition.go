@@ -0,0 +1,136 @@
+package processes
+
+import (
+	"app/config"
+	"app/controllers"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Min timeout of Batch job attempts
+const minJobDefinitionTimeout = time.Minute
+
+// Characters of job definition names other than letters, numbers, hyphens and underscores
+var invalidJobDefinitionChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// JobDefinitionTemplate generates the Batch job definition of an aws-batch process from its spec when it is loaded:
+// the image is host.image and the resources are config.maxResources. A revision of host.jobDefinition is registered
+// whenever the generated job definition changes.
+type JobDefinitionTemplate struct {
+	RetryStrategy *RetryStrategy `yaml:"retryStrategy,omitempty" json:"retryStrategy,omitempty"`
+	// Duration after which attempts are terminated, at least 1m
+	Timeout          string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	JobRoleArn       string `yaml:"jobRoleArn,omitempty" json:"jobRoleArn,omitempty"`
+	ExecutionRoleArn string `yaml:"executionRoleArn,omitempty" json:"executionRoleArn,omitempty"`
+}
+
+// RetryStrategy of a job definition, attempts is between 1 and 10
+type RetryStrategy struct {
+	Attempts       int              `yaml:"attempts,omitempty" json:"attempts,omitempty"`
+	EvaluateOnExit []EvaluateOnExit `yaml:"evaluateOnExit,omitempty" json:"evaluateOnExit,omitempty"`
+}
+
+// EvaluateOnExit retries or exits a failed attempt whose exit code, reason or status reason matches a glob pattern
+type EvaluateOnExit struct {
+	OnExitCode     string `yaml:"onExitCode,omitempty" json:"onExitCode,omitempty"`
+	OnReason       string `yaml:"onReason,omitempty" json:"onReason,omitempty"`
+	OnStatusReason string `yaml:"onStatusReason,omitempty" json:"onStatusReason,omitempty"`
+	Action         string `yaml:"action" json:"action"` // RETRY or EXIT
+}
+
+func (t *JobDefinitionTemplate) validate(p *Process) error {
+	var errs []error
+	if p.Host.Type != "aws-batch" {
+		errs = append(errs, errors.New("jobDefinitionTemplate: only supported for aws-batch host type"))
+	}
+	if p.Host.Image == "" {
+		errs = append(errs, errors.New("jobDefinitionTemplate: host.image is required"))
+	}
+	if p.Config.Resources.CPUs <= 0 || p.Config.Resources.Memory <= 0 {
+		errs = append(errs, errors.New("jobDefinitionTemplate: config.maxResources cpus and memory are required"))
+	}
+	if rs := t.RetryStrategy; rs != nil {
+		if rs.Attempts != 0 && (rs.Attempts < 1 || rs.Attempts > 10) {
+			errs = append(errs, errors.New("jobDefinitionTemplate: retryStrategy attempts must be between 1 and 10"))
+		}
+		for i, e := range rs.EvaluateOnExit {
+			if e.OnExitCode == "" && e.OnReason == "" && e.OnStatusReason == "" {
+				errs = append(errs, fmt.Errorf("jobDefinitionTemplate: evaluateOnExit %d: onExitCode, onReason or onStatusReason is required", i))
+			}
+			if a := strings.ToUpper(e.Action); a != "RETRY" && a != "EXIT" {
+				errs = append(errs, fmt.Errorf("jobDefinitionTemplate: evaluateOnExit %d: action must be RETRY or EXIT", i))
+			}
+		}
+	}
+	if t.Timeout != "" {
+		if d, err := time.ParseDuration(t.Timeout); err != nil || d < minJobDefinitionTimeout {
+			errs = append(errs, fmt.Errorf("jobDefinitionTemplate: timeout must be a duration of at least %s, e.g. 2h", minJobDefinitionTimeout))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name of the job definition generated for process processID when host.jobDefinition is not set
+func defaultJobDefinitionName(processID string) string {
+	return "sepex_" + invalidJobDefinitionChars.ReplaceAllString(processID, "-")
+}
+
+// Register the job definition generated from the spec if it changed, host.jobDefinition is set to its name so that jobs
+// are submitted to its latest revision
+func (p *Process) ensureJobDefinition(c *controllers.AWSBatchController) error {
+	t := p.Host.JobDefinitionTemplate
+	if err := t.validate(p); err != nil {
+		return err
+	}
+	if p.Host.JobDefinition == "" {
+		p.Host.JobDefinition = defaultJobDefinitionName(p.Info.ID)
+	}
+
+	spec := controllers.JobDefinitionSpec{
+		Name:             p.Host.JobDefinition,
+		Image:            p.Host.Image,
+		VCPUs:            p.Config.Resources.CPUs,
+		Memory:           p.Config.Resources.Memory,
+		JobRoleArn:       t.JobRoleArn,
+		ExecutionRoleArn: t.ExecutionRoleArn,
+	}
+	if rs := t.RetryStrategy; rs != nil {
+		spec.RetryAttempts = rs.Attempts
+		for _, e := range rs.EvaluateOnExit {
+			spec.EvaluateOnExit = append(spec.EvaluateOnExit, controllers.EvaluateOnExit{
+				OnExitCode: e.OnExitCode, OnReason: e.OnReason, OnStatusReason: e.OnStatusReason, Action: strings.ToUpper(e.Action),
+			})
+		}
+	}
+	if t.Timeout != "" {
+		d, _ := time.ParseDuration(t.Timeout)
+		spec.TimeoutSeconds = int64(d.Seconds())
+	}
+
+	arn, registered, err := c.EnsureJobDefinition(spec)
+	if err != nil {
+		return err
+	}
+	if registered {
+		log.Infof("Registered job definition %s of process %s", arn, p.Info.ID)
+	}
+	return nil
+}
+
+// RegisterJobDefinition registers the job definition generated from jobDefinitionTemplate if it changed,
+// does nothing for processes without one. Processes read from spec files register it when they are read.
+func (p *Process) RegisterJobDefinition() error {
+	if p.Host.JobDefinitionTemplate == nil {
+		return nil
+	}
+	c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
+	if err != nil {
+		return err
+	}
+	return p.ensureJobDefinition(c)
+}
@@ -0,0 +1,266 @@
+package processes
+
+import (
+	"app/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Source is where a process definition was deployed from
+type Source struct {
+	Artifact string `yaml:"artifact" json:"artifact"` // e.g. oci://ghcr.io/org/process:1.0.0
+	Digest   string `yaml:"digest" json:"digest"`     // digest of the artifact manifest
+}
+
+// Media type of the process spec layer of a process artifact. Other layers, e.g. schemas and docs, are stored next to the spec.
+const ProcessSpecMediaType = "application/vnd.sepex.process.spec.v1+yaml"
+
+const (
+	ociManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
+	ociTitleAnnotation     = "org.opencontainers.image.title"
+	maxArtifactBlobSize    = 10 * 1024 * 1024
+	defaultArtifactTimeout = time.Minute
+)
+
+// Artifact is a pulled process artifact, Files maps file names (title annotations of layers) to their content
+type Artifact struct {
+	Source
+	SpecFile string
+	Files    map[string][]byte
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+type artifactRef struct {
+	registry, repository, reference string
+}
+
+// Parse oci://<registry>/<repository>[:<tag>|@<digest>], the tag defaults to latest
+func parseArtifactRef(ref string) (artifactRef, error) {
+	rest, ok := strings.CutPrefix(ref, "oci://")
+	if !ok {
+		return artifactRef{}, errors.New("artifact reference must start with oci://")
+	}
+	registry, repo, ok := strings.Cut(rest, "/")
+	if !ok || registry == "" || repo == "" {
+		return artifactRef{}, fmt.Errorf("invalid artifact reference %s, expected oci://<registry>/<repository>:<tag>", ref)
+	}
+
+	r := artifactRef{registry: registry, repository: repo, reference: "latest"}
+	if name, digest, ok := strings.Cut(repo, "@"); ok {
+		r.repository, r.reference = name, digest
+	} else if i := strings.LastIndex(repo, ":"); i > 0 {
+		r.repository, r.reference = repo[:i], repo[i+1:]
+	}
+	if r.repository == "" || r.reference == "" {
+		return artifactRef{}, fmt.Errorf("invalid artifact reference %s", ref)
+	}
+	return r, nil
+}
+
+// ValidateArtifactRef checks that ref is a valid oci:// artifact reference
+func ValidateArtifactRef(ref string) error {
+	_, err := parseArtifactRef(ref)
+	return err
+}
+
+// PullArtifact downloads a process artifact from an OCI registry and verifies the digests of its manifest and layers.
+// Registries are accessed anonymously unless OCI_REGISTRY_USERNAME and OCI_REGISTRY_PASSWORD are set.
+func PullArtifact(ctx context.Context, ref string) (Artifact, error) {
+	r, err := parseArtifactRef(ref)
+	if err != nil {
+		return Artifact{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultArtifactTimeout)
+	defer cancel()
+	rc := &registryClient{
+		registry:  r.registry,
+		username:  config.Get().Plugins.OCI.Username,
+		password:  config.Get().Plugins.OCI.Password,
+		plainHTTP: config.Get().Plugins.OCI.PlainHTTP,
+	}
+
+	body, err := rc.get(ctx, fmt.Sprintf("/v2/%s/manifests/%s", r.repository, r.reference), ociManifestMediaType)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("could not fetch manifest: %s", err.Error())
+	}
+	digest := sha256Digest(body)
+	if strings.HasPrefix(r.reference, "sha256:") && r.reference != digest {
+		return Artifact{}, fmt.Errorf("manifest digest %s does not match %s", digest, r.reference)
+	}
+
+	var m ociManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Artifact{}, fmt.Errorf("invalid manifest: %s", err.Error())
+	}
+
+	a := Artifact{Source: Source{Artifact: ref, Digest: digest}, Files: make(map[string][]byte)}
+	for _, l := range m.Layers {
+		name := path.Base(l.Annotations[ociTitleAnnotation])
+		if name == "." || name == "/" {
+			return Artifact{}, fmt.Errorf("layer %s has no %s annotation", l.Digest, ociTitleAnnotation)
+		}
+		if l.Size > maxArtifactBlobSize {
+			return Artifact{}, fmt.Errorf("layer %s is larger than %d bytes", name, maxArtifactBlobSize)
+		}
+		blob, err := rc.get(ctx, fmt.Sprintf("/v2/%s/blobs/%s", r.repository, l.Digest), "")
+		if err != nil {
+			return Artifact{}, fmt.Errorf("could not fetch layer %s: %s", name, err.Error())
+		}
+		if sha256Digest(blob) != l.Digest {
+			return Artifact{}, fmt.Errorf("digest of layer %s does not match manifest", name)
+		}
+		a.Files[name] = blob
+
+		isYAML := strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+		if l.MediaType == ProcessSpecMediaType || (a.SpecFile == "" && isYAML) {
+			a.SpecFile = name
+		}
+	}
+	if a.SpecFile == "" {
+		return Artifact{}, errors.New("artifact has no process spec YAML layer")
+	}
+	return a, nil
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Minimal client of the OCI distribution API, supports anonymous, basic and bearer token auth
+type registryClient struct {
+	registry           string
+	username, password string
+	plainHTTP          bool // for local registries without TLS
+	token              string
+}
+
+func (rc *registryClient) get(ctx context.Context, p, accept string) ([]byte, error) {
+	resp, err := rc.do(ctx, p, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := rc.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		resp, err = rc.do(ctx, p, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry responded with %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxArtifactBlobSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxArtifactBlobSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxArtifactBlobSize)
+	}
+	return body, nil
+}
+
+func (rc *registryClient) do(ctx context.Context, p, accept string) (*http.Response, error) {
+	scheme := "https://"
+	if rc.plainHTTP {
+		scheme = "http://"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+rc.registry+p, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if rc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rc.token)
+	} else if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// Get a bearer token for the challenge of a 401 response, see https://distribution.github.io/distribution/spec/auth/token/
+func (rc *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		if rc.username == "" {
+			return errors.New("registry requires credentials, set OCI_REGISTRY_USERNAME and OCI_REGISTRY_PASSWORD")
+		}
+		return errors.New("registry rejected credentials")
+	}
+
+	values := url.Values{}
+	realm := ""
+	for _, kv := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		v = strings.Trim(v, `"`)
+		if k == "realm" {
+			realm = v
+		} else if k == "service" || k == "scope" {
+			values.Set(k, v)
+		}
+	}
+	if realm == "" {
+		return errors.New("registry sent bearer challenge without realm")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get registry token: %s", resp.Status)
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return fmt.Errorf("could not decode registry token: %s", err.Error())
+	}
+	rc.token = t.Token
+	if rc.token == "" {
+		rc.token = t.AccessToken
+	}
+	if rc.token == "" {
+		return errors.New("registry token response has no token")
+	}
+	return nil
+}
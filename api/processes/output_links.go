@@ -0,0 +1,73 @@
+package processes
+
+import (
+	"app/utils"
+	"strings"
+)
+
+// LinkOutputs returns results with the values of outputs that declare a media type as links `{"href": ..., "type": ...}`,
+// as in results documents of OGC API - Processes, so that clients know the format of referenced files without sniffing.
+// URL values of such outputs are wrapped, `{"href": ...}` objects get the type unless they have one. Arrays are handled per element,
+// results that are not an object and values of other outputs are returned unchanged.
+func (p Process) LinkOutputs(results interface{}) interface{} {
+	m, ok := results.(map[string]interface{})
+	if !ok {
+		return results
+	}
+	mediaTypes := p.OutputMediaTypes()
+	if len(mediaTypes) == 0 {
+		return results
+	}
+
+	linked := make(map[string]interface{}, len(m))
+	for id, v := range m {
+		mt, ok := mediaTypes[id]
+		if !ok {
+			linked[id] = v
+			continue
+		}
+		if arr, ok := v.([]interface{}); ok {
+			values := make([]interface{}, len(arr))
+			for i, e := range arr {
+				values[i] = outputLink(e, mt)
+			}
+			linked[id] = values
+			continue
+		}
+		linked[id] = outputLink(v, mt)
+	}
+	return linked
+}
+
+// OutputMediaTypes returns the media types declared by outputs by output ID, aliases are resolved
+func (p Process) OutputMediaTypes() map[string]string {
+	mediaTypes := make(map[string]string)
+	for _, o := range p.Outputs {
+		if o.MediaType != "" {
+			mediaTypes[o.ID] = utils.ResolveMediaType(o.MediaType)
+		}
+	}
+	return mediaTypes
+}
+
+// Link of a single output value, values that are not references are returned unchanged
+func outputLink(v interface{}, mediaType string) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if strings.Contains(vv, "://") {
+			return map[string]interface{}{"href": vv, "type": mediaType}
+		}
+	case map[string]interface{}:
+		if _, ok := vv["href"].(string); ok {
+			if _, ok := vv["type"]; !ok {
+				l := make(map[string]interface{}, len(vv)+1)
+				for k, e := range vv {
+					l[k] = e
+				}
+				l["type"] = mediaType
+				return l
+			}
+		}
+	}
+	return v
+}
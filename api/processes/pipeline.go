@@ -0,0 +1,306 @@
+package processes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Host type of processes that compose other processes, their jobs run the steps of Pipeline
+const HostPipeline = "pipeline"
+
+// Pipeline composes processes into steps that run one after the other, every step runs as a job of its process.
+// Values of step inputs and pipeline outputs can reference the inputs of the pipeline and the results of earlier steps,
+// e.g. `{{ inputs.dem }}` or `{{ steps.clip.outputs.raster }}`, see Resolve.
+type Pipeline struct {
+	Steps []PipelineStep `yaml:"steps" json:"steps"`
+	// Results of pipeline jobs by output ID, the results of the last step if not set
+	Outputs map[string]interface{} `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+}
+
+// PipelineStep runs process with inputs. With forEach the step fans out: it runs once per element of the referenced array,
+// `{{ item }}` is the element, and its results are the list of results of the runs in the order of the elements.
+type PipelineStep struct {
+	ID      string                 `yaml:"id" json:"id"`
+	Process string                 `yaml:"process" json:"process"`
+	Inputs  map[string]interface{} `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	ForEach string                 `yaml:"forEach,omitempty" json:"forEach,omitempty"`
+}
+
+// PipelineScope holds the values references of a pipeline are resolved against
+type PipelineScope struct {
+	Inputs map[string]interface{}
+	// Results of finished steps by step ID
+	Steps map[string]interface{}
+	// Element of the array of a fan-out step
+	Item interface{}
+}
+
+// A reference is a dot separated path in double braces, elements of arrays are referenced by their index
+var pipelineRef = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_\-]+(?:\.[A-Za-z0-9_\-]+)*)\s*\}\}`)
+
+var stepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// Resolve replaces references in v, strings of maps and arrays are resolved recursively.
+// A string that is a single reference is replaced by the referenced value, which can be of any type.
+// References inside longer strings are replaced by their value, which must be a string, number or boolean.
+func Resolve(v interface{}, scope PipelineScope) (interface{}, error) {
+	switch vv := v.(type) {
+	case string:
+		return resolveString(vv, scope)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			r, err := Resolve(e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, e := range vv {
+			r, err := Resolve(e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func resolveString(s string, scope PipelineScope) (interface{}, error) {
+	if m := pipelineRef.FindStringSubmatch(s); m != nil && m[0] == strings.TrimSpace(s) {
+		return scope.lookup(m[1])
+	}
+
+	var err error
+	out := pipelineRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		var v interface{}
+		v, err = scope.lookup(pipelineRef.FindStringSubmatch(ref)[1])
+		if err != nil {
+			return ""
+		}
+		switch vv := v.(type) {
+		case string:
+			return vv
+		case bool:
+			return strconv.FormatBool(vv)
+		case float64:
+			return strconv.FormatFloat(vv, 'f', -1, 64)
+		case int:
+			return strconv.Itoa(vv)
+		case json.Number:
+			return vv.String()
+		default:
+			err = fmt.Errorf("%s: %T values can only be referenced as the whole value", ref, v)
+			return ""
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Value of the reference path
+func (s PipelineScope) lookup(path string) (interface{}, error) {
+	parts := strings.Split(path, ".")
+	var v interface{}
+	var rest []string
+	switch parts[0] {
+	case "inputs":
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("{{ %s }}: reference an input, e.g. inputs.<id>", path)
+		}
+		var ok bool
+		if v, ok = s.Inputs[parts[1]]; !ok {
+			return nil, fmt.Errorf("{{ %s }}: input %s is not set", path, parts[1])
+		}
+		rest = parts[2:]
+	case "steps":
+		if len(parts) < 3 || parts[2] != "outputs" {
+			return nil, fmt.Errorf("{{ %s }}: reference the outputs of a step, e.g. steps.<id>.outputs", path)
+		}
+		var ok bool
+		if v, ok = s.Steps[parts[1]]; !ok {
+			return nil, fmt.Errorf("{{ %s }}: step %s has not run", path, parts[1])
+		}
+		rest = parts[3:]
+	case "item":
+		v, rest = s.Item, parts[1:]
+	default:
+		return nil, fmt.Errorf("{{ %s }}: references start with inputs, steps or item", path)
+	}
+
+	for i, p := range rest {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			e, ok := vv[p]
+			if !ok {
+				return nil, fmt.Errorf("{{ %s }}: %s not found", path, strings.Join(parts[:len(parts)-len(rest)+i+1], "."))
+			}
+			v = e
+		case []interface{}:
+			idx, err := strconv.Atoi(p)
+			if err != nil || idx < 0 || idx >= len(vv) {
+				return nil, fmt.Errorf("{{ %s }}: %s is not an index of an array of %d elements", path, p, len(vv))
+			}
+			v = vv[idx]
+		default:
+			return nil, fmt.Errorf("{{ %s }}: %s is neither an object nor an array", path, strings.Join(parts[:len(parts)-len(rest)+i], "."))
+		}
+	}
+	return v, nil
+}
+
+// StepIDs returns the IDs of the steps in order
+func (pl Pipeline) StepIDs() []string {
+	ids := make([]string, len(pl.Steps))
+	for i, s := range pl.Steps {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+// StepInputs returns the inputs of a run of step i
+func (pl Pipeline) StepInputs(i int, scope PipelineScope) (map[string]interface{}, error) {
+	step := pl.Steps[i]
+	v, err := Resolve(step.Inputs, scope)
+	if err != nil {
+		return nil, fmt.Errorf("step %s: %v", step.ID, err)
+	}
+	inputs, _ := v.(map[string]interface{})
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	return inputs, nil
+}
+
+// Items returns the elements step i fans out over, nil if it does not fan out
+func (pl Pipeline) Items(i int, scope PipelineScope) ([]interface{}, error) {
+	step := pl.Steps[i]
+	if step.ForEach == "" {
+		return nil, nil
+	}
+	v, err := resolveString(step.ForEach, scope)
+	if err != nil {
+		return nil, fmt.Errorf("step %s: forEach %v", step.ID, err)
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("step %s: forEach must reference an array, found %T", step.ID, v)
+	}
+	return items, nil
+}
+
+// Results returns the results of a pipeline job once all steps ran
+func (pl Pipeline) Results(scope PipelineScope) (interface{}, error) {
+	if len(pl.Outputs) == 0 {
+		return scope.Steps[pl.Steps[len(pl.Steps)-1].ID], nil
+	}
+	v, err := Resolve(pl.Outputs, scope)
+	if err != nil {
+		return nil, fmt.Errorf("outputs: %v", err)
+	}
+	return v, nil
+}
+
+// Check steps and references of the pipeline of process p, processes of steps are checked when jobs are submitted
+// since they may be registered after the pipeline
+func (pl *Pipeline) validate(p *Process) error {
+	var errs []error
+	if len(pl.Steps) == 0 {
+		errs = append(errs, errors.New("pipeline: at least one step is required"))
+	}
+
+	inputs := make(map[string]bool, len(p.Inputs))
+	for _, i := range p.Inputs {
+		inputs[i.ID] = true
+	}
+	steps := make(map[string]bool, len(pl.Steps))
+	for i, s := range pl.Steps {
+		if !stepIDPattern.MatchString(s.ID) {
+			errs = append(errs, fmt.Errorf("pipeline steps %d: id must only have letters, digits, _ and -", i))
+		} else if steps[s.ID] {
+			errs = append(errs, fmt.Errorf("pipeline steps %d: step %s is defined more than once", i, s.ID))
+		}
+		if s.Process == p.Info.ID {
+			errs = append(errs, fmt.Errorf("pipeline steps %d: a pipeline can not run itself", i))
+		}
+		if s.ForEach != "" {
+			if m := pipelineRef.FindStringSubmatch(s.ForEach); m == nil || m[0] != strings.TrimSpace(s.ForEach) {
+				errs = append(errs, fmt.Errorf("pipeline steps %d: forEach must be a single reference, e.g. {{ inputs.files }}", i))
+			} else if err := checkRefs(s.ForEach, inputs, steps, false); err != nil {
+				errs = append(errs, fmt.Errorf("pipeline steps %d: forEach %v", i, err))
+			}
+		}
+		if err := checkRefs(s.Inputs, inputs, steps, s.ForEach != ""); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline steps %d: inputs %v", i, err))
+		}
+		steps[s.ID] = true
+	}
+
+	outputs := make(map[string]bool, len(p.Outputs))
+	for _, o := range p.Outputs {
+		outputs[o.ID] = true
+	}
+	for id, v := range pl.Outputs {
+		if len(p.Outputs) > 0 && !outputs[id] {
+			errs = append(errs, fmt.Errorf("pipeline outputs: %s is not an output of the process", id))
+		}
+		if err := checkRefs(v, inputs, steps, false); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline outputs %s: %v", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check that references in v only use declared inputs, earlier steps and item if allowed
+func checkRefs(v interface{}, inputs, steps map[string]bool, item bool) error {
+	switch vv := v.(type) {
+	case string:
+		for _, m := range pipelineRef.FindAllStringSubmatch(vv, -1) {
+			parts := strings.Split(m[1], ".")
+			switch {
+			case parts[0] == "inputs" && len(parts) > 1:
+				if !inputs[parts[1]] {
+					return fmt.Errorf("%s: %s is not an input of the process", m[0], parts[1])
+				}
+			case parts[0] == "steps" && len(parts) > 2 && parts[2] == "outputs":
+				if !steps[parts[1]] {
+					return fmt.Errorf("%s: %s is not an earlier step", m[0], parts[1])
+				}
+			case parts[0] == "item":
+				if !item {
+					return fmt.Errorf("%s: item is only available in inputs of steps with forEach", m[0])
+				}
+			default:
+				return fmt.Errorf("%s: references are inputs.<id>, steps.<id>.outputs or item", m[0])
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range vv {
+			if err := checkRefs(e, inputs, steps, item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range vv {
+			if err := checkRefs(e, inputs, steps, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,17 +1,130 @@
 package processes
 
+import "app/utils"
+
 type processDescription struct {
 	Info    `json:"info"`
 	Command []string  `json:"command,omitempty"`
 	Inputs  []Inputs  `json:"inputs"`
 	Outputs []Outputs `json:"outputs"`
 	Links   []Link    `json:"links"`
+	Source  *Source   `json:"source,omitempty"`
 }
 
 func (p Process) Describe() (processDescription, error) {
+	// media type aliases are resolved so that clients only see media types
+	outputs := make([]Outputs, len(p.Outputs))
+	for i, o := range p.Outputs {
+		o.MediaType = utils.ResolveMediaType(o.MediaType)
+		outputs[i] = o
+	}
 	pd := processDescription{
-		Info: p.Info, Command: p.Command, Inputs: p.Inputs, Outputs: p.Outputs,
+		Info: p.Info, Command: p.Command, Inputs: p.Inputs, Outputs: outputs, Source: p.Source,
 	} // Links: p.createLinks()
 
 	return pd, nil
 }
+
+// Description profiles, ProfileOGC is the shape of the OGC API - Processes 1.0 process description with inputs and outputs
+// as maps of JSON schemas, as consumed by pygeoapi, GeoServer and most OGC clients
+const (
+	ProfileSepex = "sepex"
+	ProfileOGC   = "ogc"
+)
+
+type ogcProcessDescription struct {
+	ID                 string               `json:"id"`
+	Title              string               `json:"title"`
+	Description        string               `json:"description"`
+	Version            string               `json:"version"`
+	Keywords           []string             `json:"keywords,omitempty"`
+	JobControlOptions  []string             `json:"jobControlOptions"`
+	OutputTransmission []string             `json:"outputTransmission"`
+	Inputs             map[string]ogcInput  `json:"inputs"`
+	Outputs            map[string]ogcOutput `json:"outputs"`
+	Links              []Link               `json:"links"`
+}
+
+type ogcInput struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	MinOccurs   int                    `json:"minOccurs"`
+	MaxOccurs   interface{}            `json:"maxOccurs"` // "unbounded" or a number
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+type ogcOutput struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+// DescribeOGC returns the process description in the OGC profile. Literal data domains are converted to JSON schemas,
+// `value` and `string` data types are strings, possible values are an enum. Outputs echoing an input have the schema of the input,
+// outputs with a media type are URIs with contentMediaType, other outputs are strings. Outputs declaring a schema have that schema.
+func (p Process) DescribeOGC() ogcProcessDescription {
+	pd := ogcProcessDescription{
+		ID: p.Info.ID, Title: p.Info.Title, Description: p.Info.Description, Version: p.Info.Version,
+		Keywords: p.Info.Keywords, JobControlOptions: p.Info.JobControlOptions, OutputTransmission: p.Info.OutputTransmission,
+		Inputs: make(map[string]ogcInput, len(p.Inputs)), Outputs: make(map[string]ogcOutput, len(p.Outputs)), Links: []Link{},
+	}
+	if pd.JobControlOptions == nil {
+		pd.JobControlOptions = []string{}
+	}
+	if pd.OutputTransmission == nil {
+		pd.OutputTransmission = []string{}
+	}
+
+	for _, i := range p.Inputs {
+		var maxOccurs interface{} = "unbounded"
+		if i.MaxOccurs > 0 {
+			maxOccurs = i.MaxOccurs
+		}
+		pd.Inputs[i.ID] = ogcInput{
+			Title: i.Title, Description: i.Description, MinOccurs: i.MinOccurs, MaxOccurs: maxOccurs,
+			Schema: literalSchema(i.Input.LiteralDataDomain),
+		}
+	}
+	for _, o := range p.Outputs {
+		schema := map[string]interface{}{"type": "string"}
+		for _, i := range p.Inputs {
+			if o.InputID != "" && i.ID == o.InputID {
+				schema = literalSchema(i.Input.LiteralDataDomain)
+			}
+		}
+		if o.MediaType != "" {
+			// outputs with a media type reference files, see LinkOutputs
+			schema = map[string]interface{}{"type": "string", "format": "uri", "contentMediaType": utils.ResolveMediaType(o.MediaType)}
+		}
+		if o.Schema != nil {
+			schema = o.Schema
+		}
+		pd.Outputs[o.ID] = ogcOutput{Title: o.Title, Description: o.Description, Schema: schema}
+	}
+	return pd
+}
+
+// JSON schema of a literal data domain
+func literalSchema(d LiteralDataDomain) map[string]interface{} {
+	schema := make(map[string]interface{})
+	switch d.DataType {
+	case "", "value", "string":
+		schema["type"] = "string"
+	case "integer", "number", "boolean", "array", "object":
+		schema["type"] = d.DataType
+	case "int":
+		schema["type"] = "integer"
+	case "float", "double":
+		schema["type"] = "number"
+	case "bool":
+		schema["type"] = "boolean"
+	default:
+		// unknown data types are passed as strings
+		schema["type"] = "string"
+		schema["format"] = d.DataType
+	}
+	if !d.ValueDefinition.AnyValue && len(d.ValueDefinition.PossibleValues) > 0 {
+		schema["enum"] = d.ValueDefinition.PossibleValues
+	}
+	return schema
+}
@@ -3,16 +3,21 @@
 package processes
 
 import (
+	"app/config"
 	"app/controllers"
+	"app/utils"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/labstack/gommon/log"
-	"gopkg.in/yaml.v3"
+	"github.com/docker/docker/api/types/mount"
+	log "github.com/sirupsen/logrus"
 )
 
 type Process struct {
@@ -20,8 +25,40 @@ type Process struct {
 	Host    Host      `yaml:"host" json:"host"`
 	Command []string  `yaml:"command" json:"command,omitempty"`
 	Config  Config    `yaml:"config" json:"config"`
+	Access  *Access   `yaml:"access,omitempty" json:"access,omitempty"`
 	Inputs  []Inputs  `yaml:"inputs" json:"inputs"`
 	Outputs []Outputs `yaml:"outputs" json:"outputs"`
+	// Set for processes deployed from an OCI artifact
+	Source *Source `yaml:"source,omitempty" json:"source,omitempty"`
+	// Run by POST /processes/{processID}/selftest and when processes are loaded with PROCESS_SELFTEST_ON_LOAD
+	HealthCheck *HealthCheck `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
+	// Steps of processes with host type pipeline
+	Pipeline *Pipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// Access restricts who may describe and execute a process, users need at least one of the roles or groups.
+// Admins always have access.
+type Access struct {
+	Roles  []string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// Allows returns true if any of the user's roles or groups is listed.
+// Groups are compared without leading slash since Keycloak may send full group paths.
+func (a Access) Allows(roles, groups []string) bool {
+	for _, r := range roles {
+		if r != "" && utils.StringInSlice(r, a.Roles) {
+			return true
+		}
+	}
+	for _, g := range groups {
+		for _, allowed := range a.Groups {
+			if g != "" && strings.TrimPrefix(g, "/") == strings.TrimPrefix(allowed, "/") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type Link struct {
@@ -38,6 +75,11 @@ type Info struct {
 	Description        string   `yaml:"description" json:"description"`
 	JobControlOptions  []string `yaml:"jobControlOptions" json:"jobControlOptions"`
 	OutputTransmission []string `yaml:"outputTransmission" json:"outputTransmission"`
+	Keywords           []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+	// Categories operators group processes by, e.g. hydrology, beta; not part of OGC process summaries
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// Readiness state of the process, set in responses only
+	Readiness string `yaml:"-" json:"readiness,omitempty"`
 }
 
 type ValueDefinition struct {
@@ -73,11 +115,22 @@ type Outputs struct {
 	Description string `yaml:"description" json:"description"`
 	Output      Output `yaml:"output" json:"output"`
 	InputID     string `yaml:"inputId" json:"inputId,omitempty"`
+	// Media type of files referenced by the output, or an alias: cog, geotiff, zarr, geoparquet, flatgeobuf, geojson, netcdf
+	MediaType string `yaml:"mediaType,omitempty" json:"mediaType,omitempty"`
+	// JSON Schema of the value of the output in the results of jobs, see ResultsValidator
+	Schema map[string]interface{} `yaml:"schema,omitempty" json:"schema,omitempty"`
 }
 
 type Resources struct {
 	CPUs   float32 `yaml:"cpus" json:"cpus,omitempty"`
 	Memory int     `yaml:"memory" json:"memory,omitempty"`
+	Disk   int     `yaml:"disk" json:"disk,omitempty"` // MB of scratch space, local jobs only
+}
+
+// ResourceRequest are resources a job asks for, 0 values are not set
+type ResourceRequest struct {
+	CPUs   float32 `yaml:"cpus" json:"cpus,omitempty"`
+	Memory int     `yaml:"memory" json:"memory,omitempty"` // MB
 }
 
 type Host struct {
@@ -85,12 +138,112 @@ type Host struct {
 	JobDefinition string `yaml:"jobDefinition" json:"jobDefinition,omitempty"`
 	JobQueue      string `yaml:"jobQueue" json:"jobQueue,omitempty"`
 	Image         string `yaml:"image" json:"image"`
+	// Credentials of a private registry, see PullAuth for the global defaults
+	RegistryAuth *RegistryAuth `yaml:"registryAuth,omitempty" json:"registryAuth,omitempty"`
+	// Generates and registers the job definition of aws-batch processes instead of using an existing one
+	JobDefinitionTemplate *JobDefinitionTemplate `yaml:"jobDefinitionTemplate,omitempty" json:"jobDefinitionTemplate,omitempty"`
+	// Times aws-batch jobs are submitted again when their Batch job failed because its instance was interrupted, e.g. a reclaimed Spot instance
+	ResubmitInterrupted int `yaml:"resubmitInterrupted,omitempty" json:"resubmitInterrupted,omitempty"`
+	// Network, capabilities, root filesystem and user of the containers of docker processes
+	Security *Security `yaml:"security,omitempty" json:"security,omitempty"`
+	// Platform the image of docker processes is pulled and run for, os/arch[/variant], e.g. linux/amd64 on arm64 hosts with emulation
+	Platform string `yaml:"platform,omitempty" json:"platform,omitempty"`
+	// Entrypoint and working directory of the containers of docker processes instead of those of the image,
+	// command is passed to the entrypoint as arguments. The user is set with security.user
+	Entrypoint []string `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"`
+	WorkingDir string   `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
 }
 
 type Config struct {
 	EnvVars   []string  `yaml:"envVars" json:"envVars,omitempty"`
 	Volumes   []string  `yaml:"volumes" json:"volumes,omitempty"`
 	Resources Resources `yaml:"maxResources" json:"maxResources,omitempty"`
+	// Resources of jobs whose execute request does not ask for specific resources, maxResources if not set
+	DefaultResources *ResourceRequest `yaml:"defaultResources,omitempty" json:"defaultResources,omitempty"`
+	Notify           *Notify          `yaml:"notify,omitempty" json:"notify,omitempty"`
+	// Env variables read from secret stores when a job starts, values are never stored
+	EnvVarsFrom []EnvVarFrom `yaml:"envVarsFrom,omitempty" json:"envVarsFrom,omitempty"`
+	// Env variables of jobs by name, set from env variables of the server, literal values or secret stores
+	Env map[string]EnvValue `yaml:"env,omitempty" json:"env,omitempty"`
+	// Env variables that execute requests are allowed to set
+	AllowedEnvOverrides []string `yaml:"allowedEnvOverrides,omitempty" json:"allowedEnvOverrides,omitempty"`
+	// Checked in order against the tail of process logs to classify failed jobs
+	ErrorPatterns []ErrorPattern `yaml:"errorPatterns,omitempty" json:"errorPatterns,omitempty"`
+	// Time between SIGTERM and SIGKILL when a job is dismissed, e.g. 30s; empty kills immediately
+	StopGracePeriod string `yaml:"stopGracePeriod,omitempty" json:"stopGracePeriod,omitempty"`
+	// Return results of an identical successful job updated within deduplicateTTL (default 24h) instead of running a new job
+	Deduplicate    bool   `yaml:"deduplicate,omitempty" json:"deduplicate,omitempty"`
+	DeduplicateTTL string `yaml:"deduplicateTTL,omitempty" json:"deduplicateTTL,omitempty"`
+	// How inputs are passed to the process, args (default) appends them as JSON argument, file writes them to a file
+	InputDelivery string `yaml:"inputDelivery,omitempty" json:"inputDelivery,omitempty"`
+	// Where results are read from, pluginResults (default) parses {"plugin_results": ...} of the last process log line when
+	// they are requested, stdout stores the last JSON document written to stdout, or the line starting with resultsMarker,
+	// when the job succeeds
+	ResultsFrom   string `yaml:"resultsFrom,omitempty" json:"resultsFrom,omitempty"`
+	ResultsMarker string `yaml:"resultsMarker,omitempty" json:"resultsMarker,omitempty"`
+	// Jobs get temporary storage credentials only allowing to read objects referenced by s3:// URLs of their inputs and to
+	// write below their own results prefix, instead of credentials of the server from env variables
+	ScopedCredentials bool `yaml:"scopedCredentials,omitempty" json:"scopedCredentials,omitempty"`
+}
+
+// Input delivery options
+const (
+	InputDeliveryArgs = "args"
+	InputDeliveryFile = "file"
+)
+
+// Results sources
+const (
+	ResultsFromPluginResults = "pluginResults"
+	ResultsFromStdout        = "stdout"
+)
+
+// Default time results of a job can be reused by identical requests of processes with deduplicate enabled
+const defaultDeduplicateTTL = 24 * time.Hour
+
+// Maximum stop grace period, dismissed jobs keep running (and holding resources) for up to this long
+const maxStopGracePeriod = 10 * time.Minute
+
+// Maximum times an interrupted aws-batch job is resubmitted
+const maxResubmitInterrupted = 10
+
+// DedupTTL returns the parsed deduplicate TTL, default if not set
+func (c Config) DedupTTL() time.Duration {
+	d, err := time.ParseDuration(c.DeduplicateTTL)
+	if err != nil {
+		return defaultDeduplicateTTL
+	}
+	return d
+}
+
+// StopGrace returns the parsed stop grace period, 0 if not set
+func (c Config) StopGrace() time.Duration {
+	d, err := time.ParseDuration(c.StopGracePeriod)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ErrorPattern assigns class to a failed job if pattern (regular expression) matches its logs
+type ErrorPattern struct {
+	Class   string `yaml:"class" json:"class"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// EnvVarFrom sets env variable name from secret ref in source (secretsmanager, ssm or vault).
+// If key is set the secret is a JSON object and the value of key is used, key is required for vault.
+type EnvVarFrom struct {
+	Name   string `yaml:"name" json:"name"`
+	Source string `yaml:"source" json:"source"`
+	Ref    string `yaml:"ref" json:"ref"`
+	Key    string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
+// Notify configures who is notified when a job reaches a terminal state
+type Notify struct {
+	SlackChannel string `yaml:"slackChannel,omitempty" json:"slackChannel,omitempty"`
+	Email        string `yaml:"email,omitempty" json:"email,omitempty"` // comma separated list of addresses
 }
 
 func (p Process) Type() string {
@@ -134,15 +287,75 @@ func (p Process) VerifyInputs(inp map[string]interface{}) error {
 	return nil
 }
 
+// VerifyOutputs checks that all outputs selected in an execute request are outputs of the process
+func (p Process) VerifyOutputs(outputs map[string]interface{}) error {
+	for id := range outputs {
+		found := false
+		for _, o := range p.Outputs {
+			if o.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not a valid output of this process, use /processes/%s endpoint to get list of outputs", id, p.Info.ID)
+		}
+	}
+	return nil
+}
+
+// VerifyEnvOverrides checks that all env variables of an execute request are in the allowlist of the process
+func (p Process) VerifyEnvOverrides(env map[string]string) error {
+	for name := range env {
+		if !utils.StringInSlice(name, p.Config.AllowedEnvOverrides) {
+			return fmt.Errorf("env variable %s can not be set for this process, allowed: %v", name, p.Config.AllowedEnvOverrides)
+		}
+	}
+	return nil
+}
+
+// JobResources returns the resources of a job of the process asking for req, req is nil if the request does not ask for resources.
+// Values that are not set fall back to defaultResources and then to maxResources, values above maxResources are clamped to it
+// so that a request can be reused after the process ceiling was lowered. Scratch disk is always maxResources.disk.
+func (p Process) JobResources(req *ResourceRequest) (Resources, error) {
+	res := p.Config.Resources
+	if d := p.Config.DefaultResources; d != nil {
+		if d.CPUs > 0 {
+			res.CPUs = d.CPUs
+		}
+		if d.Memory > 0 {
+			res.Memory = d.Memory
+		}
+	}
+
+	if req != nil {
+		if req.CPUs < 0 || req.Memory < 0 {
+			return Resources{}, errors.New("'resources' cpus and memory must not be negative")
+		}
+		if req.CPUs > 0 {
+			res.CPUs = req.CPUs
+		}
+		if req.Memory > 0 {
+			res.Memory = req.Memory
+		}
+	}
+
+	if ceiling := p.Config.Resources.CPUs; ceiling > 0 && res.CPUs > ceiling {
+		res.CPUs = ceiling
+	}
+	if ceiling := p.Config.Resources.Memory; ceiling > 0 && res.Memory > ceiling {
+		res.Memory = ceiling
+	}
+	return res, nil
+}
+
+// VerifyLocalEnvars checks that env variables of the server used by envVars and env fromEnv are set
 func (p Process) VerifyLocalEnvars() error {
 	var missingEnvVars []string
-	for _, envVar := range p.Config.EnvVars {
-		// check all env vars start with process id
-		if !strings.HasPrefix(envVar, strings.ToUpper(p.Info.ID)) {
-			return fmt.Errorf("error: env variable %s does not start with %s", envVar, strings.ToUpper(p.Info.ID))
-		}
-		if os.Getenv(envVar) == "" {
-			missingEnvVars = append(missingEnvVars, envVar)
+	vars, _ := p.JobEnv()
+	for _, ev := range vars {
+		if ev.FromEnv != "" && os.Getenv(ev.FromEnv) == "" {
+			missingEnvVars = append(missingEnvVars, ev.FromEnv)
 		}
 	}
 	if len(missingEnvVars) > 0 {
@@ -151,19 +364,19 @@ func (p Process) VerifyLocalEnvars() error {
 	return nil
 }
 
-// EnsureLocalVolumes checks if the local volumes exist and creates them if not.
-// It validates each volume specification and ensures the host path is a directory.
+// EnsureLocalVolumes checks if the host directories of bind mounted volumes exist and creates them if not.
+// It validates each volume specification, see controllers.ParseVolume, named volumes are created by docker
+// when a container mounts them and tmpfs mounts have no source.
 func (p Process) EnsureLocalVolumes() (err error) {
 	for _, volumeSpec := range p.Config.Volumes {
-		// Split volume specification into source path and container path (if present)
-		parts := strings.Split(volumeSpec, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid volume specification %q: missing source path", volumeSpec)
+		m, err := controllers.ParseVolume(volumeSpec)
+		if err != nil {
+			return err
 		}
-		srcPath := strings.TrimSpace(parts[0])
-		if srcPath == "" {
-			return fmt.Errorf("invalid volume specification %q: empty source path", volumeSpec)
+		if m.Type != mount.TypeBind {
+			continue
 		}
+		srcPath := m.Source
 
 		info, err := os.Stat(srcPath)
 		if err != nil {
@@ -190,6 +403,9 @@ func (p Process) EnsureLocalVolumes() (err error) {
 type ProcessList struct {
 	List     []Process
 	InfoList []Info
+
+	// Images pulled in the background, nil if images were pulled before processes were registered
+	readiness *imageReadiness
 }
 
 func (ps *ProcessList) Get(processID string) (Process, int, error) {
@@ -201,13 +417,26 @@ func (ps *ProcessList) Get(processID string) (Process, int, error) {
 	return Process{}, 0, errors.New("process not found")
 }
 
+// MarshallProcess reads the spec file f, includes are not supported
 func MarshallProcess(f string) (Process, error) {
+	return marshallProcess(f, nil)
+}
+
+// Read the spec file f, resolving includes of files inside roots
+func marshallProcess(f string, roots []string) (Process, error) {
 	var p Process
 	data, err := os.ReadFile(f)
 	if err != nil {
 		return p, err
 	}
-	err = yaml.Unmarshal(data, &p)
+	doc, err := parseSpec(data, f, roots)
+	if err != nil {
+		return Process{}, err
+	}
+	if err := validateSpecNode(doc); err != nil {
+		return Process{}, err
+	}
+	err = doc.Decode(&p)
 	if err != nil {
 		return Process{}, err
 	}
@@ -216,17 +445,23 @@ func MarshallProcess(f string) (Process, error) {
 	// the problem with doing this here is that if the job definition is updated while we are doing this, our process info will not update
 	switch p.Host.Type {
 	case "aws-batch":
-		c, err := controllers.NewAWSBatchController(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_REGION"))
+		c, err := controllers.NewAWSBatchController(config.Get().AWS.AccessKeyID, config.Get().AWS.SecretAccessKey, config.Get().AWS.Region)
 		if err != nil {
 			return Process{}, err
 		}
+		if p.Host.JobDefinitionTemplate != nil {
+			if err := p.ensureJobDefinition(c); err != nil {
+				return Process{}, err
+			}
+		}
 		jdi, err := c.GetJobDefInfo(p.Host.JobDefinition)
 		if err != nil {
 			return Process{}, err
 		}
 		p.Host.Image = jdi.Image
-		p.Config.Resources.Memory = jdi.Memory // although we are fetching this information but is not being used anywhere or reported to users
-		p.Config.Resources.CPUs = jdi.VCPUs    // although we are fetching this information but is not being used anywhere or reported to users
+		// Resources of the job definition are the ceiling of resources execute requests can ask for
+		p.Config.Resources.Memory = jdi.Memory
+		p.Config.Resources.CPUs = jdi.VCPUs
 	case "docker", "subprocess":
 		// Set default resources if not specified in config
 		if p.Config.Resources.CPUs == 0 {
@@ -240,34 +475,144 @@ func MarshallProcess(f string) (Process, error) {
 	return p, nil
 }
 
-// Load all processes from yml files in the given directory and subdirectories.
+// Spec files of a plugin directory, yml files one level down. Directories starting with `_` hold files for includes
+// and are not loaded.
+func specFiles(dir string) ([]string, error) {
+	ymls, err := filepath.Glob(filepath.Join(dir, "*", "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	yamls, err := filepath.Glob(filepath.Join(dir, "*", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range append(ymls, yamls...) {
+		if !strings.HasPrefix(filepath.Base(filepath.Dir(f)), "_") {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Load all processes from yml files in the given plugin directories and subdirectories.
+// Directories are layers: a process of a later directory overrides the process with the same ID of earlier ones
+// and keeps its position in the list. Within a directory the first file of an ID is used, .yml before .yaml files
+// in lexical order. A spec that fails validation does not override the process of an earlier directory.
 // maxCPUs and maxMemory are resource limits for validating docker/subprocess processes.
-func LoadProcesses(dir string, maxCPUs float32, maxMemory int) (ProcessList, error) {
+// Number of process specs validated at the same time when processes are loaded
+const validateConcurrency = 8
+
+func LoadProcesses(dirs []string, maxCPUs float32, maxMemory int) (ProcessList, error) {
 	var pl ProcessList
 
-	ymls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yml", dir))
-	if err != nil {
-		return pl, err
+	type spec struct {
+		file string
+		dir  int
+		p    Process
 	}
-	yamls, err := filepath.Glob(fmt.Sprintf("%s/*/*.yaml", dir))
-	if err != nil {
-		return pl, err
+	var specs []spec
+	var images []ProcessImage
+	imageIndex := make(map[string]int)
+	for i, dir := range dirs {
+		files, err := specFiles(dir)
+		if err != nil {
+			return pl, err
+		}
+		for _, y := range files {
+			p, err := marshallProcess(y, dirs)
+			if err != nil {
+				log.Errorf("could not register process %s Error: %v", filepath.Base(y), err)
+				continue
+			}
+			specs = append(specs, spec{file: y, dir: i, p: p})
+			if p.Host.Type != "docker" {
+				continue
+			}
+			if j, ok := imageIndex[p.Host.Image]; !ok {
+				imageIndex[p.Host.Image] = len(images)
+				images = append(images, ProcessImage{Image: p.Host.Image, Auth: p.PullAuth()})
+			} else if images[j].Auth == nil {
+				images[j].Auth = p.PullAuth()
+			}
+		}
 	}
-	allYamls := append(ymls, yamls...)
+
+	// Missing images are pulled in parallel before validation checks that they exist, see IMAGE_PULL_CONCURRENCY.
+	// With IMAGE_LAZY_PULL processes are registered right away and their images are pulled in the background.
+	lazy := config.Get().Images.LazyPull
+	if lazy {
+		pl.readiness = newImageReadiness()
+		go pl.readiness.pull(images, config.Get().Images.PullConcurrency)
+	} else {
+		for img, err := range PullImages(context.TODO(), images, false, config.Get().Images.PullConcurrency) {
+			log.Errorf("could not pull image %s: %s", img, err.Error())
+		}
+	}
+
 	processes := make([]Process, 0)
+	index := make(map[string]int)     // position of process IDs in processes
+	specOf := make(map[string]string) // file of process IDs
+	inDir := make(map[string]int)     // directory of process IDs
+
+	// Specs are validated in parallel, results are kept in the order of specs so that layers override in order
+	errs := make([]error, len(specs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, validateConcurrency)
+	for i := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = specs[i].p.validate(maxCPUs, maxMemory, !lazy)
+		}(i)
+	}
+	wg.Wait()
 
-	for _, y := range allYamls {
-		p, err := MarshallProcess(y)
-		if err != nil {
-			log.Errorf("could not register process %s Error: %v", filepath.Base(y), err)
+	valid := make([]spec, 0, len(specs))
+	for i, s := range specs {
+		if errs[i] != nil {
+			log.Errorf("could not register process %s Error: %v", filepath.Base(s.file), errs[i].Error())
 			continue
 		}
-		err = p.Validate(maxCPUs, maxMemory)
-		if err != nil {
-			log.Errorf("could not register process %s Error: %v", filepath.Base(y), err.Error())
+		valid = append(valid, s)
+	}
+
+	// Health checks run in parallel like the checks of validation, a process failing its check does not override earlier layers
+	if config.Get().Plugins.SelfTestOnLoad {
+		ps := make([]Process, len(valid))
+		for i, s := range valid {
+			ps[i] = s.p
+		}
+		results := SelfTests(context.TODO(), ps)
+		passed := valid[:0]
+		for i, s := range valid {
+			if res, ok := results[i]; ok && !res.Passed {
+				log.Errorf("could not register process %s Error: health check failed: %s", filepath.Base(s.file), res.Error)
+				continue
+			}
+			passed = append(passed, s)
+		}
+		valid = passed
+	}
+
+	for _, s := range valid {
+		p, y := s.p, s.file
+		id := p.Info.ID
+		if d, ok := inDir[id]; ok && d == s.dir {
+			log.Errorf("could not register process %s Error: process %s is already defined by %s", filepath.Base(y), id, specOf[id])
 			continue
 		}
-		processes = append(processes, p)
+		inDir[id] = s.dir
+		if i, ok := index[id]; ok {
+			log.Infof("Process %s of %s overrides %s", id, y, specOf[id])
+			processes[i] = p
+		} else {
+			index[id] = len(processes)
+			processes = append(processes, p)
+		}
+		specOf[id] = y
 	}
 
 	infos := make([]Info, len(processes))
@@ -281,102 +626,244 @@ func LoadProcesses(dir string, maxCPUs float32, maxMemory int) (ProcessList, err
 	return pl, nil
 }
 
-// Validate checks if the Process has all required fields properly set.
+// LoadLayeredProcess reads `<processID>/<processID>.yml` (or .yaml) of the last of dirs that has it, found is false if none has it.
+// Used to restore the process of read-only layers when the process overriding it is deleted, the process is not validated.
+func LoadLayeredProcess(dirs []string, processID string) (p Process, found bool, err error) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, ext := range []string{".yml", ".yaml"} {
+			f := filepath.Join(dirs[i], processID, processID+ext)
+			if _, err := os.Stat(f); err != nil {
+				continue
+			}
+			p, err = marshallProcess(f, dirs)
+			return p, true, err
+		}
+	}
+	return Process{}, false, nil
+}
+
+// CheckResourceLimits returns an error if a docker or subprocess process requires more resources than the limits
+// for local job scheduling, a 0 limit is not checked. Jobs of such processes could never start.
+func (p *Process) CheckResourceLimits(maxCPUs float32, maxMemory int) error {
+	if p.Host.Type != "docker" && p.Host.Type != "subprocess" {
+		return nil
+	}
+	var errs []error
+	if maxCPUs > 0 && p.Config.Resources.CPUs > maxCPUs {
+		errs = append(errs, fmt.Errorf("process requires %.2f CPUs but max allowed is %.2f", p.Config.Resources.CPUs, maxCPUs))
+	}
+	if maxMemory > 0 && p.Config.Resources.Memory > maxMemory {
+		errs = append(errs, fmt.Errorf("process requires %dMB memory but max allowed is %dMB", p.Config.Resources.Memory, maxMemory))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks what the process schema can not, i.e. the environment (env variables, images, volumes, resource limits)
+// and values that need parsing (command templates, regular expressions, durations). Specs must be checked with
+// ValidateSpec first. All problems are returned at once.
 // maxCPUs and maxMemory are the resource limits for local job scheduling.
 // Pass 0 for both to skip resource limit validation.
 func (p *Process) Validate(maxCPUs float32, maxMemory int) error {
-	if p.Info.ID == "" {
-		return errors.New("process ID is required")
-	}
-	if p.Info.Title == "" {
-		return errors.New("process title is required")
+	return p.validate(maxCPUs, maxMemory, true)
+}
+
+// Validate the process, without ensureImage the image of docker processes is neither checked nor pulled
+func (p *Process) validate(maxCPUs float32, maxMemory int, ensureImage bool) error {
+	var errs []error
+
+	// Validate Environment Variables available
+	if err := p.VerifyLocalEnvars(); err != nil {
+		errs = append(errs, err)
 	}
-	if p.Info.Version == "" {
-		return errors.New("version is required")
+
+	// Validate image is available and host volumes could be created or exist
+	if p.Host.Type == "docker" {
+		if ensureImage {
+			if c, err := controllers.NewDockerController(); err != nil {
+				errs = append(errs, fmt.Errorf("error: %v", err))
+			} else if err := c.EnsureImage(context.TODO(), p.Host.Image, p.Host.Platform, p.PullAuth(), false); err != nil {
+				errs = append(errs, fmt.Errorf("error: %v", err))
+			}
+		}
+
+		if err := p.EnsureLocalVolumes(); err != nil {
+			errs = append(errs, fmt.Errorf("error: %v", err))
+		}
 	}
 
-	// Validate jobControlOptions
-	validJobControlOptions := map[string]bool{
-		"sync-execute":  true,
-		"async-execute": true,
+	if p.Host.RegistryAuth != nil {
+		if err := p.Host.RegistryAuth.validate(p); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	for _, option := range p.Info.JobControlOptions {
-		if !validJobControlOptions[option] {
-			return fmt.Errorf("invalid jobControlOption: %s; must be one of [sync-execute, async-execute]", option)
+	if p.Host.Platform != "" {
+		if p.Host.Type != "docker" {
+			errs = append(errs, errors.New("host.platform: only supported for docker host type"))
+		} else if !controllers.ValidPlatform(p.Host.Platform) {
+			errs = append(errs, fmt.Errorf("host.platform: invalid platform %q, use os/arch[/variant], e.g. linux/amd64", p.Host.Platform))
 		}
 	}
-
-	// Validate outputTransmission
-	validOutputTransmission := map[string]bool{
-		"reference": true,
-		"value":     true,
+	if p.Host.Security != nil {
+		if err := p.Host.Security.validate(p, ensureImage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := p.validateImageOverrides(); err != nil {
+		errs = append(errs, err)
 	}
-	for _, transmission := range p.Info.OutputTransmission {
-		if !validOutputTransmission[transmission] {
-			return fmt.Errorf("invalid outputTransmission: %s; must be one of [reference, value]", transmission)
+	if p.Host.JobDefinitionTemplate != nil {
+		if err := p.Host.JobDefinitionTemplate.validate(p); err != nil {
+			errs = append(errs, err)
+		}
+	} else if p.Host.Type == "aws-batch" && p.Host.JobDefinition == "" {
+		errs = append(errs, errors.New("host.jobDefinition: required for aws-batch unless host.jobDefinitionTemplate is set"))
+	}
+	if p.Host.ResubmitInterrupted != 0 {
+		if p.Host.Type != "aws-batch" {
+			errs = append(errs, errors.New("host.resubmitInterrupted: only supported for aws-batch host type"))
+		} else if p.Host.ResubmitInterrupted < 0 || p.Host.ResubmitInterrupted > maxResubmitInterrupted {
+			errs = append(errs, fmt.Errorf("host.resubmitInterrupted: must be between 0 and %d", maxResubmitInterrupted))
+		}
+	}
+	if p.HealthCheck != nil {
+		if err := p.HealthCheck.validate(p.Host.Type); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	// to do: use CASE: here to do each validation for right hosts
+	switch {
+	case p.Host.Type == HostPipeline && p.Pipeline == nil:
+		errs = append(errs, errors.New("pipeline: required for host type pipeline"))
+	case p.Host.Type == HostPipeline:
+		if err := p.Pipeline.validate(p); err != nil {
+			errs = append(errs, err)
+		}
+	case p.Pipeline != nil:
+		errs = append(errs, errors.New("pipeline: only supported for host type pipeline"))
+	}
 
-	// Validate Host Type
-	if p.Host.Type != "docker" && p.Host.Type != "aws-batch" && p.Host.Type != "subprocess" {
-		return errors.New("host type must be 'docker' or 'aws-batch' or 'subprocess'")
+	if err := p.CheckResourceLimits(maxCPUs, maxMemory); err != nil {
+		errs = append(errs, err)
+	}
+	if d := p.Config.DefaultResources; d != nil {
+		if d.CPUs > p.Config.Resources.CPUs || d.Memory > p.Config.Resources.Memory {
+			errs = append(errs, fmt.Errorf("defaultResources must not exceed maxResources of %.2f CPUs and %dMB memory", p.Config.Resources.CPUs, p.Config.Resources.Memory))
+		}
 	}
 
-	// Validate Container Image (if applicable)
-	if p.Host.Type == "docker" && p.Host.Image == "" {
-		return errors.New("container image is required for docker host type")
+	if err := p.validateCommandTemplate(); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Validate AWS data (if applicable)
-	if p.Host.Type == "aws-batch" && (p.Host.JobQueue == "" || p.Host.JobDefinition == "") {
-		return errors.New("job information is required for aws-batch host type")
+	if p.Config.InputDelivery == InputDeliveryFile && p.Host.Type == "aws-batch" {
+		errs = append(errs, errors.New("inputDelivery file is not supported for aws-batch host type"))
+	}
+	if p.Config.ResultsFrom == ResultsFromStdout && p.Host.Type == "pipeline" {
+		errs = append(errs, errors.New("resultsFrom stdout is not supported for pipeline host type"))
+	}
+	if p.Config.ResultsMarker != "" && p.Config.ResultsFrom != ResultsFromStdout {
+		errs = append(errs, errors.New("resultsMarker requires resultsFrom stdout"))
 	}
 
-	// Validate Environment Variables available
-	if err := p.VerifyLocalEnvars(); err != nil {
-		return fmt.Errorf("error: %v", err)
+	if p.Config.ScopedCredentials {
+		switch {
+		case p.Host.Type == "aws-batch":
+			errs = append(errs, errors.New("scopedCredentials: not supported for aws-batch, use the job role of the job definition"))
+		case p.Host.Type == HostPipeline:
+			errs = append(errs, errors.New("scopedCredentials: not supported for pipeline host type, set it on the processes of the steps"))
+		case config.Get().Storage.Service == "aws-s3" && config.Get().Storage.JobRoleARN == "":
+			errs = append(errs, errors.New("scopedCredentials: STORAGE_JOB_ROLE_ARN env variable is not set"))
+		}
 	}
 
-	// Validate Host Volume could be created or exist
-	if p.Host.Type == "docker" {
-		c, err := controllers.NewDockerController()
-		if err != nil {
-			return fmt.Errorf("error: %v", err)
+	// Validate access
+	if p.Access != nil && len(p.Access.Roles) == 0 && len(p.Access.Groups) == 0 {
+		errs = append(errs, errors.New("access: at least one role or group is required"))
+	}
+
+	for _, o := range p.Outputs {
+		if o.MediaType != "" && !utils.ValidMediaType(o.MediaType) {
+			errs = append(errs, fmt.Errorf("outputs %s: invalid mediaType %q", o.ID, o.MediaType))
 		}
-		if err := c.EnsureImage(context.TODO(), p.Host.Image, false); err != nil {
-			return fmt.Errorf("error: %v", err)
+		if o.Schema != nil {
+			if _, err := compileOutputSchema(o.Schema); err != nil {
+				errs = append(errs, fmt.Errorf("outputs %s: invalid schema: %v", o.ID, err))
+			}
 		}
+	}
 
-		if err := p.EnsureLocalVolumes(); err != nil {
-			return fmt.Errorf("error: %v", err)
+	// Validate error patterns
+	for i, ep := range p.Config.ErrorPatterns {
+		if _, err := regexp.Compile(ep.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("errorPatterns %d: invalid pattern: %v", i, err))
 		}
 	}
 
-	// Validate resource limits for local job types (docker/subprocess)
-	if p.Host.Type == "docker" || p.Host.Type == "subprocess" {
-		if maxCPUs > 0 && p.Config.Resources.CPUs > maxCPUs {
-			return fmt.Errorf("process requires %.2f CPUs but max allowed is %.2f", p.Config.Resources.CPUs, maxCPUs)
+	// Validate env variables from secret stores
+	if err := p.validateEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	envNames := make(map[string]bool)
+	vars, from := p.JobEnv()
+	for _, ev := range vars {
+		if envNames[ev.Name] {
+			errs = append(errs, fmt.Errorf("env variable %s is set more than once by envVars and env", ev.Name))
 		}
-		if maxMemory > 0 && p.Config.Resources.Memory > maxMemory {
-			return fmt.Errorf("process requires %dMB memory but max allowed is %dMB", p.Config.Resources.Memory, maxMemory)
+		envNames[ev.Name] = true
+	}
+	for _, ev := range from[len(p.Config.EnvVarsFrom):] {
+		if envNames[ev.Name] {
+			errs = append(errs, fmt.Errorf("env %s: env variable is already set by envVars", ev.Name))
+		}
+		envNames[ev.Name] = true
+	}
+	for i, ev := range p.Config.EnvVarsFrom {
+		if envNames[ev.Name] {
+			errs = append(errs, fmt.Errorf("envVarsFrom %d: env variable %s is set more than once", i, ev.Name))
+		}
+		envNames[ev.Name] = true
+		if ev.Source == controllers.SecretSourceVault && config.Get().Vault.Addr == "" {
+			errs = append(errs, fmt.Errorf("envVarsFrom %d: VAULT_ADDR env variable is not set", i))
+		}
+		// Batch container overrides are stored in job descriptions, secrets must be set in the job definition instead
+		if p.Host.Type == "aws-batch" {
+			errs = append(errs, fmt.Errorf("envVarsFrom %d: not supported for aws-batch, use secrets of the job definition", i))
+		}
+	}
+
+	// Validate env overrides, variables set by the process must not be overridden by callers
+	for _, name := range p.Config.AllowedEnvOverrides {
+		if envNames[name] {
+			errs = append(errs, fmt.Errorf("allowedEnvOverrides: %s is already set by envVars, env or envVarsFrom", name))
+		}
+	}
+	if p.Config.ScopedCredentials {
+		for _, name := range controllers.StorageCredentialsEnvVars {
+			if envNames[name] || utils.StringInSlice(name, p.Config.AllowedEnvOverrides) {
+				errs = append(errs, fmt.Errorf("scopedCredentials: env variable %s is set by the scoped credentials, remove it from envVars, env, envVarsFrom and allowedEnvOverrides", name))
+			}
 		}
 	}
 
-	// Validate Inputs
-	for i, input := range p.Inputs {
-		if input.ID == "" {
-			return fmt.Errorf("input %d: ID is required", i)
+	// Validate stop grace period
+	if p.Config.StopGracePeriod != "" {
+		d, err := time.ParseDuration(p.Config.StopGracePeriod)
+		if err != nil || d < 0 || d > maxStopGracePeriod {
+			errs = append(errs, fmt.Errorf("stopGracePeriod must be a duration between 0s and %s, e.g. 30s", maxStopGracePeriod))
+		}
+		// Batch stops containers with its own SIGTERM, SIGKILL sequence
+		if p.Host.Type == "aws-batch" {
+			errs = append(errs, errors.New("stopGracePeriod: not supported for aws-batch"))
 		}
 	}
 
-	// Validate Outputs
-	for i, output := range p.Outputs {
-		if output.ID == "" {
-			return fmt.Errorf("output %d: ID is required", i)
+	// Validate deduplication
+	if p.Config.DeduplicateTTL != "" {
+		d, err := time.ParseDuration(p.Config.DeduplicateTTL)
+		if err != nil || d <= 0 {
+			errs = append(errs, errors.New("deduplicateTTL must be a positive duration, e.g. 1h, 168h"))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,82 @@
+package processes
+
+import (
+	"app/controllers"
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Readiness states of processes, processes are ready unless their image is pulled in the background
+const (
+	ReadinessReady   = "ready"
+	ReadinessPulling = "pulling"
+	ReadinessFailed  = "failed"
+)
+
+// Readiness is whether jobs of a process can be created, Error is why the image of a failed process could not be pulled
+type Readiness struct {
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// imageReadiness tracks images of docker processes pulled in the background after processes are loaded, see IMAGE_LAZY_PULL
+type imageReadiness struct {
+	mu     sync.Mutex
+	images map[string]Readiness
+}
+
+func newImageReadiness() *imageReadiness {
+	return &imageReadiness{images: make(map[string]Readiness)}
+}
+
+// Mark images as pulling and pull the missing ones with at most concurrency pulls at a time, blocks until all are done
+func (ir *imageReadiness) pull(images []ProcessImage, concurrency int) {
+	ir.mu.Lock()
+	for _, img := range images {
+		ir.images[img.Image] = Readiness{State: ReadinessPulling}
+	}
+	ir.mu.Unlock()
+
+	pullImages(context.Background(), images, false, concurrency, func(image string, err error) {
+		r := Readiness{State: ReadinessReady}
+		if err != nil {
+			log.Errorf("could not pull image %s: %s", image, err.Error())
+			r = Readiness{State: ReadinessFailed, Error: err.Error()}
+		}
+		ir.mu.Lock()
+		ir.images[image] = r
+		ir.mu.Unlock()
+	})
+}
+
+// ReadinessOf returns the readiness of p. Images of failed processes are looked up again, so that processes
+// become ready once their image is available, e.g. after an image refresh or a process update pulled it.
+func (pl *ProcessList) ReadinessOf(p Process) Readiness {
+	ir := pl.readiness
+	if ir == nil || p.Host.Type != "docker" {
+		return Readiness{State: ReadinessReady}
+	}
+	ir.mu.Lock()
+	r, ok := ir.images[p.Host.Image]
+	ir.mu.Unlock()
+	if !ok {
+		return Readiness{State: ReadinessReady}
+	}
+	if r.State != ReadinessFailed {
+		return r
+	}
+
+	c, err := controllers.NewDockerController()
+	if err != nil {
+		return r
+	}
+	if _, err := c.GetImageDigest(p.Host.Image); err != nil {
+		return r
+	}
+	ir.mu.Lock()
+	ir.images[p.Host.Image] = Readiness{State: ReadinessReady}
+	ir.mu.Unlock()
+	return Readiness{State: ReadinessReady}
+}
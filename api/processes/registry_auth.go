@@ -0,0 +1,107 @@
+package processes
+
+import (
+	"app/config"
+	"app/controllers"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RegistryAuth are the credentials docker processes pull their image with from a private registry.
+// Either ecr gets a token with the AWS credentials of the server, or username is used with a password
+// read from env variable passwordEnv or from a secret store. Passwords are resolved when the image is pulled.
+type RegistryAuth struct {
+	ECR          bool       `yaml:"ecr,omitempty" json:"ecr,omitempty"`
+	Username     string     `yaml:"username,omitempty" json:"username,omitempty"`
+	PasswordEnv  string     `yaml:"passwordEnv,omitempty" json:"passwordEnv,omitempty"`
+	PasswordFrom *SecretRef `yaml:"passwordFrom,omitempty" json:"passwordFrom,omitempty"`
+}
+
+// SecretRef is secret ref in source (secretsmanager, ssm or vault), if key is set the secret is a JSON object and the value of key is used
+type SecretRef struct {
+	Source string `yaml:"source" json:"source"`
+	Ref    string `yaml:"ref" json:"ref"`
+	Key    string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
+func (ra *RegistryAuth) validate(p *Process) error {
+	var errs []error
+	if p.Host.Type != "docker" {
+		errs = append(errs, errors.New("registryAuth: only supported for docker host type, aws-batch pulls with the repositoryCredentials of the job definition"))
+	}
+	hasPassword := ra.PasswordEnv != "" || ra.PasswordFrom != nil
+	switch {
+	case ra.ECR && (ra.Username != "" || hasPassword):
+		errs = append(errs, errors.New("registryAuth: ecr can not be combined with username and password"))
+	case ra.ECR && !controllers.IsECRImage(p.Host.Image):
+		errs = append(errs, fmt.Errorf("registryAuth: image %s is not in an ECR registry", p.Host.Image))
+	case !ra.ECR && ra.Username == "":
+		errs = append(errs, errors.New("registryAuth: ecr or username is required"))
+	case ra.Username != "" && !hasPassword:
+		errs = append(errs, errors.New("registryAuth: passwordEnv or passwordFrom is required with username"))
+	case ra.PasswordEnv != "" && ra.PasswordFrom != nil:
+		errs = append(errs, errors.New("registryAuth: only one of passwordEnv and passwordFrom can be set"))
+	}
+
+	if ra.PasswordEnv != "" && os.Getenv(ra.PasswordEnv) == "" {
+		errs = append(errs, fmt.Errorf("registryAuth: env variable %s not found. please restart the server with it in place", ra.PasswordEnv))
+	}
+	if ra.PasswordFrom != nil && ra.PasswordFrom.Source == controllers.SecretSourceVault && config.Get().Vault.Addr == "" {
+		errs = append(errs, errors.New("registryAuth: VAULT_ADDR env variable is not set"))
+	}
+	return errors.Join(errs...)
+}
+
+// PullAuth returns how the image of the process is pulled: with registryAuth of the process if it is set,
+// else with the credentials of its registry in REGISTRY_AUTH_FILE, else with an ECR token if REGISTRY_ECR_AUTH is enabled
+// and the image is in ECR. Returns nil for anonymous pulls.
+func (p Process) PullAuth() controllers.RegistryAuthFunc {
+	if p.Host.Type != "docker" {
+		return nil
+	}
+	image := p.Host.Image
+
+	if ra := p.Host.RegistryAuth; ra != nil {
+		if ra.ECR {
+			return func(ctx context.Context) (*controllers.RegistryAuth, error) {
+				return controllers.ECRAuth(ctx, image)
+			}
+		}
+		username, passwordEnv, passwordFrom := ra.Username, ra.PasswordEnv, ra.PasswordFrom
+		return func(ctx context.Context) (*controllers.RegistryAuth, error) {
+			auth := controllers.RegistryAuth{Username: username, ServerAddress: controllers.RegistryHost(image)}
+			if passwordEnv != "" {
+				auth.Password = os.Getenv(passwordEnv)
+				return &auth, nil
+			}
+			sc, err := controllers.NewSecretsControllerFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			if auth.Password, err = sc.GetSecret(ctx, passwordFrom.Source, passwordFrom.Ref, passwordFrom.Key); err != nil {
+				return nil, fmt.Errorf("could not resolve password from %s %s: %s", passwordFrom.Source, passwordFrom.Ref, err.Error())
+			}
+			return &auth, nil
+		}
+	}
+
+	cfg := config.Get().Images
+	if cfg.RegistryAuthFile == "" && !(cfg.ECRAuth && controllers.IsECRImage(image)) {
+		return nil
+	}
+	authFile, ecrAuth := cfg.RegistryAuthFile, cfg.ECRAuth
+	return func(ctx context.Context) (*controllers.RegistryAuth, error) {
+		if authFile != "" {
+			auth, err := controllers.DockerConfigAuth(authFile, image)
+			if err != nil || auth != nil {
+				return auth, err
+			}
+		}
+		if ecrAuth && controllers.IsECRImage(image) {
+			return controllers.ECRAuth(ctx, image)
+		}
+		return nil, nil
+	}
+}
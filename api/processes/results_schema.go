@@ -0,0 +1,157 @@
+package processes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Keywords of JSON schema that only annotate values, output schemas may have them
+var schemaAnnotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly"}
+
+// Keywords of output schemas, those validated by jsonSchema and annotations
+func outputSchemaKeywords() map[string]bool {
+	keywords := make(map[string]bool)
+	t := reflect.TypeOf(jsonSchema{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keywords[name] = true
+		}
+	}
+	for _, k := range schemaAnnotations {
+		keywords[k] = true
+	}
+	return keywords
+}
+
+// Reject keywords of schema s at JSON pointer path that are not validated, e.g. oneOf or format, so that a schema
+// does not silently accept results it was meant to reject
+func checkSchemaKeywords(s map[string]interface{}, path string, keywords map[string]bool) error {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !keywords[k] {
+			return fmt.Errorf("%s: unsupported keyword %s", path, k)
+		}
+	}
+
+	// subschemas, additionalProperties can also be a boolean
+	sub := func(v interface{}, p string) error {
+		if m, ok := v.(map[string]interface{}); ok {
+			return checkSchemaKeywords(m, p, keywords)
+		}
+		return nil
+	}
+	for _, k := range []string{"items", "propertyNames", "additionalProperties", "if", "then"} {
+		if err := sub(s[k], path+"/"+k); err != nil {
+			return err
+		}
+	}
+	for _, k := range []string{"properties", "$defs"} {
+		m, _ := s[k].(map[string]interface{})
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := sub(m[name], path+"/"+k+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range []string{"allOf", "anyOf"} {
+		l, _ := s[k].([]interface{})
+		for i, e := range l {
+			if err := sub(e, fmt.Sprintf("%s/%s/%d", path, k, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compile the schema of an output, references are resolved in the $defs of the schema itself.
+// Keywords that are not supported are rejected.
+func compileOutputSchema(raw map[string]interface{}) (*jsonSchema, error) {
+	if err := checkSchemaKeywords(raw, "#", outputSchemaKeywords()); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ResultsValidator returns a function validating the results reported by jobs of the process against the schemas of its
+// outputs, nil if no output declares a schema. Results must be an object of output values by output ID, outputs that
+// are not in the results are not validated since execute requests can select outputs.
+func (p Process) ResultsValidator() func(results interface{}) error {
+	schemas := make(map[string]*jsonSchema)
+	for _, o := range p.Outputs {
+		if o.Schema == nil {
+			continue
+		}
+		// Schemas of loaded processes were compiled when they were validated
+		if s, err := compileOutputSchema(o.Schema); err == nil {
+			schemas[o.ID] = s
+		}
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	return func(results interface{}) error {
+		outputs, ok := results.(map[string]interface{})
+		if !ok {
+			return errors.New("results must be an object of outputs by output ID")
+		}
+		ids := make([]string, 0, len(schemas))
+		for id := range schemas {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		var problems []string
+		for _, id := range ids {
+			value, ok := outputs[id]
+			if !ok {
+				continue
+			}
+			// JSON is YAML, the value is validated like spec documents
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			var doc yaml.Node
+			if err := yaml.Unmarshal(b, &doc); err != nil || len(doc.Content) == 0 {
+				return fmt.Errorf("output %s: could not be read: %v", id, err)
+			}
+			v := schemaValidator{root: schemas[id]}
+			v.validate(schemas[id], doc.Content[0], id)
+			for _, e := range v.errs {
+				problems = append(problems, e.Path+": "+e.Message)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problem(s): %s", len(problems), strings.Join(problems, "; "))
+		}
+		return nil
+	}
+}
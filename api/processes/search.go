@@ -0,0 +1,56 @@
+package processes
+
+import (
+	"strings"
+)
+
+// ProcessQuery filters processes of a ProcessList, empty fields do not filter
+type ProcessQuery struct {
+	// Free text, every word must be in the ID, title, description or keywords
+	Text     string
+	HostType string
+	Keyword  string
+	Tag      string
+}
+
+// Search returns the infos of processes matching q in the order of the list. Matching is case insensitive,
+// keyword and tag must be equal to one of the keywords or tags of the process.
+func (pl *ProcessList) Search(q ProcessQuery) []Info {
+	words := strings.Fields(strings.ToLower(q.Text))
+	result := make([]Info, 0)
+	for _, p := range pl.List {
+		if q.HostType != "" && p.Host.Type != q.HostType {
+			continue
+		}
+		if q.Keyword != "" && !containsFold(p.Info.Keywords, q.Keyword) {
+			continue
+		}
+		if q.Tag != "" && !containsFold(p.Info.Tags, q.Tag) {
+			continue
+		}
+		if len(words) > 0 {
+			text := strings.ToLower(strings.Join(append([]string{p.Info.ID, p.Info.Title, p.Info.Description}, p.Info.Keywords...), " "))
+			matched := true
+			for _, w := range words {
+				if !strings.Contains(text, w) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		result = append(result, p.Info)
+	}
+	return result
+}
+
+func containsFold(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package processes
+
+import (
+	"app/controllers"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Security locks down the containers of docker processes, e.g. of untrusted images. Network none disables networking,
+// another network attaches containers to that existing network instead of the network of the server. Capabilities in
+// capDrop are dropped, readOnlyRootfs mounts the root filesystem read-only and user sets the user the process runs as.
+type Security struct {
+	Network        string   `yaml:"network,omitempty" json:"network,omitempty"`
+	CapDrop        []string `yaml:"capDrop,omitempty" json:"capDrop,omitempty"`
+	ReadOnlyRootfs bool     `yaml:"readOnlyRootfs,omitempty" json:"readOnlyRootfs,omitempty"`
+	User           string   `yaml:"user,omitempty" json:"user,omitempty"`
+}
+
+var (
+	capabilityRegex = regexp.MustCompile(`^(CAP_)?[A-Z][A-Z_]*$`)
+	userRegex       = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+)
+
+func (s *Security) validate(p *Process, checkNetwork bool) error {
+	var errs []error
+	if p.Host.Type != "docker" {
+		errs = append(errs, errors.New("security: only supported for docker host type"))
+	}
+
+	// Modes sharing namespaces of the host or other containers would undo the isolation
+	switch {
+	case s.Network == "host" || strings.HasPrefix(s.Network, "container:"):
+		errs = append(errs, fmt.Errorf("security: network %s is not allowed, use none or a custom network", s.Network))
+	case checkNetwork && s.Network != "" && s.Network != "none" && s.Network != controllers.DOCKER_NETWORK:
+		if c, err := controllers.NewDockerController(); err != nil {
+			errs = append(errs, fmt.Errorf("error: %v", err))
+		} else if err := c.NetworkInspect(context.TODO(), s.Network); err != nil {
+			errs = append(errs, fmt.Errorf("security: network %s not found, create it before the process is registered: %v", s.Network, err))
+		}
+	}
+
+	for _, capability := range s.CapDrop {
+		if capability != "ALL" && !capabilityRegex.MatchString(capability) {
+			errs = append(errs, fmt.Errorf("security: invalid capability %q in capDrop, e.g. NET_RAW or ALL", capability))
+		}
+	}
+	if s.User != "" && !userRegex.MatchString(s.User) {
+		errs = append(errs, fmt.Errorf("security: invalid user %q, use name or uid with optional group, e.g. 1000:1000", s.User))
+	}
+	return errors.Join(errs...)
+}
+
+// DockerSecurity returns the security options containers of the process run with, nil if security is not set
+func (p Process) DockerSecurity() *controllers.DockerSecurity {
+	s := p.Host.Security
+	if s == nil || p.Host.Type != "docker" {
+		return nil
+	}
+	return &controllers.DockerSecurity{
+		Network:        s.Network,
+		CapDrop:        append([]string{}, s.CapDrop...),
+		ReadOnlyRootfs: s.ReadOnlyRootfs,
+		User:           s.User,
+	}
+}
@@ -0,0 +1,224 @@
+package processes
+
+import (
+	"app/config"
+	"app/controllers"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthCheck is a lightweight command checking that the process can run, e.g. `[gdalinfo, --version]`.
+// It runs in the image of docker processes, or on the host for subprocess processes, and passes if it exits with 0.
+type HealthCheck struct {
+	Command []string `yaml:"command" json:"command"`
+	// e.g. 30s, default 60s
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Default and maximum time a health check can run before it fails
+const (
+	defaultHealthCheckTimeout = 60 * time.Second
+	maxHealthCheckTimeout     = 10 * time.Minute
+)
+
+// Resources of health check containers, lower if maxResources of the process are lower.
+// They are not reserved from the resource pool of local jobs.
+const (
+	selfTestCPUs   = 0.5
+	selfTestMemory = 256 // MB
+)
+
+// Number of health checks run at the same time when processes are loaded
+const selfTestConcurrency = 4
+
+// Lines of output kept in self test results
+const selfTestOutputLines = 20
+
+// SelfTestResult is the outcome of running the health check of a process
+type SelfTestResult struct {
+	ProcessID string    `json:"processID"`
+	Passed    bool      `json:"passed"`
+	ExitCode  *int      `json:"exitCode,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  float64   `json:"durationSeconds"`
+	// Last lines of stdout and stderr
+	Output []string `json:"output,omitempty"`
+	// Why the check could not run or failed
+	Error string `json:"error,omitempty"`
+}
+
+// timeout returns the parsed health check timeout, default if not set
+func (hc HealthCheck) timeout() time.Duration {
+	d, err := time.ParseDuration(hc.Timeout)
+	if err != nil || d <= 0 {
+		return defaultHealthCheckTimeout
+	}
+	return d
+}
+
+func (hc HealthCheck) validate(hostType string) error {
+	var errs []error
+	if len(hc.Command) == 0 || hc.Command[0] == "" {
+		errs = append(errs, errors.New("healthCheck: command is required"))
+	}
+	if hc.Timeout != "" {
+		d, err := time.ParseDuration(hc.Timeout)
+		if err != nil || d <= 0 || d > maxHealthCheckTimeout {
+			errs = append(errs, fmt.Errorf("healthCheck: timeout must be a duration between 0s and %s, e.g. 30s", maxHealthCheckTimeout))
+		}
+	}
+	if hostType == "aws-batch" || hostType == HostPipeline {
+		errs = append(errs, fmt.Errorf("healthCheck: not supported for %s host type", hostType))
+	}
+	return errors.Join(errs...)
+}
+
+// SelfTest runs the health check of the process and reports whether it passed. Env variables of envVars and env are set,
+// secrets of envVarsFrom and env are not resolved so that checks do not need access to secret stores. Missing images are pulled first.
+func (p Process) SelfTest(ctx context.Context) SelfTestResult {
+	res := SelfTestResult{ProcessID: p.Info.ID, StartedAt: time.Now()}
+	hc := p.HealthCheck
+	if hc == nil {
+		res.Error = "process has no healthCheck"
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+
+	vars, _ := p.JobEnv()
+	envs := make([]string, len(vars))
+	for i, ev := range vars {
+		v := ev.Value
+		if ev.FromEnv != "" {
+			v = os.Getenv(ev.FromEnv)
+		}
+		envs[i] = ev.Name + "=" + v
+	}
+
+	var exitCode int
+	var output []string
+	var err error
+	switch p.Host.Type {
+	case "docker":
+		exitCode, output, err = p.selfTestContainer(ctx, envs)
+	case "subprocess":
+		exitCode, output, err = selfTestSubprocess(ctx, hc.Command, envs)
+	default:
+		err = fmt.Errorf("health checks are not supported for %s host type", p.Host.Type)
+	}
+	res.Duration = time.Since(res.StartedAt).Seconds()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("health check did not finish within %s", hc.timeout())
+	}
+	if len(output) > selfTestOutputLines {
+		output = output[len(output)-selfTestOutputLines:]
+	}
+	res.Output = output
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.ExitCode = &exitCode
+	res.Passed = exitCode == 0
+	if !res.Passed {
+		res.Error = fmt.Sprintf("health check exited with %d", exitCode)
+	}
+	return res
+}
+
+func (p Process) selfTestContainer(ctx context.Context, envs []string) (int, []string, error) {
+	c, err := controllers.NewDockerController()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := c.EnsureImage(ctx, p.Host.Image, p.Host.Platform, p.PullAuth(), false); err != nil {
+		return 0, nil, fmt.Errorf("could not ensure image %s available: %s", p.Host.Image, err.Error())
+	}
+
+	cpus, memory := float32(selfTestCPUs), selfTestMemory
+	if mr := p.Config.Resources; mr.CPUs > 0 && mr.CPUs < cpus {
+		cpus = mr.CPUs
+	}
+	if mr := p.Config.Resources; mr.Memory > 0 && mr.Memory < memory {
+		memory = mr.Memory
+	}
+	resources := controllers.DockerResources{}
+	resources.NanoCPUs = int64(cpus * 1e9)
+	resources.Memory = int64(memory * 1024 * 1024)
+
+	labels := map[string]string{controllers.LabelSelfTest: p.Info.ID, controllers.LabelAPIName: config.Get().API.Name}
+	containerID, err := c.ContainerRun(ctx, p.Host.Image, p.Host.Platform, p.HealthCheck.Command, nil, envs, resources, p.DockerSecurity(), p.DockerImageOverrides(), labels)
+	if err != nil {
+		return 0, nil, err
+	}
+	// removed with a new context so that containers of timed out checks are removed too
+	defer c.ContainerRemove(context.Background(), containerID)
+
+	code, err := c.ContainerWait(ctx, containerID)
+	stdout, stderr, logErr := c.ContainerLog(context.Background(), containerID)
+	output := append(stdout, stderr...)
+	if err != nil {
+		return 0, output, err
+	}
+	if logErr != nil {
+		return 0, nil, logErr
+	}
+	return int(code), output, nil
+}
+
+func selfTestSubprocess(ctx context.Context, command []string, envs []string) (int, []string, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = envs
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	output := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if out.Len() == 0 {
+		output = nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return exitErr.ExitCode(), output, nil
+	}
+	if err != nil {
+		return 0, output, err
+	}
+	return 0, output, nil
+}
+
+// SelfTests runs health checks of processes that have one, with at most selfTestConcurrency checks at the same time.
+// Results are keyed by the index of processes in ps, processes without health check have no result.
+func SelfTests(ctx context.Context, ps []Process) map[int]SelfTestResult {
+	results := make(map[int]SelfTestResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, selfTestConcurrency)
+	for i, p := range ps {
+		if p.HealthCheck == nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, p Process) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := p.SelfTest(ctx)
+			mu.Lock()
+			results[i] = res
+			mu.Unlock()
+		}(i, p)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,378 @@
+package processes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSON Schema of process specs, served at GET /schemas/process
+//
+//go:embed process.schema.json
+var processSchemaJSON []byte
+
+// ProcessSchema returns the JSON Schema process specs are validated against, with registered host types
+func ProcessSchema() []byte {
+	if len(HostTypes()) == len(builtinHostTypes) {
+		return processSchemaJSON
+	}
+	b, err := processSchemaWithHostTypes()
+	if err != nil {
+		return processSchemaJSON
+	}
+	return b
+}
+
+// SpecError is a problem found in a process spec, Line and Column are 1-based positions in the spec document
+type SpecError struct {
+	Path    string `json:"path"` // e.g. config.maxResources.cpus, inputs[0].id
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e SpecError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+}
+
+// SpecErrors are all problems found in a process spec
+type SpecErrors []SpecError
+
+func (errs SpecErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("invalid process spec, %d problem(s): %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// ValidateSpec validates a YAML or JSON process spec against the process schema and returns all problems as SpecErrors.
+// Syntax errors are returned as is. Includes are not resolved, they are only supported in spec files of plugin directories.
+func ValidateSpec(data []byte) error {
+	doc, err := parseSpec(data, "", nil)
+	if err != nil {
+		return err
+	}
+	return validateSpecNode(doc)
+}
+
+// Validate a parsed spec document, see ValidateSpec
+func validateSpecNode(doc *yaml.Node) error {
+	root, err := loadProcessSchema()
+	if err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return SpecErrors{{Line: 1, Column: 1, Message: "spec is empty"}}
+	}
+
+	v := schemaValidator{root: root}
+	v.validate(root, doc.Content[0], "")
+	if len(v.errs) > 0 {
+		sort.SliceStable(v.errs, func(i, j int) bool { return v.errs[i].Line < v.errs[j].Line })
+		return v.errs
+	}
+	return nil
+}
+
+// Subset of JSON Schema (draft 2020-12) keywords used by process.schema.json and supported in schemas of outputs,
+// annotations like description and other keywords are ignored
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 schemaTypes            `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	PropertyNames        *jsonSchema            `json:"propertyNames"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	AllOf                []*jsonSchema          `json:"allOf"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	If                   *jsonSchema            `json:"if"`
+	Then                 *jsonSchema            `json:"then"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+
+	pattern *regexp.Regexp
+}
+
+// additionalProperties is false for objects with a fixed set of fields or a schema of the values of maps
+type additionalProperties struct {
+	allowed bool
+	schema  *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+// type can be a string or a list of strings
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*t = l
+	return nil
+}
+
+var (
+	processSchemaOnce sync.Once
+	processSchema     *jsonSchema
+	processSchemaErr  error
+)
+
+func loadProcessSchema() (*jsonSchema, error) {
+	processSchemaOnce.Do(func() {
+		var s jsonSchema
+		if err := json.Unmarshal(processSchemaJSON, &s); err != nil {
+			processSchemaErr = fmt.Errorf("invalid process schema: %s", err.Error())
+			return
+		}
+		if host, ok := s.Properties["host"]; ok {
+			if t, ok := host.Properties["type"]; ok {
+				t.Enum = hostTypeEnum()
+			}
+		}
+		if err := s.compile(); err != nil {
+			processSchemaErr = fmt.Errorf("invalid process schema: %s", err.Error())
+			return
+		}
+		processSchema = &s
+	})
+	return processSchema, processSchemaErr
+}
+
+// Compile patterns of the schema and its subschemas
+func (s *jsonSchema) compile() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	subs := []*jsonSchema{s.Items, s.If, s.Then, s.PropertyNames}
+	if s.AdditionalProperties != nil {
+		subs = append(subs, s.AdditionalProperties.schema)
+	}
+	subs = append(subs, s.AllOf...)
+	subs = append(subs, s.AnyOf...)
+	for _, p := range s.Properties {
+		subs = append(subs, p)
+	}
+	for _, d := range s.Defs {
+		subs = append(subs, d)
+	}
+	for _, sub := range subs {
+		if err := sub.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root *jsonSchema
+	errs SpecErrors
+}
+
+func (v *schemaValidator) addError(n *yaml.Node, path, format string, a ...interface{}) {
+	v.errs = append(v.errs, SpecError{Path: path, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, a...)})
+}
+
+// JSON Schema type of a YAML node
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+func typeMatches(types schemaTypes, t string) bool {
+	for _, want := range types {
+		if want == t || (want == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (v *schemaValidator) validate(s *jsonSchema, n *yaml.Node, path string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/$defs/")
+		ref := v.root.Defs[name]
+		if !ok || ref == nil {
+			v.addError(n, path, "schema reference %s not found", s.Ref)
+			return
+		}
+		v.validate(ref, n, path)
+	}
+
+	t := nodeType(n)
+	if len(s.Type) > 0 && !typeMatches(s.Type, t) {
+		if t == "null" {
+			v.addError(n, path, "must not be empty, expected %s", strings.Join(s.Type, " or "))
+		} else {
+			v.addError(n, path, "must be %s, got %s", strings.Join(s.Type, " or "), t)
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if n.Kind == yaml.ScalarNode && fmt.Sprint(e) == n.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed := make([]string, len(s.Enum))
+			for i, e := range s.Enum {
+				allowed[i] = fmt.Sprint(e)
+			}
+			v.addError(n, path, "%q is not allowed, must be one of [%s]", n.Value, strings.Join(allowed, ", "))
+		}
+	}
+
+	switch t {
+	case "string":
+		if s.MinLength != nil && len([]rune(n.Value)) < *s.MinLength {
+			if *s.MinLength == 1 {
+				v.addError(n, path, "must not be empty")
+			} else {
+				v.addError(n, path, "must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && len([]rune(n.Value)) > *s.MaxLength {
+			v.addError(n, path, "must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(n.Value) {
+			v.addError(n, path, "%q does not match %s", n.Value, s.Pattern)
+		}
+	case "integer", "number":
+		if s.Minimum != nil {
+			if f, err := strconv.ParseFloat(n.Value, 64); err == nil && f < *s.Minimum {
+				v.addError(n, path, "must be at least %v", *s.Minimum)
+			}
+		}
+		if s.Maximum != nil {
+			if f, err := strconv.ParseFloat(n.Value, 64); err == nil && f > *s.Maximum {
+				v.addError(n, path, "must be at most %v", *s.Maximum)
+			}
+		}
+	case "array":
+		if s.MinItems != nil && len(n.Content) < *s.MinItems {
+			v.addError(n, path, "must have at least %d item(s)", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(n.Content) > *s.MaxItems {
+			v.addError(n, path, "must have at most %d item(s)", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range n.Content {
+				v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "object":
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			present[key.Value] = true
+			if s.PropertyNames != nil {
+				v.validate(s.PropertyNames, key, joinPath(path, key.Value))
+			}
+			if p, ok := s.Properties[key.Value]; ok {
+				v.validate(p, value, joinPath(path, key.Value))
+			} else if ap := s.AdditionalProperties; ap != nil && !ap.allowed {
+				v.addError(key, joinPath(path, key.Value), "unknown field")
+			} else if ap != nil && ap.schema != nil {
+				v.validate(ap.schema, value, joinPath(path, key.Value))
+			}
+		}
+		for _, r := range s.Required {
+			if !present[r] {
+				v.addError(n, joinPath(path, r), "is required")
+			}
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, n, path)
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, sub := range s.AnyOf {
+			alt := schemaValidator{root: v.root}
+			alt.validate(sub, n, path)
+			if len(alt.errs) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.addError(n, path, "does not match any schema of anyOf")
+		}
+	}
+	if s.If != nil && s.Then != nil {
+		cond := schemaValidator{root: v.root}
+		cond.validate(s.If, n, path)
+		if len(cond.errs) == 0 {
+			v.validate(s.Then, n, path)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"app/auth"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// Build TLS config from the tls settings (TLS_* env variables), nil if TLS_CERT_FILE is not set and the server should use plain HTTP.
+// If TLS_CLIENT_CA_FILE is set client certificates are verified against it, optionally (default) or required with TLS_CLIENT_AUTH=require.
+// Errors are fatal.
+func initTLS(e *echo.Echo) *tls.Config {
+	certFile := cfg.TLS.CertFile
+	keyFile := cfg.TLS.KeyFile
+	caFile := cfg.TLS.ClientCAFile
+	// a key or CA without certificate is rejected by config validation
+	if certFile == "" {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Fatalf("Error loading TLS certificate: %s", err.Error())
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile == "" {
+		return tlsCfg
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		log.Fatalf("Error reading TLS_CLIENT_CA_FILE: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		log.Fatal("TLS_CLIENT_CA_FILE does not contain any PEM certificates")
+	}
+	tlsCfg.ClientCAs = pool
+
+	switch cfg.TLS.ClientAuth {
+	case "", "optional":
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		log.Fatal("TLS_CLIENT_AUTH must be 'optional' or 'require'")
+	}
+
+	certRoles, err := auth.ParseCertRoles(cfg.TLS.ClientCertRoles)
+	if err != nil {
+		log.Fatalf("Error parsing TLS_CLIENT_CERT_ROLES: %s", err.Error())
+	}
+	if len(certRoles) > 0 {
+		// must run before auth middlewares so that they skip token validation
+		clientCert := auth.ClientCert(certRoles)
+		e.Use(clientCert)
+		grpcAuth.Public = append(grpcAuth.Public, clientCert)
+		grpcAuth.Protected = append(grpcAuth.Protected, clientCert)
+	}
+	return tlsCfg
+}
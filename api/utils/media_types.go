@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// Media types of geospatial formats, as used by OGC API and STAC.
+// Zarr stores are directories, their media type is only used in links, not on objects.
+const (
+	MediaTypeCOG        = "image/tiff; application=geotiff; profile=cloud-optimized"
+	MediaTypeGeoTIFF    = "image/tiff; application=geotiff"
+	MediaTypeZarr       = "application/vnd+zarr"
+	MediaTypeGeoParquet = "application/vnd.apache.parquet"
+	MediaTypeFlatGeobuf = "application/vnd.flatgeobuf"
+	MediaTypeGeoJSON    = "application/geo+json"
+	MediaTypeNetCDF     = "application/netcdf"
+)
+
+// Short names that can be used instead of media types in process specs
+var mediaTypeAliases = map[string]string{
+	"cog":        MediaTypeCOG,
+	"geotiff":    MediaTypeGeoTIFF,
+	"zarr":       MediaTypeZarr,
+	"geoparquet": MediaTypeGeoParquet,
+	"flatgeobuf": MediaTypeFlatGeobuf,
+	"geojson":    MediaTypeGeoJSON,
+	"netcdf":     MediaTypeNetCDF,
+}
+
+// Media types of file extensions the standard library does not know or gets wrong for geospatial data.
+// .tif is not COG by extension, processes declare COG outputs with the cog media type.
+var extensionMediaTypes = map[string]string{
+	".tif":        MediaTypeGeoTIFF,
+	".tiff":       MediaTypeGeoTIFF,
+	".zarr":       MediaTypeZarr,
+	".parquet":    MediaTypeGeoParquet,
+	".geoparquet": MediaTypeGeoParquet,
+	".fgb":        MediaTypeFlatGeobuf,
+	".geojson":    MediaTypeGeoJSON,
+	".nc":         MediaTypeNetCDF,
+	".json":       "application/json",
+}
+
+// ResolveMediaType returns the media type of an alias like cog or zarr, other values are returned unchanged
+func ResolveMediaType(s string) string {
+	if mt, ok := mediaTypeAliases[strings.ToLower(s)]; ok {
+		return mt
+	}
+	return s
+}
+
+// ValidMediaType returns true if s is an alias or has the shape of a media type (type/subtype with optional parameters)
+func ValidMediaType(s string) bool {
+	if _, ok := mediaTypeAliases[strings.ToLower(s)]; ok {
+		return true
+	}
+	_, _, err := mime.ParseMediaType(s)
+	return err == nil && strings.Contains(s, "/")
+}
+
+// MediaTypeByExtension returns the media type of a file name or key by its extension, empty if it is not known
+func MediaTypeByExtension(name string) string {
+	ext := strings.ToLower(path.Ext(strings.TrimSuffix(name, "/")))
+	if ext == "" {
+		return ""
+	}
+	if mt, ok := extensionMediaTypes[ext]; ok {
+		return mt
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// GenericContentType returns true for content types that do not tell what an object is, as set by clients that do not know it
+func GenericContentType(ct string) bool {
+	switch strings.ToLower(strings.TrimSpace(ct)) {
+	case "", "binary/octet-stream", "application/octet-stream":
+		return true
+	}
+	return false
+}
@@ -1,16 +1,23 @@
 package utils
 
 import (
+	"app/config"
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 // Given bytes and an S3 location write a file on S3 with expiration policy
@@ -18,34 +25,64 @@ import (
 // If failure occurs append error message to the logs stream
 // This function does not panic to safeguard server
 func WriteToS3(svc *s3.S3, b []byte, key string, contType string, expDays int) error {
+	return UploadToS3(svc, bytes.NewReader(b), key, contType, expDays)
+}
+
+// WriteFileToS3 uploads the file at path to key, the file is streamed instead of read into memory
+func WriteFileToS3(svc *s3.S3, path string, key string, contType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return UploadToS3(svc, f, key, contType, 0)
+}
 
+// Uploads are split in parts of s3PartSize uploaded s3UploadConcurrency at a time, parts are retried s3UploadRetries times.
+// The uploader buffers at most s3UploadConcurrency parts of readers that are not seekable.
+const (
+	s3PartSize          = 16 * 1024 * 1024
+	s3UploadConcurrency = 4
+	s3UploadRetries     = 5
+)
+
+// UploadToS3 streams body to key with the S3 upload manager. Bodies larger than s3PartSize are uploaded
+// as multipart uploads that are aborted if a part fails after its retries. 0 value for expDays means no expiry.
+// Empty contType uses the media type of the key's extension.
+func UploadToS3(svc *s3.S3, body io.Reader, key string, contType string, expDays int) error {
+	if contType == "" {
+		contType = MediaTypeByExtension(key)
+	}
+	if contType == "" {
+		contType = "application/octet-stream"
+	}
 	var expirationDate *time.Time
 	if expDays != 0 {
 		expDate := time.Now().AddDate(0, 0, expDays)
 		expirationDate = &expDate
 	}
 
-	// Upload the data to S3
-	_, err := svc.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(os.Getenv("STORAGE_BUCKET")),
+	uploader := s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = s3PartSize
+		u.Concurrency = s3UploadConcurrency
+		u.RequestOptions = append(u.RequestOptions, func(r *request.Request) {
+			r.Retryer = client.DefaultRetryer{NumMaxRetries: s3UploadRetries}
+		})
+	})
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(config.Get().Storage.Bucket),
 		Key:         aws.String(key),
-		Body:        bytes.NewReader(b),
+		Body:        body,
 		Expires:     expirationDate,
 		ContentType: &contType,
 	})
-
-	if err != nil {
-		// to do log error
-		return err
-	}
-	// to do log
-	return nil
+	return err
 }
 
 // Check if an S3 Key exists
 func KeyExists(key string, svc *s3.S3) (bool, error) {
 	_, err := svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(os.Getenv("STORAGE_BUCKET")),
+		Bucket: aws.String(config.Get().Storage.Bucket),
 		Key:    aws.String(key),
 	})
 
@@ -64,6 +101,58 @@ func KeyExists(key string, svc *s3.S3) (bool, error) {
 	return true, nil
 }
 
+// Move an S3 object within the storage bucket, the source is deleted once it is copied
+func MoveS3Object(svc *s3.S3, srcKey, dstKey string) error {
+	bucket := config.Get().Storage.Bucket
+	_, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		CopySource: aws.String(url.PathEscape(bucket + "/" + srcKey)),
+		Key:        aws.String(dstKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(srcKey),
+	})
+	return err
+}
+
+// Max size of objects CopyObject can copy, larger objects need multipart copies
+const s3MaxCopySize = 5 * 1024 * 1024 * 1024
+
+// SetS3ContentType replaces the Content-Type of an object by copying it onto itself, user metadata is kept.
+// Without replace only generic content types (see GenericContentType) are replaced. It is a no-op if the object already has contentType.
+func SetS3ContentType(svc *s3.S3, bucket, key, contentType string, replace bool) error {
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	current := aws.StringValue(head.ContentType)
+	if current == contentType || (!replace && !GenericContentType(current)) {
+		return nil
+	}
+	if aws.Int64Value(head.ContentLength) > s3MaxCopySize {
+		return errors.New("object is too large to be copied in place")
+	}
+	_, err = svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		CopySource:        aws.String(url.PathEscape(bucket + "/" + key)),
+		Key:               aws.String(key),
+		ContentType:       aws.String(contentType),
+		Metadata:          head.Metadata,
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		// replacing metadata drops these unless they are set again
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+	})
+	return err
+}
+
 // Check if a string is in string slice
 func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
@@ -78,7 +167,7 @@ func StringInSlice(a string, list []string) bool {
 func GetS3JsonData(key string, svc *s3.S3) (interface{}, error) {
 	// Create a new S3GetObjectInput object to specify the file you want to read
 	params := &s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("STORAGE_BUCKET")),
+		Bucket: aws.String(config.Get().Storage.Bucket),
 		Key:    aws.String(key),
 	}
 
@@ -107,11 +196,39 @@ func GetS3JsonData(key string, svc *s3.S3) (interface{}, error) {
 	return data, nil
 }
 
+// GetS3Object returns the object at key with a streamed body, callers must close it.
+// byteRange is an HTTP Range header value, e.g. `bytes=0-1023`, empty returns the whole object.
+func GetS3Object(key, byteRange string, svc *s3.S3) (*s3.GetObjectOutput, error) {
+	out, _, err := GetS3ObjectIfNoneMatch(key, byteRange, "", svc)
+	return out, err
+}
+
+// GetS3ObjectIfNoneMatch is GetS3Object with an HTTP If-None-Match header value passed to storage.
+// If the ETag of the object matches it, notModified is true and no object is returned.
+func GetS3ObjectIfNoneMatch(key, byteRange, ifNoneMatch string, svc *s3.S3) (out *s3.GetObjectOutput, notModified bool, err error) {
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(config.Get().Storage.Bucket),
+		Key:    aws.String(key),
+	}
+	if byteRange != "" {
+		params.Range = aws.String(byteRange)
+	}
+	if ifNoneMatch != "" {
+		params.IfNoneMatch = aws.String(ifNoneMatch)
+	}
+	out, err = svc.GetObject(params)
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) && rerr.StatusCode() == http.StatusNotModified {
+		return nil, true, nil
+	}
+	return out, false, err
+}
+
 // Assumes file exist
 func GetS3LinesData(key string, svc *s3.S3) ([]string, error) {
 	// Create a new S3GetObjectInput object to specify the file you want to read
 	params := &s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("STORAGE_BUCKET")),
+		Bucket: aws.String(config.Get().Storage.Bucket),
 		Key:    aws.String(key),
 	}
 